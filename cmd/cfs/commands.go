@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"path"
+	"strings"
+	"time"
+)
+
+func runLs(ctx context.Context, a *app, args []string) error {
+	args, err := a.parse(a.flags("ls", "[REMOTE...]"), args, 0, -1)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = []string{"/"}
+	}
+
+	var listed []client.Resource
+
+	for _, pattern := range args {
+		resources, err := expandRemote(ctx, a.client, pattern)
+		if err != nil {
+			return err
+		}
+
+		// the single directory is listed, matches of the pattern are shown as they are
+		if len(resources) == 1 && resources[0].IsDirectory() && !hasMeta(pattern) {
+			entries, err := a.client.Directory(ctx, resources[0].Path)
+			if err != nil {
+				return err
+			}
+
+			listed = append(listed, entries...)
+
+			continue
+		}
+
+		listed = append(listed, resources...)
+	}
+
+	return a.out.resources(listed)
+}
+
+func runFind(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("find", "[QUERY]")
+	name := flags.String("name", "", "glob pattern of the name, e.g. \"*.pdf\"")
+	kind := flags.String("type", "", "f for files, d for directories")
+	in := flags.String("in", "/", "directory to search in")
+
+	args, err := a.parse(flags, args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	query := ""
+	if len(args) == 1 {
+		query = args[0]
+	}
+
+	// the API searches by a part of the path, the longest literal part of the pattern narrows it
+	if query == "" {
+		query = literal(*name)
+	}
+
+	if query == "" {
+		return errors.New("query or --name with a literal part is required")
+	}
+
+	if *kind != "" && *kind != "f" && *kind != "d" {
+		return fmt.Errorf("--type must be f or d, got %q", *kind)
+	}
+
+	resources, err := a.client.Search(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	dir := remoteDir(*in)
+	found := []client.Resource{}
+
+	for _, r := range resources {
+		if !strings.HasPrefix(r.Path, dir) || r.Path == dir {
+			continue
+		}
+
+		if (*kind == "f" && r.IsDirectory()) || (*kind == "d" && !r.IsDirectory()) {
+			continue
+		}
+
+		if *name != "" {
+			if ok, _ := path.Match(*name, r.Name); !ok {
+				continue
+			}
+		}
+
+		found = append(found, r)
+	}
+
+	return a.out.resources(found)
+}
+
+// literal returns the longest part of the glob pattern without special characters
+func literal(pattern string) string {
+	longest := ""
+
+	for _, part := range strings.FieldsFunc(pattern, func(r rune) bool { return strings.ContainsRune("*?[]\\", r) }) {
+		if len(part) > len(longest) {
+			longest = part
+		}
+	}
+
+	return longest
+}
+
+func runMkdir(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("mkdir", "REMOTE_DIR...")
+	parents := flags.BoolP("parents", "p", false, "create parent directories as needed")
+
+	args, err := a.parse(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	var created []client.Resource
+
+	for _, arg := range args {
+		dirs := []string{remoteDir(arg)}
+
+		if *parents {
+			dirs = dirs[:0]
+
+			current := "/"
+			for _, segment := range strings.Split(strings.Trim(remoteDir(arg), "/"), "/") {
+				current += segment + "/"
+				dirs = append(dirs, current)
+			}
+		}
+
+		for _, dir := range dirs {
+			r, err := a.client.CreateDirectory(ctx, dir)
+			if err != nil {
+				return fmt.Errorf("%s: %w", dir, err)
+			}
+
+			created = append(created, r)
+		}
+	}
+
+	return a.out.resources(created)
+}
+
+// moved is the result of mv
+type moved struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+func runMv(ctx context.Context, a *app, args []string) error {
+	args, err := a.parse(a.flags("mv", "REMOTE_SRC... REMOTE_DST"), args, 2, -1)
+	if err != nil {
+		return err
+	}
+
+	dst := args[len(args)-1]
+	toDir := len(args) > 2 || strings.HasSuffix(dst, "/")
+
+	var sources []client.Resource
+
+	for _, pattern := range args[:len(args)-1] {
+		resources, err := expandRemote(ctx, a.client, pattern)
+		if err != nil {
+			return err
+		}
+
+		sources = append(sources, resources...)
+	}
+
+	if len(sources) > 1 {
+		toDir = true
+	}
+
+	var result []moved
+	var rows [][]string
+
+	for _, r := range sources {
+		target := path.Clean("/" + dst)
+		if toDir {
+			target = remoteDir(dst) + r.Name
+		}
+
+		if r.IsDirectory() {
+			target = remoteDir(target)
+		}
+
+		if err := a.client.Move(ctx, r.Path, target); err != nil {
+			return fmt.Errorf("%s: %w", r.Path, err)
+		}
+
+		result = append(result, moved{From: r.Path, To: target})
+		rows = append(rows, []string{r.Path, target})
+	}
+
+	return a.out.print(result, []string{"FROM", "TO"}, rows)
+}
+
+func runRm(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("rm", "REMOTE...")
+	recursive := flags.BoolP("recursive", "r", false, "delete directories with their content")
+
+	args, err := a.parse(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	var targets []client.Resource
+
+	for _, pattern := range args {
+		resources, err := expandRemote(ctx, a.client, pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range resources {
+			if r.Path == "/" {
+				return errors.New("the root directory can't be deleted")
+			}
+
+			if r.IsDirectory() && !*recursive {
+				return fmt.Errorf("%s is a directory, use -r", r.Path)
+			}
+		}
+
+		targets = append(targets, resources...)
+	}
+
+	for _, r := range targets {
+		if err := a.client.Delete(ctx, r.Path); err != nil {
+			return fmt.Errorf("%s: %w", r.Path, err)
+		}
+	}
+
+	return a.out.resources(targets)
+}
+
+// shared is the result of share
+type shared struct {
+	Path      string `json:"path"`
+	Token     string `json:"token"`
+	TokenId   int64  `json:"token_id"`
+	ExpiresAt string `json:"expires_at"`
+	Server    string `json:"server"`
+}
+
+// runShare creates the personal access token which can only read the file or the directory
+func runShare(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("share", "REMOTE")
+	expires := flags.Duration("expires", 7*24*time.Hour, "how long the token is valid")
+	name := flags.String("name", "", "name of the token, \"share PATH\" by default")
+
+	args, err := a.parse(flags, args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	if *expires <= 0 {
+		return errors.New("--expires must be positive")
+	}
+
+	r, err := statRemote(ctx, a.client, args[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+
+	if *name == "" {
+		*name = "share " + r.Path
+	}
+
+	token, err := a.client.CreateAccessToken(ctx, client.AccessTokenRequest{
+		Name:      *name,
+		Scopes:    []string{"read"},
+		Folder:    r.Path,
+		ExpiresAt: time.Now().Add(*expires),
+	})
+	if err != nil {
+		return err
+	}
+
+	result := shared{
+		Path:      r.Path,
+		Token:     token.Token,
+		TokenId:   token.Id,
+		ExpiresAt: token.ExpiresAt,
+		Server:    a.creds.Server,
+	}
+
+	return a.out.print(result, nil, [][]string{
+		{"Path:", r.Path},
+		{"Token:", token.Token},
+		{"Expires:", token.ExpiresAt},
+		{"Download:", fmt.Sprintf("CFS_SERVER=%s CFS_TOKEN=%s cfs get -r %s", a.creds.Server, token.Token, r.Path)},
+		{"Revoke:", fmt.Sprintf("DELETE /api/user/tokens/%d", token.Id)},
+	})
+}
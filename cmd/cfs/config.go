@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// credentials are saved by login in the config dir of the user, e.g. ~/.config/cfs/credentials.json
+type credentials struct {
+	Server string `json:"server"`
+	// AccessToken is the token of the session or a personal access token
+	AccessToken string `json:"access_token,omitempty"`
+	// RefreshToken is empty for personal access tokens
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+var errNotLoggedIn = errors.New("not logged in, run: cfs login")
+
+// credentialsPath returns the path of the credentials, CFS_CONFIG overrides it
+func credentialsPath(override string) (string, error) {
+	if override != "" {
+		return override, nil
+	}
+
+	if path := os.Getenv("CFS_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "cfs", "credentials.json"), nil
+}
+
+// loadCredentials reads the saved credentials, CFS_SERVER and CFS_TOKEN override them, e.g. in CI
+func loadCredentials(path string) (credentials, error) {
+	var creds credentials
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return creds, err
+	}
+
+	if err == nil {
+		if err := json.Unmarshal(data, &creds); err != nil {
+			return creds, err
+		}
+	}
+
+	if server := os.Getenv("CFS_SERVER"); server != "" {
+		creds.Server = server
+	}
+
+	if token := os.Getenv("CFS_TOKEN"); token != "" {
+		creds.AccessToken, creds.RefreshToken = token, ""
+	}
+
+	return creds, nil
+}
+
+// saveCredentials writes the credentials readable only by the user
+func saveCredentials(path string, creds credentials) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
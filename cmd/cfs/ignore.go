@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile lists patterns of files which aren't uploaded or synced, in the root of the local directory
+const ignoreFile = ".cfsignore"
+
+// ignoreRule is the line of .cfsignore in the gitignore syntax: "#" starts comments, "!" includes
+// the ignored path again, the trailing "/" matches only directories, the pattern with "/" inside
+// is relative to the root, "**" matches any number of directories
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+type ignoreRules []ignoreRule
+
+// loadIgnore reads .cfsignore of the directory, no rules if it doesn't exist
+func loadIgnore(dir string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(dir, ignoreFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	var rules ignoreRules
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+			rules = append(rules, rule)
+		}
+	}
+
+	return rules, scanner.Err()
+}
+
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var rule ignoreRule
+
+	if rest, ok := strings.CutPrefix(line, "!"); ok {
+		rule.negate = true
+		line = rest
+	}
+
+	if rest, ok := strings.CutSuffix(line, "/"); ok {
+		rule.dirOnly = true
+		line = rest
+	}
+
+	if rest, ok := strings.CutPrefix(line, "/"); ok {
+		rule.anchored = true
+		line = rest
+	}
+
+	if strings.Contains(line, "/") {
+		rule.anchored = true
+	}
+
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	rule.pattern = line
+
+	return rule, true
+}
+
+// ignored checks the path relative to the root with / separators, the last matching rule wins.
+// Walkers skip ignored directories, so paths inside them aren't checked
+func (rules ignoreRules) ignored(rel string, isDir bool) bool {
+	rel = strings.Trim(rel, "/")
+	if rel == ignoreFile {
+		return true
+	}
+
+	ignored := false
+
+	for _, rule := range rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+
+		if rule.match(rel) {
+			ignored = !rule.negate
+		}
+	}
+
+	return ignored
+}
+
+func (rule ignoreRule) match(rel string) bool {
+	if !rule.anchored {
+		ok, _ := path.Match(rule.pattern, path.Base(rel))
+
+		return ok
+	}
+
+	return matchSegments(strings.Split(rule.pattern, "/"), strings.Split(rel, "/"))
+}
+
+// matchSegments matches the path by segments, "**" matches zero or more of them
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
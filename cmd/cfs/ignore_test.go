@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+
+	content := `# build output
+*.log
+!keep.log
+node_modules/
+/dist
+docs/**/*.tmp
+`
+	if err := os.WriteFile(filepath.Join(dir, ignoreFile), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := loadIgnore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{path: "app.log", ignored: true},
+		{path: "src/app.log", ignored: true},
+		{path: "keep.log", ignored: false},
+		{path: "node_modules", isDir: true, ignored: true},
+		{path: "src/node_modules", isDir: true, ignored: true},
+		{path: "node_modules", isDir: false, ignored: false},
+		{path: "dist", isDir: true, ignored: true},
+		{path: "src/dist", isDir: true, ignored: false},
+		{path: "docs/a.tmp", ignored: true},
+		{path: "docs/a/b/c.tmp", ignored: true},
+		{path: "src/a.tmp", ignored: false},
+		{path: "main.go", ignored: false},
+		{path: ignoreFile, ignored: true},
+	} {
+		if got := rules.ignored(tc.path, tc.isDir); got != tc.ignored {
+			t.Errorf("%s (dir: %v) must be ignored: %v, got: %v", tc.path, tc.isDir, tc.ignored, got)
+		}
+	}
+
+	missing, err := loadIgnore(t.TempDir())
+	if err != nil || missing.ignored("app.log", false) {
+		t.Errorf("directory without %s must not ignore files, got: %v", ignoreFile, err)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+)
+
+func runLogin(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("login", "")
+	server := flags.String("server", a.creds.Server, "URL of the API, e.g. https://files.example.com")
+	email := flags.String("email", "", "email of the account, asked if not set")
+	token := flags.String("token", "", "personal access token instead of the email and the password")
+	passwordStdin := flags.Bool("password-stdin", false, "read the password from stdin")
+
+	if _, err := a.parse(flags, args, 0, 0); err != nil {
+		return err
+	}
+
+	if *server == "" {
+		return errors.New("--server is required")
+	}
+
+	c, err := client.New(*server, client.WithUserAgent("cfs"), client.WithAccessToken(*token))
+	if err != nil {
+		return err
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+
+	if *token == "" {
+		if *email == "" {
+			if *email, err = prompt(stdin, "Email: "); err != nil {
+				return err
+			}
+		}
+
+		password, err := readPassword(stdin, *passwordStdin)
+		if err != nil {
+			return err
+		}
+
+		if err := c.SignIn(ctx, *email, password); err != nil {
+			return err
+		}
+	}
+
+	profile, err := c.Me(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = saveCredentials(a.credsPath, credentials{
+		Server:       *server,
+		AccessToken:  c.AccessToken(),
+		RefreshToken: c.RefreshToken(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return a.out.print(
+		map[string]string{"server": *server, "email": profile.Email},
+		nil,
+		[][]string{{fmt.Sprintf("Logged in to %s as %s", *server, profile.Email)}},
+	)
+}
+
+func runLogout(ctx context.Context, a *app, args []string) error {
+	if _, err := a.parse(a.flags("logout", ""), args, 0, 0); err != nil {
+		return err
+	}
+
+	// the session is ended on the server, personal access tokens stay valid until they are deleted
+	if a.creds.Server != "" && a.creds.RefreshToken != "" {
+		c, err := client.New(a.creds.Server, client.WithRefreshToken(a.creds.RefreshToken))
+		if err != nil {
+			return err
+		}
+
+		if err := c.SignOut(ctx); err != nil && !errors.Is(err, client.ErrUnauthorized) {
+			return err
+		}
+	}
+
+	if err := os.Remove(a.credsPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func prompt(r *bufio.Reader, label string) (string, error) {
+	_, _ = fmt.Fprint(os.Stderr, label)
+
+	line, err := r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
+// readPassword reads the password without echo from the terminal, or the line of stdin
+func readPassword(r *bufio.Reader, fromStdin bool) (string, error) {
+	fd := int(os.Stdin.Fd())
+
+	if fromStdin || !term.IsTerminal(fd) {
+		line, err := r.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", err
+		}
+
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	_, _ = fmt.Fprint(os.Stderr, "Password: ")
+
+	password, err := term.ReadPassword(fd)
+
+	_, _ = fmt.Fprintln(os.Stderr)
+
+	return string(password), err
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"github.com/spf13/pflag"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+)
+
+const usage = `usage: cfs [--json] [--quiet] [--config PATH] <command> [flags] [args]
+
+commands:
+  login     sign in and save credentials
+  logout    end the session and forget credentials
+  ls        list directories and files
+  find      search files by name
+  mkdir     create directories
+  put       upload files and directories
+  get       download files and directories
+  mv        move or rename files and directories
+  cp        copy files and directories
+  rm        delete files and directories
+  share     create a read-only token for a file or a directory
+  sync      make a remote directory the same as a local one, or back
+
+Remote paths start with /, directories end with /. Remote paths can be glob patterns,
+e.g. "/docs/*.pdf", quote them so the shell doesn't expand them.
+Run "cfs <command> --help" for flags of the command.`
+
+var errUsage = errors.New("usage")
+
+type command struct {
+	run func(ctx context.Context, a *app, args []string) error
+	// public commands work without saved credentials
+	public bool
+}
+
+var commands = map[string]command{
+	"login":  {run: runLogin, public: true},
+	"logout": {run: runLogout, public: true},
+	"ls":     {run: runLs},
+	"find":   {run: runFind},
+	"mkdir":  {run: runMkdir},
+	"put":    {run: runPut},
+	"get":    {run: runGet},
+	"mv":     {run: runMv},
+	"cp":     {run: runCp},
+	"rm":     {run: runRm},
+	"share":  {run: runShare},
+	"sync":   {run: runSync},
+}
+
+// app is the state shared by commands
+type app struct {
+	global    *pflag.FlagSet
+	json      bool
+	quiet     bool
+	config    string
+	credsPath string
+	creds     credentials
+	client    *client.Client
+	out       output
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+	err := run(ctx, os.Args[1:])
+
+	stop()
+
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "cfs:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string) error {
+	a := &app{global: pflag.NewFlagSet("cfs", pflag.ContinueOnError)}
+	a.global.BoolVar(&a.json, "json", false, "print results as JSON")
+	a.global.BoolVarP(&a.quiet, "quiet", "q", false, "don't show progress and results")
+	a.global.StringVar(&a.config, "config", "", "path of the credentials, CFS_CONFIG by default")
+	a.global.SetInterspersed(false)
+	a.global.Usage = func() {
+		_, _ = fmt.Fprintln(os.Stderr, usage)
+	}
+
+	if err := a.global.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+
+		return errUsage
+	}
+
+	if a.global.NArg() == 0 {
+		a.global.Usage()
+
+		return errUsage
+	}
+
+	name := a.global.Arg(0)
+
+	cmd, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		_, _ = fmt.Fprintf(os.Stderr, "cfs: unknown command %q, commands: %v\n", name, names)
+
+		return errUsage
+	}
+
+	var err error
+
+	a.credsPath, err = credentialsPath(a.config)
+	if err != nil {
+		return err
+	}
+
+	a.creds, err = loadCredentials(a.credsPath)
+	if err != nil {
+		return fmt.Errorf("read credentials: %w", err)
+	}
+
+	if !cmd.public {
+		if err := a.connect(); err != nil {
+			return err
+		}
+	}
+
+	err = cmd.run(ctx, a, a.global.Args()[1:])
+
+	// the session got the new access token, the next run starts with it
+	if a.client != nil && a.creds.RefreshToken != "" && a.client.AccessToken() != a.creds.AccessToken {
+		a.creds.AccessToken = a.client.AccessToken()
+		a.creds.RefreshToken = a.client.RefreshToken()
+
+		if saveErr := saveCredentials(a.credsPath, a.creds); saveErr != nil && err == nil {
+			err = saveErr
+		}
+	}
+
+	if errors.Is(err, pflag.ErrHelp) {
+		return nil
+	}
+
+	if errors.Is(err, client.ErrUnauthorized) {
+		return fmt.Errorf("%w, the session has expired, run: cfs login", err)
+	}
+
+	return err
+}
+
+// connect creates the client with the saved credentials
+func (a *app) connect() error {
+	if a.creds.Server == "" || (a.creds.AccessToken == "" && a.creds.RefreshToken == "") {
+		return errNotLoggedIn
+	}
+
+	c, err := client.New(
+		a.creds.Server,
+		client.WithAccessToken(a.creds.AccessToken),
+		client.WithRefreshToken(a.creds.RefreshToken),
+		client.WithUserAgent("cfs"),
+	)
+	if err != nil {
+		return err
+	}
+
+	a.client = c
+
+	return nil
+}
+
+// flags returns flags of the command with the global ones, so they can follow the command too
+func (a *app) flags(name, args string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	flags.AddFlagSet(a.global)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(os.Stderr, "usage: cfs %s [flags] %s\n\nflags:\n%s", name, args, flags.FlagUsages())
+	}
+
+	return flags
+}
+
+// parse parses flags of the command and checks the number of arguments, maxArgs < 0 is unlimited
+func (a *app) parse(flags *pflag.FlagSet, args []string, minArgs, maxArgs int) ([]string, error) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil, err
+		}
+
+		return nil, errUsage
+	}
+
+	a.out = output{w: os.Stdout, json: a.json, quiet: a.quiet}
+
+	if flags.NArg() < minArgs || (maxArgs >= 0 && flags.NArg() > maxArgs) {
+		flags.Usage()
+
+		return nil, errUsage
+	}
+
+	return flags.Args(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// output prints results as the table or as JSON
+type output struct {
+	w     io.Writer
+	json  bool
+	quiet bool
+}
+
+// print writes the value as JSON, or the rows under the header as the table
+func (o output) print(v any, header []string, rows [][]string) error {
+	if o.quiet {
+		return nil
+	}
+
+	if o.json {
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	}
+
+	if len(rows) == 0 {
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+
+	if header != nil {
+		_, _ = fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+
+	for _, row := range rows {
+		_, _ = fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+func (o output) resources(resources []client.Resource) error {
+	if resources == nil {
+		resources = []client.Resource{}
+	}
+
+	table := make([][]string, 0, len(resources))
+	for _, r := range resources {
+		table = append(table, []string{resourceType(r), humanSize(r.Size, r.IsDirectory()), r.Path})
+	}
+
+	return o.print(resources, []string{"TYPE", "SIZE", "PATH"}, table)
+}
+
+func resourceType(r client.Resource) string {
+	if r.IsDirectory() {
+		return "dir"
+	}
+
+	return "file"
+}
+
+// humanSize formats bytes in binary units, sizes of directories aren't known
+func humanSize(n int64, isDir bool) string {
+	if isDir {
+		return "-"
+	}
+
+	if n < 1024 {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+
+	value := float64(n)
+	for _, unit := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= 1024
+		if value < 1024 || unit == "TiB" {
+			return fmt.Sprintf("%.1f %s", value, unit)
+		}
+	}
+
+	return strconv.FormatInt(n, 10)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	progressInterval = 200 * time.Millisecond
+	progressWidth    = 30
+)
+
+// progress draws one bar for all files of the command on the terminal, parallel transfers add to it
+type progress struct {
+	w     io.Writer
+	total int64
+	files int
+	once  sync.Once
+
+	done      atomic.Int64
+	filesDone atomic.Int64
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newProgress starts drawing the bar if stderr is a terminal and the output isn't quiet or JSON
+func newProgress(a *app, files int, total int64) *progress {
+	p := &progress{total: total, files: files, stop: make(chan struct{})}
+
+	if a.quiet || a.json || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return p
+	}
+
+	p.w = os.Stderr
+	p.wg.Add(1)
+
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-p.stop:
+				return
+			case <-ticker.C:
+				p.draw()
+			}
+		}
+	}()
+
+	return p
+}
+
+// track returns the option of the transfer of one file, the resumed download starts from the offset
+func (p *progress) track(offset int64) client.TransferOption {
+	last := offset
+
+	return client.WithProgress(func(transferred, _ int64) {
+		p.done.Add(transferred - last)
+		last = transferred
+	})
+}
+
+func (p *progress) fileDone() {
+	p.filesDone.Add(1)
+}
+
+// finish draws the last state and moves to the next line, it's called once or more
+func (p *progress) finish() {
+	if p.w == nil {
+		return
+	}
+
+	p.once.Do(func() {
+		close(p.stop)
+		p.wg.Wait()
+
+		p.draw()
+		_, _ = fmt.Fprintln(p.w)
+	})
+}
+
+func (p *progress) draw() {
+	done := min(p.done.Load(), p.total)
+
+	ratio := 1.0
+	if p.total > 0 {
+		ratio = float64(done) / float64(p.total)
+	}
+
+	filled := int(ratio * progressWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressWidth-filled)
+	if filled > 0 && filled < progressWidth {
+		bar = strings.Repeat("=", filled-1) + ">" + strings.Repeat(" ", progressWidth-filled)
+	}
+
+	_, _ = fmt.Fprintf(
+		p.w,
+		"\r[%s] %3.0f%%  %s / %s  %d/%d files ",
+		bar,
+		ratio*100,
+		humanSize(done, false),
+		humanSize(p.total, false),
+		p.filesDone.Load(),
+		p.files,
+	)
+}
+
+// parallel runs fn for n items in so many workers, items aren't started after the first error
+func parallel(ctx context.Context, workers, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	items := make(chan int)
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for range max(min(workers, n), 1) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range items {
+				if err := fn(ctx, i); err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case items <- i:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	close(items)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// hasMeta checks if the path is a glob pattern
+func hasMeta(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+// remoteDir returns the path of the directory in the API form, with / at both ends
+func remoteDir(p string) string {
+	p = path.Clean("/" + p)
+	if p == "/" {
+		return p
+	}
+
+	return p + "/"
+}
+
+// expandRemote returns resources matching the path or the glob pattern, e.g. /docs/*/report-??.pdf.
+// Paths of directories end with /
+func expandRemote(ctx context.Context, c *client.Client, pattern string) ([]client.Resource, error) {
+	if !hasMeta(pattern) {
+		if strings.HasSuffix(pattern, "/") {
+			dir := remoteDir(pattern)
+
+			return []client.Resource{{Path: dir, Name: path.Base(dir), Type: client.TypeDirectory}}, nil
+		}
+
+		r, err := statRemote(ctx, c, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", pattern, err)
+		}
+
+		return []client.Resource{r}, nil
+	}
+
+	segments := strings.Split(strings.Trim(path.Clean("/"+pattern), "/"), "/")
+	dirs := []string{"/"}
+
+	var matches []client.Resource
+
+	for i, segment := range segments {
+		last := i == len(segments)-1
+
+		var next []string
+
+		for _, dir := range dirs {
+			if !hasMeta(segment) && !last {
+				next = append(next, dir+segment+"/")
+
+				continue
+			}
+
+			entries, err := c.Directory(ctx, dir)
+			if err != nil {
+				if errors.Is(err, client.ErrNotFound) {
+					continue
+				}
+
+				return nil, err
+			}
+
+			for _, entry := range entries {
+				if ok, _ := path.Match(segment, entry.Name); !ok {
+					continue
+				}
+
+				if last {
+					matches = append(matches, entry)
+				} else if entry.IsDirectory() {
+					next = append(next, entry.Path)
+				}
+			}
+		}
+
+		dirs = next
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("%s: no matches", pattern)
+	}
+
+	return matches, nil
+}
+
+// statRemote returns the file or the directory at the path, the directory can be given without the trailing /.
+// Directories created by uploads of nested files exist only as prefixes of their files
+func statRemote(ctx context.Context, c *client.Client, p string) (client.Resource, error) {
+	p = path.Clean("/" + p)
+
+	r, err := c.Resource(ctx, p)
+	if !errors.Is(err, client.ErrNotFound) || p == "/" {
+		return r, err
+	}
+
+	dir := remoteDir(p)
+
+	if r, err := c.Resource(ctx, dir); !errors.Is(err, client.ErrNotFound) {
+		return r, err
+	}
+
+	entries, dirErr := c.Directory(ctx, dir)
+	if dirErr != nil || len(entries) == 0 {
+		return client.Resource{}, err
+	}
+
+	return client.Resource{Path: dir, Name: path.Base(dir), Type: client.TypeDirectory}, nil
+}
+
+// walkRemote calls fn for every file and directory under the directory, fs.SkipDir returned for
+// a directory skips its content
+func walkRemote(ctx context.Context, c *client.Client, dir string, fn func(r client.Resource) error) error {
+	entries, err := c.Directory(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		err := fn(entry)
+		if errors.Is(err, fs.SkipDir) && entry.IsDirectory() {
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if entry.IsDirectory() {
+			if err := walkRemote(ctx, c, entry.Path, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// relative returns the path under the directory without the leading /
+func relative(dir, p string) string {
+	return strings.TrimPrefix(p, remoteDir(dir))
+}
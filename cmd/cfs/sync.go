@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	actionUpload   = "upload"
+	actionDownload = "download"
+	actionDelete   = "delete"
+)
+
+// syncAction is the change which makes the destination the same as the source
+type syncAction struct {
+	Action string `json:"action"`
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+}
+
+func runSync(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("sync", "LOCAL_DIR REMOTE_DIR")
+	down := flags.Bool("download", false, "make the local directory the same as the remote one")
+	del := flags.Bool("delete", false, "delete files which aren't in the source")
+	dryRun := flags.BoolP("dry-run", "n", false, "print changes without making them")
+	jobs := flags.IntP("jobs", "j", defaultJobs, "files transferred in parallel")
+
+	args, err := a.parse(flags, args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	local, err := filepath.Abs(args[0])
+	if err != nil {
+		return err
+	}
+
+	remote := remoteDir(args[1])
+
+	if err := os.MkdirAll(local, 0755); err != nil {
+		return err
+	}
+
+	// .cfsignore of the local directory applies to both sides, ignored files are never deleted
+	rules, err := loadIgnore(local)
+	if err != nil {
+		return err
+	}
+
+	localFiles := map[string]int64{}
+
+	err = walkLocal(local, rules, func(rel string, info fs.FileInfo) {
+		localFiles[rel] = info.Size()
+	})
+	if err != nil {
+		return err
+	}
+
+	remoteFiles := map[string]int64{}
+
+	err = walkRemote(ctx, a.client, remote, func(r client.Resource) error {
+		rel := relative(remote, r.Path)
+		if rules.ignored(rel, r.IsDirectory()) {
+			return fs.SkipDir
+		}
+
+		if !r.IsDirectory() {
+			remoteFiles[rel] = r.Size
+		}
+
+		return nil
+	})
+	if err != nil && !errors.Is(err, client.ErrNotFound) {
+		return err
+	}
+
+	var actions []syncAction
+	if *down {
+		actions = planSync(remoteFiles, localFiles, actionDownload, *del)
+	} else {
+		actions = planSync(localFiles, remoteFiles, actionUpload, *del)
+	}
+
+	if !*dryRun {
+		if err := a.applySync(ctx, actions, local, remote, *down, *jobs); err != nil {
+			return err
+		}
+	}
+
+	rows := make([][]string, 0, len(actions))
+	for _, action := range actions {
+		rows = append(rows, []string{action.Action, humanSize(action.Size, false), action.Path})
+	}
+
+	if actions == nil {
+		actions = []syncAction{}
+	}
+
+	return a.out.print(actions, []string{"ACTION", "SIZE", "PATH"}, rows)
+}
+
+// planSync returns transfers of files missing in the destination or of another size, and deletions of files
+// which aren't in the source. Files aren't compared by content, the API has no checksums
+func planSync(src, dst map[string]int64, transfer string, del bool) []syncAction {
+	var actions []syncAction
+
+	for rel, size := range src {
+		if dstSize, ok := dst[rel]; !ok || dstSize != size {
+			actions = append(actions, syncAction{Action: transfer, Path: rel, Size: size})
+		}
+	}
+
+	if del {
+		for rel, size := range dst {
+			if _, ok := src[rel]; !ok {
+				actions = append(actions, syncAction{Action: actionDelete, Path: rel, Size: size})
+			}
+		}
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		if actions[i].Path != actions[j].Path {
+			return actions[i].Path < actions[j].Path
+		}
+
+		return actions[i].Action < actions[j].Action
+	})
+
+	return actions
+}
+
+func (a *app) applySync(ctx context.Context, actions []syncAction, local, remote string, down bool, jobs int) error {
+	var uploads []upload
+	var downloads []download
+	var deletions []string
+
+	for _, action := range actions {
+		localPath := filepath.Join(local, filepath.FromSlash(action.Path))
+
+		switch action.Action {
+		case actionUpload:
+			uploads = append(uploads, upload{local: localPath, remote: remote + action.Path, size: action.Size})
+		case actionDownload:
+			downloads = append(downloads, download{remote: remote + action.Path, local: localPath, size: action.Size})
+		case actionDelete:
+			if down {
+				deletions = append(deletions, localPath)
+			} else {
+				deletions = append(deletions, remote+action.Path)
+			}
+		}
+	}
+
+	bar := newProgress(a, len(uploads)+len(downloads), uploadSize(uploads)+downloadSize(downloads))
+	defer bar.finish()
+
+	if _, err := a.upload(ctx, uploads, jobs, bar); err != nil {
+		return err
+	}
+
+	if _, err := a.download(ctx, downloads, jobs, bar); err != nil {
+		return err
+	}
+
+	for _, p := range deletions {
+		var err error
+		if down {
+			err = os.Remove(p)
+		} else {
+			err = a.client.Delete(ctx, p)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+
+	return nil
+}
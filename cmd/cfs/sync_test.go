@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanSync(t *testing.T) {
+	src := map[string]int64{"a.txt": 1, "dir/b.txt": 2, "dir/c.txt": 3}
+	dst := map[string]int64{"a.txt": 1, "dir/b.txt": 5, "old.txt": 4}
+
+	actions := planSync(src, dst, actionUpload, false)
+	expected := []syncAction{
+		{Action: actionUpload, Path: "dir/b.txt", Size: 2},
+		{Action: actionUpload, Path: "dir/c.txt", Size: 3},
+	}
+
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("changed and missing files must be uploaded, got: %+v", actions)
+	}
+
+	actions = planSync(src, dst, actionUpload, true)
+	expected = append(expected, syncAction{Action: actionDelete, Path: "old.txt", Size: 4})
+
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("files missing in the source must be deleted, got: %+v", actions)
+	}
+
+	if actions := planSync(src, src, actionDownload, true); actions != nil {
+		t.Errorf("same directories must have no changes, got: %+v", actions)
+	}
+}
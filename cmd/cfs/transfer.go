@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/pkg/client"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const defaultJobs = 4
+
+// upload is the local file uploaded to the remote path
+type upload struct {
+	local  string
+	remote string
+	size   int64
+}
+
+// download is the remote file downloaded to the local path
+type download struct {
+	remote string
+	local  string
+	size   int64
+}
+
+func runPut(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("put", "LOCAL... REMOTE_DIR")
+	recursive := flags.BoolP("recursive", "r", false, "upload directories with their content, .cfsignore is honored")
+	jobs := flags.IntP("jobs", "j", defaultJobs, "files uploaded in parallel")
+
+	args, err := a.parse(flags, args, 2, -1)
+	if err != nil {
+		return err
+	}
+
+	dst := remoteDir(args[len(args)-1])
+
+	var uploads []upload
+
+	for _, pattern := range args[:len(args)-1] {
+		paths := []string{pattern}
+		if hasMeta(pattern) {
+			paths, err = filepath.Glob(pattern)
+			if err != nil {
+				return err
+			}
+
+			if len(paths) == 0 {
+				return fmt.Errorf("%s: no matches", pattern)
+			}
+		}
+
+		for _, p := range paths {
+			found, err := localUploads(p, dst, *recursive)
+			if err != nil {
+				return err
+			}
+
+			uploads = append(uploads, found...)
+		}
+	}
+
+	bar := newProgress(a, len(uploads), uploadSize(uploads))
+
+	stored, err := a.upload(ctx, uploads, *jobs, bar)
+
+	bar.finish()
+
+	if err != nil {
+		return err
+	}
+
+	return a.out.resources(stored)
+}
+
+// localUploads returns the file, or files of the directory with their relative paths under the remote directory
+func localUploads(p, dst string, recursive bool) ([]upload, error) {
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []upload{{local: p, remote: dst + filepath.Base(p), size: info.Size()}}, nil
+	}
+
+	if !recursive {
+		return nil, fmt.Errorf("%s is a directory, use -r", p)
+	}
+
+	root, err := filepath.Abs(p)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := loadIgnore(root)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []upload
+
+	base := dst + filepath.Base(root) + "/"
+
+	err = walkLocal(root, rules, func(rel string, info fs.FileInfo) {
+		uploads = append(uploads, upload{local: filepath.Join(root, rel), remote: base + rel, size: info.Size()})
+	})
+
+	return uploads, err
+}
+
+// walkLocal calls fn for files under the root which aren't ignored, with paths relative to the root
+func walkLocal(root string, rules ignoreRules, fn func(rel string, info fs.FileInfo)) error {
+	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == root {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		rel = filepath.ToSlash(rel)
+
+		if rules.ignored(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		fn(rel, info)
+
+		return nil
+	})
+}
+
+// upload uploads files in parallel and adds them to the progress bar
+func (a *app) upload(ctx context.Context, uploads []upload, jobs int, bar *progress) ([]client.Resource, error) {
+	stored := make([]client.Resource, len(uploads))
+
+	err := parallel(ctx, jobs, len(uploads), func(ctx context.Context, i int) error {
+		u := uploads[i]
+
+		dir, name := path.Split(u.remote)
+
+		f, err := os.Open(u.local)
+		if err != nil {
+			return err
+		}
+		defer func(f *os.File) {
+			_ = f.Close()
+		}(f)
+
+		r, err := a.client.Upload(ctx, dir, name, f, u.size, bar.track(0))
+		if err != nil {
+			return fmt.Errorf("%s: %w", u.local, err)
+		}
+
+		stored[i] = r
+		bar.fileDone()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stored, nil
+}
+
+func runGet(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("get", "REMOTE... [LOCAL_DIR]")
+	recursive := flags.BoolP("recursive", "r", false, "download directories with their content")
+	jobs := flags.IntP("jobs", "j", defaultJobs, "files downloaded in parallel")
+
+	args, err := a.parse(flags, args, 1, -1)
+	if err != nil {
+		return err
+	}
+
+	dst := "."
+	if len(args) > 1 {
+		dst, args = args[len(args)-1], args[:len(args)-1]
+	}
+
+	var downloads []download
+
+	for _, pattern := range args {
+		resources, err := expandRemote(ctx, a.client, pattern)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range resources {
+			if !r.IsDirectory() {
+				downloads = append(downloads, download{remote: r.Path, local: filepath.Join(dst, r.Name), size: r.Size})
+
+				continue
+			}
+
+			if !*recursive {
+				return fmt.Errorf("%s is a directory, use -r", r.Path)
+			}
+
+			base := filepath.Join(dst, r.Name)
+
+			err := walkRemote(ctx, a.client, r.Path, func(f client.Resource) error {
+				if !f.IsDirectory() {
+					local := filepath.Join(base, filepath.FromSlash(relative(r.Path, f.Path)))
+					downloads = append(downloads, download{remote: f.Path, local: local, size: f.Size})
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	bar := newProgress(a, len(downloads), downloadSize(downloads))
+
+	downloaded, err := a.download(ctx, downloads, *jobs, bar)
+
+	bar.finish()
+
+	if err != nil {
+		return err
+	}
+
+	return a.out.resources(downloaded)
+}
+
+// download downloads files in parallel and adds them to the progress bar. Interrupted downloads
+// are resumed by running the command again
+func (a *app) download(ctx context.Context, downloads []download, jobs int, bar *progress) ([]client.Resource, error) {
+	downloaded := make([]client.Resource, len(downloads))
+
+	err := parallel(ctx, jobs, len(downloads), func(ctx context.Context, i int) error {
+		d := downloads[i]
+
+		if err := os.MkdirAll(filepath.Dir(d.local), 0755); err != nil {
+			return err
+		}
+
+		// the part downloaded before counts as transferred
+		var offset int64
+		if info, err := os.Stat(d.local + ".part"); err == nil {
+			offset = info.Size()
+			bar.done.Add(offset)
+		}
+
+		if err := a.client.DownloadFile(ctx, d.remote, d.local, bar.track(offset)); err != nil {
+			return fmt.Errorf("%s: %w", d.remote, err)
+		}
+
+		downloaded[i] = client.Resource{Path: d.local, Name: filepath.Base(d.local), Size: d.size, Type: client.TypeFile}
+		bar.fileDone()
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return downloaded, nil
+}
+
+func uploadSize(uploads []upload) int64 {
+	var total int64
+	for _, u := range uploads {
+		total += u.size
+	}
+
+	return total
+}
+
+func downloadSize(downloads []download) int64 {
+	var total int64
+	for _, d := range downloads {
+		total += d.size
+	}
+
+	return total
+}
+
+func runCp(ctx context.Context, a *app, args []string) error {
+	flags := a.flags("cp", "REMOTE_SRC... REMOTE_DST")
+	recursive := flags.BoolP("recursive", "r", false, "copy directories with their content")
+
+	args, err := a.parse(flags, args, 2, -1)
+	if err != nil {
+		return err
+	}
+
+	dst := args[len(args)-1]
+	toDir := len(args) > 2 || strings.HasSuffix(dst, "/") || hasMeta(args[0])
+
+	// files are copied through the temporary directory, the API has no copy
+	tmp, err := os.MkdirTemp("", "cfs-cp-")
+	if err != nil {
+		return err
+	}
+	defer func(tmp string) {
+		_ = os.RemoveAll(tmp)
+	}(tmp)
+
+	var copies []client.Resource
+
+	for _, pattern := range args[:len(args)-1] {
+		resources, err := expandRemote(ctx, a.client, pattern)
+		if err != nil {
+			return err
+		}
+
+		if len(resources) > 1 {
+			toDir = true
+		}
+
+		for _, r := range resources {
+			target := path.Clean("/" + dst)
+			if toDir {
+				target = remoteDir(dst) + r.Name
+			}
+
+			if !r.IsDirectory() {
+				copies = append(copies, client.Resource{Path: r.Path, Name: target, Size: r.Size})
+
+				continue
+			}
+
+			if !*recursive {
+				return fmt.Errorf("%s is a directory, use -r", r.Path)
+			}
+
+			err := walkRemote(ctx, a.client, r.Path, func(f client.Resource) error {
+				if !f.IsDirectory() {
+					copies = append(copies, client.Resource{
+						Path: f.Path,
+						Name: remoteDir(target) + relative(r.Path, f.Path),
+						Size: f.Size,
+					})
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	var downloads []download
+	for i, c := range copies {
+		downloads = append(downloads, download{
+			remote: c.Path,
+			local:  filepath.Join(tmp, fmt.Sprint(i), path.Base(c.Name)),
+			size:   c.Size,
+		})
+	}
+
+	var uploads []upload
+	for i, c := range copies {
+		uploads = append(uploads, upload{local: downloads[i].local, remote: c.Name, size: c.Size})
+	}
+
+	// every file is transferred twice
+	bar := newProgress(a, len(copies)*2, downloadSize(downloads)+uploadSize(uploads))
+	defer bar.finish()
+
+	if _, err := a.download(ctx, downloads, defaultJobs, bar); err != nil {
+		return err
+	}
+
+	stored, err := a.upload(ctx, uploads, defaultJobs, bar)
+	if err != nil {
+		return err
+	}
+
+	bar.finish()
+
+	return a.out.resources(stored)
+}
@@ -2,18 +2,66 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/db"
 	_ "github.com/albakov/go-cloud-file-storage/docs"
 	"github.com/albakov/go-cloud-file-storage/internal/api"
 	"github.com/albakov/go-cloud-file-storage/internal/config"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/metrics"
+	"github.com/albakov/go-cloud-file-storage/internal/migrator"
+	accesstokenservice "github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/account"
+	"github.com/albakov/go-cloud-file-storage/internal/service/admin"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/credentials"
+	"github.com/albakov/go-cloud-file-storage/internal/service/dav"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	exportservice "github.com/albakov/go-cloud-file-storage/internal/service/export"
+	"github.com/albakov/go-cloud-file-storage/internal/service/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/service/health"
+	jobservice "github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/journal"
 	"github.com/albakov/go-cloud-file-storage/internal/service/jwt"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	"github.com/albakov/go-cloud-file-storage/internal/service/mailer"
+	"github.com/albakov/go-cloud-file-storage/internal/service/maintenance"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/resourcejob"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	s3keyservice "github.com/albakov/go-cloud-file-storage/internal/service/s3key"
+	sftpservice "github.com/albakov/go-cloud-file-storage/internal/service/sftp"
+	sshkeyservice "github.com/albakov/go-cloud-file-storage/internal/service/sshkey"
+	"github.com/albakov/go-cloud-file-storage/internal/service/sso"
 	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
 	usersessionservice "github.com/albakov/go-cloud-file-storage/internal/service/usersession"
+	usertokenservice "github.com/albakov/go-cloud-file-storage/internal/service/usertoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/verification"
+	webhookservice "github.com/albakov/go-cloud-file-storage/internal/service/webhook"
+	"github.com/albakov/go-cloud-file-storage/internal/shutdown"
 	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/auditlog"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/filechange"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/migration"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/s3key"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/sshkey"
 	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/useridentity"
 	"github.com/albakov/go-cloud-file-storage/internal/storage/usersession"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usertoken"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/webhook"
+	"github.com/albakov/go-cloud-file-storage/internal/tracing"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/pflag"
+	"log"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -27,38 +75,409 @@ import (
 func main() {
 	// init config and db connection
 	conf := config.MustNew("")
-	dbClient := storage.MustNewClient(conf.MysqlDSN)
+
+	err := logger.Setup(os.Stdout, logger.Config{Format: conf.LogFormat, Level: conf.LogLevel})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		ServiceName: "cloud-file-storage",
+		Endpoint:    conf.TracingOTLPEndpoint,
+		Insecure:    conf.TracingOTLPInsecure,
+		SampleRatio: conf.TracingSampleRatio,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrations, err := db.MigrationsFor(conf.DBDriver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if pflag.Arg(0) == "migrate" {
+		err := runMigrate(context.Background(), conf, migrations, pflag.Args()[1:])
+		if errors.Is(err, errMigrateUsage) {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			os.Exit(2)
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
+	dbClient := storage.MustNewClient(conf.DBDriver, conf.DBDSN)
+
+	if conf.MigrateOnStart {
+		m, err := migrator.New(
+			&migrator.Config{
+				Migrations:  migrations,
+				LockTimeout: time.Second * time.Duration(conf.MigrateLockTimeoutSeconds),
+			},
+			dbClient.DB(),
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := m.UpOnStart(context.Background()); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// create user service
-	userRepo := user.NewRepository(dbClient.DB())
+	userRepo := user.NewTraced(user.NewRepository(dbClient.DB()))
 	userService := userservice.NewService(userRepo)
 
+	if conf.AdminEmails != "" {
+		// the database may be not available yet, the admins are promoted on the next start then
+		if err := userService.PromoteAdmins(context.Background(), strings.Split(conf.AdminEmails, ",")); err != nil {
+			logger.Add("main", "main", err)
+		}
+	}
+
 	// create user session service
-	userSessionRepo := usersession.NewRepository(dbClient.DB())
+	userSessionRepo := usersession.NewTraced(usersession.NewRepository(dbClient.DB()))
 	userSessionService := usersessionservice.NewService(userSessionRepo)
 
 	// create jwt service
 	jwtService := jwt.NewService(&jwt.Config{Secret: conf.JWTSecret, ExpiresMinutes: conf.JWTExpiresMinutes})
 
+	// create personal access token service
+	accessTokenRepo := accesstoken.NewRepository(dbClient.DB())
+	accessTokenService := accesstokenservice.NewService(accessTokenRepo)
+
+	// create email verification and password reset service
+	userTokenRepo := usertoken.NewRepository(dbClient.DB())
+	userTokenService := usertokenservice.NewService(
+		&usertokenservice.Config{Secret: config.DeriveSecret(conf.JWTSecret, config.PurposeUserTokens)},
+		userTokenRepo,
+	)
+	mailerService := mailer.New(&mailer.Config{
+		Driver:   conf.MailDriver,
+		From:     conf.MailFrom,
+		LogPath:  conf.MailLogPath,
+		Host:     conf.SMTPHost,
+		Port:     conf.SMTPPort,
+		Username: conf.SMTPUsername,
+		Password: conf.SMTPPassword,
+	})
+	verificationService := verification.NewService(
+		&verification.Config{
+			AppURL:               conf.AppURL,
+			EmailVerificationTTL: time.Minute * time.Duration(conf.TokenEmailVerificationExpiresMinutes),
+			PasswordResetTTL:     time.Minute * time.Duration(conf.TokenPasswordResetExpiresMinutes),
+			AccountUnlockTTL:     time.Minute * time.Duration(conf.LoginLockoutMinutes),
+		},
+		mailerService,
+		userTokenService,
+		userService,
+		userSessionService,
+	)
+
+	// create metrics
+	appMetrics := metrics.New(dbClient.DB(), userSessionRepo)
+
+	// redis is shared by the rate limiter and the event bus of all instances
+	var redisClient *redis.Client
+	if conf.RateLimitStore == ratelimit.StoreRedis || conf.EventsBroker == events.BrokerRedis {
+		redisClient = ratelimit.NewRedisClient(conf)
+		defer func() {
+			if err := redisClient.Close(); err != nil {
+				logger.Add("main", "main", err)
+			}
+		}()
+	}
+
+	// create event bus, subsystems publish events of users to their connections
+	var eventBroker events.Broker = events.NewMemoryBroker()
+	if conf.EventsBroker == events.BrokerRedis {
+		eventBroker = events.NewRedisBroker(redisClient, "events")
+	}
+
+	eventBus, err := events.NewBus(&events.Config{BufferSize: conf.EventsBufferSize}, eventBroker)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// create webhooks, file events published on this instance are queued for delivery in the database
+	webhookRepo := webhook.NewRepository(dbClient.DB())
+	webhookService := webhookservice.NewService(
+		&webhookservice.Config{
+			Secret:       conf.WebhooksSecret,
+			Timeout:      time.Second * time.Duration(conf.WebhooksTimeoutSeconds),
+			MaxAttempts:  conf.WebhooksMaxAttempts,
+			Backoff:      time.Second * time.Duration(conf.WebhooksBackoffSeconds),
+			MaxBackoff:   time.Second * time.Duration(conf.WebhooksMaxBackoffSeconds),
+			DisableAfter: conf.WebhooksDisableAfter,
+			Retention:    time.Hour * 24 * time.Duration(conf.WebhooksRetentionDays),
+			Workers:      conf.WebhooksWorkers,
+			PollInterval: time.Second * time.Duration(conf.WebhooksPollIntervalSeconds),
+			AllowPrivate: conf.WebhooksAllowPrivate,
+		},
+		webhookRepo,
+		eventBus,
+	)
+	eventBus.AddSink(webhookService)
+
+	// create journal of file changes, every change made through the s3 service is written to it
+	fileChangeRepo := filechange.NewRepository(dbClient.DB())
+	journalService := journal.NewService(
+		&journal.Config{
+			Retention:    time.Hour * 24 * time.Duration(conf.ChangesRetentionDays),
+			PollInterval: time.Second * time.Duration(conf.ChangesPollIntervalSeconds),
+		},
+		fileChangeRepo,
+		eventBus,
+	)
+
+	// create s3 service
+	s3Service := s3.NewInstrumented(s3.NewService(s3.NewClient(conf), conf.S3Bucket, journalService), appMetrics)
+
+	// create background jobs, they are queued in the database and run by workers of all instances
+	jobRepo := job.NewRepository(dbClient.DB())
+	jobService := jobservice.NewService(
+		&jobservice.Config{
+			Workers:      conf.JobsWorkers,
+			PollInterval: time.Second * time.Duration(conf.JobsPollIntervalSeconds),
+			Lease:        time.Second * time.Duration(conf.JobsLeaseSeconds),
+			MaxAttempts:  conf.JobsMaxAttempts,
+			Backoff:      time.Second * time.Duration(conf.JobsBackoffSeconds),
+			Retention:    time.Hour * 24 * time.Duration(conf.JobsRetentionDays),
+		},
+		jobRepo,
+		eventBus,
+	)
+	resourcejob.Register(jobService, s3Service)
+
+	// create archive exports, they are written by jobs and removed by jobs when they expire
+	exportService := exportservice.NewService(
+		&exportservice.Config{
+			Secret:   conf.ExportsSecret,
+			TTL:      time.Hour * time.Duration(conf.ExportsTTLHours),
+			MaxPaths: conf.ExportsMaxPaths,
+		},
+		export.NewRepository(dbClient.DB()),
+		s3Service,
+		jobService,
+		eventBus,
+	)
+	exportService.Register(jobService)
+
+	// create account service
+	accountService := account.NewService(userService, userSessionService, verificationService, s3Service, jobService)
+	accountService.Register(jobService)
+
+	// create single sign-on service
+	oidcProviders, err := sso.LoadProviders(conf.OIDCProvidersFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ssoService := sso.NewService(
+		&sso.Config{
+			Providers:     oidcProviders,
+			CallbackURL:   conf.OIDCCallbackURL,
+			AutoProvision: conf.OIDCAutoProvision,
+			Secret:        config.DeriveSecret(conf.JWTSecret, config.PurposeOIDCState),
+			StateTTL:      time.Minute * time.Duration(conf.OIDCStateExpiresMinutes),
+		},
+		useridentity.NewRepository(dbClient.DB()),
+		userService,
+		userSessionService,
+	)
+
+	// create storage quota, administration and maintenance services
+	quotaService := quota.NewService(
+		&quota.Config{
+			DefaultBytes:   conf.StorageDefaultQuotaMB * 1024 * 1024,
+			WarningPercent: conf.StorageQuotaWarningPercent,
+		},
+		userService,
+		s3Service,
+		eventBus,
+	)
+	adminService := admin.NewService(userService, userSessionService)
+	maintenanceService := maintenance.NewService(
+		userSessionRepo,
+		userTokenRepo,
+		accessTokenRepo,
+		fileChangeRepo,
+		webhookRepo,
+		jobRepo,
+		exportService,
+		userService,
+		s3Service,
+		eventBus,
+	)
+
+	// create health service
+	healthService := health.NewService(
+		&health.Config{
+			Timeout:    time.Second * time.Duration(conf.ReadinessCheckTimeoutSeconds),
+			Migrations: migrations,
+		},
+		dbClient.DB(),
+		s3Service,
+		migration.NewRepository(dbClient.DB()),
+	)
+
+	// create audit log service
+	auditService := audit.NewService(auditlog.NewRepository(dbClient.DB()))
+
+	// create rate limiter and sign in brute-force protection
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if conf.RateLimitStore == ratelimit.StoreRedis {
+		rateLimitStore = ratelimit.NewRedisStore(redisClient, "ratelimit:")
+	}
+
+	limiter := ratelimit.NewLimiter(rateLimitStore)
+	loginGuard := loginguard.NewService(
+		&loginguard.Config{
+			AccountLimit: ratelimit.Limit{
+				Requests: conf.RateLimitAccountRequests,
+				Window:   time.Second * time.Duration(conf.RateLimitAccountWindow),
+			},
+			LockoutThreshold: conf.LoginLockoutThreshold,
+			LockoutWindow:    time.Minute * time.Duration(conf.LoginLockoutWindowMinutes),
+			LockoutDuration:  time.Minute * time.Duration(conf.LoginLockoutMinutes),
+			DelayBase:        time.Second * time.Duration(conf.LoginDelayBaseSeconds),
+			DelayMax:         time.Second * time.Duration(conf.LoginDelayMaxSeconds),
+		},
+		rateLimitStore,
+		limiter,
+		verificationService,
+	)
+
+	// create WebDAV service, clients send the credentials with every request
+	credentialsService := credentials.NewService(
+		&credentials.Config{CacheTTL: time.Second * time.Duration(conf.DAVCredentialsCacheSeconds)},
+		userService,
+		accessTokenService,
+		loginGuard,
+	)
+	davService := dav.NewService(s3Service)
+
+	// create S3 gateway services, each user has the bucket with their files
+	s3KeyService := s3keyservice.NewService(
+		&s3keyservice.Config{Secret: conf.S3GatewayKeySecret},
+		s3key.NewRepository(dbClient.DB()),
+	)
+	gatewayService := gateway.NewService(&gateway.Config{Bucket: conf.S3GatewayBucket}, s3Service)
+
+	// create SFTP services, users sign in with the credentials of WebDAV or SSH keys
+	sshKeyService := sshkeyservice.NewService(sshkey.NewRepository(dbClient.DB()))
+	sftpService := sftpservice.NewService(
+		&sftpservice.Config{RequireVerifiedEmail: conf.UploadRequireVerifiedEmail},
+		s3Service,
+		quotaService,
+		auditService,
+		userService,
+	)
+
 	// create api client
-	apiClient := api.MustNewClient(conf, jwtService, userService, userSessionService)
+	services := &api.Services{
+		JWT:          jwtService,
+		User:         userService,
+		UserSession:  userSessionService,
+		AccessToken:  accessTokenService,
+		SSO:          ssoService,
+		Verification: verificationService,
+		Account:      accountService,
+		S3:           s3Service,
+		Limiter:      limiter,
+		LoginGuard:   loginGuard,
+		Quota:        quotaService,
+		Admin:        adminService,
+		Maintenance:  maintenanceService,
+		Audit:        auditService,
+		Metrics:      appMetrics,
+		Health:       healthService,
+		Credentials:  credentialsService,
+		Dav:          davService,
+		S3Key:        s3KeyService,
+		Gateway:      gatewayService,
+		SSHKey:       sshKeyService,
+		SFTP:         sftpService,
+		Journal:      journalService,
+		Events:       eventBus,
+		Webhook:      webhookService,
+		Job:          jobService,
+		Export:       exportService,
+	}
+
+	apiClient := api.MustNewClient(conf, services)
 	apiClient.Start()
 
+	webhookService.Start()
+	jobService.Start()
+
+	var s3Gateway *api.Gateway
+	if conf.S3GatewayAddr != "" {
+		s3Gateway = api.MustNewGateway(conf, services)
+		s3Gateway.Start()
+	}
+
+	var sftpServer *api.SFTP
+	if conf.SFTPAddr != "" {
+		sftpServer = api.MustNewSFTP(conf, services)
+		sftpServer.Start()
+	}
+
 	// listen for app shutdown
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
 	<-sigCh
 
-	_, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	coordinator := shutdown.New(&shutdown.Config{
+		Timeout: time.Second * time.Duration(conf.ShutdownTimeoutSeconds),
+	})
+
+	// fail readiness first, so no new requests are routed to the app
+	coordinator.Wait("readiness", func(context.Context) error {
+		healthService.Shutdown()
 
-	// shutdown api client
-	if err := apiClient.Shutdown(); err != nil {
-		logger.Add("main", "main", err)
+		return nil
+	})
+	coordinator.Wait("drain", shutdown.Delay(time.Second*time.Duration(conf.ShutdownDrainSeconds)))
+
+	// clients waiting for file changes get their responses and event streams end, so they don't hold the api
+	coordinator.Wait("changes", func(context.Context) error {
+		journalService.Shutdown()
+
+		return nil
+	})
+	coordinator.Wait("events", func(context.Context) error {
+		eventBus.Shutdown()
+
+		return nil
+	})
+	coordinator.Wait("api", apiClient.Shutdown)
+	if s3Gateway != nil {
+		coordinator.Wait("s3 gateway", s3Gateway.Shutdown)
+	}
+	if sftpServer != nil {
+		coordinator.Wait("sftp", sftpServer.Shutdown)
 	}
 
-	// shutdown db connection
-	if err := dbClient.Shutdown(); err != nil {
-		logger.Add("main", "main", err)
+	// started maintenance jobs
+	coordinator.Wait("maintenance jobs", shutdown.Blocking(maintenanceService.Wait))
+	coordinator.Wait("webhook deliveries", webhookService.Shutdown)
+	coordinator.Wait("jobs", jobService.Shutdown)
+
+	coordinator.Cleanup("s3 uploads", s3Service.AbortUploads)
+	coordinator.Cleanup("db", func(context.Context) error {
+		return dbClient.Shutdown()
+	})
+
+	// export the remaining spans
+	coordinator.Cleanup("tracing", shutdownTracing)
+
+	if err := coordinator.Run(context.Background()); err != nil {
+		os.Exit(1)
 	}
 }
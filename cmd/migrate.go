@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/migrator"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/go-sql-driver/mysql"
+	"io/fs"
+	"time"
+)
+
+// migrationsDir is where `migrate create` writes new migrations of every database, they are embedded on the next build
+const migrationsDir = "db/migrations"
+
+const migrateUsage = `usage: cloud_file_storage migrate <command> [--env-file=ENV_PATH]
+
+commands:
+  up             apply all pending migrations
+  down           roll back the last applied migration
+  status         print migrations and their state
+  create NAME    create a new SQL migration in ` + migrationsDir
+
+var errMigrateUsage = errors.New("invalid migrate command")
+
+// runMigrate runs the migrate subcommand against the database from the config
+func runMigrate(ctx context.Context, conf *config.Config, migrations fs.FS, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	switch args[0] {
+	case "create":
+		if len(args) != 2 {
+			return errMigrateUsage
+		}
+
+		paths, err := migrator.Create(migrationsDir, args[1])
+		for _, path := range paths {
+			fmt.Println("created", path)
+		}
+
+		return err
+	case "up", "down", "status":
+	default:
+		return errMigrateUsage
+	}
+
+	dsn := conf.DBDSN
+	if conf.DBDriver == storage.DriverMySQL {
+		// goose scans time of the applied migrations into time.Time
+		mysqlConf, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return err
+		}
+		mysqlConf.ParseTime = true
+
+		dsn = mysqlConf.FormatDSN()
+	}
+
+	dbClient := storage.MustNewClient(conf.DBDriver, dsn)
+	defer func() {
+		_ = dbClient.Shutdown()
+	}()
+
+	m, err := migrator.New(
+		&migrator.Config{
+			Migrations:  migrations,
+			LockTimeout: time.Second * time.Duration(conf.MigrateLockTimeoutSeconds),
+		},
+		dbClient.DB(),
+	)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := m.Up(ctx)
+		for _, r := range results {
+			fmt.Println(r)
+		}
+
+		if err == nil && len(results) == 0 {
+			fmt.Println("no pending migrations")
+		}
+
+		return err
+	case "down":
+		result, err := m.Down(ctx)
+		if result != nil {
+			fmt.Println(result)
+		}
+
+		return err
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format(time.DateTime)
+			}
+
+			fmt.Printf("%-19s  %s\n", appliedAt, s.Source.Path)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,18 @@
+package db
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+)
+
+// Migrations are goose migrations of the schema for every database in migrations/<driver>, embedded,
+// so the binary knows the schema version it needs
+//
+//go:embed migrations/*/*.sql
+var Migrations embed.FS
+
+// MigrationsFor returns migrations of the database driver
+func MigrationsFor(driver string) (fs.FS, error) {
+	return fs.Sub(Migrations, path.Join("migrations", driver))
+}
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS users_tokens
+(
+    id         BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id    BIGINT UNSIGNED NOT NULL,
+    purpose    VARCHAR(32)     NOT NULL,
+    token_hash CHAR(64)        NOT NULL UNIQUE,
+    expires_at DATETIME        NOT NULL,
+    used_at    DATETIME        NULL DEFAULT NULL,
+    CONSTRAINT `users_tokens_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS users_tokens;
+-- +goose StatementEnd
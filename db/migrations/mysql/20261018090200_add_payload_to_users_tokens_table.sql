@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users_tokens
+    ADD COLUMN payload VARCHAR(255) NULL DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users_tokens
+    DROP COLUMN payload;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS personal_access_tokens
+(
+    id           BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id      BIGINT UNSIGNED NOT NULL,
+    name         VARCHAR(255)    NOT NULL,
+    token_hash   CHAR(64)        NOT NULL UNIQUE,
+    scopes       VARCHAR(255)    NOT NULL,
+    folder       VARCHAR(1024)   NULL     DEFAULT NULL,
+    expires_at   DATETIME        NULL     DEFAULT NULL,
+    last_used_at DATETIME        NULL     DEFAULT NULL,
+    created_at   DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT `personal_access_tokens_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS personal_access_tokens;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS users_identities
+(
+    id         BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id    BIGINT UNSIGNED NOT NULL,
+    provider   VARCHAR(64)     NOT NULL,
+    subject    VARCHAR(255)    NOT NULL,
+    email      VARCHAR(255)    NOT NULL,
+    created_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY `users_identities_provider_subject_unique` (provider, subject),
+    UNIQUE KEY `users_identities_user_id_provider_unique` (user_id, provider),
+    CONSTRAINT `users_identities_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS users_identities;
+-- +goose StatementEnd
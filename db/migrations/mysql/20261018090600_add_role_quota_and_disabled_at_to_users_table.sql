@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN role        VARCHAR(16)     NOT NULL DEFAULT 'user',
+    ADD COLUMN quota_bytes BIGINT UNSIGNED NULL     DEFAULT NULL,
+    ADD COLUMN disabled_at DATETIME        NULL     DEFAULT NULL,
+    ADD COLUMN created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN role,
+    DROP COLUMN quota_bytes,
+    DROP COLUMN disabled_at,
+    DROP COLUMN created_at;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS audit_logs
+(
+    id          BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id     BIGINT UNSIGNED NULL     DEFAULT NULL,
+    email       VARCHAR(254)    NULL     DEFAULT NULL,
+    action      VARCHAR(32)     NOT NULL,
+    result      VARCHAR(16)     NOT NULL,
+    ip          VARCHAR(45)     NOT NULL,
+    user_agent  VARCHAR(255)    NOT NULL,
+    path        VARCHAR(1024)   NULL     DEFAULT NULL,
+    target_path VARCHAR(1024)   NULL     DEFAULT NULL,
+    created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX `audit_logs_user_id_created_at_index` (user_id, created_at),
+    INDEX `audit_logs_action_created_at_index` (action, created_at)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS audit_logs;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS s3_keys
+(
+    id               BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id          BIGINT UNSIGNED NOT NULL,
+    name             VARCHAR(255)    NOT NULL,
+    access_key_id    CHAR(20)        NOT NULL UNIQUE,
+    secret_encrypted VARCHAR(255)    NOT NULL,
+    last_used_at     DATETIME        NULL     DEFAULT NULL,
+    created_at       DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT `s3_keys_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS s3_keys;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS file_changes
+(
+    id         BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id    BIGINT UNSIGNED NOT NULL,
+    seq        BIGINT UNSIGNED NOT NULL,
+    action     VARCHAR(16)     NOT NULL,
+    path       VARCHAR(1024)   NOT NULL,
+    from_path  VARCHAR(1024)   NULL     DEFAULT NULL,
+    etag       VARCHAR(255)    NOT NULL DEFAULT '',
+    size       BIGINT          NOT NULL DEFAULT 0,
+    created_at DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME        NOT NULL,
+    UNIQUE KEY `file_changes_user_id_seq_unique` (user_id, seq),
+    INDEX `file_changes_expires_at_index` (expires_at),
+    CONSTRAINT `file_changes_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS file_changes;
+-- +goose StatementEnd
@@ -0,0 +1,61 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id               BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id          BIGINT UNSIGNED NOT NULL,
+    url              VARCHAR(2048)   NOT NULL,
+    secret_encrypted VARCHAR(255)    NOT NULL,
+    events           VARCHAR(255)    NOT NULL,
+    folder           VARCHAR(1024)   NOT NULL DEFAULT '',
+    failures         INT             NOT NULL DEFAULT 0,
+    disabled_at      DATETIME        NULL     DEFAULT NULL,
+    created_at       DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT `webhooks_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    webhook_id      BIGINT UNSIGNED NOT NULL,
+    event_id        VARCHAR(64)     NOT NULL,
+    event_type      VARCHAR(32)     NOT NULL,
+    payload         TEXT            NOT NULL,
+    status          VARCHAR(16)     NOT NULL,
+    attempts        INT             NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME        NOT NULL,
+    locked_until    DATETIME        NULL     DEFAULT NULL,
+    created_at      DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at      DATETIME        NOT NULL,
+    INDEX `webhook_deliveries_status_next_attempt_at_index` (status, next_attempt_at),
+    INDEX `webhook_deliveries_expires_at_index` (expires_at),
+    CONSTRAINT `webhook_deliveries_webhook_id_fn`
+        FOREIGN KEY (webhook_id) REFERENCES webhooks (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts
+(
+    id          BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    delivery_id BIGINT UNSIGNED NOT NULL,
+    status_code INT             NOT NULL DEFAULT 0,
+    error       VARCHAR(1024)   NOT NULL DEFAULT '',
+    duration_ms BIGINT          NOT NULL DEFAULT 0,
+    created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT `webhook_delivery_attempts_delivery_id_fn`
+        FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+-- +goose StatementEnd
@@ -0,0 +1,36 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id                  BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id             BIGINT UNSIGNED NULL, -- NULL for jobs of the system, e.g. the purge of the deleted account
+    type                VARCHAR(64)     NOT NULL,
+    payload             TEXT            NOT NULL,
+    status              VARCHAR(16)     NOT NULL,
+    progress_done       BIGINT          NOT NULL DEFAULT 0,
+    progress_total      BIGINT          NOT NULL DEFAULT 0,
+    result              TEXT            NULL,
+    error               VARCHAR(1024)   NOT NULL DEFAULT '',
+    attempts            INT             NOT NULL DEFAULT 0,
+    max_attempts        INT             NOT NULL,
+    run_at              DATETIME        NOT NULL,
+    lease_id            VARCHAR(64)     NOT NULL DEFAULT '',
+    locked_until        DATETIME        NULL     DEFAULT NULL,
+    cancel_requested_at DATETIME        NULL     DEFAULT NULL,
+    created_at          DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    started_at          DATETIME        NULL     DEFAULT NULL,
+    finished_at         DATETIME        NULL     DEFAULT NULL,
+    expires_at          DATETIME        NULL     DEFAULT NULL,
+    INDEX `jobs_status_run_at_index` (status, run_at),
+    INDEX `jobs_expires_at_index` (expires_at),
+    CONSTRAINT `jobs_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS jobs;
+-- +goose StatementEnd
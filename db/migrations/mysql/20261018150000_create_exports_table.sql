@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exports
+(
+    id          BIGINT UNSIGNED PRIMARY KEY AUTO_INCREMENT,
+    user_id     BIGINT UNSIGNED NOT NULL,
+    job_id      BIGINT UNSIGNED NOT NULL DEFAULT 0,
+    format      VARCHAR(16)     NOT NULL,
+    paths       TEXT            NOT NULL,
+    status      VARCHAR(16)     NOT NULL,
+    object_key  VARCHAR(255)    NOT NULL DEFAULT '',
+    size        BIGINT          NOT NULL DEFAULT 0,
+    created_at  DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at DATETIME        NULL     DEFAULT NULL,
+    expires_at  DATETIME        NOT NULL,
+    INDEX `exports_user_id_index` (user_id),
+    INDEX `exports_expires_at_index` (expires_at),
+    CONSTRAINT `exports_user_id_fn`
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS exports;
+-- +goose StatementEnd
@@ -0,0 +1,100 @@
+-- PostgreSQL starts from the schema of all MySQL migrations up to this version
+
+-- +goose Up
+CREATE TABLE IF NOT EXISTS users
+(
+    id                      BIGSERIAL PRIMARY KEY,
+    email                   VARCHAR(255) NOT NULL UNIQUE,
+    password                VARCHAR(255) NOT NULL,
+    email_verified_at       TIMESTAMP(0) NULL     DEFAULT NULL,
+    password_login_disabled BOOLEAN      NOT NULL DEFAULT FALSE,
+    role                    VARCHAR(16)  NOT NULL DEFAULT 'user',
+    quota_bytes             BIGINT       NULL     DEFAULT NULL,
+    disabled_at             TIMESTAMP(0) NULL     DEFAULT NULL,
+    created_at              TIMESTAMP(0) NOT NULL DEFAULT LOCALTIMESTAMP(0)
+);
+
+CREATE TABLE IF NOT EXISTS users_sessions
+(
+    id            BIGSERIAL PRIMARY KEY,
+    user_id       BIGINT       NOT NULL,
+    refresh_token VARCHAR(255) NOT NULL UNIQUE,
+    expires_at    TIMESTAMP(0) NOT NULL,
+    CONSTRAINT users_sessions_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS users_tokens
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT       NOT NULL,
+    purpose    VARCHAR(32)  NOT NULL,
+    token_hash CHAR(64)     NOT NULL UNIQUE,
+    expires_at TIMESTAMP(0) NOT NULL,
+    used_at    TIMESTAMP(0) NULL DEFAULT NULL,
+    payload    VARCHAR(255) NULL DEFAULT NULL,
+    CONSTRAINT users_tokens_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS personal_access_tokens
+(
+    id           BIGSERIAL PRIMARY KEY,
+    user_id      BIGINT        NOT NULL,
+    name         VARCHAR(255)  NOT NULL,
+    token_hash   CHAR(64)      NOT NULL UNIQUE,
+    scopes       VARCHAR(255)  NOT NULL,
+    folder       VARCHAR(1024) NULL     DEFAULT NULL,
+    expires_at   TIMESTAMP(0)  NULL     DEFAULT NULL,
+    last_used_at TIMESTAMP(0)  NULL     DEFAULT NULL,
+    created_at   TIMESTAMP(0)  NOT NULL DEFAULT LOCALTIMESTAMP(0),
+    CONSTRAINT personal_access_tokens_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS users_identities
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT       NOT NULL,
+    provider   VARCHAR(64)  NOT NULL,
+    subject    VARCHAR(255) NOT NULL,
+    email      VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP(0) NOT NULL DEFAULT LOCALTIMESTAMP(0),
+    CONSTRAINT users_identities_provider_subject_unique UNIQUE (provider, subject),
+    CONSTRAINT users_identities_user_id_provider_unique UNIQUE (user_id, provider),
+    CONSTRAINT users_identities_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS audit_logs
+(
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT        NULL     DEFAULT NULL,
+    email       VARCHAR(254)  NULL     DEFAULT NULL,
+    action      VARCHAR(32)   NOT NULL,
+    result      VARCHAR(16)   NOT NULL,
+    ip          VARCHAR(45)   NOT NULL,
+    user_agent  VARCHAR(255)  NOT NULL,
+    path        VARCHAR(1024) NULL     DEFAULT NULL,
+    target_path VARCHAR(1024) NULL     DEFAULT NULL,
+    created_at  TIMESTAMP(0)  NOT NULL DEFAULT LOCALTIMESTAMP(0)
+);
+
+CREATE INDEX IF NOT EXISTS audit_logs_user_id_created_at_index ON audit_logs (user_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_logs_action_created_at_index ON audit_logs (action, created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS audit_logs;
+DROP TABLE IF EXISTS users_identities;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS users_tokens;
+DROP TABLE IF EXISTS users_sessions;
+DROP TABLE IF EXISTS users;
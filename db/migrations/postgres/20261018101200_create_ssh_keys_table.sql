@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS ssh_keys
+(
+    id               BIGSERIAL PRIMARY KEY,
+    user_id          BIGINT       NOT NULL,
+    name             VARCHAR(255) NOT NULL,
+    public_key       TEXT         NOT NULL,
+    fingerprint      VARCHAR(64)  NOT NULL UNIQUE,
+    last_used_at     TIMESTAMP(0) NULL     DEFAULT NULL,
+    created_at       TIMESTAMP(0) NOT NULL DEFAULT LOCALTIMESTAMP(0),
+    CONSTRAINT ssh_keys_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ssh_keys;
+-- +goose StatementEnd
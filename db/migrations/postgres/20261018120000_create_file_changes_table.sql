@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS file_changes
+(
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT        NOT NULL,
+    seq        BIGINT        NOT NULL,
+    action     VARCHAR(16)   NOT NULL,
+    path       VARCHAR(1024) NOT NULL,
+    from_path  VARCHAR(1024) NULL     DEFAULT NULL,
+    etag       VARCHAR(255)  NOT NULL DEFAULT '',
+    size       BIGINT        NOT NULL DEFAULT 0,
+    created_at TIMESTAMP(0)  NOT NULL DEFAULT LOCALTIMESTAMP(0),
+    expires_at TIMESTAMP(0)  NOT NULL,
+    CONSTRAINT file_changes_user_id_seq_unique UNIQUE (user_id, seq),
+    CONSTRAINT file_changes_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS file_changes_expires_at_index ON file_changes (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS file_changes;
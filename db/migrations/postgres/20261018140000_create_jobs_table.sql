@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id                  BIGSERIAL PRIMARY KEY,
+    user_id             BIGINT        NULL, -- NULL for jobs of the system, e.g. the purge of the deleted account
+    type                VARCHAR(64)   NOT NULL,
+    payload             TEXT          NOT NULL,
+    status              VARCHAR(16)   NOT NULL,
+    progress_done       BIGINT        NOT NULL DEFAULT 0,
+    progress_total      BIGINT        NOT NULL DEFAULT 0,
+    result              TEXT          NULL,
+    error               VARCHAR(1024) NOT NULL DEFAULT '',
+    attempts            INT           NOT NULL DEFAULT 0,
+    max_attempts        INT           NOT NULL,
+    run_at              TIMESTAMP(0)  NOT NULL,
+    lease_id            VARCHAR(64)   NOT NULL DEFAULT '',
+    locked_until        TIMESTAMP(0)  NULL     DEFAULT NULL,
+    cancel_requested_at TIMESTAMP(0)  NULL     DEFAULT NULL,
+    created_at          TIMESTAMP(0)  NOT NULL DEFAULT LOCALTIMESTAMP(0),
+    started_at          TIMESTAMP(0)  NULL     DEFAULT NULL,
+    finished_at         TIMESTAMP(0)  NULL     DEFAULT NULL,
+    expires_at          TIMESTAMP(0)  NULL     DEFAULT NULL,
+    CONSTRAINT jobs_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS jobs_status_run_at_index ON jobs (status, run_at);
+CREATE INDEX IF NOT EXISTS jobs_expires_at_index ON jobs (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS jobs;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS exports
+(
+    id          BIGSERIAL PRIMARY KEY,
+    user_id     BIGINT       NOT NULL,
+    job_id      BIGINT       NOT NULL DEFAULT 0,
+    format      VARCHAR(16)  NOT NULL,
+    paths       TEXT         NOT NULL,
+    status      VARCHAR(16)  NOT NULL,
+    object_key  VARCHAR(255) NOT NULL DEFAULT '',
+    size        BIGINT       NOT NULL DEFAULT 0,
+    created_at  TIMESTAMP(0) NOT NULL DEFAULT LOCALTIMESTAMP(0),
+    finished_at TIMESTAMP(0) NULL     DEFAULT NULL,
+    expires_at  TIMESTAMP(0) NOT NULL,
+    CONSTRAINT exports_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS exports_user_id_index ON exports (user_id);
+CREATE INDEX IF NOT EXISTS exports_expires_at_index ON exports (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS exports;
@@ -0,0 +1,59 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS webhooks
+(
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id          INTEGER       NOT NULL,
+    url              VARCHAR(2048) NOT NULL,
+    secret_encrypted VARCHAR(255)  NOT NULL,
+    events           VARCHAR(255)  NOT NULL,
+    folder           VARCHAR(1024) NOT NULL DEFAULT '',
+    failures         INTEGER       NOT NULL DEFAULT 0,
+    disabled_at      DATETIME      NULL     DEFAULT NULL,
+    created_at       DATETIME      NOT NULL DEFAULT (datetime('now', 'localtime')),
+    CONSTRAINT webhooks_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries
+(
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id      INTEGER     NOT NULL,
+    event_id        VARCHAR(64) NOT NULL,
+    event_type      VARCHAR(32) NOT NULL,
+    payload         TEXT        NOT NULL,
+    status          VARCHAR(16) NOT NULL,
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME    NOT NULL,
+    locked_until    DATETIME    NULL     DEFAULT NULL,
+    created_at      DATETIME    NOT NULL DEFAULT (datetime('now', 'localtime')),
+    expires_at      DATETIME    NOT NULL,
+    CONSTRAINT webhook_deliveries_webhook_id_fn
+        FOREIGN KEY (webhook_id) REFERENCES webhooks (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_status_next_attempt_at_index
+    ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS webhook_deliveries_expires_at_index ON webhook_deliveries (expires_at);
+
+CREATE TABLE IF NOT EXISTS webhook_delivery_attempts
+(
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    delivery_id INTEGER       NOT NULL,
+    status_code INTEGER       NOT NULL DEFAULT 0,
+    error       VARCHAR(1024) NOT NULL DEFAULT '',
+    duration_ms INTEGER       NOT NULL DEFAULT 0,
+    created_at  DATETIME      NOT NULL DEFAULT (datetime('now', 'localtime')),
+    CONSTRAINT webhook_delivery_attempts_delivery_id_fn
+        FOREIGN KEY (delivery_id) REFERENCES webhook_deliveries (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS jobs
+(
+    id                  INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id             INTEGER       NULL, -- NULL for jobs of the system, e.g. the purge of the deleted account
+    type                VARCHAR(64)   NOT NULL,
+    payload             TEXT          NOT NULL,
+    status              VARCHAR(16)   NOT NULL,
+    progress_done       INTEGER       NOT NULL DEFAULT 0,
+    progress_total      INTEGER       NOT NULL DEFAULT 0,
+    result              TEXT          NULL,
+    error               VARCHAR(1024) NOT NULL DEFAULT '',
+    attempts            INTEGER       NOT NULL DEFAULT 0,
+    max_attempts        INTEGER       NOT NULL,
+    run_at              DATETIME      NOT NULL,
+    lease_id            VARCHAR(64)   NOT NULL DEFAULT '',
+    locked_until        DATETIME      NULL     DEFAULT NULL,
+    cancel_requested_at DATETIME      NULL     DEFAULT NULL,
+    created_at          DATETIME      NOT NULL DEFAULT (datetime('now', 'localtime')),
+    started_at          DATETIME      NULL     DEFAULT NULL,
+    finished_at         DATETIME      NULL     DEFAULT NULL,
+    expires_at          DATETIME      NULL     DEFAULT NULL,
+    CONSTRAINT jobs_user_id_fn
+        FOREIGN KEY (user_id) REFERENCES users (id)
+            ON DELETE CASCADE
+            ON UPDATE NO ACTION
+);
+
+CREATE INDEX IF NOT EXISTS jobs_status_run_at_index ON jobs (status, run_at);
+CREATE INDEX IF NOT EXISTS jobs_expires_at_index ON jobs (expires_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS jobs;
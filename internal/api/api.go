@@ -1,42 +1,98 @@
 package api
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	_ "github.com/albakov/go-cloud-file-storage/docs"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/account"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/activity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/admin"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller/auth"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/changes"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/dav"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/events"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/export"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/health"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/job"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller/profile"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/s3key"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/sshkey"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/sso"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/verification"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/webhook"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/accesslog"
 	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/authenticated"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/metrics"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/requestid"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/role"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/throttle"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/tracing"
 	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/validation"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/verified"
 	"github.com/albakov/go-cloud-file-storage/internal/config"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
-	"github.com/albakov/go-cloud-file-storage/internal/service/jwt"
-	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	accesstokenservice "github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
 	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
-	usersessionservice "github.com/albakov/go-cloud-file-storage/internal/service/usersession"
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/swagger"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"slices"
+	"strings"
+	"time"
 )
 
 type Client struct {
 	app  *fiber.App
 	conf *config.Config
+	// cancels the parent context of all requests, if they don't finish in time on shutdown
+	cancelRequests context.CancelFunc
 }
 
-func MustNewClient(
-	conf *config.Config,
-	jwtService *jwt.Service,
-	userService *userservice.Service,
-	userSessionService *usersessionservice.Service,
-) *Client {
-	app := fiber.New(fiber.Config{
+func MustNewClient(conf *config.Config, services *Services) *Client {
+	app := fiber.New(withProxy(conf, fiber.Config{
 		BodyLimit: conf.ApiFileUploadMaxSize * 1024 * 1024,
+		// WebDAV methods are rejected by Fiber unless they are listed
+		RequestMethods: append(slices.Clone(fiber.DefaultMethods), dav.Methods...),
+	}))
+
+	// probes and scrapes are registered before the middlewares, so they aren't logged and measured
+	healthCnt := health.New(services.Health)
+	app.Get("/healthz", healthCnt.LiveHandler)
+	app.Get("/readyz", healthCnt.ReadyHandler)
+	app.Get("/version", healthCnt.VersionHandler)
+
+	if conf.MetricsEnabled {
+		app.Get(
+			"/metrics",
+			metrics.Token(conf.MetricsToken),
+			adaptor.HTTPHandler(services.Metrics.Handler()),
+		)
+	}
+
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.SetUserContext(requestsCtx)
+
+		return ctx.Next()
 	})
 
+	// request id first, so every record of the request carries it
+	app.Use(requestid.New(), tracing.New(), accesslog.New())
+
+	if conf.MetricsEnabled {
+		app.Use(metrics.New(services.Metrics))
+	}
+
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     conf.CORSAllowOrigins,
 		AllowMethods:     conf.CORSAllowMethods,
@@ -44,45 +100,286 @@ func MustNewClient(
 		AllowCredentials: conf.CORSAllowCredentials,
 	}))
 
+	// credentials validation
+	validator := mustNewValidator(conf)
+
+	// rate limiting, each group of endpoints has its own budget
+	throttleMiddleware := throttle.New(services.Limiter)
+	authThrottle := throttleMiddleware.ByIP("auth", limit(conf.RateLimitAuthRequests, conf.RateLimitAuthWindow))
+	refreshThrottle := throttleMiddleware.ByIP(
+		"refresh",
+		limit(conf.RateLimitRefreshRequests, conf.RateLimitRefreshWindow),
+	)
+	uploadThrottle := throttleMiddleware.ByUser(
+		"upload",
+		limit(conf.RateLimitUploadRequests, conf.RateLimitUploadWindow),
+	)
+	searchThrottle := throttleMiddleware.ByUser(
+		"search",
+		limit(conf.RateLimitSearchRequests, conf.RateLimitSearchWindow),
+	)
+
 	// auth
-	authCnt := auth.New(conf, jwtService, userService, userSessionService)
+	authCnt := auth.New(
+		conf,
+		services.JWT,
+		services.User,
+		services.UserSession,
+		services.Verification,
+		services.LoginGuard,
+		services.Audit,
+		services.Events,
+	)
 
-	app.Post("/api/auth/sign-in", validation.EmailAndPasswordValidation, authCnt.LoginHandler)
-	app.Post("/api/auth/sign-up", validation.EmailAndPasswordValidation, authCnt.RegisterHandler)
+	app.Post("/api/auth/sign-in", authThrottle, validation.EmailAndPasswordValidation, authCnt.LoginHandler)
+	app.Post("/api/auth/sign-up", authThrottle, validator.RegistrationValidation, authCnt.RegisterHandler)
 
-	app.Post("/api/auth/refresh-token", authCnt.RefreshHandler)
+	app.Post("/api/auth/refresh-token", refreshThrottle, authCnt.RefreshHandler)
 	app.Post("/api/auth/sign-out", authCnt.LogoutHandler)
 
-	authMiddleware := authenticated.New(jwtService)
+	authMiddleware := authenticated.New(services.JWT, services.AccessToken)
+
+	// email verification, password reset and account unlock
+	verificationCnt := verification.New(services.Verification, validator, services.LoginGuard)
+
+	app.Post("/api/auth/verify-email", authThrottle, verificationCnt.VerifyEmailHandler)
+	app.Post("/api/auth/forgot-password", authThrottle, verificationCnt.ForgotPasswordHandler)
+	app.Post("/api/auth/reset-password", authThrottle, verificationCnt.ResetPasswordHandler)
+	app.Post("/api/auth/confirm-email-change", authThrottle, verificationCnt.ConfirmEmailChangeHandler)
+	app.Post("/api/auth/unlock-account", authThrottle, verificationCnt.UnlockAccountHandler)
+	app.Post(
+		"/api/user/email/verification",
+		authMiddleware.Authenticated,
+		authMiddleware.SessionOnly,
+		verificationCnt.SendEmailVerificationHandler,
+	)
 
 	// profile
-	profileCnt := profile.New(userService)
+	profileCnt := profile.New(services.User)
 	app.Get("/api/user/me", authMiddleware.Authenticated, profileCnt.ShowHandler)
 
-	s3Client := s3.NewClient(conf)
-	s3Service := s3.NewService(s3Client, conf.S3Bucket)
+	// account, not available with personal access tokens
+	accountCnt := account.New(conf, services.Account, validator, services.SSO)
+	sessionOnly := authMiddleware.SessionOnly
+	app.Patch("/api/user/password", authMiddleware.Authenticated, sessionOnly, accountCnt.ChangePasswordHandler)
+	app.Patch("/api/user/email", authMiddleware.Authenticated, sessionOnly, accountCnt.ChangeEmailHandler)
+	app.Delete("/api/user/me", authMiddleware.Authenticated, sessionOnly, accountCnt.DeleteHandler)
+
+	// single sign-on, linked identity providers
+	ssoCnt := sso.New(conf, services.SSO, authCnt, services.Audit)
+	app.Get("/api/auth/oidc", ssoCnt.ProvidersHandler)
+	app.Get("/api/auth/oidc/:provider", authThrottle, ssoCnt.LoginHandler)
+	app.Get("/api/auth/oidc/:provider/callback", authThrottle, ssoCnt.CallbackHandler)
+
+	identityGroup := app.Group("/api/user/identities")
+	identityGroup.Use(authMiddleware.Authenticated, sessionOnly)
+	identityGroup.Get("/", ssoCnt.IdentitiesHandler)
+	identityGroup.Post("/:provider", ssoCnt.LinkHandler)
+	identityGroup.Post("/:provider/reauthenticate", ssoCnt.ReauthenticateHandler)
+	identityGroup.Delete("/:id", ssoCnt.UnlinkHandler)
+	app.Patch("/api/user/password-login", authMiddleware.Authenticated, sessionOnly, ssoCnt.PasswordLoginHandler)
+
+	// own security and file events
+	activityCnt := activity.New(services.Audit)
+	app.Get("/api/user/activity", authMiddleware.Authenticated, sessionOnly, activityCnt.IndexHandler)
+	app.Get("/api/user/activity/export", authMiddleware.Authenticated, sessionOnly, activityCnt.ExportHandler)
+
+	// personal access tokens
+	accessTokenCnt := accesstoken.New(services.AccessToken)
+
+	tokenGroup := app.Group("/api/user/tokens")
+	tokenGroup.Use(authMiddleware.Authenticated, authMiddleware.SessionOnly)
+	tokenGroup.Get("/", accessTokenCnt.IndexHandler)
+	tokenGroup.Post("/", accessTokenCnt.StoreHandler)
+	tokenGroup.Delete("/:id", accessTokenCnt.DeleteHandler)
+
+	// access keys of the S3 gateway
+	s3KeyCnt := s3key.New(services.S3Key)
+
+	s3KeyGroup := app.Group("/api/user/s3-keys")
+	s3KeyGroup.Use(authMiddleware.Authenticated, authMiddleware.SessionOnly)
+	s3KeyGroup.Get("/", s3KeyCnt.IndexHandler)
+	s3KeyGroup.Post("/", s3KeyCnt.StoreHandler)
+	s3KeyGroup.Delete("/:id", s3KeyCnt.DeleteHandler)
+
+	// public keys of the SFTP server
+	sshKeyCnt := sshkey.New(services.SSHKey)
+
+	sshKeyGroup := app.Group("/api/user/ssh-keys")
+	sshKeyGroup.Use(authMiddleware.Authenticated, authMiddleware.SessionOnly)
+	sshKeyGroup.Get("/", sshKeyCnt.IndexHandler)
+	sshKeyGroup.Post("/", sshKeyCnt.StoreHandler)
+	sshKeyGroup.Delete("/:id", sshKeyCnt.DeleteHandler)
+
+	// outgoing webhooks of file events
+	webhookCnt := webhook.New(services.Webhook)
+
+	webhookGroup := app.Group("/api/user/webhooks")
+	webhookGroup.Use(authMiddleware.Authenticated, authMiddleware.SessionOnly)
+	webhookGroup.Get("/", webhookCnt.IndexHandler)
+	webhookGroup.Post("/", webhookCnt.StoreHandler)
+	webhookGroup.Delete("/:id", webhookCnt.DeleteHandler)
+	webhookGroup.Post("/:id/enable", webhookCnt.EnableHandler)
+	webhookGroup.Post("/:id/disable", webhookCnt.DisableHandler)
+	webhookGroup.Get("/:id/deliveries", webhookCnt.DeliveriesHandler)
+	webhookGroup.Post("/:id/deliveries/:deliveryId/redeliver", webhookCnt.RedeliverHandler)
 
 	// resource
-	resourceCnt := resource.New(conf, s3Service)
+	resourceCnt := resource.New(
+		conf,
+		services.S3,
+		services.Quota,
+		services.Audit,
+		services.Job,
+		services.Export,
+		services.Metrics,
+	)
+
+	// scopes are checked only for personal access tokens, sessions have full access
+	readScope := authMiddleware.RequireScope(accesstokenservice.ScopeRead)
+	writeScope := authMiddleware.RequireScope(accesstokenservice.ScopeWrite)
+	deleteScope := authMiddleware.RequireScope(accesstokenservice.ScopeDelete)
 
 	resourceGroup := app.Group("/api/resource")
 	resourceGroup.Use(authMiddleware.Authenticated)
-	resourceGroup.Get("/", resourceCnt.ShowHandler)
-	resourceGroup.Post("/", resourceCnt.StoreHandler)
-	resourceGroup.Delete("/", resourceCnt.DeleteHandler)
-	resourceGroup.Get("/move", resourceCnt.MoveHandler)
-	resourceGroup.Get("/download", resourceCnt.DownloadHandler)
-	resourceGroup.Get("/search", resourceCnt.SearchHandler)
+	resourceGroup.Get("/", readScope, resourceCnt.ShowHandler)
+	if conf.UploadRequireVerifiedEmail {
+		resourceGroup.Post(
+			"/",
+			writeScope,
+			uploadThrottle,
+			verified.New(services.User).Verified,
+			resourceCnt.StoreHandler,
+		)
+	} else {
+		resourceGroup.Post("/", writeScope, uploadThrottle, resourceCnt.StoreHandler)
+	}
+	resourceGroup.Delete("/", deleteScope, resourceCnt.DeleteHandler)
+	resourceGroup.Get("/move", writeScope, resourceCnt.MoveHandler)
+	resourceGroup.Get("/download", readScope, resourceCnt.DownloadHandler)
+	resourceGroup.Get("/search", readScope, searchThrottle, resourceCnt.SearchHandler)
+	resourceGroup.Post("/export", readScope, resourceCnt.ExportHandler)
 
 	directoryGroup := app.Group("/api/directory")
 	directoryGroup.Use(authMiddleware.Authenticated)
-	directoryGroup.Get("/", resourceCnt.DirectoryShowHandler)
-	directoryGroup.Post("/", resourceCnt.DirectoryStoreHandler)
+	directoryGroup.Get("/", readScope, resourceCnt.DirectoryShowHandler)
+	directoryGroup.Post("/", writeScope, resourceCnt.DirectoryStoreHandler)
+
+	// journal of file changes for sync clients, requests wait for changes
+	changesCnt := changes.New(services.Journal)
+	app.Get("/api/changes", authMiddleware.Authenticated, readScope, changesCnt.IndexHandler)
+
+	// background jobs of the user, e.g. deleting and moving folders
+	jobCnt := job.New(services.Job)
+
+	jobGroup := app.Group("/api/jobs")
+	jobGroup.Use(authMiddleware.Authenticated)
+	jobGroup.Get("/", readScope, jobCnt.IndexHandler)
+	jobGroup.Get("/:id", readScope, jobCnt.ShowHandler)
+	jobGroup.Delete("/:id", writeScope, jobCnt.CancelHandler)
+
+	// archives exported in background, download links are signed and work without authorization
+	exportCnt := export.New(services.Export, services.Metrics)
+	app.Get("/api/exports/:id/download", exportCnt.DownloadHandler)
+
+	exportGroup := app.Group("/api/exports")
+	exportGroup.Use(authMiddleware.Authenticated)
+	exportGroup.Get("/", readScope, exportCnt.IndexHandler)
+	exportGroup.Get("/:id", readScope, exportCnt.ShowHandler)
+	exportGroup.Delete("/:id", writeScope, exportCnt.DeleteHandler)
+
+	// events of the user over Server-Sent Events and WebSocket
+	eventsCnt := events.New(conf, services.Events)
+
+	eventsGroup := app.Group("/api/events")
+	eventsGroup.Use(authMiddleware.QueryToken, authMiddleware.Authenticated, readScope)
+	eventsGroup.Get("/", eventsCnt.StreamHandler)
+	eventsGroup.Get("/ws", eventsCnt.UpgradeHandler, websocket.New(eventsCnt.SocketHandler))
+
+	// WebDAV, authenticated with Basic credentials on every request
+	if conf.DAVEnabled {
+		davCnt := dav.New(services.Dav, services.Credentials, services.Quota, services.Audit)
+
+		davGroup := app.Group(dav.Prefix, davCnt.Authenticated)
+		davGroup.Put("/*", uploadThrottle)
+		if conf.UploadRequireVerifiedEmail {
+			davGroup.Put("/*", verified.New(services.User).Verified)
+		}
+		davGroup.Use(davCnt.Handler)
+	}
+
+	// administration, the role is checked on every request
+	adminCnt := admin.New(services.Admin, services.Quota, services.Maintenance)
+
+	adminGroup := app.Group("/api/admin")
+	adminGroup.Use(authMiddleware.Authenticated, sessionOnly, role.New(services.User).Require(userservice.RoleAdmin))
+	adminGroup.Get("/users", adminCnt.UsersHandler)
+	adminGroup.Get("/users/:id", adminCnt.UserHandler)
+	adminGroup.Post("/users/:id/disable", adminCnt.DisableHandler)
+	adminGroup.Post("/users/:id/enable", adminCnt.EnableHandler)
+	adminGroup.Patch("/users/:id/role", adminCnt.RoleHandler)
+	adminGroup.Patch("/users/:id/quota", adminCnt.QuotaHandler)
+	adminGroup.Get("/users/:id/usage", adminCnt.UsageHandler)
+	adminGroup.Delete("/users/:id/sessions", adminCnt.LogoutHandler)
+	adminGroup.Get("/maintenance", adminCnt.JobsHandler)
+	adminGroup.Post("/maintenance/:job", adminCnt.StartJobHandler)
+	adminGroup.Get("/activity", activityCnt.AdminIndexHandler)
+	adminGroup.Get("/activity/export", activityCnt.AdminExportHandler)
 
 	// swagger
 	app.Get("/swagger/*", swagger.HandlerDefault)
 
-	return &Client{app: app, conf: conf}
+	return &Client{
+		app:            app,
+		conf:           conf,
+		cancelRequests: cancelRequests,
+	}
+}
+
+// withProxy sets the proxy header of the client IP, it's read only from trusted proxies. Otherwise any client
+// could set its IP and get around rate limits and lockouts by IP
+func withProxy(conf *config.Config, fiberConfig fiber.Config) fiber.Config {
+	if conf.ApiProxyHeader == "" {
+		return fiberConfig
+	}
+
+	fiberConfig.ProxyHeader = conf.ApiProxyHeader
+	fiberConfig.EnableTrustedProxyCheck = true
+	fiberConfig.TrustedProxies = []string{}
+
+	for _, proxy := range strings.Split(conf.ApiTrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			fiberConfig.TrustedProxies = append(fiberConfig.TrustedProxies, proxy)
+		}
+	}
+
+	return fiberConfig
+}
+
+func mustNewValidator(conf *config.Config) *validation.Validator {
+	policy := password.Policy{
+		MinLength:     conf.PasswordMinLength,
+		MaxLength:     conf.PasswordMaxLength,
+		RequireUpper:  conf.PasswordRequireUpper,
+		RequireLower:  conf.PasswordRequireLower,
+		RequireDigit:  conf.PasswordRequireDigit,
+		RequireSymbol: conf.PasswordRequireSymbol,
+	}
+
+	if !conf.PasswordBreachedCheck {
+		return validation.New(policy, nil)
+	}
+
+	breachedChecker, err := password.NewBreachedChecker(conf.PasswordBreachedListPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return validation.New(policy, breachedChecker)
+}
+
+func limit(requests int, windowSeconds int64) ratelimit.Limit {
+	return ratelimit.Limit{Requests: requests, Window: time.Second * time.Duration(windowSeconds)}
 }
 
 func (cl *Client) Start() {
@@ -94,12 +391,23 @@ func (cl *Client) Start() {
 	}()
 }
 
-func (cl *Client) Shutdown() error {
-	if err := cl.app.Shutdown(); err != nil {
+// Serve serves requests accepted by the listener until Shutdown, e.g. of an in-process server in tests
+func (cl *Client) Serve(ln net.Listener) error {
+	return cl.app.Listener(ln)
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until the context is done.
+// Requests still running then are cancelled, so their uploads to S3 are interrupted
+func (cl *Client) Shutdown(ctx context.Context) error {
+	err := cl.app.ShutdownWithContext(ctx)
+
+	cl.cancelRequests()
+
+	if err != nil {
 		return logger.Error("api.Client", "Shutdown", err)
 	}
 
-	fmt.Println("API Server Shutdown")
+	slog.Info("API Server Shutdown")
 
 	return nil
 }
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWithProxy(t *testing.T) {
+	// test requests come from 0.0.0.0
+	for name, tt := range map[string]struct {
+		proxies  string
+		expected string
+	}{
+		"trusted peer":   {proxies: "10.0.0.0/8, 0.0.0.0", expected: "203.0.113.7"},
+		"untrusted peer": {proxies: "10.0.0.0/8", expected: "0.0.0.0"},
+		"no proxies":     {proxies: "", expected: "0.0.0.0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			conf := &config.Config{ApiProxyHeader: fiber.HeaderXForwardedFor, ApiTrustedProxies: tt.proxies}
+
+			app := fiber.New(withProxy(conf, fiber.Config{}))
+			app.Get("/", func(ctx *fiber.Ctx) error {
+				return ctx.SendString(ctx.IP())
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			req.Header.Set(fiber.HeaderXForwardedFor, "203.0.113.7")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("error while send request: %v", err)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("error while read response: %v", err)
+			}
+
+			if string(body) != tt.expected {
+				t.Errorf("client IP must be %s, got: %s", tt.expected, body)
+			}
+		})
+	}
+}
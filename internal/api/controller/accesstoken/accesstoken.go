@@ -0,0 +1,209 @@
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	accesstokenservice "github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	accesstokenstorage "github.com/albakov/go-cloud-file-storage/internal/storage/accesstoken"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"time"
+)
+
+type AccessToken struct {
+	pkg                string
+	accessTokenService AccessTokenService
+}
+
+type AccessTokenService interface {
+	CreateToken(
+		ctx context.Context,
+		userId int64,
+		name string,
+		scopes []string,
+		folder string,
+		expiresAt time.Time,
+	) (string, accesstokenstorage.AccessToken, error)
+	Tokens(ctx context.Context, userId int64) ([]accesstokenstorage.AccessToken, error)
+	RevokeToken(ctx context.Context, userId, tokenId int64) error
+}
+
+func New(accessTokenService AccessTokenService) *AccessToken {
+	return &AccessToken{
+		pkg:                "accesstoken",
+		accessTokenService: accessTokenService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		List personal access tokens
+//	@Description	List personal access tokens of the current user. Tokens themselves are never returned again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	[]accesstoken.Response	"List of tokens"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/tokens [get]
+func (at *AccessToken) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	tokens, err := at.accessTokenService.Tokens(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), at.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	data := make([]accesstoken.Response, 0, len(tokens))
+	for _, t := range tokens {
+		data = append(data, response(t))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// StoreHandler godoc
+//
+//	@Summary		Create personal access token
+//	@Description	Create a personal access token for scripts and CI. Scopes: read, write, delete, share.
+//	@Description	Optional folder restricts the token to the folder, optional expires_at is in RFC 3339 format.
+//	@Description	The token is shown only once
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			token			body		accesstoken.CreateRequest		true	"Token settings"
+//	@Success		201				{object}	accesstoken.CreateResponse		"Created token"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Not available with an access token"
+//	@Failure		500				{object}	entity.ErrorResponse			"Server error"
+//	@Router			/user/tokens [post]
+func (at *AccessToken) StoreHandler(ctx *fiber.Ctx) error {
+	const op = "StoreHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r accesstoken.CreateRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	var expiresAt time.Time
+	if r.ExpiresAt != "" {
+		var err error
+		expiresAt, err = time.Parse(time.RFC3339, r.ExpiresAt)
+		if err != nil {
+			return validationFailed(ctx, "expires_at", "must be a date in RFC 3339 format")
+		}
+	}
+
+	token, t, err := at.accessTokenService.CreateToken(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		r.Name,
+		r.Scopes,
+		r.Folder,
+		expiresAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, accesstokenservice.ErrNameRequired):
+			return validationFailed(ctx, "name", "is required")
+		case errors.Is(err, accesstokenservice.ErrInvalidScope):
+			return validationFailed(
+				ctx,
+				"scopes",
+				"must be a non-empty list of: "+strings.Join(accesstokenservice.Scopes, ", "),
+			)
+		case errors.Is(err, accesstokenservice.ErrExpiresAt):
+			return validationFailed(ctx, "expires_at", "must be in the future")
+		}
+
+		logger.AddContext(ctx.UserContext(), at.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusCreated)
+
+	return ctx.JSON(&accesstoken.CreateResponse{Response: response(t), Token: token})
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Revoke personal access token
+//	@Description	Revoke personal access token of the current user
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Token id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/tokens/{id} [delete]
+func (at *AccessToken) DeleteHandler(ctx *fiber.Ctx) error {
+	const op = "DeleteHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	tokenId, err := ctx.ParamsInt("id")
+	if err != nil || tokenId <= 0 {
+		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+	}
+
+	err = at.accessTokenService.RevokeToken(ctx.UserContext(), controller.RequestedUserId(ctx), int64(tokenId))
+	if err != nil {
+		if errors.Is(err, accesstokenservice.ErrNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+		}
+
+		logger.AddContext(ctx.UserContext(), at.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+func response(t accesstokenstorage.AccessToken) accesstoken.Response {
+	return accesstoken.Response{
+		Id:         t.Id,
+		Name:       t.Name,
+		Scopes:     strings.Split(t.Scopes, ","),
+		Folder:     t.Folder.String,
+		ExpiresAt:  t.ExpiredAt.String,
+		LastUsedAt: t.LastUsedAt.String,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func validationFailed(ctx *fiber.Ctx, field, message string) error {
+	return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ValidationErrorResponse{
+		Message: controller.MessageValidationFailed,
+		Errors:  []entity.FieldError{{Field: field, Message: message}},
+	})
+}
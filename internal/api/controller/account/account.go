@@ -0,0 +1,257 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/profile"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	accountservice "github.com/albakov/go-cloud-file-storage/internal/service/account"
+	"github.com/albakov/go-cloud-file-storage/internal/service/email"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+type Account struct {
+	pkg            string
+	conf           *config.Config
+	accountService AccountService
+	validator      Validator
+	reauth         Reauthentication
+}
+
+type AccountService interface {
+	ChangePassword(ctx context.Context, userId int64, currentPassword, newPassword, refreshToken string) error
+	ChangeEmail(ctx context.Context, userId int64, confirmation accountservice.Confirmation, newEmail string) error
+	DeleteAccount(ctx context.Context, userId int64, confirmation accountservice.Confirmation) error
+}
+
+type Validator interface {
+	EmailErrors(field, email string) []entity.FieldError
+	PasswordErrors(field, password string) []entity.FieldError
+}
+
+// Reauthentication checks the proof of signing in with the linked identity provider again
+type Reauthentication interface {
+	Reauthenticated(token string, userId int64) bool
+}
+
+func New(conf *config.Config, accountService AccountService, validator Validator, reauth Reauthentication) *Account {
+	return &Account{
+		pkg:            "account",
+		conf:           conf,
+		accountService: accountService,
+		validator:      validator,
+		reauth:         reauth,
+	}
+}
+
+// ChangePasswordHandler godoc
+//
+//	@Summary		Change password
+//	@Description	Change password of the current user. All sessions except the current one are signed out
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			refresh_token	header		string							false	"Cookie refresh_token of the current session"
+//	@Param			credentials		body		profile.ChangePasswordRequest	true	"Current and new password"
+//	@Success		204				{object}	nil								"No content"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request or new password doesn't match the policy"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Password invalid"
+//	@Router			/user/password [patch]
+func (a *Account) ChangePasswordHandler(ctx *fiber.Ctx) error {
+	const op = "ChangePasswordHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r profile.ChangePasswordRequest
+	if err := ctx.BodyParser(&r); err != nil || r.CurrentPassword == "" || r.NewPassword == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	if errs := a.validator.PasswordErrors("new_password", r.NewPassword); len(errs) > 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(
+			&entity.ValidationErrorResponse{Message: controller.MessageValidationFailed, Errors: errs},
+		)
+	}
+
+	err := a.accountService.ChangePassword(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		r.CurrentPassword,
+		r.NewPassword,
+		ctx.Cookies("refresh_token"),
+	)
+	if err != nil {
+		if errors.Is(err, accountservice.ErrPasswordInvalid) {
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessagePasswordInvalid},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// ChangeEmailHandler godoc
+//
+//	@Summary		Change email
+//	@Description	Send a confirmation link to the new email. The email is changed after the link is opened.
+//	@Description	The password isn't required after signing in with the identity provider again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			credentials		body		profile.ChangeEmailRequest		true	"New email and current password"
+//	@Param			reauth			header		string							false	"Cookie reauth of signing in again"
+//	@Success		202				{object}	nil								"Confirmation link sent"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request or email invalid"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Password invalid or signing in again required"
+//	@Failure		409				{object}	entity.ErrorResponse			"User already exists"
+//	@Router			/user/email [patch]
+func (a *Account) ChangeEmailHandler(ctx *fiber.Ctx) error {
+	const op = "ChangeEmailHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId := controller.RequestedUserId(ctx)
+	confirmation := a.confirmation(ctx, userId)
+
+	var r profile.ChangeEmailRequest
+	if err := ctx.BodyParser(&r); err != nil || r.Email == "" || (r.Password == "" && !confirmation.Reauthenticated) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	confirmation.Password = r.Password
+
+	r.Email = email.Normalize(r.Email)
+
+	if errs := a.validator.EmailErrors("email", r.Email); len(errs) > 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(
+			&entity.ValidationErrorResponse{Message: controller.MessageValidationFailed, Errors: errs},
+		)
+	}
+
+	err := a.accountService.ChangeEmail(ctx.UserContext(), userId, confirmation, r.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, accountservice.ErrPasswordInvalid):
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessagePasswordInvalid},
+			)
+		case errors.Is(err, accountservice.ErrReauthenticationRequired):
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessageReauthRequired},
+			)
+		case errors.Is(err, accountservice.ErrSameEmail):
+			return ctx.Status(fiber.StatusBadRequest).JSON(
+				&entity.ErrorResponse{Message: controller.MessageEmailIsTheSame},
+			)
+		case errors.Is(err, userservice.ErrAlreadyExists):
+			return ctx.Status(fiber.StatusConflict).JSON(
+				&entity.ErrorResponse{Message: controller.MessageUserAlreadyExists},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	a.clearCookie(ctx, controller.ReauthCookie, "/api/user")
+	ctx.Status(fiber.StatusAccepted)
+
+	return nil
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Delete account
+//	@Description	Delete the current user with all sessions. Files are removed in background.
+//	@Description	The password isn't required after signing in with the identity provider again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			credentials		body		profile.DeleteAccountRequest	false	"Current password"
+//	@Param			reauth			header		string							false	"Cookie reauth of signing in again"
+//	@Success		204				{object}	nil								"No content"
+//	@Failure		400				{object}	entity.ErrorResponse			"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Password invalid or signing in again required"
+//	@Router			/user/me [delete]
+func (a *Account) DeleteHandler(ctx *fiber.Ctx) error {
+	const op = "DeleteHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId := controller.RequestedUserId(ctx)
+	confirmation := a.confirmation(ctx, userId)
+
+	var r profile.DeleteAccountRequest
+	if !confirmation.Reauthenticated {
+		if err := ctx.BodyParser(&r); err != nil || r.Password == "" {
+			return ctx.Status(fiber.StatusBadRequest).JSON(
+				&entity.ErrorResponse{Message: controller.MessageBadRequest},
+			)
+		}
+	}
+
+	confirmation.Password = r.Password
+
+	err := a.accountService.DeleteAccount(ctx.UserContext(), userId, confirmation)
+	if err != nil {
+		switch {
+		case errors.Is(err, accountservice.ErrPasswordInvalid):
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessagePasswordInvalid},
+			)
+		case errors.Is(err, accountservice.ErrReauthenticationRequired):
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessageReauthRequired},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	a.clearCookie(ctx, "refresh_token", "/")
+	a.clearCookie(ctx, controller.ReauthCookie, "/api/user")
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// confirmation tells if the user has just signed in with the identity provider again, the cookie is cleared
+// after the confirmed action
+func (a *Account) confirmation(ctx *fiber.Ctx, userId int64) accountservice.Confirmation {
+	return accountservice.Confirmation{
+		Reauthenticated: a.reauth.Reauthenticated(ctx.Cookies(controller.ReauthCookie), userId),
+	}
+}
+
+func (a *Account) clearCookie(ctx *fiber.Ctx, name, path string) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		HTTPOnly: true,
+		Secure:   a.conf.CookieSecure,
+		SameSite: a.conf.CookieSameSite,
+		Expires:  time.Now(),
+	})
+}
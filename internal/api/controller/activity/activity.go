@@ -0,0 +1,292 @@
+package activity
+
+import (
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/activity"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"time"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type Activity struct {
+	pkg          string
+	auditService AuditService
+}
+
+type AuditService interface {
+	Events(filter audit.Filter, page, perPage int) ([]audit.Record, int64, error)
+	Export(w io.Writer, format string, filter audit.Filter) error
+}
+
+func New(auditService AuditService) *Activity {
+	return &Activity{
+		pkg:          "activity",
+		auditService: auditService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		Own activity
+//	@Description	List security and file events of the current user, newest first.
+//	@Description	Actions: sign-in, refresh, sign-out, resource.create, resource.move, resource.delete, resource.download, resource.share
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			action			query		string					false	"Action"
+//	@Param			result			query		string					false	"success or failure"
+//	@Param			from			query		string					false	"Events since, RFC 3339"
+//	@Param			to				query		string					false	"Events before, RFC 3339"
+//	@Param			page			query		int						false	"Page, starts from 1"
+//	@Param			per_page		query		int						false	"Events per page, 20 by default, 100 at most"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	activity.EventsResponse	"Page of events"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/activity [get]
+func (a *Activity) IndexHandler(ctx *fiber.Ctx) error {
+	filter, err := requestedFilter(ctx)
+	if err != nil {
+		return badRequest(ctx)
+	}
+
+	filter.UserId = controller.RequestedUserId(ctx)
+
+	return a.events(ctx, filter)
+}
+
+// ExportHandler godoc
+//
+//	@Summary		Export own activity
+//	@Description	Export all security and file events of the current user matching the filter
+//	@Tags			user
+//	@Accept			json
+//	@Produce		text/csv,application/json
+//	@Param			format			query		string					false	"csv (default) or json"
+//	@Param			action			query		string					false	"Action"
+//	@Param			result			query		string					false	"success or failure"
+//	@Param			from			query		string					false	"Events since, RFC 3339"
+//	@Param			to				query		string					false	"Events before, RFC 3339"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{string}	binary					"Events as attachment"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/activity/export [get]
+func (a *Activity) ExportHandler(ctx *fiber.Ctx) error {
+	filter, err := requestedFilter(ctx)
+	if err != nil {
+		return badRequest(ctx)
+	}
+
+	filter.UserId = controller.RequestedUserId(ctx)
+
+	return a.export(ctx, filter)
+}
+
+// AdminIndexHandler godoc
+//
+//	@Summary		Audit log
+//	@Description	List security and file events of all users, newest first.
+//	@Description	Actions: sign-in, refresh, sign-out, resource.create, resource.move, resource.delete, resource.download, resource.share
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			user_id			query		int						false	"Actor id"
+//	@Param			email			query		string					false	"Email used to sign in"
+//	@Param			ip				query		string					false	"Client IP"
+//	@Param			action			query		string					false	"Action"
+//	@Param			result			query		string					false	"success or failure"
+//	@Param			from			query		string					false	"Events since, RFC 3339"
+//	@Param			to				query		string					false	"Events before, RFC 3339"
+//	@Param			page			query		int						false	"Page, starts from 1"
+//	@Param			per_page		query		int						false	"Events per page, 20 by default, 100 at most"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	activity.EventsResponse	"Page of events"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/activity [get]
+func (a *Activity) AdminIndexHandler(ctx *fiber.Ctx) error {
+	filter, err := requestedAdminFilter(ctx)
+	if err != nil {
+		return badRequest(ctx)
+	}
+
+	return a.events(ctx, filter)
+}
+
+// AdminExportHandler godoc
+//
+//	@Summary		Export audit log
+//	@Description	Export all security and file events matching the filter
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		text/csv,application/json
+//	@Param			format			query		string					false	"csv (default) or json"
+//	@Param			user_id			query		int						false	"Actor id"
+//	@Param			email			query		string					false	"Email used to sign in"
+//	@Param			ip				query		string					false	"Client IP"
+//	@Param			action			query		string					false	"Action"
+//	@Param			result			query		string					false	"success or failure"
+//	@Param			from			query		string					false	"Events since, RFC 3339"
+//	@Param			to				query		string					false	"Events before, RFC 3339"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{string}	binary					"Events as attachment"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/activity/export [get]
+func (a *Activity) AdminExportHandler(ctx *fiber.Ctx) error {
+	filter, err := requestedAdminFilter(ctx)
+	if err != nil {
+		return badRequest(ctx)
+	}
+
+	return a.export(ctx, filter)
+}
+
+func (a *Activity) events(ctx *fiber.Ctx, filter audit.Filter) error {
+	const op = "events"
+
+	controller.SetCommonHeaders(ctx)
+
+	page := ctx.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	perPage := ctx.QueryInt("per_page", defaultPerPage)
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+
+	records, total, err := a.auditService.Events(filter, page, perPage)
+	if err != nil {
+		if isInvalidFilter(err) {
+			return badRequest(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := activity.EventsResponse{
+		Events:  make([]activity.EventResponse, 0, len(records)),
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}
+	for _, r := range records {
+		data.Events = append(data.Events, activity.EventResponse(r))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+func (a *Activity) export(ctx *fiber.Ctx, filter audit.Filter) error {
+	const op = "export"
+
+	format := ctx.Query("format", audit.FormatCSV)
+
+	contentType := "text/csv; charset=utf-8"
+	if format == audit.FormatJSON {
+		contentType = fiber.MIMEApplicationJSONCharsetUTF8
+	}
+
+	err := a.auditService.Export(ctx, format, filter)
+	if err != nil {
+		ctx.Response().ResetBody()
+		controller.SetCommonHeaders(ctx)
+
+		if isInvalidFilter(err) || errors.Is(err, audit.ErrUnknownFormat) {
+			return badRequest(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ctx.Status(fiber.StatusOK)
+	ctx.Set(fiber.HeaderContentType, contentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="activity.%s"`, format))
+
+	return nil
+}
+
+// requestedFilter parses the filter available to every user
+func requestedFilter(ctx *fiber.Ctx) (audit.Filter, error) {
+	filter := audit.Filter{
+		Action: ctx.Query("action"),
+		Result: ctx.Query("result"),
+	}
+
+	var err error
+	if filter.From, err = requestedTime(ctx, "from"); err != nil {
+		return audit.Filter{}, err
+	}
+
+	if filter.To, err = requestedTime(ctx, "to"); err != nil {
+		return audit.Filter{}, err
+	}
+
+	return filter, nil
+}
+
+func requestedAdminFilter(ctx *fiber.Ctx) (audit.Filter, error) {
+	filter, err := requestedFilter(ctx)
+	if err != nil {
+		return audit.Filter{}, err
+	}
+
+	userId := ctx.QueryInt("user_id", 0)
+	if userId < 0 {
+		return audit.Filter{}, errors.New("user_id is negative")
+	}
+
+	filter.UserId = int64(userId)
+	filter.Email = ctx.Query("email")
+	filter.Ip = ctx.Query("ip")
+
+	return filter, nil
+}
+
+func requestedTime(ctx *fiber.Ctx, key string) (time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+func isInvalidFilter(err error) bool {
+	return errors.Is(err, audit.ErrUnknownAction) || errors.Is(err, audit.ErrUnknownResult)
+}
+
+func badRequest(ctx *fiber.Ctx) error {
+	controller.SetCommonHeaders(ctx)
+
+	return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+}
+
+func serverError(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+}
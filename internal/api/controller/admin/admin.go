@@ -0,0 +1,493 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/admin"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	adminservice "github.com/albakov/go-cloud-file-storage/internal/service/admin"
+	"github.com/albakov/go-cloud-file-storage/internal/service/maintenance"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type Admin struct {
+	pkg                string
+	adminService       AdminService
+	quotaService       QuotaService
+	maintenanceService MaintenanceService
+}
+
+type AdminService interface {
+	Users(ctx context.Context, query string, page, perPage int) ([]user.User, int64, error)
+	User(ctx context.Context, userId int64) (user.User, error)
+	DisableUser(ctx context.Context, adminId, userId int64) error
+	EnableUser(ctx context.Context, userId int64) error
+	SetRole(ctx context.Context, adminId, userId int64, role string) error
+	SetQuota(ctx context.Context, userId int64, quotaBytes int64) error
+	Logout(ctx context.Context, userId int64) error
+}
+
+type QuotaService interface {
+	Usage(ctx context.Context, userId int64) (quota.Usage, error)
+}
+
+type MaintenanceService interface {
+	Jobs() []string
+	Start(ctx context.Context, userId int64, job string) error
+}
+
+func New(adminService AdminService, quotaService QuotaService, maintenanceService MaintenanceService) *Admin {
+	return &Admin{
+		pkg:                "admin",
+		adminService:       adminService,
+		quotaService:       quotaService,
+		maintenanceService: maintenanceService,
+	}
+}
+
+// UsersHandler godoc
+//
+//	@Summary		List users
+//	@Description	List users whose email contains the query
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			query			query		string					false	"Part of email"
+//	@Param			page			query		int						false	"Page, starts from 1"
+//	@Param			per_page		query		int						false	"Users per page, 20 by default, 100 at most"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	admin.UsersResponse		"Page of users"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users [get]
+func (a *Admin) UsersHandler(ctx *fiber.Ctx) error {
+	const op = "UsersHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	page := ctx.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	perPage := ctx.QueryInt("per_page", defaultPerPage)
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+
+	users, total, err := a.adminService.Users(ctx.UserContext(), ctx.Query("query"), page, perPage)
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := admin.UsersResponse{
+		Users:   make([]admin.UserResponse, 0, len(users)),
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}
+	for _, us := range users {
+		data.Users = append(data.Users, userResponse(us))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// UserHandler godoc
+//
+//	@Summary		Show user
+//	@Description	Show user account
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	admin.UserResponse		"User"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id} [get]
+func (a *Admin) UserHandler(ctx *fiber.Ctx) error {
+	const op = "UserHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	us, err := a.adminService.User(ctx.UserContext(), userId)
+	if err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(userResponse(us))
+}
+
+// DisableHandler godoc
+//
+//	@Summary		Disable user
+//	@Description	Block sign in of the user and sign out all the sessions
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		409				{object}	entity.ErrorResponse	"Own account can't be disabled"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id}/disable [post]
+func (a *Admin) DisableHandler(ctx *fiber.Ctx) error {
+	const op = "DisableHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	if err := a.adminService.DisableUser(ctx.UserContext(), controller.RequestedUserId(ctx), userId); err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// EnableHandler godoc
+//
+//	@Summary		Enable user
+//	@Description	Allow sign in of the disabled user
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id}/enable [post]
+func (a *Admin) EnableHandler(ctx *fiber.Ctx) error {
+	const op = "EnableHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	if err := a.adminService.EnableUser(ctx.UserContext(), userId); err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// RoleHandler godoc
+//
+//	@Summary		Change user role
+//	@Description	Change role of the user: user or admin
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			role			body		admin.RoleRequest		true	"New role"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		400				{object}	entity.ErrorResponse	"Invalid role"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		409				{object}	entity.ErrorResponse	"Own role can't be changed"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id}/role [patch]
+func (a *Admin) RoleHandler(ctx *fiber.Ctx) error {
+	const op = "RoleHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	var r admin.RoleRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	if err := a.adminService.SetRole(ctx.UserContext(), controller.RequestedUserId(ctx), userId, r.Role); err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// QuotaHandler godoc
+//
+//	@Summary		Change user quota
+//	@Description	Change storage quota of the user in bytes. 0 - unlimited, null - the default quota
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			quota			body		admin.QuotaRequest		true	"New quota"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id}/quota [patch]
+func (a *Admin) QuotaHandler(ctx *fiber.Ctx) error {
+	const op = "QuotaHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	var r admin.QuotaRequest
+	if err := ctx.BodyParser(&r); err != nil || (r.QuotaBytes != nil && *r.QuotaBytes < 0) {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	// negative quota resets it to the default one
+	quotaBytes := int64(-1)
+	if r.QuotaBytes != nil {
+		quotaBytes = *r.QuotaBytes
+	}
+
+	if err := a.adminService.SetQuota(ctx.UserContext(), userId, quotaBytes); err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// UsageHandler godoc
+//
+//	@Summary		Show user storage usage
+//	@Description	Show used storage, number of objects and the quota of the user
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	admin.UsageResponse		"Storage usage"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id}/usage [get]
+func (a *Admin) UsageHandler(ctx *fiber.Ctx) error {
+	const op = "UsageHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	if _, err := a.adminService.User(ctx.UserContext(), userId); err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	usage, err := a.quotaService.Usage(ctx.UserContext(), userId)
+	if err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&admin.UsageResponse{
+		UsedBytes:  usage.UsedBytes,
+		Objects:    usage.Objects,
+		QuotaBytes: usage.QuotaBytes,
+	})
+}
+
+// LogoutHandler godoc
+//
+//	@Summary		Sign out user
+//	@Description	Sign out all sessions of the user
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"User id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/admin/users/{id}/sessions [delete]
+func (a *Admin) LogoutHandler(ctx *fiber.Ctx) error {
+	const op = "LogoutHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId, ok := requestedUserId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	if err := a.adminService.Logout(ctx.UserContext(), userId); err != nil {
+		return a.failed(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// JobsHandler godoc
+//
+//	@Summary		List maintenance jobs
+//	@Description	List maintenance jobs which can be started
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	admin.JobsResponse		"Maintenance jobs"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Router			/admin/maintenance [get]
+func (a *Admin) JobsHandler(ctx *fiber.Ctx) error {
+	controller.SetCommonHeaders(ctx)
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&admin.JobsResponse{Jobs: a.maintenanceService.Jobs()})
+}
+
+// StartJobHandler godoc
+//
+//	@Summary		Start maintenance job
+//	@Description	Start maintenance job in background: expired-sessions, expired-tokens, expired-changes,
+//	@Description	expired-webhook-deliveries, expired-jobs, expired-exports or orphaned-folders.
+//	@Description	The job.done event is sent to the administrator when the job is finished
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			job				path		string					true	"Job name"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		202				{object}	nil						"Job is started"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Forbidden"
+//	@Failure		404				{object}	entity.ErrorResponse	"Unknown job"
+//	@Failure		409				{object}	entity.ErrorResponse	"Job is already running"
+//	@Router			/admin/maintenance/{job} [post]
+func (a *Admin) StartJobHandler(ctx *fiber.Ctx) error {
+	const op = "StartJobHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	err := a.maintenanceService.Start(ctx.UserContext(), controller.RequestedUserId(ctx), ctx.Params("job"))
+	if err != nil {
+		switch {
+		case errors.Is(err, maintenance.ErrUnknownJob):
+			return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageUnknownJob})
+		case errors.Is(err, maintenance.ErrAlreadyRunning):
+			return ctx.Status(fiber.StatusConflict).JSON(
+				&entity.ErrorResponse{Message: controller.MessageJobAlreadyRunning},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ctx.Status(fiber.StatusAccepted)
+
+	return nil
+}
+
+func (a *Admin) failed(ctx *fiber.Ctx, op string, err error) error {
+	switch {
+	case errors.Is(err, adminservice.ErrNotFound):
+		return notFound(ctx)
+	case errors.Is(err, adminservice.ErrSelf):
+		return ctx.Status(fiber.StatusConflict).JSON(&entity.ErrorResponse{Message: controller.MessageCannotChangeSelf})
+	case errors.Is(err, userservice.ErrInvalidRole):
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageInvalidRole})
+	}
+
+	logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+
+	return serverError(ctx)
+}
+
+func requestedUserId(ctx *fiber.Ctx) (int64, bool) {
+	userId, err := ctx.ParamsInt("id")
+	if err != nil || userId <= 0 {
+		return 0, false
+	}
+
+	return int64(userId), true
+}
+
+func userResponse(us user.User) admin.UserResponse {
+	r := admin.UserResponse{
+		Id:                    us.Id,
+		Email:                 us.Email.String,
+		EmailVerified:         us.EmailVerifiedAt.Valid,
+		Role:                  us.Role,
+		Disabled:              us.DisabledAt.Valid,
+		DisabledAt:            us.DisabledAt.String,
+		PasswordLoginDisabled: us.PasswordLoginDisabled,
+		CreatedAt:             us.CreatedAt,
+	}
+
+	if us.QuotaBytes.Valid {
+		r.QuotaBytes = &us.QuotaBytes.Int64
+	}
+
+	return r
+}
+
+func notFound(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+}
+
+func serverError(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+}
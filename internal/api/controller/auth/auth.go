@@ -1,12 +1,16 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity/profile"
 	"github.com/albakov/go-cloud-file-storage/internal/config"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
 	"github.com/albakov/go-cloud-file-storage/internal/service/password"
 	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
 	usersessionservice "github.com/albakov/go-cloud-file-storage/internal/service/usersession"
@@ -18,11 +22,15 @@ import (
 )
 
 type Auth struct {
-	pkg                string
-	conf               *config.Config
-	authService        AuthService
-	userService        UserService
-	userSessionService UserSessionService
+	pkg                 string
+	conf                *config.Config
+	authService         AuthService
+	userService         UserService
+	userSessionService  UserSessionService
+	verificationService VerificationService
+	loginGuard          LoginGuard
+	auditService        AuditService
+	eventBus            EventBus
 }
 
 type AuthService interface {
@@ -31,14 +39,34 @@ type AuthService interface {
 }
 
 type UserService interface {
-	CreateUser(userEntity userservice.User) (user.User, error)
-	UserByEmail(email string) (user.User, error)
+	CreateUser(ctx context.Context, userEntity userservice.User) (user.User, error)
+	UserByEmail(ctx context.Context, email string) (user.User, error)
+	UserById(ctx context.Context, userId int64) (user.User, error)
 }
 
 type UserSessionService interface {
-	ValidUserSessionByRefreshToken(refreshToken string) (usersession.Session, error)
-	CreateUserSession(userSessionEntity usersessionservice.UserSession) (usersession.Session, error)
-	DeleteUserSession(userId int64, refreshToken string) error
+	ValidUserSessionByRefreshToken(ctx context.Context, refreshToken string) (usersession.Session, error)
+	CreateUserSession(ctx context.Context, userSessionEntity usersessionservice.UserSession) (usersession.Session, error)
+	DeleteUserSession(ctx context.Context, userId int64, refreshToken string) error
+}
+
+type VerificationService interface {
+	SendEmailVerification(ctx context.Context, userId int64) error
+}
+
+type AuditService interface {
+	Record(event audit.Event)
+}
+
+// EventBus tells other connections of the user about new and ended sessions
+type EventBus interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+type LoginGuard interface {
+	Check(ctx context.Context, email string) (time.Duration, error)
+	Failed(ctx context.Context, email string) error
+	Succeeded(ctx context.Context, email string) error
 }
 
 func New(
@@ -46,13 +74,21 @@ func New(
 	authService AuthService,
 	userService UserService,
 	userSessionService UserSessionService,
+	verificationService VerificationService,
+	loginGuard LoginGuard,
+	auditService AuditService,
+	eventBus EventBus,
 ) *Auth {
 	return &Auth{
-		pkg:                "auth",
-		conf:               conf,
-		authService:        authService,
-		userService:        userService,
-		userSessionService: userSessionService,
+		pkg:                 "auth",
+		conf:                conf,
+		authService:         authService,
+		userService:         userService,
+		userSessionService:  userSessionService,
+		verificationService: verificationService,
+		loginGuard:          loginGuard,
+		auditService:        auditService,
+		eventBus:            eventBus,
 	}
 }
 
@@ -67,7 +103,10 @@ func New(
 //	@Success		200			{object}	profile.LoginResponse	"Success auth"
 //	@Failure		400			{object}	entity.ErrorResponse	"Bad request"
 //	@Failure		401			{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403			{object}	entity.ErrorResponse	"Account is disabled or password sign in is disabled for the user"
+//	@Failure		429			{object}	entity.ErrorResponse	"Too many attempts or account locked, see Retry-After header"
 //	@Header			200			{string}	refresh_token			"Set refresh token in cookie to recreate access_token"
+//	@Header			429			{integer}	Retry-After				"Seconds to wait before the next attempt"
 //	@Router			/auth/sign-in [post]
 func (a *Auth) LoginHandler(ctx *fiber.Ctx) error {
 	const op = "loginHandler"
@@ -75,45 +114,82 @@ func (a *Auth) LoginHandler(ctx *fiber.Ctx) error {
 	controller.SetCommonHeaders(ctx)
 	r := controller.RequestedLogin(ctx)
 
-	us, err := a.userService.UserByEmail(r.Email)
+	retryAfter, err := a.loginGuard.Check(ctx.UserContext(), r.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, loginguard.ErrLocked):
+			a.record(ctx, audit.ActionSignIn, audit.ResultFailure, 0, r.Email)
+			controller.SetRetryAfter(ctx, retryAfter)
+
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(
+				&entity.ErrorResponse{Message: controller.MessageAccountLocked},
+			)
+		case errors.Is(err, loginguard.ErrTooManyAttempts):
+			a.record(ctx, audit.ActionSignIn, audit.ResultFailure, 0, r.Email)
+			controller.SetRetryAfter(ctx, retryAfter)
+
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(
+				&entity.ErrorResponse{Message: controller.MessageTooManyRequests},
+			)
+		}
+
+		// the store is unavailable, don't block users because of it
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+	}
+
+	us, err := a.userService.UserByEmail(ctx.UserContext(), r.Email)
 	if err != nil {
 		if !errors.Is(err, userservice.ErrNotFound) {
-			logger.Add(a.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 		}
 
+		a.loginFailed(ctx, 0, r.Email)
+
 		return ctx.Status(fiber.StatusUnauthorized).JSON(
 			&entity.ErrorResponse{Message: controller.MessageLoginOrPasswordInvalid},
 		)
 	}
 
 	if !password.CheckPassword(r.Password, us.Password) {
+		a.loginFailed(ctx, us.Id, r.Email)
+
 		return ctx.Status(fiber.StatusUnauthorized).JSON(
 			&entity.ErrorResponse{Message: controller.MessageLoginOrPasswordInvalid},
 		)
 	}
 
-	accessToken, refreshToken, err := a.tokens(us.Id)
-	if err != nil {
-		logger.Add(a.pkg, op, err)
+	if err := a.loginGuard.Succeeded(ctx.UserContext(), r.Email); err != nil {
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+	}
 
-		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	// the password is checked first, so the state of the account isn't revealed
+	if us.DisabledAt.Valid {
+		a.record(ctx, audit.ActionSignIn, audit.ResultFailure, us.Id, r.Email)
+
+		return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageAccountDisabled})
 	}
 
-	expires := time.Now().Add(time.Hour * time.Duration(a.conf.CookieExpires))
-	_, err = a.userSessionService.CreateUserSession(usersessionservice.UserSession{
-		UserId:       us.Id,
-		RefreshToken: refreshToken,
-		ExpiredAt:    expires.Format(time.DateTime),
-	})
+	if us.PasswordLoginDisabled {
+		a.record(ctx, audit.ActionSignIn, audit.ResultFailure, us.Id, r.Email)
+
+		return ctx.Status(fiber.StatusForbidden).JSON(
+			&entity.ErrorResponse{Message: controller.MessagePasswordLoginDisabled},
+		)
+	}
+
+	accessToken, err := a.SignIn(ctx, us.Id)
 	if err != nil {
 		if !errors.Is(err, usersessionservice.ErrAlreadyExists) {
-			logger.Add(a.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 		}
 
+		a.record(ctx, audit.ActionSignIn, audit.ResultFailure, us.Id, r.Email)
+
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
-	a.setCookie(ctx, refreshToken, expires)
+	a.record(ctx, audit.ActionSignIn, audit.ResultSuccess, us.Id, r.Email)
+
 	ctx.Status(fiber.StatusOK)
 
 	return ctx.JSON(&profile.LoginResponse{
@@ -124,15 +200,15 @@ func (a *Auth) LoginHandler(ctx *fiber.Ctx) error {
 // RegisterHandler godoc
 //
 //	@Summary		User Registration
-//	@Description	Register user using email and password
+//	@Description	Register user using email and password, a link to confirm the email is sent
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
-//	@Param			credentials	body		profile.RegisterRequest	true	"Credentials to register"
-//	@Success		201			{object}	profile.LoginResponse	"User created"
-//	@Failure		400			{object}	entity.ErrorResponse	"Bad request"
-//	@Failure		409			{object}	entity.ErrorResponse	"User already exists"
-//	@Header			200			{string}	refresh_token			"Set refresh token in cookie to recreate access_token"
+//	@Param			credentials	body		profile.RegisterRequest			true	"Credentials to register"
+//	@Success		201			{object}	profile.LoginResponse			"User created"
+//	@Failure		400			{object}	entity.ValidationErrorResponse	"Email invalid or password doesn't match the policy"
+//	@Failure		409			{object}	entity.ErrorResponse			"User already exists"
+//	@Header			200			{string}	refresh_token					"Set refresh token in cookie to recreate access_token"
 //	@Router			/auth/sign-up [post]
 func (a *Auth) RegisterHandler(ctx *fiber.Ctx) error {
 	const op = "registerHandler"
@@ -140,7 +216,7 @@ func (a *Auth) RegisterHandler(ctx *fiber.Ctx) error {
 	controller.SetCommonHeaders(ctx)
 	r := controller.RequestedLogin(ctx)
 
-	us, err := a.userService.CreateUser(userservice.User{
+	us, err := a.userService.CreateUser(ctx.UserContext(), userservice.User{
 		Email:    r.Email,
 		Password: r.Password,
 	})
@@ -151,33 +227,25 @@ func (a *Auth) RegisterHandler(ctx *fiber.Ctx) error {
 			)
 		}
 
-		logger.Add(a.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
-	accessToken, refreshToken, err := a.tokens(us.Id)
-	if err != nil {
-		logger.Add(a.pkg, op, err)
-
-		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	// the user is already registered, so the failed email is only logged, it can be requested again
+	if err := a.verificationService.SendEmailVerification(ctx.UserContext(), us.Id); err != nil {
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 	}
 
-	expires := time.Now().Add(time.Hour * time.Duration(a.conf.CookieExpires))
-	_, err = a.userSessionService.CreateUserSession(usersessionservice.UserSession{
-		UserId:       us.Id,
-		RefreshToken: refreshToken,
-		ExpiredAt:    expires.Format(time.DateTime),
-	})
+	accessToken, err := a.SignIn(ctx, us.Id)
 	if err != nil {
 		if !errors.Is(err, usersessionservice.ErrAlreadyExists) {
-			logger.Add(a.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 		}
 
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
-	a.setCookie(ctx, refreshToken, expires)
 	ctx.Status(fiber.StatusCreated)
 
 	return ctx.JSON(&profile.LoginResponse{
@@ -195,6 +263,7 @@ func (a *Auth) RegisterHandler(ctx *fiber.Ctx) error {
 //	@Param			refresh_token	header		string								true	"Cookie refresh_token"
 //	@Success		200				{object}	profile.RefreshAccessTokenResponse	"New access_token"
 //	@Failure		401				{object}	entity.ErrorResponse				"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse				"Account is disabled"
 //	@Router			/auth/refresh-token [post]
 func (a *Auth) RefreshHandler(ctx *fiber.Ctx) error {
 	const op = "refreshHandler"
@@ -206,22 +275,40 @@ func (a *Auth) RefreshHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
-	us, err := a.userSessionService.ValidUserSessionByRefreshToken(refreshToken)
+	us, err := a.userSessionService.ValidUserSessionByRefreshToken(ctx.UserContext(), refreshToken)
 	if err != nil {
 		if !errors.Is(err, usersessionservice.ErrNotFound) && !errors.Is(err, usersessionservice.ErrSessionExpired) {
-			logger.Add(a.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+		}
+
+		a.record(ctx, audit.ActionRefresh, audit.ResultFailure, 0, "")
+
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
+	}
+
+	u, err := a.userService.UserById(ctx.UserContext(), us.UserId)
+	if err != nil {
+		if !errors.Is(err, userservice.ErrNotFound) {
+			logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 		}
 
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
+	if u.DisabledAt.Valid {
+		a.record(ctx, audit.ActionRefresh, audit.ResultFailure, u.Id, "")
+
+		return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageAccountDisabled})
+	}
+
 	accessToken, err := a.authService.GenerateAccessToken(us.UserId)
 	if err != nil {
-		logger.Add(a.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
+	a.record(ctx, audit.ActionRefresh, audit.ResultSuccess, us.UserId, "")
 	ctx.Status(fiber.StatusOK)
 
 	return ctx.JSON(&profile.RefreshAccessTokenResponse{
@@ -250,22 +337,26 @@ func (a *Auth) LogoutHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
-	us, err := a.userSessionService.ValidUserSessionByRefreshToken(refreshToken)
+	us, err := a.userSessionService.ValidUserSessionByRefreshToken(ctx.UserContext(), refreshToken)
 	if err != nil {
 		if !errors.Is(err, usersessionservice.ErrNotFound) && !errors.Is(err, usersessionservice.ErrSessionExpired) {
-			logger.Add(a.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), a.pkg, op, err)
 		}
 
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
-	err = a.userSessionService.DeleteUserSession(us.UserId, refreshToken)
+	err = a.userSessionService.DeleteUserSession(ctx.UserContext(), us.UserId, refreshToken)
 	if err != nil {
-		logger.Add(a.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), a.pkg, op, err)
+		a.record(ctx, audit.ActionSignOut, audit.ResultFailure, us.UserId, "")
 
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
 
+	a.record(ctx, audit.ActionSignOut, audit.ResultSuccess, us.UserId, "")
+	a.publish(ctx, events.TypeSignOut, us.UserId)
+
 	// clear cookie
 	a.setCookie(ctx, "", time.Now())
 	ctx.Status(fiber.StatusOK)
@@ -273,6 +364,59 @@ func (a *Auth) LogoutHandler(ctx *fiber.Ctx) error {
 	return nil
 }
 
+// SignIn creates a session of the user, sets the refresh token cookie and returns a new access token
+func (a *Auth) SignIn(ctx *fiber.Ctx, userId int64) (string, error) {
+	const op = "SignIn"
+
+	accessToken, refreshToken, err := a.tokens(userId)
+	if err != nil {
+		return "", logger.Error(a.pkg, op, err)
+	}
+
+	expires := time.Now().Add(time.Hour * time.Duration(a.conf.CookieExpires))
+	_, err = a.userSessionService.CreateUserSession(ctx.UserContext(), usersessionservice.UserSession{
+		UserId:       userId,
+		RefreshToken: refreshToken,
+		ExpiredAt:    expires.Format(time.DateTime),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	a.setCookie(ctx, refreshToken, expires)
+	a.publish(ctx, events.TypeSignIn, userId)
+
+	return accessToken, nil
+}
+
+func (a *Auth) loginFailed(ctx *fiber.Ctx, userId int64, email string) {
+	a.record(ctx, audit.ActionSignIn, audit.ResultFailure, userId, email)
+
+	if err := a.loginGuard.Failed(ctx.UserContext(), email); err != nil {
+		logger.AddContext(ctx.UserContext(), a.pkg, "loginFailed", err)
+	}
+}
+
+// record records the auth event, the actor isn't authenticated yet, so it's passed explicitly
+func (a *Auth) record(ctx *fiber.Ctx, action, result string, userId int64, email string) {
+	event := controller.AuditEvent(ctx, action, result)
+	event.UserId = userId
+	event.Email = email
+
+	a.auditService.Record(event)
+}
+
+func (a *Auth) publish(ctx *fiber.Ctx, eventType string, userId int64) {
+	a.eventBus.Publish(ctx.UserContext(), events.Event{
+		UserId: userId,
+		Type:   eventType,
+		Data: map[string]string{
+			"ip":         ctx.IP(),
+			"user_agent": ctx.Get(fiber.HeaderUserAgent),
+		},
+	})
+}
+
 func (a *Auth) setCookie(ctx *fiber.Ctx, refreshToken string, expires time.Time) {
 	ctx.Cookie(&fiber.Cookie{
 		Name:     "refresh_token",
@@ -0,0 +1,143 @@
+package changes
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/changes"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/journal"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultLimit   = 500
+	maxLimit       = 1000
+	defaultTimeout = 30
+	maxTimeout     = 60
+)
+
+type Changes struct {
+	pkg            string
+	journalService JournalService
+}
+
+type JournalService interface {
+	Cursor(ctx context.Context, userId int64) (int64, error)
+	Changes(ctx context.Context, userId, since int64, folder string, limit int, wait time.Duration) (journal.Page, error)
+}
+
+func New(journalService JournalService) *Changes {
+	return &Changes{
+		pkg:            "changes",
+		journalService: journalService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		Changes of files
+//	@Description	Long poll of the journal of file changes: create, update, move and delete, oldest first.
+//	@Description	Without since the cursor of the latest change is returned, the client lists its files and follows changes from it.
+//	@Description	If there are no changes after the cursor, the request waits for them up to timeout seconds.
+//	@Description	410 means the changes after the cursor are removed, the client lists its files again
+//	@Tags			resource
+//	@Accept			json
+//	@Produce		json
+//	@Param			since			query		int						false	"Cursor of the last received change"
+//	@Param			limit			query		int						false	"Changes per page, 500 by default, 1000 at most"
+//	@Param			timeout			query		int						false	"Seconds to wait for changes, 30 by default, 60 at most, 0 - don't wait"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	changes.PageResponse	"Page of changes"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		410				{object}	entity.ErrorResponse	"Cursor expired"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/changes [get]
+func (c *Changes) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	userId := controller.RequestedUserId(ctx)
+
+	if ctx.Query("since") == "" {
+		cursor, err := c.journalService.Cursor(ctx.UserContext(), userId)
+		if err != nil {
+			logger.AddContext(ctx.UserContext(), c.pkg, op, err)
+
+			return serverError(ctx)
+		}
+
+		ctx.Status(fiber.StatusOK)
+
+		return ctx.JSON(&changes.PageResponse{Changes: []changes.ChangeResponse{}, Cursor: cursor})
+	}
+
+	since, err := strconv.ParseInt(ctx.Query("since"), 10, 64)
+	if err != nil || since < 0 {
+		return badRequest(ctx, controller.MessageBadRequest)
+	}
+
+	limit := ctx.QueryInt("limit", defaultLimit)
+	if limit < 1 || limit > maxLimit {
+		limit = defaultLimit
+	}
+
+	timeout := ctx.QueryInt("timeout", defaultTimeout)
+	if timeout < 0 || timeout > maxTimeout {
+		timeout = defaultTimeout
+	}
+
+	// the token restricted to the folder sees changes inside of it only
+	var folder string
+	if t, ok := controller.RequestedAccessToken(ctx); ok {
+		folder = t.Folder
+	}
+
+	page, err := c.journalService.Changes(
+		ctx.UserContext(),
+		userId,
+		since,
+		folder,
+		limit,
+		time.Second*time.Duration(timeout),
+	)
+	if err != nil {
+		if errors.Is(err, journal.ErrInvalidCursor) {
+			return badRequest(ctx, controller.MessageCursorInvalid)
+		}
+
+		if errors.Is(err, journal.ErrCursorExpired) {
+			return ctx.Status(fiber.StatusGone).JSON(&entity.ErrorResponse{Message: controller.MessageCursorExpired})
+		}
+
+		logger.AddContext(ctx.UserContext(), c.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := changes.PageResponse{
+		Changes: make([]changes.ChangeResponse, 0, len(page.Changes)),
+		Cursor:  page.Cursor,
+		HasMore: page.HasMore,
+	}
+	for _, change := range page.Changes {
+		data.Changes = append(data.Changes, changes.ChangeResponse(change))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+func badRequest(ctx *fiber.Ctx, message string) error {
+	return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: message})
+}
+
+func serverError(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+}
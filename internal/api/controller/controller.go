@@ -1,14 +1,46 @@
 package controller
 
 import (
+	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity/profile"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
 	"github.com/gofiber/fiber/v2"
+	"math"
+	"strconv"
+	"time"
 )
 
+// ReauthCookie keeps the proof that the user has just signed in with the linked identity provider again,
+// it confirms changing the email and deleting the account instead of the password
+const ReauthCookie = "reauth"
+
 func RequestedUserId(ctx *fiber.Ctx) int64 {
 	return ctx.Locals("user_id").(int64)
 }
 
+// AuditEvent returns the audit event of the action with the actor and the client of the request,
+// the actor is unknown if the request is not authenticated
+func AuditEvent(ctx *fiber.Ctx, action, result string) audit.Event {
+	userId, _ := ctx.Locals("user_id").(int64)
+
+	return audit.Event{
+		UserId:    userId,
+		Action:    action,
+		Result:    result,
+		Ip:        ctx.IP(),
+		UserAgent: ctx.Get(fiber.HeaderUserAgent),
+	}
+}
+
+// RequestedAccessToken returns the personal access token used to authenticate the request,
+// false means the request is authenticated with a session
+func RequestedAccessToken(ctx *fiber.Ctx) (accesstoken.Token, bool) {
+	t, ok := ctx.Locals("access_token").(accesstoken.Token)
+
+	return t, ok
+}
+
 func RequestedLogin(ctx *fiber.Ctx) profile.LoginRequest {
 	return ctx.Locals("user_data").(profile.LoginRequest)
 }
@@ -18,3 +50,22 @@ func SetCommonHeaders(ctx *fiber.Ctx) {
 	ctx.Set(fiber.HeaderContentType, "application/json")
 	ctx.Set(fiber.HeaderAccept, "application/json")
 }
+
+// ResponseStatus returns the status of the response, taking into account the error returned by the handlers
+func ResponseStatus(ctx *fiber.Ctx, err error) int {
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return fiberErr.Code
+	}
+
+	if err != nil {
+		return fiber.StatusInternalServerError
+	}
+
+	return ctx.Response().StatusCode()
+}
+
+// SetRetryAfter sets Retry-After header in seconds, rounded up
+func SetRetryAfter(ctx *fiber.Ctx, d time.Duration) {
+	ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(d.Seconds()))))
+}
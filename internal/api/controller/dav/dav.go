@@ -0,0 +1,278 @@
+package dav
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/credentials"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Prefix is the path WebDAV is served under
+const Prefix = "/dav"
+
+// Methods are WebDAV methods Fiber must accept in addition to the HTTP ones
+var Methods = []string{"PROPFIND", "PROPPATCH", "MKCOL", "COPY", "MOVE", "LOCK", "UNLOCK"}
+
+type Dav struct {
+	pkg                string
+	davService         DavService
+	credentialsService CredentialsService
+	quotaService       QuotaService
+	auditService       AuditService
+}
+
+type DavService interface {
+	Handler(prefix string, userId int64, folder string) http.Handler
+}
+
+type CredentialsService interface {
+	Authenticate(ctx context.Context, username, secret string) (credentials.Identity, time.Duration, error)
+}
+
+type QuotaService interface {
+	Check(ctx context.Context, userId, incoming int64) error
+}
+
+type AuditService interface {
+	Record(event audit.Event)
+}
+
+func New(
+	davService DavService,
+	credentialsService CredentialsService,
+	quotaService QuotaService,
+	auditService AuditService,
+) *Dav {
+	return &Dav{
+		pkg:                "dav",
+		davService:         davService,
+		credentialsService: credentialsService,
+		quotaService:       quotaService,
+		auditService:       auditService,
+	}
+}
+
+// Authenticated accepts Basic credentials: the email with the password or a personal access token.
+// WebDAV clients send them with every request, after the challenge to the first one
+func (d *Dav) Authenticated(ctx *fiber.Ctx) error {
+	const op = "Authenticated"
+
+	username, secret, ok := basicAuth(ctx)
+	if !ok {
+		return d.challenge(ctx)
+	}
+
+	identity, retryAfter, err := d.credentialsService.Authenticate(ctx.UserContext(), username, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, credentials.ErrInvalid):
+			d.signInFailed(ctx, username, secret)
+
+			return d.challenge(ctx)
+		case errors.Is(err, loginguard.ErrLocked):
+			d.signInFailed(ctx, username, secret)
+			controller.SetRetryAfter(ctx, retryAfter)
+
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(
+				&entity.ErrorResponse{Message: controller.MessageAccountLocked},
+			)
+		case errors.Is(err, loginguard.ErrTooManyAttempts):
+			d.signInFailed(ctx, username, secret)
+			controller.SetRetryAfter(ctx, retryAfter)
+
+			return ctx.Status(fiber.StatusTooManyRequests).JSON(
+				&entity.ErrorResponse{Message: controller.MessageTooManyRequests},
+			)
+		case errors.Is(err, credentials.ErrAccountDisabled):
+			return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageAccountDisabled})
+		case errors.Is(err, credentials.ErrPasswordLoginDisabled):
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessagePasswordLoginDisabled},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), d.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+	}
+
+	ctx.Locals("user_id", identity.UserId)
+	ctx.SetUserContext(logger.With(ctx.UserContext(), "user_id", identity.UserId))
+
+	if identity.IsAccessToken() {
+		ctx.Locals("access_token", identity.AccessToken)
+	}
+
+	return ctx.Next()
+}
+
+// Handler serves WebDAV requests of the authenticated user. Personal access tokens restricted to a folder
+// see the folder as the root
+func (d *Dav) Handler(ctx *fiber.Ctx) error {
+	const op = "Handler"
+
+	userId := controller.RequestedUserId(ctx)
+	method := ctx.Method()
+
+	t, isAccessToken := controller.RequestedAccessToken(ctx)
+	if isAccessToken && !t.HasScope(scope(method)) {
+		return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageInsufficientScope})
+	}
+
+	name := d.requestedName(ctx, t.Folder)
+
+	if method == fiber.MethodPut {
+		err := d.quotaService.Check(ctx.UserContext(), userId, int64(len(ctx.Body())))
+		if err != nil {
+			if errors.Is(err, quota.ErrExceeded) {
+				d.record(ctx, audit.ActionResourceCreate, audit.ResultFailure, name, "")
+
+				return ctx.Status(fiber.StatusInsufficientStorage).JSON(
+					&entity.ErrorResponse{Message: controller.MessageQuotaExceeded},
+				)
+			}
+
+			logger.AddContext(ctx.UserContext(), d.pkg, op, err)
+
+			return ctx.Status(fiber.StatusInternalServerError).JSON(
+				&entity.ErrorResponse{Message: controller.MessageServerError},
+			)
+		}
+	}
+
+	// the handler gets the context of the request, so S3 calls are traced and cancelled on shutdown
+	userCtx := ctx.UserContext()
+	handler := d.davService.Handler(Prefix, userId, t.Folder)
+
+	err := adaptor.HTTPHandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, r.WithContext(userCtx))
+	})(ctx)
+	if err != nil {
+		return err
+	}
+
+	d.recordResult(ctx, method, name, t.Folder)
+
+	return nil
+}
+
+func (d *Dav) challenge(ctx *fiber.Ctx) error {
+	ctx.Set(fiber.HeaderWWWAuthenticate, `Basic realm="Cloud File Storage", charset="UTF-8"`)
+
+	return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
+}
+
+// signInFailed records the failed attempt to authenticate with the password,
+// invalid personal access tokens aren't attempts to sign in to the account
+func (d *Dav) signInFailed(ctx *fiber.Ctx, email, secret string) {
+	if accesstoken.IsAccessToken(secret) {
+		return
+	}
+
+	event := controller.AuditEvent(ctx, audit.ActionSignIn, audit.ResultFailure)
+	event.Email = email
+
+	d.auditService.Record(event)
+}
+
+// recordResult records file events of the served request
+func (d *Dav) recordResult(ctx *fiber.Ctx, method, name, folder string) {
+	var action, targetPath string
+
+	switch method {
+	case fiber.MethodGet:
+		action = audit.ActionResourceDownload
+	case fiber.MethodPut, "MKCOL", "COPY":
+		action = audit.ActionResourceCreate
+	case "MOVE":
+		action = audit.ActionResourceMove
+		targetPath = destinationName(ctx.Get("Destination"), folder)
+	case fiber.MethodDelete:
+		action = audit.ActionResourceDelete
+	default:
+		return
+	}
+
+	// copies are recorded as created at the destination
+	if method == "COPY" {
+		name = destinationName(ctx.Get("Destination"), folder)
+	}
+
+	status := ctx.Response().StatusCode()
+	switch {
+	case status >= 200 && status < 300:
+		d.record(ctx, action, audit.ResultSuccess, name, targetPath)
+	case status >= 400 && status != fiber.StatusNotFound:
+		d.record(ctx, action, audit.ResultFailure, name, targetPath)
+	}
+}
+
+func (d *Dav) record(ctx *fiber.Ctx, action, result, path, targetPath string) {
+	event := controller.AuditEvent(ctx, action, result)
+	event.Path = path
+	event.TargetPath = targetPath
+
+	d.auditService.Record(event)
+}
+
+// requestedName returns the requested path in the user's files
+func (d *Dav) requestedName(ctx *fiber.Ctx, folder string) string {
+	name, err := url.PathUnescape(strings.TrimPrefix(ctx.Path(), Prefix))
+	if err != nil {
+		name = strings.TrimPrefix(ctx.Path(), Prefix)
+	}
+
+	return path.Join("/", folder, name)
+}
+
+// destinationName returns the path in the user's files of the Destination header of COPY and MOVE
+func destinationName(destination, folder string) string {
+	u, err := url.Parse(destination)
+	if err != nil {
+		return ""
+	}
+
+	return path.Join("/", folder, strings.TrimPrefix(u.Path, Prefix))
+}
+
+// scope returns the scope of personal access tokens the method requires
+func scope(method string) string {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, "PROPFIND":
+		return accesstoken.ScopeRead
+	case fiber.MethodDelete:
+		return accesstoken.ScopeDelete
+	default:
+		return accesstoken.ScopeWrite
+	}
+}
+
+func basicAuth(ctx *fiber.Ctx) (string, string, bool) {
+	const prefix = "Basic "
+
+	auth := ctx.Get(fiber.HeaderAuthorization)
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,246 @@
+package events
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/events"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	eventservice "github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// retryMillis is how soon the browser reconnects after the stream ends, e.g. on shutdown
+	retryMillis = 3000
+	// writeTimeout limits the write of one message to the WebSocket of a stalled client
+	writeTimeout = 10 * time.Second
+)
+
+type Events struct {
+	pkg       string
+	heartbeat time.Duration
+	eventBus  EventBus
+}
+
+type EventBus interface {
+	Subscribe(userId int64, filter eventservice.Filter) *eventservice.Subscription
+}
+
+func New(conf *config.Config, eventBus EventBus) *Events {
+	return &Events{
+		pkg:       "events",
+		heartbeat: time.Second * time.Duration(conf.EventsHeartbeatSeconds),
+		eventBus:  eventBus,
+	}
+}
+
+// StreamHandler godoc
+//
+//	@Summary		Stream of events
+//	@Description	Server-Sent Events of the user: file.created, file.updated, file.moved, file.deleted, quota.warning,
+//	@Description	job.done, export.ready, auth.sign-in, auth.sign-out and webhook.disabled. The event name is its type,
+//	@Description	the data is EventResponse.
+//	@Description	Comments are sent as the heartbeat. Events aren't stored, events missed while disconnected are
+//	@Description	requested from /changes with the cursor of the last file event.
+//	@Description	The stream ends if the client doesn't keep up with events, the client reconnects then.
+//	@Description	Browsers pass the token in the access_token query parameter
+//	@Tags			events
+//	@Produce		text/event-stream
+//	@Param			types			query		string					false	"Comma-separated types of events, all by default"
+//	@Param			folder			query		string					false	"Only file events inside of the folder, e.g. /docs"
+//	@Param			access_token	query		string					false	"Access token, if the Authorization header can't be set"
+//	@Param			Authorization	header		string					false	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	events.EventResponse	"Stream of events"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Router			/events [get]
+func (e *Events) StreamHandler(ctx *fiber.Ctx) error {
+	const op = "StreamHandler"
+
+	filter, err := e.filter(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	subscription := e.eventBus.Subscribe(controller.RequestedUserId(ctx), filter)
+	reqCtx := ctx.UserContext()
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	// proxies must pass events as they are written
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer subscription.Close()
+
+		heartbeat := time.NewTicker(e.heartbeat)
+		defer heartbeat.Stop()
+
+		_, _ = fmt.Fprintf(w, "retry: %d\n\n", retryMillis)
+
+		for {
+			// the gone client is found out by the failed write
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case event, ok := <-subscription.Events():
+				if !ok {
+					return
+				}
+
+				data, err := json.Marshal(response(event))
+				if err != nil {
+					logger.AddContext(reqCtx, e.pkg, op, err)
+
+					return
+				}
+
+				_, _ = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data)
+			case <-heartbeat.C:
+				_, _ = w.WriteString(": heartbeat\n\n")
+			}
+		}
+	})
+
+	return nil
+}
+
+// UpgradeHandler godoc
+//
+//	@Summary		WebSocket of events
+//	@Description	The same events as /events, each one is a text message with EventResponse.
+//	@Description	Pings are sent as the heartbeat, messages of the client are ignored.
+//	@Description	Browsers pass the token in the access_token query parameter
+//	@Tags			events
+//	@Param			types			query		string					false	"Comma-separated types of events, all by default"
+//	@Param			folder			query		string					false	"Only file events inside of the folder, e.g. /docs"
+//	@Param			access_token	query		string					false	"Access token, if the Authorization header can't be set"
+//	@Param			Authorization	header		string					false	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		101				{object}	events.EventResponse	"Switching protocols"
+//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		426				{object}	entity.ErrorResponse	"Not a WebSocket request"
+//	@Router			/events/ws [get]
+func (e *Events) UpgradeHandler(ctx *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(ctx) {
+		return ctx.Status(fiber.StatusUpgradeRequired).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	filter, err := e.filter(ctx)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	// locals are passed to the connection
+	ctx.Locals("events_filter", filter)
+
+	return ctx.Next()
+}
+
+// SocketHandler streams events to the WebSocket upgraded by UpgradeHandler
+func (e *Events) SocketHandler(conn *websocket.Conn) {
+	userId, _ := conn.Locals("user_id").(int64)
+	filter, _ := conn.Locals("events_filter").(eventservice.Filter)
+
+	subscription := e.eventBus.Subscribe(userId, filter)
+	defer subscription.Close()
+
+	// reading handles pings and the close of the client, the gone client closes the subscription
+	go func() {
+		defer subscription.Close()
+
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(e.heartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				_ = conn.WriteControl(
+					websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ""),
+					time.Now().Add(writeTimeout),
+				)
+
+				return
+			}
+
+			_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := conn.WriteJSON(response(event)); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// filter returns the filter of the requested types and folder. The token restricted to the folder
+// gets events inside of it only
+func (e *Events) filter(ctx *fiber.Ctx) (eventservice.Filter, error) {
+	var filter eventservice.Filter
+
+	if types := ctx.Query("types"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if !slices.Contains(eventservice.Types, t) {
+				return filter, fmt.Errorf("unknown type of events: %q", t)
+			}
+
+			filter.Types = append(filter.Types, t)
+		}
+	}
+
+	if folder := ctx.Query("folder"); folder != "" {
+		if !strings.HasPrefix(folder, "/") {
+			return filter, fmt.Errorf("folder must be absolute: %q", folder)
+		}
+
+		filter.Folder = strings.TrimSuffix(path.Clean(folder), "/")
+	}
+
+	if t, ok := controller.RequestedAccessToken(ctx); ok && t.Folder != "" {
+		if filter.Folder == "" {
+			filter.Folder = t.Folder
+		}
+
+		if filter.Folder != t.Folder && !strings.HasPrefix(filter.Folder, t.Folder+"/") {
+			return filter, fmt.Errorf("folder %q is outside of the token folder", filter.Folder)
+		}
+	}
+
+	return filter, nil
+}
+
+func response(event eventservice.Event) events.EventResponse {
+	return events.EventResponse{
+		Id:        event.Id,
+		Type:      event.Type,
+		Path:      event.Path,
+		FromPath:  event.FromPath,
+		Cursor:    event.Cursor,
+		Data:      event.Data,
+		CreatedAt: event.CreatedAt.Format(time.DateTime),
+	}
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/export"
+	exportservice "github.com/albakov/go-cloud-file-storage/internal/service/export"
+	exportstorage "github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	"github.com/gofiber/fiber/v2"
+)
+
+// ExportResponse returns the export, the download path of the ready export becomes the absolute link
+func ExportResponse(ctx *fiber.Ctx, e exportstorage.Export, downloadPath string) export.Response {
+	data := export.Response{
+		Id:         e.Id,
+		JobId:      e.JobId,
+		Format:     e.Format,
+		Paths:      exportservice.Paths(e),
+		Status:     e.Status,
+		Size:       e.Size,
+		CreatedAt:  e.CreatedAt,
+		FinishedAt: e.FinishedAt.String,
+		ExpiresAt:  e.ExpiresAt,
+	}
+
+	if downloadPath != "" {
+		data.DownloadURL = ctx.BaseURL() + downloadPath
+	}
+
+	return data
+}
@@ -0,0 +1,315 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/export"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	exportservice "github.com/albakov/go-cloud-file-storage/internal/service/export"
+	exportstorage "github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	"github.com/gofiber/fiber/v2"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+type Export struct {
+	pkg           string
+	exportService ExportService
+	metrics       Metrics
+}
+
+type ExportService interface {
+	Export(ctx context.Context, userId, id int64) (exportstorage.Export, error)
+	Exports(ctx context.Context, userId int64) ([]exportstorage.Export, error)
+	Delete(ctx context.Context, userId, id int64) error
+	DownloadPath(e exportstorage.Export) (string, error)
+	Open(
+		ctx context.Context,
+		id, expires int64,
+		signature string,
+	) (exportstorage.Export, io.ReadCloser, minio.ObjectInfo, error)
+}
+
+type Metrics interface {
+	AddDownloadedBytes(n int64)
+}
+
+func New(exportService ExportService, metrics Metrics) *Export {
+	return &Export{
+		pkg:           "export",
+		exportService: exportService,
+		metrics:       metrics,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		List exports
+//	@Description	List archives exported by the current user, newest first. Statuses: pending, ready.
+//	@Description	Exports are removed when they expire, the pending export whose job has failed too.
+//	@Description	The access token restricted to the folder sees only exports of paths inside of it
+//	@Tags			exports
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	[]export.Response		"List of exports"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/exports [get]
+func (ex *Export) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	exports, err := ex.exportService.Exports(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := make([]export.Response, 0, len(exports))
+	for _, e := range exports {
+		if !isAvailable(ctx, e) {
+			continue
+		}
+
+		r, err := ex.response(ctx, e)
+		if err != nil {
+			logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+			return serverError(ctx)
+		}
+
+		data = append(data, r)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// ShowHandler godoc
+//
+//	@Summary		Show export
+//	@Description	Show the export. The ready export has the download link, it works without authorization
+//	@Description	until the export expires. The progress of the pending export is the one of its job
+//	@Tags			exports
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Export id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	export.Response			"Export"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/exports/{id} [get]
+func (ex *Export) ShowHandler(ctx *fiber.Ctx) error {
+	const op = "ShowHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	e, err := ex.exportService.Export(ctx.UserContext(), controller.RequestedUserId(ctx), id)
+	if err != nil {
+		if errors.Is(err, exportservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	if !isAvailable(ctx, e) {
+		return notFound(ctx)
+	}
+
+	data, err := ex.response(ctx, e)
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Delete export
+//	@Description	Delete the export with its archive, the job writing the archive is cancelled.
+//	@Description	Download links of the export stop working
+//	@Tags			exports
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Export id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/exports/{id} [delete]
+func (ex *Export) DeleteHandler(ctx *fiber.Ctx) error {
+	const op = "DeleteHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	userId := controller.RequestedUserId(ctx)
+
+	e, err := ex.exportService.Export(ctx.UserContext(), userId, id)
+	if err != nil {
+		if errors.Is(err, exportservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	if !isAvailable(ctx, e) {
+		return notFound(ctx)
+	}
+
+	if err := ex.exportService.Delete(ctx.UserContext(), userId, id); err != nil {
+		if errors.Is(err, exportservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// DownloadHandler godoc
+//
+//	@Summary		Download export
+//	@Description	Download the archive of the export by the link from the export, no authorization is needed.
+//	@Description	The link is signed and expires with the export
+//	@Tags			exports
+//	@Produce		application/octet-stream
+//	@Param			id			path		int						true	"Export id"
+//	@Param			expires		query		int						true	"Expiration of the link, unix time"
+//	@Param			signature	query		string					true	"Signature of the link"
+//	@Success		200			{string}	binary					"Archive in zip or tar.gz format"
+//	@Failure		403			{object}	entity.ErrorResponse	"Download link invalid or expired"
+//	@Failure		404			{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500			{object}	entity.ErrorResponse	"Server error"
+//	@Router			/exports/{id}/download [get]
+func (ex *Export) DownloadHandler(ctx *fiber.Ctx) error {
+	const op = "DownloadHandler"
+
+	id, ok := requestedId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	expires, err := strconv.ParseInt(ctx.Query("expires"), 10, 64)
+	if err != nil {
+		return linkInvalid(ctx)
+	}
+
+	e, reader, info, err := ex.exportService.Open(ctx.UserContext(), id, expires, ctx.Query("signature"))
+	if err != nil {
+		if errors.Is(err, exportservice.ErrLinkInvalid) || errors.Is(err, exportservice.ErrLinkExpired) {
+			return linkInvalid(ctx)
+		}
+
+		if errors.Is(err, exportservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), ex.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ex.metrics.AddDownloadedBytes(info.Size)
+
+	ctx.Status(fiber.StatusOK)
+	ctx.Set(fiber.HeaderContentType, info.ContentType)
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="export-%d.%s"`, e.Id, e.Format))
+
+	return ctx.SendStream(reader, int(info.Size))
+}
+
+// response returns the export with the download link if it's ready
+func (ex *Export) response(ctx *fiber.Ctx, e exportstorage.Export) (export.Response, error) {
+	if e.Status != exportstorage.StatusReady {
+		return controller.ExportResponse(ctx, e, ""), nil
+	}
+
+	downloadPath, err := ex.exportService.DownloadPath(e)
+	if err != nil {
+		return export.Response{}, err
+	}
+
+	return controller.ExportResponse(ctx, e, downloadPath), nil
+}
+
+// isAvailable checks that the access token restricted to the folder has all paths of the export inside of it,
+// other exports are hidden from the token as if they don't exist
+func isAvailable(ctx *fiber.Ctx, e exportstorage.Export) bool {
+	t, ok := controller.RequestedAccessToken(ctx)
+	if !ok || t.Folder == "" {
+		return true
+	}
+
+	paths := exportservice.Paths(e)
+	if len(paths) == 0 {
+		return false
+	}
+
+	for _, p := range paths {
+		p = path.Clean("/" + p)
+		if p != t.Folder && !strings.HasPrefix(p, t.Folder+"/") {
+			return false
+		}
+	}
+
+	return true
+}
+
+func requestedId(ctx *fiber.Ctx) (int64, bool) {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return int64(id), true
+}
+
+func linkInvalid(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageDownloadLinkInvalid})
+}
+
+func notFound(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+}
+
+func serverError(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+}
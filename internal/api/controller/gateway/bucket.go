@@ -0,0 +1,234 @@
+package gateway
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/gateway"
+	gatewayservice "github.com/albakov/go-cloud-file-storage/internal/service/gateway"
+	"github.com/gofiber/fiber/v2"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// listBuckets lists the only bucket of the gateway
+func (g *Gateway) listBuckets(ctx *fiber.Ctx) error {
+	const op = "listBuckets"
+
+	userId := controller.RequestedUserId(ctx)
+
+	us, err := g.userService.UserById(ctx.UserContext(), userId)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &gateway.ListAllMyBucketsResult{
+		Xmlns: gateway.Namespace,
+		Owner: gateway.Owner{ID: strconv.FormatInt(userId, 10), DisplayName: us.Email.String},
+		Buckets: []gateway.Bucket{{
+			Name:         g.gatewayService.Bucket(),
+			CreationDate: createdAt(us.CreatedAt).Format(iso8601),
+		}},
+	})
+}
+
+func (g *Gateway) location(ctx *fiber.Ctx) error {
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &gateway.LocationConstraint{Xmlns: gateway.Namespace, Location: g.region})
+}
+
+func (g *Gateway) versioning(ctx *fiber.Ctx) error {
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &gateway.VersioningConfiguration{Xmlns: gateway.Namespace})
+}
+
+// listObjects serves ListObjectsV2 and ListObjects of V1
+func (g *Gateway) listObjects(ctx *fiber.Ctx) error {
+	const op = "listObjects"
+
+	maxKeys, err := queryInt(ctx, "max-keys", gatewayservice.MaxKeys)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidArgument", "Argument max-keys must be an integer.")
+	}
+
+	v2 := ctx.Query("list-type") == "2"
+	r := gatewayservice.ListRequest{
+		Prefix:    ctx.Query("prefix"),
+		Delimiter: ctx.Query("delimiter"),
+		MaxKeys:   maxKeys,
+	}
+
+	if v2 {
+		r.StartAfter = ctx.Query("start-after")
+		r.ContinuationToken = ctx.Query("continuation-token")
+	} else {
+		r.StartAfter = startAfterMarker(ctx.Query("marker"), r.Prefix, r.Delimiter)
+	}
+
+	var list gatewayservice.ListResult
+	if maxKeys > 0 {
+		list, err = g.gatewayService.ListObjects(ctx.UserContext(), controller.RequestedUserId(ctx), r)
+		if err != nil {
+			return g.serviceError(ctx, op, err)
+		}
+	}
+
+	encodingType := ctx.Query("encoding-type")
+	encode := func(s string) string {
+		if encodingType == "url" {
+			return s3utils.EncodePath(s)
+		}
+
+		return s
+	}
+
+	result := gateway.ListBucketResult{
+		Xmlns:          gateway.Namespace,
+		Name:           g.gatewayService.Bucket(),
+		Prefix:         encode(r.Prefix),
+		Delimiter:      encode(r.Delimiter),
+		MaxKeys:        maxKeys,
+		EncodingType:   encodingType,
+		IsTruncated:    list.IsTruncated,
+		Contents:       make([]gateway.Object, 0, len(list.Objects)),
+		CommonPrefixes: make([]gateway.CommonPrefix, 0, len(list.CommonPrefixes)),
+	}
+
+	last := ""
+	for _, o := range list.Objects {
+		result.Contents = append(result.Contents, gateway.Object{
+			Key:          encode(o.Key),
+			LastModified: o.LastModified.UTC().Format(iso8601),
+			ETag:         quoted(o.ETag),
+			Size:         o.Size,
+			StorageClass: "STANDARD",
+		})
+		last = max(last, o.Key)
+	}
+
+	for _, p := range list.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, gateway.CommonPrefix{Prefix: encode(p)})
+		last = max(last, p)
+	}
+
+	if v2 {
+		keyCount := len(list.Objects) + len(list.CommonPrefixes)
+		result.KeyCount = &keyCount
+		result.StartAfter = encode(r.StartAfter)
+		result.ContinuationToken = r.ContinuationToken
+		result.NextContinuationToken = list.NextContinuationToken
+	} else {
+		marker := encode(ctx.Query("marker"))
+		result.Marker = &marker
+
+		// without the delimiter clients continue after the last key themselves
+		if list.IsTruncated && r.Delimiter != "" {
+			result.NextMarker = encode(last)
+		}
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &result)
+}
+
+func (g *Gateway) listMultipartUploads(ctx *fiber.Ctx) error {
+	const op = "listMultipartUploads"
+
+	maxUploads, err := queryInt(ctx, "max-uploads", gatewayservice.MaxKeys)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidArgument", "Argument max-uploads must be an integer.")
+	}
+
+	list, err := g.gatewayService.ListMultipartUploads(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		ctx.Query("prefix"),
+		ctx.Query("key-marker"),
+		ctx.Query("upload-id-marker"),
+		ctx.Query("delimiter"),
+		maxUploads,
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	result := gateway.ListMultipartUploadsResult{
+		Xmlns:              gateway.Namespace,
+		Bucket:             g.gatewayService.Bucket(),
+		KeyMarker:          ctx.Query("key-marker"),
+		UploadIdMarker:     ctx.Query("upload-id-marker"),
+		NextKeyMarker:      list.NextKeyMarker,
+		NextUploadIdMarker: list.NextUploadIdMarker,
+		Prefix:             ctx.Query("prefix"),
+		Delimiter:          ctx.Query("delimiter"),
+		MaxUploads:         maxUploads,
+		IsTruncated:        list.IsTruncated,
+		Uploads:            make([]gateway.Upload, 0, len(list.Uploads)),
+		CommonPrefixes:     make([]gateway.CommonPrefix, 0, len(list.CommonPrefixes)),
+	}
+
+	for _, u := range list.Uploads {
+		result.Uploads = append(result.Uploads, gateway.Upload{
+			Key:          u.Key,
+			UploadId:     u.UploadId,
+			Initiated:    u.Initiated.UTC().Format(iso8601),
+			StorageClass: "STANDARD",
+		})
+	}
+
+	for _, p := range list.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, gateway.CommonPrefix{Prefix: p})
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &result)
+}
+
+// startAfterMarker returns the key to list V1 after. The marker within a common prefix, e.g. NextMarker "dir/",
+// skips the whole prefix, otherwise the next page would start with the keys rolled up into it
+func startAfterMarker(marker, prefix, delimiter string) string {
+	rest, ok := strings.CutPrefix(marker, prefix)
+	if !ok || delimiter == "" {
+		return marker
+	}
+
+	i := strings.Index(rest, delimiter)
+	if i < 0 {
+		return marker
+	}
+
+	return prefix + rest[:i+len(delimiter)] + string(utf8.MaxRune)
+}
+
+// queryInt returns the integer query parameter, the default if it's missing
+func queryInt(ctx *fiber.Ctx, name string, def int) (int, error) {
+	v := ctx.Query(name)
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, strconv.ErrSyntax
+	}
+
+	return n, nil
+}
+
+// createdAt parses the creation date of the user, the layout depends on the database
+func createdAt(value string) time.Time {
+	for _, layout := range []string{time.DateTime, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+
+	return time.Unix(0, 0)
+}
@@ -0,0 +1,505 @@
+package gateway
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	gatewayservice "github.com/albakov/go-cloud-file-storage/internal/service/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	s3keyservice "github.com/albakov/go-cloud-file-storage/internal/service/s3key"
+	"github.com/albakov/go-cloud-file-storage/internal/service/sigv4"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// iso8601 is the format of dates in S3 responses
+const iso8601 = "2006-01-02T15:04:05.000Z"
+
+// unsupportedSubresources are the query parameters of S3 requests the gateway doesn't implement
+var unsupportedSubresources = []string{
+	"accelerate", "acl", "analytics", "attributes", "cors", "encryption", "intelligent-tiering", "inventory",
+	"legal-hold", "lifecycle", "logging", "metrics", "notification", "object-lock", "ownershipControls",
+	"policy", "policyStatus", "publicAccessBlock", "replication", "requestPayment", "restore", "retention",
+	"select", "tagging", "torrent", "versions", "website",
+}
+
+type Gateway struct {
+	pkg                  string
+	region               string
+	requireVerifiedEmail bool
+	gatewayService       GatewayService
+	s3KeyService         S3KeyService
+	userService          UserService
+	quotaService         QuotaService
+	auditService         AuditService
+	metrics              Metrics
+}
+
+type GatewayService interface {
+	Bucket() string
+	CheckBucket(bucket string) error
+	ListObjects(ctx context.Context, userId int64, r gatewayservice.ListRequest) (gatewayservice.ListResult, error)
+	GetObject(
+		ctx context.Context,
+		userId int64,
+		key string,
+		opts minio.GetObjectOptions,
+	) (io.ReadCloser, minio.ObjectInfo, http.Header, error)
+	HeadObject(ctx context.Context, userId int64, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error)
+	PutObject(
+		ctx context.Context,
+		userId int64,
+		key string,
+		reader io.Reader,
+		size int64,
+		opts minio.PutObjectOptions,
+	) (minio.UploadInfo, error)
+	CopyObject(ctx context.Context, userId int64, to, from string, metadata map[string]string) (minio.ObjectInfo, error)
+	DeleteObject(ctx context.Context, userId int64, key string) error
+	CreateMultipartUpload(ctx context.Context, userId int64, key string, opts minio.PutObjectOptions) (string, error)
+	UploadPart(
+		ctx context.Context,
+		userId int64,
+		key, uploadId string,
+		partNumber int,
+		reader io.Reader,
+		size int64,
+	) (minio.ObjectPart, error)
+	CompleteMultipartUpload(
+		ctx context.Context,
+		userId int64,
+		key, uploadId string,
+		parts []minio.CompletePart,
+	) (minio.UploadInfo, error)
+	AbortMultipartUpload(ctx context.Context, userId int64, key, uploadId string) error
+	ListParts(
+		ctx context.Context,
+		userId int64,
+		key, uploadId string,
+		partNumberMarker, maxParts int,
+	) (minio.ListObjectPartsResult, error)
+	UploadSize(ctx context.Context, userId int64, key, uploadId string) (int64, error)
+	ListMultipartUploads(
+		ctx context.Context,
+		userId int64,
+		prefix, keyMarker, uploadIdMarker, delimiter string,
+		maxUploads int,
+	) (gatewayservice.UploadsResult, error)
+}
+
+type S3KeyService interface {
+	Key(accessKeyId string) (s3keyservice.Key, error)
+}
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+type QuotaService interface {
+	Check(ctx context.Context, userId, incoming int64) error
+}
+
+type AuditService interface {
+	Record(event audit.Event)
+}
+
+type Metrics interface {
+	AddUploadedBytes(n int64)
+	AddDownloadedBytes(n int64)
+}
+
+func New(
+	conf *config.Config,
+	gatewayService GatewayService,
+	s3KeyService S3KeyService,
+	userService UserService,
+	quotaService QuotaService,
+	auditService AuditService,
+	metrics Metrics,
+) *Gateway {
+	return &Gateway{
+		pkg:                  "gateway",
+		region:               conf.S3GatewayRegion,
+		requireVerifiedEmail: conf.UploadRequireVerifiedEmail,
+		gatewayService:       gatewayService,
+		s3KeyService:         s3KeyService,
+		userService:          userService,
+		quotaService:         quotaService,
+		auditService:         auditService,
+		metrics:              metrics,
+	}
+}
+
+// Authenticated verifies Signature V4 of the request with the secret of the access key. Signed payloads
+// are checked too, the body of the request is available with body afterward
+func (g *Gateway) Authenticated(ctx *fiber.Ctx) error {
+	const op = "Authenticated"
+
+	if requestId, ok := ctx.Locals("request_id").(string); ok {
+		ctx.Set("X-Amz-Request-Id", requestId)
+	}
+
+	r, err := adaptor.ConvertRequest(ctx, true)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidRequest", "The request is invalid.")
+	}
+
+	signature, err := sigv4.Parse(r)
+	if err != nil {
+		return g.signatureError(ctx, err)
+	}
+
+	key, err := g.s3KeyService.Key(signature.AccessKeyId)
+	if err != nil {
+		if errors.Is(err, s3keyservice.ErrInvalid) {
+			return g.error(
+				ctx,
+				fiber.StatusForbidden,
+				"InvalidAccessKeyId",
+				"The AWS Access Key Id you provided does not exist in our records.",
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), g.pkg, op, err)
+
+		return g.internalError(ctx)
+	}
+
+	err = signature.Verify(r, key.SecretAccessKey, g.region, time.Now())
+	if err != nil {
+		return g.signatureError(ctx, err)
+	}
+
+	// the raw body, Fiber would decompress bodies of objects uploaded with Content-Encoding: gzip
+	body, err := signature.Payload(ctx.Request().Body(), key.SecretAccessKey)
+	if err != nil {
+		return g.signatureError(ctx, err)
+	}
+
+	ctx.Locals("s3_body", body)
+	ctx.Locals("user_id", key.UserId)
+	ctx.SetUserContext(logger.With(ctx.UserContext(), "user_id", key.UserId))
+
+	return ctx.Next()
+}
+
+// Handler serves S3 requests of the authenticated user. Buckets are addressed in the path,
+// the only bucket is the one of the gateway with the files of the user
+func (g *Gateway) Handler(ctx *fiber.Ctx) error {
+	bucket, key, err := requestedObject(ctx)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidURI", "Couldn't parse the specified URI.")
+	}
+
+	if bucket == "" {
+		if ctx.Method() != fiber.MethodGet {
+			return g.methodNotAllowed(ctx)
+		}
+
+		return g.listBuckets(ctx)
+	}
+
+	if err := g.gatewayService.CheckBucket(bucket); err != nil {
+		return g.serviceError(ctx, "Handler", err)
+	}
+
+	for _, name := range unsupportedSubresources {
+		if ctx.Request().URI().QueryArgs().Has(name) {
+			return g.error(
+				ctx,
+				fiber.StatusNotImplemented,
+				"NotImplemented",
+				"A header or query you provided implies functionality that is not implemented.",
+			)
+		}
+	}
+
+	if g.requireVerifiedEmail && writes(ctx) {
+		verified, err := g.emailVerified(ctx)
+		if err != nil {
+			return g.serviceError(ctx, "Handler", err)
+		}
+
+		if !verified {
+			return g.error(ctx, fiber.StatusForbidden, "AccessDenied", controller.MessageEmailNotVerified)
+		}
+	}
+
+	if key == "" {
+		return g.bucket(ctx)
+	}
+
+	return g.object(ctx, key)
+}
+
+// bucket serves the requests to the bucket
+func (g *Gateway) bucket(ctx *fiber.Ctx) error {
+	query := ctx.Request().URI().QueryArgs()
+
+	switch ctx.Method() {
+	case fiber.MethodGet:
+		switch {
+		case query.Has("location"):
+			return g.location(ctx)
+		case query.Has("versioning"):
+			return g.versioning(ctx)
+		case query.Has("uploads"):
+			return g.listMultipartUploads(ctx)
+		}
+
+		return g.listObjects(ctx)
+	case fiber.MethodHead:
+		ctx.Status(fiber.StatusOK)
+
+		return nil
+	case fiber.MethodPut:
+		return g.error(
+			ctx,
+			fiber.StatusConflict,
+			"BucketAlreadyOwnedByYou",
+			"Your previous request to create the named bucket succeeded and you already own it.",
+		)
+	case fiber.MethodPost:
+		if query.Has("delete") {
+			return g.deleteObjects(ctx)
+		}
+	case fiber.MethodDelete:
+		return g.error(ctx, fiber.StatusForbidden, "AccessDenied", "The bucket of the gateway can't be deleted.")
+	}
+
+	return g.methodNotAllowed(ctx)
+}
+
+// object serves the requests to the object, file requests are recorded in the audit log
+func (g *Gateway) object(ctx *fiber.Ctx, key string) error {
+	query := ctx.Request().URI().QueryArgs()
+
+	switch ctx.Method() {
+	case fiber.MethodGet:
+		if query.Has("uploadId") {
+			return g.listParts(ctx, key)
+		}
+
+		return g.audited(ctx, audit.ActionResourceDownload, key, g.getObject)
+	case fiber.MethodHead:
+		return g.headObject(ctx, key)
+	case fiber.MethodPut:
+		switch {
+		case query.Has("uploadId"):
+			return g.uploadPart(ctx, key)
+		case ctx.Get("X-Amz-Copy-Source") != "":
+			// copies are recorded as created at the destination
+			return g.audited(ctx, audit.ActionResourceCreate, key, g.copyObject)
+		}
+
+		return g.audited(ctx, audit.ActionResourceCreate, key, g.putObject)
+	case fiber.MethodPost:
+		switch {
+		case query.Has("uploads"):
+			return g.createMultipartUpload(ctx, key)
+		case query.Has("uploadId"):
+			return g.audited(ctx, audit.ActionResourceCreate, key, g.completeMultipartUpload)
+		}
+	case fiber.MethodDelete:
+		if query.Has("uploadId") {
+			return g.abortMultipartUpload(ctx, key)
+		}
+
+		return g.audited(ctx, audit.ActionResourceDelete, key, g.deleteObject)
+	}
+
+	return g.methodNotAllowed(ctx)
+}
+
+// audited records the result of the handler of the object in the audit log, missing objects aren't recorded
+func (g *Gateway) audited(ctx *fiber.Ctx, action, key string, handler func(ctx *fiber.Ctx, key string) error) error {
+	if err := handler(ctx, key); err != nil {
+		return err
+	}
+
+	status := ctx.Response().StatusCode()
+	switch {
+	case status >= 200 && status < 300:
+		g.record(ctx, action, audit.ResultSuccess, key)
+	case status >= 400 && status != fiber.StatusNotFound:
+		g.record(ctx, action, audit.ResultFailure, key)
+	}
+
+	return nil
+}
+
+func (g *Gateway) record(ctx *fiber.Ctx, action, result, key string) {
+	event := controller.AuditEvent(ctx, action, result)
+	event.Path = "/" + key
+
+	g.auditService.Record(event)
+}
+
+func (g *Gateway) emailVerified(ctx *fiber.Ctx) (bool, error) {
+	us, err := g.userService.UserById(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		return false, err
+	}
+
+	return us.EmailVerifiedAt.Valid, nil
+}
+
+// checkQuota writes the error response and returns false if the user can't store incoming bytes more
+func (g *Gateway) checkQuota(ctx *fiber.Ctx, incoming int64) (bool, error) {
+	const op = "checkQuota"
+
+	err := g.quotaService.Check(ctx.UserContext(), controller.RequestedUserId(ctx), incoming)
+	if err != nil {
+		if errors.Is(err, quota.ErrExceeded) {
+			return false, g.error(ctx, fiber.StatusInsufficientStorage, "QuotaExceeded", controller.MessageQuotaExceeded)
+		}
+
+		logger.AddContext(ctx.UserContext(), g.pkg, op, err)
+
+		return false, g.internalError(ctx)
+	}
+
+	return true, nil
+}
+
+// serviceError writes the error of the gateway service, errors S3 responded with are returned to the client as is
+func (g *Gateway) serviceError(ctx *fiber.Ctx, op string, err error) error {
+	var requestError *s3.RequestError
+
+	switch {
+	case errors.As(err, &requestError):
+		return g.error(ctx, requestError.StatusCode, requestError.Code, requestError.Message)
+	case errors.Is(err, gatewayservice.ErrNoSuchBucket):
+		return g.error(ctx, fiber.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist.")
+	case errors.Is(err, gatewayservice.ErrInvalidKey):
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidArgument", "The specified key is not supported.")
+	}
+
+	logger.AddContext(ctx.UserContext(), g.pkg, op, err)
+
+	return g.internalError(ctx)
+}
+
+func (g *Gateway) signatureError(ctx *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, sigv4.ErrMissing):
+		return g.error(ctx, fiber.StatusForbidden, "AccessDenied", "Anonymous requests are not allowed.")
+	case errors.Is(err, sigv4.ErrMalformed):
+		return g.error(ctx, fiber.StatusBadRequest, "AuthorizationHeaderMalformed", err.Error())
+	case errors.Is(err, sigv4.ErrUnsupported):
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidRequest", err.Error())
+	case errors.Is(err, sigv4.ErrTimeSkewed):
+		return g.error(
+			ctx,
+			fiber.StatusForbidden,
+			"RequestTimeTooSkewed",
+			"The difference between the request time and the server's time is too large.",
+		)
+	case errors.Is(err, sigv4.ErrExpired):
+		return g.error(ctx, fiber.StatusForbidden, "AccessDenied", "Request has expired.")
+	case errors.Is(err, sigv4.ErrContentMismatch):
+		return g.error(
+			ctx,
+			fiber.StatusBadRequest,
+			"XAmzContentSHA256Mismatch",
+			"The provided 'x-amz-content-sha256' header does not match what was computed.",
+		)
+	}
+
+	return g.error(
+		ctx,
+		fiber.StatusForbidden,
+		"SignatureDoesNotMatch",
+		"The request signature we calculated does not match the signature you provided.",
+	)
+}
+
+func (g *Gateway) methodNotAllowed(ctx *fiber.Ctx) error {
+	return g.error(
+		ctx,
+		fiber.StatusMethodNotAllowed,
+		"MethodNotAllowed",
+		"The specified method is not allowed against this resource.",
+	)
+}
+
+func (g *Gateway) internalError(ctx *fiber.Ctx) error {
+	return g.error(ctx, fiber.StatusInternalServerError, "InternalError", controller.MessageServerError)
+}
+
+// error writes the S3 error, responses to HEAD requests and of unmet conditions have no body
+func (g *Gateway) error(ctx *fiber.Ctx, status int, code, message string) error {
+	ctx.Status(status)
+
+	if ctx.Method() == fiber.MethodHead || status == fiber.StatusNotModified {
+		return nil
+	}
+
+	requestId, _ := ctx.Locals("request_id").(string)
+
+	return g.xml(ctx, &gateway.Error{
+		Code:      code,
+		Message:   message,
+		Resource:  string(ctx.Request().URI().PathOriginal()),
+		RequestId: requestId,
+	})
+}
+
+func (g *Gateway) xml(ctx *fiber.Ctx, v any) error {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationXML)
+
+	return ctx.Send(append([]byte(xml.Header), data...))
+}
+
+// body returns the payload of the request, decoded if it was sent in signed chunks
+func body(ctx *fiber.Ctx) []byte {
+	return ctx.Locals("s3_body").([]byte)
+}
+
+// requestedObject returns the bucket and the key of the path-style request
+func requestedObject(ctx *fiber.Ctx) (string, string, error) {
+	p, err := url.PathUnescape(string(ctx.Request().URI().PathOriginal()))
+	if err != nil {
+		return "", "", err
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(p, "/"), "/")
+
+	return bucket, key, nil
+}
+
+// writes reports whether the request stores data
+func writes(ctx *fiber.Ctx) bool {
+	switch ctx.Method() {
+	case fiber.MethodPut:
+		return true
+	case fiber.MethodPost:
+		query := ctx.Request().URI().QueryArgs()
+
+		return query.Has("uploads") || query.Has("uploadId")
+	}
+
+	return false
+}
+
+func quoted(etag string) string {
+	return `"` + strings.Trim(etag, `"`) + `"`
+}
@@ -0,0 +1,206 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/gateway"
+	gatewayservice "github.com/albakov/go-cloud-file-storage/internal/service/gateway"
+	"github.com/gofiber/fiber/v2"
+	"github.com/minio/minio-go/v7"
+	"strconv"
+)
+
+// maxPartNumber is the most parts of the multipart upload in S3
+const maxPartNumber = 10000
+
+func (g *Gateway) createMultipartUpload(ctx *fiber.Ctx, key string) error {
+	const op = "createMultipartUpload"
+
+	uploadId, err := g.gatewayService.CreateMultipartUpload(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		key,
+		putOptions(ctx),
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &gateway.InitiateMultipartUploadResult{
+		Xmlns:    gateway.Namespace,
+		Bucket:   g.gatewayService.Bucket(),
+		Key:      key,
+		UploadId: uploadId,
+	})
+}
+
+// uploadPart uploads the part, the whole upload is checked against the quota when it's completed
+func (g *Gateway) uploadPart(ctx *fiber.Ctx, key string) error {
+	const op = "uploadPart"
+
+	if ctx.Get("X-Amz-Copy-Source") != "" {
+		return g.error(
+			ctx,
+			fiber.StatusNotImplemented,
+			"NotImplemented",
+			"A header or query you provided implies functionality that is not implemented.",
+		)
+	}
+
+	n, err := strconv.Atoi(ctx.Query("partNumber"))
+	if err != nil || n < 1 || n > maxPartNumber {
+		return g.error(
+			ctx,
+			fiber.StatusBadRequest,
+			"InvalidArgument",
+			"Part number must be an integer between 1 and 10000, inclusive.",
+		)
+	}
+
+	data := body(ctx)
+	if !g.checkMD5(ctx, data) {
+		return nil
+	}
+
+	if ok, err := g.checkQuota(ctx, int64(len(data))); !ok {
+		return err
+	}
+
+	part, err := g.gatewayService.UploadPart(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		key,
+		ctx.Query("uploadId"),
+		n,
+		bytes.NewReader(data),
+		int64(len(data)),
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	g.metrics.AddUploadedBytes(int64(len(data)))
+
+	ctx.Set(fiber.HeaderETag, quoted(part.ETag))
+	ctx.Status(fiber.StatusOK)
+
+	return nil
+}
+
+func (g *Gateway) completeMultipartUpload(ctx *fiber.Ctx, key string) error {
+	const op = "completeMultipartUpload"
+
+	userId := controller.RequestedUserId(ctx)
+	uploadId := ctx.Query("uploadId")
+
+	var r gateway.CompleteMultipartUpload
+	if err := xml.Unmarshal(body(ctx), &r); err != nil || len(r.Parts) == 0 {
+		return g.error(
+			ctx,
+			fiber.StatusBadRequest,
+			"MalformedXML",
+			"The XML you provided was not well-formed or did not validate against our published schema.",
+		)
+	}
+
+	size, err := g.gatewayService.UploadSize(ctx.UserContext(), userId, key, uploadId)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	if ok, err := g.checkQuota(ctx, size); !ok {
+		return err
+	}
+
+	parts := make([]minio.CompletePart, 0, len(r.Parts))
+	for _, p := range r.Parts {
+		parts = append(parts, minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+
+	info, err := g.gatewayService.CompleteMultipartUpload(ctx.UserContext(), userId, key, uploadId, parts)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &gateway.CompleteMultipartUploadResult{
+		Xmlns:    gateway.Namespace,
+		Location: ctx.BaseURL() + "/" + g.gatewayService.Bucket() + "/" + key,
+		Bucket:   g.gatewayService.Bucket(),
+		Key:      key,
+		ETag:     quoted(info.ETag),
+	})
+}
+
+func (g *Gateway) abortMultipartUpload(ctx *fiber.Ctx, key string) error {
+	const op = "abortMultipartUpload"
+
+	err := g.gatewayService.AbortMultipartUpload(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		key,
+		ctx.Query("uploadId"),
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+func (g *Gateway) listParts(ctx *fiber.Ctx, key string) error {
+	const op = "listParts"
+
+	marker, err := queryInt(ctx, "part-number-marker", 0)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidArgument", "Argument part-number-marker must be an integer.")
+	}
+
+	maxParts, err := queryInt(ctx, "max-parts", gatewayservice.MaxKeys)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidArgument", "Argument max-parts must be an integer.")
+	}
+
+	list, err := g.gatewayService.ListParts(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		key,
+		ctx.Query("uploadId"),
+		marker,
+		maxParts,
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	result := gateway.ListPartsResult{
+		Xmlns:                gateway.Namespace,
+		Bucket:               list.Bucket,
+		Key:                  list.Key,
+		UploadId:             list.UploadID,
+		PartNumberMarker:     marker,
+		NextPartNumberMarker: list.NextPartNumberMarker,
+		MaxParts:             maxParts,
+		IsTruncated:          list.IsTruncated,
+		Parts:                make([]gateway.Part, 0, len(list.ObjectParts)),
+	}
+
+	for _, p := range list.ObjectParts {
+		result.Parts = append(result.Parts, gateway.Part{
+			PartNumber:   p.PartNumber,
+			LastModified: p.LastModified.UTC().Format(iso8601),
+			ETag:         quoted(p.ETag),
+			Size:         p.Size,
+		})
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &result)
+}
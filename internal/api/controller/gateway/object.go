@@ -0,0 +1,401 @@
+package gateway
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	gatewayservice "github.com/albakov/go-cloud-file-storage/internal/service/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"github.com/gofiber/fiber/v2"
+	"github.com/minio/minio-go/v7"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const metaPrefix = "X-Amz-Meta-"
+
+// conditionalHeaders of GET and HEAD are passed to S3
+var conditionalHeaders = []string{
+	fiber.HeaderRange,
+	fiber.HeaderIfMatch,
+	fiber.HeaderIfNoneMatch,
+	fiber.HeaderIfModifiedSince,
+	fiber.HeaderIfUnmodifiedSince,
+}
+
+// objectHeaders are the headers of the object returned with its content, along with the user metadata
+var objectHeaders = []string{
+	fiber.HeaderContentType,
+	fiber.HeaderContentRange,
+	fiber.HeaderETag,
+	fiber.HeaderLastModified,
+	fiber.HeaderAcceptRanges,
+	fiber.HeaderCacheControl,
+	fiber.HeaderContentDisposition,
+	fiber.HeaderContentEncoding,
+	fiber.HeaderContentLanguage,
+	fiber.HeaderExpires,
+	"X-Amz-Mp-Parts-Count",
+}
+
+// responseParams override the headers of the response to GET, e.g. in presigned URLs
+var responseParams = []string{
+	"response-cache-control",
+	"response-content-disposition",
+	"response-content-encoding",
+	"response-content-language",
+	"response-content-type",
+	"response-expires",
+}
+
+// copyHeaders of CopyObject are passed to S3, with REPLACE directive the metadata of the copy are passed too
+var copyHeaders = []string{
+	"X-Amz-Metadata-Directive",
+	"X-Amz-Copy-Source-If-Match",
+	"X-Amz-Copy-Source-If-None-Match",
+	"X-Amz-Copy-Source-If-Modified-Since",
+	"X-Amz-Copy-Source-If-Unmodified-Since",
+}
+
+func (g *Gateway) getObject(ctx *fiber.Ctx, key string) error {
+	const op = "getObject"
+
+	opts := minio.GetObjectOptions{}
+	setConditions(ctx, &opts)
+
+	for _, param := range responseParams {
+		if v := ctx.Query(param); v != "" {
+			opts.AddReqParam(param, v)
+		}
+	}
+
+	partNumber, err := queryInt(ctx, "partNumber", 0)
+	if err != nil {
+		return g.error(ctx, fiber.StatusBadRequest, "InvalidArgument", "Part number must be an integer.")
+	}
+
+	opts.PartNumber = partNumber
+
+	reader, info, header, err := g.gatewayService.GetObject(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		key,
+		opts,
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	writeObjectHeaders(ctx, header)
+
+	if header.Get(fiber.HeaderContentRange) != "" {
+		ctx.Status(fiber.StatusPartialContent)
+	} else {
+		ctx.Status(fiber.StatusOK)
+	}
+
+	g.metrics.AddDownloadedBytes(info.Size)
+
+	// the stream is closed after the response is sent
+	return ctx.SendStream(reader, int(info.Size))
+}
+
+func (g *Gateway) headObject(ctx *fiber.Ctx, key string) error {
+	const op = "headObject"
+
+	opts := minio.StatObjectOptions{}
+	setConditions(ctx, &opts)
+
+	info, err := g.gatewayService.HeadObject(ctx.UserContext(), controller.RequestedUserId(ctx), key, opts)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	writeObjectHeaders(ctx, info.Metadata)
+	ctx.Set(fiber.HeaderContentType, info.ContentType)
+	ctx.Set(fiber.HeaderETag, quoted(info.ETag))
+	ctx.Set(fiber.HeaderLastModified, info.LastModified.UTC().Format(http.TimeFormat))
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+	ctx.Response().Header.SetContentLength(int(info.Size))
+	ctx.Status(fiber.StatusOK)
+
+	return nil
+}
+
+func (g *Gateway) putObject(ctx *fiber.Ctx, key string) error {
+	const op = "putObject"
+
+	data := body(ctx)
+	if !g.checkMD5(ctx, data) {
+		return nil
+	}
+
+	if ok, err := g.checkQuota(ctx, int64(len(data))); !ok {
+		return err
+	}
+
+	info, err := g.gatewayService.PutObject(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		key,
+		bytes.NewReader(data),
+		int64(len(data)),
+		putOptions(ctx),
+	)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	g.metrics.AddUploadedBytes(int64(len(data)))
+
+	ctx.Set(fiber.HeaderETag, quoted(info.ETag))
+	ctx.Status(fiber.StatusOK)
+
+	return nil
+}
+
+// copyObject copies the object within the bucket, the copy counts towards the quota with the size of the source
+func (g *Gateway) copyObject(ctx *fiber.Ctx, key string) error {
+	const op = "copyObject"
+
+	userId := controller.RequestedUserId(ctx)
+
+	bucket, from, ok := copySource(ctx.Get("X-Amz-Copy-Source"))
+	if !ok {
+		return g.error(
+			ctx,
+			fiber.StatusBadRequest,
+			"InvalidArgument",
+			"Copy Source must mention the source bucket and key: sourcebucket/sourcekey.",
+		)
+	}
+
+	if err := g.gatewayService.CheckBucket(bucket); err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	source, err := g.gatewayService.HeadObject(ctx.UserContext(), userId, from, minio.StatObjectOptions{})
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	if ok, err := g.checkQuota(ctx, source.Size); !ok {
+		return err
+	}
+
+	metadata := make(map[string]string)
+	for _, name := range copyHeaders {
+		if v := ctx.Get(name); v != "" {
+			metadata[name] = v
+		}
+	}
+
+	if strings.EqualFold(ctx.Get("X-Amz-Metadata-Directive"), "REPLACE") {
+		opts := putOptions(ctx)
+
+		metadata[fiber.HeaderContentType] = opts.ContentType
+		for name, v := range opts.UserMetadata {
+			metadata[metaPrefix+name] = v
+		}
+
+		for name, v := range map[string]string{
+			fiber.HeaderCacheControl:       opts.CacheControl,
+			fiber.HeaderContentDisposition: opts.ContentDisposition,
+			fiber.HeaderContentEncoding:    opts.ContentEncoding,
+			fiber.HeaderContentLanguage:    opts.ContentLanguage,
+		} {
+			if v != "" {
+				metadata[name] = v
+			}
+		}
+	}
+
+	info, err := g.gatewayService.CopyObject(ctx.UserContext(), userId, key, from, metadata)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &gateway.CopyObjectResult{
+		Xmlns:        gateway.Namespace,
+		LastModified: info.LastModified.UTC().Format(iso8601),
+		ETag:         quoted(info.ETag),
+	})
+}
+
+func (g *Gateway) deleteObject(ctx *fiber.Ctx, key string) error {
+	const op = "deleteObject"
+
+	err := g.gatewayService.DeleteObject(ctx.UserContext(), controller.RequestedUserId(ctx), key)
+	if err != nil {
+		return g.serviceError(ctx, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// deleteObjects serves DeleteObjects, each key is recorded in the audit log
+func (g *Gateway) deleteObjects(ctx *fiber.Ctx) error {
+	const op = "deleteObjects"
+
+	var r gateway.Delete
+	if err := xml.Unmarshal(body(ctx), &r); err != nil || len(r.Objects) > 1000 {
+		return g.error(
+			ctx,
+			fiber.StatusBadRequest,
+			"MalformedXML",
+			"The XML you provided was not well-formed or did not validate against our published schema.",
+		)
+	}
+
+	result := gateway.DeleteResult{Xmlns: gateway.Namespace}
+
+	for _, o := range r.Objects {
+		err := g.gatewayService.DeleteObject(ctx.UserContext(), controller.RequestedUserId(ctx), o.Key)
+		if err == nil {
+			g.record(ctx, audit.ActionResourceDelete, audit.ResultSuccess, o.Key)
+
+			if !r.Quiet {
+				result.Deleted = append(result.Deleted, gateway.Deleted{Key: o.Key})
+			}
+
+			continue
+		}
+
+		g.record(ctx, audit.ActionResourceDelete, audit.ResultFailure, o.Key)
+
+		deleteError := gateway.DeleteError{Key: o.Key, Code: "InternalError", Message: controller.MessageServerError}
+
+		var requestError *s3.RequestError
+		switch {
+		case errors.As(err, &requestError):
+			deleteError.Code, deleteError.Message = requestError.Code, requestError.Message
+		case errors.Is(err, gatewayservice.ErrInvalidKey):
+			deleteError.Code, deleteError.Message = "InvalidArgument", "The specified key is not supported."
+		default:
+			logger.AddContext(ctx.UserContext(), g.pkg, op, err)
+		}
+
+		result.Errors = append(result.Errors, deleteError)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return g.xml(ctx, &result)
+}
+
+// checkMD5 writes the error response and returns false if Content-MD5 of the request doesn't match the body
+func (g *Gateway) checkMD5(ctx *fiber.Ctx, data []byte) bool {
+	value := ctx.Get("Content-Md5")
+	if value == "" {
+		return true
+	}
+
+	expected, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || len(expected) != md5.Size {
+		_ = g.error(ctx, fiber.StatusBadRequest, "InvalidDigest", "The Content-MD5 you specified is not valid.")
+
+		return false
+	}
+
+	sum := md5.Sum(data)
+	if !bytes.Equal(sum[:], expected) {
+		_ = g.error(
+			ctx,
+			fiber.StatusBadRequest,
+			"BadDigest",
+			"The Content-MD5 you specified did not match what we received.",
+		)
+
+		return false
+	}
+
+	return true
+}
+
+// putOptions returns the options of the stored object with the headers and the user metadata of the request
+func putOptions(ctx *fiber.Ctx) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{
+		ContentType:        ctx.Get(fiber.HeaderContentType),
+		ContentDisposition: ctx.Get(fiber.HeaderContentDisposition),
+		CacheControl:       ctx.Get(fiber.HeaderCacheControl),
+		ContentLanguage:    ctx.Get(fiber.HeaderContentLanguage),
+		ContentEncoding:    contentEncoding(ctx.Get(fiber.HeaderContentEncoding)),
+		UserMetadata:       make(map[string]string),
+	}
+
+	ctx.Request().Header.VisitAll(func(key, value []byte) {
+		name := http.CanonicalHeaderKey(string(key))
+		if strings.HasPrefix(name, metaPrefix) {
+			opts.UserMetadata[strings.TrimPrefix(name, metaPrefix)] = string(value)
+		}
+	})
+
+	return opts
+}
+
+// contentEncoding returns the encoding of the object without aws-chunked of the signed upload
+func contentEncoding(value string) string {
+	encodings := make([]string, 0, 1)
+	for _, e := range strings.Split(value, ",") {
+		e = strings.TrimSpace(e)
+		if e != "" && !strings.EqualFold(e, "aws-chunked") {
+			encodings = append(encodings, e)
+		}
+	}
+
+	return strings.Join(encodings, ",")
+}
+
+func setConditions(ctx *fiber.Ctx, opts *minio.GetObjectOptions) {
+	for _, name := range conditionalHeaders {
+		if v := ctx.Get(name); v != "" {
+			opts.Set(name, v)
+		}
+	}
+}
+
+func writeObjectHeaders(ctx *fiber.Ctx, header http.Header) {
+	for name, values := range header {
+		if len(values) == 0 {
+			continue
+		}
+
+		if strings.HasPrefix(name, metaPrefix) {
+			ctx.Set(name, values[0])
+
+			continue
+		}
+
+		for _, h := range objectHeaders {
+			if strings.EqualFold(name, h) {
+				ctx.Set(h, values[0])
+			}
+		}
+	}
+}
+
+// copySource returns the bucket and the key of X-Amz-Copy-Source: "/bucket/key" or "bucket/key" with
+// the key URL-encoded, the version of the source is ignored
+func copySource(value string) (string, string, bool) {
+	value, _, _ = strings.Cut(value, "?versionId=")
+
+	source, err := url.PathUnescape(value)
+	if err != nil {
+		return "", "", false
+	}
+
+	bucket, key, ok := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+
+	return bucket, key, ok && bucket != "" && key != ""
+}
@@ -0,0 +1,74 @@
+package health
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/health"
+	healthservice "github.com/albakov/go-cloud-file-storage/internal/service/health"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Health struct {
+	healthService HealthService
+}
+
+type HealthService interface {
+	Ready(ctx context.Context) healthservice.Readiness
+	Version() healthservice.BuildInfo
+}
+
+func New(healthService HealthService) *Health {
+	return &Health{
+		healthService: healthService,
+	}
+}
+
+// LiveHandler responds while the process is able to serve requests, dependencies aren't checked,
+// so a failed database doesn't make the orchestrator restart the app
+func (h *Health) LiveHandler(ctx *fiber.Ctx) error {
+	controller.SetCommonHeaders(ctx)
+
+	return ctx.Status(fiber.StatusOK).JSON(&health.StatusResponse{Status: healthservice.StatusUp})
+}
+
+// ReadyHandler responds with 503 if a dependency isn't available or the app is shutting down
+func (h *Health) ReadyHandler(ctx *fiber.Ctx) error {
+	controller.SetCommonHeaders(ctx)
+
+	readiness := h.healthService.Ready(ctx.UserContext())
+
+	data := health.ReadinessResponse{
+		Status: readiness.Status,
+		Checks: make([]health.CheckResponse, 0, len(readiness.Checks)),
+	}
+	for _, c := range readiness.Checks {
+		data.Checks = append(data.Checks, health.CheckResponse{
+			Name:       c.Name,
+			Status:     c.Status,
+			Error:      c.Error,
+			DurationMs: c.Duration.Milliseconds(),
+		})
+	}
+
+	status := fiber.StatusOK
+	if readiness.Status != healthservice.StatusUp {
+		status = fiber.StatusServiceUnavailable
+	}
+
+	return ctx.Status(status).JSON(&data)
+}
+
+// VersionHandler responds with build info of the binary
+func (h *Health) VersionHandler(ctx *fiber.Ctx) error {
+	controller.SetCommonHeaders(ctx)
+
+	b := h.healthService.Version()
+
+	return ctx.Status(fiber.StatusOK).JSON(&health.VersionResponse{
+		Version:   b.Version,
+		Revision:  b.Revision,
+		Time:      b.Time,
+		Modified:  b.Modified,
+		GoVersion: b.GoVersion,
+	})
+}
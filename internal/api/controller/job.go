@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/job"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/gofiber/fiber/v2"
+)
+
+func JobResponse(j jobstorage.Job) job.Response {
+	data := job.Response{
+		Id:          j.Id,
+		Type:        j.Type,
+		Status:      j.Status,
+		Progress:    job.ProgressResponse{Done: j.ProgressDone, Total: j.ProgressTotal},
+		Error:       j.Error,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		CreatedAt:   j.CreatedAt,
+		StartedAt:   j.StartedAt.String,
+		FinishedAt:  j.FinishedAt.String,
+	}
+
+	if j.Result.Valid {
+		data.Result = json.RawMessage(j.Result.String)
+	}
+
+	return data
+}
+
+// JobAccepted responds to the request whose work is queued as the job, the client follows it by the location
+func JobAccepted(ctx *fiber.Ctx, data job.Response) error {
+	ctx.Set(fiber.HeaderLocation, fmt.Sprintf("/api/jobs/%d", data.Id))
+	ctx.Status(fiber.StatusAccepted)
+
+	return ctx.JSON(&data)
+}
@@ -0,0 +1,194 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/job"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	jobservice "github.com/albakov/go-cloud-file-storage/internal/service/job"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type Job struct {
+	pkg        string
+	jobService JobService
+}
+
+type JobService interface {
+	Job(ctx context.Context, userId, id int64) (jobstorage.Job, error)
+	Jobs(ctx context.Context, userId int64, page, perPage int) ([]jobstorage.Job, int64, error)
+	Cancel(ctx context.Context, userId, id int64) (jobstorage.Job, error)
+}
+
+func New(jobService JobService) *Job {
+	return &Job{
+		pkg:        "job",
+		jobService: jobService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		List jobs
+//	@Description	List background jobs of the current user, newest first. Finished jobs are kept for JOBS_RETENTION_DAYS
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			page			query		int						false	"Page, starts from 1"
+//	@Param			per_page		query		int						false	"Jobs per page, 20 by default, 100 at most"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	job.JobsResponse		"Page of jobs"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/jobs [get]
+func (jb *Job) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	page := ctx.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	perPage := ctx.QueryInt("per_page", defaultPerPage)
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+
+	jobs, total, err := jb.jobService.Jobs(ctx.UserContext(), controller.RequestedUserId(ctx), page, perPage)
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), jb.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := job.JobsResponse{
+		Jobs:    make([]job.Response, 0, len(jobs)),
+		Total:   total,
+		Page:    page,
+		PerPage: perPage,
+	}
+	for _, j := range jobs {
+		data.Jobs = append(data.Jobs, controller.JobResponse(j))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// ShowHandler godoc
+//
+//	@Summary		Show job
+//	@Description	Show the status and the progress of the background job. Statuses: queued, running, succeeded,
+//	@Description	failed, cancelled. Progress total is 0 while it isn't known. The result is set when the job succeeds
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Job id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	job.Response			"Job"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/jobs/{id} [get]
+func (jb *Job) ShowHandler(ctx *fiber.Ctx) error {
+	const op = "ShowHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	j, err := jb.jobService.Job(ctx.UserContext(), controller.RequestedUserId(ctx), id)
+	if err != nil {
+		if errors.Is(err, jobservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), jb.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := controller.JobResponse(j)
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// CancelHandler godoc
+//
+//	@Summary		Cancel job
+//	@Description	Cancel the queued or running job. The running job stops within seconds, work done by then isn't undone
+//	@Tags			jobs
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Job id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	job.Response			"Job, its status becomes cancelled when it stops"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		409				{object}	entity.ErrorResponse	"Job is already finished"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/jobs/{id} [delete]
+func (jb *Job) CancelHandler(ctx *fiber.Ctx) error {
+	const op = "CancelHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx)
+	if !ok {
+		return notFound(ctx)
+	}
+
+	j, err := jb.jobService.Cancel(ctx.UserContext(), controller.RequestedUserId(ctx), id)
+	if err != nil {
+		if errors.Is(err, jobservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		if errors.Is(err, jobservice.ErrFinished) {
+			return ctx.Status(fiber.StatusConflict).JSON(&entity.ErrorResponse{Message: controller.MessageJobFinished})
+		}
+
+		logger.AddContext(ctx.UserContext(), jb.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := controller.JobResponse(j)
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+func requestedId(ctx *fiber.Ctx) (int64, bool) {
+	id, err := ctx.ParamsInt("id")
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return int64(id), true
+}
+
+func notFound(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+}
+
+func serverError(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+}
@@ -7,4 +7,32 @@ const (
 	MessageUnauthorized           = "Unauthorized"
 	MessageUserAlreadyExists      = "User with this email already exists"
 	MessageNotFound               = "Not found"
+	MessageTokenInvalid           = "Token invalid or expired"
+	MessageEmailAlreadyVerified   = "Email already verified"
+	MessageEmailNotVerified       = "Email is not verified"
+	MessagePasswordInvalid        = "Password invalid"
+	MessageEmailIsTheSame         = "New email is the same as the current one"
+	MessageValidationFailed       = "Validation failed"
+	MessageTooManyRequests        = "Too many requests, try again later"
+	MessageAccountLocked          = "Account is temporarily locked after too many failed sign in attempts"
+	MessageInsufficientScope      = "Access token does not have the required scope"
+	MessageSessionRequired        = "This action is not available with an access token"
+	MessagePasswordLoginDisabled  = "Sign in with password is disabled, use single sign-on"
+	MessageIdentityNotLinked      = "Link an identity provider before disabling sign in with password"
+	MessageLastLoginMethod        = "Enable sign in with password before unlinking the last identity provider"
+	MessageReauthRequired         = "Confirm the action by signing in with the identity provider again"
+	MessageForbidden              = "Forbidden"
+	MessageAccountDisabled        = "Account is disabled"
+	MessageCannotChangeSelf       = "Administrators can't disable or demote their own account"
+	MessageInvalidRole            = "Role invalid"
+	MessageUnknownJob             = "Unknown maintenance job"
+	MessageJobAlreadyRunning      = "Maintenance job is already running"
+	MessageQuotaExceeded          = "Storage quota exceeded"
+	MessagePreconditionFailed     = "Files have been changed by another client"
+	MessageCursorInvalid          = "Cursor is ahead of the journal"
+	MessageCursorExpired          = "Changes after the cursor are removed, list the files again"
+	MessageTooManyWebhooks        = "Too many webhooks, delete unused ones first"
+	MessageWebhookDisabled        = "Webhook is disabled, enable it first"
+	MessageJobFinished            = "Job is already finished"
+	MessageDownloadLinkInvalid    = "Download link invalid or expired"
 )
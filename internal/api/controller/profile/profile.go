@@ -1,6 +1,7 @@
 package profile
 
 import (
+	"context"
 	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
@@ -17,7 +18,7 @@ type Profile struct {
 }
 
 type UserService interface {
-	UserById(userId int64) (user.User, error)
+	UserById(ctx context.Context, userId int64) (user.User, error)
 }
 
 func New(userService UserService) *Profile {
@@ -30,12 +31,12 @@ func New(userService UserService) *Profile {
 // ShowHandler godoc
 //
 //	@Summary		Profile
-//	@Description	Show profile info (email)
+//	@Description	Show profile info (email, verification status, role)
 //	@Tags			auth
 //	@Accept			json
 //	@Produce		json
 //	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
-//	@Success		200				{object}	profile.ProfileResponse	"Email address"
+//	@Success		200				{object}	profile.ProfileResponse	"Email address and verification status"
 //	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
 //	@Router			/user/me [get]
 func (p *Profile) ShowHandler(ctx *fiber.Ctx) error {
@@ -44,10 +45,10 @@ func (p *Profile) ShowHandler(ctx *fiber.Ctx) error {
 	controller.SetCommonHeaders(ctx)
 
 	userId := controller.RequestedUserId(ctx)
-	us, err := p.userService.UserById(userId)
+	us, err := p.userService.UserById(ctx.UserContext(), userId)
 	if err != nil {
 		if !errors.Is(err, storage.ErrNotFound) {
-			logger.Add(p.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), p.pkg, op, err)
 		}
 
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
@@ -56,6 +57,8 @@ func (p *Profile) ShowHandler(ctx *fiber.Ctx) error {
 	ctx.Status(fiber.StatusOK)
 
 	return ctx.JSON(&profile.ProfileResponse{
-		Email: us.Email.String,
+		Email:         us.Email.String,
+		EmailVerified: us.EmailVerifiedAt.Valid,
+		Role:          us.Role,
 	})
 }
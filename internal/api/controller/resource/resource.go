@@ -1,37 +1,82 @@
 package resource
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/export"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/job"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
 	"github.com/albakov/go-cloud-file-storage/internal/config"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	exportservice "github.com/albakov/go-cloud-file-storage/internal/service/export"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/albakov/go-cloud-file-storage/internal/service/resourcejob"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	exportstorage "github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
 	"github.com/gofiber/fiber/v2"
 	"github.com/minio/minio-go/v7"
+	"io"
 	"mime/multipart"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
 type Resource struct {
-	pkg       string
-	conf      *config.Config
-	s3Service S3Service
+	pkg           string
+	conf          *config.Config
+	s3Service     S3Service
+	quotaService  QuotaService
+	auditService  AuditService
+	jobService    JobService
+	exportService ExportService
+	metrics       Metrics
+}
+
+type QuotaService interface {
+	Check(ctx context.Context, userId, incoming int64) error
+}
+
+type AuditService interface {
+	Record(event audit.Event)
+}
+
+// JobService queues operations on directories, they may take too long for the request
+type JobService interface {
+	Enqueue(ctx context.Context, userId int64, jobType string, payload any) (jobstorage.Job, error)
+}
+
+// ExportService queues archives of paths, they are downloaded by links when written
+type ExportService interface {
+	Create(ctx context.Context, userId int64, format string, paths []resource.Path) (exportstorage.Export, error)
+}
+
+type Metrics interface {
+	AddUploadedBytes(n int64)
+	AddDownloadedBytes(n int64)
 }
 
 type S3Service interface {
 	Object(ctx context.Context, path resource.Path) (*minio.Object, error)
-	StoreObject(ctx context.Context, files []*multipart.FileHeader, paths map[string]string, userId int64, path resource.Path) *[]resource.Response
+	Stat(ctx context.Context, path resource.Path) (minio.ObjectInfo, error)
+	StoreObject(
+		ctx context.Context,
+		files []*multipart.FileHeader,
+		paths map[string]string,
+		etags map[string]string,
+		userId int64,
+		path resource.Path,
+	) (*[]resource.Response, []string)
 	Delete(ctx context.Context, path resource.Path) error
 
 	Move(ctx context.Context, to, from resource.Path) error
 	Search(ctx context.Context, userId int64, query string) *[]resource.Response
-	MakeZip(ctx context.Context, path resource.Path) (*bytes.Buffer, error)
 
 	StoreDirectory(ctx context.Context, path resource.Path) (minio.UploadInfo, error)
 	PaginateDirectory(ctx context.Context, userId int64, path resource.Path) *[]resource.Response
@@ -42,11 +87,24 @@ type S3Service interface {
 	UserFolderPath(userId int64) string
 }
 
-func New(conf *config.Config, s3Service S3Service) *Resource {
+func New(
+	conf *config.Config,
+	s3Service S3Service,
+	quotaService QuotaService,
+	auditService AuditService,
+	jobService JobService,
+	exportService ExportService,
+	metrics Metrics,
+) *Resource {
 	return &Resource{
-		pkg:       "resource",
-		conf:      conf,
-		s3Service: s3Service,
+		pkg:           "resource",
+		conf:          conf,
+		s3Service:     s3Service,
+		quotaService:  quotaService,
+		auditService:  auditService,
+		jobService:    jobService,
+		exportService: exportService,
+		metrics:       metrics,
 	}
 }
 
@@ -76,16 +134,16 @@ func (res *Resource) ShowHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
 	}
 
-	object, err := res.s3Service.Object(ctx.Context(), path)
+	object, err := res.s3Service.Object(ctx.UserContext(), path)
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
 
 		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
 	}
 
 	stat, err := object.Stat()
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
 
 		return ctx.Status(fiber.StatusInternalServerError).JSON(
 			&entity.ErrorResponse{Message: controller.MessageServerError},
@@ -99,6 +157,7 @@ func (res *Resource) ShowHandler(ctx *fiber.Ctx) error {
 		Name: filepath.Base(stat.Key),
 		Size: stat.Size,
 		Type: res.s3Service.ObjectType(stat.Key),
+		ETag: stat.ETag,
 	})
 }
 
@@ -109,13 +168,16 @@ func (res *Resource) ShowHandler(ctx *fiber.Ctx) error {
 //	@Tags			resource
 //	@Accept			json
 //	@Produce		json
-//	@Param			path			query		string					true	"path=/folder1/folder2/"
-//	@Param			paths			formData	string					true	"Must consist json string with paths. Keys are name of resource and values are full path. Example: {'folder':'/folder1/folder/',...}"
-//	@Param			files			formData	[]file					true	"Uploading files"
-//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
-//	@Success		201				{object}	[]resource.Response		"Returns list of created resources"
-//	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
-//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Param			path			query		string						true	"path=/folder1/folder2/"
+//	@Param			paths			formData	string						true	"Must consist json string with paths. Keys are name of resource and values are full path. Example: {'folder':'/folder1/folder/',...}"
+//	@Param			files			formData	[]file						true	"Uploading files"
+//	@Param			etags			formData	string						false	"JSON with expected etags of overwritten files by names of resources, empty etag - the file must not exist. Example: {'a.txt':'9a0364b9e99bb480dd25e1f0284c8555','b.txt':''}"
+//	@Param			Authorization	header		string						true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		201				{object}	[]resource.Response			"Returns list of created resources"
+//	@Failure		400				{object}	entity.ErrorResponse		"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse		"Unauthorized"
+//	@Failure		412				{object}	resource.ConflictResponse	"Files don't have the expected etags"
+//	@Failure		507				{object}	entity.ErrorResponse		"Storage quota exceeded"
 //	@Router			/resource [post]
 func (res *Resource) StoreHandler(ctx *fiber.Ctx) error {
 	const op = "StoreHandler"
@@ -134,29 +196,73 @@ func (res *Resource) StoreHandler(ctx *fiber.Ctx) error {
 
 	err = json.Unmarshal([]byte(pathsJson), &paths)
 	if err != nil {
-		logger.Add(res.pkg, op, fmt.Errorf("invalid paths JSON: %w", err))
+		logger.AddContext(ctx.UserContext(), res.pkg, op, fmt.Errorf("invalid paths JSON: %w", err))
 
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
+	// conditional uploads of sync clients, which must not overwrite changes of other clients
+	etags := make(map[string]string)
+
+	if etagsJson := ctx.FormValue("etags"); etagsJson != "" {
+		err = json.Unmarshal([]byte(etagsJson), &etags)
+		if err != nil {
+			logger.AddContext(ctx.UserContext(), res.pkg, op, fmt.Errorf("invalid etags JSON: %w", err))
+
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+		}
+	}
+
 	form, err := ctx.MultipartForm()
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
 
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
 	files := form.File["files"]
+	var incoming int64
 
 	for _, file := range files {
 		if _, ok := paths[file.Filename]; !ok {
-			logger.Add(res.pkg, op, err)
+			logger.AddContext(ctx.UserContext(), res.pkg, op, err)
 
 			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 		}
+
+		incoming += file.Size
+	}
+
+	err = res.quotaService.Check(ctx.UserContext(), userId, incoming)
+	if err != nil {
+		if errors.Is(err, quota.ErrExceeded) {
+			res.record(ctx, audit.ActionResourceCreate, audit.ResultFailure, path.OriginalPath, "")
+
+			return ctx.Status(fiber.StatusInsufficientStorage).JSON(&entity.ErrorResponse{Message: controller.MessageQuotaExceeded})
+		}
+
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
 	}
 
-	data := res.s3Service.StoreObject(ctx.Context(), files, paths, userId, path)
+	data, conflicts := res.s3Service.StoreObject(ctx.UserContext(), files, paths, etags, userId, path)
+	for _, r := range *data {
+		res.record(ctx, audit.ActionResourceCreate, audit.ResultSuccess, r.Path, "")
+		res.metrics.AddUploadedBytes(r.Size)
+	}
+
+	if len(conflicts) > 0 {
+		for _, p := range conflicts {
+			res.record(ctx, audit.ActionResourceCreate, audit.ResultFailure, p, "")
+		}
+
+		return ctx.Status(fiber.StatusPreconditionFailed).JSON(&resource.ConflictResponse{
+			Message:   controller.MessagePreconditionFailed,
+			Conflicts: conflicts,
+			Stored:    *data,
+		})
+	}
 
 	ctx.Status(fiber.StatusCreated)
 
@@ -166,16 +272,19 @@ func (res *Resource) StoreHandler(ctx *fiber.Ctx) error {
 // DeleteHandler godoc
 //
 //	@Summary		Delete resource
-//	@Description	Delete resource in the given path
+//	@Description	Delete resource in the given path. Folders are deleted by the background job, its progress
+//	@Description	is at the Location header
 //	@Tags			resource
 //	@Accept			json
 //	@Produce		json
 //	@Param			path			query		string					true	"path=/folder1/folder2/"
 //	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		202				{object}	job.Response			"Queued job deleting the folder"
 //	@Success		204				{object}	nil						"No content"
 //	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
 //	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
 //	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
 //	@Router			/resource [delete]
 func (res *Resource) DeleteHandler(ctx *fiber.Ctx) error {
 	const op = "DeleteHandler"
@@ -188,13 +297,31 @@ func (res *Resource) DeleteHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
-	err = res.s3Service.Delete(ctx.Context(), path)
+	if path.IsDirectory {
+		payload := resourcejob.DeletePayload{Directory: resourcejob.NewDirectory(path)}
+
+		return res.enqueue(
+			ctx,
+			op,
+			userId,
+			resourcejob.TypeDelete,
+			payload,
+			audit.ActionResourceDelete,
+			path.OriginalPath,
+			"",
+		)
+	}
+
+	err = res.s3Service.Delete(ctx.UserContext(), path)
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, audit.ActionResourceDelete, audit.ResultFailure, path.OriginalPath, "")
 
 		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
 	}
 
+	res.record(ctx, audit.ActionResourceDelete, audit.ResultSuccess, path.OriginalPath, "")
+
 	ctx.Status(fiber.StatusNoContent)
 
 	return nil
@@ -203,16 +330,21 @@ func (res *Resource) DeleteHandler(ctx *fiber.Ctx) error {
 // DownloadHandler godoc
 //
 //	@Summary		Download resource
-//	@Description	Download resource from the given path
+//	@Description	Download the file from the given path. The folder is exported as a zip archive in background,
+//	@Description	the response is the queued export, its download link is set when the archive is ready
 //	@Tags			resource
 //	@Accept			json
 //	@Produce		application/octet-stream
 //	@Param			path			query		string					true	"path=/folder1/folder2/"
+//	@Param			Range			header		string					false	"Range of the file to resume the download, e.g. bytes=1024-"
 //	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
-//	@Success		200				{string}	binary					"If path is a folder, returns zip archive, else - attachment. Content-Type for response is application/octet-stream"
+//	@Success		200				{string}	binary					"File attachment, Content-Type for response is application/octet-stream"
+//	@Success		202				{object}	export.Response			"Queued export of the folder"
+//	@Success		206				{string}	binary					"Requested range of the file"
 //	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
 //	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
 //	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		416				{object}	entity.ErrorResponse	"Range not satisfiable"
 //	@Failure		500				{object}	entity.ErrorResponse	"Server error"
 //	@Router			/resource/download [get]
 func (res *Resource) DownloadHandler(ctx *fiber.Ctx) error {
@@ -228,27 +360,27 @@ func (res *Resource) DownloadHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
-	// zip all in directory
+	// the archive of the folder is written by the export job, it's downloaded by the link of the export
 	if path.IsDirectory {
-		buf, err := res.s3Service.MakeZip(ctx.Context(), path)
-		if err != nil {
-			logger.Add(res.pkg, op, err)
+		if _, err := res.s3Service.Stat(ctx.UserContext(), path); err != nil {
+			if errors.Is(err, s3.ErrNotFound) {
+				return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+			}
+
+			logger.AddContext(ctx.UserContext(), res.pkg, op, err)
 
 			return ctx.Status(fiber.StatusInternalServerError).JSON(
 				&entity.ErrorResponse{Message: controller.MessageServerError},
 			)
 		}
 
-		ctx.Status(fiber.StatusOK)
-		ctx.Set(fiber.HeaderContentType, "application/octet-stream")
-		ctx.Set(fiber.HeaderContentDisposition, "attachment; filename=\"archive.zip\"")
-
-		return ctx.Send(buf.Bytes())
+		return res.export(ctx, op, userId, s3.ArchiveZip, []resource.Path{path}, path.OriginalPath)
 	}
 
-	object, err := res.s3Service.Object(ctx.Context(), path)
+	object, err := res.s3Service.Object(ctx.UserContext(), path)
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, audit.ActionResourceDownload, audit.ResultFailure, path.OriginalPath, "")
 
 		return ctx.Status(fiber.StatusInternalServerError).JSON(
 			&entity.ErrorResponse{Message: controller.MessageServerError},
@@ -257,7 +389,8 @@ func (res *Resource) DownloadHandler(ctx *fiber.Ctx) error {
 
 	stat, err := object.Stat()
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, audit.ActionResourceDownload, audit.ResultFailure, path.OriginalPath, "")
 
 		return ctx.Status(fiber.StatusInternalServerError).JSON(
 			&entity.ErrorResponse{Message: controller.MessageServerError},
@@ -266,8 +399,41 @@ func (res *Resource) DownloadHandler(ctx *fiber.Ctx) error {
 
 	ctx.Set(fiber.HeaderContentType, "application/octet-stream")
 	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filepath.Base(stat.Key)))
+	ctx.Set(fiber.HeaderAcceptRanges, "bytes")
+
+	// the range of the file lets clients resume interrupted downloads
+	if header := ctx.Get(fiber.HeaderRange); header != "" {
+		start, end, ok := byteRange(header, stat.Size)
+		if !ok {
+			ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes */%d", stat.Size))
+
+			return ctx.Status(fiber.StatusRequestedRangeNotSatisfiable).JSON(
+				&entity.ErrorResponse{Message: controller.MessageBadRequest},
+			)
+		}
+
+		if _, err := object.Seek(start, io.SeekStart); err != nil {
+			logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+			res.record(ctx, audit.ActionResourceDownload, audit.ResultFailure, path.OriginalPath, "")
+
+			return ctx.Status(fiber.StatusInternalServerError).JSON(
+				&entity.ErrorResponse{Message: controller.MessageServerError},
+			)
+		}
+
+		res.record(ctx, audit.ActionResourceDownload, audit.ResultSuccess, path.OriginalPath, "")
+		res.metrics.AddDownloadedBytes(end - start + 1)
+
+		ctx.Status(fiber.StatusPartialContent)
+		ctx.Set(fiber.HeaderContentRange, fmt.Sprintf("bytes %d-%d/%d", start, end, stat.Size))
+
+		return ctx.SendStream(io.LimitReader(object, end-start+1), int(end-start+1))
+	}
+
+	res.record(ctx, audit.ActionResourceDownload, audit.ResultSuccess, path.OriginalPath, "")
+	res.metrics.AddDownloadedBytes(stat.Size)
 
-	return ctx.SendStream(object)
+	return ctx.SendStream(object, int(stat.Size))
 }
 
 // SearchHandler godoc
@@ -293,7 +459,19 @@ func (res *Resource) SearchHandler(ctx *fiber.Ctx) error {
 
 	userId := controller.RequestedUserId(ctx)
 
-	data := res.s3Service.Search(ctx.Context(), userId, query)
+	data := res.s3Service.Search(ctx.UserContext(), userId, query)
+
+	// personal access token restricted to a folder must not reveal files outside of it
+	if t, ok := controller.RequestedAccessToken(ctx); ok && t.Folder != "" {
+		filtered := []resource.Response{}
+		for _, r := range *data {
+			if isInFolder(r.Path, t.Folder) {
+				filtered = append(filtered, r)
+			}
+		}
+
+		data = &filtered
+	}
 
 	ctx.Status(fiber.StatusOK)
 
@@ -303,16 +481,18 @@ func (res *Resource) SearchHandler(ctx *fiber.Ctx) error {
 // MoveHandler godoc
 //
 //	@Summary		Move resource
-//	@Description	Move resource $from $to
+//	@Description	Move resource $from $to. Folders are moved by the background job, its progress is at the Location header
 //	@Tags			resource
 //	@Accept			json
 //	@Produce		json
 //	@Param			from			query		string					true	"from=/folder/file"
 //	@Param			to				query		string					true	"to=/another-folder/file"
 //	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		202				{object}	job.Response			"Queued job moving the folder"
 //	@Success		204				{object}	nil						"No content"
 //	@Failure		400				{object}	entity.ErrorResponse	"Bad request"
 //	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
 //	@Router			/resource/move [get]
 func (res *Resource) MoveHandler(ctx *fiber.Ctx) error {
 	const op = "MoveHandler"
@@ -336,18 +516,94 @@ func (res *Resource) MoveHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
-	err = res.s3Service.Move(ctx.Context(), to, from)
+	if from.IsDirectory {
+		payload := resourcejob.MovePayload{From: resourcejob.NewDirectory(from), To: resourcejob.NewDirectory(to)}
+
+		return res.enqueue(
+			ctx,
+			op,
+			userId,
+			resourcejob.TypeMove,
+			payload,
+			audit.ActionResourceMove,
+			from.OriginalPath,
+			to.OriginalPath,
+		)
+	}
+
+	err = res.s3Service.Move(ctx.UserContext(), to, from)
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, audit.ActionResourceMove, audit.ResultFailure, from.OriginalPath, to.OriginalPath)
 
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
+	res.record(ctx, audit.ActionResourceMove, audit.ResultSuccess, from.OriginalPath, to.OriginalPath)
+
 	ctx.Status(fiber.StatusNoContent)
 
 	return nil
 }
 
+// ExportHandler godoc
+//
+//	@Summary		Export resources
+//	@Description	Queue the archive of files and folders in zip or tar.gz format. The archive is written in background,
+//	@Description	the export.ready event is sent when it's ready. The export has the download link then,
+//	@Description	the link works without authorization until the export expires
+//	@Tags			resource
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			export			body		export.CreateRequest			true	"Paths and format of the archive"
+//	@Success		202				{object}	export.Response					"Queued export"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse			"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse			"Server error"
+//	@Router			/resource/export [post]
+func (res *Resource) ExportHandler(ctx *fiber.Ctx) error {
+	const op = "ExportHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r export.CreateRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	userId := controller.RequestedUserId(ctx)
+
+	paths := make([]resource.Path, 0, len(r.Paths))
+	for _, v := range r.Paths {
+		if v == "" {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+		}
+
+		path, err := res.userPath(ctx, v, userId)
+		if err != nil {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+		}
+
+		if _, err := res.s3Service.Stat(ctx.UserContext(), path); err != nil {
+			if errors.Is(err, s3.ErrNotFound) {
+				return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+			}
+
+			logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+
+			return ctx.Status(fiber.StatusInternalServerError).JSON(
+				&entity.ErrorResponse{Message: controller.MessageServerError},
+			)
+		}
+
+		paths = append(paths, path)
+	}
+
+	return res.export(ctx, op, userId, r.Format, paths, strings.Join(r.Paths, ","))
+}
+
 // DirectoryShowHandler godoc
 //
 //	@Summary		Show resources in the directory
@@ -371,7 +627,7 @@ func (res *Resource) DirectoryShowHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
 	}
 
-	data := res.s3Service.PaginateDirectory(ctx.Context(), userId, path)
+	data := res.s3Service.PaginateDirectory(ctx.UserContext(), userId, path)
 	ctx.Status(fiber.StatusOK)
 
 	return ctx.JSON(&data)
@@ -402,13 +658,16 @@ func (res *Resource) DirectoryStoreHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
-	object, err := res.s3Service.StoreDirectory(ctx.Context(), path)
+	object, err := res.s3Service.StoreDirectory(ctx.UserContext(), path)
 	if err != nil {
-		logger.Add(res.pkg, op, err)
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, audit.ActionResourceCreate, audit.ResultFailure, path.OriginalPath, "")
 
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
+	res.record(ctx, audit.ActionResourceCreate, audit.ResultSuccess, path.OriginalPath, "")
+
 	ctx.Status(fiber.StatusCreated)
 
 	return ctx.JSON(&resource.Response{
@@ -419,12 +678,87 @@ func (res *Resource) DirectoryStoreHandler(ctx *fiber.Ctx) error {
 	})
 }
 
+// enqueue queues the job on the directory and responds with it, the action is audited when it's queued
+func (res *Resource) enqueue(
+	ctx *fiber.Ctx,
+	op string,
+	userId int64,
+	jobType string,
+	payload any,
+	action, path, targetPath string,
+) error {
+	j, err := res.jobService.Enqueue(ctx.UserContext(), userId, jobType, payload)
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, action, audit.ResultFailure, path, targetPath)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	res.record(ctx, action, audit.ResultSuccess, path, targetPath)
+
+	var data job.Response = controller.JobResponse(j)
+
+	return controller.JobAccepted(ctx, data)
+}
+
+// export queues the archive of the paths and answers with the export, the path is the one of the audit log
+func (res *Resource) export(
+	ctx *fiber.Ctx,
+	op string,
+	userId int64,
+	format string,
+	paths []resource.Path,
+	path string,
+) error {
+	e, err := res.exportService.Create(ctx.UserContext(), userId, format, paths)
+	if err != nil {
+		if field, message, ok := invalidExport(err); ok {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ValidationErrorResponse{
+				Message: controller.MessageValidationFailed,
+				Errors:  []entity.FieldError{{Field: field, Message: message}},
+			})
+		}
+
+		logger.AddContext(ctx.UserContext(), res.pkg, op, err)
+		res.record(ctx, audit.ActionResourceExport, audit.ResultFailure, path, "")
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	res.record(ctx, audit.ActionResourceExport, audit.ResultSuccess, path, "")
+
+	var data export.Response = controller.ExportResponse(ctx, e, "")
+
+	ctx.Set(fiber.HeaderLocation, fmt.Sprintf("/api/exports/%d", e.Id))
+	ctx.Status(fiber.StatusAccepted)
+
+	return ctx.JSON(&data)
+}
+
+func (res *Resource) record(ctx *fiber.Ctx, action, result, path, targetPath string) {
+	event := controller.AuditEvent(ctx, action, result)
+	event.Path = path
+	event.TargetPath = targetPath
+
+	res.auditService.Record(event)
+}
+
 func (res *Resource) requestedPath(ctx *fiber.Ctx, key string, userId int64) (resource.Path, error) {
 	path := ctx.Query(key, "")
 	if path == "" {
 		return resource.Path{}, fmt.Errorf("%s is empty", key)
 	}
 
+	return res.userPath(ctx, path, userId)
+}
+
+// userPath returns the path in the user folder, it must be inside the folder of the access token if there is one
+func (res *Resource) userPath(ctx *fiber.Ctx, path string, userId int64) (resource.Path, error) {
 	p := resource.Path{
 		OriginalPath: path,
 		IsDirectory:  strings.HasSuffix(path, "/"),
@@ -437,7 +771,74 @@ func (res *Resource) requestedPath(ctx *fiber.Ctx, key string, userId int64) (re
 		return resource.Path{}, errors.New("path traversal attempt detected")
 	}
 
+	if t, ok := controller.RequestedAccessToken(ctx); ok && t.Folder != "" {
+		if !isInFolder(strings.TrimPrefix(path, base), t.Folder) {
+			return resource.Path{}, errors.New("path is outside of the access token folder")
+		}
+	}
+
 	p.CleanPath = path
 
 	return p, nil
 }
+
+// invalidExport returns the field and the message of the validation error of the export
+func invalidExport(err error) (string, string, bool) {
+	switch {
+	case errors.Is(err, exportservice.ErrUnknownFormat):
+		return "format", "must be zip or tar.gz", true
+	case errors.Is(err, exportservice.ErrNoPaths):
+		return "paths", "must not be empty", true
+	case errors.Is(err, exportservice.ErrTooManyPaths):
+		return "paths", "too many paths", true
+	default:
+		return "", "", false
+	}
+}
+
+// byteRange returns the first and the last byte of the single range "bytes=start-end", "bytes=start-"
+// or "bytes=-suffix" within the file of the size
+func byteRange(header string, size int64) (int64, int64, bool) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+
+	first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 || size == 0 {
+			return 0, 0, false
+		}
+
+		return max(size-n, 0), size - 1, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 || start >= size {
+		return 0, 0, false
+	}
+
+	end := size - 1
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+
+		end = min(end, size-1)
+	}
+
+	return start, end, true
+}
+
+// isInFolder checks that the path relative to the user folder is the folder itself or inside of it
+func isInFolder(path, folder string) bool {
+	path = strings.TrimSuffix(path, "/")
+
+	return path == folder || strings.HasPrefix(path, folder+"/")
+}
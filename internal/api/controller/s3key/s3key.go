@@ -0,0 +1,167 @@
+package s3key
+
+import (
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/s3key"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	s3keyservice "github.com/albakov/go-cloud-file-storage/internal/service/s3key"
+	s3keystorage "github.com/albakov/go-cloud-file-storage/internal/storage/s3key"
+	"github.com/gofiber/fiber/v2"
+)
+
+type S3Key struct {
+	pkg          string
+	s3KeyService S3KeyService
+}
+
+type S3KeyService interface {
+	CreateKey(userId int64, name string) (string, s3keystorage.S3Key, error)
+	Keys(userId int64) ([]s3keystorage.S3Key, error)
+	RevokeKey(userId, keyId int64) error
+}
+
+func New(s3KeyService S3KeyService) *S3Key {
+	return &S3Key{
+		pkg:          "s3key",
+		s3KeyService: s3KeyService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		List S3 access keys
+//	@Description	List access keys of the S3 gateway of the current user. Secret access keys are never returned again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	[]s3key.Response		"List of keys"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/s3-keys [get]
+func (sk *S3Key) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	keys, err := sk.s3KeyService.Keys(controller.RequestedUserId(ctx))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), sk.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	data := make([]s3key.Response, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, response(k))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// StoreHandler godoc
+//
+//	@Summary		Create S3 access key
+//	@Description	Create an access key pair for S3 clients of the S3 gateway. Requests are signed with Signature V4,
+//	@Description	the bucket of the gateway holds the files of the user. The secret access key is shown only once
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			key				body		s3key.CreateRequest				true	"Key settings"
+//	@Success		201				{object}	s3key.CreateResponse			"Created key"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Not available with an access token"
+//	@Failure		500				{object}	entity.ErrorResponse			"Server error"
+//	@Router			/user/s3-keys [post]
+func (sk *S3Key) StoreHandler(ctx *fiber.Ctx) error {
+	const op = "StoreHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r s3key.CreateRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	secret, k, err := sk.s3KeyService.CreateKey(controller.RequestedUserId(ctx), r.Name)
+	if err != nil {
+		if errors.Is(err, s3keyservice.ErrNameRequired) {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ValidationErrorResponse{
+				Message: controller.MessageValidationFailed,
+				Errors:  []entity.FieldError{{Field: "name", Message: "is required"}},
+			})
+		}
+
+		logger.AddContext(ctx.UserContext(), sk.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusCreated)
+
+	return ctx.JSON(&s3key.CreateResponse{Response: response(k), SecretAccessKey: secret})
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Revoke S3 access key
+//	@Description	Revoke access key of the S3 gateway of the current user
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Key id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/s3-keys/{id} [delete]
+func (sk *S3Key) DeleteHandler(ctx *fiber.Ctx) error {
+	const op = "DeleteHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	keyId, err := ctx.ParamsInt("id")
+	if err != nil || keyId <= 0 {
+		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+	}
+
+	err = sk.s3KeyService.RevokeKey(controller.RequestedUserId(ctx), int64(keyId))
+	if err != nil {
+		if errors.Is(err, s3keyservice.ErrNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+		}
+
+		logger.AddContext(ctx.UserContext(), sk.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+func response(k s3keystorage.S3Key) s3key.Response {
+	return s3key.Response{
+		Id:          k.Id,
+		Name:        k.Name,
+		AccessKeyId: k.AccessKeyId,
+		LastUsedAt:  k.LastUsedAt.String,
+		CreatedAt:   k.CreatedAt,
+	}
+}
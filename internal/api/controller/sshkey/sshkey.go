@@ -0,0 +1,177 @@
+package sshkey
+
+import (
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/sshkey"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	sshkeyservice "github.com/albakov/go-cloud-file-storage/internal/service/sshkey"
+	sshkeystorage "github.com/albakov/go-cloud-file-storage/internal/storage/sshkey"
+	"github.com/gofiber/fiber/v2"
+)
+
+type SSHKey struct {
+	pkg           string
+	sshKeyService SSHKeyService
+}
+
+type SSHKeyService interface {
+	AddKey(userId int64, name, authorizedKey string) (sshkeystorage.SSHKey, error)
+	Keys(userId int64) ([]sshkeystorage.SSHKey, error)
+	RemoveKey(userId, keyId int64) error
+}
+
+func New(sshKeyService SSHKeyService) *SSHKey {
+	return &SSHKey{
+		pkg:           "sshkey",
+		sshKeyService: sshKeyService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		List SSH keys
+//	@Description	List public keys the current user signs in to the SFTP server with
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	[]sshkey.Response		"List of keys"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/ssh-keys [get]
+func (sk *SSHKey) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	keys, err := sk.sshKeyService.Keys(controller.RequestedUserId(ctx))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), sk.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	data := make([]sshkey.Response, 0, len(keys))
+	for _, k := range keys {
+		data = append(data, response(k))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// StoreHandler godoc
+//
+//	@Summary		Add SSH key
+//	@Description	Add a public key in the authorized_keys format to sign in to the SFTP server with.
+//	@Description	The comment of the key is its name if the name is empty. RSA keys must have at least 2048 bits
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			key				body		sshkey.CreateRequest			true	"Public key"
+//	@Success		201				{object}	sshkey.Response					"Added key"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Not available with an access token"
+//	@Failure		409				{object}	entity.ErrorResponse			"Key already added"
+//	@Failure		500				{object}	entity.ErrorResponse			"Server error"
+//	@Router			/user/ssh-keys [post]
+func (sk *SSHKey) StoreHandler(ctx *fiber.Ctx) error {
+	const op = "StoreHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r sshkey.CreateRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	k, err := sk.sshKeyService.AddKey(controller.RequestedUserId(ctx), r.Name, r.PublicKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, sshkeyservice.ErrInvalidKey):
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ValidationErrorResponse{
+				Message: controller.MessageValidationFailed,
+				Errors:  []entity.FieldError{{Field: "public_key", Message: "is invalid or not allowed"}},
+			})
+		case errors.Is(err, sshkeyservice.ErrNameRequired):
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ValidationErrorResponse{
+				Message: controller.MessageValidationFailed,
+				Errors:  []entity.FieldError{{Field: "name", Message: "is required"}},
+			})
+		case errors.Is(err, sshkeyservice.ErrAlreadyExists):
+			return ctx.Status(fiber.StatusConflict).JSON(&entity.ErrorResponse{Message: "SSH key already added"})
+		}
+
+		logger.AddContext(ctx.UserContext(), sk.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusCreated)
+
+	return ctx.JSON(response(k))
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Remove SSH key
+//	@Description	Remove public key of the current user, the SFTP server doesn't accept it anymore
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Key id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/ssh-keys/{id} [delete]
+func (sk *SSHKey) DeleteHandler(ctx *fiber.Ctx) error {
+	const op = "DeleteHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	keyId, err := ctx.ParamsInt("id")
+	if err != nil || keyId <= 0 {
+		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+	}
+
+	err = sk.sshKeyService.RemoveKey(controller.RequestedUserId(ctx), int64(keyId))
+	if err != nil {
+		if errors.Is(err, sshkeyservice.ErrNotFound) {
+			return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+		}
+
+		logger.AddContext(ctx.UserContext(), sk.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+func response(k sshkeystorage.SSHKey) sshkey.Response {
+	return sshkey.Response{
+		Id:          k.Id,
+		Name:        k.Name,
+		PublicKey:   k.PublicKey,
+		Fingerprint: k.Fingerprint,
+		LastUsedAt:  k.LastUsedAt.String,
+		CreatedAt:   k.CreatedAt,
+	}
+}
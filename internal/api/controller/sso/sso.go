@@ -0,0 +1,428 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/sso"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	ssoservice "github.com/albakov/go-cloud-file-storage/internal/service/sso"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/useridentity"
+	"github.com/gofiber/fiber/v2"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const stateCookie = "oidc_state"
+
+type SSO struct {
+	pkg          string
+	conf         *config.Config
+	ssoService   SSOService
+	auth         Auth
+	auditService AuditService
+}
+
+type SSOService interface {
+	Providers() []string
+	AuthURL(providerName string, userId int64) (string, string, error)
+	ReauthURL(providerName string, userId int64) (string, string, error)
+	ReauthToken(userId int64) (string, time.Time)
+	Callback(ctx context.Context, providerName, code, stateParam, stateCookie string) (ssoservice.Result, error)
+	Identities(ctx context.Context, userId int64) ([]useridentity.Identity, error)
+	Unlink(ctx context.Context, userId, identityId int64) error
+	SetPasswordLogin(ctx context.Context, userId int64, enabled bool) error
+}
+
+type Auth interface {
+	SignIn(ctx *fiber.Ctx, userId int64) (string, error)
+}
+
+type AuditService interface {
+	Record(event audit.Event)
+}
+
+func New(conf *config.Config, ssoService SSOService, auth Auth, auditService AuditService) *SSO {
+	return &SSO{
+		pkg:          "sso",
+		conf:         conf,
+		ssoService:   ssoService,
+		auth:         auth,
+		auditService: auditService,
+	}
+}
+
+// ProvidersHandler godoc
+//
+//	@Summary		Identity providers
+//	@Description	Names of the configured OpenID Connect providers
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Success		200	{object}	[]string	"Provider names"
+//	@Router			/auth/oidc [get]
+func (s *SSO) ProvidersHandler(ctx *fiber.Ctx) error {
+	controller.SetCommonHeaders(ctx)
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(s.ssoService.Providers())
+}
+
+// LoginHandler godoc
+//
+//	@Summary		Sign in with identity provider
+//	@Description	Redirect to the OpenID Connect provider (authorization code flow with PKCE)
+//	@Tags			auth
+//	@Param			provider	path		string					true	"Provider name"
+//	@Success		302			{object}	nil						"Redirect to the provider"
+//	@Failure		404			{object}	entity.ErrorResponse	"Unknown provider"
+//	@Failure		500			{object}	entity.ErrorResponse	"Provider is unavailable"
+//	@Router			/auth/oidc/{provider} [get]
+func (s *SSO) LoginHandler(ctx *fiber.Ctx) error {
+	const op = "LoginHandler"
+
+	authURL, state, err := s.ssoService.AuthURL(ctx.Params("provider"), 0)
+	if err != nil {
+		return s.authURLError(ctx, op, err)
+	}
+
+	s.setStateCookie(ctx, state, time.Now().Add(time.Minute*time.Duration(s.conf.OIDCStateExpiresMinutes)))
+
+	return ctx.Redirect(authURL, fiber.StatusFound)
+}
+
+// CallbackHandler godoc
+//
+//	@Summary		Identity provider callback
+//	@Description	Complete sign in or linking of the provider and redirect to APP_URL/auth/sso.
+//	@Description	On success the refresh_token cookie is set and query has result=signed-in or result=linked,
+//	@Description	after signing in again the reauth cookie is set and query has result=reauthenticated,
+//	@Description	on failure query has error
+//	@Tags			auth
+//	@Param			provider	path		string			true	"Provider name"
+//	@Param			code		query		string			true	"Authorization code"
+//	@Param			state		query		string			true	"State"
+//	@Success		302			{object}	nil				"Redirect to the app"
+//	@Header			302			{string}	refresh_token	"Set refresh token in cookie to recreate access_token"
+//	@Header			302			{string}	reauth			"Set proof of signing in again in cookie to confirm actions"
+//	@Router			/auth/oidc/{provider}/callback [get]
+func (s *SSO) CallbackHandler(ctx *fiber.Ctx) error {
+	const op = "CallbackHandler"
+
+	state := ctx.Cookies(stateCookie)
+	s.setStateCookie(ctx, "", time.Now())
+
+	// the user has declined the consent or the provider has failed
+	if ctx.Query("error") != "" {
+		return s.redirect(ctx, "error", "provider_error")
+	}
+
+	result, err := s.ssoService.Callback(
+		ctx.UserContext(),
+		ctx.Params("provider"),
+		ctx.Query("code"),
+		ctx.Query("state"),
+		state,
+	)
+	if err != nil {
+		// rejected sign in attempts, the user is unknown to the audit log here
+		if errors.Is(err, ssoservice.ErrEmailNotVerified) ||
+			errors.Is(err, ssoservice.ErrUserDisabled) ||
+			errors.Is(err, ssoservice.ErrUserNotFound) {
+			s.auditService.Record(controller.AuditEvent(ctx, audit.ActionSignIn, audit.ResultFailure))
+		}
+
+		switch {
+		case errors.Is(err, ssoservice.ErrUnknownProvider), errors.Is(err, ssoservice.ErrInvalidState):
+			return s.redirect(ctx, "error", "invalid_state")
+		case errors.Is(err, ssoservice.ErrEmailNotVerified):
+			return s.redirect(ctx, "error", "email_not_verified")
+		case errors.Is(err, ssoservice.ErrUserDisabled):
+			return s.redirect(ctx, "error", "account_disabled")
+		case errors.Is(err, ssoservice.ErrUserNotFound):
+			return s.redirect(ctx, "error", "user_not_found")
+		case errors.Is(err, ssoservice.ErrIdentityLinked):
+			return s.redirect(ctx, "error", "identity_linked")
+		case errors.Is(err, ssoservice.ErrProviderAlreadyLinked):
+			return s.redirect(ctx, "error", "provider_already_linked")
+		case errors.Is(err, ssoservice.ErrNotFound):
+			return s.redirect(ctx, "error", "identity_not_linked")
+		}
+
+		logger.AddContext(ctx.UserContext(), s.pkg, op, err)
+
+		return s.redirect(ctx, "error", "server_error")
+	}
+
+	if result.Linked {
+		return s.redirect(ctx, "result", "linked")
+	}
+
+	if result.Reauthenticated {
+		token, expires := s.ssoService.ReauthToken(result.UserId)
+		ctx.Cookie(&fiber.Cookie{
+			Name:     controller.ReauthCookie,
+			Value:    token,
+			Path:     "/api/user",
+			HTTPOnly: true,
+			Secure:   s.conf.CookieSecure,
+			SameSite: s.conf.CookieSameSite,
+			Expires:  expires,
+		})
+
+		return s.redirect(ctx, "result", "reauthenticated")
+	}
+
+	// the app gets the access token with /auth/refresh-token, so it doesn't appear in the url
+	event := controller.AuditEvent(ctx, audit.ActionSignIn, audit.ResultSuccess)
+	event.UserId = result.UserId
+
+	if _, err := s.auth.SignIn(ctx, result.UserId); err != nil {
+		logger.AddContext(ctx.UserContext(), s.pkg, op, err)
+
+		event.Result = audit.ResultFailure
+		s.auditService.Record(event)
+
+		return s.redirect(ctx, "error", "server_error")
+	}
+
+	s.auditService.Record(event)
+
+	return s.redirect(ctx, "result", "signed-in")
+}
+
+// IdentitiesHandler godoc
+//
+//	@Summary		Linked identity providers
+//	@Description	List identity providers linked to the current user
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	[]sso.IdentityResponse	"Linked providers"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/identities [get]
+func (s *SSO) IdentitiesHandler(ctx *fiber.Ctx) error {
+	const op = "IdentitiesHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	identities, err := s.ssoService.Identities(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), s.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	data := make([]sso.IdentityResponse, 0, len(identities))
+	for _, i := range identities {
+		data = append(data, sso.IdentityResponse{
+			Id:        i.Id,
+			Provider:  i.Provider,
+			Email:     i.Email,
+			CreatedAt: i.CreatedAt,
+		})
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// LinkHandler godoc
+//
+//	@Summary		Link identity provider
+//	@Description	Start linking the provider to the current user, the app must open the returned url
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			provider		path		string					true	"Provider name"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	sso.AuthURLResponse		"Url of the provider"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Unknown provider"
+//	@Failure		500				{object}	entity.ErrorResponse	"Provider is unavailable"
+//	@Router			/user/identities/{provider} [post]
+func (s *SSO) LinkHandler(ctx *fiber.Ctx) error {
+	const op = "LinkHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	authURL, state, err := s.ssoService.AuthURL(ctx.Params("provider"), controller.RequestedUserId(ctx))
+	if err != nil {
+		return s.authURLError(ctx, op, err)
+	}
+
+	s.setStateCookie(ctx, state, time.Now().Add(time.Minute*time.Duration(s.conf.OIDCStateExpiresMinutes)))
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&sso.AuthURLResponse{URL: authURL})
+}
+
+// ReauthenticateHandler godoc
+//
+//	@Summary		Sign in with identity provider again
+//	@Description	Start signing in with the linked provider again, the app must open the returned url. After the
+//	@Description	callback changing the email and deleting the account don't require the password for 5 minutes
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			provider		path		string					true	"Provider name"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	sso.AuthURLResponse		"Url of the provider"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Unknown provider"
+//	@Failure		500				{object}	entity.ErrorResponse	"Provider is unavailable"
+//	@Router			/user/identities/{provider}/reauthenticate [post]
+func (s *SSO) ReauthenticateHandler(ctx *fiber.Ctx) error {
+	const op = "ReauthenticateHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	authURL, state, err := s.ssoService.ReauthURL(ctx.Params("provider"), controller.RequestedUserId(ctx))
+	if err != nil {
+		return s.authURLError(ctx, op, err)
+	}
+
+	s.setStateCookie(ctx, state, time.Now().Add(time.Minute*time.Duration(s.conf.OIDCStateExpiresMinutes)))
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&sso.AuthURLResponse{URL: authURL})
+}
+
+// UnlinkHandler godoc
+//
+//	@Summary		Unlink identity provider
+//	@Description	Unlink the identity provider from the current user
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Identity id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		409				{object}	entity.ErrorResponse	"The last provider while sign in with password is disabled"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/identities/{id} [delete]
+func (s *SSO) UnlinkHandler(ctx *fiber.Ctx) error {
+	const op = "UnlinkHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	identityId, err := ctx.ParamsInt("id")
+	if err != nil || identityId <= 0 {
+		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+	}
+
+	err = s.ssoService.Unlink(ctx.UserContext(), controller.RequestedUserId(ctx), int64(identityId))
+	if err != nil {
+		switch {
+		case errors.Is(err, ssoservice.ErrNotFound):
+			return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+		case errors.Is(err, ssoservice.ErrLastLoginMethod):
+			return ctx.Status(fiber.StatusConflict).JSON(
+				&entity.ErrorResponse{Message: controller.MessageLastLoginMethod},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), s.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// PasswordLoginHandler godoc
+//
+//	@Summary		Sign in with password
+//	@Description	Enable or disable sign in with password for the current user. It can be disabled only
+//	@Description	when at least one identity provider is linked
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string						true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			settings		body		sso.PasswordLoginRequest	true	"Password sign in settings"
+//	@Success		204				{object}	nil							"No content"
+//	@Failure		400				{object}	entity.ErrorResponse		"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse		"Unauthorized"
+//	@Failure		409				{object}	entity.ErrorResponse		"No linked identity providers"
+//	@Failure		500				{object}	entity.ErrorResponse		"Server error"
+//	@Router			/user/password-login [patch]
+func (s *SSO) PasswordLoginHandler(ctx *fiber.Ctx) error {
+	const op = "PasswordLoginHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r sso.PasswordLoginRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	err := s.ssoService.SetPasswordLogin(ctx.UserContext(), controller.RequestedUserId(ctx), r.Enabled)
+	if err != nil {
+		if errors.Is(err, ssoservice.ErrNoIdentity) {
+			return ctx.Status(fiber.StatusConflict).JSON(
+				&entity.ErrorResponse{Message: controller.MessageIdentityNotLinked},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), s.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+func (s *SSO) authURLError(ctx *fiber.Ctx, op string, err error) error {
+	controller.SetCommonHeaders(ctx)
+
+	if errors.Is(err, ssoservice.ErrUnknownProvider) {
+		return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+	}
+
+	logger.AddContext(ctx.UserContext(), s.pkg, op, err)
+
+	return ctx.Status(fiber.StatusInternalServerError).JSON(
+		&entity.ErrorResponse{Message: controller.MessageServerError},
+	)
+}
+
+// setStateCookie keeps the login state until the callback, lax same site is required
+// because the callback is a cross-site redirect from the provider
+func (s *SSO) setStateCookie(ctx *fiber.Ctx, state string, expires time.Time) {
+	ctx.Cookie(&fiber.Cookie{
+		Name:     stateCookie,
+		Value:    state,
+		Path:     "/api/auth/oidc",
+		HTTPOnly: true,
+		Secure:   s.conf.CookieSecure,
+		SameSite: fiber.CookieSameSiteLaxMode,
+		Expires:  expires,
+	})
+}
+
+func (s *SSO) redirect(ctx *fiber.Ctx, key, value string) error {
+	return ctx.Redirect(
+		strings.TrimSuffix(s.conf.AppURL, "/")+"/auth/sso?"+url.Values{key: {value}}.Encode(),
+		fiber.StatusFound,
+	)
+}
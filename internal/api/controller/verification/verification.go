@@ -0,0 +1,278 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/profile"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/email"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	verificationservice "github.com/albakov/go-cloud-file-storage/internal/service/verification"
+	"github.com/gofiber/fiber/v2"
+)
+
+type Verification struct {
+	pkg                 string
+	verificationService VerificationService
+	validator           Validator
+	loginGuard          LoginGuard
+}
+
+type VerificationService interface {
+	SendEmailVerification(ctx context.Context, userId int64) error
+	VerifyEmail(ctx context.Context, token string) error
+	SendPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	ConfirmEmailChange(ctx context.Context, token string) error
+	UnlockAccount(ctx context.Context, token string) (string, error)
+}
+
+type LoginGuard interface {
+	Unlock(ctx context.Context, email string) error
+}
+
+type Validator interface {
+	PasswordErrors(field, password string) []entity.FieldError
+}
+
+func New(verificationService VerificationService, validator Validator, loginGuard LoginGuard) *Verification {
+	return &Verification{
+		pkg:                 "verification",
+		verificationService: verificationService,
+		validator:           validator,
+		loginGuard:          loginGuard,
+	}
+}
+
+// SendEmailVerificationHandler godoc
+//
+//	@Summary		Send email verification
+//	@Description	Send a link to confirm the email of the current user
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		409				{object}	entity.ErrorResponse	"Email already verified"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/email/verification [post]
+func (v *Verification) SendEmailVerificationHandler(ctx *fiber.Ctx) error {
+	const op = "SendEmailVerificationHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	err := v.verificationService.SendEmailVerification(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		if errors.Is(err, verificationservice.ErrAlreadyVerified) {
+			return ctx.Status(fiber.StatusConflict).JSON(
+				&entity.ErrorResponse{Message: controller.MessageEmailAlreadyVerified},
+			)
+		}
+
+		logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// VerifyEmailHandler godoc
+//
+//	@Summary		Verify email
+//	@Description	Confirm email using the token from the verification email
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		profile.VerifyEmailRequest	true	"Token from the email"
+//	@Success		204		{object}	nil							"No content"
+//	@Failure		400		{object}	entity.ErrorResponse		"Token invalid or expired"
+//	@Router			/auth/verify-email [post]
+func (v *Verification) VerifyEmailHandler(ctx *fiber.Ctx) error {
+	const op = "VerifyEmailHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r profile.VerifyEmailRequest
+	if err := ctx.BodyParser(&r); err != nil || r.Token == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	err := v.verificationService.VerifyEmail(ctx.UserContext(), r.Token)
+	if err != nil {
+		if !errors.Is(err, verificationservice.ErrInvalidToken) {
+			logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+		}
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageTokenInvalid})
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// ForgotPasswordHandler godoc
+//
+//	@Summary		Forgot password
+//	@Description	Send a link to reset the password. Always succeeds, so registered emails are not revealed
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			email	body		profile.ForgotPasswordRequest	true	"Email of the account"
+//	@Success		204		{object}	nil								"No content"
+//	@Failure		400		{object}	entity.ErrorResponse			"Bad request"
+//	@Router			/auth/forgot-password [post]
+func (v *Verification) ForgotPasswordHandler(ctx *fiber.Ctx) error {
+	const op = "ForgotPasswordHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r profile.ForgotPasswordRequest
+	if err := ctx.BodyParser(&r); err != nil || r.Email == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	err := v.verificationService.SendPasswordReset(ctx.UserContext(), email.Normalize(r.Email))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// ResetPasswordHandler godoc
+//
+//	@Summary		Reset password
+//	@Description	Set a new password using the token from the password reset email. All sessions are signed out
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			credentials	body		profile.ResetPasswordRequest	true	"Token from the email and a new password"
+//	@Success		204			{object}	nil								"No content"
+//	@Failure		400			{object}	entity.ValidationErrorResponse	"Token invalid or expired, or password doesn't match the policy"
+//	@Router			/auth/reset-password [post]
+func (v *Verification) ResetPasswordHandler(ctx *fiber.Ctx) error {
+	const op = "ResetPasswordHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r profile.ResetPasswordRequest
+	if err := ctx.BodyParser(&r); err != nil || r.Token == "" || r.Password == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	if errs := v.validator.PasswordErrors("password", r.Password); len(errs) > 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(
+			&entity.ValidationErrorResponse{Message: controller.MessageValidationFailed, Errors: errs},
+		)
+	}
+
+	err := v.verificationService.ResetPassword(ctx.UserContext(), r.Token, r.Password)
+	if err != nil {
+		if !errors.Is(err, verificationservice.ErrInvalidToken) {
+			logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+		}
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageTokenInvalid})
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// ConfirmEmailChangeHandler godoc
+//
+//	@Summary		Confirm email change
+//	@Description	Set the new email using the token from the email change confirmation
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		profile.VerifyEmailRequest	true	"Token from the email"
+//	@Success		204		{object}	nil							"No content"
+//	@Failure		400		{object}	entity.ErrorResponse		"Token invalid or expired"
+//	@Failure		409		{object}	entity.ErrorResponse		"User already exists"
+//	@Router			/auth/confirm-email-change [post]
+func (v *Verification) ConfirmEmailChangeHandler(ctx *fiber.Ctx) error {
+	const op = "ConfirmEmailChangeHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r profile.VerifyEmailRequest
+	if err := ctx.BodyParser(&r); err != nil || r.Token == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	err := v.verificationService.ConfirmEmailChange(ctx.UserContext(), r.Token)
+	if err != nil {
+		if errors.Is(err, userservice.ErrAlreadyExists) {
+			return ctx.Status(fiber.StatusConflict).JSON(
+				&entity.ErrorResponse{Message: controller.MessageUserAlreadyExists},
+			)
+		}
+
+		if !errors.Is(err, verificationservice.ErrInvalidToken) {
+			logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+		}
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageTokenInvalid})
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// UnlockAccountHandler godoc
+//
+//	@Summary		Unlock account
+//	@Description	Unlock the account locked after too many failed sign in attempts using the token from the email
+//	@Tags			auth
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	body		profile.VerifyEmailRequest	true	"Token from the email"
+//	@Success		204		{object}	nil							"No content"
+//	@Failure		400		{object}	entity.ErrorResponse		"Token invalid or expired"
+//	@Failure		500		{object}	entity.ErrorResponse		"Server error"
+//	@Router			/auth/unlock-account [post]
+func (v *Verification) UnlockAccountHandler(ctx *fiber.Ctx) error {
+	const op = "UnlockAccountHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r profile.VerifyEmailRequest
+	if err := ctx.BodyParser(&r); err != nil || r.Token == "" {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	e, err := v.verificationService.UnlockAccount(ctx.UserContext(), r.Token)
+	if err != nil {
+		if !errors.Is(err, verificationservice.ErrInvalidToken) {
+			logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+		}
+
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageTokenInvalid})
+	}
+
+	if err := v.loginGuard.Unlock(ctx.UserContext(), email.Normalize(e)); err != nil {
+		logger.AddContext(ctx.UserContext(), v.pkg, op, err)
+
+		return ctx.Status(fiber.StatusInternalServerError).JSON(
+			&entity.ErrorResponse{Message: controller.MessageServerError},
+		)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
@@ -0,0 +1,438 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/webhook"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	webhookservice "github.com/albakov/go-cloud-file-storage/internal/service/webhook"
+	webhookstorage "github.com/albakov/go-cloud-file-storage/internal/storage/webhook"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+)
+
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+type Webhook struct {
+	pkg            string
+	webhookService WebhookService
+}
+
+type WebhookService interface {
+	Create(
+		ctx context.Context,
+		userId int64,
+		url string,
+		secret string,
+		types []string,
+		folder string,
+	) (string, webhookstorage.Webhook, error)
+	Webhooks(ctx context.Context, userId int64) ([]webhookstorage.Webhook, error)
+	Delete(ctx context.Context, userId, id int64) error
+	Enable(ctx context.Context, userId, id int64) (webhookstorage.Webhook, error)
+	Disable(ctx context.Context, userId, id int64) (webhookstorage.Webhook, error)
+	Deliveries(ctx context.Context, userId, id int64, page, perPage int) ([]webhookservice.DeliveryLog, int64, error)
+	Redeliver(ctx context.Context, userId, id, deliveryId int64) (webhookstorage.Delivery, error)
+}
+
+func New(webhookService WebhookService) *Webhook {
+	return &Webhook{
+		pkg:            "webhook",
+		webhookService: webhookService,
+	}
+}
+
+// IndexHandler godoc
+//
+//	@Summary		List webhooks
+//	@Description	List webhooks of the current user. Secrets are never returned again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	[]webhook.Response		"List of webhooks"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/webhooks [get]
+func (wh *Webhook) IndexHandler(ctx *fiber.Ctx) error {
+	const op = "IndexHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	webhooks, err := wh.webhookService.Webhooks(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		logger.AddContext(ctx.UserContext(), wh.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := make([]webhook.Response, 0, len(webhooks))
+	for _, w := range webhooks {
+		data = append(data, response(w))
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// StoreHandler godoc
+//
+//	@Summary		Create webhook
+//	@Description	Create a webhook receiving file events of the current user: file.created, file.updated, file.moved,
+//	@Description	file.deleted. Empty events subscribe it to all of them, the folder limits events to files inside it.
+//	@Description	Events are posted as JSON with X-Webhook-Event, X-Webhook-Delivery, X-Webhook-Timestamp and
+//	@Description	X-Webhook-Signature headers, the signature is "sha256=" and hex of HMAC-SHA256 of the timestamp,
+//	@Description	a dot and the body with the secret. The empty secret is generated, the secret is shown only once.
+//	@Description	Failed deliveries are retried with exponential backoff, the webhook is disabled after failures in a row
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			Authorization	header		string							true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Param			webhook			body		webhook.CreateRequest			true	"Webhook settings"
+//	@Success		201				{object}	webhook.CreateResponse			"Created webhook"
+//	@Failure		400				{object}	entity.ValidationErrorResponse	"Bad request"
+//	@Failure		401				{object}	entity.ErrorResponse			"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse			"Not available with an access token"
+//	@Failure		409				{object}	entity.ErrorResponse			"Too many webhooks"
+//	@Failure		500				{object}	entity.ErrorResponse			"Server error"
+//	@Router			/user/webhooks [post]
+func (wh *Webhook) StoreHandler(ctx *fiber.Ctx) error {
+	const op = "StoreHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	var r webhook.CreateRequest
+	if err := ctx.BodyParser(&r); err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	secret, w, err := wh.webhookService.Create(
+		ctx.UserContext(),
+		controller.RequestedUserId(ctx),
+		r.URL,
+		r.Secret,
+		r.Events,
+		r.Folder,
+	)
+	if err != nil {
+		if field, message, ok := invalidField(err); ok {
+			return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ValidationErrorResponse{
+				Message: controller.MessageValidationFailed,
+				Errors:  []entity.FieldError{{Field: field, Message: message}},
+			})
+		}
+
+		if errors.Is(err, webhookservice.ErrTooMany) {
+			return ctx.Status(fiber.StatusConflict).JSON(&entity.ErrorResponse{Message: controller.MessageTooManyWebhooks})
+		}
+
+		logger.AddContext(ctx.UserContext(), wh.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ctx.Status(fiber.StatusCreated)
+
+	return ctx.JSON(&webhook.CreateResponse{Response: response(w), Secret: secret})
+}
+
+// DeleteHandler godoc
+//
+//	@Summary		Delete webhook
+//	@Description	Delete webhook of the current user with its deliveries
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Webhook id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		204				{object}	nil						"No content"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/webhooks/{id} [delete]
+func (wh *Webhook) DeleteHandler(ctx *fiber.Ctx) error {
+	const op = "DeleteHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx, "id")
+	if !ok {
+		return notFound(ctx)
+	}
+
+	if err := wh.webhookService.Delete(ctx.UserContext(), controller.RequestedUserId(ctx), id); err != nil {
+		if errors.Is(err, webhookservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), wh.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	ctx.Status(fiber.StatusNoContent)
+
+	return nil
+}
+
+// EnableHandler godoc
+//
+//	@Summary		Enable webhook
+//	@Description	Enable the disabled webhook, its pending deliveries are attempted again
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Webhook id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	webhook.Response		"Enabled webhook"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/webhooks/{id}/enable [post]
+func (wh *Webhook) EnableHandler(ctx *fiber.Ctx) error {
+	return wh.setDisabled(ctx, "EnableHandler", wh.webhookService.Enable)
+}
+
+// DisableHandler godoc
+//
+//	@Summary		Disable webhook
+//	@Description	Disable the webhook, events aren't queued for it until it's enabled
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int						true	"Webhook id"
+//	@Param			Authorization	header		string					true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	webhook.Response		"Disabled webhook"
+//	@Failure		401				{object}	entity.ErrorResponse	"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse	"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse	"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse	"Server error"
+//	@Router			/user/webhooks/{id}/disable [post]
+func (wh *Webhook) DisableHandler(ctx *fiber.Ctx) error {
+	return wh.setDisabled(ctx, "DisableHandler", wh.webhookService.Disable)
+}
+
+// DeliveriesHandler godoc
+//
+//	@Summary		Webhook deliveries
+//	@Description	List deliveries of the webhook with their attempts, newest first.
+//	@Description	Statuses: pending, succeeded, failed. Finished deliveries are kept for WEBHOOKS_RETENTION_DAYS
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int							true	"Webhook id"
+//	@Param			page			query		int							false	"Page, starts from 1"
+//	@Param			per_page		query		int							false	"Deliveries per page, 20 by default, 100 at most"
+//	@Param			Authorization	header		string						true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		200				{object}	webhook.DeliveriesResponse	"Page of deliveries"
+//	@Failure		401				{object}	entity.ErrorResponse		"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse		"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse		"Not found"
+//	@Failure		500				{object}	entity.ErrorResponse		"Server error"
+//	@Router			/user/webhooks/{id}/deliveries [get]
+func (wh *Webhook) DeliveriesHandler(ctx *fiber.Ctx) error {
+	const op = "DeliveriesHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx, "id")
+	if !ok {
+		return notFound(ctx)
+	}
+
+	page := ctx.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+
+	perPage := ctx.QueryInt("per_page", defaultPerPage)
+	if perPage < 1 || perPage > maxPerPage {
+		perPage = defaultPerPage
+	}
+
+	logs, total, err := wh.webhookService.Deliveries(ctx.UserContext(), controller.RequestedUserId(ctx), id, page, perPage)
+	if err != nil {
+		if errors.Is(err, webhookservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), wh.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := webhook.DeliveriesResponse{
+		Deliveries: make([]webhook.DeliveryResponse, 0, len(logs)),
+		Total:      total,
+		Page:       page,
+		PerPage:    perPage,
+	}
+	for _, l := range logs {
+		d := deliveryResponse(l.Delivery)
+		for _, a := range l.Attempts {
+			d.Attempts = append(d.Attempts, webhook.AttemptResponse{
+				StatusCode: a.StatusCode,
+				Error:      a.Error,
+				DurationMs: a.DurationMs,
+				CreatedAt:  a.CreatedAt,
+			})
+		}
+
+		data.Deliveries = append(data.Deliveries, d)
+	}
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+// RedeliverHandler godoc
+//
+//	@Summary		Redeliver webhook event
+//	@Description	Queue the payload of the delivery again as a new delivery, the event keeps its id
+//	@Tags			user
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int							true	"Webhook id"
+//	@Param			deliveryId		path		int							true	"Delivery id"
+//	@Param			Authorization	header		string						true	"Authorization Bearer <ACCESS_TOKEN>"
+//	@Success		202				{object}	webhook.DeliveryResponse	"Queued delivery"
+//	@Failure		401				{object}	entity.ErrorResponse		"Unauthorized"
+//	@Failure		403				{object}	entity.ErrorResponse		"Not available with an access token"
+//	@Failure		404				{object}	entity.ErrorResponse		"Not found"
+//	@Failure		409				{object}	entity.ErrorResponse		"Webhook is disabled"
+//	@Failure		500				{object}	entity.ErrorResponse		"Server error"
+//	@Router			/user/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (wh *Webhook) RedeliverHandler(ctx *fiber.Ctx) error {
+	const op = "RedeliverHandler"
+
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx, "id")
+	if !ok {
+		return notFound(ctx)
+	}
+
+	deliveryId, ok := requestedId(ctx, "deliveryId")
+	if !ok {
+		return notFound(ctx)
+	}
+
+	d, err := wh.webhookService.Redeliver(ctx.UserContext(), controller.RequestedUserId(ctx), id, deliveryId)
+	if err != nil {
+		if errors.Is(err, webhookservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		if errors.Is(err, webhookservice.ErrDisabled) {
+			return ctx.Status(fiber.StatusConflict).JSON(&entity.ErrorResponse{Message: controller.MessageWebhookDisabled})
+		}
+
+		logger.AddContext(ctx.UserContext(), wh.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := deliveryResponse(d)
+
+	ctx.Status(fiber.StatusAccepted)
+
+	return ctx.JSON(&data)
+}
+
+func (wh *Webhook) setDisabled(
+	ctx *fiber.Ctx,
+	op string,
+	set func(ctx context.Context, userId, id int64) (webhookstorage.Webhook, error),
+) error {
+	controller.SetCommonHeaders(ctx)
+
+	id, ok := requestedId(ctx, "id")
+	if !ok {
+		return notFound(ctx)
+	}
+
+	w, err := set(ctx.UserContext(), controller.RequestedUserId(ctx), id)
+	if err != nil {
+		if errors.Is(err, webhookservice.ErrNotFound) {
+			return notFound(ctx)
+		}
+
+		logger.AddContext(ctx.UserContext(), wh.pkg, op, err)
+
+		return serverError(ctx)
+	}
+
+	data := response(w)
+
+	ctx.Status(fiber.StatusOK)
+
+	return ctx.JSON(&data)
+}
+
+func requestedId(ctx *fiber.Ctx, key string) (int64, bool) {
+	id, err := ctx.ParamsInt(key)
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+
+	return int64(id), true
+}
+
+// invalidField returns the field and the message of the validation error of the webhook
+func invalidField(err error) (string, string, bool) {
+	switch {
+	case errors.Is(err, webhookservice.ErrInvalidURL):
+		return "url", "must be an http or https url", true
+	case errors.Is(err, webhookservice.ErrInvalidEvents):
+		return "events", "must be file.created, file.updated, file.moved or file.deleted", true
+	case errors.Is(err, webhookservice.ErrInvalidFolder):
+		return "folder", "must be an absolute path", true
+	case errors.Is(err, webhookservice.ErrInvalidSecret):
+		return "secret", "must be from 16 to 128 characters", true
+	default:
+		return "", "", false
+	}
+}
+
+func response(w webhookstorage.Webhook) webhook.Response {
+	return webhook.Response{
+		Id:         w.Id,
+		URL:        w.URL,
+		Events:     strings.Split(w.Events, ","),
+		Folder:     w.Folder,
+		Failures:   w.Failures,
+		DisabledAt: w.DisabledAt.String,
+		CreatedAt:  w.CreatedAt,
+	}
+}
+
+func deliveryResponse(d webhookstorage.Delivery) webhook.DeliveryResponse {
+	return webhook.DeliveryResponse{
+		Id:            d.Id,
+		EventId:       d.EventId,
+		EventType:     d.EventType,
+		Status:        d.Status,
+		Attempts:      []webhook.AttemptResponse{},
+		NextAttemptAt: d.NextAttemptAt,
+		Payload:       d.Payload,
+		CreatedAt:     d.CreatedAt,
+	}
+}
+
+func notFound(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusNotFound).JSON(&entity.ErrorResponse{Message: controller.MessageNotFound})
+}
+
+func serverError(ctx *fiber.Ctx) error {
+	return ctx.Status(fiber.StatusInternalServerError).JSON(&entity.ErrorResponse{Message: controller.MessageServerError})
+}
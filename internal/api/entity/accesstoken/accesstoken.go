@@ -0,0 +1,23 @@
+package accesstoken
+
+type CreateRequest struct {
+	Name      string   `json:"name" example:"ci"`
+	Scopes    []string `json:"scopes" example:"read,write"`
+	Folder    string   `json:"folder" example:"/ci/artifacts/"`
+	ExpiresAt string   `json:"expires_at" example:"2027-01-01T00:00:00Z"`
+} // @name CreateAccessTokenRequest
+
+type Response struct {
+	Id         int64    `json:"id" example:"1"`
+	Name       string   `json:"name" example:"ci"`
+	Scopes     []string `json:"scopes" example:"read,write"`
+	Folder     string   `json:"folder" example:"/ci/artifacts"`
+	ExpiresAt  string   `json:"expires_at" example:"2027-01-01 00:00:00"`
+	LastUsedAt string   `json:"last_used_at" example:"2026-10-18 09:00:00"`
+	CreatedAt  string   `json:"created_at" example:"2026-10-18 08:00:00"`
+} // @name AccessTokenResponse
+
+type CreateResponse struct {
+	Response
+	Token string `json:"token" example:"cfs_pat_secret"`
+} // @name CreateAccessTokenResponse
@@ -0,0 +1,21 @@
+package activity
+
+type EventResponse struct {
+	Id         int64  `json:"id" example:"1"`
+	UserId     int64  `json:"user_id" example:"1"`
+	Email      string `json:"email" example:"user@example.com"`
+	Action     string `json:"action" example:"resource.move"`
+	Result     string `json:"result" example:"success"`
+	Ip         string `json:"ip" example:"127.0.0.1"`
+	UserAgent  string `json:"user_agent" example:"Mozilla/5.0"`
+	Path       string `json:"path" example:"/folder/file.txt"`
+	TargetPath string `json:"target_path" example:"/another-folder/file.txt"`
+	CreatedAt  string `json:"created_at" example:"2026-10-18 09:00:00"`
+} // @name ActivityEventResponse
+
+type EventsResponse struct {
+	Events  []EventResponse `json:"events"`
+	Total   int64           `json:"total" example:"1"`
+	Page    int             `json:"page" example:"1"`
+	PerPage int             `json:"per_page" example:"20"`
+} // @name ActivityEventsResponse
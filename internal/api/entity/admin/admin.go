@@ -0,0 +1,38 @@
+package admin
+
+type UserResponse struct {
+	Id                    int64  `json:"id" example:"1"`
+	Email                 string `json:"email" example:"user@example.com"`
+	EmailVerified         bool   `json:"email_verified" example:"true"`
+	Role                  string `json:"role" example:"user"`
+	QuotaBytes            *int64 `json:"quota_bytes" example:"1073741824"`
+	Disabled              bool   `json:"disabled" example:"false"`
+	DisabledAt            string `json:"disabled_at" example:""`
+	PasswordLoginDisabled bool   `json:"password_login_disabled" example:"false"`
+	CreatedAt             string `json:"created_at" example:"2026-10-18 08:00:00"`
+} // @name AdminUserResponse
+
+type UsersResponse struct {
+	Users   []UserResponse `json:"users"`
+	Total   int64          `json:"total" example:"1"`
+	Page    int            `json:"page" example:"1"`
+	PerPage int            `json:"per_page" example:"20"`
+} // @name AdminUsersResponse
+
+type UsageResponse struct {
+	UsedBytes  int64 `json:"used_bytes" example:"1048576"`
+	Objects    int64 `json:"objects" example:"10"`
+	QuotaBytes int64 `json:"quota_bytes" example:"1073741824"`
+} // @name AdminUsageResponse
+
+type RoleRequest struct {
+	Role string `json:"role" example:"admin"`
+} // @name AdminRoleRequest
+
+type QuotaRequest struct {
+	QuotaBytes *int64 `json:"quota_bytes" example:"1073741824"`
+} // @name AdminQuotaRequest
+
+type JobsResponse struct {
+	Jobs []string `json:"jobs" example:"expired-sessions,expired-tokens,orphaned-folders"`
+} // @name AdminJobsResponse
@@ -0,0 +1,18 @@
+package changes
+
+type ChangeResponse struct {
+	Cursor    int64  `json:"cursor" example:"42"`
+	Action    string `json:"action" example:"move"`
+	Path      string `json:"path" example:"/folder2/file.txt"`
+	FromPath  string `json:"from_path,omitempty" example:"/folder1/file.txt"`
+	Type      string `json:"type" example:"FILE"`
+	ETag      string `json:"etag,omitempty" example:"9a0364b9e99bb480dd25e1f0284c8555"`
+	Size      int64  `json:"size" example:"123456789"`
+	CreatedAt string `json:"created_at" example:"2026-10-18 09:00:00"`
+} // @name ChangeResponse
+
+type PageResponse struct {
+	Changes []ChangeResponse `json:"changes"`
+	Cursor  int64            `json:"cursor" example:"42"`
+	HasMore bool             `json:"has_more" example:"false"`
+} // @name ChangesPageResponse
@@ -3,3 +3,13 @@ package entity
 type ErrorResponse struct {
 	Message string `json:"message" example:"error message"`
 } // @name ErrorResponse
+
+type FieldError struct {
+	Field   string `json:"field" example:"password"`
+	Message string `json:"message" example:"must be at least 8 characters long"`
+} // @name FieldError
+
+type ValidationErrorResponse struct {
+	Message string       `json:"message" example:"Validation failed"`
+	Errors  []FieldError `json:"errors"`
+} // @name ValidationErrorResponse
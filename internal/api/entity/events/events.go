@@ -0,0 +1,11 @@
+package events
+
+type EventResponse struct {
+	Id        string            `json:"id" example:"0b7d3f2e-6c1a-4f59-9d2a-1f0e8c4b5a77"`
+	Type      string            `json:"type" example:"file.moved"`
+	Path      string            `json:"path,omitempty" example:"/folder2/file.txt"`
+	FromPath  string            `json:"from_path,omitempty" example:"/folder1/file.txt"`
+	Cursor    int64             `json:"cursor,omitempty" example:"42"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt string            `json:"created_at" example:"2026-10-18 09:00:00"`
+} // @name EventResponse
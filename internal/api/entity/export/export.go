@@ -0,0 +1,19 @@
+package export
+
+type CreateRequest struct {
+	Paths  []string `json:"paths" example:"/docs/,/photos/cat.jpg"`
+	Format string   `json:"format" example:"zip"`
+} // @name CreateExportRequest
+
+type Response struct {
+	Id          int64    `json:"id" example:"1"`
+	JobId       int64    `json:"job_id" example:"12"`
+	Format      string   `json:"format" example:"zip"`
+	Paths       []string `json:"paths" example:"/docs/,/photos/cat.jpg"`
+	Status      string   `json:"status" example:"ready"`
+	Size        int64    `json:"size" example:"1048576"`
+	DownloadURL string   `json:"download_url" example:"https://storage.example.com/api/exports/1/download?expires=1792396800&signature=9c1f"`
+	CreatedAt   string   `json:"created_at" example:"2026-10-18 09:00:00"`
+	FinishedAt  string   `json:"finished_at" example:"2026-10-18 09:01:00"`
+	ExpiresAt   string   `json:"expires_at" example:"2026-10-19 09:01:00"`
+} // @name ExportResponse
@@ -0,0 +1,25 @@
+package health
+
+type StatusResponse struct {
+	Status string `json:"status" example:"up"`
+} // @name HealthStatusResponse
+
+type CheckResponse struct {
+	Name       string `json:"name" example:"database"`
+	Status     string `json:"status" example:"up"`
+	Error      string `json:"error,omitempty" example:""` // unavailable or timeout, details are only logged
+	DurationMs int64  `json:"duration_ms" example:"3"`
+} // @name HealthCheckResponse
+
+type ReadinessResponse struct {
+	Status string          `json:"status" example:"up"`
+	Checks []CheckResponse `json:"checks"`
+} // @name ReadinessResponse
+
+type VersionResponse struct {
+	Version   string `json:"version" example:"v1.2.0"`
+	Revision  string `json:"revision" example:"4ec4faf0c1d2"`
+	Time      string `json:"time" example:"2026-10-18T08:00:00Z"`
+	Modified  bool   `json:"modified" example:"false"`
+	GoVersion string `json:"go_version" example:"go1.24.3"`
+} // @name VersionResponse
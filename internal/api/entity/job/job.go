@@ -0,0 +1,29 @@
+package job
+
+import "encoding/json"
+
+type ProgressResponse struct {
+	Done  int64 `json:"done" example:"120"`
+	Total int64 `json:"total" example:"400"`
+} // @name JobProgressResponse
+
+type Response struct {
+	Id          int64            `json:"id" example:"1"`
+	Type        string           `json:"type" example:"resource.delete"`
+	Status      string           `json:"status" example:"running"`
+	Progress    ProgressResponse `json:"progress"`
+	Result      json.RawMessage  `json:"result,omitempty" swaggertype:"object"`
+	Error       string           `json:"error" example:""`
+	Attempts    int              `json:"attempts" example:"1"`
+	MaxAttempts int              `json:"max_attempts" example:"3"`
+	CreatedAt   string           `json:"created_at" example:"2026-10-18 09:00:00"`
+	StartedAt   string           `json:"started_at" example:"2026-10-18 09:00:01"`
+	FinishedAt  string           `json:"finished_at" example:""`
+} // @name JobResponse
+
+type JobsResponse struct {
+	Jobs    []Response `json:"jobs"`
+	Total   int64      `json:"total" example:"1"`
+	Page    int        `json:"page" example:"1"`
+	PerPage int        `json:"per_page" example:"20"`
+} // @name JobsResponse
@@ -15,9 +15,38 @@ type LoginResponse struct {
 } // @name LoginResponse
 
 type ProfileResponse struct {
-	Email string `json:"email" example:"user@example.com"`
+	Email         string `json:"email" example:"user@example.com"`
+	EmailVerified bool   `json:"email_verified" example:"true"`
+	Role          string `json:"role" example:"user"`
 } // @name ProfileResponse
 
 type RefreshAccessTokenResponse struct {
 	AccessToken string `json:"access_token" example:"secret-access-token"`
 } // @name RefreshAccessTokenResponse
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" example:"secret-token"`
+} // @name VerifyEmailRequest
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" example:"user@example.com"`
+} // @name ForgotPasswordRequest
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" example:"secret-token"`
+	Password string `json:"password" example:"new-secret"`
+} // @name ResetPasswordRequest
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" example:"secret"`
+	NewPassword     string `json:"new_password" example:"new-secret"`
+} // @name ChangePasswordRequest
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" example:"new-user@example.com"`
+	Password string `json:"password" example:"secret"`
+} // @name ChangeEmailRequest
+
+type DeleteAccountRequest struct {
+	Password string `json:"password" example:"secret"`
+} // @name DeleteAccountRequest
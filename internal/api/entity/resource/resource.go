@@ -10,8 +10,17 @@ type Response struct {
 	Name string `json:"name" example:"folder2"`
 	Size int64  `json:"size" example:"123456789"`
 	Type string `json:"type" example:"DIRECTORY"`
+	ETag string `json:"etag,omitempty" example:"9a0364b9e99bb480dd25e1f0284c8555"`
 } // @name Response
 
+// ConflictResponse lists files which don't have the expected etag, files stored before the conflict
+// was found during the upload are listed too
+type ConflictResponse struct {
+	Message   string     `json:"message" example:"Files have been changed by another client"`
+	Conflicts []string   `json:"conflicts" example:"/folder1/file.txt"`
+	Stored    []Response `json:"stored"`
+} // @name ConflictResponse
+
 type Path struct {
 	IsDirectory  bool
 	OriginalPath string // requested path from client
@@ -0,0 +1,18 @@
+package s3key
+
+type CreateRequest struct {
+	Name string `json:"name" example:"backup"`
+} // @name CreateS3KeyRequest
+
+type Response struct {
+	Id          int64  `json:"id" example:"1"`
+	Name        string `json:"name" example:"backup"`
+	AccessKeyId string `json:"access_key_id" example:"CFSJ6Q2ZK7W3X4N5B2LA"`
+	LastUsedAt  string `json:"last_used_at" example:"2026-10-18 09:00:00"`
+	CreatedAt   string `json:"created_at" example:"2026-10-18 08:00:00"`
+} // @name S3KeyResponse
+
+type CreateResponse struct {
+	Response
+	SecretAccessKey string `json:"secret_access_key" example:"wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"`
+} // @name CreateS3KeyResponse
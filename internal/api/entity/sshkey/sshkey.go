@@ -0,0 +1,15 @@
+package sshkey
+
+type CreateRequest struct {
+	Name      string `json:"name" example:"laptop"`
+	PublicKey string `json:"public_key" example:"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl user@laptop"`
+} // @name CreateSSHKeyRequest
+
+type Response struct {
+	Id          int64  `json:"id" example:"1"`
+	Name        string `json:"name" example:"laptop"`
+	PublicKey   string `json:"public_key" example:"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl"`
+	Fingerprint string `json:"fingerprint" example:"SHA256:uNiVztksCsDhcc0u9e8BujQXVUpKZIDTMczCvj3tD2s"`
+	LastUsedAt  string `json:"last_used_at" example:"2026-10-18 09:00:00"`
+	CreatedAt   string `json:"created_at" example:"2026-10-18 08:00:00"`
+} // @name SSHKeyResponse
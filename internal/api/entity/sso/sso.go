@@ -0,0 +1,16 @@
+package sso
+
+type AuthURLResponse struct {
+	URL string `json:"url" example:"https://idp.example.com/authorize?client_id=..."`
+} // @name AuthURLResponse
+
+type IdentityResponse struct {
+	Id        int64  `json:"id" example:"1"`
+	Provider  string `json:"provider" example:"company"`
+	Email     string `json:"email" example:"user@company.com"`
+	CreatedAt string `json:"created_at" example:"2026-10-18 08:00:00"`
+} // @name IdentityResponse
+
+type PasswordLoginRequest struct {
+	Enabled bool `json:"enabled" example:"false"`
+} // @name PasswordLoginRequest
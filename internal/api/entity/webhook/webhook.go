@@ -0,0 +1,48 @@
+package webhook
+
+type CreateRequest struct {
+	URL    string   `json:"url" example:"https://example.com/hooks/storage"`
+	Secret string   `json:"secret" example:""`
+	Events []string `json:"events" example:"file.created,file.deleted"`
+	Folder string   `json:"folder" example:"/docs"`
+} // @name CreateWebhookRequest
+
+type Response struct {
+	Id         int64    `json:"id" example:"1"`
+	URL        string   `json:"url" example:"https://example.com/hooks/storage"`
+	Events     []string `json:"events" example:"file.created,file.deleted"`
+	Folder     string   `json:"folder" example:"/docs"`
+	Failures   int      `json:"failures" example:"0"`
+	DisabledAt string   `json:"disabled_at" example:""`
+	CreatedAt  string   `json:"created_at" example:"2026-10-18 08:00:00"`
+} // @name WebhookResponse
+
+type CreateResponse struct {
+	Response
+	Secret string `json:"secret" example:"3f1c0b9e6a5d4c2b8e7f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c5d6e7f8a9b0c1d"`
+} // @name CreateWebhookResponse
+
+type AttemptResponse struct {
+	StatusCode int    `json:"status_code" example:"500"`
+	Error      string `json:"error" example:"unexpected response status 500 Internal Server Error"`
+	DurationMs int64  `json:"duration_ms" example:"120"`
+	CreatedAt  string `json:"created_at" example:"2026-10-18 09:00:00"`
+} // @name WebhookAttemptResponse
+
+type DeliveryResponse struct {
+	Id            int64             `json:"id" example:"1"`
+	EventId       string            `json:"event_id" example:"0b6f1d2e-8c1a-4c52-9a57-3f1e2d4c5b6a"`
+	EventType     string            `json:"event_type" example:"file.created"`
+	Status        string            `json:"status" example:"pending"`
+	Attempts      []AttemptResponse `json:"attempts"`
+	NextAttemptAt string            `json:"next_attempt_at" example:"2026-10-18 09:01:00"`
+	Payload       string            `json:"payload" example:"{\"id\":\"0b6f1d2e-8c1a-4c52-9a57-3f1e2d4c5b6a\",\"type\":\"file.created\"}"`
+	CreatedAt     string            `json:"created_at" example:"2026-10-18 09:00:00"`
+} // @name WebhookDeliveryResponse
+
+type DeliveriesResponse struct {
+	Deliveries []DeliveryResponse `json:"deliveries"`
+	Total      int64              `json:"total" example:"1"`
+	Page       int                `json:"page" example:"1"`
+	PerPage    int                `json:"per_page" example:"20"`
+} // @name WebhookDeliveriesResponse
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/accesslog"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/metrics"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/requestid"
+	"github.com/albakov/go-cloud-file-storage/internal/api/middleware/tracing"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/gofiber/fiber/v2"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+)
+
+// Gateway is the S3-compatible server, listening on its own address, so S3 clients get the bucket
+// at the root of the URL
+type Gateway struct {
+	app  *fiber.App
+	conf *config.Config
+	// cancels the parent context of all requests, if they don't finish in time on shutdown
+	cancelRequests context.CancelFunc
+}
+
+func MustNewGateway(conf *config.Config, services *Services) *Gateway {
+	app := fiber.New(withProxy(conf, fiber.Config{
+		BodyLimit:             conf.ApiFileUploadMaxSize * 1024 * 1024,
+		DisableStartupMessage: true,
+	}))
+
+	requestsCtx, cancelRequests := context.WithCancel(context.Background())
+	app.Use(func(ctx *fiber.Ctx) error {
+		ctx.SetUserContext(requestsCtx)
+
+		return ctx.Next()
+	})
+
+	app.Use(requestid.New(), tracing.New(), accesslog.New())
+
+	if conf.MetricsEnabled {
+		app.Use(metrics.New(services.Metrics))
+	}
+
+	gatewayCnt := gateway.New(
+		conf,
+		services.Gateway,
+		services.S3Key,
+		services.User,
+		services.Quota,
+		services.Audit,
+		services.Metrics,
+	)
+
+	// every request is signed, buckets and keys are in the path
+	app.Use(gatewayCnt.Authenticated, gatewayCnt.Handler)
+
+	return &Gateway{
+		app:            app,
+		conf:           conf,
+		cancelRequests: cancelRequests,
+	}
+}
+
+func (gw *Gateway) Start() {
+	go func() {
+		slog.Info("S3 gateway listening", "addr", gw.conf.S3GatewayAddr)
+
+		err := gw.app.Listen(gw.conf.S3GatewayAddr)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// Serve serves requests accepted by the listener until Shutdown
+func (gw *Gateway) Serve(ln net.Listener) error {
+	return gw.app.Listener(ln)
+}
+
+// Shutdown stops accepting connections and waits for in-flight requests until the context is done
+func (gw *Gateway) Shutdown(ctx context.Context) error {
+	err := gw.app.ShutdownWithContext(ctx)
+
+	gw.cancelRequests()
+
+	if err != nil {
+		return logger.Error("api.Gateway", "Shutdown", err)
+	}
+
+	slog.Info("S3 Gateway Shutdown")
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package accesslog
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/gofiber/fiber/v2"
+	"log/slog"
+	"time"
+)
+
+// New logs every request with route, status and latency, must be used after requestid.New,
+// so the record carries the request id. user_id is added if the request is authenticated
+func New() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		started := time.Now()
+
+		err := ctx.Next()
+
+		status := controller.ResponseStatus(ctx, err)
+
+		// route pattern instead of the path, so paths with ids are grouped
+		attrs := []any{
+			"method", ctx.Method(),
+			"route", ctx.Route().Path,
+			"status", status,
+			"latency_ms", time.Since(started).Milliseconds(),
+			"ip", ctx.IP(),
+		}
+
+		if userId, ok := ctx.Locals("user_id").(int64); ok {
+			attrs = append(attrs, "user_id", userId)
+		}
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		slog.Log(ctx.UserContext(), level, "request", attrs...)
+
+		return err
+	}
+}
@@ -1,8 +1,11 @@
 package authenticated
 
 import (
+	"context"
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
 	"github.com/golang-jwt/jwt/v5"
 	"strconv"
 	"strings"
@@ -14,18 +17,31 @@ type AuthService interface {
 	ValidateAccessToken(tokenStr string) (*jwt.Token, error)
 }
 
+type AccessTokenService interface {
+	ValidateToken(ctx context.Context, token string) (accesstoken.Token, error)
+}
+
 type Authenticated struct {
-	authService AuthService
+	authService        AuthService
+	accessTokenService AccessTokenService
 }
 
-func New(authService AuthService) *Authenticated {
+func New(authService AuthService, accessTokenService AccessTokenService) *Authenticated {
 	return &Authenticated{
-		authService: authService,
+		authService:        authService,
+		accessTokenService: accessTokenService,
 	}
 }
 
+// Authenticated accepts both JWT access tokens and personal access tokens
 func (a *Authenticated) Authenticated(ctx *fiber.Ctx) error {
-	t, err := a.authService.ValidateAccessToken(strings.TrimPrefix(ctx.Get("Authorization"), "Bearer "))
+	bearer := strings.TrimPrefix(ctx.Get("Authorization"), "Bearer ")
+
+	if accesstoken.IsAccessToken(bearer) {
+		return a.personalAccessToken(ctx, bearer)
+	}
+
+	t, err := a.authService.ValidateAccessToken(bearer)
 	if err != nil || !t.Valid {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
 	}
@@ -41,6 +57,56 @@ func (a *Authenticated) Authenticated(ctx *fiber.Ctx) error {
 	}
 
 	ctx.Locals("user_id", userId)
+	ctx.SetUserContext(logger.With(ctx.UserContext(), "user_id", userId))
+
+	return ctx.Next()
+}
+
+// QueryToken takes the token from the access_token query parameter, if the Authorization header isn't set.
+// Browsers can't set headers of EventSource and WebSocket requests, so it's used only for their endpoints
+func (a *Authenticated) QueryToken(ctx *fiber.Ctx) error {
+	if token := ctx.Query("access_token"); token != "" && ctx.Get("Authorization") == "" {
+		ctx.Request().Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return ctx.Next()
+}
+
+// RequireScope allows requests authenticated with a session or with a personal access token having the scope
+func (a *Authenticated) RequireScope(scope string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		t, ok := controller.RequestedAccessToken(ctx)
+		if ok && !t.HasScope(scope) {
+			return ctx.Status(fiber.StatusForbidden).JSON(
+				&entity.ErrorResponse{Message: controller.MessageInsufficientScope},
+			)
+		}
+
+		return ctx.Next()
+	}
+}
+
+// SessionOnly rejects requests authenticated with a personal access token,
+// used for account management which must not be available to scripts
+func (a *Authenticated) SessionOnly(ctx *fiber.Ctx) error {
+	if _, ok := controller.RequestedAccessToken(ctx); ok {
+		return ctx.Status(fiber.StatusForbidden).JSON(
+			&entity.ErrorResponse{Message: controller.MessageSessionRequired},
+		)
+	}
+
+	return ctx.Next()
+}
+
+func (a *Authenticated) personalAccessToken(ctx *fiber.Ctx, bearer string) error {
+	t, err := a.accessTokenService.ValidateToken(ctx.UserContext(), bearer)
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
+	}
+
+	ctx.Locals("user_id", t.UserId)
+	ctx.SetUserContext(logger.With(ctx.UserContext(), "user_id", t.UserId))
+	ctx.Locals("access_token", t)
 
 	return ctx.Next()
 }
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+	"time"
+)
+
+type Metrics interface {
+	ObserveRequest(method, route string, status int, duration time.Duration)
+}
+
+// New records count and duration of every request. Requests are labeled with the route pattern
+// (e.g. /api/admin/users/:id) instead of the path, so the number of label values stays bounded
+func New(metrics Metrics) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		started := time.Now()
+
+		err := ctx.Next()
+
+		metrics.ObserveRequest(
+			ctx.Method(),
+			ctx.Route().Path,
+			controller.ResponseStatus(ctx, err),
+			time.Since(started),
+		)
+
+		return err
+	}
+}
+
+// Token allows scraping only with "Authorization: Bearer <token>", empty token allows everyone
+func Token(token string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		if token == "" {
+			return ctx.Next()
+		}
+
+		requested, ok := strings.CutPrefix(ctx.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(requested), []byte(token)) != 1 {
+			return ctx.SendStatus(fiber.StatusUnauthorized)
+		}
+
+		return ctx.Next()
+	}
+}
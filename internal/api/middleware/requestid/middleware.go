@@ -0,0 +1,46 @@
+package requestid
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+const (
+	Header = fiber.HeaderXRequestID
+
+	// maxLength longer ids from clients are replaced, so they can't flood the logs
+	maxLength = 128
+)
+
+// New propagates X-Request-ID of the request or generates a new one. The id is returned in the response
+// header and added to every record logged with ctx.UserContext()
+func New() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		id := ctx.Get(Header)
+		if !valid(id) {
+			id = uuid.NewString()
+		}
+
+		ctx.Set(Header, id)
+		ctx.Locals("request_id", id)
+		ctx.SetUserContext(logger.With(ctx.UserContext(), "request_id", id))
+
+		return ctx.Next()
+	}
+}
+
+// valid allows only printable ASCII ids without spaces
+func valid(id string) bool {
+	if id == "" || len(id) > maxLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+
+	return true
+}
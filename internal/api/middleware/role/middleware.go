@@ -0,0 +1,44 @@
+package role
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+type Role struct {
+	userService UserService
+}
+
+func New(userService UserService) *Role {
+	return &Role{
+		userService: userService,
+	}
+}
+
+// Require allows the request only for enabled users with the role. The role is read from the db
+// on every request, so revoked roles take effect immediately. Must be used after authenticated.Authenticated
+func (r *Role) Require(role string) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		us, err := r.userService.UserById(ctx.UserContext(), controller.RequestedUserId(ctx))
+		if err != nil || us.DisabledAt.Valid {
+			return ctx.Status(fiber.StatusUnauthorized).JSON(
+				&entity.ErrorResponse{Message: controller.MessageUnauthorized},
+			)
+		}
+
+		if us.Role != role {
+			return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageForbidden})
+		}
+
+		ctx.Locals("user_role", us.Role)
+
+		return ctx.Next()
+	}
+}
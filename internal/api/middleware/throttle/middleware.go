@@ -0,0 +1,71 @@
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+)
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error)
+}
+
+type Throttle struct {
+	pkg     string
+	limiter Limiter
+}
+
+func New(limiter Limiter) *Throttle {
+	return &Throttle{
+		pkg:     "throttle",
+		limiter: limiter,
+	}
+}
+
+// ByIP limits requests per client ip, each name has its own budget
+func (t *Throttle) ByIP(name string, limit ratelimit.Limit) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return t.handle(ctx, fmt.Sprintf("%s:ip:%s", name, ctx.IP()), limit)
+	}
+}
+
+// ByUser limits requests per user, must be used after authenticated.Authenticated
+func (t *Throttle) ByUser(name string, limit ratelimit.Limit) fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		return t.handle(ctx, fmt.Sprintf("%s:user:%d", name, controller.RequestedUserId(ctx)), limit)
+	}
+}
+
+func (t *Throttle) handle(ctx *fiber.Ctx, key string, limit ratelimit.Limit) error {
+	const op = "handle"
+
+	if !limit.Enabled() {
+		return ctx.Next()
+	}
+
+	res, err := t.limiter.Allow(ctx.UserContext(), key, limit)
+	if err != nil {
+		// the store is unavailable, don't block users because of it
+		logger.AddContext(ctx.UserContext(), t.pkg, op, err)
+
+		return ctx.Next()
+	}
+
+	ctx.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	ctx.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+
+	if !res.Allowed {
+		controller.SetRetryAfter(ctx, res.RetryAfter)
+
+		return ctx.Status(fiber.StatusTooManyRequests).JSON(
+			&entity.ErrorResponse{Message: controller.MessageTooManyRequests},
+		)
+	}
+
+	return ctx.Next()
+}
@@ -0,0 +1,78 @@
+package tracing
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/tracing"
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"strconv"
+)
+
+// New starts a server span of every request continuing the trace of the traceparent header.
+// Must be used after requestid.New, the span is put to the user context, so handlers pass it further
+func New() fiber.Handler {
+	return func(ctx *fiber.Ctx) error {
+		parent := otel.GetTextMapPropagator().Extract(ctx.UserContext(), carrier{ctx: ctx})
+
+		spanCtx, span := tracing.Tracer().Start(
+			parent,
+			ctx.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(ctx.Method()),
+				semconv.URLPath(ctx.Path()),
+				semconv.ClientAddress(ctx.IP()),
+			),
+		)
+		defer span.End()
+
+		if requestId, ok := ctx.Locals("request_id").(string); ok {
+			span.SetAttributes(attribute.String("request_id", requestId))
+		}
+
+		ctx.SetUserContext(spanCtx)
+
+		err := ctx.Next()
+
+		status := controller.ResponseStatus(ctx, err)
+
+		// the route is known only after routing
+		route := ctx.Route().Path
+		span.SetName(ctx.Method() + " " + route)
+		span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, strconv.Itoa(status))
+		}
+
+		return err
+	}
+}
+
+// carrier reads and writes trace context headers of the request
+type carrier struct {
+	ctx *fiber.Ctx
+}
+
+func (c carrier) Get(key string) string {
+	return c.ctx.Get(key)
+}
+
+func (c carrier) Set(key, value string) {
+	c.ctx.Request().Header.Set(key, value)
+}
+
+func (c carrier) Keys() []string {
+	headers := c.ctx.GetReqHeaders()
+
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
@@ -4,9 +4,29 @@ import (
 	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity/profile"
+	"github.com/albakov/go-cloud-file-storage/internal/service/email"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
 	"github.com/gofiber/fiber/v2"
 )
 
+type BreachedChecker interface {
+	IsBreached(password string) bool
+}
+
+type Validator struct {
+	policy          password.Policy
+	breachedChecker BreachedChecker
+}
+
+// New creates validator for credentials. Pass nil breachedChecker to disable the breached passwords check.
+func New(policy password.Policy, breachedChecker BreachedChecker) *Validator {
+	return &Validator{
+		policy:          policy,
+		breachedChecker: breachedChecker,
+	}
+}
+
+// EmailAndPasswordValidation checks that credentials are present, used to sign in
 func EmailAndPasswordValidation(ctx *fiber.Ctx) error {
 	var r profile.LoginRequest
 	err := ctx.BodyParser(&r)
@@ -14,6 +34,8 @@ func EmailAndPasswordValidation(ctx *fiber.Ctx) error {
 		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
 	}
 
+	r.Email = email.Normalize(r.Email)
+
 	if !isEmailAndPasswordValid(r.Email, r.Password) {
 		return ctx.Status(fiber.StatusUnauthorized).JSON(
 			&entity.ErrorResponse{Message: controller.MessageLoginOrPasswordInvalid},
@@ -25,6 +47,55 @@ func EmailAndPasswordValidation(ctx *fiber.Ctx) error {
 	return ctx.Next()
 }
 
+// RegistrationValidation checks the email format and the password policy, used to sign up
+func (v *Validator) RegistrationValidation(ctx *fiber.Ctx) error {
+	var r profile.LoginRequest
+	err := ctx.BodyParser(&r)
+	if err != nil {
+		return ctx.Status(fiber.StatusBadRequest).JSON(&entity.ErrorResponse{Message: controller.MessageBadRequest})
+	}
+
+	r.Email = email.Normalize(r.Email)
+
+	errs := append(v.EmailErrors("email", r.Email), v.PasswordErrors("password", r.Password)...)
+	if len(errs) > 0 {
+		return ctx.Status(fiber.StatusBadRequest).JSON(
+			&entity.ValidationErrorResponse{Message: controller.MessageValidationFailed, Errors: errs},
+		)
+	}
+
+	ctx.Locals("user_data", r)
+
+	return ctx.Next()
+}
+
+// EmailErrors validates already normalized email
+func (v *Validator) EmailErrors(field, e string) []entity.FieldError {
+	if err := email.Validate(e); err != nil {
+		return []entity.FieldError{{Field: field, Message: "must be a valid email address"}}
+	}
+
+	return nil
+}
+
+// PasswordErrors validates the password against the policy and the list of breached passwords
+func (v *Validator) PasswordErrors(field, passwd string) []entity.FieldError {
+	var errs []entity.FieldError
+
+	for _, violation := range v.policy.Validate(passwd) {
+		errs = append(errs, entity.FieldError{Field: field, Message: violation})
+	}
+
+	if v.breachedChecker != nil && v.breachedChecker.IsBreached(passwd) {
+		errs = append(errs, entity.FieldError{
+			Field:   field,
+			Message: "has appeared in a data breach, choose another password",
+		})
+	}
+
+	return errs
+}
+
 func isEmailAndPasswordValid(email, password string) bool {
 	return email != "" && password != ""
 }
@@ -0,0 +1,37 @@
+package verified
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/api/controller"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/gofiber/fiber/v2"
+)
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+type Verified struct {
+	userService UserService
+}
+
+func New(userService UserService) *Verified {
+	return &Verified{
+		userService: userService,
+	}
+}
+
+// Verified allows the request only for users with confirmed email. Must be used after authenticated.Authenticated
+func (v *Verified) Verified(ctx *fiber.Ctx) error {
+	us, err := v.userService.UserById(ctx.UserContext(), controller.RequestedUserId(ctx))
+	if err != nil {
+		return ctx.Status(fiber.StatusUnauthorized).JSON(&entity.ErrorResponse{Message: controller.MessageUnauthorized})
+	}
+
+	if !us.EmailVerifiedAt.Valid {
+		return ctx.Status(fiber.StatusForbidden).JSON(&entity.ErrorResponse{Message: controller.MessageEmailNotVerified})
+	}
+
+	return ctx.Next()
+}
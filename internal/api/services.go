@@ -0,0 +1,62 @@
+package api
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/metrics"
+	accesstokenservice "github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	accountservice "github.com/albakov/go-cloud-file-storage/internal/service/account"
+	adminservice "github.com/albakov/go-cloud-file-storage/internal/service/admin"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/credentials"
+	davservice "github.com/albakov/go-cloud-file-storage/internal/service/dav"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	exportservice "github.com/albakov/go-cloud-file-storage/internal/service/export"
+	gatewayservice "github.com/albakov/go-cloud-file-storage/internal/service/gateway"
+	"github.com/albakov/go-cloud-file-storage/internal/service/health"
+	jobservice "github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/journal"
+	"github.com/albakov/go-cloud-file-storage/internal/service/jwt"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	"github.com/albakov/go-cloud-file-storage/internal/service/maintenance"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	s3keyservice "github.com/albakov/go-cloud-file-storage/internal/service/s3key"
+	sftpservice "github.com/albakov/go-cloud-file-storage/internal/service/sftp"
+	sshkeyservice "github.com/albakov/go-cloud-file-storage/internal/service/sshkey"
+	"github.com/albakov/go-cloud-file-storage/internal/service/sso"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	usersessionservice "github.com/albakov/go-cloud-file-storage/internal/service/usersession"
+	verificationservice "github.com/albakov/go-cloud-file-storage/internal/service/verification"
+	"github.com/albakov/go-cloud-file-storage/internal/service/webhook"
+)
+
+// Services are created in main and shared between the api and background workers
+type Services struct {
+	JWT          *jwt.Service
+	User         *userservice.Service
+	UserSession  *usersessionservice.Service
+	AccessToken  *accesstokenservice.Service
+	SSO          *sso.Service
+	Verification *verificationservice.Service
+	Account      *accountservice.Service
+	S3           *s3.Instrumented
+	Limiter      *ratelimit.Limiter
+	LoginGuard   *loginguard.Service
+	Quota        *quota.Service
+	Admin        *adminservice.Service
+	Maintenance  *maintenance.Service
+	Audit        *audit.Service
+	Metrics      *metrics.Metrics
+	Health       *health.Service
+	Credentials  *credentials.Service
+	Dav          *davservice.Service
+	S3Key        *s3keyservice.Service
+	Gateway      *gatewayservice.Service
+	SSHKey       *sshkeyservice.Service
+	SFTP         *sftpservice.Service
+	Journal      *journal.Service
+	Events       *events.Bus
+	Webhook      *webhook.Service
+	Job          *jobservice.Service
+	Export       *exportservice.Service
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/credentials"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	sftpservice "github.com/albakov/go-cloud-file-storage/internal/service/sftp"
+	sshkeyservice "github.com/albakov/go-cloud-file-storage/internal/service/sshkey"
+	"github.com/albakov/go-cloud-file-storage/internal/shutdown"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"io"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// sftpHandshakeTimeout limits connections which never authenticate
+	sftpHandshakeTimeout = 30 * time.Second
+	// sftpIdentityExtension keeps the authenticated identity in the permissions of the connection
+	sftpIdentityExtension = "cfs-identity"
+)
+
+// SFTP is the SFTP server of the user's files, listening on its own address. Users sign in
+// with the email and the password or a personal access token, or with the SSH key added to the account
+type SFTP struct {
+	conf      *config.Config
+	services  *Services
+	sshConfig *ssh.ServerConfig
+	// cancels S3 calls of the sessions, when the connections are closed on shutdown
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+func MustNewSFTP(conf *config.Config, services *Services) *SFTP {
+	hostKey, err := sftpHostKey(conf.SFTPHostKeyPath)
+	if err != nil {
+		log.Fatal(logger.Error("api.SFTP", "MustNewSFTP", err))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s := &SFTP{
+		conf:     conf,
+		services: services,
+		ctx:      ctx,
+		cancel:   cancel,
+		conns:    map[net.Conn]struct{}{},
+	}
+
+	s.sshConfig = &ssh.ServerConfig{
+		PasswordCallback:  s.passwordCallback,
+		PublicKeyCallback: s.publicKeyCallback,
+		ServerVersion:     "SSH-2.0-CloudFileStorage",
+	}
+	s.sshConfig.AddHostKey(hostKey)
+
+	return s
+}
+
+func (s *SFTP) Start() {
+	ln, err := net.Listen("tcp", s.conf.SFTPAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	go func() {
+		slog.Info("SFTP server listening", "addr", s.conf.SFTPAddr)
+
+		if err := s.Serve(ln); err != nil {
+			log.Fatal(err)
+		}
+	}()
+}
+
+// Serve serves connections accepted by the listener until Shutdown
+func (s *SFTP) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	s.listener = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+
+			return err
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go func() {
+			defer s.wg.Done()
+			defer s.forget(conn)
+
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Shutdown stops accepting connections and waits for started uploads until the context is done,
+// then closes the connections. Sessions are long-lived, so they aren't waited for
+func (s *SFTP) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	s.mu.Unlock()
+
+	err := shutdown.Blocking(s.services.SFTP.Wait)(ctx)
+
+	s.cancel()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+
+	if err != nil {
+		return logger.Error("api.SFTP", "Shutdown", err)
+	}
+
+	slog.Info("SFTP Server Shutdown")
+
+	return nil
+}
+
+func (s *SFTP) serveConn(conn net.Conn) {
+	const op = "serveConn"
+
+	_ = conn.SetDeadline(time.Now().Add(sftpHandshakeTimeout))
+
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, s.sshConfig)
+	if err != nil {
+		slog.Debug("SSH handshake failed", "ip", remoteIp(conn.RemoteAddr()), "error", err)
+
+		return
+	}
+	defer func(sconn *ssh.ServerConn) {
+		_ = sconn.Close()
+	}(sconn)
+
+	_ = conn.SetDeadline(time.Time{})
+
+	var identity credentials.Identity
+	if err := json.Unmarshal([]byte(sconn.Permissions.Extensions[sftpIdentityExtension]), &identity); err != nil {
+		logger.Add("api.SFTP", op, err)
+
+		return
+	}
+
+	session := sftpservice.Session{
+		UserId:        identity.UserId,
+		AccessToken:   identity.AccessToken,
+		Ip:            remoteIp(conn.RemoteAddr()),
+		ClientVersion: string(sconn.ClientVersion()),
+	}
+	ctx := logger.With(s.ctx, "user_id", identity.UserId)
+
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only session channels are supported")
+
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			logger.AddContext(ctx, "api.SFTP", op, err)
+
+			continue
+		}
+
+		go s.serveSession(ctx, session, channel, requests)
+	}
+}
+
+// serveSession serves the sftp subsystem, shells and commands are refused
+func (s *SFTP) serveSession(
+	ctx context.Context,
+	session sftpservice.Session,
+	channel ssh.Channel,
+	requests <-chan *ssh.Request,
+) {
+	const op = "serveSession"
+
+	defer func(channel ssh.Channel) {
+		_ = channel.Close()
+	}(channel)
+
+	for req := range requests {
+		// the payload of the subsystem request is the string with its length
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		_ = req.Reply(ok, nil)
+
+		if !ok {
+			continue
+		}
+
+		go ssh.DiscardRequests(requests)
+
+		server := sftp.NewRequestServer(channel, s.services.SFTP.Handlers(ctx, session))
+
+		err := server.Serve()
+		if err != nil && !errors.Is(err, io.EOF) {
+			logger.AddContext(ctx, "api.SFTP", op, err)
+		}
+
+		_ = server.Close()
+
+		return
+	}
+}
+
+// passwordCallback authenticates with the email and the password or a personal access token
+func (s *SFTP) passwordCallback(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	const op = "passwordCallback"
+
+	identity, _, err := s.services.Credentials.Authenticate(s.ctx, meta.User(), string(password))
+	if err != nil {
+		switch {
+		case errors.Is(err, credentials.ErrInvalid),
+			errors.Is(err, loginguard.ErrLocked),
+			errors.Is(err, loginguard.ErrTooManyAttempts):
+			s.signInFailed(meta, string(password))
+		case errors.Is(err, credentials.ErrAccountDisabled), errors.Is(err, credentials.ErrPasswordLoginDisabled):
+			// the password is correct, the account can't be used with it
+		default:
+			logger.Add("api.SFTP", op, err)
+		}
+
+		return nil, err
+	}
+
+	return sftpPermissions(identity)
+}
+
+// publicKeyCallback authenticates with the SSH key added to the account, the username is ignored.
+// Clients offer all their keys, so unknown keys aren't failed attempts to sign in
+func (s *SFTP) publicKeyCallback(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+	const op = "publicKeyCallback"
+
+	userId, err := s.services.SSHKey.Authenticate(key)
+	if err != nil {
+		if !errors.Is(err, sshkeyservice.ErrInvalid) {
+			logger.Add("api.SFTP", op, err)
+		}
+
+		return nil, err
+	}
+
+	return sftpPermissions(credentials.Identity{UserId: userId})
+}
+
+// signInFailed records the failed attempt to authenticate with the password,
+// invalid personal access tokens aren't attempts to sign in to the account
+func (s *SFTP) signInFailed(meta ssh.ConnMetadata, secret string) {
+	if accesstoken.IsAccessToken(secret) {
+		return
+	}
+
+	s.services.Audit.Record(audit.Event{
+		Email:     meta.User(),
+		Action:    audit.ActionSignIn,
+		Result:    audit.ResultFailure,
+		Ip:        remoteIp(meta.RemoteAddr()),
+		UserAgent: string(meta.ClientVersion()),
+	})
+}
+
+func (s *SFTP) forget(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+func sftpPermissions(identity credentials.Identity) (*ssh.Permissions, error) {
+	data, err := json.Marshal(identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.Permissions{Extensions: map[string]string{sftpIdentityExtension: string(data)}}, nil
+}
+
+// sftpHostKey reads the private host key, a new Ed25519 key is generated if the file doesn't exist,
+// so clients see the same host key after restarts
+func sftpHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		return ssh.ParsePrivateKey(data)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := ssh.MarshalPrivateKey(key, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+
+	slog.Info("SFTP host key generated", "path", path)
+
+	return ssh.NewSignerFromKey(key)
+}
+
+func remoteIp(addr net.Addr) string {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return a.IP.String()
+	}
+
+	return addr.String()
+}
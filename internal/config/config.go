@@ -10,10 +10,32 @@ import (
 )
 
 type Config struct {
-	MysqlDSN string `mapstructure:"MYSQL_DSN"`
+	DBDriver string `mapstructure:"DB_DRIVER"`
+	DBDSN    string `mapstructure:"DB_DSN"`
+	MysqlDSN string `mapstructure:"MYSQL_DSN"` // DB_DSN of mysql before other databases were supported
+
+	MigrateOnStart            bool  `mapstructure:"MIGRATE_ON_START"`
+	MigrateLockTimeoutSeconds int64 `mapstructure:"MIGRATE_LOCK_TIMEOUT_SECONDS"`
+
+	LogFormat string `mapstructure:"LOG_FORMAT"`
+	LogLevel  string `mapstructure:"LOG_LEVEL"`
+
+	MetricsEnabled bool   `mapstructure:"METRICS_ENABLED"`
+	MetricsToken   string `mapstructure:"METRICS_TOKEN"`
+
+	TracingOTLPEndpoint string  `mapstructure:"TRACING_OTLP_ENDPOINT"`
+	TracingOTLPInsecure bool    `mapstructure:"TRACING_OTLP_INSECURE"`
+	TracingSampleRatio  float64 `mapstructure:"TRACING_SAMPLE_RATIO"`
+
+	ReadinessCheckTimeoutSeconds int64 `mapstructure:"READINESS_CHECK_TIMEOUT_SECONDS"`
+
+	ShutdownTimeoutSeconds int64 `mapstructure:"SHUTDOWN_TIMEOUT_SECONDS"`
+	ShutdownDrainSeconds   int64 `mapstructure:"SHUTDOWN_DRAIN_SECONDS"`
 
 	ApiAddr              string `mapstructure:"API_ADDR"`
 	ApiFileUploadMaxSize int    `mapstructure:"API_FILE_UPLOAD_MAX_SIZE"`
+	ApiProxyHeader       string `mapstructure:"API_PROXY_HEADER"`
+	ApiTrustedProxies    string `mapstructure:"API_TRUSTED_PROXIES"`
 
 	JWTSecret         string `mapstructure:"JWT_SECRET"`
 	JWTExpiresMinutes int64  `mapstructure:"JWT_EXPIRES_MINUTES"`
@@ -33,6 +55,96 @@ type Config struct {
 	S3Bucket       string `mapstructure:"MINIO_BUCKET"`
 	S3UseSSL       bool   `mapstructure:"MINIO_USE_SSL"`
 	S3Paginate     int    `mapstructure:"MINIO_FILES_PAGINATE"`
+
+	AppURL string `mapstructure:"APP_URL"`
+
+	MailDriver   string `mapstructure:"MAIL_DRIVER"`
+	MailFrom     string `mapstructure:"MAIL_FROM"`
+	MailLogPath  string `mapstructure:"MAIL_LOG_PATH"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	TokenEmailVerificationExpiresMinutes int64 `mapstructure:"TOKEN_EMAIL_VERIFICATION_EXPIRES_MINUTES"`
+	TokenPasswordResetExpiresMinutes     int64 `mapstructure:"TOKEN_PASSWORD_RESET_EXPIRES_MINUTES"`
+	UploadRequireVerifiedEmail           bool  `mapstructure:"UPLOAD_REQUIRE_VERIFIED_EMAIL"`
+
+	PasswordMinLength        int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMaxLength        int    `mapstructure:"PASSWORD_MAX_LENGTH"`
+	PasswordRequireUpper     bool   `mapstructure:"PASSWORD_REQUIRE_UPPER"`
+	PasswordRequireLower     bool   `mapstructure:"PASSWORD_REQUIRE_LOWER"`
+	PasswordRequireDigit     bool   `mapstructure:"PASSWORD_REQUIRE_DIGIT"`
+	PasswordRequireSymbol    bool   `mapstructure:"PASSWORD_REQUIRE_SYMBOL"`
+	PasswordBreachedCheck    bool   `mapstructure:"PASSWORD_BREACHED_CHECK"`
+	PasswordBreachedListPath string `mapstructure:"PASSWORD_BREACHED_LIST_PATH"`
+
+	RateLimitStore            string `mapstructure:"RATE_LIMIT_STORE"`
+	RedisAddr                 string `mapstructure:"REDIS_ADDR"`
+	RedisPassword             string `mapstructure:"REDIS_PASSWORD"`
+	RedisDB                   int    `mapstructure:"REDIS_DB"`
+	RateLimitAuthRequests     int    `mapstructure:"RATE_LIMIT_AUTH_REQUESTS"`
+	RateLimitAuthWindow       int64  `mapstructure:"RATE_LIMIT_AUTH_WINDOW_SECONDS"`
+	RateLimitAccountRequests  int    `mapstructure:"RATE_LIMIT_ACCOUNT_REQUESTS"`
+	RateLimitAccountWindow    int64  `mapstructure:"RATE_LIMIT_ACCOUNT_WINDOW_SECONDS"`
+	RateLimitRefreshRequests  int    `mapstructure:"RATE_LIMIT_REFRESH_REQUESTS"`
+	RateLimitRefreshWindow    int64  `mapstructure:"RATE_LIMIT_REFRESH_WINDOW_SECONDS"`
+	RateLimitUploadRequests   int    `mapstructure:"RATE_LIMIT_UPLOAD_REQUESTS"`
+	RateLimitUploadWindow     int64  `mapstructure:"RATE_LIMIT_UPLOAD_WINDOW_SECONDS"`
+	RateLimitSearchRequests   int    `mapstructure:"RATE_LIMIT_SEARCH_REQUESTS"`
+	RateLimitSearchWindow     int64  `mapstructure:"RATE_LIMIT_SEARCH_WINDOW_SECONDS"`
+	LoginLockoutThreshold     int    `mapstructure:"LOGIN_LOCKOUT_THRESHOLD"`
+	LoginLockoutWindowMinutes int64  `mapstructure:"LOGIN_LOCKOUT_WINDOW_MINUTES"`
+	LoginLockoutMinutes       int64  `mapstructure:"LOGIN_LOCKOUT_MINUTES"`
+	LoginDelayBaseSeconds     int64  `mapstructure:"LOGIN_DELAY_BASE_SECONDS"`
+	LoginDelayMaxSeconds      int64  `mapstructure:"LOGIN_DELAY_MAX_SECONDS"`
+
+	OIDCProvidersFile       string `mapstructure:"OIDC_PROVIDERS_FILE"`
+	OIDCCallbackURL         string `mapstructure:"OIDC_CALLBACK_URL"`
+	OIDCAutoProvision       bool   `mapstructure:"OIDC_AUTO_PROVISION"`
+	OIDCStateExpiresMinutes int64  `mapstructure:"OIDC_STATE_EXPIRES_MINUTES"`
+
+	StorageDefaultQuotaMB      int64  `mapstructure:"STORAGE_DEFAULT_QUOTA_MB"`
+	StorageQuotaWarningPercent int64  `mapstructure:"STORAGE_QUOTA_WARNING_PERCENT"`
+	AdminEmails                string `mapstructure:"ADMIN_EMAILS"`
+
+	DAVEnabled                 bool  `mapstructure:"DAV_ENABLED"`
+	DAVCredentialsCacheSeconds int64 `mapstructure:"DAV_CREDENTIALS_CACHE_SECONDS"`
+
+	S3GatewayAddr      string `mapstructure:"S3_GATEWAY_ADDR"`
+	S3GatewayBucket    string `mapstructure:"S3_GATEWAY_BUCKET"`
+	S3GatewayRegion    string `mapstructure:"S3_GATEWAY_REGION"`
+	S3GatewayKeySecret string `mapstructure:"S3_GATEWAY_KEY_SECRET"`
+
+	SFTPAddr        string `mapstructure:"SFTP_ADDR"`
+	SFTPHostKeyPath string `mapstructure:"SFTP_HOST_KEY_PATH"`
+
+	ChangesRetentionDays       int64 `mapstructure:"CHANGES_RETENTION_DAYS"`
+	ChangesPollIntervalSeconds int64 `mapstructure:"CHANGES_POLL_INTERVAL_SECONDS"`
+
+	EventsBroker           string `mapstructure:"EVENTS_BROKER"`
+	EventsHeartbeatSeconds int64  `mapstructure:"EVENTS_HEARTBEAT_SECONDS"`
+	EventsBufferSize       int    `mapstructure:"EVENTS_BUFFER_SIZE"`
+
+	WebhooksSecret              string `mapstructure:"WEBHOOKS_SECRET"`
+	WebhooksTimeoutSeconds      int64  `mapstructure:"WEBHOOKS_TIMEOUT_SECONDS"`
+	WebhooksMaxAttempts         int    `mapstructure:"WEBHOOKS_MAX_ATTEMPTS"`
+	WebhooksBackoffSeconds      int64  `mapstructure:"WEBHOOKS_BACKOFF_SECONDS"`
+	WebhooksMaxBackoffSeconds   int64  `mapstructure:"WEBHOOKS_MAX_BACKOFF_SECONDS"`
+	WebhooksDisableAfter        int    `mapstructure:"WEBHOOKS_DISABLE_AFTER"`
+	WebhooksRetentionDays       int64  `mapstructure:"WEBHOOKS_RETENTION_DAYS"`
+	WebhooksWorkers             int    `mapstructure:"WEBHOOKS_WORKERS"`
+	WebhooksPollIntervalSeconds int64  `mapstructure:"WEBHOOKS_POLL_INTERVAL_SECONDS"`
+	WebhooksAllowPrivate        bool   `mapstructure:"WEBHOOKS_ALLOW_PRIVATE"`
+	JobsWorkers                 int    `mapstructure:"JOBS_WORKERS"`
+	JobsPollIntervalSeconds     int64  `mapstructure:"JOBS_POLL_INTERVAL_SECONDS"`
+	JobsLeaseSeconds            int64  `mapstructure:"JOBS_LEASE_SECONDS"`
+	JobsMaxAttempts             int    `mapstructure:"JOBS_MAX_ATTEMPTS"`
+	JobsBackoffSeconds          int64  `mapstructure:"JOBS_BACKOFF_SECONDS"`
+	JobsRetentionDays           int64  `mapstructure:"JOBS_RETENTION_DAYS"`
+	ExportsSecret               string `mapstructure:"EXPORTS_SECRET"`
+	ExportsTTLHours             int64  `mapstructure:"EXPORTS_TTL_HOURS"`
+	ExportsMaxPaths             int    `mapstructure:"EXPORTS_MAX_PATHS"`
 }
 
 const f = "config"
@@ -58,6 +170,126 @@ func MustNew(envPath string) *Config {
 		log.Fatal(err)
 	}
 
+	if config.DBDriver == "" {
+		config.DBDriver = "mysql"
+	}
+
+	if config.DBDSN == "" {
+		config.DBDSN = config.MysqlDSN
+	}
+
+	if config.S3GatewayBucket == "" {
+		config.S3GatewayBucket = "files"
+	}
+
+	if config.S3GatewayRegion == "" {
+		config.S3GatewayRegion = "us-east-1"
+	}
+
+	if config.S3GatewayKeySecret == "" {
+		config.S3GatewayKeySecret = DeriveSecret(config.JWTSecret, PurposeS3GatewayKeys)
+	}
+
+	if config.SFTPHostKeyPath == "" {
+		config.SFTPHostKeyPath = "sftp_host_key"
+	}
+
+	if config.ChangesRetentionDays <= 0 {
+		config.ChangesRetentionDays = 30
+	}
+
+	if config.ChangesPollIntervalSeconds <= 0 {
+		config.ChangesPollIntervalSeconds = 2
+	}
+
+	if config.StorageQuotaWarningPercent <= 0 {
+		config.StorageQuotaWarningPercent = 90
+	}
+
+	if config.EventsBroker == "" {
+		config.EventsBroker = "memory"
+	}
+
+	if config.EventsHeartbeatSeconds <= 0 {
+		config.EventsHeartbeatSeconds = 25
+	}
+
+	if config.EventsBufferSize <= 0 {
+		config.EventsBufferSize = 64
+	}
+
+	if config.WebhooksSecret == "" {
+		config.WebhooksSecret = DeriveSecret(config.JWTSecret, PurposeWebhooks)
+	}
+
+	if config.WebhooksTimeoutSeconds <= 0 {
+		config.WebhooksTimeoutSeconds = 10
+	}
+
+	if config.WebhooksMaxAttempts <= 0 {
+		config.WebhooksMaxAttempts = 8
+	}
+
+	if config.WebhooksBackoffSeconds <= 0 {
+		config.WebhooksBackoffSeconds = 30
+	}
+
+	if config.WebhooksMaxBackoffSeconds <= 0 {
+		config.WebhooksMaxBackoffSeconds = 3600
+	}
+
+	if config.WebhooksDisableAfter <= 0 {
+		config.WebhooksDisableAfter = 20
+	}
+
+	if config.WebhooksRetentionDays <= 0 {
+		config.WebhooksRetentionDays = 7
+	}
+
+	if config.WebhooksWorkers <= 0 {
+		config.WebhooksWorkers = 4
+	}
+
+	if config.WebhooksPollIntervalSeconds <= 0 {
+		config.WebhooksPollIntervalSeconds = 5
+	}
+
+	if config.JobsWorkers <= 0 {
+		config.JobsWorkers = 4
+	}
+
+	if config.JobsPollIntervalSeconds <= 0 {
+		config.JobsPollIntervalSeconds = 2
+	}
+
+	if config.JobsLeaseSeconds <= 0 {
+		config.JobsLeaseSeconds = 60
+	}
+
+	if config.JobsMaxAttempts <= 0 {
+		config.JobsMaxAttempts = 3
+	}
+
+	if config.JobsBackoffSeconds <= 0 {
+		config.JobsBackoffSeconds = 10
+	}
+
+	if config.JobsRetentionDays <= 0 {
+		config.JobsRetentionDays = 7
+	}
+
+	if config.ExportsSecret == "" {
+		config.ExportsSecret = DeriveSecret(config.JWTSecret, PurposeExports)
+	}
+
+	if config.ExportsTTLHours <= 0 {
+		config.ExportsTTLHours = 24
+	}
+
+	if config.ExportsMaxPaths <= 0 {
+		config.ExportsMaxPaths = 100
+	}
+
 	return &config
 }
 
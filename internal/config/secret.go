@@ -0,0 +1,25 @@
+package config
+
+import (
+	"crypto/hkdf"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Purposes of keys derived from JWT_SECRET
+const (
+	PurposeUserTokens    = "user-tokens"
+	PurposeOIDCState     = "oidc-state"
+	PurposeS3GatewayKeys = "s3-gateway-keys"
+	PurposeWebhooks      = "webhooks"
+	PurposeExports       = "exports"
+)
+
+// DeriveSecret returns the key of the purpose derived from the secret with HKDF. Keys of different purposes
+// are independent, so a token signed for one purpose can't be replayed as another, e.g. as the access token
+func DeriveSecret(secret, purpose string) string {
+	// the error is returned only for keys longer than 255 hashes
+	key, _ := hkdf.Key(sha256.New, []byte(secret), nil, "go-cloud-file-storage/"+purpose, sha256.Size)
+
+	return hex.EncodeToString(key)
+}
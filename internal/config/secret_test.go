@@ -0,0 +1,21 @@
+package config
+
+import "testing"
+
+func TestDeriveSecret(t *testing.T) {
+	userTokens := DeriveSecret("secret", PurposeUserTokens)
+
+	if userTokens != DeriveSecret("secret", PurposeUserTokens) {
+		t.Error("key of the purpose must be the same for the secret")
+	}
+
+	for _, key := range []string{
+		"secret",
+		DeriveSecret("secret", PurposeOIDCState),
+		DeriveSecret("another-secret", PurposeUserTokens),
+	} {
+		if key == userTokens {
+			t.Errorf("key must differ from the secret and keys of other purposes and secrets, got: %s", key)
+		}
+	}
+}
@@ -1,17 +1,115 @@
 package logger
 
 import (
+	"context"
 	"fmt"
-	"log"
-	"time"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"log/slog"
+	"strings"
 )
 
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+type Config struct {
+	Format string // json or text
+	Level  string // debug, info, warn or error
+}
+
+type attrsKey struct{}
+
+// New creates a logger writing to w, attributes added to the context with With are added to every record.
+// Empty format and level default to text and info
+func New(w io.Writer, conf Config) (*slog.Logger, error) {
+	level := slog.LevelInfo
+	if conf.Level != "" {
+		if err := level.UnmarshalText([]byte(conf.Level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", conf.Level, err)
+		}
+	}
+
+	if conf.Format == "" {
+		conf.Format = FormatText
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+	switch strings.ToLower(conf.Format) {
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q", conf.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+// Setup makes the logger the default one, so log.Printf and slog functions write through it too
+func Setup(w io.Writer, conf Config) error {
+	l, err := New(w, conf)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(l)
+
+	return nil
+}
+
+// With returns a copy of the context carrying the attributes, e.g. the request id,
+// they are added to every record logged with the context
+func With(ctx context.Context, args ...any) context.Context {
+	attrs, _ := ctx.Value(attrsKey{}).([]any)
+
+	merged := make([]any, 0, len(attrs)+len(args))
+	merged = append(merged, attrs...)
+	merged = append(merged, args...)
+
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
 // Error wrapper for fmt.Errorf
 func Error(f, op string, err error) error {
 	return fmt.Errorf("%v -> %v: %v", f, op, err)
 }
 
-// Add wrapper for log.Printf
+// Add logs the error of the operation
 func Add(f, op string, err error) {
-	log.Printf("[%s] %v -> %v: %v", time.Now().Format(time.DateTime), f, op, err)
+	AddContext(context.Background(), f, op, err)
+}
+
+// AddContext logs the error of the operation with the attributes of the context
+func AddContext(ctx context.Context, f, op string, err error) {
+	slog.ErrorContext(ctx, err.Error(), "pkg", f, "op", op)
+}
+
+// contextHandler adds attributes of the context and ids of the current span to the record
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]any); ok {
+		r.Add(attrs...)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.Add("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
 }
@@ -0,0 +1,111 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogger_JSONWithContext(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, Config{Format: FormatJSON, Level: "info"})
+	if err != nil {
+		t.Fatalf("error while create logger: %v", err)
+	}
+
+	ctx := With(context.Background(), "request_id", "abc")
+	ctx = With(ctx, "user_id", int64(1))
+
+	l.ErrorContext(ctx, "failed", "pkg", "auth", "op", "LoginHandler")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line must be json: %v, %s", err, buf.String())
+	}
+
+	for key, want := range map[string]any{
+		"msg":        "failed",
+		"level":      "ERROR",
+		"pkg":        "auth",
+		"op":         "LoginHandler",
+		"request_id": "abc",
+		"user_id":    float64(1),
+	} {
+		if line[key] != want {
+			t.Errorf("%s must be %v, got: %v", key, want, line[key])
+		}
+	}
+}
+
+func TestLogger_Level(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, Config{Format: FormatText, Level: "warn"})
+	if err != nil {
+		t.Fatalf("error while create logger: %v", err)
+	}
+
+	l.Info("hidden")
+	l.Warn("shown")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "msg=shown") {
+		t.Errorf("only records of the level and above must be logged, got: %s", buf.String())
+	}
+}
+
+func TestLogger_InvalidConfig(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, Config{Format: FormatJSON, Level: "verbose"}); err == nil {
+		t.Error("unknown level must return error")
+	}
+
+	if _, err := New(&bytes.Buffer{}, Config{Format: "xml", Level: "info"}); err == nil {
+		t.Error("unknown format must return error")
+	}
+}
+
+func TestLogger_AddContext(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Setup(&buf, Config{Format: FormatJSON, Level: "info"}); err != nil {
+		t.Fatalf("error while setup logger: %v", err)
+	}
+	defer slog.SetDefault(slog.New(slog.NewTextHandler(&bytes.Buffer{}, nil)))
+
+	AddContext(With(context.Background(), "request_id", "abc"), "s3.service", "Delete", errors.New("not found"))
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line must be json: %v, %s", err, buf.String())
+	}
+
+	if line["msg"] != "not found" || line["pkg"] != "s3.service" || line["request_id"] != "abc" {
+		t.Errorf("error must be logged with pkg, op and the context, got: %v", line)
+	}
+}
+
+func TestLogger_TraceIds(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, Config{Format: FormatJSON, Level: "info"})
+	if err != nil {
+		t.Fatalf("error while create logger: %v", err)
+	}
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+
+	l.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "traced")
+
+	var line map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("log line must be json: %v, %s", err, buf.String())
+	}
+
+	if line["trace_id"] != sc.TraceID().String() || line["span_id"] != sc.SpanID().String() {
+		t.Errorf("record must contain ids of the span, got: %s", buf.String())
+	}
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const namespace = "cfs"
+
+// SessionCounter returns the number of not expired sessions
+type SessionCounter interface {
+	CountActive(ctx context.Context) (int64, error)
+}
+
+// Metrics collects application metrics in its own registry
+type Metrics struct {
+	pkg             string
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	uploadedBytes   prometheus.Counter
+	downloadedBytes prometheus.Counter
+	s3Duration      *prometheus.HistogramVec
+	s3Errors        *prometheus.CounterVec
+	archiveDuration prometheus.Histogram
+}
+
+func New(db *storage.DB, sessions SessionCounter) *Metrics {
+	m := &Metrics{
+		pkg:      "metrics",
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route pattern and status.",
+		}, []string{"method", "route", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		uploadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "uploaded_bytes_total",
+			Help:      "Bytes of stored files.",
+		}),
+		downloadedBytes: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "downloaded_bytes_total",
+			Help:      "Bytes of downloaded files and archives.",
+		}),
+		s3Duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "s3_operation_duration_seconds",
+			Help:      "Duration of S3 operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		s3Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "s3_operation_errors_total",
+			Help:      "Number of failed S3 operations.",
+		}, []string{"operation"}),
+		archiveDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "archive_write_duration_seconds",
+			Help:      "Duration of writing archives of exports.",
+			Buckets:   []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db.DB, db.Dialect().Name()),
+		m.requests,
+		m.requestDuration,
+		m.uploadedBytes,
+		m.downloadedBytes,
+		m.s3Duration,
+		m.s3Errors,
+		m.archiveDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_sessions",
+			Help:      "Number of not expired user sessions.",
+		}, func() float64 {
+			count, err := sessions.CountActive(context.Background())
+			if err != nil {
+				logger.Add(m.pkg, "active_sessions", err)
+
+				return 0
+			}
+
+			return float64(count)
+		}),
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest records the request, route must be the route pattern, so the number of label values stays bounded
+func (m *Metrics) ObserveRequest(method, route string, status int, duration time.Duration) {
+	m.requests.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+	m.requestDuration.WithLabelValues(method, route).Observe(duration.Seconds())
+}
+
+func (m *Metrics) AddUploadedBytes(n int64) {
+	m.uploadedBytes.Add(float64(n))
+}
+
+func (m *Metrics) AddDownloadedBytes(n int64) {
+	m.downloadedBytes.Add(float64(n))
+}
+
+func (m *Metrics) ObserveS3Operation(operation string, duration time.Duration, err error) {
+	m.s3Duration.WithLabelValues(operation).Observe(duration.Seconds())
+
+	if err != nil {
+		m.s3Errors.WithLabelValues(operation).Inc()
+	}
+}
+
+func (m *Metrics) ObserveArchiveWrite(duration time.Duration) {
+	m.archiveDuration.Observe(duration.Seconds())
+}
@@ -0,0 +1,67 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type sessionsMock struct {
+	count int64
+}
+
+func (s *sessionsMock) CountActive(context.Context) (int64, error) {
+	return s.count, nil
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	dialect, err := storage.NewDialect(storage.DriverMySQL)
+	if err != nil {
+		t.Fatalf("error while create dialect: %v", err)
+	}
+
+	db, err := dialect.Open("user:password@tcp(127.0.0.1:3306)/db")
+	if err != nil {
+		t.Fatalf("error while open db: %v", err)
+	}
+	defer db.Close()
+
+	m := New(storage.NewDB(db, dialect), &sessionsMock{count: 3})
+
+	m.ObserveRequest("GET", "/api/resource", 200, 10*time.Millisecond)
+	m.ObserveRequest("GET", "/api/resource", 200, 20*time.Millisecond)
+	m.ObserveRequest("GET", "/api/resource", 404, 5*time.Millisecond)
+	m.AddUploadedBytes(100)
+	m.AddDownloadedBytes(50)
+	m.ObserveS3Operation("object", time.Millisecond, nil)
+	m.ObserveS3Operation("object", time.Millisecond, errors.New("failed"))
+	m.ObserveArchiveWrite(time.Second)
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	body, _ := io.ReadAll(rec.Body)
+	text := string(body)
+
+	for _, line := range []string{
+		`cfs_http_requests_total{method="GET",route="/api/resource",status="200"} 2`,
+		`cfs_http_requests_total{method="GET",route="/api/resource",status="404"} 1`,
+		`cfs_http_request_duration_seconds_count{method="GET",route="/api/resource"} 3`,
+		`cfs_uploaded_bytes_total 100`,
+		`cfs_downloaded_bytes_total 50`,
+		`cfs_s3_operation_duration_seconds_count{operation="object"} 2`,
+		`cfs_s3_operation_errors_total{operation="object"} 1`,
+		`cfs_archive_write_duration_seconds_count 1`,
+		`cfs_active_sessions 3`,
+		`go_sql_max_open_connections{db_name="mysql"}`,
+	} {
+		if !strings.Contains(text, line) {
+			t.Errorf("metrics must contain %q", line)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const (
+	defaultLockTimeout = time.Minute
+	// lockName is the name of the MySQL advisory lock, the lock is held by one connection at a time on the server
+	lockName = "cloud_file_storage.migrations"
+	// versionFormat is the timestamp version of goose migrations
+	versionFormat = "20060102150405"
+)
+
+const migrationTemplate = `-- +goose Up
+-- +goose StatementBegin
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+-- +goose StatementEnd
+`
+
+var ErrLockTimeout = errors.New("migrations are locked by another process")
+
+type Config struct {
+	Migrations fs.FS
+	// LockTimeout is how long to wait while other process (e.g. other replica on start) applies migrations
+	LockTimeout time.Duration
+}
+
+// Migrator applies goose migrations from the embedded files.
+// Migrations are changed under the advisory lock, so several replicas starting at once don't race
+type Migrator struct {
+	provider *goose.Provider
+}
+
+func New(conf *Config, db *storage.DB) (*Migrator, error) {
+	timeout := conf.LockTimeout
+	if timeout <= 0 {
+		timeout = defaultLockTimeout
+	}
+
+	var opts []goose.ProviderOption
+
+	dialect := goose.DialectMySQL
+	switch db.Dialect().Name() {
+	case storage.DriverMySQL:
+		opts = append(opts, goose.WithSessionLocker(&mysqlLocker{name: lockName, timeout: timeout}))
+	case storage.DriverPostgres:
+		dialect = goose.DialectPostgres
+
+		// the lock is retried every second
+		locker, err := lock.NewPostgresSessionLocker(lock.WithLockTimeout(1, uint64(max(timeout.Seconds(), 1))))
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, goose.WithSessionLocker(locker))
+	case storage.DriverSQLite:
+		// the database file is used by one process
+		dialect = goose.DialectSQLite3
+	}
+
+	provider, err := goose.NewProvider(dialect, db.DB, conf.Migrations, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{provider: provider}, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the last applied migration
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Status returns all migrations with their state, in ascending order of versions
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// UpOnStart applies pending migrations before the app starts serving requests.
+// The replica which gets the lock applies migrations, others wait and find nothing to apply
+func (m *Migrator) UpOnStart(ctx context.Context) error {
+	results, err := m.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("error while applying migrations: %w", err)
+	}
+
+	for _, r := range results {
+		slog.InfoContext(ctx, "migration applied", "migration", r.Source.Path, "duration", r.Duration)
+	}
+
+	return nil
+}
+
+// Create writes a new blank SQL migration for every database to dir/<driver>, the migrations have the same version.
+// It returns paths of the created files
+func Create(dir, name string) ([]string, error) {
+	filename := time.Now().UTC().Format(versionFormat) + "_" + snakeCase(name) + ".sql"
+
+	var paths []string
+	for _, driver := range []string{storage.DriverMySQL, storage.DriverPostgres, storage.DriverSQLite} {
+		path := filepath.Join(dir, driver, filename)
+
+		// O_EXCL doesn't overwrite the migration created in the same second
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			return paths, err
+		}
+
+		_, err = f.WriteString(migrationTemplate)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return paths, err
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// snakeCase makes the name of the migration file from words of any case
+func snakeCase(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	return strings.Join(words, "_")
+}
+
+// mysqlLocker holds the MySQL advisory lock on the connection goose runs migrations on
+type mysqlLocker struct {
+	name    string
+	timeout time.Duration
+}
+
+func (l *mysqlLocker) SessionLock(ctx context.Context, conn *sql.Conn) error {
+	var acquired sql.NullInt64
+
+	// GET_LOCK returns 1 when the lock is acquired, 0 on timeout and NULL on error
+	err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", l.name, int64(l.timeout.Seconds())).Scan(&acquired)
+	if err != nil {
+		return err
+	}
+
+	if !acquired.Valid || acquired.Int64 != 1 {
+		return fmt.Errorf("%w, waited %s", ErrLockTimeout, l.timeout)
+	}
+
+	return nil
+}
+
+func (l *mysqlLocker) SessionUnlock(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.name)
+
+	return err
+}
@@ -0,0 +1,32 @@
+package accesstoken
+
+// Prefix marks personal access tokens, so they can be told apart from JWT access tokens
+const Prefix = "cfs_pat_"
+
+const (
+	ScopeRead   = "read"
+	ScopeWrite  = "write"
+	ScopeDelete = "delete"
+	ScopeShare  = "share"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeDelete, ScopeShare}
+
+// Token is a validated personal access token
+type Token struct {
+	Id     int64
+	UserId int64
+	Scopes []string
+	Folder string // empty if the token is not restricted to a folder
+}
+
+// HasScope reports whether the token grants the scope
+func (t Token) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
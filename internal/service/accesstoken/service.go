@@ -0,0 +1,218 @@
+package accesstoken
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/accesstoken"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid      = errors.New("access token invalid")
+	ErrExpired      = errors.New("access token expired")
+	ErrNotFound     = errors.New("access token not found")
+	ErrInvalidScope = errors.New("access token scope invalid")
+	ErrNameRequired = errors.New("access token name required")
+	ErrExpiresAt    = errors.New("access token expiration must be in the future")
+)
+
+type Service struct {
+	pkg             string
+	accessTokenRepo Repository
+}
+
+type Repository interface {
+	Create(ctx context.Context, token accesstoken.AccessToken) (accesstoken.AccessToken, error)
+	ById(ctx context.Context, userId, tokenId int64) (accesstoken.AccessToken, error)
+	ByTokenHash(ctx context.Context, tokenHash string) (accesstoken.AccessToken, error)
+	ByUserId(ctx context.Context, userId int64) ([]accesstoken.AccessToken, error)
+	Delete(ctx context.Context, userId, tokenId int64) error
+	UpdateLastUsed(ctx context.Context, tokenId int64) error
+}
+
+func NewService(accessTokenRepo Repository) *Service {
+	return &Service{
+		pkg:             "accesstoken.service",
+		accessTokenRepo: accessTokenRepo,
+	}
+}
+
+// CreateToken creates a new personal access token. The plain token is returned only once,
+// just the hash of it is stored. Zero expiresAt means the token never expires,
+// empty folder (or /) means the token has access to all user files.
+func (s *Service) CreateToken(
+	ctx context.Context,
+	userId int64,
+	name string,
+	scopes []string,
+	folder string,
+	expiresAt time.Time,
+) (string, accesstoken.AccessToken, error) {
+	const op = "CreateToken"
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", accesstoken.AccessToken{}, ErrNameRequired
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", accesstoken.AccessToken{}, err
+	}
+
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return "", accesstoken.AccessToken{}, ErrExpiresAt
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", accesstoken.AccessToken{}, logger.Error(s.pkg, op, err)
+	}
+
+	token := Prefix + base64.RawURLEncoding.EncodeToString(b)
+	folder = NormalizeFolder(folder)
+
+	t, err := s.accessTokenRepo.Create(ctx, accesstoken.AccessToken{
+		UserId:    userId,
+		Name:      name,
+		TokenHash: s.hash(token),
+		Scopes:    strings.Join(scopes, ","),
+		Folder:    sql.NullString{String: folder, Valid: folder != ""},
+		ExpiredAt: sql.NullString{String: expiresAt.Format(time.DateTime), Valid: !expiresAt.IsZero()},
+	})
+	if err != nil {
+		return "", accesstoken.AccessToken{}, logger.Error(s.pkg, op, err)
+	}
+
+	return token, t, nil
+}
+
+// Tokens returns all personal access tokens of the user
+func (s *Service) Tokens(ctx context.Context, userId int64) ([]accesstoken.AccessToken, error) {
+	const op = "Tokens"
+
+	tokens, err := s.accessTokenRepo.ByUserId(ctx, userId)
+	if err != nil {
+		return nil, logger.Error(s.pkg, op, err)
+	}
+
+	return tokens, nil
+}
+
+// RevokeToken deletes the token of the user
+func (s *Service) RevokeToken(ctx context.Context, userId, tokenId int64) error {
+	const op = "RevokeToken"
+
+	err := s.accessTokenRepo.Delete(ctx, userId, tokenId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// ValidateToken checks the plain token and returns its owner, scopes and folder restriction
+func (s *Service) ValidateToken(ctx context.Context, token string) (Token, error) {
+	const op = "ValidateToken"
+
+	if !IsAccessToken(token) {
+		return Token{}, ErrInvalid
+	}
+
+	t, err := s.accessTokenRepo.ByTokenHash(ctx, s.hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return Token{}, ErrInvalid
+		}
+
+		return Token{}, logger.Error(s.pkg, op, err)
+	}
+
+	if t.ExpiredAt.Valid {
+		expiresAt, err := time.ParseInLocation(time.DateTime, t.ExpiredAt.String, time.Local)
+		if err != nil {
+			return Token{}, logger.Error(s.pkg, op, err)
+		}
+
+		if time.Now().After(expiresAt) {
+			return Token{}, ErrExpired
+		}
+	}
+
+	err = s.accessTokenRepo.UpdateLastUsed(ctx, t.Id)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+	}
+
+	return Token{
+		Id:     t.Id,
+		UserId: t.UserId,
+		Scopes: strings.Split(t.Scopes, ","),
+		Folder: t.Folder.String,
+	}, nil
+}
+
+// IsAccessToken reports whether the bearer token looks like a personal access token
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, Prefix) && len(token) > len(Prefix)
+}
+
+// NormalizeFolder returns folder in /folder1/folder2 form, empty string means the whole storage
+func NormalizeFolder(folder string) string {
+	folder = strings.TrimSpace(folder)
+	if folder == "" {
+		return ""
+	}
+
+	folder = filepath.Clean("/" + folder)
+	if folder == "/" {
+		return ""
+	}
+
+	return folder
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	requested := map[string]bool{}
+	for _, scope := range scopes {
+		requested[strings.ToLower(strings.TrimSpace(scope))] = true
+	}
+
+	// keep the canonical order and drop duplicates
+	var result []string
+	for _, scope := range Scopes {
+		if requested[scope] {
+			result = append(result, scope)
+			delete(requested, scope)
+		}
+	}
+
+	if len(requested) > 0 {
+		return nil, ErrInvalidScope
+	}
+
+	return result, nil
+}
+
+func (s *Service) hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
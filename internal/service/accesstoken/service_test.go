@@ -0,0 +1,216 @@
+package accesstoken
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/accesstoken"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryRepository struct {
+	lastId int64
+	tokens map[int64]accesstoken.AccessToken
+}
+
+func TestAccessTokenService_ValidateToken(t *testing.T) {
+	service := accessTokenTestService()
+
+	scopes := []string{"write", "read", "read"}
+	token, _, err := service.CreateToken(context.Background(), 1, "ci", scopes, "ci/artifacts/", time.Time{})
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	if !strings.HasPrefix(token, Prefix) {
+		t.Errorf("token must start with %s, got: %s", Prefix, token)
+	}
+
+	at, err := service.ValidateToken(context.Background(), token)
+	if err != nil {
+		t.Fatalf("error while validate token: %v", err)
+	}
+
+	if at.UserId != 1 {
+		t.Errorf("user id must be 1, got: %d", at.UserId)
+	}
+
+	if !reflect.DeepEqual(at.Scopes, []string{ScopeRead, ScopeWrite}) {
+		t.Errorf("scopes must be [read write], got: %v", at.Scopes)
+	}
+
+	if at.Folder != "/ci/artifacts" {
+		t.Errorf("folder must be /ci/artifacts, got: %s", at.Folder)
+	}
+
+	if at.HasScope(ScopeDelete) {
+		t.Error("token must not have delete scope")
+	}
+}
+
+func TestAccessTokenService_ValidateTokenInvalid(t *testing.T) {
+	service := accessTokenTestService()
+
+	token, _, err := service.CreateToken(context.Background(), 1, "ci", []string{ScopeRead}, "", time.Time{})
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	for _, tc := range []string{"", Prefix, token + "x", "eyJhbGciOiJIUzI1NiJ9.e30.sig"} {
+		_, err = service.ValidateToken(context.Background(), tc)
+		if !errors.Is(err, ErrInvalid) {
+			t.Errorf("token %q must return ErrInvalid, got: %v", tc, err)
+		}
+	}
+}
+
+func TestAccessTokenService_ValidateTokenExpired(t *testing.T) {
+	service := accessTokenTestService()
+
+	expiresAt := time.Now().Add(time.Hour)
+	token, at, err := service.CreateToken(context.Background(), 1, "ci", []string{ScopeRead}, "", expiresAt)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	// move expiration to the past
+	repo := service.accessTokenRepo.(*memoryRepository)
+	at.ExpiredAt.String = time.Now().Add(-time.Minute).Format(time.DateTime)
+	repo.tokens[at.Id] = at
+
+	_, err = service.ValidateToken(context.Background(), token)
+	if !errors.Is(err, ErrExpired) {
+		t.Errorf("expired token must return ErrExpired, got: %v", err)
+	}
+}
+
+func TestAccessTokenService_CreateTokenValidation(t *testing.T) {
+	service := accessTokenTestService()
+
+	_, _, err := service.CreateToken(context.Background(), 1, " ", []string{ScopeRead}, "", time.Time{})
+	if !errors.Is(err, ErrNameRequired) {
+		t.Errorf("empty name must return ErrNameRequired, got: %v", err)
+	}
+
+	_, _, err = service.CreateToken(context.Background(), 1, "ci", []string{ScopeRead, "admin"}, "", time.Time{})
+	if !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("unknown scope must return ErrInvalidScope, got: %v", err)
+	}
+
+	_, _, err = service.CreateToken(context.Background(), 1, "ci", nil, "", time.Time{})
+	if !errors.Is(err, ErrInvalidScope) {
+		t.Errorf("no scopes must return ErrInvalidScope, got: %v", err)
+	}
+
+	_, _, err = service.CreateToken(context.Background(), 1, "ci", []string{ScopeRead}, "", time.Now().Add(-time.Hour))
+	if !errors.Is(err, ErrExpiresAt) {
+		t.Errorf("expiration in the past must return ErrExpiresAt, got: %v", err)
+	}
+}
+
+func TestAccessTokenService_RevokeToken(t *testing.T) {
+	service := accessTokenTestService()
+
+	token, at, err := service.CreateToken(context.Background(), 1, "ci", []string{ScopeRead}, "", time.Time{})
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	if err := service.RevokeToken(context.Background(), 2, at.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("token of another user must return ErrNotFound, got: %v", err)
+	}
+
+	if err := service.RevokeToken(context.Background(), 1, at.Id); err != nil {
+		t.Fatalf("error while revoke token: %v", err)
+	}
+
+	_, err = service.ValidateToken(context.Background(), token)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("revoked token must return ErrInvalid, got: %v", err)
+	}
+}
+
+func TestNormalizeFolder(t *testing.T) {
+	for folder, expected := range map[string]string{
+		"":               "",
+		"/":              "",
+		"docs":           "/docs",
+		"/docs/":         "/docs",
+		"/docs/../../..": "",
+		"/a/./b/":        "/a/b",
+	} {
+		if got := NormalizeFolder(folder); got != expected {
+			t.Errorf("folder %q must be %q, got: %q", folder, expected, got)
+		}
+	}
+}
+
+func accessTokenTestService() *Service {
+	return NewService(&memoryRepository{tokens: map[int64]accesstoken.AccessToken{}})
+}
+
+func (r *memoryRepository) Create(_ context.Context, token accesstoken.AccessToken) (accesstoken.AccessToken, error) {
+	r.lastId++
+	token.Id = r.lastId
+	token.CreatedAt = time.Now().Format(time.DateTime)
+	r.tokens[token.Id] = token
+
+	return token, nil
+}
+
+func (r *memoryRepository) ById(_ context.Context, userId, tokenId int64) (accesstoken.AccessToken, error) {
+	t, ok := r.tokens[tokenId]
+	if !ok || t.UserId != userId {
+		return accesstoken.AccessToken{}, storage.ErrNotFound
+	}
+
+	return t, nil
+}
+
+func (r *memoryRepository) ByTokenHash(_ context.Context, tokenHash string) (accesstoken.AccessToken, error) {
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+
+	return accesstoken.AccessToken{}, storage.ErrNotFound
+}
+
+func (r *memoryRepository) ByUserId(_ context.Context, userId int64) ([]accesstoken.AccessToken, error) {
+	var tokens []accesstoken.AccessToken
+	for _, t := range r.tokens {
+		if t.UserId == userId {
+			tokens = append(tokens, t)
+		}
+	}
+
+	return tokens, nil
+}
+
+func (r *memoryRepository) Delete(_ context.Context, userId, tokenId int64) error {
+	t, ok := r.tokens[tokenId]
+	if !ok || t.UserId != userId {
+		return storage.ErrNotFound
+	}
+
+	delete(r.tokens, tokenId)
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateLastUsed(_ context.Context, tokenId int64) error {
+	t, ok := r.tokens[tokenId]
+	if !ok {
+		return storage.ErrNotFound
+	}
+
+	t.LastUsedAt.String = time.Now().Format(time.DateTime)
+	t.LastUsedAt.Valid = true
+	r.tokens[tokenId] = t
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package account
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"strings"
+)
+
+var (
+	ErrPasswordInvalid = errors.New("password invalid")
+	ErrSameEmail       = errors.New("email is the same")
+	ErrAccountExists   = errors.New("account exists")
+	// ErrReauthenticationRequired is returned to users with disabled password login, they confirm actions
+	// by signing in with the identity provider again
+	ErrReauthenticationRequired = errors.New("sign in with the identity provider is required")
+)
+
+// Confirmation proves that the user is present: the current password or the fresh sign in with the linked
+// identity provider, users provisioned by single sign-on don't know their password
+type Confirmation struct {
+	Password        string
+	Reauthenticated bool
+}
+
+// TypePurge is the job removing files and exports of the deleted account
+const TypePurge = "account.purge"
+
+type PurgePayload struct {
+	UserId int64 `json:"user_id"`
+}
+
+type Service struct {
+	pkg                 string
+	userService         UserService
+	userSessionService  UserSessionService
+	verificationService VerificationService
+	s3Service           S3Service
+	jobService          JobService
+}
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+	UserByEmail(ctx context.Context, email string) (user.User, error)
+	UpdatePassword(ctx context.Context, userId int64, password string) error
+	DeleteUser(ctx context.Context, userId int64) error
+}
+
+type UserSessionService interface {
+	DeleteOtherUserSessions(ctx context.Context, userId int64, refreshToken string) error
+}
+
+type VerificationService interface {
+	SendEmailChange(ctx context.Context, userId int64, newEmail string) error
+}
+
+type S3Service interface {
+	DeleteUserFolder(ctx context.Context, userId int64) error
+}
+
+type JobService interface {
+	EnqueueSystem(ctx context.Context, jobType string, payload any) (jobstorage.Job, error)
+}
+
+func NewService(
+	userService UserService,
+	userSessionService UserSessionService,
+	verificationService VerificationService,
+	s3Service S3Service,
+	jobService JobService,
+) *Service {
+	return &Service{
+		pkg:                 "account.service",
+		userService:         userService,
+		userSessionService:  userSessionService,
+		verificationService: verificationService,
+		s3Service:           s3Service,
+		jobService:          jobService,
+	}
+}
+
+// Register sets the handler of the purge job
+func (s *Service) Register(jobService *job.Service) {
+	job.Register(jobService, TypePurge, s.Purge)
+}
+
+// ChangePassword sets a new password and signs out all sessions except the current one
+func (s *Service) ChangePassword(ctx context.Context, userId int64, currentPassword, newPassword, refreshToken string) error {
+	const op = "ChangePassword"
+
+	if err := s.checkPassword(ctx, userId, currentPassword); err != nil {
+		return err
+	}
+
+	err := s.userService.UpdatePassword(ctx, userId, newPassword)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.userSessionService.DeleteOtherUserSessions(ctx, userId, refreshToken)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// ChangeEmail sends a confirmation link to the new email, the email is changed after confirmation
+func (s *Service) ChangeEmail(ctx context.Context, userId int64, confirmation Confirmation, newEmail string) error {
+	const op = "ChangeEmail"
+
+	us, err := s.confirm(ctx, userId, confirmation)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(us.Email.String, newEmail) {
+		return ErrSameEmail
+	}
+
+	_, err = s.userService.UserByEmail(ctx, newEmail)
+	if err == nil {
+		return userservice.ErrAlreadyExists
+	}
+
+	if !errors.Is(err, userservice.ErrNotFound) {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.verificationService.SendEmailChange(ctx, userId, newEmail)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// DeleteAccount removes the user with sessions and tokens. User's files are removed by the purge job, so they
+// are removed even if the app is restarted meanwhile
+func (s *Service) DeleteAccount(ctx context.Context, userId int64, confirmation Confirmation) error {
+	const op = "DeleteAccount"
+
+	if _, err := s.confirm(ctx, userId, confirmation); err != nil {
+		return err
+	}
+
+	err := s.userService.DeleteUser(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	// the account is deleted anyway, files left without the job are removed by the orphaned-folders maintenance
+	if _, err := s.jobService.EnqueueSystem(ctx, TypePurge, PurgePayload{UserId: userId}); err != nil {
+		logger.AddContext(ctx, s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Purge removes files and exports of the deleted account, objects removed by the interrupted run stay removed
+// and the next run removes the rest
+func (s *Service) Purge(ctx context.Context, _ *job.Run, payload PurgePayload) error {
+	// files of existing accounts are never removed by the job
+	_, err := s.userService.UserById(ctx, payload.UserId)
+	if err == nil {
+		return job.Permanent(ErrAccountExists)
+	}
+
+	if !errors.Is(err, userservice.ErrNotFound) {
+		return err
+	}
+
+	return s.s3Service.DeleteUserFolder(ctx, payload.UserId)
+}
+
+func (s *Service) checkPassword(ctx context.Context, userId int64, currentPassword string) error {
+	const op = "checkPassword"
+
+	us, err := s.userService.UserById(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if !password.CheckPassword(currentPassword, us.Password) {
+		return ErrPasswordInvalid
+	}
+
+	return nil
+}
+
+// confirm checks the confirmation of the action and returns the user, the password isn't checked
+// after the fresh sign in with the identity provider
+func (s *Service) confirm(ctx context.Context, userId int64, confirmation Confirmation) (user.User, error) {
+	const op = "confirm"
+
+	us, err := s.userService.UserById(ctx, userId)
+	if err != nil {
+		return user.User{}, logger.Error(s.pkg, op, err)
+	}
+
+	if confirmation.Reauthenticated {
+		return us, nil
+	}
+
+	if us.PasswordLoginDisabled {
+		return user.User{}, ErrReauthenticationRequired
+	}
+
+	if !password.CheckPassword(confirmation.Password, us.Password) {
+		return user.User{}, ErrPasswordInvalid
+	}
+
+	return us, nil
+}
@@ -0,0 +1,228 @@
+package account
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"testing"
+)
+
+type testService struct {
+	service  *Service
+	users    *memoryUserService
+	sessions *memorySessionService
+	s3       *memoryS3Service
+	jobs     *memoryJobService
+}
+
+type memoryUserService struct {
+	users map[int64]user.User
+}
+
+type memorySessionService struct {
+	keptRefreshToken string
+}
+
+type memoryS3Service struct {
+	deleted []int64
+}
+
+type memoryVerificationService struct{}
+
+type memoryJobService struct {
+	payloads []any
+}
+
+func TestAccountService_ChangePassword(t *testing.T) {
+	ts := accountTestService(t)
+
+	err := ts.service.ChangePassword(context.Background(), 1, "wrong", "new-secret", "refresh")
+	if !errors.Is(err, ErrPasswordInvalid) {
+		t.Errorf("wrong current password must return ErrPasswordInvalid, got: %v", err)
+	}
+
+	err = ts.service.ChangePassword(context.Background(), 1, "secret", "new-secret", "refresh")
+	if err != nil {
+		t.Fatalf("error while change password: %v", err)
+	}
+
+	if !password.CheckPassword("new-secret", ts.users.users[1].Password) {
+		t.Error("password is not changed")
+	}
+
+	if ts.sessions.keptRefreshToken != "refresh" {
+		t.Error("current session must be kept")
+	}
+}
+
+func TestAccountService_ChangeEmailDuplicate(t *testing.T) {
+	ts := accountTestService(t)
+
+	err := ts.service.ChangeEmail(context.Background(), 1, Confirmation{Password: "secret"}, "other@example.ru")
+	if !errors.Is(err, userservice.ErrAlreadyExists) {
+		t.Errorf("taken email must return ErrAlreadyExists, got: %v", err)
+	}
+}
+
+func TestAccountService_ConfirmWithIdentityProvider(t *testing.T) {
+	ts := accountTestService(t)
+	ctx := context.Background()
+
+	// the user provisioned by single sign-on doesn't know the password
+	us := ts.users.users[1]
+	us.PasswordLoginDisabled = true
+	ts.users.users[1] = us
+
+	err := ts.service.DeleteAccount(ctx, 1, Confirmation{Password: "secret"})
+	if !errors.Is(err, ErrReauthenticationRequired) {
+		t.Errorf("user without password login must sign in with the provider, got: %v", err)
+	}
+
+	err = ts.service.ChangeEmail(ctx, 1, Confirmation{}, "new@example.ru")
+	if !errors.Is(err, ErrReauthenticationRequired) {
+		t.Errorf("user without password login must sign in with the provider, got: %v", err)
+	}
+
+	if err := ts.service.ChangeEmail(ctx, 1, Confirmation{Reauthenticated: true}, "new@example.ru"); err != nil {
+		t.Errorf("fresh sign in with the provider must confirm the email change, got: %v", err)
+	}
+
+	if err := ts.service.DeleteAccount(ctx, 1, Confirmation{Reauthenticated: true}); err != nil {
+		t.Errorf("fresh sign in with the provider must confirm the deletion, got: %v", err)
+	}
+
+	if _, ok := ts.users.users[1]; ok {
+		t.Error("user is not deleted")
+	}
+
+	// the password is still checked for users who sign in with it
+	if err := ts.service.DeleteAccount(ctx, 2, Confirmation{}); !errors.Is(err, ErrPasswordInvalid) {
+		t.Errorf("missing password must return ErrPasswordInvalid, got: %v", err)
+	}
+}
+
+func TestAccountService_DeleteAccount(t *testing.T) {
+	ts := accountTestService(t)
+
+	err := ts.service.DeleteAccount(context.Background(), 1, Confirmation{Password: "secret"})
+	if err != nil {
+		t.Fatalf("error while delete account: %v", err)
+	}
+
+	if _, ok := ts.users.users[1]; ok {
+		t.Error("user is not deleted")
+	}
+
+	if len(ts.jobs.payloads) != 1 || ts.jobs.payloads[0] != (PurgePayload{UserId: 1}) {
+		t.Fatalf("purge of the user must be queued, got: %+v", ts.jobs.payloads)
+	}
+
+	if len(ts.s3.deleted) != 0 {
+		t.Errorf("user folder must be deleted by the job, got: %v", ts.s3.deleted)
+	}
+}
+
+func TestAccountService_Purge(t *testing.T) {
+	ts := accountTestService(t)
+	ctx := context.Background()
+
+	if err := ts.service.Purge(ctx, &job.Run{}, PurgePayload{UserId: 2}); !errors.Is(err, ErrAccountExists) {
+		t.Errorf("files of the existing account must not be removed, got: %v", err)
+	}
+
+	if err := ts.service.Purge(ctx, &job.Run{}, PurgePayload{UserId: 3}); err != nil {
+		t.Fatalf("error while purge account: %v", err)
+	}
+
+	if len(ts.s3.deleted) != 1 || ts.s3.deleted[0] != 3 {
+		t.Errorf("folder of the deleted user must be removed, got: %v", ts.s3.deleted)
+	}
+}
+
+func accountTestService(t *testing.T) *testService {
+	hashed, err := password.CreateHashedPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &memoryUserService{users: map[int64]user.User{
+		1: {Id: 1, Email: sql.NullString{String: "test@example.ru", Valid: true}, Password: hashed},
+		2: {Id: 2, Email: sql.NullString{String: "other@example.ru", Valid: true}, Password: hashed},
+	}}
+	sessions := &memorySessionService{}
+	s3 := &memoryS3Service{}
+	jobs := &memoryJobService{}
+
+	return &testService{
+		service:  NewService(users, sessions, &memoryVerificationService{}, s3, jobs),
+		users:    users,
+		sessions: sessions,
+		s3:       s3,
+		jobs:     jobs,
+	}
+}
+
+func (m *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	us, ok := m.users[userId]
+	if !ok {
+		return user.User{}, userservice.ErrNotFound
+	}
+
+	return us, nil
+}
+
+func (m *memoryUserService) UserByEmail(_ context.Context, email string) (user.User, error) {
+	for _, us := range m.users {
+		if us.Email.String == email {
+			return us, nil
+		}
+	}
+
+	return user.User{}, userservice.ErrNotFound
+}
+
+func (m *memoryUserService) UpdatePassword(_ context.Context, userId int64, newPassword string) error {
+	hashed, err := password.CreateHashedPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	us := m.users[userId]
+	us.Password = hashed
+	m.users[userId] = us
+
+	return nil
+}
+
+func (m *memoryUserService) DeleteUser(_ context.Context, userId int64) error {
+	delete(m.users, userId)
+
+	return nil
+}
+
+func (m *memorySessionService) DeleteOtherUserSessions(_ context.Context, _ int64, refreshToken string) error {
+	m.keptRefreshToken = refreshToken
+
+	return nil
+}
+
+func (m *memoryVerificationService) SendEmailChange(context.Context, int64, string) error {
+	return nil
+}
+
+func (m *memoryS3Service) DeleteUserFolder(_ context.Context, userId int64) error {
+	m.deleted = append(m.deleted, userId)
+
+	return nil
+}
+
+func (m *memoryJobService) EnqueueSystem(_ context.Context, _ string, payload any) (jobstorage.Job, error) {
+	m.payloads = append(m.payloads, payload)
+
+	return jobstorage.Job{Id: int64(len(m.payloads))}, nil
+}
@@ -0,0 +1,157 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+)
+
+var (
+	ErrNotFound = errors.New("user not found")
+	ErrSelf     = errors.New("administrator can't disable or demote own account")
+)
+
+type Service struct {
+	pkg                string
+	userService        UserService
+	userSessionService UserSessionService
+}
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+	Users(ctx context.Context, query string, limit, offset int) ([]user.User, int64, error)
+	SetRole(ctx context.Context, userId int64, role string) error
+	SetQuota(ctx context.Context, userId int64, quotaBytes int64) error
+	SetDisabled(ctx context.Context, userId int64, disabled bool) error
+}
+
+type UserSessionService interface {
+	DeleteUserSessions(ctx context.Context, userId int64) error
+}
+
+func NewService(userService UserService, userSessionService UserSessionService) *Service {
+	return &Service{
+		pkg:                "admin.service",
+		userService:        userService,
+		userSessionService: userSessionService,
+	}
+}
+
+// Users returns the page of users whose email contains the query and the total number of them
+func (s *Service) Users(ctx context.Context, query string, page, perPage int) ([]user.User, int64, error) {
+	const op = "Users"
+
+	users, total, err := s.userService.Users(ctx, query, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	return users, total, nil
+}
+
+func (s *Service) User(ctx context.Context, userId int64) (user.User, error) {
+	const op = "User"
+
+	us, err := s.userService.UserById(ctx, userId)
+	if err != nil {
+		if errors.Is(err, userservice.ErrNotFound) {
+			return user.User{}, ErrNotFound
+		}
+
+		return user.User{}, logger.Error(s.pkg, op, err)
+	}
+
+	return us, nil
+}
+
+// DisableUser blocks sign in of the user and signs out all the sessions
+func (s *Service) DisableUser(ctx context.Context, adminId, userId int64) error {
+	const op = "DisableUser"
+
+	if adminId == userId {
+		return ErrSelf
+	}
+
+	if _, err := s.User(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.userService.SetDisabled(ctx, userId, true); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if err := s.userSessionService.DeleteUserSessions(ctx, userId); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) EnableUser(ctx context.Context, userId int64) error {
+	const op = "EnableUser"
+
+	if _, err := s.User(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.userService.SetDisabled(ctx, userId, false); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) SetRole(ctx context.Context, adminId, userId int64, role string) error {
+	const op = "SetRole"
+
+	if adminId == userId {
+		return ErrSelf
+	}
+
+	if _, err := s.User(ctx, userId); err != nil {
+		return err
+	}
+
+	err := s.userService.SetRole(ctx, userId, role)
+	if err != nil {
+		if errors.Is(err, userservice.ErrInvalidRole) {
+			return err
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// SetQuota sets storage quota of the user in bytes, negative quota resets it to the default one
+func (s *Service) SetQuota(ctx context.Context, userId int64, quotaBytes int64) error {
+	const op = "SetQuota"
+
+	if _, err := s.User(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.userService.SetQuota(ctx, userId, quotaBytes); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Logout signs out all sessions of the user
+func (s *Service) Logout(ctx context.Context, userId int64) error {
+	const op = "Logout"
+
+	if _, err := s.User(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.userSessionService.DeleteUserSessions(ctx, userId); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
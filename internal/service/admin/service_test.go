@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"testing"
+	"time"
+)
+
+type memoryUserService struct {
+	users map[int64]user.User
+}
+
+type memorySessionService struct {
+	sessions map[int64]int
+}
+
+func TestAdminService_DisableUser(t *testing.T) {
+	users, sessions := adminTestServices()
+	service := NewService(users, sessions)
+
+	if err := service.DisableUser(context.Background(), 1, 1); !errors.Is(err, ErrSelf) {
+		t.Errorf("admin must not disable own account, got: %v", err)
+	}
+
+	if err := service.DisableUser(context.Background(), 1, 100); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown user must return ErrNotFound, got: %v", err)
+	}
+
+	if err := service.DisableUser(context.Background(), 1, 2); err != nil {
+		t.Fatalf("error while disable user: %v", err)
+	}
+
+	if !users.users[2].DisabledAt.Valid || sessions.sessions[2] != 0 {
+		t.Errorf("user must be disabled and signed out, got: %+v, %d sessions", users.users[2], sessions.sessions[2])
+	}
+
+	if err := service.EnableUser(context.Background(), 2); err != nil {
+		t.Fatalf("error while enable user: %v", err)
+	}
+
+	if users.users[2].DisabledAt.Valid {
+		t.Error("user must be enabled")
+	}
+}
+
+func TestAdminService_SetRole(t *testing.T) {
+	users, sessions := adminTestServices()
+	service := NewService(users, sessions)
+
+	if err := service.SetRole(context.Background(), 1, 1, userservice.RoleUser); !errors.Is(err, ErrSelf) {
+		t.Errorf("admin must not demote own account, got: %v", err)
+	}
+
+	if err := service.SetRole(context.Background(), 1, 2, "root"); !errors.Is(err, userservice.ErrInvalidRole) {
+		t.Errorf("unknown role must return ErrInvalidRole, got: %v", err)
+	}
+
+	if err := service.SetRole(context.Background(), 1, 2, userservice.RoleAdmin); err != nil {
+		t.Fatalf("error while set role: %v", err)
+	}
+
+	if users.users[2].Role != userservice.RoleAdmin {
+		t.Errorf("role must be admin, got: %s", users.users[2].Role)
+	}
+}
+
+func TestAdminService_Users(t *testing.T) {
+	users, sessions := adminTestServices()
+	service := NewService(users, sessions)
+
+	list, total, err := service.Users(context.Background(), "", 2, 1)
+	if err != nil {
+		t.Fatalf("error while list users: %v", err)
+	}
+
+	if total != 2 || len(list) != 1 || list[0].Id != 2 {
+		t.Errorf("second page must contain user 2 of 2, got: %v of %d", list, total)
+	}
+}
+
+func adminTestServices() (*memoryUserService, *memorySessionService) {
+	return &memoryUserService{users: map[int64]user.User{
+			1: {Id: 1, Role: userservice.RoleAdmin},
+			2: {Id: 2, Role: userservice.RoleUser},
+		}},
+		&memorySessionService{sessions: map[int64]int{1: 1, 2: 3}}
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	us, ok := s.users[userId]
+	if !ok {
+		return user.User{}, userservice.ErrNotFound
+	}
+
+	return us, nil
+}
+
+func (s *memoryUserService) Users(_ context.Context, query string, limit, offset int) ([]user.User, int64, error) {
+	var users []user.User
+	for id := int64(1); id <= int64(len(s.users)); id++ {
+		users = append(users, s.users[id])
+	}
+
+	if offset >= len(users) {
+		return []user.User{}, int64(len(users)), nil
+	}
+
+	return users[offset:min(offset+limit, len(users))], int64(len(users)), nil
+}
+
+func (s *memoryUserService) SetRole(_ context.Context, userId int64, role string) error {
+	if role != userservice.RoleUser && role != userservice.RoleAdmin {
+		return userservice.ErrInvalidRole
+	}
+
+	us := s.users[userId]
+	us.Role = role
+	s.users[userId] = us
+
+	return nil
+}
+
+func (s *memoryUserService) SetQuota(_ context.Context, userId int64, quotaBytes int64) error {
+	us := s.users[userId]
+	us.QuotaBytes = sql.NullInt64{Int64: quotaBytes, Valid: quotaBytes >= 0}
+	s.users[userId] = us
+
+	return nil
+}
+
+func (s *memoryUserService) SetDisabled(_ context.Context, userId int64, disabled bool) error {
+	us := s.users[userId]
+	us.DisabledAt = sql.NullString{String: time.Now().Format(time.DateTime), Valid: disabled}
+	s.users[userId] = us
+
+	return nil
+}
+
+func (s *memorySessionService) DeleteUserSessions(_ context.Context, userId int64) error {
+	s.sessions[userId] = 0
+
+	return nil
+}
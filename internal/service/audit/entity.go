@@ -0,0 +1,74 @@
+package audit
+
+import "time"
+
+const (
+	ActionSignIn           = "sign-in"
+	ActionRefresh          = "refresh"
+	ActionSignOut          = "sign-out"
+	ActionResourceCreate   = "resource.create"
+	ActionResourceMove     = "resource.move"
+	ActionResourceDelete   = "resource.delete"
+	ActionResourceDownload = "resource.download"
+	ActionResourceShare    = "resource.share"
+	ActionResourceExport   = "resource.export"
+)
+
+var Actions = []string{
+	ActionSignIn,
+	ActionRefresh,
+	ActionSignOut,
+	ActionResourceCreate,
+	ActionResourceMove,
+	ActionResourceDelete,
+	ActionResourceDownload,
+	ActionResourceShare,
+	ActionResourceExport,
+}
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// Event is an action to record
+type Event struct {
+	UserId     int64  // 0 - the actor is unknown
+	Email      string // email used to sign in
+	Action     string
+	Result     string
+	Ip         string
+	UserAgent  string
+	Path       string
+	TargetPath string
+}
+
+// Filter selects recorded events, zero values match everything
+type Filter struct {
+	UserId int64
+	Email  string
+	Action string
+	Result string
+	Ip     string
+	From   time.Time
+	To     time.Time
+}
+
+// Record is a recorded event as it is exported
+type Record struct {
+	Id         int64  `json:"id"`
+	UserId     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	Action     string `json:"action"`
+	Result     string `json:"result"`
+	Ip         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Path       string `json:"path"`
+	TargetPath string `json:"target_path"`
+	CreatedAt  string `json:"created_at"`
+}
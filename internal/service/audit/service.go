@@ -0,0 +1,295 @@
+package audit
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/auditlog"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// exportBatch number of entries read from the db at once during export
+const exportBatch = 500
+
+// column sizes of audit_logs table
+const (
+	maxEmailLength     = 254
+	maxIpLength        = 45
+	maxUserAgentLength = 255
+	maxPathLength      = 1024
+)
+
+var (
+	ErrUnknownAction = errors.New("unknown audit action")
+	ErrUnknownResult = errors.New("unknown audit result")
+	ErrUnknownFormat = errors.New("unknown export format")
+)
+
+type Service struct {
+	pkg  string
+	repo Repository
+	now  func() time.Time
+}
+
+type Repository interface {
+	Create(entry auditlog.Entry) error
+	Search(filter auditlog.Filter, limit, offset int) ([]auditlog.Entry, error)
+	Count(filter auditlog.Filter) (int64, error)
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{
+		pkg:  "audit.service",
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// Record appends the event to the audit log. Failures are only logged,
+// so an unavailable audit log doesn't break the action itself
+func (s *Service) Record(event Event) {
+	const op = "Record"
+
+	entry := auditlog.Entry{
+		Email:      nullString(truncate(event.Email, maxEmailLength)),
+		Action:     event.Action,
+		Result:     event.Result,
+		Ip:         truncate(event.Ip, maxIpLength),
+		UserAgent:  truncate(event.UserAgent, maxUserAgentLength),
+		Path:       nullString(truncate(event.Path, maxPathLength)),
+		TargetPath: nullString(truncate(event.TargetPath, maxPathLength)),
+		CreatedAt:  s.now().Format(time.DateTime),
+	}
+
+	if event.UserId != 0 {
+		entry.UserId = sql.NullInt64{Int64: event.UserId, Valid: true}
+	}
+
+	if err := s.repo.Create(entry); err != nil {
+		logger.Add(s.pkg, op, err)
+	}
+}
+
+// Events returns the page of events matching the filter, newest first, and the total number of them
+func (s *Service) Events(filter Filter, page, perPage int) ([]Record, int64, error) {
+	const op = "Events"
+
+	f, err := storageFilter(filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entries, err := s.repo.Search(f, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	total, err := s.repo.Count(f)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	records := make([]Record, 0, len(entries))
+	for _, e := range entries {
+		records = append(records, record(e))
+	}
+
+	return records, total, nil
+}
+
+// Export writes all events matching the filter in csv or json format, newest first
+func (s *Service) Export(w io.Writer, format string, filter Filter) error {
+	const op = "Export"
+
+	if format != FormatCSV && format != FormatJSON {
+		return ErrUnknownFormat
+	}
+
+	f, err := storageFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	var exporter recordWriter = newCSVWriter(w)
+	if format == FormatJSON {
+		exporter = newJSONWriter(w)
+	}
+
+	if err := exporter.begin(); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	// entries are read by id, so entries recorded during export don't shift the batches
+	for {
+		entries, err := s.repo.Search(f, exportBatch, 0)
+		if err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+
+		for _, e := range entries {
+			if err := exporter.write(record(e)); err != nil {
+				return logger.Error(s.pkg, op, err)
+			}
+		}
+
+		if len(entries) < exportBatch {
+			break
+		}
+
+		f.BeforeId = entries[len(entries)-1].Id
+	}
+
+	if err := exporter.end(); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func storageFilter(filter Filter) (auditlog.Filter, error) {
+	if filter.Action != "" && !slices.Contains(Actions, filter.Action) {
+		return auditlog.Filter{}, ErrUnknownAction
+	}
+
+	if filter.Result != "" && filter.Result != ResultSuccess && filter.Result != ResultFailure {
+		return auditlog.Filter{}, ErrUnknownResult
+	}
+
+	f := auditlog.Filter{
+		UserId: filter.UserId,
+		Email:  filter.Email,
+		Action: filter.Action,
+		Result: filter.Result,
+		Ip:     filter.Ip,
+	}
+
+	if !filter.From.IsZero() {
+		f.From = filter.From.Local().Format(time.DateTime)
+	}
+
+	if !filter.To.IsZero() {
+		f.To = filter.To.Local().Format(time.DateTime)
+	}
+
+	return f, nil
+}
+
+func record(e auditlog.Entry) Record {
+	return Record{
+		Id:         e.Id,
+		UserId:     e.UserId.Int64,
+		Email:      e.Email.String,
+		Action:     e.Action,
+		Result:     e.Result,
+		Ip:         e.Ip,
+		UserAgent:  e.UserAgent,
+		Path:       e.Path.String,
+		TargetPath: e.TargetPath.String,
+		CreatedAt:  e.CreatedAt,
+	}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// truncate cuts the string to the max number of runes
+func truncate(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+
+	return string(runes[:max])
+}
+
+type recordWriter interface {
+	begin() error
+	write(r Record) error
+	end() error
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) begin() error {
+	return c.w.Write([]string{
+		"id", "user_id", "email", "action", "result", "ip", "user_agent", "path", "target_path", "created_at",
+	})
+}
+
+func (c *csvWriter) write(r Record) error {
+	userId := ""
+	if r.UserId != 0 {
+		userId = strconv.FormatInt(r.UserId, 10)
+	}
+
+	return c.w.Write([]string{
+		strconv.FormatInt(r.Id, 10),
+		userId,
+		r.Email,
+		r.Action,
+		r.Result,
+		r.Ip,
+		r.UserAgent,
+		r.Path,
+		r.TargetPath,
+		r.CreatedAt,
+	})
+}
+
+func (c *csvWriter) end() error {
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+// jsonWriter writes records as a json array without keeping all of them in memory
+type jsonWriter struct {
+	w     io.Writer
+	first bool
+}
+
+func newJSONWriter(w io.Writer) *jsonWriter {
+	return &jsonWriter{w: w, first: true}
+}
+
+func (j *jsonWriter) begin() error {
+	_, err := io.WriteString(j.w, "[")
+
+	return err
+}
+
+func (j *jsonWriter) write(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if !j.first {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.first = false
+
+	_, err = j.w.Write(data)
+
+	return err
+}
+
+func (j *jsonWriter) end() error {
+	_, err := io.WriteString(j.w, "]")
+
+	return err
+}
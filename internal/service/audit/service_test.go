@@ -0,0 +1,176 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/auditlog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryRepository struct {
+	entries []auditlog.Entry
+}
+
+func TestAuditService_RecordAndEvents(t *testing.T) {
+	repo := &memoryRepository{}
+	service := NewService(repo)
+
+	service.Record(Event{Email: "user@example.com", Action: ActionSignIn, Result: ResultFailure, Ip: "127.0.0.1"})
+	service.Record(Event{UserId: 1, Action: ActionSignIn, Result: ResultSuccess, Ip: "127.0.0.1"})
+	service.Record(Event{
+		UserId:     1,
+		Action:     ActionResourceMove,
+		Result:     ResultSuccess,
+		UserAgent:  strings.Repeat("a", 300),
+		Path:       "/a.txt",
+		TargetPath: "/b/a.txt",
+	})
+	service.Record(Event{UserId: 2, Action: ActionResourceDelete, Result: ResultSuccess, Path: "/c.txt"})
+
+	if repo.entries[0].UserId.Valid || repo.entries[0].Email.String != "user@example.com" {
+		t.Errorf("unknown actor must be recorded with the email only, got: %+v", repo.entries[0])
+	}
+
+	if len(repo.entries[2].UserAgent) != maxUserAgentLength {
+		t.Errorf("user agent must be truncated to %d, got: %d", maxUserAgentLength, len(repo.entries[2].UserAgent))
+	}
+
+	records, total, err := service.Events(Filter{UserId: 1}, 1, 10)
+	if err != nil {
+		t.Fatalf("error while get events: %v", err)
+	}
+
+	if total != 2 || len(records) != 2 {
+		t.Fatalf("user must have 2 events, got: %d, %d", total, len(records))
+	}
+
+	// newest first
+	if records[0].Action != ActionResourceMove || records[0].TargetPath != "/b/a.txt" {
+		t.Errorf("the newest event must be first, got: %+v", records[0])
+	}
+
+	records, total, err = service.Events(Filter{UserId: 1, Action: ActionSignIn}, 1, 10)
+	if err != nil || total != 1 || records[0].Result != ResultSuccess {
+		t.Errorf("events must be filtered by action, got: %+v, %v", records, err)
+	}
+
+	_, _, err = service.Events(Filter{Action: "unknown"}, 1, 10)
+	if !errors.Is(err, ErrUnknownAction) {
+		t.Errorf("unknown action must return ErrUnknownAction, got: %v", err)
+	}
+
+	_, _, err = service.Events(Filter{Result: "unknown"}, 1, 10)
+	if !errors.Is(err, ErrUnknownResult) {
+		t.Errorf("unknown result must return ErrUnknownResult, got: %v", err)
+	}
+}
+
+func TestAuditService_Export(t *testing.T) {
+	repo := &memoryRepository{}
+	service := NewService(repo)
+
+	// more than one batch
+	count := exportBatch + 10
+	for i := 0; i < count; i++ {
+		service.Record(Event{UserId: 1, Action: ActionResourceDownload, Result: ResultSuccess, Path: "/a,\"b\".txt"})
+	}
+	service.Record(Event{UserId: 2, Action: ActionResourceDownload, Result: ResultSuccess})
+
+	var buf bytes.Buffer
+	if err := service.Export(&buf, FormatJSON, Filter{UserId: 1}); err != nil {
+		t.Fatalf("error while export json: %v", err)
+	}
+
+	var records []Record
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("export must be a valid json array: %v", err)
+	}
+
+	if len(records) != count {
+		t.Fatalf("json export must contain %d records, got: %d", count, len(records))
+	}
+
+	if records[0].Id != int64(count) || records[count-1].Id != 1 {
+		t.Errorf("records must be exported newest first, got ids %d..%d", records[0].Id, records[count-1].Id)
+	}
+
+	buf.Reset()
+	if err := service.Export(&buf, FormatCSV, Filter{UserId: 1}); err != nil {
+		t.Fatalf("error while export csv: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("export must be a valid csv: %v", err)
+	}
+
+	if len(rows) != count+1 || rows[0][0] != "id" || rows[1][7] != "/a,\"b\".txt" {
+		t.Errorf("csv export must contain the header and %d rows, got: %d rows, %v", count, len(rows), rows[1])
+	}
+
+	if err := service.Export(&buf, "xml", Filter{}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format must return ErrUnknownFormat, got: %v", err)
+	}
+}
+
+func TestAuditService_FilterByTime(t *testing.T) {
+	repo := &memoryRepository{}
+	service := NewService(repo)
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.Local)
+	service.now = func() time.Time { return now.Add(-time.Hour) }
+	service.Record(Event{UserId: 1, Action: ActionSignOut, Result: ResultSuccess})
+	service.now = func() time.Time { return now }
+	service.Record(Event{UserId: 1, Action: ActionRefresh, Result: ResultSuccess})
+
+	records, total, err := service.Events(Filter{From: now.Add(-time.Minute)}, 1, 10)
+	if err != nil || total != 1 || records[0].Action != ActionRefresh {
+		t.Errorf("events must be filtered by time, got: %+v, %v", records, err)
+	}
+}
+
+func (m *memoryRepository) Create(entry auditlog.Entry) error {
+	entry.Id = int64(len(m.entries) + 1)
+	m.entries = append(m.entries, entry)
+
+	return nil
+}
+
+func (m *memoryRepository) Search(filter auditlog.Filter, limit, offset int) ([]auditlog.Entry, error) {
+	var found []auditlog.Entry
+	for i := len(m.entries) - 1; i >= 0; i-- {
+		if match(m.entries[i], filter) {
+			found = append(found, m.entries[i])
+		}
+	}
+
+	if offset >= len(found) {
+		return []auditlog.Entry{}, nil
+	}
+
+	return found[offset:min(offset+limit, len(found))], nil
+}
+
+func (m *memoryRepository) Count(filter auditlog.Filter) (int64, error) {
+	var count int64
+	for _, e := range m.entries {
+		if match(e, filter) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func match(e auditlog.Entry, f auditlog.Filter) bool {
+	return (f.UserId == 0 || e.UserId.Int64 == f.UserId) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Result == "" || e.Result == f.Result) &&
+		(f.From == "" || e.CreatedAt >= f.From) &&
+		(f.To == "" || e.CreatedAt < f.To) &&
+		(f.BeforeId == 0 || e.Id < f.BeforeId)
+}
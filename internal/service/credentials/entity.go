@@ -0,0 +1,28 @@
+package credentials
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"time"
+)
+
+type Config struct {
+	// CacheTTL is how long the checked password is accepted without checking it again,
+	// clients of WebDAV and SFTP send it with every request
+	CacheTTL time.Duration
+}
+
+// Identity is the user authenticated with the credentials
+type Identity struct {
+	UserId      int64
+	AccessToken accesstoken.Token // zero if the user is authenticated with the password
+}
+
+// IsAccessToken reports whether the user is authenticated with a personal access token
+func (i Identity) IsAccessToken() bool {
+	return i.AccessToken.Id != 0
+}
+
+type verifiedPassword struct {
+	userId    int64
+	expiresAt time.Time
+}
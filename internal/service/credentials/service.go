@@ -0,0 +1,216 @@
+package credentials
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/email"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalid               = errors.New("credentials invalid")
+	ErrAccountDisabled       = errors.New("account disabled")
+	ErrPasswordLoginDisabled = errors.New("password login disabled")
+)
+
+// Service authenticates clients which send the credentials with every request instead of signing in,
+// e.g. WebDAV and SFTP. The secret is either the password of the user or a personal access token.
+type Service struct {
+	pkg                string
+	conf               *Config
+	userService        UserService
+	accessTokenService AccessTokenService
+	loginGuard         LoginGuard
+	// key of the cache entries, so the passwords aren't kept in memory
+	key      []byte
+	mu       sync.Mutex
+	verified map[string]verifiedPassword
+}
+
+type UserService interface {
+	UserByEmail(ctx context.Context, email string) (user.User, error)
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+type AccessTokenService interface {
+	ValidateToken(ctx context.Context, token string) (accesstoken.Token, error)
+}
+
+type LoginGuard interface {
+	Check(ctx context.Context, email string) (time.Duration, error)
+	Failed(ctx context.Context, email string) error
+	Succeeded(ctx context.Context, email string) error
+}
+
+func NewService(
+	conf *Config,
+	userService UserService,
+	accessTokenService AccessTokenService,
+	loginGuard LoginGuard,
+) *Service {
+	key := make([]byte, 32)
+	_, _ = rand.Read(key)
+
+	return &Service{
+		pkg:                "credentials.service",
+		conf:               conf,
+		userService:        userService,
+		accessTokenService: accessTokenService,
+		loginGuard:         loginGuard,
+		key:                key,
+		verified:           map[string]verifiedPassword{},
+	}
+}
+
+// Authenticate checks the email and the password or the personal access token, the username is ignored
+// for tokens. Password attempts are protected by the login guard, it returns the time the client has to wait
+// with loginguard.ErrLocked or loginguard.ErrTooManyAttempts.
+func (s *Service) Authenticate(ctx context.Context, username, secret string) (Identity, time.Duration, error) {
+	const op = "Authenticate"
+
+	if accesstoken.IsAccessToken(secret) {
+		t, err := s.accessTokenService.ValidateToken(ctx, secret)
+		if err != nil {
+			if errors.Is(err, accesstoken.ErrInvalid) || errors.Is(err, accesstoken.ErrExpired) {
+				return Identity{}, 0, ErrInvalid
+			}
+
+			return Identity{}, 0, logger.Error(s.pkg, op, err)
+		}
+
+		return Identity{UserId: t.UserId, AccessToken: t}, 0, nil
+	}
+
+	// the email is searched case-insensitively, so each spelling of it must share the counters of the login guard
+	username = email.Normalize(username)
+
+	if username == "" || secret == "" {
+		return Identity{}, 0, ErrInvalid
+	}
+
+	cacheKey := s.cacheKey(username, secret)
+	if userId, ok := s.cached(cacheKey); ok {
+		// the state of the account is checked on every request, so disabling takes effect at once
+		us, err := s.userService.UserById(ctx, userId)
+		if err != nil {
+			if errors.Is(err, userservice.ErrNotFound) {
+				return Identity{}, 0, ErrInvalid
+			}
+
+			return Identity{}, 0, logger.Error(s.pkg, op, err)
+		}
+
+		identity, err := s.identity(us)
+
+		return identity, 0, err
+	}
+
+	retryAfter, err := s.loginGuard.Check(ctx, username)
+	if err != nil {
+		if errors.Is(err, loginguard.ErrLocked) || errors.Is(err, loginguard.ErrTooManyAttempts) {
+			return Identity{}, retryAfter, err
+		}
+
+		// the store is unavailable, don't block users because of it
+		logger.AddContext(ctx, s.pkg, op, err)
+	}
+
+	us, err := s.userService.UserByEmail(ctx, username)
+	if err != nil {
+		if !errors.Is(err, userservice.ErrNotFound) {
+			return Identity{}, 0, logger.Error(s.pkg, op, err)
+		}
+
+		s.failed(ctx, username)
+
+		return Identity{}, 0, ErrInvalid
+	}
+
+	if !password.CheckPassword(secret, us.Password) {
+		s.failed(ctx, username)
+
+		return Identity{}, 0, ErrInvalid
+	}
+
+	if err := s.loginGuard.Succeeded(ctx, username); err != nil {
+		logger.AddContext(ctx, s.pkg, op, err)
+	}
+
+	identity, err := s.identity(us)
+	if err != nil {
+		return Identity{}, 0, err
+	}
+
+	s.cache(cacheKey, us.Id)
+
+	return identity, 0, nil
+}
+
+// identity checks the state of the account authenticated with the password
+func (s *Service) identity(us user.User) (Identity, error) {
+	if us.DisabledAt.Valid {
+		return Identity{}, ErrAccountDisabled
+	}
+
+	if us.PasswordLoginDisabled {
+		return Identity{}, ErrPasswordLoginDisabled
+	}
+
+	return Identity{UserId: us.Id}, nil
+}
+
+func (s *Service) failed(ctx context.Context, email string) {
+	if err := s.loginGuard.Failed(ctx, email); err != nil {
+		logger.AddContext(ctx, s.pkg, "failed", err)
+	}
+}
+
+func (s *Service) cached(key string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v, ok := s.verified[key]
+	if !ok || time.Now().After(v.expiresAt) {
+		return 0, false
+	}
+
+	return v.userId, true
+}
+
+func (s *Service) cache(key string, userId int64) {
+	if s.conf.CacheTTL <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.verified {
+		if now.After(v.expiresAt) {
+			delete(s.verified, k)
+		}
+	}
+
+	s.verified[key] = verifiedPassword{userId: userId, expiresAt: now.Add(s.conf.CacheTTL)}
+}
+
+func (s *Service) cacheKey(email, secret string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(email))
+	mac.Write([]byte{0})
+	mac.Write([]byte(secret))
+
+	return string(mac.Sum(nil))
+}
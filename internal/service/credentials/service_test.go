@@ -0,0 +1,203 @@
+package credentials
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"testing"
+	"time"
+)
+
+type memoryUserService struct {
+	users map[int64]user.User
+}
+
+type memoryAccessTokenService struct {
+	tokens map[string]accesstoken.Token
+}
+
+type memoryLoginGuard struct {
+	checks   int
+	failures map[string]int
+}
+
+func TestCredentialsService_AuthenticatePassword(t *testing.T) {
+	service, guard := credentialsTestService(t)
+	ctx := context.Background()
+
+	identity, _, err := service.Authenticate(ctx, "test@example.ru", "secret")
+	if err != nil {
+		t.Fatalf("error while authenticate with password: %v", err)
+	}
+
+	if identity.UserId != 1 || identity.IsAccessToken() {
+		t.Errorf("user 1 must be authenticated with password, got: %+v", identity)
+	}
+
+	// the password is checked once while it's cached
+	if _, _, err := service.Authenticate(ctx, "test@example.ru", "secret"); err != nil {
+		t.Fatalf("error while authenticate with cached password: %v", err)
+	}
+
+	if guard.checks != 1 {
+		t.Errorf("cached password must not be checked again, got %d checks", guard.checks)
+	}
+
+	_, _, err = service.Authenticate(ctx, "test@example.ru", "wrong")
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("wrong password must return ErrInvalid, got: %v", err)
+	}
+
+	_, _, err = service.Authenticate(ctx, "unknown@example.ru", "secret")
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown email must return ErrInvalid, got: %v", err)
+	}
+
+	if guard.failures["test@example.ru"] != 1 || guard.failures["unknown@example.ru"] != 1 {
+		t.Errorf("failed attempts must be registered, got: %v", guard.failures)
+	}
+
+	// other spellings of the email are the same account for the login guard
+	_, _, err = service.Authenticate(ctx, " Test@Example.RU", "wrong")
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("wrong password must return ErrInvalid, got: %v", err)
+	}
+
+	if guard.failures["test@example.ru"] != 2 || len(guard.failures) != 2 {
+		t.Errorf("failed attempts must be registered for the normalized email, got: %v", guard.failures)
+	}
+
+	if identity, _, err := service.Authenticate(ctx, "TEST@example.ru", "secret"); err != nil || identity.UserId != 1 {
+		t.Errorf("user 1 must be authenticated with the email in upper case, got: %+v %v", identity, err)
+	}
+}
+
+func TestCredentialsService_AuthenticateAccountState(t *testing.T) {
+	service, _ := credentialsTestService(t)
+	ctx := context.Background()
+
+	_, _, err := service.Authenticate(ctx, "disabled@example.ru", "secret")
+	if !errors.Is(err, ErrAccountDisabled) {
+		t.Errorf("disabled account must return ErrAccountDisabled, got: %v", err)
+	}
+
+	_, _, err = service.Authenticate(ctx, "sso@example.ru", "secret")
+	if !errors.Is(err, ErrPasswordLoginDisabled) {
+		t.Errorf("account without password login must return ErrPasswordLoginDisabled, got: %v", err)
+	}
+
+	_, _, err = service.Authenticate(ctx, "locked@example.ru", "secret")
+	if !errors.Is(err, loginguard.ErrLocked) {
+		t.Errorf("locked account must return loginguard.ErrLocked, got: %v", err)
+	}
+}
+
+func TestCredentialsService_AuthenticateAccessToken(t *testing.T) {
+	service, guard := credentialsTestService(t)
+	ctx := context.Background()
+
+	identity, _, err := service.Authenticate(ctx, "anything", accesstoken.Prefix+"valid")
+	if err != nil {
+		t.Fatalf("error while authenticate with access token: %v", err)
+	}
+
+	if identity.UserId != 1 || !identity.IsAccessToken() || identity.AccessToken.Folder != "/docs" {
+		t.Errorf("user 1 must be authenticated with the access token, got: %+v", identity)
+	}
+
+	_, _, err = service.Authenticate(ctx, "test@example.ru", accesstoken.Prefix+"revoked")
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown access token must return ErrInvalid, got: %v", err)
+	}
+
+	if guard.checks != 0 {
+		t.Errorf("access tokens must not be checked by the login guard, got %d checks", guard.checks)
+	}
+}
+
+func credentialsTestService(t *testing.T) (*Service, *memoryLoginGuard) {
+	hash, err := password.CreateHashedPassword("secret")
+	if err != nil {
+		t.Fatalf("error while hash password: %v", err)
+	}
+
+	guard := &memoryLoginGuard{failures: map[string]int{}}
+
+	return NewService(
+		&Config{CacheTTL: time.Minute},
+		&memoryUserService{users: map[int64]user.User{
+			1: {Id: 1, Email: sql.NullString{String: "test@example.ru", Valid: true}, Password: hash},
+			2: {
+				Id:         2,
+				Email:      sql.NullString{String: "disabled@example.ru", Valid: true},
+				Password:   hash,
+				DisabledAt: sql.NullString{String: "2026-01-01 00:00:00", Valid: true},
+			},
+			3: {
+				Id:                    3,
+				Email:                 sql.NullString{String: "sso@example.ru", Valid: true},
+				Password:              hash,
+				PasswordLoginDisabled: true,
+			},
+		}},
+		&memoryAccessTokenService{tokens: map[string]accesstoken.Token{
+			accesstoken.Prefix + "valid": {Id: 1, UserId: 1, Scopes: []string{accesstoken.ScopeRead}, Folder: "/docs"},
+		}},
+		guard,
+	), guard
+}
+
+func (s *memoryUserService) UserByEmail(_ context.Context, email string) (user.User, error) {
+	for _, us := range s.users {
+		if us.Email.String == email {
+			return us, nil
+		}
+	}
+
+	return user.User{}, userservice.ErrNotFound
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	us, ok := s.users[userId]
+	if !ok {
+		return user.User{}, userservice.ErrNotFound
+	}
+
+	return us, nil
+}
+
+func (s *memoryAccessTokenService) ValidateToken(_ context.Context, token string) (accesstoken.Token, error) {
+	t, ok := s.tokens[token]
+	if !ok {
+		return accesstoken.Token{}, accesstoken.ErrInvalid
+	}
+
+	return t, nil
+}
+
+func (g *memoryLoginGuard) Check(_ context.Context, email string) (time.Duration, error) {
+	g.checks++
+
+	if email == "locked@example.ru" {
+		return time.Minute, loginguard.ErrLocked
+	}
+
+	return 0, nil
+}
+
+func (g *memoryLoginGuard) Failed(_ context.Context, email string) error {
+	g.failures[email]++
+
+	return nil
+}
+
+func (g *memoryLoginGuard) Succeeded(_ context.Context, email string) error {
+	delete(g.failures, email)
+
+	return nil
+}
@@ -0,0 +1,379 @@
+package dav
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/net/webdav"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// deadPropsKey is the user metadata of the object keeping its dead properties
+	deadPropsKey = "Dav-Props"
+	// maxDeadPropsSize leaves a room in 2 KB of user metadata S3 allows
+	maxDeadPropsSize = 1800
+)
+
+// file is opened for reading or writing. The written content is kept in a temporary file
+// and stored on close, as S3 objects can't be changed in place
+type file struct {
+	ctx  context.Context
+	fs   *fileSystem
+	name string
+	info *fileInfo
+
+	object  *minio.Object // opened on the first read
+	entries []os.FileInfo // listed on the first read of the directory
+
+	dirty bool
+	tmp   *os.File
+	// dead properties patched before the file is stored
+	pendingProps bool
+}
+
+type fileInfo struct {
+	name        string
+	size        int64
+	modTime     time.Time
+	isDir       bool
+	etag        string // empty for directories without the object of its own
+	contentType string
+	metadata    map[string]string
+}
+
+// deadProperty is the stored webdav.Property
+type deadProperty struct {
+	Space    string `json:"s"`
+	Local    string `json:"l"`
+	Lang     string `json:"g,omitempty"`
+	InnerXML string `json:"x"`
+}
+
+func newFileInfo(name string, object minio.ObjectInfo, isDir bool) *fileInfo {
+	return &fileInfo{
+		name:        name,
+		size:        object.Size,
+		modTime:     object.LastModified,
+		isDir:       isDir,
+		etag:        object.ETag,
+		contentType: object.ContentType,
+		metadata:    object.UserMetadata,
+	}
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.info.isDir {
+		return 0, errIsDirectory
+	}
+
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+
+	return f.object.Read(p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.info.isDir {
+		return 0, errIsDirectory
+	}
+
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+
+	return f.object.Seek(offset, whence)
+}
+
+func (f *file) Write(p []byte) (int, error) {
+	if f.info.isDir {
+		return 0, errIsDirectory
+	}
+
+	if f.tmp == nil {
+		tmp, err := os.CreateTemp("", "cfs-dav-*")
+		if err != nil {
+			return 0, err
+		}
+
+		f.tmp = tmp
+		f.dirty = true
+		f.info.size = 0
+	}
+
+	n, err := f.tmp.Write(p)
+	f.info.size += int64(n)
+
+	return n, err
+}
+
+func (f *file) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.isDir {
+		return nil, errNotDirectory
+	}
+
+	if f.entries == nil {
+		list := f.fs.s3Service.PaginateDirectory(f.ctx, f.fs.userId, f.fs.path(f.name, true))
+
+		f.entries = make([]os.FileInfo, 0, len(*list))
+		for _, r := range *list {
+			f.entries = append(f.entries, &fileInfo{
+				name:  path.Join(f.name, r.Name),
+				size:  r.Size,
+				isDir: r.Type == "DIRECTORY",
+			})
+		}
+	}
+
+	if count <= 0 {
+		entries := f.entries
+		f.entries = f.entries[len(f.entries):]
+
+		return entries, nil
+	}
+
+	if len(f.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	n := min(count, len(f.entries))
+	entries := f.entries[:n]
+	f.entries = f.entries[n:]
+
+	return entries, nil
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *file) Close() error {
+	var errs []error
+
+	if f.object != nil {
+		errs = append(errs, f.object.Close())
+	}
+
+	if f.dirty {
+		errs = append(errs, f.store())
+	}
+
+	if f.tmp != nil {
+		errs = append(errs, f.tmp.Close(), os.Remove(f.tmp.Name()))
+	}
+
+	return errors.Join(errs...)
+}
+
+// DeadProps returns the properties set by clients, they are kept in the user metadata of the object
+func (f *file) DeadProps() (map[xml.Name]webdav.Property, error) {
+	props := map[xml.Name]webdav.Property{}
+
+	value := f.info.metadata[deadPropsKey]
+	if value == "" {
+		return props, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored []deadProperty
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+
+	for _, p := range stored {
+		name := xml.Name{Space: p.Space, Local: p.Local}
+		props[name] = webdav.Property{XMLName: name, Lang: p.Lang, InnerXML: []byte(p.InnerXML)}
+	}
+
+	return props, nil
+}
+
+func (f *file) Patch(patches []webdav.Proppatch) ([]webdav.Propstat, error) {
+	props, err := f.DeadProps()
+	if err != nil {
+		return nil, err
+	}
+
+	pstat := webdav.Propstat{Status: http.StatusOK}
+	for _, patch := range patches {
+		for _, p := range patch.Props {
+			pstat.Props = append(pstat.Props, webdav.Property{XMLName: p.XMLName})
+
+			if patch.Remove {
+				delete(props, p.XMLName)
+			} else {
+				props[p.XMLName] = p
+			}
+		}
+	}
+
+	value, err := encodeDeadProps(props)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(value) > maxDeadPropsSize {
+		pstat.Status = http.StatusInsufficientStorage
+
+		return []webdav.Propstat{pstat}, nil
+	}
+
+	metadata := map[string]string{}
+	if value != "" {
+		metadata[deadPropsKey] = value
+	}
+
+	f.info.metadata = metadata
+
+	// the new content is stored with the properties on close
+	if f.dirty {
+		f.pendingProps = true
+
+		return []webdav.Propstat{pstat}, nil
+	}
+
+	if err := f.storeProps(); err != nil {
+		return nil, err
+	}
+
+	return []webdav.Propstat{pstat}, nil
+}
+
+func (f *file) open() error {
+	if f.object != nil {
+		return nil
+	}
+
+	object, err := f.fs.s3Service.Object(f.ctx, f.fs.path(f.name, false))
+	if err != nil {
+		return err
+	}
+
+	f.object = object
+
+	return nil
+}
+
+func (f *file) store() error {
+	var (
+		reader io.Reader = strings.NewReader("")
+		size   int64
+	)
+
+	if f.tmp != nil {
+		stat, err := f.tmp.Stat()
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		reader, size = f.tmp, stat.Size()
+	}
+
+	if _, err := f.fs.s3Service.StoreFile(f.ctx, f.fs.path(f.name, false), reader, size); err != nil {
+		return err
+	}
+
+	if f.pendingProps {
+		return f.storeProps()
+	}
+
+	return nil
+}
+
+func (f *file) storeProps() error {
+	// the directory gets the object of its own to keep the properties
+	if f.info.isDir && f.info.etag == "" {
+		if _, err := f.fs.s3Service.StoreDirectory(f.ctx, f.fs.path(f.name, true)); err != nil {
+			return err
+		}
+	}
+
+	return f.fs.s3Service.SetMetadata(f.ctx, f.fs.path(f.name, f.info.isDir), f.info.metadata)
+}
+
+func encodeDeadProps(props map[xml.Name]webdav.Property) (string, error) {
+	if len(props) == 0 {
+		return "", nil
+	}
+
+	stored := make([]deadProperty, 0, len(props))
+	for name, p := range props {
+		stored = append(stored, deadProperty{
+			Space:    name.Space,
+			Local:    name.Local,
+			Lang:     p.Lang,
+			InnerXML: string(p.InnerXML),
+		})
+	}
+
+	data, err := json.Marshal(stored)
+	if err != nil {
+		return "", err
+	}
+
+	// user metadata of S3 objects must be ASCII
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+func (fi *fileInfo) Name() string {
+	return path.Base(fi.name)
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.isDir
+}
+
+func (fi *fileInfo) Sys() any {
+	return nil
+}
+
+// ETag returns the ETag of the object, so it changes only with the content
+func (fi *fileInfo) ETag(context.Context) (string, error) {
+	if fi.etag == "" {
+		return "", webdav.ErrNotImplemented
+	}
+
+	return `"` + fi.etag + `"`, nil
+}
+
+// ContentType returns the content type the object is stored with, without reading its content
+func (fi *fileInfo) ContentType(context.Context) (string, error) {
+	if fi.isDir || fi.contentType == "" {
+		return "", webdav.ErrNotImplemented
+	}
+
+	return fi.contentType, nil
+}
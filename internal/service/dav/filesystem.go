@@ -0,0 +1,181 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"golang.org/x/net/webdav"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	errIsDirectory  = errors.New("resource is a directory")
+	errNotDirectory = errors.New("resource is not a directory")
+)
+
+// fileSystem is the tree of the user's files inside the root folder. Directories are objects with
+// the tailing slash, a directory without the object of its own exists while it has objects inside
+type fileSystem struct {
+	s3Service S3Service
+	userId    int64
+	root      string // path to the root folder in the bucket: "user-USER_ID-files/folder"
+}
+
+func (fs *fileSystem) Mkdir(ctx context.Context, name string, _ os.FileMode) error {
+	name = cleanName(name)
+
+	_, err := fs.stat(ctx, name)
+	if err == nil {
+		return os.ErrExist
+	}
+
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := fs.checkParent(ctx, name); err != nil {
+		return err
+	}
+
+	_, err = fs.s3Service.StoreDirectory(ctx, fs.path(name, true))
+
+	return err
+}
+
+func (fs *fileSystem) OpenFile(ctx context.Context, name string, flag int, _ os.FileMode) (webdav.File, error) {
+	name = cleanName(name)
+
+	info, err := fs.stat(ctx, name)
+	if err != nil {
+		if !os.IsNotExist(err) || flag&os.O_CREATE == 0 {
+			return nil, err
+		}
+
+		if err := fs.checkParent(ctx, name); err != nil {
+			return nil, err
+		}
+
+		// the new file is stored on close, even if nothing is written
+		return &file{ctx: ctx, fs: fs, name: name, info: &fileInfo{name: name, modTime: time.Now()}, dirty: true}, nil
+	}
+
+	if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, os.ErrExist
+	}
+
+	if info.isDir && flag&os.O_TRUNC != 0 {
+		return nil, errIsDirectory
+	}
+
+	return &file{ctx: ctx, fs: fs, name: name, info: info, dirty: flag&os.O_TRUNC != 0}, nil
+}
+
+func (fs *fileSystem) RemoveAll(ctx context.Context, name string) error {
+	name = cleanName(name)
+	if name == "/" {
+		return os.ErrPermission
+	}
+
+	info, err := fs.stat(ctx, name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	return fs.s3Service.Delete(ctx, fs.path(name, info.isDir))
+}
+
+func (fs *fileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = cleanName(oldName), cleanName(newName)
+	if oldName == "/" || newName == "/" {
+		return os.ErrPermission
+	}
+
+	// a directory can't be moved inside itself
+	if strings.HasPrefix(newName, oldName+"/") {
+		return os.ErrInvalid
+	}
+
+	info, err := fs.stat(ctx, oldName)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.checkParent(ctx, newName); err != nil {
+		return err
+	}
+
+	return fs.s3Service.Move(ctx, fs.path(newName, info.isDir), fs.path(oldName, info.isDir))
+}
+
+func (fs *fileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	info, err := fs.stat(ctx, cleanName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (fs *fileSystem) stat(ctx context.Context, name string) (*fileInfo, error) {
+	// the root folder always exists, even before anything is stored
+	if name == "/" {
+		return &fileInfo{name: name, isDir: true}, nil
+	}
+
+	object, err := fs.s3Service.Stat(ctx, fs.path(name, false))
+	if err == nil {
+		return newFileInfo(name, object, false), nil
+	}
+
+	if !errors.Is(err, s3.ErrNotFound) {
+		return nil, err
+	}
+
+	object, err = fs.s3Service.Stat(ctx, fs.path(name, true))
+	if err != nil {
+		if errors.Is(err, s3.ErrNotFound) {
+			return nil, os.ErrNotExist
+		}
+
+		return nil, err
+	}
+
+	return newFileInfo(name, object, true), nil
+}
+
+// checkParent returns os.ErrNotExist if the parent directory of the name doesn't exist,
+// so WebDAV responds with 409 Conflict
+func (fs *fileSystem) checkParent(ctx context.Context, name string) error {
+	parent, err := fs.stat(ctx, path.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	if !parent.isDir {
+		return os.ErrNotExist
+	}
+
+	return nil
+}
+
+// path returns the path in the bucket of the name inside the root folder
+func (fs *fileSystem) path(name string, isDirectory bool) resource.Path {
+	return resource.Path{
+		IsDirectory:  isDirectory,
+		OriginalPath: name,
+		CleanPath:    path.Join(fs.root, name),
+	}
+}
+
+// cleanName returns the name in /folder/file form, the root folder is /
+func cleanName(name string) string {
+	return path.Clean("/" + name)
+}
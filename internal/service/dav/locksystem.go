@@ -0,0 +1,147 @@
+package dav
+
+import (
+	"golang.org/x/net/webdav"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockSystem keeps WebDAV locks of all users in memory, so locks aren't shared between instances of the app.
+// Every user locks the names inside the own folder, tokens of locks outside the folder aren't accepted
+type LockSystem struct {
+	mu     sync.Mutex
+	ls     webdav.LockSystem
+	owners map[string]lockOwner
+}
+
+type lockOwner struct {
+	name      string    // the locked name in the lock system
+	expiresAt time.Time // zero if the lock never expires
+}
+
+// folderLockSystem is the view of the lock system for the folder
+type folderLockSystem struct {
+	locks *LockSystem
+	root  string
+}
+
+func NewLockSystem() *LockSystem {
+	return &LockSystem{
+		ls:     webdav.NewMemLS(),
+		owners: map[string]lockOwner{},
+	}
+}
+
+// Folder returns the lock system of the names inside the folder
+func (l *LockSystem) Folder(root string) webdav.LockSystem {
+	return &folderLockSystem{locks: l, root: path.Clean("/" + root)}
+}
+
+func (l *LockSystem) own(now time.Time, token, name string, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for t, owner := range l.owners {
+		if !owner.expiresAt.IsZero() && now.After(owner.expiresAt) {
+			delete(l.owners, t)
+		}
+	}
+
+	owner := lockOwner{name: name}
+	if duration >= 0 {
+		owner.expiresAt = now.Add(duration)
+	}
+
+	l.owners[token] = owner
+}
+
+// owns reports whether the lock of the token is inside the folder
+func (l *LockSystem) owns(token, root string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	owner, ok := l.owners[token]
+
+	return ok && (owner.name == root || strings.HasPrefix(owner.name, root+"/"))
+}
+
+func (l *LockSystem) release(token string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.owners, token)
+}
+
+func (f *folderLockSystem) Confirm(
+	now time.Time,
+	name0, name1 string,
+	conditions ...webdav.Condition,
+) (func(), error) {
+	return f.locks.ls.Confirm(now, f.name(name0), f.name(name1), conditions...)
+}
+
+func (f *folderLockSystem) Create(now time.Time, details webdav.LockDetails) (string, error) {
+	details.Root = f.name(details.Root)
+
+	token, err := f.locks.ls.Create(now, details)
+	if err != nil {
+		return "", err
+	}
+
+	f.locks.own(now, token, details.Root, details.Duration)
+
+	return token, nil
+}
+
+func (f *folderLockSystem) Refresh(now time.Time, token string, duration time.Duration) (webdav.LockDetails, error) {
+	if !f.locks.owns(token, f.root) {
+		return webdav.LockDetails{}, webdav.ErrNoSuchLock
+	}
+
+	details, err := f.locks.ls.Refresh(now, token, duration)
+	if err != nil {
+		if err == webdav.ErrNoSuchLock {
+			f.locks.release(token)
+		}
+
+		return webdav.LockDetails{}, err
+	}
+
+	f.locks.own(now, token, details.Root, duration)
+	details.Root = f.folderName(details.Root)
+
+	return details, nil
+}
+
+func (f *folderLockSystem) Unlock(now time.Time, token string) error {
+	if !f.locks.owns(token, f.root) {
+		return webdav.ErrNoSuchLock
+	}
+
+	err := f.locks.ls.Unlock(now, token)
+	if err == nil || err == webdav.ErrNoSuchLock {
+		f.locks.release(token)
+	}
+
+	return err
+}
+
+// name returns the name in the lock system of the name inside the folder, empty name means no resource
+func (f *folderLockSystem) name(name string) string {
+	if name == "" {
+		return ""
+	}
+
+	return path.Join(f.root, name)
+}
+
+// folderName returns the name inside the folder of the name in the lock system
+func (f *folderLockSystem) folderName(name string) string {
+	if name == f.root {
+		return "/"
+	}
+
+	return strings.TrimPrefix(name, f.root)
+}
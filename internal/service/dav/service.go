@@ -0,0 +1,71 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/net/webdav"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+)
+
+// Service serves the user's files over WebDAV on top of the S3 operations
+type Service struct {
+	s3Service S3Service
+	locks     *LockSystem
+}
+
+type S3Service interface {
+	Object(ctx context.Context, path resource.Path) (*minio.Object, error)
+	StoreFile(ctx context.Context, path resource.Path, reader io.Reader, size int64) (minio.UploadInfo, error)
+	Stat(ctx context.Context, path resource.Path) (minio.ObjectInfo, error)
+	SetMetadata(ctx context.Context, path resource.Path, metadata map[string]string) error
+	Delete(ctx context.Context, path resource.Path) error
+	Move(ctx context.Context, to, from resource.Path) error
+
+	StoreDirectory(ctx context.Context, path resource.Path) (minio.UploadInfo, error)
+	PaginateDirectory(ctx context.Context, userId int64, path resource.Path) *[]resource.Response
+
+	UserFolderPath(userId int64) string
+}
+
+func NewService(s3Service S3Service) *Service {
+	return &Service{
+		s3Service: s3Service,
+		locks:     NewLockSystem(),
+	}
+}
+
+// Handler returns the WebDAV handler of the user's files served under the prefix.
+// Not empty folder makes the folder the root, e.g. the folder of the personal access token
+func (s *Service) Handler(prefix string, userId int64, folder string) http.Handler {
+	return &webdav.Handler{
+		Prefix:     prefix,
+		FileSystem: s.FileSystem(userId, folder),
+		LockSystem: s.locks.Folder(s.root(userId, folder)),
+		Logger: func(r *http.Request, err error) {
+			// missing and existing resources are answered with the status, S3 errors are logged by the s3 service
+			if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, os.ErrExist) {
+				slog.DebugContext(r.Context(), "webdav request failed", "method", r.Method, "error", err)
+			}
+		},
+	}
+}
+
+// FileSystem returns the tree of the user's files inside the folder
+func (s *Service) FileSystem(userId int64, folder string) webdav.FileSystem {
+	return &fileSystem{
+		s3Service: s.s3Service,
+		userId:    userId,
+		root:      s.root(userId, folder),
+	}
+}
+
+// root returns the path to the folder in the bucket
+func (s *Service) root(userId int64, folder string) string {
+	return path.Join(s.s3Service.UserFolderPath(userId), cleanName(folder))
+}
@@ -0,0 +1,313 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/net/webdav"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryObject struct {
+	data     string
+	metadata map[string]string
+}
+
+type memoryS3Service struct {
+	objects map[string]memoryObject
+}
+
+func TestDavService_Collections(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]memoryObject{}}
+	handler := NewService(s3Service).Handler("/dav", 1, "")
+
+	for _, tc := range []struct {
+		method, path string
+		headers      map[string]string
+		body         string
+		status       int
+	}{
+		{method: "MKCOL", path: "/dav/a/", status: http.StatusCreated},
+		{method: "MKCOL", path: "/dav/a/", status: http.StatusMethodNotAllowed},
+		{method: "MKCOL", path: "/dav/missing/b/", status: http.StatusConflict},
+		{method: "PUT", path: "/dav/a/file.txt", body: "content", status: http.StatusCreated},
+		{method: "PUT", path: "/dav/missing/file.txt", body: "content", status: http.StatusConflict},
+		{method: "MKCOL", path: "/dav/a/sub/", status: http.StatusCreated},
+		{method: "PUT", path: "/dav/a/sub/nested.txt", status: http.StatusCreated},
+		{
+			method:  "MOVE",
+			path:    "/dav/a/",
+			headers: map[string]string{"Destination": "/dav/b/"},
+			status:  http.StatusCreated,
+		},
+		{method: "PROPFIND", path: "/dav/a/", headers: map[string]string{"Depth": "0"}, status: http.StatusNotFound},
+	} {
+		rec := davRequest(handler, tc.method, tc.path, tc.headers, tc.body)
+		if rec.Code != tc.status {
+			t.Errorf("%s %s must respond %d, got: %d %s", tc.method, tc.path, tc.status, rec.Code, rec.Body)
+		}
+	}
+
+	for _, key := range []string{"user-1-files/b/", "user-1-files/b/file.txt", "user-1-files/b/sub/nested.txt"} {
+		if _, ok := s3Service.objects[key]; !ok {
+			t.Errorf("moved directory must have %s, got: %v", key, s3Service.keys())
+		}
+	}
+
+	if s3Service.objects["user-1-files/b/file.txt"].data != "content" {
+		t.Errorf("moved file must keep the content, got: %q", s3Service.objects["user-1-files/b/file.txt"].data)
+	}
+
+	rec := davRequest(handler, "PROPFIND", "/dav/b/", map[string]string{"Depth": "1"}, "")
+	if rec.Code != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND must respond 207, got: %d", rec.Code)
+	}
+
+	for _, href := range []string{"<D:href>/dav/b/</D:href>", "<D:href>/dav/b/file.txt</D:href>", "<D:href>/dav/b/sub/</D:href>"} {
+		if !strings.Contains(rec.Body.String(), href) {
+			t.Errorf("PROPFIND must list %s, got: %s", href, rec.Body)
+		}
+	}
+
+	rec = davRequest(handler, "DELETE", "/dav/b/", nil, "")
+	if rec.Code != http.StatusNoContent || len(s3Service.objects) != 0 {
+		t.Errorf("DELETE must remove the directory with all objects, got: %d %v", rec.Code, s3Service.keys())
+	}
+}
+
+func TestDavService_DeadProperties(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]memoryObject{}}
+	handler := NewService(s3Service).Handler("/dav", 1, "")
+
+	davRequest(handler, "PUT", "/dav/file.txt", nil, "content")
+
+	rec := davRequest(handler, "PROPPATCH", "/dav/file.txt", nil, `<?xml version="1.0" encoding="utf-8"?>
+<D:propertyupdate xmlns:D="DAV:" xmlns:Z="http://example.com/ns">
+	<D:set><D:prop><Z:color>blue</Z:color></D:prop></D:set>
+</D:propertyupdate>`)
+	if rec.Code != http.StatusMultiStatus || !strings.Contains(rec.Body.String(), "200 OK") {
+		t.Fatalf("PROPPATCH must set the property, got: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = davRequest(handler, "PROPFIND", "/dav/file.txt", map[string]string{"Depth": "0"}, `<?xml version="1.0" encoding="utf-8"?>
+<D:propfind xmlns:D="DAV:"><D:prop><color xmlns="http://example.com/ns"/></D:prop></D:propfind>`)
+	if !strings.Contains(rec.Body.String(), ">blue</color>") {
+		t.Errorf("PROPFIND must return the stored property, got: %s", rec.Body)
+	}
+}
+
+func TestDavService_Folder(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]memoryObject{}}
+	handler := NewService(s3Service).Handler("/dav", 1, "/docs")
+
+	rec := davRequest(handler, "PUT", "/dav/file.txt", nil, "content")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("PUT must respond 201, got: %d", rec.Code)
+	}
+
+	if _, ok := s3Service.objects["user-1-files/docs/file.txt"]; !ok {
+		t.Errorf("file must be stored in the folder, got: %v", s3Service.keys())
+	}
+
+	rec = davRequest(handler, "PUT", "/dav/../file.txt", nil, "content")
+	if _, ok := s3Service.objects["user-1-files/file.txt"]; ok {
+		t.Errorf("file must not be stored outside of the folder, got: %d %v", rec.Code, s3Service.keys())
+	}
+}
+
+func TestLockSystem(t *testing.T) {
+	locks := NewLockSystem()
+	owner, other, folder := locks.Folder("user-1-files"), locks.Folder("user-2-files"), locks.Folder("user-1-files/docs")
+	now := time.Now()
+
+	token, err := owner.Create(now, webdav.LockDetails{Root: "/docs/a.txt", Duration: time.Minute})
+	if err != nil {
+		t.Fatalf("error while create lock: %v", err)
+	}
+
+	if _, err := other.Create(now, webdav.LockDetails{Root: "/docs/a.txt", Duration: time.Minute}); err != nil {
+		t.Errorf("users must lock the same names independently, got: %v", err)
+	}
+
+	if err := other.Unlock(now, token); !errors.Is(err, webdav.ErrNoSuchLock) {
+		t.Errorf("lock of other user must not be unlocked, got: %v", err)
+	}
+
+	details, err := folder.Refresh(now, token, time.Hour)
+	if err != nil {
+		t.Fatalf("lock inside the folder must be refreshed, got: %v", err)
+	}
+
+	if details.Root != "/a.txt" {
+		t.Errorf("lock root must be the name inside the folder, got: %s", details.Root)
+	}
+
+	if err := owner.Unlock(now, token); err != nil {
+		t.Errorf("error while unlock: %v", err)
+	}
+}
+
+func davRequest(handler http.Handler, method, target string, headers map[string]string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func (s *memoryS3Service) Object(context.Context, resource.Path) (*minio.Object, error) {
+	return nil, errors.New("reading objects is not supported")
+}
+
+func (s *memoryS3Service) StoreFile(
+	_ context.Context,
+	path resource.Path,
+	reader io.Reader,
+	_ int64,
+) (minio.UploadInfo, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
+	s.objects[path.CleanPath] = memoryObject{data: string(data)}
+
+	return minio.UploadInfo{Key: path.CleanPath, Size: int64(len(data))}, nil
+}
+
+func (s *memoryS3Service) Stat(_ context.Context, path resource.Path) (minio.ObjectInfo, error) {
+	key := path.CleanPath
+	if path.IsDirectory {
+		key = path.CleanPathWithTailingSlash()
+	}
+
+	if object, ok := s.objects[key]; ok {
+		return minio.ObjectInfo{
+			Key:          key,
+			Size:         int64(len(object.data)),
+			ETag:         fmt.Sprintf("%x", len(object.data)),
+			ContentType:  "text/plain",
+			UserMetadata: object.metadata,
+		}, nil
+	}
+
+	if path.IsDirectory && len(s.list(key)) > 0 {
+		return minio.ObjectInfo{Key: key}, nil
+	}
+
+	return minio.ObjectInfo{}, s3.ErrNotFound
+}
+
+func (s *memoryS3Service) SetMetadata(_ context.Context, path resource.Path, metadata map[string]string) error {
+	key := path.CleanPath
+	if path.IsDirectory {
+		key = path.CleanPathWithTailingSlash()
+	}
+
+	object, ok := s.objects[key]
+	if !ok {
+		return s3.ErrNotFound
+	}
+
+	object.metadata = metadata
+	s.objects[key] = object
+
+	return nil
+}
+
+func (s *memoryS3Service) Delete(_ context.Context, path resource.Path) error {
+	if !path.IsDirectory {
+		delete(s.objects, path.CleanPath)
+
+		return nil
+	}
+
+	for _, key := range s.list(path.CleanPathWithTailingSlash()) {
+		delete(s.objects, key)
+	}
+
+	return nil
+}
+
+func (s *memoryS3Service) Move(_ context.Context, to, from resource.Path) error {
+	if !from.IsDirectory {
+		s.objects[to.CleanPath] = s.objects[from.CleanPath]
+		delete(s.objects, from.CleanPath)
+
+		return nil
+	}
+
+	prefix := from.CleanPathWithTailingSlash()
+	for _, key := range s.list(prefix) {
+		s.objects[to.CleanPathWithTailingSlash()+strings.TrimPrefix(key, prefix)] = s.objects[key]
+		delete(s.objects, key)
+	}
+
+	return nil
+}
+
+func (s *memoryS3Service) StoreDirectory(_ context.Context, path resource.Path) (minio.UploadInfo, error) {
+	s.objects[path.CleanPathWithTailingSlash()] = memoryObject{}
+
+	return minio.UploadInfo{Key: path.CleanPathWithTailingSlash()}, nil
+}
+
+func (s *memoryS3Service) PaginateDirectory(_ context.Context, _ int64, p resource.Path) *[]resource.Response {
+	prefix := p.CleanPathWithTailingSlash()
+	seen := map[string]bool{}
+	data := []resource.Response{}
+
+	for _, key := range s.list(prefix) {
+		name, _, isDir := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+		if name == "" || seen[name] {
+			continue
+		}
+
+		seen[name] = true
+
+		r := resource.Response{Name: name, Type: "FILE", Size: int64(len(s.objects[key].data))}
+		if isDir {
+			r = resource.Response{Name: name, Type: "DIRECTORY"}
+		}
+
+		r.Path = path.Join(p.OriginalPath, name)
+		data = append(data, r)
+	}
+
+	return &data
+}
+
+func (s *memoryS3Service) UserFolderPath(userId int64) string {
+	return fmt.Sprintf("user-%d-files", userId)
+}
+
+func (s *memoryS3Service) list(prefix string) []string {
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *memoryS3Service) keys() []string {
+	return s.list("")
+}
@@ -0,0 +1,36 @@
+package email
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// MaxLength maximum length of the address in the SMTP path (RFC 5321)
+const MaxLength = 254
+
+var ErrInvalid = errors.New("email invalid")
+
+// Normalize trims spaces and lowercases the email, so the same address is always stored and searched the same way
+func Normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Validate checks that the email is a bare RFC 5322 address (without display name) with a domain part
+func Validate(email string) error {
+	if email == "" || len(email) > MaxLength {
+		return ErrInvalid
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || strings.ContainsAny(email, "<>") {
+		return ErrInvalid
+	}
+
+	local, domain, ok := strings.Cut(addr.Address, "@")
+	if !ok || local == "" || !strings.Contains(domain, ".") || strings.HasSuffix(domain, ".") {
+		return ErrInvalid
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package email
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	if n := Normalize("  User@Example.COM "); n != "user@example.com" {
+		t.Errorf("expected user@example.com, got: %s", n)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		"user@example.com",
+		"first.last+tag@sub.example.ru",
+		`"quoted local"@example.com`,
+	}
+	for _, e := range valid {
+		if err := Validate(e); err != nil {
+			t.Errorf("%q must be valid, got: %v", e, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"foo",
+		"foo@",
+		"@example.com",
+		"foo@localhost",
+		"foo@example.",
+		"User <user@example.com>",
+		"<user@example.com>",
+		"user@exa mple.com",
+	}
+	for _, e := range invalid {
+		if err := Validate(e); err == nil {
+			t.Errorf("%q must be invalid", e)
+		}
+	}
+}
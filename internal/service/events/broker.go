@@ -0,0 +1,59 @@
+package events
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+// Broker delivers published events to the buses of all instances of the app, including the publishing one.
+// Implementations must be safe for concurrent use.
+type Broker interface {
+	Publish(ctx context.Context, event Event) error
+	// Subscribe calls deliver with every published event until the returned closer is closed
+	Subscribe(deliver func(Event)) (io.Closer, error)
+}
+
+// MemoryBroker delivers events within the process, subscribers on other replicas don't get them
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	handlers map[*memoryHandler]struct{}
+}
+
+type memoryHandler struct {
+	broker  *MemoryBroker
+	deliver func(Event)
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: map[*memoryHandler]struct{}{}}
+}
+
+func (m *MemoryBroker) Publish(_ context.Context, event Event) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for h := range m.handlers {
+		h.deliver(event)
+	}
+
+	return nil
+}
+
+func (m *MemoryBroker) Subscribe(deliver func(Event)) (io.Closer, error) {
+	h := &memoryHandler{broker: m, deliver: deliver}
+
+	m.mu.Lock()
+	m.handlers[h] = struct{}{}
+	m.mu.Unlock()
+
+	return h, nil
+}
+
+func (h *memoryHandler) Close() error {
+	h.broker.mu.Lock()
+	delete(h.broker.handlers, h)
+	h.broker.mu.Unlock()
+
+	return nil
+}
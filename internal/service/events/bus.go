@@ -0,0 +1,180 @@
+package events
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/google/uuid"
+	"io"
+	"sync"
+	"time"
+)
+
+// Bus passes events of users from the subsystems publishing them to the subscribed connections of the users.
+// Events aren't stored: subscribers get only events published while they are subscribed
+type Bus struct {
+	pkg           string
+	conf          *Config
+	broker        Broker
+	listener      io.Closer
+	sinks         []Sink
+	now           func() time.Time
+	mu            sync.Mutex
+	subscriptions map[int64]map[*Subscription]struct{}
+	closed        bool
+}
+
+// Sink receives every event published on this instance, e.g. to store it for later delivery. It's called
+// before the event is sent to subscribers, so it must be fast
+type Sink interface {
+	Receive(ctx context.Context, event Event)
+}
+
+// Subscription receives events of the user matching its filter until it's closed
+type Subscription struct {
+	bus    *Bus
+	userId int64
+	filter Filter
+	events chan Event
+}
+
+func NewBus(conf *Config, broker Broker) (*Bus, error) {
+	const op = "NewBus"
+
+	b := &Bus{
+		pkg:           "events.bus",
+		conf:          conf,
+		broker:        broker,
+		now:           time.Now,
+		subscriptions: map[int64]map[*Subscription]struct{}{},
+	}
+
+	listener, err := broker.Subscribe(b.deliver)
+	if err != nil {
+		return nil, logger.Error(b.pkg, op, err)
+	}
+
+	b.listener = listener
+
+	return b, nil
+}
+
+// AddSink adds the sink of published events, sinks are added before the bus is used
+func (b *Bus) AddSink(sink Sink) {
+	b.sinks = append(b.sinks, sink)
+}
+
+// Publish sends the event to subscribers of its user on all instances. Failures are only logged,
+// events are notifications and the action which has happened doesn't fail because of them
+func (b *Bus) Publish(ctx context.Context, event Event) {
+	const op = "Publish"
+
+	if event.Id == "" {
+		event.Id = uuid.NewString()
+	}
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = b.now().UTC()
+	}
+
+	for _, sink := range b.sinks {
+		sink.Receive(context.WithoutCancel(ctx), event)
+	}
+
+	if err := b.broker.Publish(context.WithoutCancel(ctx), event); err != nil {
+		logger.AddContext(ctx, b.pkg, op, err)
+	}
+}
+
+// Subscribe returns the subscription to events of the user, it must be closed when the connection is gone
+func (b *Bus) Subscribe(userId int64, filter Filter) *Subscription {
+	s := &Subscription{
+		bus:    b,
+		userId: userId,
+		filter: filter,
+		events: make(chan Event, b.conf.BufferSize),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// the bus is shut down, the connection ends at once
+	if b.closed {
+		close(s.events)
+
+		return s
+	}
+
+	if b.subscriptions[userId] == nil {
+		b.subscriptions[userId] = map[*Subscription]struct{}{}
+	}
+
+	b.subscriptions[userId][s] = struct{}{}
+
+	return s
+}
+
+// Shutdown stops receiving events and closes all subscriptions, so their connections end and don't hold
+// the shutdown of the server
+func (b *Bus) Shutdown() {
+	const op = "Shutdown"
+
+	if err := b.listener.Close(); err != nil {
+		logger.Add(b.pkg, op, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	for _, subscriptions := range b.subscriptions {
+		for s := range subscriptions {
+			b.remove(s)
+		}
+	}
+}
+
+func (b *Bus) deliver(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscriptions[event.UserId] {
+		if !s.filter.Match(event) {
+			continue
+		}
+
+		select {
+		case s.events <- event:
+		default:
+			// the subscriber doesn't keep up and has lost the event, its connection ends, so the client
+			// reconnects and catches up
+			b.remove(s)
+		}
+	}
+}
+
+// remove closes the subscription, the caller holds the lock
+func (b *Bus) remove(s *Subscription) {
+	if _, ok := b.subscriptions[s.userId][s]; !ok {
+		return
+	}
+
+	delete(b.subscriptions[s.userId], s)
+	if len(b.subscriptions[s.userId]) == 0 {
+		delete(b.subscriptions, s.userId)
+	}
+
+	close(s.events)
+}
+
+// Events returns the channel of events, it's closed when the subscription is closed by either side
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+
+	s.bus.remove(s)
+}
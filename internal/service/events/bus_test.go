@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBus_Subscribe(t *testing.T) {
+	bus, err := NewBus(&Config{BufferSize: 2}, NewMemoryBroker())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	all := bus.Subscribe(1, Filter{})
+	docs := bus.Subscribe(1, Filter{Types: []string{TypeFileCreated, TypeFileMoved}, Folder: "/docs"})
+	other := bus.Subscribe(2, Filter{})
+
+	bus.Publish(ctx, Event{UserId: 1, Type: TypeFileCreated, Path: "/docs/a.txt"})
+	bus.Publish(ctx, Event{UserId: 1, Type: TypeFileMoved, Path: "/a.txt", FromPath: "/docs/a.txt"})
+
+	for _, event := range []Event{<-docs.Events(), <-docs.Events()} {
+		if event.Id == "" || event.CreatedAt.IsZero() {
+			t.Errorf("published event must get the id and the time, got: %+v", event)
+		}
+	}
+
+	// skipped by the filter of the folder, the quota warning isn't a file event but has the wrong type
+	bus.Publish(ctx, Event{UserId: 1, Type: TypeFileCreated, Path: "/other/b.txt"})
+	bus.Publish(ctx, Event{UserId: 1, Type: TypeQuotaWarning})
+
+	select {
+	case event := <-docs.Events():
+		t.Errorf("events outside of the filter must be skipped, got: %+v", event)
+	default:
+	}
+
+	select {
+	case event := <-other.Events():
+		t.Errorf("events of other users must be skipped, got: %+v", event)
+	default:
+	}
+
+	// the subscriber without a filter doesn't read events and overflows its buffer
+	if _, ok := <-all.Events(); !ok {
+		t.Fatalf("buffered events must be received")
+	}
+
+	if _, ok := <-all.Events(); !ok {
+		t.Fatalf("buffered events must be received")
+	}
+
+	if _, ok := <-all.Events(); ok {
+		t.Errorf("subscription must be closed after its buffer overflows")
+	}
+
+	docs.Close()
+	docs.Close()
+
+	if _, ok := <-docs.Events(); ok {
+		t.Errorf("closed subscription must not receive events")
+	}
+
+	bus.Shutdown()
+
+	if _, ok := <-other.Events(); ok {
+		t.Errorf("subscriptions must be closed on shutdown")
+	}
+
+	if _, ok := <-bus.Subscribe(2, Filter{}).Events(); ok {
+		t.Errorf("subscription after shutdown must be closed")
+	}
+}
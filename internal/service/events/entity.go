@@ -0,0 +1,80 @@
+package events
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	BrokerMemory = "memory"
+	BrokerRedis  = "redis"
+)
+
+const (
+	TypeFileCreated  = "file.created"
+	TypeFileUpdated  = "file.updated"
+	TypeFileMoved    = "file.moved"
+	TypeFileDeleted  = "file.deleted"
+	TypeQuotaWarning = "quota.warning"
+	TypeJobDone      = "job.done"
+	TypeExportReady  = "export.ready"
+	TypeSignIn       = "auth.sign-in"
+	TypeSignOut      = "auth.sign-out"
+
+	TypeWebhookDisabled = "webhook.disabled"
+)
+
+var Types = []string{
+	TypeFileCreated,
+	TypeFileUpdated,
+	TypeFileMoved,
+	TypeFileDeleted,
+	TypeQuotaWarning,
+	TypeJobDone,
+	TypeExportReady,
+	TypeSignIn,
+	TypeSignOut,
+	TypeWebhookDisabled,
+}
+
+type Config struct {
+	BufferSize int // events queued for a subscriber, the subscriber is dropped when its queue is full
+}
+
+// Event happened with the account of the user. Path and FromPath are set for file events, paths of directories
+// end with /. Data has the details of the event, e.g. the etag of the file or the name of the job
+type Event struct {
+	Id        string
+	UserId    int64
+	Type      string
+	Path      string
+	FromPath  string
+	Cursor    int64 // position of the file event in the journal of changes, 0 for other events
+	Data      map[string]string
+	CreatedAt time.Time
+}
+
+// Filter selects events of the subscription. Empty Types match all events, Folder skips file events outside
+// of it, e.g. "/docs", other events aren't related to folders and pass it
+type Filter struct {
+	Types  []string
+	Folder string
+}
+
+func (f Filter) Match(event Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, event.Type) {
+		return false
+	}
+
+	if f.Folder == "" || event.Path == "" {
+		return true
+	}
+
+	// the file moved out of the folder is gone for the subscriber, the file moved into it appeared
+	return inFolder(event.Path, f.Folder) || inFolder(event.FromPath, f.Folder)
+}
+
+func inFolder(path, folder string) bool {
+	return path != "" && strings.HasPrefix(path, strings.TrimSuffix(folder, "/")+"/")
+}
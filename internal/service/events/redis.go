@@ -0,0 +1,70 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/redis/go-redis/v9"
+	"io"
+)
+
+// RedisBroker delivers events to all replicas through a Redis pub/sub channel. Events published while
+// the replica is disconnected from Redis are lost for its subscribers
+type RedisBroker struct {
+	pkg     string
+	channel string
+	client  *redis.Client
+}
+
+func NewRedisBroker(client *redis.Client, channel string) *RedisBroker {
+	return &RedisBroker{
+		pkg:     "events.redis",
+		channel: channel,
+		client:  client,
+	}
+}
+
+func (r *RedisBroker) Publish(ctx context.Context, event Event) error {
+	const op = "Publish"
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	if err := r.client.Publish(ctx, r.channel, data).Err(); err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (r *RedisBroker) Subscribe(deliver func(Event)) (io.Closer, error) {
+	const op = "Subscribe"
+
+	ctx := context.Background()
+
+	// the subscription is confirmed before returning, so events published after it aren't missed
+	pubsub := r.client.Subscribe(ctx, r.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	// the channel reconnects by itself and is closed with the subscription
+	go func() {
+		for msg := range pubsub.Channel() {
+			var event Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				logger.Add(r.pkg, op, err)
+
+				continue
+			}
+
+			deliver(event)
+		}
+	}()
+
+	return pubsub, nil
+}
@@ -0,0 +1,38 @@
+package export
+
+import (
+	"errors"
+	"time"
+)
+
+// Types of export jobs
+const (
+	TypeArchive = "export.archive"
+	TypeExpire  = "export.expire"
+)
+
+var (
+	ErrNotFound      = errors.New("export not found")
+	ErrUnknownFormat = errors.New("unknown archive format")
+	ErrNoPaths       = errors.New("no paths to export")
+	ErrTooManyPaths  = errors.New("too many paths to export")
+	ErrLinkInvalid   = errors.New("download link invalid")
+	ErrLinkExpired   = errors.New("download link expired")
+)
+
+type Config struct {
+	Secret   string        // signs download links
+	TTL      time.Duration // the export is removed after it, the download link expires with it
+	MaxPaths int
+}
+
+// Path is the exported path of the user, Key is its clean path with the user folder
+type Path struct {
+	Path        string `json:"path"`
+	Key         string `json:"key"`
+	IsDirectory bool   `json:"is_directory"`
+}
+
+type Payload struct {
+	ExportId int64 `json:"export_id"`
+}
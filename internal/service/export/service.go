@@ -0,0 +1,462 @@
+package export
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// expiredBatch limits exports removed by one query of DeleteExpired
+const expiredBatch = 100
+
+type Service struct {
+	pkg        string
+	conf       *Config
+	repo       Repository
+	s3Service  S3Service
+	jobService JobService
+	events     Publisher
+	now        func() time.Time
+}
+
+type Repository interface {
+	Create(ctx context.Context, e export.Export) (export.Export, error)
+	SetJob(ctx context.Context, id, jobId int64) error
+	ById(ctx context.Context, userId, id int64) (export.Export, error)
+	Find(ctx context.Context, id int64) (export.Export, error)
+	ByUserId(ctx context.Context, userId int64) ([]export.Export, error)
+	Expired(ctx context.Context, now string, limit int) ([]export.Export, error)
+	Ready(ctx context.Context, id int64, objectKey string, size int64, now, expiresAt string) error
+	Delete(ctx context.Context, id int64) error
+}
+
+type S3Service interface {
+	WriteArchive(
+		ctx context.Context,
+		w io.Writer,
+		format string,
+		userId int64,
+		paths []resource.Path,
+		progress s3.Progress,
+	) error
+	StoreArchive(ctx context.Context, key, format string, reader io.Reader) (int64, error)
+	GetObject(
+		ctx context.Context,
+		key string,
+		opts minio.GetObjectOptions,
+	) (io.ReadCloser, minio.ObjectInfo, http.Header, error)
+	RemoveObject(ctx context.Context, key string) error
+	ExportFolderPath(userId int64) string
+}
+
+type JobService interface {
+	Enqueue(ctx context.Context, userId int64, jobType string, payload any) (jobstorage.Job, error)
+	EnqueueAt(
+		ctx context.Context,
+		userId int64,
+		jobType string,
+		payload any,
+		runAt time.Time,
+	) (jobstorage.Job, error)
+	Cancel(ctx context.Context, userId, id int64) (jobstorage.Job, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+func NewService(
+	conf *Config,
+	repo Repository,
+	s3Service S3Service,
+	jobService JobService,
+	publisher Publisher,
+) *Service {
+	return &Service{
+		pkg:        "export.service",
+		conf:       conf,
+		repo:       repo,
+		s3Service:  s3Service,
+		jobService: jobService,
+		events:     publisher,
+		now:        time.Now,
+	}
+}
+
+// Register sets handlers of export jobs, the progress of the archive job counts written files
+func (s *Service) Register(jobService *job.Service) {
+	job.Register(jobService, TypeArchive, s.Archive)
+	job.Register(jobService, TypeExpire, s.Expire)
+}
+
+// Create queues the archive of the paths of the user. The archive is written by the job in background,
+// the export is removed with its archive when it expires, whether the job has succeeded or not
+func (s *Service) Create(
+	ctx context.Context,
+	userId int64,
+	format string,
+	paths []resource.Path,
+) (export.Export, error) {
+	const op = "Create"
+
+	if format != s3.ArchiveZip && format != s3.ArchiveTarGz {
+		return export.Export{}, ErrUnknownFormat
+	}
+
+	if len(paths) == 0 {
+		return export.Export{}, ErrNoPaths
+	}
+
+	if len(paths) > s.conf.MaxPaths {
+		return export.Export{}, ErrTooManyPaths
+	}
+
+	exported := make([]Path, 0, len(paths))
+	for _, p := range paths {
+		exported = append(exported, Path{Path: p.OriginalPath, Key: p.CleanPath, IsDirectory: p.IsDirectory})
+	}
+
+	data, err := json.Marshal(exported)
+	if err != nil {
+		return export.Export{}, logger.Error(s.pkg, op, err)
+	}
+
+	expiresAt := s.now().Add(s.conf.TTL)
+
+	e, err := s.repo.Create(ctx, export.Export{
+		UserId:    userId,
+		Format:    format,
+		Paths:     string(data),
+		Status:    export.StatusPending,
+		ExpiresAt: expiresAt.Format(time.DateTime),
+	})
+	if err != nil {
+		return export.Export{}, logger.Error(s.pkg, op, err)
+	}
+
+	if err := s.enqueue(ctx, &e, expiresAt); err != nil {
+		if err := s.repo.Delete(ctx, e.Id); err != nil {
+			logger.Add(s.pkg, op, err)
+		}
+
+		return export.Export{}, logger.Error(s.pkg, op, err)
+	}
+
+	return e, nil
+}
+
+func (s *Service) Export(ctx context.Context, userId, id int64) (export.Export, error) {
+	const op = "Export"
+
+	e, err := s.repo.ById(ctx, userId, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return export.Export{}, ErrNotFound
+		}
+
+		return export.Export{}, logger.Error(s.pkg, op, err)
+	}
+
+	return e, nil
+}
+
+// Exports returns exports of the user, newest first
+func (s *Service) Exports(ctx context.Context, userId int64) ([]export.Export, error) {
+	const op = "Exports"
+
+	exports, err := s.repo.ByUserId(ctx, userId)
+	if err != nil {
+		return nil, logger.Error(s.pkg, op, err)
+	}
+
+	return exports, nil
+}
+
+// Delete removes the export with its archive, the job writing the archive is cancelled
+func (s *Service) Delete(ctx context.Context, userId, id int64) error {
+	const op = "Delete"
+
+	e, err := s.Export(ctx, userId, id)
+	if err != nil {
+		return err
+	}
+
+	if e.Status == export.StatusPending && e.JobId != 0 {
+		_, err := s.jobService.Cancel(ctx, userId, e.JobId)
+		if err != nil && !errors.Is(err, job.ErrFinished) && !errors.Is(err, job.ErrNotFound) {
+			return logger.Error(s.pkg, op, err)
+		}
+	}
+
+	if err := s.delete(ctx, e); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// DownloadPath returns the path and the query of the link downloading the ready export without authorization,
+// the link expires with the export
+func (s *Service) DownloadPath(e export.Export) (string, error) {
+	const op = "DownloadPath"
+
+	expiresAt, err := time.ParseInLocation(time.DateTime, e.ExpiresAt, time.Local)
+	if err != nil {
+		return "", logger.Error(s.pkg, op, err)
+	}
+
+	expires := expiresAt.Unix()
+	query := url.Values{
+		"expires":   {strconv.FormatInt(expires, 10)},
+		"signature": {s.sign(e.Id, expires)},
+	}
+
+	return fmt.Sprintf("/api/exports/%d/download?%s", e.Id, query.Encode()), nil
+}
+
+// Open returns the archive of the export the download link is signed for
+func (s *Service) Open(
+	ctx context.Context,
+	id, expires int64,
+	signature string,
+) (export.Export, io.ReadCloser, minio.ObjectInfo, error) {
+	const op = "Open"
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(id, expires))) {
+		return export.Export{}, nil, minio.ObjectInfo{}, ErrLinkInvalid
+	}
+
+	if s.now().Unix() > expires {
+		return export.Export{}, nil, minio.ObjectInfo{}, ErrLinkExpired
+	}
+
+	e, err := s.repo.Find(ctx, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return export.Export{}, nil, minio.ObjectInfo{}, ErrNotFound
+		}
+
+		return export.Export{}, nil, minio.ObjectInfo{}, logger.Error(s.pkg, op, err)
+	}
+
+	if e.Status != export.StatusReady {
+		return export.Export{}, nil, minio.ObjectInfo{}, ErrNotFound
+	}
+
+	reader, info, _, err := s.s3Service.GetObject(ctx, e.ObjectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return export.Export{}, nil, minio.ObjectInfo{}, logger.Error(s.pkg, op, err)
+	}
+
+	return e, reader, info, nil
+}
+
+// DeleteExpired removes expired exports with their archives and returns their number, the expire job removes
+// each export in time, it catches exports whose job has been lost
+func (s *Service) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	var removed int64
+
+	for {
+		exports, err := s.repo.Expired(ctx, s.now().Format(time.DateTime), expiredBatch)
+		if err != nil {
+			return removed, logger.Error(s.pkg, op, err)
+		}
+
+		for _, e := range exports {
+			if err := s.delete(ctx, e); err != nil {
+				return removed, logger.Error(s.pkg, op, err)
+			}
+
+			removed++
+		}
+
+		if len(exports) < expiredBatch {
+			return removed, nil
+		}
+	}
+}
+
+// Paths returns paths of the export as the user has requested them
+func Paths(e export.Export) []string {
+	var exported []Path
+	if err := json.Unmarshal([]byte(e.Paths), &exported); err != nil {
+		return []string{}
+	}
+
+	paths := make([]string, 0, len(exported))
+	for _, p := range exported {
+		paths = append(paths, p.Path)
+	}
+
+	return paths
+}
+
+// Archive writes the archive of the export into the bucket. The archive is streamed, it isn't kept in memory
+// nor on the disk. The archive written by the interrupted run is written again by the next run
+func (s *Service) Archive(ctx context.Context, run *job.Run, payload Payload) error {
+	e, err := s.repo.Find(ctx, payload.ExportId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return job.Permanent(ErrNotFound)
+		}
+
+		return err
+	}
+
+	var exported []Path
+	if err := json.Unmarshal([]byte(e.Paths), &exported); err != nil {
+		return err
+	}
+
+	paths := make([]resource.Path, 0, len(exported))
+	for _, p := range exported {
+		paths = append(paths, resource.Path{IsDirectory: p.IsDirectory, OriginalPath: p.Path, CleanPath: p.Key})
+	}
+
+	key := fmt.Sprintf("%s/%d.%s", s.s3Service.ExportFolderPath(e.UserId), e.Id, e.Format)
+
+	reader, writer := io.Pipe()
+
+	written := make(chan error, 1)
+	go func() {
+		err := s.s3Service.WriteArchive(ctx, writer, e.Format, e.UserId, paths, run.Progress)
+		writer.CloseWithError(err)
+		written <- err
+	}()
+
+	size, err := s.s3Service.StoreArchive(ctx, key, e.Format, reader)
+
+	// the writer is stopped if the upload has failed
+	reader.CloseWithError(io.ErrClosedPipe)
+
+	writeErr := <-written
+	if errors.Is(writeErr, s3.ErrNotFound) {
+		return job.Permanent(errors.New("path to export not found"))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if writeErr != nil {
+		return writeErr
+	}
+
+	now := s.now()
+
+	err = s.repo.Ready(
+		ctx,
+		e.Id,
+		key,
+		size,
+		now.Format(time.DateTime),
+		now.Add(s.conf.TTL).Format(time.DateTime),
+	)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			// the export has been deleted while the archive has been written
+			if err := s.s3Service.RemoveObject(ctx, key); err != nil {
+				logger.Add(s.pkg, "Archive", err)
+			}
+
+			return job.Permanent(ErrNotFound)
+		}
+
+		return err
+	}
+
+	s.events.Publish(ctx, events.Event{
+		UserId: e.UserId,
+		Type:   events.TypeExportReady,
+		Data: map[string]string{
+			"export_id": strconv.FormatInt(e.Id, 10),
+			"format":    e.Format,
+			"size":      strconv.FormatInt(size, 10),
+		},
+	})
+
+	return run.SetResult(map[string]int64{"export_id": e.Id, "size": size})
+}
+
+// Expire removes the export when it expires. The export becoming ready lives longer,
+// the job is queued again for its new expiration
+func (s *Service) Expire(ctx context.Context, _ *job.Run, payload Payload) error {
+	e, err := s.repo.Find(ctx, payload.ExportId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil
+		}
+
+		return err
+	}
+
+	expiresAt, err := time.ParseInLocation(time.DateTime, e.ExpiresAt, time.Local)
+	if err != nil {
+		return err
+	}
+
+	if expiresAt.After(s.now()) {
+		_, err := s.jobService.EnqueueAt(ctx, e.UserId, TypeExpire, Payload{ExportId: e.Id}, expiresAt)
+
+		return err
+	}
+
+	return s.delete(ctx, e)
+}
+
+// enqueue queues the job writing the archive of the export and the job removing the export when it expires
+func (s *Service) enqueue(ctx context.Context, e *export.Export, expiresAt time.Time) error {
+	j, err := s.jobService.Enqueue(ctx, e.UserId, TypeArchive, Payload{ExportId: e.Id})
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetJob(ctx, e.Id, j.Id); err != nil {
+		return err
+	}
+
+	e.JobId = j.Id
+
+	_, err = s.jobService.EnqueueAt(ctx, e.UserId, TypeExpire, Payload{ExportId: e.Id}, expiresAt)
+
+	return err
+}
+
+// delete removes the archive of the export and then the export
+func (s *Service) delete(ctx context.Context, e export.Export) error {
+	if e.ObjectKey != "" {
+		if err := s.s3Service.RemoveObject(ctx, e.ObjectKey); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.Delete(ctx, e.Id)
+}
+
+// sign returns the signature of the download link of the export valid till the time
+func (s *Service) sign(id, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(s.conf.Secret))
+	mac.Write([]byte(fmt.Sprintf("export:%d:%d", id, expires)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,437 @@
+package export
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	jobstorage "github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type memoryRepository struct {
+	lastId  int64
+	exports map[int64]export.Export
+}
+
+type memoryS3 struct {
+	objects map[string]string
+	missing bool // WriteArchive returns s3.ErrNotFound
+}
+
+type memoryJobs struct {
+	lastId    int64
+	queued    []jobstorage.Job
+	cancelled []int64
+}
+
+type memoryPublisher struct {
+	events []events.Event
+}
+
+func TestExportService_Create(t *testing.T) {
+	repo := newMemoryRepository()
+	jobs := &memoryJobs{}
+	service := newService(repo, &memoryS3{}, jobs)
+	ctx := context.Background()
+
+	if _, err := service.Create(ctx, 1, "rar", []resource.Path{docs()}); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format must return ErrUnknownFormat, got: %v", err)
+	}
+
+	if _, err := service.Create(ctx, 1, s3.ArchiveZip, nil); !errors.Is(err, ErrNoPaths) {
+		t.Errorf("export without paths must return ErrNoPaths, got: %v", err)
+	}
+
+	tooMany := []resource.Path{docs(), docs(), docs()}
+	if _, err := service.Create(ctx, 1, s3.ArchiveZip, tooMany); !errors.Is(err, ErrTooManyPaths) {
+		t.Errorf("export of too many paths must return ErrTooManyPaths, got: %v", err)
+	}
+
+	e, err := service.Create(ctx, 1, s3.ArchiveTarGz, []resource.Path{docs()})
+	if err != nil {
+		t.Fatalf("error while create export: %v", err)
+	}
+
+	if e.Status != export.StatusPending || e.JobId == 0 || repo.exports[e.Id].JobId != e.JobId {
+		t.Errorf("export must be pending with the job, got: %+v", e)
+	}
+
+	if len(jobs.queued) != 2 || jobs.queued[0].Type != TypeArchive || jobs.queued[1].Type != TypeExpire {
+		t.Fatalf("archive and expire jobs must be queued, got: %+v", jobs.queued)
+	}
+
+	if jobs.queued[1].RunAt != e.ExpiresAt {
+		t.Errorf("expire job must run when the export expires, got: %s, want: %s", jobs.queued[1].RunAt, e.ExpiresAt)
+	}
+}
+
+func TestExportService_Archive(t *testing.T) {
+	repo := newMemoryRepository()
+	s3Service := &memoryS3{objects: map[string]string{}}
+	publisher := &memoryPublisher{}
+	service := newService(repo, s3Service, &memoryJobs{})
+	service.events = publisher
+	ctx := context.Background()
+
+	e, _ := service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+
+	if err := service.Archive(ctx, &job.Run{}, Payload{ExportId: e.Id}); err != nil {
+		t.Fatalf("error while archive export: %v", err)
+	}
+
+	e = repo.exports[e.Id]
+	if e.Status != export.StatusReady || e.Size != 7 || s3Service.objects[e.ObjectKey] != "archive" {
+		t.Errorf("export must be ready with the archive, got: %+v", e)
+	}
+
+	if e.ObjectKey != "exports/user-1/1.zip" {
+		t.Errorf("archive must be stored in the export folder, got: %s", e.ObjectKey)
+	}
+
+	if len(publisher.events) != 1 || publisher.events[0].Type != events.TypeExportReady {
+		t.Errorf("export.ready event must be published, got: %+v", publisher.events)
+	}
+
+	s3Service.missing = true
+	missing, _ := service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+
+	err := service.Archive(ctx, &job.Run{}, Payload{ExportId: missing.Id})
+	if err == nil || err.Error() != "path to export not found" {
+		t.Errorf("missing path must fail the job permanently, got: %v", err)
+	}
+}
+
+func TestExportService_Download(t *testing.T) {
+	repo := newMemoryRepository()
+	service := newService(repo, &memoryS3{objects: map[string]string{}}, &memoryJobs{})
+	ctx := context.Background()
+
+	e, _ := service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+	_ = service.Archive(ctx, &job.Run{}, Payload{ExportId: e.Id})
+	e = repo.exports[e.Id]
+
+	link, err := service.DownloadPath(e)
+	if err != nil {
+		t.Fatalf("error while sign download link: %v", err)
+	}
+
+	id, expires, signature := parseLink(t, link)
+
+	_, reader, _, err := service.Open(ctx, id, expires, signature)
+	if err != nil {
+		t.Fatalf("signed link must open the archive, got: %v", err)
+	}
+
+	if content, _ := io.ReadAll(reader); string(content) != "archive" {
+		t.Errorf("link must return the archive, got: %q", content)
+	}
+
+	if _, _, _, err := service.Open(ctx, id, expires+1, signature); !errors.Is(err, ErrLinkInvalid) {
+		t.Errorf("link with changed expiration must return ErrLinkInvalid, got: %v", err)
+	}
+
+	if _, _, _, err := service.Open(ctx, id+1, expires, signature); !errors.Is(err, ErrLinkInvalid) {
+		t.Errorf("link of another export must return ErrLinkInvalid, got: %v", err)
+	}
+
+	service.now = func() time.Time { return time.Unix(expires+1, 0) }
+
+	if _, _, _, err := service.Open(ctx, id, expires, signature); !errors.Is(err, ErrLinkExpired) {
+		t.Errorf("expired link must return ErrLinkExpired, got: %v", err)
+	}
+}
+
+func TestExportService_Expire(t *testing.T) {
+	repo := newMemoryRepository()
+	s3Service := &memoryS3{objects: map[string]string{}}
+	jobs := &memoryJobs{}
+	service := newService(repo, s3Service, jobs)
+	ctx := context.Background()
+
+	e, _ := service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+
+	// the archive is ready an hour later, so the export lives longer than the expire job expected
+	service.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_ = service.Archive(ctx, &job.Run{}, Payload{ExportId: e.Id})
+	e = repo.exports[e.Id]
+
+	service.now = func() time.Time { return time.Now().Add(24*time.Hour + 30*time.Minute) }
+
+	if err := service.Expire(ctx, &job.Run{}, Payload{ExportId: e.Id}); err != nil {
+		t.Fatalf("error while expire export: %v", err)
+	}
+
+	if _, ok := repo.exports[e.Id]; !ok || len(jobs.queued) != 3 || jobs.queued[2].RunAt != e.ExpiresAt {
+		t.Fatalf("export which hasn't expired must be kept and expired later, got: %+v", jobs.queued)
+	}
+
+	service.now = func() time.Time { return time.Now().Add(26 * time.Hour) }
+
+	if err := service.Expire(ctx, &job.Run{}, Payload{ExportId: e.Id}); err != nil {
+		t.Fatalf("error while expire export: %v", err)
+	}
+
+	if _, ok := repo.exports[e.Id]; ok || len(s3Service.objects) != 0 {
+		t.Errorf("expired export must be removed with its archive, got: %+v, %+v", repo.exports, s3Service.objects)
+	}
+
+	if err := service.Expire(ctx, &job.Run{}, Payload{ExportId: e.Id}); err != nil {
+		t.Errorf("removed export must be skipped, got: %v", err)
+	}
+}
+
+func TestExportService_DeleteExpired(t *testing.T) {
+	repo := newMemoryRepository()
+	service := newService(repo, &memoryS3{objects: map[string]string{}}, &memoryJobs{})
+	ctx := context.Background()
+
+	_, _ = service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+	service.now = func() time.Time { return time.Now().Add(25 * time.Hour) }
+	kept, _ := service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+
+	removed, err := service.DeleteExpired(ctx)
+	if err != nil {
+		t.Fatalf("error while delete expired exports: %v", err)
+	}
+
+	if _, ok := repo.exports[kept.Id]; removed != 1 || !ok || len(repo.exports) != 1 {
+		t.Errorf("only expired export must be removed, got: %d removed, %+v", removed, repo.exports)
+	}
+}
+
+func TestExportService_Delete(t *testing.T) {
+	repo := newMemoryRepository()
+	jobs := &memoryJobs{}
+	service := newService(repo, &memoryS3{objects: map[string]string{}}, jobs)
+	ctx := context.Background()
+
+	e, _ := service.Create(ctx, 1, s3.ArchiveZip, []resource.Path{docs()})
+
+	if err := service.Delete(ctx, 2, e.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("export of another user must return ErrNotFound, got: %v", err)
+	}
+
+	if err := service.Delete(ctx, 1, e.Id); err != nil {
+		t.Fatalf("error while delete export: %v", err)
+	}
+
+	if len(jobs.cancelled) != 1 || jobs.cancelled[0] != e.JobId || len(repo.exports) != 0 {
+		t.Errorf("pending export must be removed and its job cancelled, got: %+v", jobs.cancelled)
+	}
+
+	// the job finishing after the export is removed drops the archive
+	err := service.Archive(ctx, &job.Run{}, Payload{ExportId: e.Id})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("archive of removed export must fail, got: %v", err)
+	}
+}
+
+func newService(repo *memoryRepository, s3Service *memoryS3, jobs *memoryJobs) *Service {
+	return NewService(
+		&Config{Secret: "secret", TTL: 24 * time.Hour, MaxPaths: 2},
+		repo,
+		s3Service,
+		jobs,
+		&memoryPublisher{},
+	)
+}
+
+func docs() resource.Path {
+	return resource.Path{IsDirectory: true, OriginalPath: "/docs/", CleanPath: "user-1-files/docs"}
+}
+
+func parseLink(t *testing.T, link string) (int64, int64, string) {
+	t.Helper()
+
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("invalid download link: %v", err)
+	}
+
+	var id int64
+	if _, err := fmt.Sscanf(u.Path, "/api/exports/%d/download", &id); err != nil {
+		t.Fatalf("invalid download link %q: %v", link, err)
+	}
+
+	expires, err := strconv.ParseInt(u.Query().Get("expires"), 10, 64)
+	if err != nil {
+		t.Fatalf("invalid download link %q: %v", link, err)
+	}
+
+	return id, expires, u.Query().Get("signature")
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{exports: map[int64]export.Export{}}
+}
+
+func (r *memoryRepository) Create(_ context.Context, e export.Export) (export.Export, error) {
+	r.lastId++
+	e.Id = r.lastId
+	r.exports[e.Id] = e
+
+	return e, nil
+}
+
+func (r *memoryRepository) SetJob(_ context.Context, id, jobId int64) error {
+	e := r.exports[id]
+	e.JobId = jobId
+	r.exports[id] = e
+
+	return nil
+}
+
+func (r *memoryRepository) ById(ctx context.Context, userId, id int64) (export.Export, error) {
+	e, err := r.Find(ctx, id)
+	if err != nil || e.UserId != userId {
+		return export.Export{}, storage.ErrNotFound
+	}
+
+	return e, nil
+}
+
+func (r *memoryRepository) Find(_ context.Context, id int64) (export.Export, error) {
+	e, ok := r.exports[id]
+	if !ok {
+		return export.Export{}, storage.ErrNotFound
+	}
+
+	return e, nil
+}
+
+func (r *memoryRepository) ByUserId(_ context.Context, userId int64) ([]export.Export, error) {
+	var data []export.Export
+	for _, e := range r.exports {
+		if e.UserId == userId {
+			data = append(data, e)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *memoryRepository) Expired(_ context.Context, now string, limit int) ([]export.Export, error) {
+	var data []export.Export
+	for _, e := range r.exports {
+		if e.ExpiresAt < now && len(data) < limit {
+			data = append(data, e)
+		}
+	}
+
+	return data, nil
+}
+
+func (r *memoryRepository) Ready(
+	_ context.Context,
+	id int64,
+	objectKey string,
+	size int64,
+	now, expiresAt string,
+) error {
+	e, ok := r.exports[id]
+	if !ok || e.Status != export.StatusPending {
+		return storage.ErrNotFound
+	}
+
+	e.Status = export.StatusReady
+	e.ObjectKey = objectKey
+	e.Size = size
+	e.FinishedAt.String, e.FinishedAt.Valid = now, true
+	e.ExpiresAt = expiresAt
+	r.exports[id] = e
+
+	return nil
+}
+
+func (r *memoryRepository) Delete(_ context.Context, id int64) error {
+	delete(r.exports, id)
+
+	return nil
+}
+
+func (s *memoryS3) WriteArchive(
+	_ context.Context,
+	w io.Writer,
+	_ string,
+	_ int64,
+	_ []resource.Path,
+	_ s3.Progress,
+) error {
+	if s.missing {
+		return s3.ErrNotFound
+	}
+
+	_, err := io.WriteString(w, "archive")
+
+	return err
+}
+
+func (s *memoryS3) StoreArchive(_ context.Context, key, _ string, reader io.Reader) (int64, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, err
+	}
+
+	s.objects[key] = string(data)
+
+	return int64(len(s.objects[key])), nil
+}
+
+func (s *memoryS3) GetObject(
+	_ context.Context,
+	key string,
+	_ minio.GetObjectOptions,
+) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	return io.NopCloser(strings.NewReader(s.objects[key])), minio.ObjectInfo{Key: key}, nil, nil
+}
+
+func (s *memoryS3) RemoveObject(_ context.Context, key string) error {
+	delete(s.objects, key)
+
+	return nil
+}
+
+func (s *memoryS3) ExportFolderPath(userId int64) string {
+	return "exports/user-" + strconv.FormatInt(userId, 10)
+}
+
+func (j *memoryJobs) Enqueue(ctx context.Context, userId int64, jobType string, payload any) (jobstorage.Job, error) {
+	return j.EnqueueAt(ctx, userId, jobType, payload, time.Now())
+}
+
+func (j *memoryJobs) EnqueueAt(
+	_ context.Context,
+	userId int64,
+	jobType string,
+	_ any,
+	runAt time.Time,
+) (jobstorage.Job, error) {
+	j.lastId++
+	queued := jobstorage.Job{Id: j.lastId, UserId: userId, Type: jobType, RunAt: runAt.Format(time.DateTime)}
+	j.queued = append(j.queued, queued)
+
+	return queued, nil
+}
+
+func (j *memoryJobs) Cancel(_ context.Context, _ int64, id int64) (jobstorage.Job, error) {
+	j.cancelled = append(j.cancelled, id)
+
+	return jobstorage.Job{Id: id}, nil
+}
+
+func (p *memoryPublisher) Publish(_ context.Context, event events.Event) {
+	p.events = append(p.events, event)
+}
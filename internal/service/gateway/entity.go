@@ -0,0 +1,43 @@
+package gateway
+
+import "time"
+
+// MaxKeys is the most keys and uploads returned by a listing, as in S3
+const MaxKeys = 1000
+
+// ListRequest is a listing of ListObjectsV2, V1 listings pass the marker as StartAfter
+type ListRequest struct {
+	Prefix            string
+	Delimiter         string
+	StartAfter        string
+	ContinuationToken string
+	MaxKeys           int
+}
+
+type ListResult struct {
+	Objects               []Object
+	CommonPrefixes        []string
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+type Object struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+}
+
+type Upload struct {
+	Key       string
+	UploadId  string
+	Initiated time.Time
+}
+
+type UploadsResult struct {
+	Uploads            []Upload
+	CommonPrefixes     []string
+	IsTruncated        bool
+	NextKeyMarker      string
+	NextUploadIdMarker string
+}
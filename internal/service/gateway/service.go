@@ -0,0 +1,379 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrNoSuchBucket = errors.New("bucket doesn't exist")
+	ErrInvalidKey   = errors.New("key is not supported")
+)
+
+// maxKeyLength is the longest key S3 accepts
+const maxKeyLength = 1024
+
+type Config struct {
+	Bucket string // the name of the bucket every user sees
+}
+
+// Service maps the bucket of the S3 gateway to the folder of the user: the key "dir/a.txt" is the object
+// "user-USER_ID-files/dir/a.txt", so users see only their files
+type Service struct {
+	bucket    string
+	s3Service S3Service
+}
+
+type S3Service interface {
+	ListObjects(
+		ctx context.Context,
+		prefix, delimiter, startAfter, continuationToken string,
+		maxKeys int,
+	) (minio.ListBucketV2Result, error)
+	GetObject(ctx context.Context, key string, opts minio.GetObjectOptions) (io.ReadCloser, minio.ObjectInfo, http.Header, error)
+	StatObject(ctx context.Context, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error)
+	PutObject(
+		ctx context.Context,
+		key string,
+		reader io.Reader,
+		size int64,
+		opts minio.PutObjectOptions,
+	) (minio.UploadInfo, error)
+	CopyObject(ctx context.Context, to, from string, metadata map[string]string) (minio.ObjectInfo, error)
+	RemoveObject(ctx context.Context, key string) error
+	NewMultipartUpload(ctx context.Context, key string, opts minio.PutObjectOptions) (string, error)
+	PutObjectPart(
+		ctx context.Context,
+		key, uploadId string,
+		partNumber int,
+		reader io.Reader,
+		size int64,
+	) (minio.ObjectPart, error)
+	CompleteMultipartUpload(
+		ctx context.Context,
+		key, uploadId string,
+		parts []minio.CompletePart,
+	) (minio.UploadInfo, error)
+	AbortMultipartUpload(ctx context.Context, key, uploadId string) error
+	ListObjectParts(
+		ctx context.Context,
+		key, uploadId string,
+		partNumberMarker, maxParts int,
+	) (minio.ListObjectPartsResult, error)
+	ListMultipartUploads(
+		ctx context.Context,
+		prefix, keyMarker, uploadIdMarker, delimiter string,
+		maxUploads int,
+	) (minio.ListMultipartUploadsResult, error)
+	UserFolderPath(userId int64) string
+}
+
+func NewService(conf *Config, s3Service S3Service) *Service {
+	return &Service{
+		bucket:    conf.Bucket,
+		s3Service: s3Service,
+	}
+}
+
+// Bucket returns the name of the bucket of the gateway
+func (s *Service) Bucket() string {
+	return s.bucket
+}
+
+// CheckBucket returns ErrNoSuchBucket if the name isn't the bucket of the gateway
+func (s *Service) CheckBucket(bucket string) error {
+	if bucket != s.bucket {
+		return ErrNoSuchBucket
+	}
+
+	return nil
+}
+
+// ListObjects lists the keys of the user, the keys and the prefixes of the result are relative to the bucket
+func (s *Service) ListObjects(ctx context.Context, userId int64, r ListRequest) (ListResult, error) {
+	root := s.root(userId)
+
+	startAfter := ""
+	if r.StartAfter != "" {
+		startAfter = root + r.StartAfter
+	}
+
+	list, err := s.s3Service.ListObjects(
+		ctx,
+		root+r.Prefix,
+		r.Delimiter,
+		startAfter,
+		r.ContinuationToken,
+		maxKeys(r.MaxKeys),
+	)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	result := ListResult{
+		Objects:               make([]Object, 0, len(list.Contents)),
+		CommonPrefixes:        make([]string, 0, len(list.CommonPrefixes)),
+		IsTruncated:           list.IsTruncated,
+		NextContinuationToken: list.NextContinuationToken,
+	}
+
+	for _, v := range list.Contents {
+		result.Objects = append(result.Objects, Object{
+			Key:          strings.TrimPrefix(v.Key, root),
+			Size:         v.Size,
+			ETag:         strings.Trim(v.ETag, `"`),
+			LastModified: v.LastModified,
+		})
+	}
+
+	for _, p := range list.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(p.Prefix, root))
+	}
+
+	return result, nil
+}
+
+func (s *Service) GetObject(
+	ctx context.Context,
+	userId int64,
+	key string,
+	opts minio.GetObjectOptions,
+) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	if !ValidKey(key) {
+		return nil, minio.ObjectInfo{}, nil, ErrInvalidKey
+	}
+
+	return s.s3Service.GetObject(ctx, s.root(userId)+key, opts)
+}
+
+func (s *Service) HeadObject(
+	ctx context.Context,
+	userId int64,
+	key string,
+	opts minio.StatObjectOptions,
+) (minio.ObjectInfo, error) {
+	if !ValidKey(key) {
+		return minio.ObjectInfo{}, ErrInvalidKey
+	}
+
+	return s.s3Service.StatObject(ctx, s.root(userId)+key, opts)
+}
+
+func (s *Service) PutObject(
+	ctx context.Context,
+	userId int64,
+	key string,
+	reader io.Reader,
+	size int64,
+	opts minio.PutObjectOptions,
+) (minio.UploadInfo, error) {
+	if !ValidKey(key) {
+		return minio.UploadInfo{}, ErrInvalidKey
+	}
+
+	return s.s3Service.PutObject(ctx, s.root(userId)+key, reader, size, opts)
+}
+
+// CopyObject copies the object of the user, metadata are the headers of the copy
+func (s *Service) CopyObject(
+	ctx context.Context,
+	userId int64,
+	to, from string,
+	metadata map[string]string,
+) (minio.ObjectInfo, error) {
+	if !ValidKey(to) || !ValidKey(from) {
+		return minio.ObjectInfo{}, ErrInvalidKey
+	}
+
+	root := s.root(userId)
+
+	return s.s3Service.CopyObject(ctx, root+to, root+from, metadata)
+}
+
+func (s *Service) DeleteObject(ctx context.Context, userId int64, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	return s.s3Service.RemoveObject(ctx, s.root(userId)+key)
+}
+
+func (s *Service) CreateMultipartUpload(
+	ctx context.Context,
+	userId int64,
+	key string,
+	opts minio.PutObjectOptions,
+) (string, error) {
+	if !ValidKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	return s.s3Service.NewMultipartUpload(ctx, s.root(userId)+key, opts)
+}
+
+// UploadPart uploads the part of the upload. Uploads are bound to their keys,
+// so the upload of another user isn't found under the key of the user
+func (s *Service) UploadPart(
+	ctx context.Context,
+	userId int64,
+	key, uploadId string,
+	partNumber int,
+	reader io.Reader,
+	size int64,
+) (minio.ObjectPart, error) {
+	if !ValidKey(key) {
+		return minio.ObjectPart{}, ErrInvalidKey
+	}
+
+	return s.s3Service.PutObjectPart(ctx, s.root(userId)+key, uploadId, partNumber, reader, size)
+}
+
+func (s *Service) CompleteMultipartUpload(
+	ctx context.Context,
+	userId int64,
+	key, uploadId string,
+	parts []minio.CompletePart,
+) (minio.UploadInfo, error) {
+	if !ValidKey(key) {
+		return minio.UploadInfo{}, ErrInvalidKey
+	}
+
+	return s.s3Service.CompleteMultipartUpload(ctx, s.root(userId)+key, uploadId, parts)
+}
+
+func (s *Service) AbortMultipartUpload(ctx context.Context, userId int64, key, uploadId string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+
+	return s.s3Service.AbortMultipartUpload(ctx, s.root(userId)+key, uploadId)
+}
+
+// ListParts lists the uploaded parts, the key of the result is relative to the bucket
+func (s *Service) ListParts(
+	ctx context.Context,
+	userId int64,
+	key, uploadId string,
+	partNumberMarker, maxParts int,
+) (minio.ListObjectPartsResult, error) {
+	if !ValidKey(key) {
+		return minio.ListObjectPartsResult{}, ErrInvalidKey
+	}
+
+	result, err := s.s3Service.ListObjectParts(ctx, s.root(userId)+key, uploadId, partNumberMarker, maxKeys(maxParts))
+	if err != nil {
+		return minio.ListObjectPartsResult{}, err
+	}
+
+	result.Bucket, result.Key = s.bucket, key
+
+	return result, nil
+}
+
+// UploadSize returns the size of all uploaded parts, the size of the object the upload is completed with
+func (s *Service) UploadSize(ctx context.Context, userId int64, key, uploadId string) (int64, error) {
+	var (
+		size   int64
+		marker int
+	)
+
+	for {
+		result, err := s.ListParts(ctx, userId, key, uploadId, marker, MaxKeys)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, part := range result.ObjectParts {
+			size += part.Size
+		}
+
+		if !result.IsTruncated {
+			return size, nil
+		}
+
+		marker = result.NextPartNumberMarker
+	}
+}
+
+// ListMultipartUploads lists the unfinished uploads of the user, the keys are relative to the bucket
+func (s *Service) ListMultipartUploads(
+	ctx context.Context,
+	userId int64,
+	prefix, keyMarker, uploadIdMarker, delimiter string,
+	maxUploads int,
+) (UploadsResult, error) {
+	root := s.root(userId)
+
+	if keyMarker != "" {
+		keyMarker = root + keyMarker
+	}
+
+	list, err := s.s3Service.ListMultipartUploads(
+		ctx,
+		root+prefix,
+		keyMarker,
+		uploadIdMarker,
+		delimiter,
+		maxKeys(maxUploads),
+	)
+	if err != nil {
+		return UploadsResult{}, err
+	}
+
+	result := UploadsResult{
+		Uploads:            make([]Upload, 0, len(list.Uploads)),
+		CommonPrefixes:     make([]string, 0, len(list.CommonPrefixes)),
+		IsTruncated:        list.IsTruncated,
+		NextKeyMarker:      strings.TrimPrefix(list.NextKeyMarker, root),
+		NextUploadIdMarker: list.NextUploadIDMarker,
+	}
+
+	for _, u := range list.Uploads {
+		result.Uploads = append(result.Uploads, Upload{
+			Key:       strings.TrimPrefix(u.Key, root),
+			UploadId:  u.UploadID,
+			Initiated: u.Initiated,
+		})
+	}
+
+	for _, p := range list.CommonPrefixes {
+		result.CommonPrefixes = append(result.CommonPrefixes, strings.TrimPrefix(p.Prefix, root))
+	}
+
+	return result, nil
+}
+
+// ValidKey reports whether the key can be a path of the user's files: "dir/file.txt" or "dir/" of a directory.
+// Keys with empty, "." or ".." segments are rejected, they would be out of the user's tree
+func ValidKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength || !utf8.ValidString(key) {
+		return false
+	}
+
+	segments := strings.Split(strings.TrimSuffix(key, "/"), "/")
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+
+	return true
+}
+
+// root returns the prefix of the user's keys in the bucket of the storage
+func (s *Service) root(userId int64) string {
+	return s.s3Service.UserFolderPath(userId) + "/"
+}
+
+func maxKeys(n int) int {
+	if n <= 0 || n > MaxKeys {
+		return MaxKeys
+	}
+
+	return n
+}
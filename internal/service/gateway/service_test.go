@@ -0,0 +1,343 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type memoryS3Service struct {
+	objects map[string]string
+	parts   map[string][]minio.ObjectPart // parts by the key and the upload id
+}
+
+func TestGatewayService_ListObjects(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{
+		"user-1-files/a.txt":       "a",
+		"user-1-files/dir/":        "",
+		"user-1-files/dir/b.txt":   "bb",
+		"user-1-files/dir/c.txt":   "ccc",
+		"user-10-files/other.txt":  "x",
+		"user-2-files/private.txt": "x",
+	}}
+	service := NewService(&Config{Bucket: "files"}, s3Service)
+	ctx := context.Background()
+
+	result, err := service.ListObjects(ctx, 1, ListRequest{Delimiter: "/"})
+	if err != nil {
+		t.Fatalf("error while list objects: %v", err)
+	}
+
+	if keys := objectKeys(result); !reflect.DeepEqual(keys, []string{"a.txt"}) {
+		t.Errorf("listing must have only the keys of the user relative to the bucket, got: %v", keys)
+	}
+
+	if !reflect.DeepEqual(result.CommonPrefixes, []string{"dir/"}) {
+		t.Errorf("listing must have the directories of the user, got: %v", result.CommonPrefixes)
+	}
+
+	result, err = service.ListObjects(ctx, 1, ListRequest{Prefix: "dir/", StartAfter: "dir/b.txt"})
+	if err != nil {
+		t.Fatalf("error while list objects: %v", err)
+	}
+
+	if keys := objectKeys(result); !reflect.DeepEqual(keys, []string{"dir/c.txt"}) {
+		t.Errorf("listing must start after the key, got: %v", keys)
+	}
+
+	// the prefix is matched literally, it doesn't lead out of the user's folder
+	result, err = service.ListObjects(ctx, 1, ListRequest{Prefix: "../user-2-files/"})
+	if err != nil {
+		t.Fatalf("error while list objects: %v", err)
+	}
+
+	if len(result.Objects) != 0 {
+		t.Errorf("objects of other users must not be listed, got: %v", objectKeys(result))
+	}
+}
+
+func TestGatewayService_Objects(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{"user-2-files/private.txt": "secret"}}
+	service := NewService(&Config{Bucket: "files"}, s3Service)
+	ctx := context.Background()
+
+	if err := service.CheckBucket("other"); !errors.Is(err, ErrNoSuchBucket) {
+		t.Errorf("other bucket must return ErrNoSuchBucket, got: %v", err)
+	}
+
+	if _, err := service.PutObject(ctx, 1, "dir/a.txt", strings.NewReader("a"), 1, minio.PutObjectOptions{}); err != nil {
+		t.Fatalf("error while put object: %v", err)
+	}
+
+	if _, err := service.CopyObject(ctx, 1, "dir/b.txt", "dir/a.txt", nil); err != nil {
+		t.Fatalf("error while copy object: %v", err)
+	}
+
+	for _, key := range []string{"user-1-files/dir/a.txt", "user-1-files/dir/b.txt"} {
+		if _, ok := s3Service.objects[key]; !ok {
+			t.Errorf("object %s must be stored in the folder of the user, got: %v", key, s3Service.keys())
+		}
+	}
+
+	if _, _, _, err := service.GetObject(ctx, 1, "private.txt", minio.GetObjectOptions{}); !s3.IsRequestError(err) {
+		t.Errorf("object of other user must not be found, got: %v", err)
+	}
+
+	for _, key := range []string{"", "/a.txt", "a//b.txt", "../user-2-files/private.txt", "dir/./a.txt", "\xff"} {
+		if _, err := service.HeadObject(ctx, 1, key, minio.StatObjectOptions{}); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("key %q must be invalid, got: %v", key, err)
+		}
+	}
+
+	if err := service.DeleteObject(ctx, 1, "dir/a.txt"); err != nil {
+		t.Fatalf("error while delete object: %v", err)
+	}
+
+	if _, ok := s3Service.objects["user-1-files/dir/a.txt"]; ok {
+		t.Error("deleted object must be removed")
+	}
+}
+
+func TestGatewayService_UploadSize(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{}, parts: map[string][]minio.ObjectPart{}}
+	service := NewService(&Config{Bucket: "files"}, s3Service)
+	ctx := context.Background()
+
+	uploadId, err := service.CreateMultipartUpload(ctx, 1, "big.bin", minio.PutObjectOptions{})
+	if err != nil {
+		t.Fatalf("error while create upload: %v", err)
+	}
+
+	// more parts than a page of the listing
+	for i := 1; i <= MaxKeys+5; i++ {
+		if _, err := service.UploadPart(ctx, 1, "big.bin", uploadId, i, strings.NewReader("xx"), 2); err != nil {
+			t.Fatalf("error while upload part: %v", err)
+		}
+	}
+
+	size, err := service.UploadSize(ctx, 1, "big.bin", uploadId)
+	if err != nil {
+		t.Fatalf("error while get upload size: %v", err)
+	}
+
+	if size != 2*(MaxKeys+5) {
+		t.Errorf("size must be the sum of all parts, got: %d", size)
+	}
+
+	if _, err := service.UploadSize(ctx, 2, "big.bin", uploadId); !s3.IsRequestError(err) {
+		t.Errorf("upload of other user must not be found, got: %v", err)
+	}
+}
+
+func objectKeys(result ListResult) []string {
+	keys := []string{}
+	for _, o := range result.Objects {
+		keys = append(keys, o.Key)
+	}
+
+	return keys
+}
+
+func (s *memoryS3Service) ListObjects(
+	_ context.Context,
+	prefix, delimiter, startAfter, _ string,
+	maxKeys int,
+) (minio.ListBucketV2Result, error) {
+	result := minio.ListBucketV2Result{}
+	seen := map[string]bool{}
+
+	for _, key := range s.keys() {
+		if !strings.HasPrefix(key, prefix) || key <= startAfter {
+			continue
+		}
+
+		if len(result.Contents)+len(result.CommonPrefixes) == maxKeys {
+			result.IsTruncated = true
+
+			break
+		}
+
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			p := prefix + rest[:i+1]
+			if !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, minio.CommonPrefix{Prefix: p})
+			}
+
+			continue
+		}
+
+		result.Contents = append(result.Contents, minio.ObjectInfo{Key: key, Size: int64(len(s.objects[key]))})
+	}
+
+	return result, nil
+}
+
+func (s *memoryS3Service) GetObject(
+	_ context.Context,
+	key string,
+	_ minio.GetObjectOptions,
+) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, minio.ObjectInfo{}, nil, noSuchKey()
+	}
+
+	return io.NopCloser(strings.NewReader(data)), minio.ObjectInfo{Key: key, Size: int64(len(data))}, http.Header{}, nil
+}
+
+func (s *memoryS3Service) StatObject(_ context.Context, key string, _ minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return minio.ObjectInfo{}, noSuchKey()
+	}
+
+	return minio.ObjectInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (s *memoryS3Service) PutObject(
+	_ context.Context,
+	key string,
+	reader io.Reader,
+	_ int64,
+	_ minio.PutObjectOptions,
+) (minio.UploadInfo, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
+	s.objects[key] = string(data)
+
+	return minio.UploadInfo{Key: key, Size: int64(len(data))}, nil
+}
+
+func (s *memoryS3Service) CopyObject(_ context.Context, to, from string, _ map[string]string) (minio.ObjectInfo, error) {
+	data, ok := s.objects[from]
+	if !ok {
+		return minio.ObjectInfo{}, noSuchKey()
+	}
+
+	s.objects[to] = data
+
+	return minio.ObjectInfo{Key: to, Size: int64(len(data))}, nil
+}
+
+func (s *memoryS3Service) RemoveObject(_ context.Context, key string) error {
+	delete(s.objects, key)
+
+	return nil
+}
+
+func (s *memoryS3Service) NewMultipartUpload(_ context.Context, key string, _ minio.PutObjectOptions) (string, error) {
+	uploadId := fmt.Sprintf("upload-%d", len(s.parts)+1)
+	s.parts[key+"?"+uploadId] = []minio.ObjectPart{}
+
+	return uploadId, nil
+}
+
+func (s *memoryS3Service) PutObjectPart(
+	_ context.Context,
+	key, uploadId string,
+	partNumber int,
+	_ io.Reader,
+	size int64,
+) (minio.ObjectPart, error) {
+	parts, ok := s.parts[key+"?"+uploadId]
+	if !ok {
+		return minio.ObjectPart{}, noSuchUpload()
+	}
+
+	part := minio.ObjectPart{PartNumber: partNumber, Size: size}
+	s.parts[key+"?"+uploadId] = append(parts, part)
+
+	return part, nil
+}
+
+func (s *memoryS3Service) CompleteMultipartUpload(
+	context.Context,
+	string,
+	string,
+	[]minio.CompletePart,
+) (minio.UploadInfo, error) {
+	return minio.UploadInfo{}, errors.New("completing uploads is not supported")
+}
+
+func (s *memoryS3Service) AbortMultipartUpload(_ context.Context, key, uploadId string) error {
+	delete(s.parts, key+"?"+uploadId)
+
+	return nil
+}
+
+func (s *memoryS3Service) ListObjectParts(
+	_ context.Context,
+	key, uploadId string,
+	partNumberMarker, maxParts int,
+) (minio.ListObjectPartsResult, error) {
+	parts, ok := s.parts[key+"?"+uploadId]
+	if !ok {
+		return minio.ListObjectPartsResult{}, noSuchUpload()
+	}
+
+	result := minio.ListObjectPartsResult{Key: key, UploadID: uploadId}
+	for _, part := range parts {
+		if part.PartNumber <= partNumberMarker {
+			continue
+		}
+
+		if len(result.ObjectParts) == maxParts {
+			result.IsTruncated = true
+
+			break
+		}
+
+		result.ObjectParts = append(result.ObjectParts, part)
+		result.NextPartNumberMarker = part.PartNumber
+	}
+
+	return result, nil
+}
+
+func (s *memoryS3Service) ListMultipartUploads(
+	context.Context,
+	string, string, string, string,
+	int,
+) (minio.ListMultipartUploadsResult, error) {
+	return minio.ListMultipartUploadsResult{}, nil
+}
+
+func (s *memoryS3Service) UserFolderPath(userId int64) string {
+	return fmt.Sprintf("user-%d-files", userId)
+}
+
+func (s *memoryS3Service) keys() []string {
+	keys := make([]string, 0, len(s.objects))
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func noSuchKey() error {
+	return &s3.RequestError{Code: "NoSuchKey", Message: "The specified key does not exist.", StatusCode: http.StatusNotFound}
+}
+
+func noSuchUpload() error {
+	return &s3.RequestError{
+		Code:       "NoSuchUpload",
+		Message:    "The specified multipart upload does not exist.",
+		StatusCode: http.StatusNotFound,
+	}
+}
@@ -0,0 +1,49 @@
+package health
+
+import (
+	"io/fs"
+	"time"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Errors of failed checks, the details are only logged, since readiness is public
+const (
+	ErrorUnavailable = "unavailable"
+	ErrorTimeout     = "timeout"
+)
+
+// Names of the checked dependencies
+const (
+	CheckDatabase   = "database"
+	CheckBucket     = "bucket"
+	CheckMigrations = "migrations"
+)
+
+type Config struct {
+	Timeout    time.Duration // of every check, 2 seconds by default
+	Migrations fs.FS         // goose migrations the app needs
+}
+
+type Check struct {
+	Name     string
+	Status   string
+	Error    string
+	Duration time.Duration
+}
+
+type Readiness struct {
+	Status string
+	Checks []Check
+}
+
+type BuildInfo struct {
+	Version   string // version of the main module, "(devel)" for local builds
+	Revision  string // vcs revision
+	Time      string // vcs commit time
+	Modified  bool   // built with uncommitted changes
+	GoVersion string
+}
@@ -0,0 +1,203 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"io/fs"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTimeout = 2 * time.Second
+
+type Service struct {
+	pkg           string
+	conf          *Config
+	db            DB
+	s3Service     S3Service
+	migrationRepo MigrationRepository
+	shuttingDown  atomic.Bool
+}
+
+type DB interface {
+	PingContext(ctx context.Context) error
+}
+
+type S3Service interface {
+	CheckBucket(ctx context.Context) error
+}
+
+type MigrationRepository interface {
+	AppliedVersions(ctx context.Context) (map[int64]bool, error)
+}
+
+func NewService(conf *Config, db DB, s3Service S3Service, migrationRepo MigrationRepository) *Service {
+	return &Service{
+		pkg:           "health.service",
+		conf:          conf,
+		db:            db,
+		s3Service:     s3Service,
+		migrationRepo: migrationRepo,
+	}
+}
+
+// Ready checks the dependencies concurrently, every check is limited by the timeout.
+// The app isn't ready if any check fails or the app is shutting down
+func (s *Service) Ready(ctx context.Context) Readiness {
+	checks := []struct {
+		name  string
+		check func(ctx context.Context) error
+	}{
+		{CheckDatabase, s.db.PingContext},
+		{CheckBucket, s.s3Service.CheckBucket},
+		{CheckMigrations, s.checkMigrations},
+	}
+
+	readiness := Readiness{
+		Status: StatusUp,
+		Checks: make([]Check, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			readiness.Checks[i] = s.run(ctx, c.name, c.check)
+		}()
+	}
+	wg.Wait()
+
+	for _, c := range readiness.Checks {
+		if c.Status != StatusUp {
+			readiness.Status = StatusDown
+		}
+	}
+
+	if s.shuttingDown.Load() {
+		readiness.Status = StatusDown
+	}
+
+	return readiness
+}
+
+// Shutdown makes readiness fail, so no new requests are routed to the app while it stops
+func (s *Service) Shutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// Version returns build info of the binary
+func (s *Service) Version() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+
+	b := BuildInfo{
+		Version:   info.Main.Version,
+		GoVersion: info.GoVersion,
+	}
+
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			b.Revision = setting.Value
+		case "vcs.time":
+			b.Time = setting.Value
+		case "vcs.modified":
+			b.Modified = setting.Value == "true"
+		}
+	}
+
+	return b
+}
+
+func (s *Service) run(ctx context.Context, name string, check func(ctx context.Context) error) Check {
+	const op = "run"
+
+	timeout := s.conf.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	err := check(ctx)
+
+	c := Check{
+		Name:     name,
+		Status:   StatusUp,
+		Duration: time.Since(started),
+	}
+
+	if err != nil {
+		c.Status = StatusDown
+		c.Error = ErrorUnavailable
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("timed out after %s: %w", timeout, err)
+			c.Error = ErrorTimeout
+		}
+
+		logger.AddContext(ctx, s.pkg, op, fmt.Errorf("%s: %w", name, err))
+	}
+
+	return c
+}
+
+func (s *Service) checkMigrations(ctx context.Context) error {
+	pending, err := s.pendingMigrations(ctx)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migrations are not applied, the first one is %d", len(pending), pending[0])
+	}
+
+	return nil
+}
+
+// pendingMigrations returns versions of the migrations the app needs which aren't applied, in ascending order
+func (s *Service) pendingMigrations(ctx context.Context) ([]int64, error) {
+	entries, err := fs.ReadDir(s.conf.Migrations, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := s.migrationRepo.AppliedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []int64
+	for _, entry := range entries {
+		prefix, _, ok := strings.Cut(entry.Name(), "_")
+		if !ok || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if !applied[version] {
+			pending = append(pending, version)
+		}
+	}
+
+	slices.Sort(pending)
+
+	return pending, nil
+}
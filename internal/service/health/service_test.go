@@ -0,0 +1,109 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+type memoryDB struct {
+	err   error
+	block bool // wait until the context is done
+}
+
+type memoryS3Service struct {
+	err error
+}
+
+type memoryMigrationRepository struct {
+	applied map[int64]bool
+}
+
+var migrations = fstest.MapFS{
+	"20250101000000_create_users_table.sql":    {Data: []byte("-- +goose Up")},
+	"20250102000000_create_sessions_table.sql": {Data: []byte("-- +goose Up")},
+}
+
+func TestHealthService_Ready(t *testing.T) {
+	service := NewService(
+		&Config{Migrations: migrations},
+		&memoryDB{},
+		&memoryS3Service{},
+		&memoryMigrationRepository{applied: map[int64]bool{20250101000000: true, 20250102000000: true}},
+	)
+
+	readiness := service.Ready(context.Background())
+	if readiness.Status != StatusUp {
+		t.Fatalf("all checks passed, status must be %q, got: %+v", StatusUp, readiness)
+	}
+
+	if len(readiness.Checks) != 3 {
+		t.Fatalf("3 dependencies must be checked, got: %d", len(readiness.Checks))
+	}
+
+	service.Shutdown()
+
+	if readiness := service.Ready(context.Background()); readiness.Status != StatusDown {
+		t.Errorf("status must be %q while shutting down, got: %q", StatusDown, readiness.Status)
+	}
+}
+
+func TestHealthService_ReadyFailedChecks(t *testing.T) {
+	service := NewService(
+		&Config{Timeout: 50 * time.Millisecond, Migrations: migrations},
+		&memoryDB{block: true},
+		&memoryS3Service{err: errors.New("bucket doesn't exist")},
+		&memoryMigrationRepository{applied: map[int64]bool{20250101000000: true}},
+	)
+
+	readiness := service.Ready(context.Background())
+	if readiness.Status != StatusDown {
+		t.Fatalf("status must be %q, got: %q", StatusDown, readiness.Status)
+	}
+
+	for _, c := range readiness.Checks {
+		if c.Status != StatusDown {
+			t.Errorf("check %q must fail", c.Name)
+		}
+	}
+
+	errorsByName := map[string]string{}
+	for _, c := range readiness.Checks {
+		errorsByName[c.Name] = c.Error
+	}
+
+	if errorsByName[CheckDatabase] != ErrorTimeout {
+		t.Errorf("blocked ping must time out, got: %q", errorsByName[CheckDatabase])
+	}
+
+	// details of errors aren't shown to unauthenticated callers
+	if errorsByName[CheckBucket] != ErrorUnavailable || errorsByName[CheckMigrations] != ErrorUnavailable {
+		t.Errorf("failed checks must be unavailable without details, got: %v", errorsByName)
+	}
+
+	pending, err := service.pendingMigrations(context.Background())
+	if err != nil || !slices.Equal(pending, []int64{20250102000000}) {
+		t.Errorf("pending migration must be found, got: %v %v", pending, err)
+	}
+}
+
+func (m *memoryDB) PingContext(ctx context.Context) error {
+	if m.block {
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+
+	return m.err
+}
+
+func (m *memoryS3Service) CheckBucket(context.Context) error {
+	return m.err
+}
+
+func (m *memoryMigrationRepository) AppliedVersions(context.Context) (map[int64]bool, error) {
+	return m.applied, nil
+}
@@ -0,0 +1,40 @@
+package job
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrNotFound    = errors.New("job not found")
+	ErrUnknownType = errors.New("unknown job type")
+	ErrFinished    = errors.New("job already finished")
+)
+
+type Config struct {
+	Workers      int
+	PollInterval time.Duration // how often other replicas' and retried jobs are looked for
+	Lease        time.Duration // the job is started again by another worker if its worker doesn't renew the lease
+	MaxAttempts  int           // the job fails after them
+	Backoff      time.Duration // before the second attempt, doubled before each next one
+	Retention    time.Duration // finished jobs are kept for it
+}
+
+// permanentError fails the job at once, there is no point in retrying it
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks the error of the handler which retrying won't fix, e.g. the missing resource.
+// Its message is shown to the user, messages of other errors are only logged
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
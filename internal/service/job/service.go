@@ -0,0 +1,229 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/service/workerpool"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Service struct {
+	pkg      string
+	conf     *Config
+	repo     Repository
+	events   Publisher
+	handlers map[string]handler
+	now      func() time.Time
+	pool     *workerpool.Pool
+	ctx      context.Context // runs of jobs are interrupted when it's cancelled on shutdown
+	cancel   context.CancelCauseFunc
+	mu       sync.Mutex
+	running  map[int64]context.CancelCauseFunc // jobs run by this instance
+}
+
+type Repository interface {
+	Create(ctx context.Context, j job.Job) (job.Job, error)
+	ById(ctx context.Context, userId, id int64) (job.Job, error)
+	Find(ctx context.Context, id int64) (job.Job, error)
+	ByUserId(ctx context.Context, userId int64, limit, offset int) ([]job.Job, int64, error)
+	Due(ctx context.Context, now string, limit int) ([]int64, error)
+	Claim(ctx context.Context, id int64, leaseId, now, lockedUntil string) (bool, error)
+	Renew(ctx context.Context, id int64, leaseId, lockedUntil string, done, total int64) (bool, error)
+	Release(ctx context.Context, j job.Job) error
+	CancelQueued(ctx context.Context, userId, id int64, now, expiresAt string) (bool, error)
+	RequestCancel(ctx context.Context, userId, id int64, now string) (bool, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+// handler runs the job with its JSON payload
+type handler func(ctx context.Context, run *Run, payload []byte) error
+
+func NewService(conf *Config, repo Repository, publisher Publisher) *Service {
+	s := &Service{
+		pkg:      "job.service",
+		conf:     conf,
+		repo:     repo,
+		events:   publisher,
+		handlers: map[string]handler{},
+		now:      time.Now,
+		running:  map[int64]context.CancelCauseFunc{},
+	}
+
+	s.pool = workerpool.New(
+		s.pkg,
+		&workerpool.Config{Workers: conf.Workers, PollInterval: conf.PollInterval, Batch: conf.Workers},
+		s.due,
+		s.run,
+	)
+
+	return s
+}
+
+// Register sets the handler of jobs of the type, it must be called before Start. The handler gets the payload
+// the job was queued with and should stop when the context is done: the job is cancelled, its lease is lost
+// or the instance shuts down. Failed runs are retried, so the handler must be safe to run again
+func Register[P any](s *Service, jobType string, handle func(ctx context.Context, run *Run, payload P) error) {
+	s.handlers[jobType] = func(ctx context.Context, run *Run, data []byte) error {
+		var payload P
+		if err := json.Unmarshal(data, &payload); err != nil {
+			return Permanent(errors.New("job payload invalid"))
+		}
+
+		return handle(ctx, run, payload)
+	}
+}
+
+// Enqueue queues the job of the user, one of workers of any replica runs it as soon as it's free
+func (s *Service) Enqueue(ctx context.Context, userId int64, jobType string, payload any) (job.Job, error) {
+	return s.EnqueueAt(ctx, userId, jobType, payload, s.now())
+}
+
+// EnqueueSystem queues the job which doesn't belong to any user, e.g. the purge of files of the deleted account.
+// It isn't listed to users and its end isn't published
+func (s *Service) EnqueueSystem(ctx context.Context, jobType string, payload any) (job.Job, error) {
+	return s.EnqueueAt(ctx, 0, jobType, payload, s.now())
+}
+
+// EnqueueAt queues the job of the user which isn't started before the time, e.g. to clean up after it
+func (s *Service) EnqueueAt(
+	ctx context.Context,
+	userId int64,
+	jobType string,
+	payload any,
+	runAt time.Time,
+) (job.Job, error) {
+	const op = "EnqueueAt"
+
+	if _, ok := s.handlers[jobType]; !ok {
+		return job.Job{}, ErrUnknownType
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return job.Job{}, logger.Error(s.pkg, op, err)
+	}
+
+	j, err := s.repo.Create(ctx, job.Job{
+		UserId:      userId,
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      job.StatusQueued,
+		MaxAttempts: s.conf.MaxAttempts,
+		RunAt:       runAt.Format(time.DateTime),
+	})
+	if err != nil {
+		return job.Job{}, logger.Error(s.pkg, op, err)
+	}
+
+	s.pool.Wake()
+
+	return j, nil
+}
+
+func (s *Service) Job(ctx context.Context, userId, id int64) (job.Job, error) {
+	const op = "Job"
+
+	j, err := s.repo.ById(ctx, userId, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return job.Job{}, ErrNotFound
+		}
+
+		return job.Job{}, logger.Error(s.pkg, op, err)
+	}
+
+	return j, nil
+}
+
+// Jobs returns the page of jobs of the user, newest first, and the total number of them
+func (s *Service) Jobs(ctx context.Context, userId int64, page, perPage int) ([]job.Job, int64, error) {
+	const op = "Jobs"
+
+	jobs, total, err := s.repo.ByUserId(ctx, userId, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	return jobs, total, nil
+}
+
+// Cancel cancels the queued job at once. The running job is stopped by its worker: at once if it runs on this
+// instance, otherwise when the worker renews its lease. Work done by then isn't undone
+func (s *Service) Cancel(ctx context.Context, userId, id int64) (job.Job, error) {
+	const op = "Cancel"
+
+	j, err := s.Job(ctx, userId, id)
+	if err != nil {
+		return job.Job{}, err
+	}
+
+	if j.Finished() {
+		return job.Job{}, ErrFinished
+	}
+
+	now := s.now()
+
+	cancelled, err := s.repo.CancelQueued(
+		ctx,
+		userId,
+		id,
+		now.Format(time.DateTime),
+		now.Add(s.conf.Retention).Format(time.DateTime),
+	)
+	if err != nil {
+		return job.Job{}, logger.Error(s.pkg, op, err)
+	}
+
+	if cancelled {
+		j.Status = job.StatusCancelled
+		s.done(ctx, j)
+	} else {
+		if _, err := s.repo.RequestCancel(ctx, userId, id, now.Format(time.DateTime)); err != nil {
+			return job.Job{}, logger.Error(s.pkg, op, err)
+		}
+
+		s.mu.Lock()
+		if cancel, ok := s.running[id]; ok {
+			cancel(errCancelled)
+		}
+		s.mu.Unlock()
+	}
+
+	// the job may have been finished meanwhile, its state is returned as it is
+	return s.Job(ctx, userId, id)
+}
+
+// done tells the user that the job is finished
+func (s *Service) done(ctx context.Context, j job.Job) {
+	if j.UserId == 0 {
+		return
+	}
+
+	result := "success"
+	switch j.Status {
+	case job.StatusFailed:
+		result = "failure"
+	case job.StatusCancelled:
+		result = "cancelled"
+	}
+
+	s.events.Publish(ctx, events.Event{
+		UserId: j.UserId,
+		Type:   events.TypeJobDone,
+		Data: map[string]string{
+			"job":    j.Type,
+			"job_id": strconv.FormatInt(j.Id, 10),
+			"result": result,
+		},
+	})
+}
@@ -0,0 +1,513 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryRepository struct {
+	mu     sync.Mutex
+	lastId int64
+	jobs   map[int64]job.Job
+}
+
+type memoryPublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+type testPayload struct {
+	Path string `json:"path"`
+}
+
+func TestJobService_Enqueue(t *testing.T) {
+	repo := newMemoryRepository()
+	service := newService(repo, &memoryPublisher{})
+	ctx := context.Background()
+
+	Register(service, "test", func(context.Context, *Run, testPayload) error { return nil })
+
+	if _, err := service.Enqueue(ctx, 1, "unknown", testPayload{}); !errors.Is(err, ErrUnknownType) {
+		t.Errorf("job of unknown type must return ErrUnknownType, got: %v", err)
+	}
+
+	j, err := service.Enqueue(ctx, 1, "test", testPayload{Path: "/docs/"})
+	if err != nil {
+		t.Fatalf("error while enqueue job: %v", err)
+	}
+
+	if j.Status != job.StatusQueued || j.Payload != `{"path":"/docs/"}` || j.MaxAttempts != 2 {
+		t.Errorf("job must be queued with the payload, got: %+v", j)
+	}
+
+	if _, err := service.Job(ctx, 2, j.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("job of another user must return ErrNotFound, got: %v", err)
+	}
+
+	later, _ := service.EnqueueAt(ctx, 1, "test", testPayload{}, time.Now().Add(time.Hour))
+
+	service.run(later.Id)
+
+	if got, _ := service.Job(ctx, 1, later.Id); got.Status != job.StatusQueued || got.Attempts != 0 {
+		t.Errorf("job must not be run before its time, got: %+v", got)
+	}
+}
+
+func TestJobService_Run(t *testing.T) {
+	repo := newMemoryRepository()
+	publisher := &memoryPublisher{}
+	service := newService(repo, publisher)
+	ctx := context.Background()
+
+	Register(service, "test", func(_ context.Context, run *Run, payload testPayload) error {
+		run.Progress(3, 3)
+
+		return run.SetResult(map[string]string{"path": payload.Path})
+	})
+
+	j, _ := service.Enqueue(ctx, 1, "test", testPayload{Path: "/docs/"})
+
+	service.run(j.Id)
+
+	j, _ = service.Job(ctx, 1, j.Id)
+	if j.Status != job.StatusSucceeded || j.Result.String != `{"path":"/docs/"}` || j.ProgressDone != 3 {
+		t.Errorf("job must succeed with the result and the progress, got: %+v", j)
+	}
+
+	if !j.FinishedAt.Valid || !j.ExpiresAt.Valid || j.LeaseId != "" || j.LockedUntil.Valid {
+		t.Errorf("finished job must expire and release the lease, got: %+v", j)
+	}
+
+	if len(publisher.events) != 1 || publisher.events[0].Data["result"] != "success" {
+		t.Errorf("job.done event must be published, got: %+v", publisher.events)
+	}
+
+	// the finished job isn't due anymore
+	service.run(j.Id)
+
+	if got, _ := service.Job(ctx, 1, j.Id); got.Attempts != 1 {
+		t.Errorf("finished job must not be run again, got: %d attempts", got.Attempts)
+	}
+}
+
+func TestJobService_System(t *testing.T) {
+	repo := newMemoryRepository()
+	publisher := &memoryPublisher{}
+	service := newService(repo, publisher)
+	ctx := context.Background()
+
+	Register(service, "test", func(context.Context, *Run, testPayload) error { return nil })
+
+	j, err := service.EnqueueSystem(ctx, "test", testPayload{Path: "/docs/"})
+	if err != nil {
+		t.Fatalf("error while enqueue job: %v", err)
+	}
+
+	service.run(j.Id)
+
+	if j, _ = repo.Find(ctx, j.Id); j.Status != job.StatusSucceeded || j.UserId != 0 {
+		t.Errorf("job of the system must be run, got: %+v", j)
+	}
+
+	if len(publisher.events) != 0 {
+		t.Errorf("end of the job of the system must not be published, got: %+v", publisher.events)
+	}
+}
+
+func TestJobService_Retry(t *testing.T) {
+	repo := newMemoryRepository()
+	publisher := &memoryPublisher{}
+	service := newService(repo, publisher)
+	ctx := context.Background()
+
+	now := time.Now()
+	service.now = func() time.Time { return now }
+
+	Register(service, "test", func(_ context.Context, _ *Run, payload testPayload) error {
+		if payload.Path == "" {
+			return Permanent(errors.New("path is empty"))
+		}
+
+		return errors.New("storage is unavailable")
+	})
+
+	j, _ := service.Enqueue(ctx, 1, "test", testPayload{Path: "/docs/"})
+
+	service.run(j.Id)
+
+	j, _ = service.Job(ctx, 1, j.Id)
+	retryAt := now.Add(time.Minute).Format(time.DateTime)
+	if j.Status != job.StatusQueued || j.Error != messageInternalError || j.RunAt != retryAt {
+		t.Errorf("failed job must be queued again after the backoff, got: %+v", j)
+	}
+
+	service.run(j.Id)
+
+	if got, _ := service.Job(ctx, 1, j.Id); got.Attempts != 1 {
+		t.Errorf("job must not be run before the backoff, got: %d attempts", got.Attempts)
+	}
+
+	now = now.Add(time.Minute)
+	service.run(j.Id)
+
+	j, _ = service.Job(ctx, 1, j.Id)
+	if j.Status != job.StatusFailed || j.Attempts != 2 {
+		t.Errorf("job must fail after max attempts, got: %+v", j)
+	}
+
+	j, _ = service.Enqueue(ctx, 1, "test", testPayload{})
+
+	service.run(j.Id)
+
+	j, _ = service.Job(ctx, 1, j.Id)
+	if j.Status != job.StatusFailed || j.Error != "path is empty" || j.Attempts != 1 {
+		t.Errorf("permanent error must fail the job at once with its message, got: %+v", j)
+	}
+
+	if len(publisher.events) != 2 || publisher.events[1].Data["result"] != "failure" {
+		t.Errorf("job.done events must be published for failed jobs, got: %+v", publisher.events)
+	}
+}
+
+func TestJobService_Cancel(t *testing.T) {
+	repo := newMemoryRepository()
+	publisher := &memoryPublisher{}
+	service := newService(repo, publisher)
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	Register(service, "test", func(ctx context.Context, _ *Run, _ testPayload) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+
+	queued, _ := service.Enqueue(ctx, 1, "test", testPayload{})
+
+	queued, err := service.Cancel(ctx, 1, queued.Id)
+	if err != nil || queued.Status != job.StatusCancelled {
+		t.Errorf("queued job must be cancelled at once, got: %+v %v", queued, err)
+	}
+
+	if _, err := service.Cancel(ctx, 1, queued.Id); !errors.Is(err, ErrFinished) {
+		t.Errorf("finished job must return ErrFinished, got: %v", err)
+	}
+
+	running, _ := service.Enqueue(ctx, 1, "test", testPayload{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		service.run(running.Id)
+	}()
+
+	<-started
+
+	if _, err := service.Cancel(ctx, 1, running.Id); err != nil {
+		t.Fatalf("error while cancel running job: %v", err)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("running job must be interrupted")
+	}
+
+	if j, _ := service.Job(ctx, 1, running.Id); j.Status != job.StatusCancelled || !j.CancelRequestedAt.Valid {
+		t.Errorf("running job must be cancelled, got: %+v", j)
+	}
+
+	if len(publisher.events) != 2 || publisher.events[1].Data["result"] != "cancelled" {
+		t.Errorf("job.done events must be published for cancelled jobs, got: %+v", publisher.events)
+	}
+}
+
+func TestJobService_ExpiredLease(t *testing.T) {
+	repo := newMemoryRepository()
+	service := newService(repo, &memoryPublisher{})
+	ctx := context.Background()
+
+	runs := 0
+	Register(service, "test", func(context.Context, *Run, testPayload) error {
+		runs++
+
+		return nil
+	})
+
+	j, _ := service.Enqueue(ctx, 1, "test", testPayload{})
+
+	// the worker which claimed the job has been gone
+	expired := time.Now().Add(-time.Minute).Format(time.DateTime)
+	repo.update(j.Id, func(j *job.Job) {
+		j.Status = job.StatusRunning
+		j.Attempts = 1
+		j.LeaseId = "gone"
+		j.LockedUntil = sql.NullString{String: expired, Valid: true}
+	})
+
+	service.run(j.Id)
+
+	if j, _ = service.Job(ctx, 1, j.Id); j.Status != job.StatusSucceeded || runs != 1 {
+		t.Errorf("job with the expired lease must be run again, got: %+v", j)
+	}
+
+	j, _ = service.Enqueue(ctx, 1, "test", testPayload{})
+	repo.update(j.Id, func(j *job.Job) {
+		j.Status = job.StatusRunning
+		j.Attempts = 2
+		j.LeaseId = "gone"
+		j.LockedUntil = sql.NullString{String: expired, Valid: true}
+	})
+
+	service.run(j.Id)
+
+	if j, _ = service.Job(ctx, 1, j.Id); j.Status != job.StatusFailed || j.Error != errInterrupted.Error() || runs != 1 {
+		t.Errorf("job interrupted too many times must fail, got: %+v", j)
+	}
+}
+
+func TestJobService_Shutdown(t *testing.T) {
+	repo := newMemoryRepository()
+	service := newService(repo, &memoryPublisher{})
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	Register(service, "test", func(ctx context.Context, _ *Run, _ testPayload) error {
+		close(started)
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+
+	service.Start()
+
+	// queued jobs wake up the poller, so the job doesn't wait for the poll interval
+	j, _ := service.Enqueue(ctx, 1, "test", testPayload{})
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("job must be started by workers")
+	}
+
+	if err := service.Shutdown(ctx); err != nil {
+		t.Errorf("error while shutdown: %v", err)
+	}
+
+	if j, _ = service.Job(ctx, 1, j.Id); j.Status != job.StatusQueued || j.Attempts != 0 || j.LeaseId != "" {
+		t.Errorf("interrupted job must be queued again without counting the attempt, got: %+v", j)
+	}
+}
+
+func newService(repo *memoryRepository, publisher *memoryPublisher) *Service {
+	service := NewService(&Config{
+		Workers:      2,
+		PollInterval: time.Minute,
+		Lease:        time.Minute,
+		MaxAttempts:  2,
+		Backoff:      time.Minute,
+		Retention:    time.Hour,
+	}, repo, publisher)
+
+	// jobs are run without Start in most tests
+	service.ctx, service.cancel = context.WithCancelCause(context.Background())
+
+	return service
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{jobs: map[int64]job.Job{}}
+}
+
+func (p *memoryPublisher) Publish(_ context.Context, event events.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+}
+
+func (r *memoryRepository) update(id int64, f func(j *job.Job)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j := r.jobs[id]
+	f(&j)
+	r.jobs[id] = j
+}
+
+func (r *memoryRepository) Create(_ context.Context, j job.Job) (job.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastId++
+	j.Id = r.lastId
+	j.CreatedAt = time.Now().Format(time.DateTime)
+	r.jobs[j.Id] = j
+
+	return j, nil
+}
+
+func (r *memoryRepository) ById(ctx context.Context, userId, id int64) (job.Job, error) {
+	j, err := r.Find(ctx, id)
+	if err != nil || j.UserId != userId {
+		return job.Job{}, storage.ErrNotFound
+	}
+
+	return j, nil
+}
+
+func (r *memoryRepository) Find(_ context.Context, id int64) (job.Job, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok {
+		return job.Job{}, storage.ErrNotFound
+	}
+
+	return j, nil
+}
+
+func (r *memoryRepository) ByUserId(_ context.Context, userId int64, limit, offset int) ([]job.Job, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	jobs := []job.Job{}
+	for id := r.lastId; id > 0; id-- {
+		if j, ok := r.jobs[id]; ok && j.UserId == userId {
+			jobs = append(jobs, j)
+		}
+	}
+
+	total := int64(len(jobs))
+
+	return jobs[min(offset, len(jobs)):min(offset+limit, len(jobs))], total, nil
+}
+
+func (r *memoryRepository) Due(_ context.Context, now string, limit int) ([]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := []int64{}
+	for id := int64(1); id <= r.lastId && len(ids) < limit; id++ {
+		if j, ok := r.jobs[id]; ok && due(j, now) {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+func (r *memoryRepository) Claim(_ context.Context, id int64, leaseId, now, lockedUntil string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok || !due(j, now) {
+		return false, nil
+	}
+
+	j.Status = job.StatusRunning
+	j.LeaseId = leaseId
+	j.LockedUntil = sql.NullString{String: lockedUntil, Valid: true}
+	j.Attempts++
+	if !j.StartedAt.Valid {
+		j.StartedAt = sql.NullString{String: now, Valid: true}
+	}
+	r.jobs[id] = j
+
+	return true, nil
+}
+
+func (r *memoryRepository) Renew(
+	_ context.Context,
+	id int64,
+	leaseId, lockedUntil string,
+	done, total int64,
+) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok || j.LeaseId != leaseId || j.Status != job.StatusRunning {
+		return false, storage.ErrNotFound
+	}
+
+	j.LockedUntil = sql.NullString{String: lockedUntil, Valid: true}
+	j.ProgressDone, j.ProgressTotal = done, total
+	r.jobs[id] = j
+
+	return j.CancelRequestedAt.Valid, nil
+}
+
+func (r *memoryRepository) Release(_ context.Context, released job.Job) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[released.Id]
+	if !ok || j.LeaseId != released.LeaseId {
+		return storage.ErrNotFound
+	}
+
+	j.Status = released.Status
+	j.ProgressDone, j.ProgressTotal = released.ProgressDone, released.ProgressTotal
+	j.Result = released.Result
+	j.Error = released.Error
+	j.Attempts = released.Attempts
+	j.RunAt = released.RunAt
+	j.LeaseId = ""
+	j.LockedUntil = sql.NullString{}
+	j.FinishedAt = released.FinishedAt
+	j.ExpiresAt = released.ExpiresAt
+	r.jobs[j.Id] = j
+
+	return nil
+}
+
+func (r *memoryRepository) CancelQueued(_ context.Context, userId, id int64, now, expiresAt string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok || j.UserId != userId || j.Status != job.StatusQueued {
+		return false, nil
+	}
+
+	j.Status = job.StatusCancelled
+	j.CancelRequestedAt = sql.NullString{String: now, Valid: true}
+	j.FinishedAt = sql.NullString{String: now, Valid: true}
+	j.ExpiresAt = sql.NullString{String: expiresAt, Valid: true}
+	r.jobs[id] = j
+
+	return true, nil
+}
+
+func (r *memoryRepository) RequestCancel(_ context.Context, userId, id int64, now string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	j, ok := r.jobs[id]
+	if !ok || j.UserId != userId || j.Status != job.StatusRunning || j.CancelRequestedAt.Valid {
+		return false, nil
+	}
+
+	j.CancelRequestedAt = sql.NullString{String: now, Valid: true}
+	r.jobs[id] = j
+
+	return true, nil
+}
+
+// due tells if the job is queued and should be started or its lease has expired
+func due(j job.Job, now string) bool {
+	return (j.Status == job.StatusQueued && j.RunAt <= now) ||
+		(j.Status == job.StatusRunning && j.LockedUntil.Valid && j.LockedUntil.String < now)
+}
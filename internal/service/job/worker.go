@@ -0,0 +1,314 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/workerpool"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/job"
+	"github.com/google/uuid"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// progressInterval limits writes of the progress, the lease is renewed with them
+	progressInterval = time.Second
+
+	// messageInternalError replaces errors of handlers which aren't permanent, they may reveal internals
+	messageInternalError = "internal error"
+)
+
+var (
+	errCancelled   = errors.New("job cancelled")
+	errLeaseLost   = errors.New("job lease lost")
+	errShutdown    = errors.New("job service shut down")
+	errInterrupted = errors.New("job interrupted too many times")
+)
+
+// Run is the attempt of the job, the handler reports the progress and sets the result through it
+type Run struct {
+	Job     job.Job
+	mu      sync.Mutex
+	done    int64
+	total   int64
+	changed bool
+	result  sql.NullString
+}
+
+// Progress saves how much of the job is done of the total, zero total means it isn't known.
+// It's written to the database at most once per progress interval, so it can be called for each item
+func (r *Run) Progress(done, total int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.done = done
+	r.total = total
+	r.changed = true
+}
+
+// SetResult sets the value returned to the user as JSON when the job succeeds
+func (r *Run) SetResult(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.result = sql.NullString{String: string(data), Valid: true}
+
+	return nil
+}
+
+// progress returns the progress and whether it has changed since the last call
+func (r *Run) progress() (int64, int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changed := r.changed
+	r.changed = false
+
+	return r.done, r.total, changed
+}
+
+// Start runs workers running queued jobs until Shutdown. Jobs are claimed in the database under a lease,
+// so workers of all replicas share them and the job of the crashed replica is started again after its lease
+func (s *Service) Start() {
+	s.ctx, s.cancel = context.WithCancelCause(context.Background())
+
+	s.pool.Start()
+}
+
+// Shutdown stops looking for jobs and interrupts running ones, they are queued again to be started
+// by this or another replica, so the shutdown isn't delayed by long jobs
+func (s *Service) Shutdown(ctx context.Context) error {
+	if s.cancel != nil {
+		s.cancel(errShutdown)
+	}
+
+	return s.pool.Shutdown(ctx)
+}
+
+// due returns queued jobs whose time has come, retried ones and ones whose lease has expired
+func (s *Service) due(ctx context.Context, limit int) ([]int64, error) {
+	return s.repo.Due(ctx, s.now().Format(time.DateTime), limit)
+}
+
+// run claims the job and runs it, the job is skipped if another worker has claimed it
+func (s *Service) run(id int64) {
+	const op = "run"
+
+	ctx := context.Background()
+	now := s.now()
+
+	claimed, err := s.repo.Claim(
+		ctx,
+		id,
+		uuid.NewString(),
+		now.Format(time.DateTime),
+		now.Add(s.conf.Lease).Format(time.DateTime),
+	)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	if !claimed {
+		return
+	}
+
+	j, err := s.repo.Find(ctx, id)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	run := &Run{Job: j, done: j.ProgressDone, total: j.ProgressTotal}
+
+	switch {
+	case j.CancelRequestedAt.Valid:
+		// cancelled while its previous worker has been gone
+		err = errCancelled
+	case j.Attempts > j.MaxAttempts:
+		// attempts of workers which have been gone don't finish the job
+		err = Permanent(errInterrupted)
+	default:
+		err = s.execute(j, run)
+	}
+
+	s.release(ctx, run, err)
+}
+
+// execute runs the handler of the job, the error is the cause if the run has been interrupted
+func (s *Service) execute(j job.Job, run *Run) error {
+	handle, ok := s.handlers[j.Type]
+	if !ok {
+		return Permanent(ErrUnknownType)
+	}
+
+	ctx, cancel := context.WithCancelCause(s.ctx)
+	defer cancel(nil)
+
+	s.mu.Lock()
+	s.running[j.Id] = cancel
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, j.Id)
+		s.mu.Unlock()
+	}()
+
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		s.heartbeat(ctx, cancel, run, stop)
+	}()
+
+	err := handle(ctx, run, []byte(j.Payload))
+
+	close(stop)
+	wg.Wait()
+
+	// the handler which has finished anyway has done the job
+	if err != nil && ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+
+	return err
+}
+
+// heartbeat saves the progress and renews the lease of the run until it stops. The run is interrupted
+// if the lease is lost or the user has asked another replica to cancel the job
+func (s *Service) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, run *Run, stop <-chan struct{}) {
+	const op = "heartbeat"
+
+	renewEvery := s.conf.Lease / 3
+
+	ticker := time.NewTicker(min(progressInterval, renewEvery))
+	defer ticker.Stop()
+
+	renewed := s.now()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		done, total, changed := run.progress()
+		if !changed && s.now().Sub(renewed) < renewEvery {
+			continue
+		}
+
+		now := s.now()
+
+		cancelRequested, err := s.repo.Renew(
+			context.Background(),
+			run.Job.Id,
+			run.Job.LeaseId,
+			now.Add(s.conf.Lease).Format(time.DateTime),
+			done,
+			total,
+		)
+		if err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				cancel(errLeaseLost)
+
+				return
+			}
+
+			logger.Add(s.pkg, op, err)
+
+			continue
+		}
+
+		renewed = now
+
+		if cancelRequested {
+			cancel(errCancelled)
+
+			return
+		}
+	}
+}
+
+// release saves the outcome of the run: the job is finished or queued again to be retried
+func (s *Service) release(ctx context.Context, run *Run, err error) {
+	const op = "release"
+
+	j := run.Job
+	j.ProgressDone, j.ProgressTotal, _ = run.progress()
+
+	now := s.now()
+
+	var permanent permanentError
+
+	switch {
+	case err == nil:
+		j.Status = job.StatusSucceeded
+		j.Error = ""
+
+		run.mu.Lock()
+		j.Result = run.result
+		run.mu.Unlock()
+	case errors.Is(err, errLeaseLost):
+		slog.Warn("job lease lost, it's run by another worker", "pkg", s.pkg, "job_id", j.Id)
+
+		return
+	case errors.Is(err, errShutdown):
+		// the interrupted attempt doesn't count
+		j.Status = job.StatusQueued
+		j.Attempts--
+		j.RunAt = now.Format(time.DateTime)
+	case errors.Is(err, errCancelled):
+		j.Status = job.StatusCancelled
+	case errors.As(err, &permanent):
+		j.Status = job.StatusFailed
+		j.Error = workerpool.Truncate(err.Error())
+	default:
+		logger.Add(s.pkg, op, err)
+
+		j.Error = messageInternalError
+		if j.Attempts >= j.MaxAttempts {
+			j.Status = job.StatusFailed
+		} else {
+			j.Status = job.StatusQueued
+			j.RunAt = now.Add(workerpool.Backoff(s.conf.Backoff, 0, j.Attempts)).Format(time.DateTime)
+		}
+	}
+
+	if j.Finished() {
+		j.FinishedAt = sql.NullString{String: now.Format(time.DateTime), Valid: true}
+		j.ExpiresAt = sql.NullString{String: now.Add(s.conf.Retention).Format(time.DateTime), Valid: true}
+	}
+
+	if err := s.repo.Release(ctx, j); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			slog.Warn("job lease lost, it's run by another worker", "pkg", s.pkg, "job_id", j.Id)
+		} else {
+			logger.Add(s.pkg, op, err)
+		}
+
+		return
+	}
+
+	if j.Finished() {
+		s.done(ctx, j)
+	}
+}
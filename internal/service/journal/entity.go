@@ -0,0 +1,32 @@
+package journal
+
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionMove   = "move"
+	ActionDelete = "delete"
+)
+
+const (
+	TypeFile      = "FILE"
+	TypeDirectory = "DIRECTORY"
+)
+
+// Change is the change of the file or the directory of the user, paths of directories end with /
+type Change struct {
+	Cursor    int64 // position of the change in the journal of the user
+	Action    string
+	Path      string
+	FromPath  string // source of move
+	Type      string
+	ETag      string
+	Size      int64
+	CreatedAt string
+}
+
+// Page is the part of the journal after the cursor the client has
+type Page struct {
+	Changes []Change
+	Cursor  int64 // the cursor to request the next changes with
+	HasMore bool  // the page is full, the next one is returned without waiting
+}
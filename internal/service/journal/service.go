@@ -0,0 +1,323 @@
+package journal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/filechange"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidCursor = errors.New("cursor is ahead of the journal")
+	ErrCursorExpired = errors.New("changes after the cursor are removed from the journal")
+)
+
+// createAttempts is how many times the change is written if another change of the user takes its seq
+const createAttempts = 5
+
+// lockShards is the number of locks serializing writes of the users on this instance
+const lockShards = 64
+
+var eventTypes = map[string]string{
+	ActionCreate: events.TypeFileCreated,
+	ActionUpdate: events.TypeFileUpdated,
+	ActionMove:   events.TypeFileMoved,
+	ActionDelete: events.TypeFileDeleted,
+}
+
+type Config struct {
+	Retention    time.Duration // how long changes are kept
+	PollInterval time.Duration // how often waiting clients check changes written by other instances
+}
+
+// Service keeps the journal of changes of user files. Every user has the own sequence of changes,
+// its numbers are cursors which clients request the following changes with
+type Service struct {
+	pkg     string
+	conf    *Config
+	repo    Repository
+	events  Publisher
+	now     func() time.Time
+	locks   [lockShards]sync.Mutex
+	mu      sync.Mutex
+	waiters map[int64]map[chan struct{}]struct{}
+	done    chan struct{}
+	stop    sync.Once
+}
+
+type Repository interface {
+	Create(ctx context.Context, entry filechange.Entry) (int64, error)
+	Since(ctx context.Context, userId, since, until int64, limit int) ([]filechange.Entry, error)
+	Bounds(ctx context.Context, userId int64) (int64, int64, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+func NewService(conf *Config, repo Repository, publisher Publisher) *Service {
+	return &Service{
+		pkg:     "journal.service",
+		conf:    conf,
+		repo:    repo,
+		events:  publisher,
+		now:     time.Now,
+		waiters: map[int64]map[chan struct{}]struct{}{},
+		done:    make(chan struct{}),
+	}
+}
+
+// Record appends the change to the journal of the user, wakes up clients waiting for changes and publishes
+// the event of the change. Failures are only logged, so an unavailable journal doesn't break the change itself
+func (s *Service) Record(ctx context.Context, userId int64, change Change) {
+	const op = "Record"
+
+	// the change has happened, it's recorded even if the request is cancelled
+	ctx = context.WithoutCancel(ctx)
+
+	now := s.now()
+	entry := filechange.Entry{
+		UserId:    userId,
+		Action:    change.Action,
+		Path:      change.Path,
+		ETag:      change.ETag,
+		Size:      change.Size,
+		CreatedAt: now.Format(time.DateTime),
+		ExpiresAt: now.Add(s.conf.Retention).Format(time.DateTime),
+	}
+
+	if change.FromPath != "" {
+		entry.FromPath = sql.NullString{String: change.FromPath, Valid: true}
+	}
+
+	// changes of the user on this instance don't compete for the seq
+	lock := &s.locks[userId%lockShards]
+	lock.Lock()
+
+	var (
+		seq int64
+		err error
+	)
+
+	for attempt := 0; attempt < createAttempts; attempt++ {
+		seq, err = s.repo.Create(ctx, entry)
+		if !errors.Is(err, storage.ErrDuplicateNotAllowed) {
+			break
+		}
+	}
+
+	lock.Unlock()
+
+	if err != nil {
+		logger.AddContext(ctx, s.pkg, op, err)
+
+		return
+	}
+
+	s.notify(userId)
+
+	entry.Seq = seq
+	s.publish(ctx, entry)
+}
+
+// publish sends the event of the written change to subscribers of the user
+func (s *Service) publish(ctx context.Context, entry filechange.Entry) {
+	c := change(entry)
+
+	s.events.Publish(ctx, events.Event{
+		UserId:   entry.UserId,
+		Type:     eventTypes[c.Action],
+		Path:     c.Path,
+		FromPath: c.FromPath,
+		Cursor:   c.Cursor,
+		Data: map[string]string{
+			"type": c.Type,
+			"etag": c.ETag,
+			"size": strconv.FormatInt(c.Size, 10),
+		},
+	})
+}
+
+// Cursor returns the cursor of the latest change of the user, the client follows changes from it
+// after listing its files
+func (s *Service) Cursor(ctx context.Context, userId int64) (int64, error) {
+	const op = "Cursor"
+
+	_, last, err := s.repo.Bounds(ctx, userId)
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	return last, nil
+}
+
+// Changes returns changes of the user after the cursor inside the folder, "" - all changes. If there are none,
+// it waits for them up to the wait duration and returns the empty page with the same cursor after that
+func (s *Service) Changes(
+	ctx context.Context,
+	userId, since int64,
+	folder string,
+	limit int,
+	wait time.Duration,
+) (Page, error) {
+	const op = "Changes"
+
+	// subscribed before reading the journal, so the change written in between isn't missed
+	notified := s.subscribe(userId)
+	defer s.unsubscribe(userId, notified)
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	poll := time.NewTicker(s.conf.PollInterval)
+	defer poll.Stop()
+
+	for {
+		page, err := s.page(ctx, userId, since, folder, limit)
+		if err != nil {
+			if errors.Is(err, ErrInvalidCursor) || errors.Is(err, ErrCursorExpired) {
+				return Page{}, err
+			}
+
+			return Page{}, logger.Error(s.pkg, op, err)
+		}
+
+		if len(page.Changes) > 0 || page.HasMore {
+			return page, nil
+		}
+
+		// changes outside of the folder move the cursor, but aren't returned
+		since = page.Cursor
+
+		select {
+		case <-notified:
+		case <-poll.C:
+		case <-timeout.C:
+			return page, nil
+		case <-s.done:
+			return page, nil
+		case <-ctx.Done():
+			return Page{}, ctx.Err()
+		}
+	}
+}
+
+// Shutdown returns waiting clients their empty pages, so they don't hold the server until their timeout
+func (s *Service) Shutdown() {
+	s.stop.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *Service) page(ctx context.Context, userId, since int64, folder string, limit int) (Page, error) {
+	first, last, err := s.repo.Bounds(ctx, userId)
+	if err != nil {
+		return Page{}, err
+	}
+
+	if since < 0 || since > last {
+		return Page{}, ErrInvalidCursor
+	}
+
+	// the journal keeps the newest change of the user at least, older ones are removed after the retention
+	if since < last && first > since+1 {
+		return Page{}, ErrCursorExpired
+	}
+
+	page := Page{Changes: []Change{}, Cursor: last}
+	if since == last {
+		return page, nil
+	}
+
+	entries, err := s.repo.Since(ctx, userId, since, last, limit)
+	if err != nil {
+		return Page{}, err
+	}
+
+	if len(entries) == limit && entries[len(entries)-1].Seq < last {
+		page.Cursor = entries[len(entries)-1].Seq
+		page.HasMore = true
+	}
+
+	for _, e := range entries {
+		c := change(e)
+		if folder != "" && !inFolder(c.Path, folder) && !inFolder(c.FromPath, folder) {
+			continue
+		}
+
+		page.Changes = append(page.Changes, c)
+	}
+
+	return page, nil
+}
+
+func (s *Service) subscribe(userId int64) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan struct{}, 1)
+
+	if s.waiters[userId] == nil {
+		s.waiters[userId] = map[chan struct{}]struct{}{}
+	}
+
+	s.waiters[userId][ch] = struct{}{}
+
+	return ch
+}
+
+func (s *Service) unsubscribe(userId int64, ch chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.waiters[userId], ch)
+
+	if len(s.waiters[userId]) == 0 {
+		delete(s.waiters, userId)
+	}
+}
+
+func (s *Service) notify(userId int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for ch := range s.waiters[userId] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func change(e filechange.Entry) Change {
+	c := Change{
+		Cursor:    e.Seq,
+		Action:    e.Action,
+		Path:      e.Path,
+		FromPath:  e.FromPath.String,
+		Type:      TypeFile,
+		ETag:      e.ETag,
+		Size:      e.Size,
+		CreatedAt: e.CreatedAt,
+	}
+
+	if strings.HasSuffix(e.Path, "/") {
+		c.Type = TypeDirectory
+	}
+
+	return c
+}
+
+// inFolder checks that the path is the folder itself or inside of it, the folder has no trailing slash
+func inFolder(path, folder string) bool {
+	path = strings.TrimSuffix(path, "/")
+
+	return path != "" && (path == folder || strings.HasPrefix(path, folder+"/"))
+}
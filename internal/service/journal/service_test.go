@@ -0,0 +1,206 @@
+package journal
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/filechange"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memoryPublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+type memoryRepository struct {
+	mu         sync.Mutex
+	entries    []filechange.Entry
+	duplicates int // number of the next creates failing as if another instance took the seq
+}
+
+func newTestService() (*Service, *memoryRepository, *memoryPublisher) {
+	repo := &memoryRepository{}
+	publisher := &memoryPublisher{}
+
+	return NewService(&Config{Retention: time.Hour, PollInterval: time.Minute}, repo, publisher), repo, publisher
+}
+
+func TestJournalService_RecordAndChanges(t *testing.T) {
+	service, repo, publisher := newTestService()
+	ctx := context.Background()
+
+	repo.duplicates = 2
+
+	service.Record(ctx, 1, Change{Action: ActionCreate, Path: "/docs/", Size: 0})
+	service.Record(ctx, 1, Change{Action: ActionCreate, Path: "/docs/a.txt", ETag: "e1", Size: 3})
+	service.Record(ctx, 2, Change{Action: ActionCreate, Path: "/b.txt", ETag: "e2", Size: 1})
+	service.Record(ctx, 1, Change{Action: ActionMove, Path: "/a.txt", FromPath: "/docs/a.txt", ETag: "e1", Size: 3})
+	service.Record(ctx, 1, Change{Action: ActionDelete, Path: "/docs/"})
+
+	if len(repo.entries) != 5 {
+		t.Fatalf("change must be written again if its seq is taken, got: %d entries", len(repo.entries))
+	}
+
+	moved := publisher.events[3]
+	if len(publisher.events) != 5 || moved.Type != events.TypeFileMoved || moved.Cursor != 3 ||
+		moved.FromPath != "/docs/a.txt" || moved.Data["size"] != "3" {
+		t.Errorf("every recorded change must be published, got: %+v", publisher.events)
+	}
+
+	page, err := service.Changes(ctx, 1, 0, "", 3, 0)
+	if err != nil {
+		t.Fatalf("error while get changes: %v", err)
+	}
+
+	if len(page.Changes) != 3 || page.Cursor != 3 || !page.HasMore {
+		t.Fatalf("full page must have the cursor of its last change, got: %+v", page)
+	}
+
+	if page.Changes[0].Type != TypeDirectory || page.Changes[1].Type != TypeFile || page.Changes[2].FromPath != "/docs/a.txt" {
+		t.Errorf("changes must be returned in order with their types, got: %+v", page.Changes)
+	}
+
+	page, err = service.Changes(ctx, 1, page.Cursor, "", 3, 0)
+	if err != nil || len(page.Changes) != 1 || page.Cursor != 4 || page.HasMore {
+		t.Fatalf("the last page must have the cursor of the latest change, got: %+v, %v", page, err)
+	}
+
+	cursor, err := service.Cursor(ctx, 2)
+	if err != nil || cursor != 1 {
+		t.Errorf("each user must have the own sequence, got: %d, %v", cursor, err)
+	}
+
+	// the move out of the folder is returned, the later deletion of the folder too
+	page, err = service.Changes(ctx, 1, 1, "/docs", 10, 0)
+	if err != nil || len(page.Changes) != 3 || page.Cursor != 4 {
+		t.Errorf("changes must be filtered by the folder, got: %+v, %v", page, err)
+	}
+
+	page, err = service.Changes(ctx, 1, 0, "/other", 10, 0)
+	if err != nil || len(page.Changes) != 0 || page.Cursor != 4 {
+		t.Errorf("changes outside of the folder must move the cursor, got: %+v, %v", page, err)
+	}
+
+	if _, err := service.Changes(ctx, 1, 5, "", 10, 0); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor ahead of the journal must be invalid, got: %v", err)
+	}
+
+	// the retention has removed the first changes
+	repo.entries = repo.entries[2:]
+
+	if _, err := service.Changes(ctx, 1, 1, "", 10, 0); !errors.Is(err, ErrCursorExpired) {
+		t.Errorf("cursor before removed changes must be expired, got: %v", err)
+	}
+
+	if page, err := service.Changes(ctx, 1, 2, "", 10, 0); err != nil || len(page.Changes) != 2 {
+		t.Errorf("cursor before remaining changes must be valid, got: %+v, %v", page, err)
+	}
+}
+
+func TestJournalService_Wait(t *testing.T) {
+	service, _, _ := newTestService()
+	ctx := context.Background()
+
+	service.Record(ctx, 1, Change{Action: ActionCreate, Path: "/a.txt"})
+
+	started := time.Now()
+
+	page, err := service.Changes(ctx, 1, 1, "", 10, 20*time.Millisecond)
+	if err != nil || len(page.Changes) != 0 || page.Cursor != 1 {
+		t.Errorf("no changes must be returned after the wait, got: %+v, %v", page, err)
+	}
+
+	if time.Since(started) < 20*time.Millisecond {
+		t.Errorf("client must wait for changes, waited: %s", time.Since(started))
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		service.Record(ctx, 2, Change{Action: ActionCreate, Path: "/other.txt"})
+		service.Record(ctx, 1, Change{Action: ActionUpdate, Path: "/a.txt"})
+	}()
+
+	page, err = service.Changes(ctx, 1, 1, "", 10, time.Minute)
+	if err != nil || len(page.Changes) != 1 || page.Changes[0].Action != ActionUpdate {
+		t.Errorf("waiting client must get the new change, got: %+v, %v", page, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		service.Shutdown()
+	}()
+
+	page, err = service.Changes(ctx, 1, 2, "", 10, time.Minute)
+	if err != nil || len(page.Changes) != 0 || page.Cursor != 2 {
+		t.Errorf("waiting client must get the empty page on shutdown, got: %+v, %v", page, err)
+	}
+}
+
+func (p *memoryPublisher) Publish(_ context.Context, event events.Event) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.events = append(p.events, event)
+}
+
+func (r *memoryRepository) Create(_ context.Context, entry filechange.Entry) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.duplicates > 0 {
+		r.duplicates--
+
+		return 0, storage.ErrDuplicateNotAllowed
+	}
+
+	entry.Id = int64(len(r.entries) + 1)
+
+	for _, e := range r.entries {
+		if e.UserId == entry.UserId && e.Seq > entry.Seq {
+			entry.Seq = e.Seq
+		}
+	}
+
+	entry.Seq++
+	r.entries = append(r.entries, entry)
+
+	return entry.Seq, nil
+}
+
+func (r *memoryRepository) Since(_ context.Context, userId, since, until int64, limit int) ([]filechange.Entry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	entries := []filechange.Entry{}
+	for _, e := range r.entries {
+		if e.UserId == userId && e.Seq > since && e.Seq <= until && len(entries) < limit {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries, nil
+}
+
+func (r *memoryRepository) Bounds(_ context.Context, userId int64) (int64, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var first, last int64
+	for _, e := range r.entries {
+		if e.UserId != userId {
+			continue
+		}
+
+		if first == 0 || e.Seq < first {
+			first = e.Seq
+		}
+
+		last = max(last, e.Seq)
+	}
+
+	return first, last, nil
+}
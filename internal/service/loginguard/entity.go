@@ -0,0 +1,15 @@
+package loginguard
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	"time"
+)
+
+type Config struct {
+	AccountLimit     ratelimit.Limit // sign in attempts per account, successful included
+	LockoutThreshold int             // failures in LockoutWindow to lock the account, 0 - never lock
+	LockoutWindow    time.Duration   // failures are counted in this window, both for lockout and delays
+	LockoutDuration  time.Duration
+	DelayBase        time.Duration // delay after the first failure, doubled after each next one
+	DelayMax         time.Duration
+}
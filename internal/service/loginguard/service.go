@@ -0,0 +1,175 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	"time"
+)
+
+var (
+	ErrLocked          = errors.New("account temporarily locked")
+	ErrTooManyAttempts = errors.New("too many sign in attempts")
+)
+
+// Service protects sign in from brute force: limits attempts per account, delays attempts
+// progressively after each failure and locks the account after too many failures.
+type Service struct {
+	pkg          string
+	conf         *Config
+	store        ratelimit.Store
+	limiter      Limiter
+	unlockSender UnlockSender
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error)
+}
+
+type UnlockSender interface {
+	SendAccountUnlock(ctx context.Context, email string) error
+}
+
+func NewService(conf *Config, store ratelimit.Store, limiter Limiter, unlockSender UnlockSender) *Service {
+	return &Service{
+		pkg:          "loginguard.service",
+		conf:         conf,
+		store:        store,
+		limiter:      limiter,
+		unlockSender: unlockSender,
+	}
+}
+
+// Check must be called before the password check. It returns the time the client has to wait
+// with ErrLocked or ErrTooManyAttempts.
+func (s *Service) Check(ctx context.Context, email string) (time.Duration, error) {
+	const op = "Check"
+
+	ttl, err := s.store.TTL(ctx, s.lockKey(email))
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	if ttl > 0 {
+		return ttl, ErrLocked
+	}
+
+	ttl, err = s.store.TTL(ctx, s.delayKey(email))
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	if ttl > 0 {
+		return ttl, ErrTooManyAttempts
+	}
+
+	res, err := s.limiter.Allow(ctx, "login:account:"+email, s.conf.AccountLimit)
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	if !res.Allowed {
+		return res.RetryAfter, ErrTooManyAttempts
+	}
+
+	return 0, nil
+}
+
+// Failed registers the failed attempt. Unknown emails are counted too, so responses don't reveal registered emails.
+func (s *Service) Failed(ctx context.Context, email string) error {
+	const op = "Failed"
+
+	failures, err := s.store.Incr(ctx, s.failuresKey(email), s.conf.LockoutWindow)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if s.conf.LockoutThreshold > 0 && failures >= int64(s.conf.LockoutThreshold) {
+		return s.lock(ctx, email)
+	}
+
+	if delay := s.delay(failures); delay > 0 {
+		if _, err := s.store.Incr(ctx, s.delayKey(email), delay); err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+	}
+
+	return nil
+}
+
+// Succeeded resets failures after the successful sign in
+func (s *Service) Succeeded(ctx context.Context, email string) error {
+	const op = "Succeeded"
+
+	if err := s.store.Delete(ctx, s.failuresKey(email)); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Unlock removes the lock and resets failures
+func (s *Service) Unlock(ctx context.Context, email string) error {
+	const op = "Unlock"
+
+	for _, key := range []string{s.lockKey(email), s.delayKey(email), s.failuresKey(email)} {
+		if err := s.store.Delete(ctx, key); err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) lock(ctx context.Context, email string) error {
+	const op = "lock"
+
+	locks, err := s.store.Incr(ctx, s.lockKey(email), s.conf.LockoutDuration)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if err := s.store.Delete(ctx, s.failuresKey(email)); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	// send the unlock link only once per lock
+	if locks == 1 {
+		if err := s.unlockSender.SendAccountUnlock(ctx, email); err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+	}
+
+	return nil
+}
+
+// delay returns DelayBase * 2^(failures-1) limited by DelayMax
+func (s *Service) delay(failures int64) time.Duration {
+	if s.conf.DelayBase <= 0 || failures < 1 {
+		return 0
+	}
+
+	delay := s.conf.DelayBase
+	for i := int64(1); i < failures && delay < s.conf.DelayMax; i++ {
+		delay *= 2
+	}
+
+	if s.conf.DelayMax > 0 && delay > s.conf.DelayMax {
+		return s.conf.DelayMax
+	}
+
+	return delay
+}
+
+func (s *Service) lockKey(email string) string {
+	return "login:lock:" + email
+}
+
+func (s *Service) delayKey(email string) string {
+	return "login:delay:" + email
+}
+
+func (s *Service) failuresKey(email string) string {
+	return "login:failures:" + email
+}
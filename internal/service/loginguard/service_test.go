@@ -0,0 +1,108 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	"testing"
+	"time"
+)
+
+type memoryUnlockSender struct {
+	sent []string
+}
+
+func TestLoginGuardService_ProgressiveDelay(t *testing.T) {
+	service, _ := loginGuardTestService(&Config{LockoutWindow: time.Minute, DelayBase: time.Second, DelayMax: 3 * time.Second})
+	ctx := context.Background()
+
+	if _, err := service.Check(ctx, "test@example.ru"); err != nil {
+		t.Fatalf("first attempt must be allowed, got: %v", err)
+	}
+
+	for i, expected := range []time.Duration{time.Second, 2 * time.Second, 3 * time.Second, 3 * time.Second} {
+		if err := service.Failed(ctx, "test@example.ru"); err != nil {
+			t.Fatalf("error while register failure: %v", err)
+		}
+
+		retryAfter, err := service.Check(ctx, "test@example.ru")
+		if !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("attempt after failure %d must be delayed, got: %v", i+1, err)
+		}
+
+		if retryAfter <= expected-time.Second/2 || retryAfter > expected {
+			t.Errorf("delay after failure %d must be %v, got: %v", i+1, expected, retryAfter)
+		}
+
+		// let the delay pass
+		_ = service.store.Delete(ctx, service.delayKey("test@example.ru"))
+	}
+}
+
+func TestLoginGuardService_Lockout(t *testing.T) {
+	service, sender := loginGuardTestService(&Config{
+		LockoutThreshold: 3,
+		LockoutWindow:    time.Minute,
+		LockoutDuration:  time.Hour,
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := service.Failed(ctx, "test@example.ru"); err != nil {
+			t.Fatalf("error while register failure: %v", err)
+		}
+	}
+
+	retryAfter, err := service.Check(ctx, "test@example.ru")
+	if !errors.Is(err, ErrLocked) {
+		t.Fatalf("account must be locked, got: %v", err)
+	}
+
+	if retryAfter <= 59*time.Minute {
+		t.Errorf("lock must last 1h, got: %v", retryAfter)
+	}
+
+	if len(sender.sent) != 1 || sender.sent[0] != "test@example.ru" {
+		t.Errorf("unlock email must be sent once, got: %v", sender.sent)
+	}
+
+	if _, err := service.Check(ctx, "other@example.ru"); err != nil {
+		t.Errorf("other accounts must not be locked, got: %v", err)
+	}
+
+	if err := service.Unlock(ctx, "test@example.ru"); err != nil {
+		t.Fatalf("error while unlock: %v", err)
+	}
+
+	if _, err := service.Check(ctx, "test@example.ru"); err != nil {
+		t.Errorf("account must be unlocked, got: %v", err)
+	}
+}
+
+func TestLoginGuardService_AccountLimit(t *testing.T) {
+	service, _ := loginGuardTestService(&Config{AccountLimit: ratelimit.Limit{Requests: 2, Window: time.Minute}})
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := service.Check(ctx, "test@example.ru"); err != nil {
+			t.Fatalf("attempt %d must be allowed, got: %v", i+1, err)
+		}
+	}
+
+	if _, err := service.Check(ctx, "test@example.ru"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("attempt over the account limit must be rejected, got: %v", err)
+	}
+}
+
+func loginGuardTestService(conf *Config) (*Service, *memoryUnlockSender) {
+	store := ratelimit.NewMemoryStore()
+	sender := &memoryUnlockSender{}
+
+	return NewService(conf, store, ratelimit.NewLimiter(store), sender), sender
+}
+
+func (m *memoryUnlockSender) SendAccountUnlock(_ context.Context, email string) error {
+	m.sent = append(m.sent, email)
+
+	return nil
+}
@@ -0,0 +1,22 @@
+package mailer
+
+const (
+	DriverSMTP = "smtp"
+	DriverLog  = "log"
+)
+
+type Config struct {
+	Driver   string
+	From     string
+	LogPath  string // empty - write messages to the standard logger
+	Host     string
+	Port     int
+	Username string
+	Password string
+}
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogMailer doesn't send anything, it writes messages to the file or to the standard logger.
+// Use it for dev and tests.
+type LogMailer struct {
+	pkg  string
+	conf *Config
+	mu   sync.Mutex
+}
+
+func NewLogMailer(conf *Config) *LogMailer {
+	return &LogMailer{
+		pkg:  "mailer.log",
+		conf: conf,
+	}
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	const op = "Send"
+
+	text := fmt.Sprintf(
+		"[%s] From: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		time.Now().Format(time.DateTime), m.conf.From, msg.To, msg.Subject, msg.Body,
+	)
+
+	if m.conf.LogPath == "" {
+		log.Print(text)
+
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	file, err := os.OpenFile(m.conf.LogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return logger.Error(m.pkg, op, err)
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			logger.Add(m.pkg, op, err)
+		}
+	}(file)
+
+	if _, err := file.WriteString(text); err != nil {
+		return logger.Error(m.pkg, op, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package mailer
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// New returns mailer for the configured driver, the log mailer is used by default
+func New(conf *Config) Mailer {
+	if conf.Driver == DriverSMTP {
+		return NewSMTPMailer(conf)
+	}
+
+	return NewLogMailer(conf)
+}
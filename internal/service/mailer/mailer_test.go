@@ -0,0 +1,29 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogMailer_Send(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "mail.log")
+	m := New(&Config{Driver: DriverLog, From: "no-reply@example.com", LogPath: logPath})
+
+	err := m.Send(Message{To: "test@example.ru", Subject: "Subject", Body: "https://example.com/link"})
+	if err != nil {
+		t.Fatalf("error while send message: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("error while read mail log: %v", err)
+	}
+
+	for _, s := range []string{"To: test@example.ru", "Subject: Subject", "https://example.com/link"} {
+		if !strings.Contains(string(data), s) {
+			t.Errorf("mail log must contain %q", s)
+		}
+	}
+}
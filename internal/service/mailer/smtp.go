@@ -0,0 +1,60 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+type SMTPMailer struct {
+	pkg  string
+	conf *Config
+}
+
+func NewSMTPMailer(conf *Config) *SMTPMailer {
+	return &SMTPMailer{
+		pkg:  "mailer.smtp",
+		conf: conf,
+	}
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	const op = "Send"
+
+	var auth smtp.Auth
+	if m.conf.Username != "" {
+		auth = smtp.PlainAuth("", m.conf.Username, m.conf.Password, m.conf.Host)
+	}
+
+	err := smtp.SendMail(
+		net.JoinHostPort(m.conf.Host, strconv.Itoa(m.conf.Port)),
+		auth,
+		m.conf.From,
+		[]string{msg.To},
+		m.message(msg),
+	)
+	if err != nil {
+		return logger.Error(m.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (m *SMTPMailer) message(msg Message) []byte {
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, "From: %s\r\n", m.conf.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=\"utf-8\"\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(msg.Body)
+
+	return buf.Bytes()
+}
@@ -0,0 +1,261 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	JobExpiredSessions = "expired-sessions"
+	JobExpiredTokens   = "expired-tokens"
+	JobOrphanedFolders = "orphaned-folders"
+	JobExpiredChanges  = "expired-changes"
+	JobExpiredWebhooks = "expired-webhook-deliveries"
+	JobExpiredJobs     = "expired-jobs"
+	JobExpiredExports  = "expired-exports"
+)
+
+var (
+	ErrUnknownJob     = errors.New("unknown maintenance job")
+	ErrAlreadyRunning = errors.New("maintenance job is already running")
+)
+
+type Service struct {
+	pkg             string
+	userSessionRepo Cleaner
+	userTokenRepo   Cleaner
+	accessTokenRepo Cleaner
+	fileChangeRepo  Cleaner
+	webhookRepo     Cleaner
+	jobRepo         Cleaner
+	exportService   Cleaner
+	userService     UserService
+	s3Service       S3Service
+	events          Publisher
+	mu              sync.Mutex
+	running         map[string]bool
+	wg              sync.WaitGroup
+}
+
+// Cleaner removes expired rows and returns their number
+type Cleaner interface {
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+type S3Service interface {
+	UserIds(ctx context.Context) ([]int64, error)
+	DeleteUserFolder(ctx context.Context, userId int64) error
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+func NewService(
+	userSessionRepo Cleaner,
+	userTokenRepo Cleaner,
+	accessTokenRepo Cleaner,
+	fileChangeRepo Cleaner,
+	webhookRepo Cleaner,
+	jobRepo Cleaner,
+	exportService Cleaner,
+	userService UserService,
+	s3Service S3Service,
+	publisher Publisher,
+) *Service {
+	return &Service{
+		pkg:             "maintenance.service",
+		userSessionRepo: userSessionRepo,
+		userTokenRepo:   userTokenRepo,
+		accessTokenRepo: accessTokenRepo,
+		fileChangeRepo:  fileChangeRepo,
+		webhookRepo:     webhookRepo,
+		jobRepo:         jobRepo,
+		exportService:   exportService,
+		userService:     userService,
+		s3Service:       s3Service,
+		events:          publisher,
+		running:         map[string]bool{},
+	}
+}
+
+// Jobs returns names of the maintenance jobs
+func (s *Service) Jobs() []string {
+	return []string{
+		JobExpiredSessions,
+		JobExpiredTokens,
+		JobOrphanedFolders,
+		JobExpiredChanges,
+		JobExpiredWebhooks,
+		JobExpiredJobs,
+		JobExpiredExports,
+	}
+}
+
+// Start runs the job in background, the same job can't run twice at the same time.
+// The user who started the job gets the event when it's done
+func (s *Service) Start(ctx context.Context, userId int64, job string) error {
+	if !s.isJob(job) {
+		return ErrUnknownJob
+	}
+
+	s.mu.Lock()
+	if s.running[job] {
+		s.mu.Unlock()
+
+		return ErrAlreadyRunning
+	}
+	s.running[job] = true
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, job)
+			s.mu.Unlock()
+		}()
+
+		started := time.Now()
+
+		// the job outlives the request, but stays in its trace
+		ctx := context.WithoutCancel(ctx)
+
+		removed, err := s.Run(ctx, job)
+		s.done(ctx, userId, job, removed, err)
+
+		if err != nil {
+			logger.AddContext(ctx, s.pkg, "Start", err)
+
+			return
+		}
+
+		slog.Info("maintenance job finished", "pkg", s.pkg, "job", job, "removed", removed, "duration", time.Since(started))
+	}()
+
+	return nil
+}
+
+// Wait blocks until started jobs are finished
+func (s *Service) Wait() {
+	s.wg.Wait()
+}
+
+// Run runs the job synchronously and returns the number of removed items
+func (s *Service) Run(ctx context.Context, job string) (int64, error) {
+	const op = "Run"
+
+	var (
+		removed int64
+		err     error
+	)
+
+	switch job {
+	case JobExpiredSessions:
+		removed, err = s.userSessionRepo.DeleteExpired(ctx)
+	case JobExpiredTokens:
+		removed, err = s.deleteExpiredTokens(ctx)
+	case JobOrphanedFolders:
+		removed, err = s.deleteOrphanedFolders(ctx)
+	case JobExpiredChanges:
+		removed, err = s.fileChangeRepo.DeleteExpired(ctx)
+	case JobExpiredWebhooks:
+		removed, err = s.webhookRepo.DeleteExpired(ctx)
+	case JobExpiredJobs:
+		removed, err = s.jobRepo.DeleteExpired(ctx)
+	case JobExpiredExports:
+		removed, err = s.exportService.DeleteExpired(ctx)
+	default:
+		return 0, ErrUnknownJob
+	}
+
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	return removed, nil
+}
+
+func (s *Service) deleteExpiredTokens(ctx context.Context) (int64, error) {
+	userTokens, err := s.userTokenRepo.DeleteExpired(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	accessTokens, err := s.accessTokenRepo.DeleteExpired(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return userTokens + accessTokens, nil
+}
+
+// deleteOrphanedFolders removes files of users which don't exist anymore,
+// e.g. if the removal after the account deletion has been interrupted
+func (s *Service) deleteOrphanedFolders(ctx context.Context) (int64, error) {
+	userIds, err := s.s3Service.UserIds(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, userId := range userIds {
+		_, err := s.userService.UserById(ctx, userId)
+		if err == nil {
+			continue
+		}
+
+		if !errors.Is(err, userservice.ErrNotFound) {
+			return removed, err
+		}
+
+		if err := s.s3Service.DeleteUserFolder(ctx, userId); err != nil {
+			return removed, err
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (s *Service) done(ctx context.Context, userId int64, job string, removed int64, err error) {
+	event := events.Event{
+		UserId: userId,
+		Type:   events.TypeJobDone,
+		Data: map[string]string{
+			"job":     job,
+			"result":  "success",
+			"removed": strconv.FormatInt(removed, 10),
+		},
+	}
+
+	if err != nil {
+		event.Data["result"] = "failure"
+	}
+
+	s.events.Publish(ctx, event)
+}
+
+func (s *Service) isJob(job string) bool {
+	for _, j := range s.Jobs() {
+		if j == job {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,134 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"slices"
+	"testing"
+)
+
+type memoryCleaner struct {
+	expired int64
+}
+
+type memoryUserService struct {
+	users map[int64]bool
+}
+
+type memoryS3Service struct {
+	folders []int64
+	deleted []int64
+}
+
+type memoryPublisher struct {
+	events []events.Event
+}
+
+func TestMaintenanceService_Run(t *testing.T) {
+	s3 := &memoryS3Service{folders: []int64{1, 2, 3}}
+	service := NewService(
+		&memoryCleaner{expired: 3},
+		&memoryCleaner{expired: 2},
+		&memoryCleaner{expired: 1},
+		&memoryCleaner{expired: 4},
+		&memoryCleaner{expired: 5},
+		&memoryCleaner{expired: 6},
+		&memoryCleaner{expired: 7},
+		&memoryUserService{users: map[int64]bool{1: true, 3: true}},
+		s3,
+		&memoryPublisher{},
+	)
+
+	for job, expected := range map[string]int64{
+		JobExpiredSessions: 3,
+		JobExpiredTokens:   3,
+		JobOrphanedFolders: 1,
+		JobExpiredChanges:  4,
+		JobExpiredWebhooks: 5,
+		JobExpiredJobs:     6,
+		JobExpiredExports:  7,
+	} {
+		removed, err := service.Run(context.Background(), job)
+		if err != nil {
+			t.Fatalf("error while run %s: %v", job, err)
+		}
+
+		if removed != expected {
+			t.Errorf("job %s must remove %d, got: %d", job, expected, removed)
+		}
+	}
+
+	if !slices.Equal(s3.deleted, []int64{2}) {
+		t.Errorf("only folder of the deleted user 2 must be removed, got: %v", s3.deleted)
+	}
+
+	if _, err := service.Run(context.Background(), "unknown"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown job must return ErrUnknownJob, got: %v", err)
+	}
+}
+
+func TestMaintenanceService_Start(t *testing.T) {
+	publisher := &memoryPublisher{}
+	service := NewService(
+		&memoryCleaner{expired: 2},
+		&memoryCleaner{},
+		&memoryCleaner{},
+		&memoryCleaner{},
+		&memoryCleaner{},
+		&memoryCleaner{},
+		&memoryCleaner{},
+		&memoryUserService{},
+		&memoryS3Service{},
+		publisher,
+	)
+
+	if err := service.Start(context.Background(), 1, "unknown"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown job must return ErrUnknownJob, got: %v", err)
+	}
+
+	if err := service.Start(context.Background(), 1, JobExpiredSessions); err != nil {
+		t.Fatalf("error while start job: %v", err)
+	}
+
+	service.Wait()
+
+	if len(publisher.events) != 1 || publisher.events[0].UserId != 1 || publisher.events[0].Data["removed"] != "2" {
+		t.Errorf("user who started the job must get the event when it's done, got: %+v", publisher.events)
+	}
+
+	// the finished job can be started again
+	if err := service.Start(context.Background(), 1, JobExpiredSessions); err != nil {
+		t.Errorf("error while start finished job again: %v", err)
+	}
+
+	service.Wait()
+}
+
+func (p *memoryPublisher) Publish(_ context.Context, event events.Event) {
+	p.events = append(p.events, event)
+}
+
+func (c *memoryCleaner) DeleteExpired(_ context.Context) (int64, error) {
+	return c.expired, nil
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	if !s.users[userId] {
+		return user.User{}, userservice.ErrNotFound
+	}
+
+	return user.User{Id: userId}, nil
+}
+
+func (s *memoryS3Service) UserIds(ctx context.Context) ([]int64, error) {
+	return s.folders, nil
+}
+
+func (s *memoryS3Service) DeleteUserFolder(ctx context.Context, userId int64) error {
+	s.deleted = append(s.deleted, userId)
+
+	return nil
+}
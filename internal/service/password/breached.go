@@ -0,0 +1,225 @@
+package password
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed breached.txt
+var bundledBreachedList string
+
+// prefixLength is the length of the hash prefix used for k-anonymity lookups (as in the Have I Been Pwned range API)
+const prefixLength = 5
+
+// maxLineLength limits lines of the list file, a line is the hash, the colon and the count
+const maxLineLength = 128
+
+// BreachedChecker checks passwords against a list of SHA-1 hashes of leaked passwords.
+// The bundled list is grouped by a 5 chars prefix in memory, so a lookup only touches the suffixes of one range.
+// The list file is searched on disk, so it may be as large as the full Have I Been Pwned list
+type BreachedChecker struct {
+	ranges map[string]map[string]struct{}
+	file   *sortedList // nil for the bundled list
+}
+
+// NewBreachedChecker opens the list file in the Have I Been Pwned format (HASH or HASH:COUNT per line),
+// lines must be sorted by hash like in the download of the list ordered by hash.
+// The bundled list of the most common passwords is used if the path is empty.
+func NewBreachedChecker(path string) (*BreachedChecker, error) {
+	const op = "NewBreachedChecker"
+
+	if path == "" {
+		return newBreachedChecker(strings.NewReader(bundledBreachedList))
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, logger.Error("password", op, err)
+	}
+
+	list, err := newSortedList(file)
+	if err != nil {
+		_ = file.Close()
+
+		return nil, logger.Error("password", op, err)
+	}
+
+	return &BreachedChecker{file: list}, nil
+}
+
+// IsBreached reports whether the password is in the list. Errors of reading the list file are logged,
+// the password isn't rejected then
+func (c *BreachedChecker) IsBreached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	if c.file != nil {
+		ok, err := c.file.contains(hash)
+		if err != nil {
+			logger.Add("password", "IsBreached", err)
+		}
+
+		return ok
+	}
+
+	suffixes, ok := c.ranges[hash[:prefixLength]]
+	if !ok {
+		return false
+	}
+
+	_, ok = suffixes[hash[prefixLength:]]
+
+	return ok
+}
+
+func newBreachedChecker(r io.Reader) (*BreachedChecker, error) {
+	c := &BreachedChecker{ranges: make(map[string]map[string]struct{})}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		hash, err := parseHash(line)
+		if err != nil {
+			return nil, err
+		}
+
+		prefix := hash[:prefixLength]
+
+		if _, ok := c.ranges[prefix]; !ok {
+			c.ranges[prefix] = make(map[string]struct{})
+		}
+
+		c.ranges[prefix][hash[prefixLength:]] = struct{}{}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, logger.Error("password", "newBreachedChecker", err)
+	}
+
+	return c, nil
+}
+
+// sortedList is the list file sorted by hash, hashes are found by the binary search over offsets in the file
+type sortedList struct {
+	file *os.File
+	size int64
+}
+
+func newSortedList(file *os.File) (*sortedList, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &sortedList{file: file, size: info.Size()}
+
+	// the format is checked by the first line, the file is too large to be read at startup
+	if _, line, err := l.lineFrom(0); err != nil {
+		return nil, err
+	} else if _, err := parseHash(line); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// contains reports whether the hash is in the list. Lines starting in [lo, hi) are searched,
+// the line found from the middle offset halves the range
+func (l *sortedList) contains(hash string) (bool, error) {
+	lo, hi := int64(0), l.size
+
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, line, err := l.lineFrom(mid)
+		if err != nil {
+			return false, err
+		}
+
+		// no line starts in [mid, hi)
+		if start >= hi {
+			hi = mid
+
+			continue
+		}
+
+		lineHash, err := parseHash(line)
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.Compare(lineHash, hash) {
+		case 0:
+			return true, nil
+		case -1:
+			lo = start + int64(len(line)) + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return false, nil
+}
+
+// lineFrom returns the first line starting at the offset or after it, the start is the size of the file
+// if there is no such line. The line doesn't include the line break
+func (l *sortedList) lineFrom(offset int64) (int64, string, error) {
+	// the byte before the offset tells if the line starts at the offset
+	from := max(offset-1, 0)
+
+	buf := make([]byte, 2*maxLineLength)
+	n, err := l.file.ReadAt(buf, from)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return 0, "", err
+	}
+	buf = buf[:n]
+
+	start := from
+	if offset > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 {
+			if from+int64(n) < l.size {
+				return 0, "", fmt.Errorf("password: line longer than %d bytes in breached list", maxLineLength)
+			}
+
+			return l.size, "", nil
+		}
+
+		buf = buf[i+1:]
+		start = from + int64(i) + 1
+	}
+
+	end := bytes.IndexByte(buf, '\n')
+	if end < 0 {
+		if start+int64(len(buf)) < l.size {
+			return 0, "", fmt.Errorf("password: line longer than %d bytes in breached list", maxLineLength)
+		}
+
+		end = len(buf)
+	}
+
+	return start, string(buf[:end]), nil
+}
+
+// parseHash returns the upper case hash of the line HASH or HASH:COUNT
+func parseHash(line string) (string, error) {
+	hash, _, _ := strings.Cut(strings.TrimSpace(line), ":")
+	if len(hash) != sha1.Size*2 {
+		return "", fmt.Errorf("password: invalid hash in breached list: %q", hash)
+	}
+
+	return strings.ToUpper(hash), nil
+}
@@ -0,0 +1,71 @@
+package password
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// MaxBcryptLength bcrypt ignores bytes after the 72nd
+const MaxBcryptLength = 72
+
+type Policy struct {
+	MinLength     int
+	MaxLength     int // in bytes, can't be greater than MaxBcryptLength
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// Validate returns a list of the policy violations, empty list means the password is valid
+func (p Policy) Validate(password string) []string {
+	var violations []string
+
+	if len([]rune(password)) < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters long", p.MinLength))
+	}
+
+	if len(password) > p.maxLength() {
+		violations = append(violations, fmt.Sprintf("must be at most %d bytes long", p.maxLength()))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r) || unicode.IsSpace(r):
+			hasSymbol = true
+		}
+	}
+
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	return violations
+}
+
+func (p Policy) maxLength() int {
+	if p.MaxLength <= 0 || p.MaxLength > MaxBcryptLength {
+		return MaxBcryptLength
+	}
+
+	return p.MaxLength
+}
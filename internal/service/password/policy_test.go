@@ -0,0 +1,124 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPolicy_Validate(t *testing.T) {
+	policy := Policy{
+		MinLength:     8,
+		MaxLength:     100,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+
+	cases := map[string]int{
+		"Very-strong-secret-7":     0,
+		"a":                        4, // short, no upper, no digit, no symbol
+		"alllowercase":             3,
+		"ALLUPPERCASE-1":           1,
+		strings.Repeat("Aa1-", 19): 1, // 76 bytes, max length is capped by bcrypt limit
+	}
+
+	for passwd, expected := range cases {
+		violations := policy.Validate(passwd)
+		if len(violations) != expected {
+			t.Errorf("password %q: expected %d violations, got: %v", passwd, expected, violations)
+		}
+	}
+}
+
+func TestBreachedChecker_IsBreached(t *testing.T) {
+	checker, err := NewBreachedChecker("")
+	if err != nil {
+		t.Fatalf("error while load bundled breached list: %v", err)
+	}
+
+	if !checker.IsBreached("password123") {
+		t.Error("password123 must be breached")
+	}
+
+	if checker.IsBreached("very-strong-secret-7") {
+		t.Error("very-strong-secret-7 must not be breached")
+	}
+}
+
+func TestBreachedChecker_IsBreachedCustomList(t *testing.T) {
+	checker, err := newBreachedChecker(strings.NewReader(
+		"# sha1 of very-strong-secret-7\n" + "1318fd93bd66499459c7b9e07d197fb9c3aa915c:3\n",
+	))
+	if err != nil {
+		t.Fatalf("error while load breached list: %v", err)
+	}
+
+	if !checker.IsBreached("very-strong-secret-7") {
+		t.Error("very-strong-secret-7 must be breached")
+	}
+
+	if checker.IsBreached("password123") {
+		t.Error("custom list must not contain password123")
+	}
+}
+
+func TestBreachedChecker_IsBreachedSortedFile(t *testing.T) {
+	var (
+		lines     []string
+		breached  []string
+		untouched []string
+	)
+
+	for i := range 1000 {
+		passwd := fmt.Sprintf("password-%d", i)
+		if i%2 == 1 {
+			untouched = append(untouched, passwd)
+
+			continue
+		}
+
+		sum := sha1.Sum([]byte(passwd))
+		lines = append(lines, fmt.Sprintf("%s:%d\r\n", strings.ToUpper(hex.EncodeToString(sum[:])), i))
+		breached = append(breached, passwd)
+	}
+
+	slices.Sort(lines)
+
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "")), 0o600); err != nil {
+		t.Fatalf("error while write breached list: %v", err)
+	}
+
+	checker, err := NewBreachedChecker(path)
+	if err != nil {
+		t.Fatalf("error while open breached list: %v", err)
+	}
+
+	for _, passwd := range breached {
+		if !checker.IsBreached(passwd) {
+			t.Errorf("%s must be breached", passwd)
+		}
+	}
+
+	for _, passwd := range untouched {
+		if checker.IsBreached(passwd) {
+			t.Errorf("%s must not be breached", passwd)
+		}
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.txt")
+	if err := os.WriteFile(invalid, []byte("password123\n"), 0o600); err != nil {
+		t.Fatalf("error while write breached list: %v", err)
+	}
+
+	if _, err := NewBreachedChecker(invalid); err == nil {
+		t.Error("list file with invalid hashes must not be opened")
+	}
+}
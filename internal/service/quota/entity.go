@@ -0,0 +1,12 @@
+package quota
+
+type Config struct {
+	DefaultBytes   int64 // 0 - unlimited
+	WarningPercent int64 // the user is warned when the upload fills the quota to this share, 0 - never
+}
+
+type Usage struct {
+	UsedBytes  int64
+	Objects    int64
+	QuotaBytes int64 // 0 - unlimited
+}
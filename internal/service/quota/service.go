@@ -0,0 +1,115 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"strconv"
+)
+
+var ErrExceeded = errors.New("storage quota exceeded")
+
+type Service struct {
+	pkg         string
+	conf        *Config
+	userService UserService
+	s3Service   S3Service
+	events      Publisher
+}
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+type S3Service interface {
+	Usage(ctx context.Context, userId int64) (int64, int64, error)
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+func NewService(conf *Config, userService UserService, s3Service S3Service, publisher Publisher) *Service {
+	return &Service{
+		pkg:         "quota.service",
+		conf:        conf,
+		userService: userService,
+		s3Service:   s3Service,
+		events:      publisher,
+	}
+}
+
+// Usage returns used storage and the quota of the user
+func (s *Service) Usage(ctx context.Context, userId int64) (Usage, error) {
+	const op = "Usage"
+
+	quota, err := s.quota(ctx, userId)
+	if err != nil {
+		return Usage{}, logger.Error(s.pkg, op, err)
+	}
+
+	size, objects, err := s.s3Service.Usage(ctx, userId)
+	if err != nil {
+		return Usage{}, logger.Error(s.pkg, op, err)
+	}
+
+	return Usage{UsedBytes: size, Objects: objects, QuotaBytes: quota}, nil
+}
+
+// Check returns ErrExceeded if storing incoming bytes exceeds the quota of the user
+func (s *Service) Check(ctx context.Context, userId int64, incoming int64) error {
+	const op = "Check"
+
+	quota, err := s.quota(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if quota == 0 {
+		return nil
+	}
+
+	// fail fast without listing the storage
+	if incoming > quota {
+		return ErrExceeded
+	}
+
+	size, _, err := s.s3Service.Usage(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if size+incoming > quota {
+		return ErrExceeded
+	}
+
+	// only the upload crossing the threshold warns, so the user isn't warned about every next file
+	threshold := quota * s.conf.WarningPercent / 100
+	if threshold > 0 && size < threshold && size+incoming >= threshold {
+		s.events.Publish(ctx, events.Event{
+			UserId: userId,
+			Type:   events.TypeQuotaWarning,
+			Data: map[string]string{
+				"used_bytes":  strconv.FormatInt(size+incoming, 10),
+				"quota_bytes": strconv.FormatInt(quota, 10),
+			},
+		})
+	}
+
+	return nil
+}
+
+func (s *Service) quota(ctx context.Context, userId int64) (int64, error) {
+	us, err := s.userService.UserById(ctx, userId)
+	if err != nil {
+		return 0, err
+	}
+
+	if us.QuotaBytes.Valid {
+		return us.QuotaBytes.Int64, nil
+	}
+
+	return s.conf.DefaultBytes, nil
+}
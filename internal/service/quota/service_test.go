@@ -0,0 +1,93 @@
+package quota
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"testing"
+)
+
+type memoryUserService struct {
+	users map[int64]user.User
+}
+
+type memoryS3Service struct {
+	sizes map[int64]int64
+}
+
+type memoryPublisher struct {
+	events []events.Event
+}
+
+func TestQuotaService_Check(t *testing.T) {
+	publisher := &memoryPublisher{}
+	service := NewService(
+		&Config{DefaultBytes: 100, WarningPercent: 90},
+		&memoryUserService{users: map[int64]user.User{
+			1: {Id: 1},
+			2: {Id: 2, QuotaBytes: sql.NullInt64{Int64: 1000, Valid: true}},
+			3: {Id: 3, QuotaBytes: sql.NullInt64{Int64: 0, Valid: true}},
+		}},
+		&memoryS3Service{sizes: map[int64]int64{1: 60, 2: 60, 3: 5000}},
+		publisher,
+	)
+
+	for _, tc := range []struct {
+		userId   int64
+		incoming int64
+		err      error
+	}{
+		{userId: 1, incoming: 40, err: nil},          // default quota, exactly full
+		{userId: 1, incoming: 41, err: ErrExceeded},  // default quota
+		{userId: 1, incoming: 500, err: ErrExceeded}, // larger than the quota itself
+		{userId: 2, incoming: 500, err: nil},         // own quota
+		{userId: 3, incoming: 1 << 40, err: nil},     // unlimited
+	} {
+		err := service.Check(context.Background(), tc.userId, tc.incoming)
+		if !errors.Is(err, tc.err) {
+			t.Errorf("user %d storing %d bytes must return %v, got: %v", tc.userId, tc.incoming, tc.err, err)
+		}
+	}
+
+	// only the upload filling the default quota of the first user crosses 90%
+	if len(publisher.events) != 1 || publisher.events[0].UserId != 1 || publisher.events[0].Data["used_bytes"] != "100" {
+		t.Errorf("upload crossing the threshold must warn the user, got: %+v", publisher.events)
+	}
+}
+
+func TestQuotaService_Usage(t *testing.T) {
+	service := NewService(
+		&Config{DefaultBytes: 100},
+		&memoryUserService{users: map[int64]user.User{1: {Id: 1}}},
+		&memoryS3Service{sizes: map[int64]int64{1: 60}},
+		&memoryPublisher{},
+	)
+
+	usage, err := service.Usage(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("error while get usage: %v", err)
+	}
+
+	if usage.UsedBytes != 60 || usage.QuotaBytes != 100 {
+		t.Errorf("usage must be 60 of 100 bytes, got: %+v", usage)
+	}
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	us, ok := s.users[userId]
+	if !ok {
+		return user.User{}, errors.New("user not found")
+	}
+
+	return us, nil
+}
+
+func (p *memoryPublisher) Publish(_ context.Context, event events.Event) {
+	p.events = append(p.events, event)
+}
+
+func (s *memoryS3Service) Usage(ctx context.Context, userId int64) (int64, int64, error) {
+	return s.sizes[userId], 1, nil
+}
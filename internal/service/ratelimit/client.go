@@ -0,0 +1,27 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/redis/go-redis/v9"
+	"log"
+)
+
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+func NewRedisClient(conf *config.Config) *redis.Client {
+	client := redis.NewClient(&redis.Options{
+		Addr:     conf.RedisAddr,
+		Password: conf.RedisPassword,
+		DB:       conf.RedisDB,
+	})
+
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		log.Fatal("redis is not response:", err)
+	}
+
+	return client
+}
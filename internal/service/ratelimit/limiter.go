@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"math"
+	"time"
+)
+
+type Limit struct {
+	Requests int
+	Window   time.Duration
+}
+
+// Enabled zero limit disables limiting
+func (l Limit) Enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// Limiter implements the sliding window counter: the counter of the previous window
+// is weighted by the part of it which still overlaps the sliding window.
+type Limiter struct {
+	pkg   string
+	store Store
+	now   func() time.Time
+}
+
+func NewLimiter(store Store) *Limiter {
+	return &Limiter{
+		pkg:   "ratelimit.limiter",
+		store: store,
+		now:   time.Now,
+	}
+}
+
+// Allow registers the request for the key and reports whether it fits the limit
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	const op = "Allow"
+
+	if !limit.Enabled() {
+		return Result{Allowed: true}, nil
+	}
+
+	now := l.now()
+	windowStart := now.Truncate(limit.Window)
+	elapsed := now.Sub(windowStart)
+
+	current, err := l.store.Incr(ctx, l.windowKey(key, windowStart), limit.Window*2)
+	if err != nil {
+		return Result{}, logger.Error(l.pkg, op, err)
+	}
+
+	previous, err := l.store.Get(ctx, l.windowKey(key, windowStart.Add(-limit.Window)))
+	if err != nil {
+		return Result{}, logger.Error(l.pkg, op, err)
+	}
+
+	weight := 1 - float64(elapsed)/float64(limit.Window)
+	count := int(math.Floor(float64(previous)*weight)) + int(current)
+
+	if count > limit.Requests {
+		return Result{
+			Allowed:    false,
+			Limit:      limit.Requests,
+			RetryAfter: limit.Window - elapsed,
+		}, nil
+	}
+
+	return Result{
+		Allowed:   true,
+		Limit:     limit.Requests,
+		Remaining: limit.Requests - count,
+	}, nil
+}
+
+func (l *Limiter) windowKey(key string, windowStart time.Time) string {
+	return fmt.Sprintf("%s:%d", key, windowStart.Unix())
+}
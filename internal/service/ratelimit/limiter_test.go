@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := limiterTest(&now)
+	limit := Limit{Requests: 3, Window: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		res, err := limiter.Allow(context.Background(), "key", limit)
+		if err != nil {
+			t.Fatalf("error while allow: %v", err)
+		}
+
+		if !res.Allowed {
+			t.Fatalf("request %d must be allowed", i+1)
+		}
+	}
+
+	res, err := limiter.Allow(context.Background(), "key", limit)
+	if err != nil {
+		t.Fatalf("error while allow: %v", err)
+	}
+
+	if res.Allowed {
+		t.Error("request over the limit must not be allowed")
+	}
+
+	if res.RetryAfter != time.Minute {
+		t.Errorf("retry after must be 1m, got: %v", res.RetryAfter)
+	}
+
+	// other keys have their own budget
+	res, _ = limiter.Allow(context.Background(), "other", limit)
+	if !res.Allowed {
+		t.Error("request for another key must be allowed")
+	}
+}
+
+func TestLimiter_AllowSlidingWindow(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	limiter := limiterTest(&now)
+	limit := Limit{Requests: 4, Window: time.Minute}
+
+	for i := 0; i < 4; i++ {
+		_, _ = limiter.Allow(context.Background(), "key", limit)
+	}
+
+	// a quarter of the next window: 3 of 4 previous requests still count
+	now = now.Add(time.Minute + 15*time.Second)
+
+	res, _ := limiter.Allow(context.Background(), "key", limit)
+	if !res.Allowed || res.Remaining != 0 {
+		t.Errorf("one request must be allowed, got: %+v", res)
+	}
+
+	res, _ = limiter.Allow(context.Background(), "key", limit)
+	if res.Allowed {
+		t.Error("request over the sliding window limit must not be allowed")
+	}
+}
+
+func TestLimiter_AllowDisabled(t *testing.T) {
+	now := time.Now()
+	limiter := limiterTest(&now)
+
+	for i := 0; i < 100; i++ {
+		res, _ := limiter.Allow(context.Background(), "key", Limit{})
+		if !res.Allowed {
+			t.Fatal("zero limit must allow all requests")
+		}
+	}
+}
+
+func limiterTest(now *time.Time) *Limiter {
+	store := NewMemoryStore()
+	store.now = func() time.Time { return *now }
+
+	limiter := NewLimiter(store)
+	limiter.now = func() time.Time { return *now }
+
+	return limiter
+}
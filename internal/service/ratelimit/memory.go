@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval how often expired counters are removed from the memory store
+const sweepInterval = time.Minute
+
+type memoryCounter struct {
+	value     int64
+	expiresAt time.Time
+}
+
+// MemoryStore keeps counters in the process memory, counters are not shared between replicas
+type MemoryStore struct {
+	mu        sync.Mutex
+	counters  map[string]memoryCounter
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]memoryCounter),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (m *MemoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expiresAt) {
+		c = memoryCounter{expiresAt: now.Add(ttl)}
+	}
+
+	c.value++
+	m.counters[key] = c
+
+	return c.value, nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.counters[key]
+	if !ok || !m.now().Before(c.expiresAt) {
+		return 0, nil
+	}
+
+	return c.value, nil
+}
+
+func (m *MemoryStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.counters[key]
+	if !ok {
+		return 0, nil
+	}
+
+	ttl := c.expiresAt.Sub(m.now())
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counters, key)
+
+	return nil
+}
+
+// sweep removes expired counters, must be called under the lock
+func (m *MemoryStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, c := range m.counters {
+		if !now.Before(c.expiresAt) {
+			delete(m.counters, key)
+		}
+	}
+
+	m.lastSweep = now
+}
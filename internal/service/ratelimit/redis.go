@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+// RedisStore keeps counters in Redis (or any server speaking the Redis protocol), so limits are shared between replicas
+type RedisStore struct {
+	pkg    string
+	prefix string
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{
+		pkg:    "ratelimit.redis",
+		prefix: prefix,
+		client: client,
+	}
+}
+
+func (r *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	const op = "Incr"
+
+	v, err := r.client.Incr(ctx, r.prefix+key).Result()
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	// the counter is just created, set expiration only once, so the window is not extended by each request
+	if v == 1 {
+		if err := r.client.PExpire(ctx, r.prefix+key, ttl).Err(); err != nil {
+			return 0, logger.Error(r.pkg, op, err)
+		}
+	}
+
+	return v, nil
+}
+
+func (r *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	const op = "Get"
+
+	v, err := r.client.Get(ctx, r.prefix+key).Int64()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, nil
+		}
+
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return v, nil
+}
+
+func (r *RedisStore) TTL(ctx context.Context, key string) (time.Duration, error) {
+	const op = "TTL"
+
+	ttl, err := r.client.PTTL(ctx, r.prefix+key).Result()
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	// negative values mean the key doesn't exist or has no expiration
+	if ttl < 0 {
+		return 0, nil
+	}
+
+	return ttl, nil
+}
+
+func (r *RedisStore) Delete(ctx context.Context, key string) error {
+	const op = "Delete"
+
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
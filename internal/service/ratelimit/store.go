@@ -0,0 +1,17 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps counters with expiration. Implementations must be safe for concurrent use.
+type Store interface {
+	// Incr increments the counter and returns the new value. Expiration is set when the counter is created.
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Get returns the counter value, 0 if the counter doesn't exist
+	Get(ctx context.Context, key string) (int64, error)
+	// TTL returns time left until the counter expires, 0 if the counter doesn't exist
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Delete(ctx context.Context, key string) error
+}
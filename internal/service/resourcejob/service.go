@@ -0,0 +1,74 @@
+package resourcejob
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+)
+
+// Types of jobs on directories of users, they are too long for a request as directories may hold any number of objects
+const (
+	TypeDelete = "resource.delete"
+	TypeMove   = "resource.move"
+)
+
+// Directory is the directory of the user, Key is its clean path with the user folder
+type Directory struct {
+	Path string `json:"path"`
+	Key  string `json:"key"`
+}
+
+type DeletePayload struct {
+	Directory Directory `json:"directory"`
+}
+
+type MovePayload struct {
+	From Directory `json:"from"`
+	To   Directory `json:"to"`
+}
+
+type Service struct {
+	s3Service S3Service
+}
+
+type S3Service interface {
+	DeleteDirectory(ctx context.Context, path resource.Path, progress s3.Progress) error
+	MoveDirectory(ctx context.Context, to, from resource.Path, progress s3.Progress) error
+}
+
+// Register sets handlers of resource jobs, their progress counts processed objects of the directory
+func Register(jobService *job.Service, s3Service S3Service) {
+	s := &Service{s3Service: s3Service}
+
+	job.Register(jobService, TypeDelete, s.Delete)
+	job.Register(jobService, TypeMove, s.Move)
+}
+
+// NewDirectory returns the directory to put into the payload
+func NewDirectory(path resource.Path) Directory {
+	return Directory{Path: path.OriginalPath, Key: path.CleanPath}
+}
+
+// Delete removes the directory, objects removed by the interrupted run stay removed and the next run removes the rest
+func (s *Service) Delete(ctx context.Context, run *job.Run, payload DeletePayload) error {
+	if err := s.s3Service.DeleteDirectory(ctx, payload.Directory.resourcePath(), run.Progress); err != nil {
+		return err
+	}
+
+	return run.SetResult(map[string]string{"path": payload.Directory.Path})
+}
+
+// Move moves the directory, objects copied by the interrupted run are copied again by the next run
+func (s *Service) Move(ctx context.Context, run *job.Run, payload MovePayload) error {
+	err := s.s3Service.MoveDirectory(ctx, payload.To.resourcePath(), payload.From.resourcePath(), run.Progress)
+	if err != nil {
+		return err
+	}
+
+	return run.SetResult(map[string]string{"from": payload.From.Path, "to": payload.To.Path})
+}
+
+func (d Directory) resourcePath() resource.Path {
+	return resource.Path{IsDirectory: true, OriginalPath: d.Path, CleanPath: d.Key}
+}
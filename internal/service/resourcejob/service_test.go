@@ -0,0 +1,78 @@
+package resourcejob
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/job"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"testing"
+)
+
+type memoryS3Service struct {
+	deleted []resource.Path
+	moved   [][2]resource.Path
+	err     error
+}
+
+func TestResourceJobService_Delete(t *testing.T) {
+	s3Service := &memoryS3Service{}
+	service := &Service{s3Service: s3Service}
+	dir := NewDirectory(resource.Path{IsDirectory: true, OriginalPath: "/docs/", CleanPath: "user-1-files/docs"})
+
+	if err := service.Delete(context.Background(), &job.Run{}, DeletePayload{Directory: dir}); err != nil {
+		t.Fatalf("error while delete directory: %v", err)
+	}
+
+	expected := resource.Path{IsDirectory: true, OriginalPath: "/docs/", CleanPath: "user-1-files/docs"}
+	if len(s3Service.deleted) != 1 || s3Service.deleted[0] != expected {
+		t.Errorf("directory of the payload must be deleted, got: %+v", s3Service.deleted)
+	}
+
+	s3Service.err = errors.New("storage is unavailable")
+
+	if err := service.Delete(context.Background(), &job.Run{}, DeletePayload{Directory: dir}); err == nil {
+		t.Error("error of the storage must be returned to retry the job")
+	}
+}
+
+func TestResourceJobService_Move(t *testing.T) {
+	s3Service := &memoryS3Service{}
+	service := &Service{s3Service: s3Service}
+
+	payload := MovePayload{
+		From: Directory{Path: "/docs/", Key: "user-1-files/docs"},
+		To:   Directory{Path: "/archive/docs/", Key: "user-1-files/archive/docs"},
+	}
+
+	if err := service.Move(context.Background(), &job.Run{}, payload); err != nil {
+		t.Fatalf("error while move directory: %v", err)
+	}
+
+	if len(s3Service.moved) != 1 || s3Service.moved[0][0].CleanPath != "user-1-files/archive/docs" ||
+		s3Service.moved[0][1].CleanPath != "user-1-files/docs" {
+		t.Errorf("directory must be moved from and to paths of the payload, got: %+v", s3Service.moved)
+	}
+}
+
+func (s *memoryS3Service) DeleteDirectory(_ context.Context, path resource.Path, progress s3.Progress) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.deleted = append(s.deleted, path)
+	progress(1, 1)
+
+	return nil
+}
+
+func (s *memoryS3Service) MoveDirectory(_ context.Context, to, from resource.Path, progress s3.Progress) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.moved = append(s.moved, [2]resource.Path{to, from})
+	progress(2, 2)
+
+	return nil
+}
@@ -0,0 +1,262 @@
+package s3
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Formats of archives
+const (
+	ArchiveZip   = "zip"
+	ArchiveTarGz = "tar.gz"
+)
+
+// archive writes files as entries of the archive
+type archive interface {
+	add(name string, info minio.ObjectInfo, reader io.Reader) error
+	Close() error
+}
+
+// WriteArchive writes files under the paths of the user into w as the archive of the format, entries are named
+// by their paths in the user folder. It returns ErrNotFound if any of the paths doesn't exist.
+// The progress counts written files, it stops when the context is done
+func (s *Service) WriteArchive(
+	ctx context.Context,
+	w io.Writer,
+	format string,
+	userId int64,
+	paths []resource.Path,
+	progress Progress,
+) error {
+	const op = "WriteArchive"
+
+	var a archive
+	switch format {
+	case ArchiveZip:
+		a = &zipArchive{zip: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		a = &tarArchive{gzip: gz, tar: tar.NewWriter(gz)}
+	default:
+		return logger.Error(s.pkg, op, fmt.Errorf("unknown archive format %q", format))
+	}
+
+	paths = outermost(paths)
+
+	var total int64
+	for _, path := range paths {
+		if _, err := s.Stat(ctx, path); err != nil {
+			return err
+		}
+
+		if progress == nil {
+			continue
+		}
+
+		if !path.IsDirectory {
+			total++
+
+			continue
+		}
+
+		files, err := s.countFiles(ctx, path.CleanPathWithTailingSlash())
+		if err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+
+		total += files
+	}
+
+	var c *counter
+	if progress != nil {
+		c = &counter{progress: progress, total: total}
+		progress(0, total)
+	}
+
+	prefix := s.UserFolderPath(userId) + "/"
+
+	for _, path := range paths {
+		if !path.IsDirectory {
+			if err := s.putObjectInArchive(ctx, a, path.CleanPath, prefix, c); err != nil {
+				return logger.Error(s.pkg, op, err)
+			}
+
+			continue
+		}
+
+		opts := minio.ListObjectsOptions{Prefix: path.CleanPathWithTailingSlash(), Recursive: true}
+
+		for v := range s.s3Client.ListObjects(ctx, s.bucket, opts) {
+			if v.Err != nil {
+				return logger.Error(s.pkg, op, v.Err)
+			}
+
+			// directories are created by paths of files
+			if strings.HasSuffix(v.Key, "/") {
+				continue
+			}
+
+			if err := s.putObjectInArchive(ctx, a, v.Key, prefix, c); err != nil {
+				return logger.Error(s.pkg, op, err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	if err := a.Close(); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// StoreArchive uploads the archive of the format read from the reader. The key must be outside of user folders,
+// so the archive isn't listed to users nor counted in their usage. It returns the size of the archive
+func (s *Service) StoreArchive(ctx context.Context, key, format string, reader io.Reader) (int64, error) {
+	const op = "StoreArchive"
+
+	contentType := "application/zip"
+	if format == ArchiveTarGz {
+		contentType = "application/gzip"
+	}
+
+	// the size is known only when the archive is written
+	opts := minio.PutObjectOptions{ContentType: contentType, PartSize: streamPartSize}
+
+	s.startUpload(key)
+
+	object, err := s.s3Client.PutObject(ctx, s.bucket, key, reader, -1, opts)
+
+	s.finishUpload(key, err != nil && ctx.Err() != nil)
+
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	return object.Size, nil
+}
+
+// putObjectInArchive adds the object to the archive named by its path under the prefix
+func (s *Service) putObjectInArchive(ctx context.Context, a archive, key, prefix string, c *counter) error {
+	obj, err := s.s3Client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer func(obj *minio.Object) {
+		_ = obj.Close()
+	}(obj)
+
+	// the size of the entry must match the content read, the object may have changed since it was listed
+	info, err := obj.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := a.add(strings.TrimPrefix(key, prefix), info, obj); err != nil {
+		return err
+	}
+
+	c.add()
+
+	return nil
+}
+
+// countFiles returns the number of objects with the prefix except directories
+func (s *Service) countFiles(ctx context.Context, prefix string) (int64, error) {
+	var files int64
+
+	for v := range s.s3Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if v.Err != nil {
+			return 0, v.Err
+		}
+
+		if !strings.HasSuffix(v.Key, "/") {
+			files++
+		}
+	}
+
+	return files, nil
+}
+
+// outermost returns the paths without duplicates and paths inside other directories of the list,
+// so every file is written to the archive once
+func outermost(paths []resource.Path) []resource.Path {
+	var data []resource.Path
+
+	for i, path := range paths {
+		duplicate := slices.ContainsFunc(paths[:i], func(p resource.Path) bool {
+			return p.CleanPath == path.CleanPath
+		})
+
+		inside := slices.ContainsFunc(paths, func(p resource.Path) bool {
+			return p.IsDirectory && strings.HasPrefix(path.CleanPath, p.CleanPathWithTailingSlash())
+		})
+
+		if !duplicate && !inside {
+			data = append(data, path)
+		}
+	}
+
+	return data
+}
+
+type zipArchive struct {
+	zip *zip.Writer
+}
+
+func (a *zipArchive) add(name string, info minio.ObjectInfo, reader io.Reader) error {
+	entry, err := a.zip.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: info.LastModified})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(entry, reader)
+
+	return err
+}
+
+func (a *zipArchive) Close() error {
+	return a.zip.Close()
+}
+
+type tarArchive struct {
+	gzip *gzip.Writer
+	tar  *tar.Writer
+}
+
+func (a *tarArchive) add(name string, info minio.ObjectInfo, reader io.Reader) error {
+	err := a.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0o644,
+		Size:     info.Size,
+		ModTime:  info.LastModified,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(a.tar, reader)
+
+	return err
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.tar.Close(); err != nil {
+		return err
+	}
+
+	return a.gzip.Close()
+}
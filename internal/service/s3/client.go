@@ -2,15 +2,23 @@ package s3
 
 import (
 	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/internal/tracing"
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
 	"log"
 )
 
 func NewClient(conf *config.Config) *minio.Client {
+	transport, err := minio.DefaultTransport(conf.S3UseSSL)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	minioClient, err := minio.New(conf.S3Endpoint, &minio.Options{
 		Creds:  credentials.NewStaticV4(conf.S3AccessKey, conf.S3SecretAccess, ""),
 		Secure: conf.S3UseSSL,
+		// span of every call, ended when the response headers are received
+		Transport: tracing.Transport(transport, "s3"),
 	})
 	if err != nil {
 		log.Fatalln(err)
@@ -0,0 +1,388 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/tracing"
+	"github.com/minio/minio-go/v7"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"time"
+)
+
+// Operation names used as metric labels
+const (
+	OperationObject            = "object"
+	OperationStoreObject       = "store_object"
+	OperationStoreFile         = "store_file"
+	OperationStat              = "stat"
+	OperationSetMetadata       = "set_metadata"
+	OperationDelete            = "delete"
+	OperationSearch            = "search"
+	OperationMove              = "move"
+	OperationStoreDirectory    = "store_directory"
+	OperationPaginateDirectory = "paginate_directory"
+	OperationDeleteUserFolder  = "delete_user_folder"
+	OperationUsage             = "usage"
+	OperationUserIds           = "user_ids"
+	OperationWriteArchive      = "write_archive"
+	OperationStoreArchive      = "store_archive"
+
+	OperationListObjects             = "list_objects"
+	OperationGetObject               = "get_object"
+	OperationStatObject              = "stat_object"
+	OperationPutObject               = "put_object"
+	OperationCopyObject              = "copy_object"
+	OperationRemoveObject            = "remove_object"
+	OperationNewMultipartUpload      = "new_multipart_upload"
+	OperationPutObjectPart           = "put_object_part"
+	OperationCompleteMultipartUpload = "complete_multipart_upload"
+	OperationAbortMultipartUpload    = "abort_multipart_upload"
+	OperationListObjectParts         = "list_object_parts"
+	OperationListMultipartUploads    = "list_multipart_uploads"
+)
+
+type Metrics interface {
+	ObserveS3Operation(operation string, duration time.Duration, err error)
+	ObserveArchiveWrite(duration time.Duration)
+}
+
+// Instrumented wraps the service, records latency and errors of its operations and starts their spans.
+// Operations which only log their errors are recorded as successful
+type Instrumented struct {
+	*Service
+	metrics Metrics
+}
+
+func NewInstrumented(service *Service, metrics Metrics) *Instrumented {
+	return &Instrumented{
+		Service: service,
+		metrics: metrics,
+	}
+}
+
+func (i *Instrumented) Object(ctx context.Context, path resource.Path) (*minio.Object, error) {
+	ctx, span, started := i.start(ctx, OperationObject)
+	object, err := i.Service.Object(ctx, path)
+	i.finish(span, OperationObject, started, err)
+
+	return object, err
+}
+
+func (i *Instrumented) StoreObject(
+	ctx context.Context,
+	files []*multipart.FileHeader,
+	paths map[string]string,
+	etags map[string]string,
+	userId int64,
+	path resource.Path,
+) (*[]resource.Response, []string) {
+	ctx, span, started := i.start(ctx, OperationStoreObject)
+	data, conflicts := i.Service.StoreObject(ctx, files, paths, etags, userId, path)
+	i.finish(span, OperationStoreObject, started, nil)
+
+	return data, conflicts
+}
+
+func (i *Instrumented) StoreFile(
+	ctx context.Context,
+	path resource.Path,
+	reader io.Reader,
+	size int64,
+) (minio.UploadInfo, error) {
+	ctx, span, started := i.start(ctx, OperationStoreFile)
+	info, err := i.Service.StoreFile(ctx, path, reader, size)
+	i.finish(span, OperationStoreFile, started, err)
+
+	return info, err
+}
+
+func (i *Instrumented) Stat(ctx context.Context, path resource.Path) (minio.ObjectInfo, error) {
+	ctx, span, started := i.start(ctx, OperationStat)
+	info, err := i.Service.Stat(ctx, path)
+
+	// a missing object is an expected result, not a failure
+	if errors.Is(err, ErrNotFound) {
+		i.finish(span, OperationStat, started, nil)
+	} else {
+		i.finish(span, OperationStat, started, err)
+	}
+
+	return info, err
+}
+
+func (i *Instrumented) SetMetadata(ctx context.Context, path resource.Path, metadata map[string]string) error {
+	ctx, span, started := i.start(ctx, OperationSetMetadata)
+	err := i.Service.SetMetadata(ctx, path, metadata)
+	i.finish(span, OperationSetMetadata, started, err)
+
+	return err
+}
+
+func (i *Instrumented) Delete(ctx context.Context, path resource.Path) error {
+	ctx, span, started := i.start(ctx, OperationDelete)
+	err := i.Service.Delete(ctx, path)
+	i.finish(span, OperationDelete, started, err)
+
+	return err
+}
+
+func (i *Instrumented) DeleteDirectory(ctx context.Context, path resource.Path, progress Progress) error {
+	ctx, span, started := i.start(ctx, OperationDelete)
+	err := i.Service.DeleteDirectory(ctx, path, progress)
+	i.finish(span, OperationDelete, started, err)
+
+	return err
+}
+
+func (i *Instrumented) Search(ctx context.Context, userId int64, query string) *[]resource.Response {
+	ctx, span, started := i.start(ctx, OperationSearch)
+	data := i.Service.Search(ctx, userId, query)
+	i.finish(span, OperationSearch, started, nil)
+
+	return data
+}
+
+func (i *Instrumented) WriteArchive(
+	ctx context.Context,
+	w io.Writer,
+	format string,
+	userId int64,
+	paths []resource.Path,
+	progress Progress,
+) error {
+	ctx, span, started := i.start(ctx, OperationWriteArchive)
+	err := i.Service.WriteArchive(ctx, w, format, userId, paths, progress)
+
+	// a missing path is an expected result, not a failure
+	if errors.Is(err, ErrNotFound) {
+		i.finish(span, OperationWriteArchive, started, nil)
+	} else {
+		i.finish(span, OperationWriteArchive, started, err)
+	}
+
+	if err == nil {
+		i.metrics.ObserveArchiveWrite(time.Since(started))
+	}
+
+	return err
+}
+
+func (i *Instrumented) StoreArchive(ctx context.Context, key, format string, reader io.Reader) (int64, error) {
+	ctx, span, started := i.start(ctx, OperationStoreArchive)
+	size, err := i.Service.StoreArchive(ctx, key, format, reader)
+	i.finish(span, OperationStoreArchive, started, err)
+
+	return size, err
+}
+
+func (i *Instrumented) Move(ctx context.Context, to, from resource.Path) error {
+	ctx, span, started := i.start(ctx, OperationMove)
+	err := i.Service.Move(ctx, to, from)
+	i.finish(span, OperationMove, started, err)
+
+	return err
+}
+
+func (i *Instrumented) MoveDirectory(ctx context.Context, to, from resource.Path, progress Progress) error {
+	ctx, span, started := i.start(ctx, OperationMove)
+	err := i.Service.MoveDirectory(ctx, to, from, progress)
+	i.finish(span, OperationMove, started, err)
+
+	return err
+}
+
+func (i *Instrumented) StoreDirectory(ctx context.Context, path resource.Path) (minio.UploadInfo, error) {
+	ctx, span, started := i.start(ctx, OperationStoreDirectory)
+	info, err := i.Service.StoreDirectory(ctx, path)
+	i.finish(span, OperationStoreDirectory, started, err)
+
+	return info, err
+}
+
+func (i *Instrumented) PaginateDirectory(ctx context.Context, userId int64, path resource.Path) *[]resource.Response {
+	ctx, span, started := i.start(ctx, OperationPaginateDirectory)
+	data := i.Service.PaginateDirectory(ctx, userId, path)
+	i.finish(span, OperationPaginateDirectory, started, nil)
+
+	return data
+}
+
+func (i *Instrumented) DeleteUserFolder(ctx context.Context, userId int64) error {
+	ctx, span, started := i.start(ctx, OperationDeleteUserFolder)
+	err := i.Service.DeleteUserFolder(ctx, userId)
+	i.finish(span, OperationDeleteUserFolder, started, err)
+
+	return err
+}
+
+func (i *Instrumented) Usage(ctx context.Context, userId int64) (int64, int64, error) {
+	ctx, span, started := i.start(ctx, OperationUsage)
+	size, objects, err := i.Service.Usage(ctx, userId)
+	i.finish(span, OperationUsage, started, err)
+
+	return size, objects, err
+}
+
+func (i *Instrumented) UserIds(ctx context.Context) ([]int64, error) {
+	ctx, span, started := i.start(ctx, OperationUserIds)
+	ids, err := i.Service.UserIds(ctx)
+	i.finish(span, OperationUserIds, started, err)
+
+	return ids, err
+}
+
+func (i *Instrumented) ListObjects(
+	ctx context.Context,
+	prefix, delimiter, startAfter, continuationToken string,
+	maxKeys int,
+) (minio.ListBucketV2Result, error) {
+	ctx, span, started := i.start(ctx, OperationListObjects)
+	result, err := i.Service.ListObjects(ctx, prefix, delimiter, startAfter, continuationToken, maxKeys)
+	i.finish(span, OperationListObjects, started, serviceError(err))
+
+	return result, err
+}
+
+func (i *Instrumented) GetObject(
+	ctx context.Context,
+	key string,
+	opts minio.GetObjectOptions,
+) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	ctx, span, started := i.start(ctx, OperationGetObject)
+	reader, info, header, err := i.Service.GetObject(ctx, key, opts)
+	i.finish(span, OperationGetObject, started, serviceError(err))
+
+	return reader, info, header, err
+}
+
+func (i *Instrumented) StatObject(ctx context.Context, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	ctx, span, started := i.start(ctx, OperationStatObject)
+	info, err := i.Service.StatObject(ctx, key, opts)
+	i.finish(span, OperationStatObject, started, serviceError(err))
+
+	return info, err
+}
+
+func (i *Instrumented) PutObject(
+	ctx context.Context,
+	key string,
+	reader io.Reader,
+	size int64,
+	opts minio.PutObjectOptions,
+) (minio.UploadInfo, error) {
+	ctx, span, started := i.start(ctx, OperationPutObject)
+	info, err := i.Service.PutObject(ctx, key, reader, size, opts)
+	i.finish(span, OperationPutObject, started, serviceError(err))
+
+	return info, err
+}
+
+func (i *Instrumented) CopyObject(
+	ctx context.Context,
+	to, from string,
+	metadata map[string]string,
+) (minio.ObjectInfo, error) {
+	ctx, span, started := i.start(ctx, OperationCopyObject)
+	info, err := i.Service.CopyObject(ctx, to, from, metadata)
+	i.finish(span, OperationCopyObject, started, serviceError(err))
+
+	return info, err
+}
+
+func (i *Instrumented) RemoveObject(ctx context.Context, key string) error {
+	ctx, span, started := i.start(ctx, OperationRemoveObject)
+	err := i.Service.RemoveObject(ctx, key)
+	i.finish(span, OperationRemoveObject, started, serviceError(err))
+
+	return err
+}
+
+func (i *Instrumented) NewMultipartUpload(ctx context.Context, key string, opts minio.PutObjectOptions) (string, error) {
+	ctx, span, started := i.start(ctx, OperationNewMultipartUpload)
+	uploadId, err := i.Service.NewMultipartUpload(ctx, key, opts)
+	i.finish(span, OperationNewMultipartUpload, started, serviceError(err))
+
+	return uploadId, err
+}
+
+func (i *Instrumented) PutObjectPart(
+	ctx context.Context,
+	key, uploadId string,
+	partNumber int,
+	reader io.Reader,
+	size int64,
+) (minio.ObjectPart, error) {
+	ctx, span, started := i.start(ctx, OperationPutObjectPart)
+	part, err := i.Service.PutObjectPart(ctx, key, uploadId, partNumber, reader, size)
+	i.finish(span, OperationPutObjectPart, started, serviceError(err))
+
+	return part, err
+}
+
+func (i *Instrumented) CompleteMultipartUpload(
+	ctx context.Context,
+	key, uploadId string,
+	parts []minio.CompletePart,
+) (minio.UploadInfo, error) {
+	ctx, span, started := i.start(ctx, OperationCompleteMultipartUpload)
+	info, err := i.Service.CompleteMultipartUpload(ctx, key, uploadId, parts)
+	i.finish(span, OperationCompleteMultipartUpload, started, serviceError(err))
+
+	return info, err
+}
+
+func (i *Instrumented) AbortMultipartUpload(ctx context.Context, key, uploadId string) error {
+	ctx, span, started := i.start(ctx, OperationAbortMultipartUpload)
+	err := i.Service.AbortMultipartUpload(ctx, key, uploadId)
+	i.finish(span, OperationAbortMultipartUpload, started, serviceError(err))
+
+	return err
+}
+
+func (i *Instrumented) ListObjectParts(
+	ctx context.Context,
+	key, uploadId string,
+	partNumberMarker, maxParts int,
+) (minio.ListObjectPartsResult, error) {
+	ctx, span, started := i.start(ctx, OperationListObjectParts)
+	result, err := i.Service.ListObjectParts(ctx, key, uploadId, partNumberMarker, maxParts)
+	i.finish(span, OperationListObjectParts, started, serviceError(err))
+
+	return result, err
+}
+
+func (i *Instrumented) ListMultipartUploads(
+	ctx context.Context,
+	prefix, keyMarker, uploadIdMarker, delimiter string,
+	maxUploads int,
+) (minio.ListMultipartUploadsResult, error) {
+	ctx, span, started := i.start(ctx, OperationListMultipartUploads)
+	result, err := i.Service.ListMultipartUploads(ctx, prefix, keyMarker, uploadIdMarker, delimiter, maxUploads)
+	i.finish(span, OperationListMultipartUploads, started, serviceError(err))
+
+	return result, err
+}
+
+func (i *Instrumented) start(ctx context.Context, operation string) (context.Context, trace.Span, time.Time) {
+	ctx, span := tracing.Tracer().Start(ctx, "s3."+operation)
+
+	return ctx, span, time.Now()
+}
+
+func (i *Instrumented) finish(span trace.Span, operation string, started time.Time, err error) {
+	i.metrics.ObserveS3Operation(operation, time.Since(started), err)
+	tracing.End(span, err)
+}
+
+// serviceError returns nil for errors caused by the request, they are expected results, not failures
+func serviceError(err error) error {
+	if IsRequestError(err) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,254 @@
+package s3
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/journal"
+	"github.com/minio/minio-go/v7"
+	"io"
+	"net/http"
+)
+
+// RequestError is the error S3 responded with because of the request, e.g. of the missing key or upload.
+// The S3 gateway returns it to the client as is
+type RequestError struct {
+	Code       string
+	Message    string
+	StatusCode int
+}
+
+func (e *RequestError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Operations with the keys of the bucket the S3 gateway passes requests of S3 clients through
+
+// ListObjects lists the keys like ListObjectsV2 of S3, the continuation token is the one of the bucket
+func (s *Service) ListObjects(
+	ctx context.Context,
+	prefix, delimiter, startAfter, continuationToken string,
+	maxKeys int,
+) (minio.ListBucketV2Result, error) {
+	const op = "ListObjects"
+
+	// the low level listing isn't cancelled with the context
+	if err := ctx.Err(); err != nil {
+		return minio.ListBucketV2Result{}, err
+	}
+
+	result, err := s.core().ListObjectsV2(s.bucket, prefix, startAfter, continuationToken, delimiter, maxKeys)
+	if err != nil {
+		return minio.ListBucketV2Result{}, s.requestError(op, err)
+	}
+
+	return result, nil
+}
+
+// GetObject returns the content of the object with the range and the conditions of the options
+func (s *Service) GetObject(
+	ctx context.Context,
+	key string,
+	opts minio.GetObjectOptions,
+) (io.ReadCloser, minio.ObjectInfo, http.Header, error) {
+	const op = "GetObject"
+
+	reader, info, header, err := s.core().GetObject(ctx, s.bucket, key, opts)
+	if err != nil {
+		return nil, minio.ObjectInfo{}, nil, s.requestError(op, err)
+	}
+
+	return reader, info, header, nil
+}
+
+func (s *Service) StatObject(ctx context.Context, key string, opts minio.StatObjectOptions) (minio.ObjectInfo, error) {
+	const op = "StatObject"
+
+	info, err := s.s3Client.StatObject(ctx, s.bucket, key, opts)
+	if err != nil {
+		return minio.ObjectInfo{}, s.requestError(op, err)
+	}
+
+	return info, nil
+}
+
+// PutObject uploads the content of the given size in a single request
+func (s *Service) PutObject(
+	ctx context.Context,
+	key string,
+	reader io.Reader,
+	size int64,
+	opts minio.PutObjectOptions,
+) (minio.UploadInfo, error) {
+	const op = "PutObject"
+
+	_, existed := s.current(ctx, key)
+
+	info, err := s.core().PutObject(ctx, s.bucket, key, reader, size, "", "", opts)
+	if err != nil {
+		return minio.UploadInfo{}, s.requestError(op, err)
+	}
+
+	s.record(ctx, written(existed), key, "", info.ETag, info.Size)
+
+	return info, nil
+}
+
+// CopyObject copies the object on the server side. The metadata are the headers of the copy,
+// with "x-amz-metadata-directive: REPLACE" they replace the metadata of the source
+func (s *Service) CopyObject(ctx context.Context, to, from string, metadata map[string]string) (minio.ObjectInfo, error) {
+	const op = "CopyObject"
+
+	_, existed := s.current(ctx, to)
+
+	info, err := s.core().CopyObject(
+		ctx,
+		s.bucket,
+		from,
+		s.bucket,
+		to,
+		metadata,
+		minio.CopySrcOptions{},
+		minio.PutObjectOptions{},
+	)
+	if err != nil {
+		return minio.ObjectInfo{}, s.requestError(op, err)
+	}
+
+	// the copy result has no size
+	stored, _ := s.current(ctx, to)
+
+	s.record(ctx, written(existed), to, "", info.ETag, stored.Size)
+
+	return info, nil
+}
+
+// RemoveObject removes the object, removing the missing object succeeds
+func (s *Service) RemoveObject(ctx context.Context, key string) error {
+	const op = "RemoveObject"
+
+	_, existed := s.current(ctx, key)
+
+	err := s.s3Client.RemoveObject(ctx, s.bucket, key, *s.removeOptions())
+	if err != nil {
+		return s.requestError(op, err)
+	}
+
+	if existed {
+		s.record(ctx, journal.ActionDelete, key, "", "", 0)
+	}
+
+	return nil
+}
+
+func (s *Service) NewMultipartUpload(ctx context.Context, key string, opts minio.PutObjectOptions) (string, error) {
+	const op = "NewMultipartUpload"
+
+	uploadId, err := s.core().NewMultipartUpload(ctx, s.bucket, key, opts)
+	if err != nil {
+		return "", s.requestError(op, err)
+	}
+
+	return uploadId, nil
+}
+
+func (s *Service) PutObjectPart(
+	ctx context.Context,
+	key, uploadId string,
+	partNumber int,
+	reader io.Reader,
+	size int64,
+) (minio.ObjectPart, error) {
+	const op = "PutObjectPart"
+
+	part, err := s.core().PutObjectPart(ctx, s.bucket, key, uploadId, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return minio.ObjectPart{}, s.requestError(op, err)
+	}
+
+	return part, nil
+}
+
+func (s *Service) CompleteMultipartUpload(
+	ctx context.Context,
+	key, uploadId string,
+	parts []minio.CompletePart,
+) (minio.UploadInfo, error) {
+	const op = "CompleteMultipartUpload"
+
+	_, existed := s.current(ctx, key)
+
+	info, err := s.core().CompleteMultipartUpload(ctx, s.bucket, key, uploadId, parts, minio.PutObjectOptions{})
+	if err != nil {
+		return minio.UploadInfo{}, s.requestError(op, err)
+	}
+
+	// the size of the assembled object is known to S3 only
+	stored, _ := s.current(ctx, key)
+
+	s.record(ctx, written(existed), key, "", info.ETag, stored.Size)
+
+	return info, nil
+}
+
+func (s *Service) AbortMultipartUpload(ctx context.Context, key, uploadId string) error {
+	const op = "AbortMultipartUpload"
+
+	err := s.core().AbortMultipartUpload(ctx, s.bucket, key, uploadId)
+	if err != nil {
+		return s.requestError(op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) ListObjectParts(
+	ctx context.Context,
+	key, uploadId string,
+	partNumberMarker, maxParts int,
+) (minio.ListObjectPartsResult, error) {
+	const op = "ListObjectParts"
+
+	result, err := s.core().ListObjectParts(ctx, s.bucket, key, uploadId, partNumberMarker, maxParts)
+	if err != nil {
+		return minio.ListObjectPartsResult{}, s.requestError(op, err)
+	}
+
+	return result, nil
+}
+
+func (s *Service) ListMultipartUploads(
+	ctx context.Context,
+	prefix, keyMarker, uploadIdMarker, delimiter string,
+	maxUploads int,
+) (minio.ListMultipartUploadsResult, error) {
+	const op = "ListMultipartUploads"
+
+	result, err := s.core().ListMultipartUploads(ctx, s.bucket, prefix, keyMarker, uploadIdMarker, delimiter, maxUploads)
+	if err != nil {
+		return minio.ListMultipartUploadsResult{}, s.requestError(op, err)
+	}
+
+	return result, nil
+}
+
+func (s *Service) core() *minio.Core {
+	return &minio.Core{Client: s.s3Client}
+}
+
+// requestError returns errors of the request as RequestError, other errors are the errors of the service
+func (s *Service) requestError(op string, err error) error {
+	resp := minio.ToErrorResponse(err)
+	if resp.StatusCode >= http.StatusMultipleChoices && resp.StatusCode < http.StatusInternalServerError {
+		return &RequestError{Code: resp.Code, Message: resp.Message, StatusCode: resp.StatusCode}
+	}
+
+	return logger.Error(s.pkg, op, err)
+}
+
+// IsRequestError reports whether the error is caused by the request, not by the service
+func IsRequestError(err error) bool {
+	var requestError *RequestError
+
+	return errors.As(err, &requestError)
+}
@@ -1,31 +1,73 @@
 package s3
 
 import (
-	"archive/zip"
-	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/journal"
 	"github.com/minio/minio-go/v7"
 	"io"
+	"mime"
 	"mime/multipart"
 	"path/filepath"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
+var (
+	// ErrNotFound is returned if there is no object with the path
+	ErrNotFound = errors.New("object not found")
+	// ErrPreconditionFailed is returned if the object doesn't have the expected etag
+	ErrPreconditionFailed = errors.New("object etag doesn't match")
+)
+
+// streamPartSize is the part of uploads of unknown size, e.g. streamed over SFTP. Objects up to 10000 parts are allowed
+const streamPartSize = 16 << 20
+
+// writeCheckInterval is how long the result of writing the readiness object is reused. Probes are
+// unauthenticated, so they must not write to the bucket on every request
+const writeCheckInterval = 30 * time.Second
+
+// Progress is told how many objects of the operation on the directory are processed of the total
+type Progress func(done, total int64)
+
 type Service struct {
 	pkg      string
 	bucket   string
 	s3Client *minio.Client
+	journal  Journal
+	mu       sync.Mutex
+	uploads  map[string]int // keys of the objects being uploaded by this instance
+	// the readiness object of this instance, replicas don't write the same key
+	readinessKey string
+	checkMu      sync.Mutex
+	checkedAt    time.Time
+	checkErr     error
 }
 
-func NewService(s3Client *minio.Client, bucket string) *Service {
+// Journal records changes of objects in user folders, every mutating operation writes them
+type Journal interface {
+	Record(ctx context.Context, userId int64, change journal.Change)
+}
+
+func NewService(s3Client *minio.Client, bucket string, journalService Journal) *Service {
+	instance := make([]byte, 8)
+	_, _ = rand.Read(instance)
+
 	return &Service{
 		pkg:      "s3_service",
 		bucket:   bucket,
 		s3Client: s3Client,
+		journal:  journalService,
+		uploads:  map[string]int{},
+		// the key doesn't match user folders, so it's never listed to users
+		readinessKey: ".readiness-check/" + hex.EncodeToString(instance),
 	}
 }
 
@@ -45,23 +87,165 @@ func (s *Service) Object(ctx context.Context, path resource.Path) (*minio.Object
 	return object, nil
 }
 
+// StoreObject uploads the files under the path. The file with the etag in etags overwrites only the object
+// with this etag, the empty etag means the object must not exist. If any of the objects doesn't match,
+// nothing is uploaded and their paths are returned. The object changed by another client during the upload
+// is kept, its path is returned too
 func (s *Service) StoreObject(
 	ctx context.Context,
 	files []*multipart.FileHeader,
 	paths map[string]string,
+	etags map[string]string,
 	userId int64,
 	path resource.Path,
-) *[]resource.Response {
+) (*[]resource.Response, []string) {
 	prefix := s.UserFolderPath(userId)
 
-	opts := minio.PutObjectOptions{}
 	data := []resource.Response{}
 
+	var conflicts []string
+
 	for _, fileHeader := range files {
-		s.uploadFile(ctx, &data, fileHeader, path, prefix, paths, opts)
+		etag, ok := etags[fileHeader.Filename]
+		if !ok {
+			continue
+		}
+
+		key := filepath.Join(path.CleanPath, paths[fileHeader.Filename])
+		if !s.matches(ctx, key, etag) {
+			conflicts = append(conflicts, s.PathToObjectWithoutPrefix(key, prefix))
+		}
 	}
 
-	return &data
+	if len(conflicts) > 0 {
+		return &data, conflicts
+	}
+
+	for _, fileHeader := range files {
+		etag, conditional := etags[fileHeader.Filename]
+
+		err := s.uploadFile(ctx, &data, fileHeader, path, prefix, paths, etag, conditional)
+		if errors.Is(err, ErrPreconditionFailed) {
+			key := filepath.Join(path.CleanPath, paths[fileHeader.Filename])
+			conflicts = append(conflicts, s.PathToObjectWithoutPrefix(key, prefix))
+		}
+	}
+
+	return &data, conflicts
+}
+
+// StoreFile uploads the object of the given size read from the reader, overwriting the object with the same path.
+// The size is -1 if it's unknown until the reader is read to the end
+func (s *Service) StoreFile(
+	ctx context.Context,
+	path resource.Path,
+	reader io.Reader,
+	size int64,
+) (minio.UploadInfo, error) {
+	const op = "StoreFile"
+
+	opts := minio.PutObjectOptions{ContentType: mime.TypeByExtension(filepath.Ext(path.CleanPath))}
+
+	// the content of unknown size is buffered by parts, the default part is too big to keep in memory
+	if size < 0 {
+		opts.PartSize = streamPartSize
+	}
+
+	_, existed := s.current(ctx, path.CleanPath)
+
+	s.startUpload(path.CleanPath)
+
+	object, err := s.s3Client.PutObject(ctx, s.bucket, path.CleanPath, reader, size, opts)
+
+	s.finishUpload(path.CleanPath, err != nil && ctx.Err() != nil)
+
+	if err != nil {
+		return minio.UploadInfo{}, logger.Error(s.pkg, op, err)
+	}
+
+	s.record(ctx, written(existed), object.Key, "", object.ETag, object.Size)
+
+	return object, nil
+}
+
+// Stat returns the info of the object. A directory exists if it has the object of its own or objects inside,
+// the info of the directory without the object of its own has only the key
+func (s *Service) Stat(ctx context.Context, path resource.Path) (minio.ObjectInfo, error) {
+	const op = "Stat"
+
+	key := path.CleanPath
+	if path.IsDirectory {
+		key = path.CleanPathWithTailingSlash()
+	}
+
+	info, err := s.s3Client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err == nil {
+		return info, nil
+	}
+
+	if minio.ToErrorResponse(err).Code != "NoSuchKey" {
+		return minio.ObjectInfo{}, logger.Error(s.pkg, op, err)
+	}
+
+	if !path.IsDirectory {
+		return minio.ObjectInfo{}, ErrNotFound
+	}
+
+	// the listing is stopped after the first object
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for v := range s.s3Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: key, MaxKeys: 1}) {
+		if v.Err != nil {
+			return minio.ObjectInfo{}, logger.Error(s.pkg, op, v.Err)
+		}
+
+		return minio.ObjectInfo{Key: key}, nil
+	}
+
+	return minio.ObjectInfo{}, ErrNotFound
+}
+
+// SetMetadata replaces the user metadata of the object, the content type is kept
+func (s *Service) SetMetadata(ctx context.Context, path resource.Path, metadata map[string]string) error {
+	const op = "SetMetadata"
+
+	key := path.CleanPath
+	if path.IsDirectory {
+		key = path.CleanPathWithTailingSlash()
+	}
+
+	info, err := s.s3Client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	userMetadata := map[string]string{"Content-Type": info.ContentType}
+	for k, v := range metadata {
+		userMetadata[k] = v
+	}
+
+	// the object is copied onto itself, S3 doesn't change metadata in place
+	object, err := s.s3Client.CopyObject(
+		ctx,
+		minio.CopyDestOptions{
+			Bucket:          s.bucket,
+			Object:          key,
+			UserMetadata:    userMetadata,
+			ReplaceMetadata: true,
+		},
+		minio.CopySrcOptions{
+			Bucket: s.bucket,
+			Object: key,
+		},
+	)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	s.record(ctx, journal.ActionUpdate, key, "", object.ETag, info.Size)
+
+	return nil
 }
 
 func (s *Service) Delete(ctx context.Context, path resource.Path) error {
@@ -69,16 +253,20 @@ func (s *Service) Delete(ctx context.Context, path resource.Path) error {
 
 	// if the resource is a directory - remove all data inside
 	if path.IsDirectory {
-		s.deleteRecursive(ctx, path.CleanPathWithTailingSlash())
-
-		return nil
+		return s.DeleteDirectory(ctx, path, nil)
 	}
 
+	_, existed := s.current(ctx, path.CleanPath)
+
 	err := s.deleteObject(ctx, path.CleanPath, s.removeOptions())
 	if err != nil {
 		return logger.Error(s.pkg, op, err)
 	}
 
+	if existed {
+		s.record(ctx, journal.ActionDelete, path.CleanPath, "", "", 0)
+	}
+
 	return nil
 }
 
@@ -108,6 +296,7 @@ func (s *Service) Search(ctx context.Context, userId int64, query string) *[]res
 				Name: filepath.Base(v.Key),
 				Size: v.Size,
 				Type: s.ObjectType(v.Key),
+				ETag: v.ETag,
 			})
 		}
 	}
@@ -115,49 +304,17 @@ func (s *Service) Search(ctx context.Context, userId int64, query string) *[]res
 	return &data
 }
 
-func (s *Service) MakeZip(ctx context.Context, path resource.Path) (*bytes.Buffer, error) {
-	const op = "MakeZip"
-
-	buf := new(bytes.Buffer)
-	zipWriter := zip.NewWriter(buf)
-
-	opts := minio.ListObjectsOptions{
-		Prefix:    path.CleanPathWithTailingSlash(),
-		Recursive: true,
-	}
-
-	for v := range s.s3Client.ListObjects(ctx, s.bucket, opts) {
-		err := s.putObjectInZip(ctx, v, zipWriter, path.CleanPath)
-		if err != nil {
-			return nil, logger.Error(s.pkg, op, err)
-		}
-	}
-
-	if err := zipWriter.Close(); err != nil {
-		return nil, logger.Error(s.pkg, op, err)
-	}
-
-	return buf, nil
-}
-
 func (s *Service) Move(ctx context.Context, to, from resource.Path) error {
 	const op = "Move"
 
 	// if "from" is a directory, move all items inside "to"
 	if from.IsDirectory {
-		fromPath := from.CleanPathWithTailingSlash() // user-N-files/from/
-
-		err := s.copyRecursive(ctx, to.CleanPathWithTailingSlash(), fromPath)
-		if err != nil {
-			return logger.Error(s.pkg, op, err)
-		}
-
-		s.deleteRecursive(ctx, fromPath)
-
-		return nil
+		return s.MoveDirectory(ctx, to, from, nil)
 	}
 
-	_, err := s.s3Client.CopyObject(
+	info, _ := s.current(ctx, from.CleanPath)
+
+	object, err := s.s3Client.CopyObject(
 		ctx,
 		minio.CopyDestOptions{
 			Bucket: s.bucket,
@@ -177,12 +334,59 @@ func (s *Service) Move(ctx context.Context, to, from resource.Path) error {
 		return logger.Error(s.pkg, op, err)
 	}
 
+	s.record(ctx, journal.ActionMove, object.Key, from.CleanPath, object.ETag, info.Size)
+
+	return nil
+}
+
+// DeleteDirectory removes the directory with all objects inside, the progress counts removed objects.
+// It stops when the context is done, objects removed by then stay removed
+func (s *Service) DeleteDirectory(ctx context.Context, path resource.Path, progress Progress) error {
+	const op = "DeleteDirectory"
+
+	prefix := path.CleanPathWithTailingSlash()
+
+	c, err := s.counter(ctx, progress, prefix, 1)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if err := s.deleteRecursive(ctx, prefix, true, c); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// MoveDirectory copies objects of the directory "from" into "to" and then removes them, the progress counts
+// copied and removed objects. It stops when the context is done, "from" is kept whole until all objects are copied
+func (s *Service) MoveDirectory(ctx context.Context, to, from resource.Path, progress Progress) error {
+	const op = "MoveDirectory"
+
+	fromPath := from.CleanPathWithTailingSlash() // user-N-files/from/
+
+	c, err := s.counter(ctx, progress, fromPath, 2)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if err := s.copyRecursive(ctx, to.CleanPathWithTailingSlash(), fromPath, c); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	// the objects are recorded as moved, not deleted
+	if err := s.deleteRecursive(ctx, fromPath, false, c); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
 	return nil
 }
 
 func (s *Service) StoreDirectory(ctx context.Context, path resource.Path) (minio.UploadInfo, error) {
 	const op = "StoreDirectory"
 
+	_, existed := s.current(ctx, path.CleanPathWithTailingSlash())
+
 	object, err := s.s3Client.PutObject(
 		ctx,
 		s.bucket,
@@ -195,6 +399,10 @@ func (s *Service) StoreDirectory(ctx context.Context, path resource.Path) (minio
 		return minio.UploadInfo{}, logger.Error(s.pkg, op, err)
 	}
 
+	if !existed {
+		s.record(ctx, journal.ActionCreate, object.Key, "", "", 0)
+	}
+
 	return object, nil
 }
 
@@ -223,6 +431,7 @@ func (s *Service) PaginateDirectory(ctx context.Context, userId int64, path reso
 			Name: filepath.Base(v.Key),
 			Size: v.Size,
 			Type: s.ObjectType(v.Key),
+			ETag: v.ETag,
 		})
 	}
 
@@ -231,6 +440,147 @@ func (s *Service) PaginateDirectory(ctx context.Context, userId int64, path reso
 	return &data
 }
 
+// DeleteUserFolder removes all objects of the user and archives exported by the user,
+// the journal of the deleted user isn't written
+func (s *Service) DeleteUserFolder(ctx context.Context, userId int64) error {
+	const op = "DeleteUserFolder"
+
+	if err := s.deleteRecursive(ctx, fmt.Sprintf("%s/", s.UserFolderPath(userId)), false, nil); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if err := s.deleteRecursive(ctx, fmt.Sprintf("%s/", s.ExportFolderPath(userId)), false, nil); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Usage returns the total size and the number of objects of the user
+func (s *Service) Usage(ctx context.Context, userId int64) (int64, int64, error) {
+	const op = "Usage"
+
+	var size, objects int64
+
+	opts := minio.ListObjectsOptions{
+		Prefix:    fmt.Sprintf("%s/", s.UserFolderPath(userId)),
+		Recursive: true,
+	}
+
+	for v := range s.s3Client.ListObjects(ctx, s.bucket, opts) {
+		if v.Err != nil {
+			return 0, 0, logger.Error(s.pkg, op, v.Err)
+		}
+
+		size += v.Size
+		objects++
+	}
+
+	return size, objects, nil
+}
+
+// UserIds returns ids of users having a root folder in the bucket
+func (s *Service) UserIds(ctx context.Context) ([]int64, error) {
+	const op = "UserIds"
+
+	var ids []int64
+
+	for v := range s.s3Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{}) {
+		if v.Err != nil {
+			return nil, logger.Error(s.pkg, op, v.Err)
+		}
+
+		var userId int64
+		if _, err := fmt.Sscanf(v.Key, "user-%d-files/", &userId); err != nil {
+			continue
+		}
+
+		if v.Key == fmt.Sprintf("%s/", s.UserFolderPath(userId)) {
+			ids = append(ids, userId)
+		}
+	}
+
+	return ids, nil
+}
+
+// AbortUploads removes parts of the multipart uploads started by this instance which aren't finished,
+// called on shutdown after the requests still uploading are cancelled
+func (s *Service) AbortUploads(ctx context.Context) error {
+	const op = "AbortUploads"
+
+	s.mu.Lock()
+	keys := make([]string, 0, len(s.uploads))
+	for key := range s.uploads {
+		keys = append(keys, key)
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, key := range keys {
+		if err := s.s3Client.RemoveIncompleteUpload(ctx, s.bucket, key); err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		s.mu.Lock()
+		delete(s.uploads, key)
+		s.mu.Unlock()
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// CheckBucket returns an error if the bucket doesn't exist or an object can't be written to it. The object is
+// written at most once per writeCheckInterval, the result is reused until then
+func (s *Service) CheckBucket(ctx context.Context) error {
+	const op = "CheckBucket"
+
+	exists, err := s.s3Client.BucketExists(ctx, s.bucket)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if !exists {
+		return logger.Error(s.pkg, op, fmt.Errorf("bucket %q doesn't exist", s.bucket))
+	}
+
+	// concurrent probes wait for one write instead of writing too
+	s.checkMu.Lock()
+	defer s.checkMu.Unlock()
+
+	if time.Since(s.checkedAt) < writeCheckInterval {
+		return s.checkErr
+	}
+
+	if err := s.checkWrite(ctx); err != nil {
+		// the probe timed out or was cancelled, the next one checks again
+		if ctx.Err() == nil {
+			s.checkErr, s.checkedAt = err, time.Now()
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	s.checkErr, s.checkedAt = nil, time.Now()
+
+	return nil
+}
+
+// checkWrite writes and removes the readiness object of this instance
+func (s *Service) checkWrite(ctx context.Context) error {
+	_, err := s.s3Client.PutObject(ctx, s.bucket, s.readinessKey, strings.NewReader("ok"), 2, minio.PutObjectOptions{})
+	if err != nil {
+		return err
+	}
+
+	return s.s3Client.RemoveObject(ctx, s.bucket, s.readinessKey, minio.RemoveObjectOptions{})
+}
+
 // AbsPathToObject returns the path to the object with the suffix: "user-USER_ID-files/"
 func (s *Service) AbsPathToObject(userId int64, path string) string {
 	return filepath.Join(s.UserFolderPath(userId), path)
@@ -241,6 +591,11 @@ func (s *Service) UserFolderPath(userId int64) string {
 	return fmt.Sprintf("user-%d-files", userId)
 }
 
+// ExportFolderPath returns the folder of archives exported by the user, it's outside of the user's root folder
+func (s *Service) ExportFolderPath(userId int64) string {
+	return fmt.Sprintf("exports/user-%d", userId)
+}
+
 // PathToObjectWithoutPrefix returns the path without the prefix: "user-USER_ID-files"
 func (s *Service) PathToObjectWithoutPrefix(path, prefix string) string {
 	pathToFile, _ := strings.CutPrefix(path, prefix)
@@ -257,6 +612,8 @@ func (s *Service) ObjectType(filePath string) string {
 	return "FILE"
 }
 
+// uploadFile uploads the file and adds it to the data. Only ErrPreconditionFailed is returned,
+// other errors are logged and the file is skipped
 func (s *Service) uploadFile(
 	ctx context.Context,
 	data *[]resource.Response,
@@ -264,15 +621,16 @@ func (s *Service) uploadFile(
 	path resource.Path,
 	prefix string,
 	paths map[string]string,
-	opts minio.PutObjectOptions,
-) {
+	etag string,
+	conditional bool,
+) error {
 	const op = "uploadFile"
 
 	fileData, err := file.Open()
 	if err != nil {
 		logger.Add(s.pkg, op, err)
 
-		return
+		return nil
 	}
 	defer func(fileData multipart.File) {
 		err := fileData.Close()
@@ -281,29 +639,79 @@ func (s *Service) uploadFile(
 		}
 	}(fileData)
 
+	key := filepath.Join(path.CleanPath, paths[file.Filename])
+
+	// S3 checks the etag again, so the object changed after the check isn't overwritten
+	opts := minio.PutObjectOptions{}
+	if conditional && etag == "" {
+		opts.SetMatchETagExcept("*")
+	} else if conditional {
+		opts.SetMatchETag(etag)
+	}
+
+	_, existed := s.current(ctx, key)
+
+	s.startUpload(key)
+
 	object, err := s.s3Client.PutObject(
 		ctx,
 		s.bucket,
-		filepath.Join(path.CleanPath, paths[file.Filename]),
+		key,
 		fileData,
 		file.Size,
 		opts,
 	)
+
+	// parts of an upload interrupted by the cancelled request stay in the bucket until AbortUploads
+	s.finishUpload(key, err != nil && ctx.Err() != nil)
+
 	if err != nil {
+		if minio.ToErrorResponse(err).Code == "PreconditionFailed" {
+			return ErrPreconditionFailed
+		}
+
 		logger.Add(s.pkg, op, err)
 
-		return
+		return nil
 	}
 
+	s.record(ctx, written(existed), object.Key, "", object.ETag, object.Size)
+
 	*data = append(*data, resource.Response{
 		Path: s.PathToObjectWithoutPrefix(object.Key, prefix),
 		Name: filepath.Base(object.Key),
 		Size: object.Size,
 		Type: s.ObjectType(object.Key),
+		ETag: object.ETag,
 	})
+
+	return nil
+}
+
+func (s *Service) startUpload(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uploads[key]++
+}
+
+func (s *Service) finishUpload(key string, interrupted bool) {
+	if interrupted {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.uploads[key]--
+	if s.uploads[key] <= 0 {
+		delete(s.uploads, key)
+	}
 }
 
-func (s *Service) deleteRecursive(ctx context.Context, path string) {
+// deleteRecursive removes objects with the prefix, their deletion is written to the journal if record is true.
+// Objects which can't be removed are skipped, it stops only when the context is done
+func (s *Service) deleteRecursive(ctx context.Context, path string, record bool, c *counter) error {
 	const op = "deleteRecursive"
 
 	opts := minio.ListObjectsOptions{
@@ -314,6 +722,10 @@ func (s *Service) deleteRecursive(ctx context.Context, path string) {
 	removeOpts := s.removeOptions()
 
 	for object := range s.s3Client.ListObjects(ctx, s.bucket, opts) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
 		if object.Err != nil {
 			logger.Add(s.pkg, op, object.Err)
 
@@ -322,44 +734,19 @@ func (s *Service) deleteRecursive(ctx context.Context, path string) {
 
 		err := s.deleteObject(ctx, object.Key, removeOpts)
 		if err != nil {
-			logger.Add(s.pkg, op, object.Err)
+			logger.Add(s.pkg, op, err)
 
 			continue
 		}
-	}
-}
-
-func (s *Service) putObjectInZip(ctx context.Context, v minio.ObjectInfo, zipWriter *zip.Writer, prefix string) error {
-	const op = "putObjectInZip"
-
-	if v.Err != nil {
-		return logger.Error(s.pkg, op, v.Err)
-	}
-
-	// skip directory
-	if strings.HasSuffix(v.Key, "/") {
-		return nil
-	}
-
-	entry, err := zipWriter.Create(s.PathToObjectWithoutPrefix(v.Key, prefix))
-	if err != nil {
-		return logger.Error(s.pkg, op, v.Err)
-	}
-
-	obj, err := s.s3Client.GetObject(ctx, s.bucket, v.Key, minio.GetObjectOptions{})
-	if err != nil {
-		return logger.Error(s.pkg, op, v.Err)
-	}
 
-	if _, err := io.Copy(entry, obj); err != nil {
-		return logger.Error(s.pkg, op, v.Err)
-	}
+		if record {
+			s.record(ctx, journal.ActionDelete, object.Key, "", "", 0)
+		}
 
-	if err := obj.Close(); err != nil {
-		return logger.Error(s.pkg, op, v.Err)
+		c.add()
 	}
 
-	return nil
+	return ctx.Err()
 }
 
 func (s *Service) sortObjectsList(data *[]resource.Response) {
@@ -376,13 +763,12 @@ func (s *Service) sortObjectsList(data *[]resource.Response) {
 	})
 }
 
-func (s *Service) copyRecursive(ctx context.Context, to, from string) error {
+func (s *Service) copyRecursive(ctx context.Context, to, from string, c *counter) error {
 	const op = "copyRecursive"
 
 	opts := minio.ListObjectsOptions{
-		Prefix:     from,
-		StartAfter: from,
-		Recursive:  true,
+		Prefix:    from,
+		Recursive: true,
 	}
 
 	isChanged := false
@@ -393,12 +779,9 @@ func (s *Service) copyRecursive(ctx context.Context, to, from string) error {
 		}
 
 		isChanged = true
-		copyTo := filepath.Join(to, filepath.Base(v.Key))
 
-		// if is a directory, copy resource as directory
-		if strings.HasSuffix(v.Key, "/") {
-			copyTo = fmt.Sprintf("%s/", copyTo)
-		}
+		// objects keep their path relative to the directory, so nested directories aren't flattened
+		copyTo := to + strings.TrimPrefix(v.Key, from)
 
 		_, err := s.s3Client.CopyObject(
 			ctx,
@@ -414,6 +797,9 @@ func (s *Service) copyRecursive(ctx context.Context, to, from string) error {
 		if err != nil {
 			return logger.Error(s.pkg, op, err)
 		}
+
+		s.record(ctx, journal.ActionMove, copyTo, v.Key, v.ETag, v.Size)
+		c.add()
 	}
 
 	// if trying to rename empty folder
@@ -432,6 +818,8 @@ func (s *Service) copyRecursive(ctx context.Context, to, from string) error {
 		if err != nil {
 			return logger.Error(s.pkg, op, err)
 		}
+
+		s.record(ctx, journal.ActionMove, to, from, "", 0)
 	}
 
 	return nil
@@ -448,9 +836,108 @@ func (s *Service) deleteObject(ctx context.Context, path string, removeOpts *min
 	return nil
 }
 
+// counter counts processed objects of the operation for the progress
+type counter struct {
+	progress Progress
+	done     int64
+	total    int64
+}
+
+// counter returns the counter of the operation processing objects with the prefix steps times each,
+// nil if the progress isn't followed
+func (s *Service) counter(ctx context.Context, progress Progress, prefix string, steps int64) (*counter, error) {
+	if progress == nil {
+		return nil, nil
+	}
+
+	var objects int64
+
+	for v := range s.s3Client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if v.Err != nil {
+			return nil, v.Err
+		}
+
+		objects++
+	}
+
+	c := &counter{progress: progress, total: objects * steps}
+	progress(0, c.total)
+
+	return c, nil
+}
+
+func (c *counter) add() {
+	if c == nil {
+		return
+	}
+
+	c.done++
+	c.progress(c.done, c.total)
+}
+
 func (s *Service) removeOptions() *minio.RemoveObjectOptions {
 	return &minio.RemoveObjectOptions{
 		ForceDelete:      true,
 		GovernanceBypass: true,
 	}
 }
+
+// current returns the info of the object and whether it exists
+func (s *Service) current(ctx context.Context, key string) (minio.ObjectInfo, bool) {
+	info, err := s.s3Client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return minio.ObjectInfo{}, false
+	}
+
+	return info, true
+}
+
+// matches reports whether the object has the etag, the empty etag matches the missing object
+func (s *Service) matches(ctx context.Context, key, etag string) bool {
+	info, exists := s.current(ctx, key)
+	if etag == "" {
+		return !exists
+	}
+
+	return exists && info.ETag == etag
+}
+
+// record writes the change of the object to the journal of its user, objects outside of user folders
+// aren't recorded. Paths of the journal are relative to the user folder
+func (s *Service) record(ctx context.Context, action, key, fromKey, etag string, size int64) {
+	userId, path, ok := s.userPath(key)
+	if !ok {
+		return
+	}
+
+	change := journal.Change{Action: action, Path: path, ETag: etag, Size: size}
+	if fromKey != "" {
+		_, change.FromPath, _ = s.userPath(fromKey)
+	}
+
+	s.journal.Record(ctx, userId, change)
+}
+
+// userPath returns the user and the path of the key in the user folder
+func (s *Service) userPath(key string) (int64, string, bool) {
+	var userId int64
+	if _, err := fmt.Sscanf(key, "user-%d-files/", &userId); err != nil {
+		return 0, "", false
+	}
+
+	path := strings.TrimPrefix(key, s.UserFolderPath(userId))
+	if path == "/" {
+		return 0, "", false
+	}
+
+	return userId, path, true
+}
+
+// written returns the action of writing the object which has existed before or not
+func written(existed bool) string {
+	if existed {
+		return journal.ActionUpdate
+	}
+
+	return journal.ActionCreate
+}
@@ -0,0 +1,12 @@
+package s3key
+
+// AccessKeyIdPrefix marks access key ids of the S3 gateway
+const AccessKeyIdPrefix = "CFS"
+
+// Key is a validated key of the S3 gateway with the secret access key requests are signed with
+type Key struct {
+	Id              int64
+	UserId          int64
+	AccessKeyId     string
+	SecretAccessKey string
+}
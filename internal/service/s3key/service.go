@@ -0,0 +1,203 @@
+package s3key
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/s3key"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid      = errors.New("s3 key invalid")
+	ErrNotFound     = errors.New("s3 key not found")
+	ErrNameRequired = errors.New("s3 key name required")
+)
+
+// lastUsedInterval is how often the last use of the key is stored, the gateway verifies keys on every request
+const lastUsedInterval = time.Minute
+
+type Config struct {
+	Secret string // encrypts secret access keys stored in the database
+}
+
+type Service struct {
+	pkg       string
+	s3KeyRepo Repository
+	aead      cipher.AEAD
+}
+
+type Repository interface {
+	Create(key s3key.S3Key) (s3key.S3Key, error)
+	ByAccessKeyId(accessKeyId string) (s3key.S3Key, error)
+	ByUserId(userId int64) ([]s3key.S3Key, error)
+	Delete(userId, keyId int64) error
+	UpdateLastUsed(keyId int64) error
+}
+
+func NewService(conf *Config, s3KeyRepo Repository) *Service {
+	sum := sha256.Sum256([]byte(conf.Secret))
+
+	// AES-256 accepts the key of 32 bytes and GCM the standard nonce, neither returns an error then
+	block, _ := aes.NewCipher(sum[:])
+	aead, _ := cipher.NewGCM(block)
+
+	return &Service{
+		pkg:       "s3key.service",
+		s3KeyRepo: s3KeyRepo,
+		aead:      aead,
+	}
+}
+
+// CreateKey creates a new key of the S3 gateway. The secret access key is returned only once,
+// it's stored encrypted, as verifying signatures needs the secret itself
+func (s *Service) CreateKey(userId int64, name string) (string, s3key.S3Key, error) {
+	const op = "CreateKey"
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", s3key.S3Key{}, ErrNameRequired
+	}
+
+	b := make([]byte, 41)
+	if _, err := rand.Read(b); err != nil {
+		return "", s3key.S3Key{}, logger.Error(s.pkg, op, err)
+	}
+
+	// 20 characters like the access key ids of AWS, 40 characters of the secret
+	accessKeyId := AccessKeyIdPrefix + base32.StdEncoding.EncodeToString(b[:11])[:20-len(AccessKeyIdPrefix)]
+	secret := base64.StdEncoding.EncodeToString(b[11:])
+
+	encrypted, err := s.encrypt(accessKeyId, secret)
+	if err != nil {
+		return "", s3key.S3Key{}, logger.Error(s.pkg, op, err)
+	}
+
+	k, err := s.s3KeyRepo.Create(s3key.S3Key{
+		UserId:          userId,
+		Name:            name,
+		AccessKeyId:     accessKeyId,
+		SecretEncrypted: encrypted,
+	})
+	if err != nil {
+		return "", s3key.S3Key{}, logger.Error(s.pkg, op, err)
+	}
+
+	return secret, k, nil
+}
+
+// Keys returns all keys of the S3 gateway of the user
+func (s *Service) Keys(userId int64) ([]s3key.S3Key, error) {
+	const op = "Keys"
+
+	keys, err := s.s3KeyRepo.ByUserId(userId)
+	if err != nil {
+		return nil, logger.Error(s.pkg, op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeKey deletes the key of the user
+func (s *Service) RevokeKey(userId, keyId int64) error {
+	const op = "RevokeKey"
+
+	err := s.s3KeyRepo.Delete(userId, keyId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Key returns the key with the access key id and its secret, keys of disabled users are invalid
+func (s *Service) Key(accessKeyId string) (Key, error) {
+	const op = "Key"
+
+	if !strings.HasPrefix(accessKeyId, AccessKeyIdPrefix) {
+		return Key{}, ErrInvalid
+	}
+
+	k, err := s.s3KeyRepo.ByAccessKeyId(accessKeyId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return Key{}, ErrInvalid
+		}
+
+		return Key{}, logger.Error(s.pkg, op, err)
+	}
+
+	secret, err := s.decrypt(k.AccessKeyId, k.SecretEncrypted)
+	if err != nil {
+		return Key{}, logger.Error(s.pkg, op, err)
+	}
+
+	if s.lastUseOutdated(k) {
+		if err := s.s3KeyRepo.UpdateLastUsed(k.Id); err != nil {
+			logger.Add(s.pkg, op, err)
+		}
+	}
+
+	return Key{
+		Id:              k.Id,
+		UserId:          k.UserId,
+		AccessKeyId:     k.AccessKeyId,
+		SecretAccessKey: secret,
+	}, nil
+}
+
+func (s *Service) lastUseOutdated(k s3key.S3Key) bool {
+	if !k.LastUsedAt.Valid {
+		return true
+	}
+
+	lastUsedAt, err := time.ParseInLocation(time.DateTime, k.LastUsedAt.String, time.Local)
+	if err != nil {
+		return true
+	}
+
+	return time.Since(lastUsedAt) >= lastUsedInterval
+}
+
+// encrypt seals the secret with the access key id as additional data, so it can't be moved to another key
+func (s *Service) encrypt(accessKeyId, secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), []byte(accessKeyId))
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Service) decrypt(accessKeyId, encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+
+	secret, err := s.aead.Open(nil, nonce, ciphertext, []byte(accessKeyId))
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
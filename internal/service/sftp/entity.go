@@ -0,0 +1,77 @@
+package sftp
+
+import (
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+type Config struct {
+	RequireVerifiedEmail bool // uploads are rejected until the user verifies the email
+}
+
+// Session is the authenticated SSH connection the SFTP requests are served for
+type Session struct {
+	UserId        int64
+	AccessToken   accesstoken.Token // zero if the user is authenticated with the password or the SSH key
+	Ip            string
+	ClientVersion string // e.g. SSH-2.0-OpenSSH_9.6, recorded as the user agent
+}
+
+// IsAccessToken reports whether the session is authenticated with a personal access token
+func (s Session) IsAccessToken() bool {
+	return s.AccessToken.Id != 0
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (fi *fileInfo) Name() string {
+	return path.Base(fi.name)
+}
+
+func (fi *fileInfo) Size() int64 {
+	return fi.size
+}
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir | 0755
+	}
+
+	return 0644
+}
+
+func (fi *fileInfo) ModTime() time.Time {
+	return fi.modTime
+}
+
+func (fi *fileInfo) IsDir() bool {
+	return fi.isDir
+}
+
+func (fi *fileInfo) Sys() any {
+	return nil
+}
+
+// listerAt is the listing of the directory or the info of the single file
+type listerAt []os.FileInfo
+
+func (l listerAt) ListAt(entries []os.FileInfo, offset int64) (int, error) {
+	if offset >= int64(len(l)) {
+		return 0, io.EOF
+	}
+
+	n := copy(entries, l[offset:])
+	if n+int(offset) == len(l) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
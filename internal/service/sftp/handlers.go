@@ -0,0 +1,478 @@
+package sftp
+
+import (
+	"context"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	pkgsftp "github.com/pkg/sftp"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+var (
+	errIsDirectory  = errors.New("resource is a directory")
+	errNotDirectory = errors.New("resource is not a directory")
+	errNotEmpty     = errors.New("directory is not empty")
+	errClosing      = errors.New("server is shutting down")
+	errNotVerified  = errors.New("email is not verified")
+)
+
+// listPageSize is the number of keys listed by a request to S3
+const listPageSize = 1000
+
+// handlers serve SFTP requests of the session in the tree of the user's files inside the root folder.
+// Directories are objects with the tailing slash, like the WebDAV tree of the files
+type handlers struct {
+	ctx     context.Context
+	service *Service
+	session Session
+	root    string // path to the root folder in the bucket: "user-USER_ID-files/folder"
+}
+
+// Fileread opens the object for reading, clients read it in chunks with offsets
+func (h *handlers) Fileread(r *pkgsftp.Request) (io.ReaderAt, error) {
+	const op = "Fileread"
+
+	name := cleanName(r.Filepath)
+	if !h.allowed(accesstoken.ScopeRead) {
+		return nil, pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	info, err := h.stat(name)
+	if err == nil && info.isDir {
+		err = errIsDirectory
+	}
+
+	var object io.ReaderAt
+	if err == nil {
+		object, err = h.service.s3Service.Object(h.ctx, h.path(name, false))
+	}
+
+	h.record(audit.ActionResourceDownload, name, "", err)
+	if err != nil {
+		return nil, h.error(op, err)
+	}
+
+	return object, nil
+}
+
+// Filewrite opens the upload of the file, the content is streamed to S3 while it's written
+func (h *handlers) Filewrite(r *pkgsftp.Request) (io.WriterAt, error) {
+	const op = "Filewrite"
+
+	name := cleanName(r.Filepath)
+	if !h.allowed(accesstoken.ScopeWrite) {
+		return nil, pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	// objects can't be appended to
+	if r.Pflags().Append {
+		return nil, pkgsftp.ErrSSHFxOpUnsupported
+	}
+
+	err := h.checkVerified()
+	if err == nil {
+		err = h.checkWrite(name, r.Pflags().Excl)
+	}
+
+	if err == nil {
+		err = h.service.quotaService.Check(h.ctx, h.session.UserId, 0)
+	}
+
+	if err == nil && !h.service.startUpload() {
+		err = errClosing
+	}
+
+	if err != nil {
+		h.record(audit.ActionResourceCreate, name, "", err)
+
+		return nil, h.error(op, err)
+	}
+
+	return newWriter(h, name), nil
+}
+
+func (h *handlers) Filecmd(r *pkgsftp.Request) error {
+	name := cleanName(r.Filepath)
+
+	switch r.Method {
+	case "Setstat":
+		// permissions and times of objects can't be changed, clients set them after uploads
+		if !h.allowed(accesstoken.ScopeWrite) {
+			return pkgsftp.ErrSSHFxPermissionDenied
+		}
+
+		_, err := h.stat(name)
+
+		return h.error("Setstat", err)
+	case "Mkdir":
+		return h.mkdir(name)
+	case "Rename":
+		return h.rename(name, cleanName(r.Target), false)
+	case "Remove":
+		return h.remove(name, false)
+	case "Rmdir":
+		return h.remove(name, true)
+	default:
+		// links aren't supported by S3
+		return pkgsftp.ErrSSHFxOpUnsupported
+	}
+}
+
+// PosixRename renames the file replacing the existing one, Rename fails if the target exists
+func (h *handlers) PosixRename(r *pkgsftp.Request) error {
+	return h.rename(cleanName(r.Filepath), cleanName(r.Target), true)
+}
+
+func (h *handlers) Filelist(r *pkgsftp.Request) (pkgsftp.ListerAt, error) {
+	name := cleanName(r.Filepath)
+	if !h.allowed(accesstoken.ScopeRead) {
+		return nil, pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	switch r.Method {
+	case "List":
+		entries, err := h.list(name)
+		if err != nil {
+			return nil, h.error("List", err)
+		}
+
+		return listerAt(entries), nil
+	case "Stat":
+		info, err := h.stat(name)
+		if err != nil {
+			return nil, h.error("Stat", err)
+		}
+
+		return listerAt{info}, nil
+	default:
+		return nil, pkgsftp.ErrSSHFxOpUnsupported
+	}
+}
+
+func (h *handlers) mkdir(name string) error {
+	const op = "mkdir"
+
+	if !h.allowed(accesstoken.ScopeWrite) {
+		return pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	_, err := h.stat(name)
+	switch {
+	case err == nil:
+		err = os.ErrExist
+	case os.IsNotExist(err):
+		err = h.checkParent(name)
+		if err == nil {
+			_, err = h.service.s3Service.StoreDirectory(h.ctx, h.path(name, true))
+		}
+	}
+
+	h.record(audit.ActionResourceCreate, name, "", err)
+
+	return h.error(op, err)
+}
+
+func (h *handlers) rename(oldName, newName string, replace bool) error {
+	const op = "rename"
+
+	if !h.allowed(accesstoken.ScopeWrite) {
+		return pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	if oldName == "/" || newName == "/" {
+		return pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	// a directory can't be moved inside itself
+	if oldName == newName || strings.HasPrefix(newName, oldName+"/") {
+		return pkgsftp.ErrSSHFxFailure
+	}
+
+	info, err := h.stat(oldName)
+	if err == nil {
+		err = h.checkRenameTarget(newName, info.isDir, replace)
+	}
+
+	if err == nil {
+		err = h.service.s3Service.Move(h.ctx, h.path(newName, info.isDir), h.path(oldName, info.isDir))
+	}
+
+	h.record(audit.ActionResourceMove, oldName, newName, err)
+
+	return h.error(op, err)
+}
+
+// checkRenameTarget checks the parent of the target exists. Only files replace existing files
+func (h *handlers) checkRenameTarget(name string, isDir, replace bool) error {
+	target, err := h.stat(name)
+	switch {
+	case err == nil && (!replace || isDir || target.isDir):
+		return os.ErrExist
+	case err == nil:
+		return nil
+	case os.IsNotExist(err):
+		return h.checkParent(name)
+	default:
+		return err
+	}
+}
+
+func (h *handlers) remove(name string, isDir bool) error {
+	const op = "remove"
+
+	if !h.allowed(accesstoken.ScopeDelete) {
+		return pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	if name == "/" {
+		return pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	info, err := h.stat(name)
+	switch {
+	case err != nil:
+	case isDir && !info.isDir:
+		err = errNotDirectory
+	case !isDir && info.isDir:
+		err = errIsDirectory
+	case isDir:
+		// unlike deleting with the api, removing directories doesn't delete files inside
+		err = h.checkEmpty(name)
+	}
+
+	if err == nil {
+		err = h.service.s3Service.Delete(h.ctx, h.path(name, isDir))
+	}
+
+	h.record(audit.ActionResourceDelete, name, "", err)
+
+	return h.error(op, err)
+}
+
+// checkVerified checks the user can upload files, uploads may require the verified email
+// like uploads over the API, WebDAV and the S3 gateway
+func (h *handlers) checkVerified() error {
+	if !h.service.conf.RequireVerifiedEmail {
+		return nil
+	}
+
+	us, err := h.service.userService.UserById(h.ctx, h.session.UserId)
+	if err != nil {
+		return err
+	}
+
+	if !us.EmailVerifiedAt.Valid {
+		return errNotVerified
+	}
+
+	return nil
+}
+
+// checkWrite checks the file can be stored with the name
+func (h *handlers) checkWrite(name string, exclusive bool) error {
+	info, err := h.stat(name)
+	switch {
+	case err == nil && info.isDir:
+		return errIsDirectory
+	case err == nil && exclusive:
+		return os.ErrExist
+	case err == nil:
+		return nil
+	case os.IsNotExist(err):
+		return h.checkParent(name)
+	default:
+		return err
+	}
+}
+
+func (h *handlers) checkEmpty(name string) error {
+	prefix := h.prefix(name)
+
+	result, err := h.service.s3Service.ListObjects(h.ctx, prefix, "/", prefix, "", 1)
+	if err != nil {
+		return err
+	}
+
+	if len(result.Contents) > 0 || len(result.CommonPrefixes) > 0 {
+		return errNotEmpty
+	}
+
+	return nil
+}
+
+// list returns the files and directories inside the directory, all pages of the listing
+func (h *handlers) list(name string) ([]os.FileInfo, error) {
+	info, err := h.stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.isDir {
+		return nil, errNotDirectory
+	}
+
+	prefix := h.prefix(name)
+	entries := []os.FileInfo{}
+	token := ""
+
+	for {
+		result, err := h.service.s3Service.ListObjects(h.ctx, prefix, "/", prefix, token, listPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range result.CommonPrefixes {
+			entries = append(entries, &fileInfo{name: path.Join(name, path.Base(p.Prefix)), isDir: true})
+		}
+
+		for _, o := range result.Contents {
+			// the object of the directory itself
+			if o.Key == prefix {
+				continue
+			}
+
+			entries = append(entries, &fileInfo{
+				name:    path.Join(name, path.Base(o.Key)),
+				size:    o.Size,
+				modTime: o.LastModified,
+			})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return entries, nil
+		}
+
+		token = result.NextContinuationToken
+	}
+}
+
+func (h *handlers) stat(name string) (*fileInfo, error) {
+	// the root folder always exists, even before anything is stored
+	if name == "/" {
+		return &fileInfo{name: name, isDir: true}, nil
+	}
+
+	object, err := h.service.s3Service.Stat(h.ctx, h.path(name, false))
+	if err == nil {
+		return &fileInfo{name: name, size: object.Size, modTime: object.LastModified}, nil
+	}
+
+	if !errors.Is(err, s3.ErrNotFound) {
+		return nil, err
+	}
+
+	object, err = h.service.s3Service.Stat(h.ctx, h.path(name, true))
+	if err != nil {
+		if errors.Is(err, s3.ErrNotFound) {
+			return nil, os.ErrNotExist
+		}
+
+		return nil, err
+	}
+
+	return &fileInfo{name: name, modTime: object.LastModified, isDir: true}, nil
+}
+
+// checkParent returns os.ErrNotExist if the parent directory of the name doesn't exist
+func (h *handlers) checkParent(name string) error {
+	parent, err := h.stat(path.Dir(name))
+	if err != nil {
+		return err
+	}
+
+	if !parent.isDir {
+		return errNotDirectory
+	}
+
+	return nil
+}
+
+// allowed reports whether the session has the scope, password and key sessions have full access
+func (h *handlers) allowed(scope string) bool {
+	return !h.session.IsAccessToken() || h.session.AccessToken.HasScope(scope)
+}
+
+// record records the file event, missing resources aren't recorded
+func (h *handlers) record(action, name, targetPath string, err error) {
+	if os.IsNotExist(err) {
+		return
+	}
+
+	result := audit.ResultSuccess
+	if err != nil {
+		result = audit.ResultFailure
+	}
+
+	event := audit.Event{
+		UserId:    h.session.UserId,
+		Action:    action,
+		Result:    result,
+		Ip:        h.session.Ip,
+		UserAgent: h.session.ClientVersion,
+		Path:      h.name(name),
+	}
+
+	if targetPath != "" {
+		event.TargetPath = h.name(targetPath)
+	}
+
+	h.service.auditService.Record(event)
+}
+
+// error returns the error sent to the client. The text of errors is shown to users,
+// so unexpected errors are logged and replaced with the generic failure
+func (h *handlers) error(op string, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case public(err):
+		return err
+	case errors.Is(err, context.Canceled):
+		return pkgsftp.ErrSSHFxConnectionLost
+	case errors.Is(err, errNotVerified):
+		return pkgsftp.ErrSSHFxPermissionDenied
+	}
+
+	logger.AddContext(h.ctx, h.service.pkg, op, err)
+
+	return pkgsftp.ErrSSHFxFailure
+}
+
+// public reports whether the text of the error can be sent to the client
+func public(err error) bool {
+	return os.IsNotExist(err) || os.IsExist(err) || errors.Is(err, quota.ErrExceeded) ||
+		errors.Is(err, errIsDirectory) || errors.Is(err, errNotDirectory) || errors.Is(err, errNotEmpty) ||
+		errors.Is(err, errNotSequential) || errors.Is(err, errClosing)
+}
+
+// path returns the path in the bucket of the name inside the root folder
+func (h *handlers) path(name string, isDirectory bool) resource.Path {
+	return resource.Path{
+		IsDirectory:  isDirectory,
+		OriginalPath: name,
+		CleanPath:    path.Join(h.root, name),
+	}
+}
+
+// prefix returns the prefix of the keys inside the directory
+func (h *handlers) prefix(name string) string {
+	return h.path(name, true).CleanPathWithTailingSlash()
+}
+
+// name returns the path in the user's files, the folder of the token is a part of it
+func (h *handlers) name(name string) string {
+	return path.Join("/", h.session.AccessToken.Folder, name)
+}
+
+// cleanName returns the name in /folder/file form, the root folder is /
+func cleanName(name string) string {
+	return path.Clean("/" + name)
+}
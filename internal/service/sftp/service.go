@@ -0,0 +1,108 @@
+package sftp
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/minio/minio-go/v7"
+	pkgsftp "github.com/pkg/sftp"
+	"io"
+	"path"
+	"sync"
+)
+
+// Service serves the user's files over SFTP on top of the S3 operations
+type Service struct {
+	pkg          string
+	conf         *Config
+	s3Service    S3Service
+	quotaService QuotaService
+	auditService AuditService
+	userService  UserService
+
+	mu      sync.Mutex
+	closing bool
+	uploads sync.WaitGroup // uploads being written
+}
+
+type S3Service interface {
+	Object(ctx context.Context, path resource.Path) (*minio.Object, error)
+	StoreFile(ctx context.Context, path resource.Path, reader io.Reader, size int64) (minio.UploadInfo, error)
+	Stat(ctx context.Context, path resource.Path) (minio.ObjectInfo, error)
+	Delete(ctx context.Context, path resource.Path) error
+	Move(ctx context.Context, to, from resource.Path) error
+	StoreDirectory(ctx context.Context, path resource.Path) (minio.UploadInfo, error)
+	ListObjects(
+		ctx context.Context,
+		prefix, delimiter, startAfter, continuationToken string,
+		maxKeys int,
+	) (minio.ListBucketV2Result, error)
+
+	UserFolderPath(userId int64) string
+}
+
+type QuotaService interface {
+	Check(ctx context.Context, userId, incoming int64) error
+}
+
+type AuditService interface {
+	Record(event audit.Event)
+}
+
+type UserService interface {
+	UserById(ctx context.Context, userId int64) (user.User, error)
+}
+
+func NewService(
+	conf *Config,
+	s3Service S3Service,
+	quotaService QuotaService,
+	auditService AuditService,
+	userService UserService,
+) *Service {
+	return &Service{
+		pkg:          "sftp.service",
+		conf:         conf,
+		s3Service:    s3Service,
+		quotaService: quotaService,
+		auditService: auditService,
+		userService:  userService,
+	}
+}
+
+// Handlers returns the handlers of SFTP requests of the session. Personal access tokens restricted to a folder
+// see the folder as the root. S3 calls get the context, so closing the connection cancels them
+func (s *Service) Handlers(ctx context.Context, session Session) pkgsftp.Handlers {
+	h := &handlers{
+		ctx:     ctx,
+		service: s,
+		session: session,
+		root:    path.Join(s.s3Service.UserFolderPath(session.UserId), cleanName(session.AccessToken.Folder)),
+	}
+
+	return pkgsftp.Handlers{FileGet: h, FilePut: h, FileCmd: h, FileList: h}
+}
+
+// Wait rejects new uploads and waits for the started ones to be stored
+func (s *Service) Wait() {
+	s.mu.Lock()
+	s.closing = true
+	s.mu.Unlock()
+
+	s.uploads.Wait()
+}
+
+// startUpload registers the upload, it's rejected if the service is closing
+func (s *Service) startUpload() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closing {
+		return false
+	}
+
+	s.uploads.Add(1)
+
+	return true
+}
@@ -0,0 +1,493 @@
+package sftp
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/api/entity/resource"
+	"github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/quota"
+	"github.com/albakov/go-cloud-file-storage/internal/service/s3"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/minio/minio-go/v7"
+	pkgsftp "github.com/pkg/sftp"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memoryS3Service struct {
+	mu      sync.Mutex
+	objects map[string]string
+}
+
+type memoryQuotaService struct {
+	exceeded bool
+}
+
+type memoryAuditService struct {
+	mu     sync.Mutex
+	events []audit.Event
+}
+
+type memoryUserService struct {
+	verified bool
+}
+
+func TestSFTPService_Files(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{}}
+	auditService := &memoryAuditService{}
+	service := NewService(&Config{}, s3Service, &memoryQuotaService{}, auditService, &memoryUserService{})
+	client := newClient(t, service, Session{UserId: 1})
+
+	if err := client.Mkdir("/docs"); err != nil {
+		t.Fatalf("error while mkdir: %v", err)
+	}
+
+	if err := client.Mkdir("/missing/sub"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("directory in missing parent must not be created, got: %v", err)
+	}
+
+	// concurrent writes of the client arrive out of order
+	content := bytes.Repeat([]byte("0123456789abcdef"), 64*1024)
+	writeFile(t, client, "/docs/big.bin", content)
+
+	if stored := s3Service.object("user-1-files/docs/big.bin"); stored != string(content) {
+		t.Errorf("file must be stored with the written content, got %d bytes", len(stored))
+	}
+
+	writeFile(t, client, "/docs/a.txt", []byte("a"))
+
+	entries, err := client.ReadDir("/docs")
+	if err != nil {
+		t.Fatalf("error while read dir: %v", err)
+	}
+
+	if names := entryNames(entries); names != "a.txt big.bin" {
+		t.Errorf("directory must list its files, got: %s", names)
+	}
+
+	if err := client.Rename("/docs/a.txt", "/docs/big.bin"); err == nil {
+		t.Error("rename must not replace the existing file")
+	}
+
+	if err := client.PosixRename("/docs/a.txt", "/docs/b.txt"); err != nil {
+		t.Fatalf("error while rename: %v", err)
+	}
+
+	if err := client.Rename("/docs", "/archive"); err != nil {
+		t.Fatalf("error while rename directory: %v", err)
+	}
+
+	if s3Service.object("user-1-files/archive/b.txt") != "a" {
+		t.Errorf("renamed directory must keep the files, got: %v", s3Service.keys())
+	}
+
+	if err := client.RemoveDirectory("/archive"); err == nil {
+		t.Error("not empty directory must not be removed")
+	}
+
+	for _, name := range []string{"/archive/b.txt", "/archive/big.bin"} {
+		if err := client.Remove(name); err != nil {
+			t.Fatalf("error while remove %s: %v", name, err)
+		}
+	}
+
+	if err := client.RemoveDirectory("/archive"); err != nil {
+		t.Fatalf("error while remove directory: %v", err)
+	}
+
+	if keys := s3Service.keys(); len(keys) != 0 {
+		t.Errorf("all objects must be removed, got: %v", keys)
+	}
+
+	if n := auditService.count(audit.ActionResourceCreate, audit.ResultSuccess); n != 3 {
+		t.Errorf("created directory and files must be recorded, got %d events", n)
+	}
+}
+
+func TestSFTPService_AccessToken(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{"user-1-files/private.txt": "secret"}}
+	session := Session{
+		UserId:      1,
+		AccessToken: accesstoken.Token{Id: 1, UserId: 1, Scopes: []string{accesstoken.ScopeRead}, Folder: "/docs"},
+	}
+	service := NewService(&Config{}, s3Service, &memoryQuotaService{}, &memoryAuditService{}, &memoryUserService{})
+	client := newClient(t, service, session)
+
+	if _, err := client.Create("/file.txt"); err == nil {
+		t.Error("token without the write scope must not create files")
+	}
+
+	if _, err := client.Stat("/../private.txt"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("files outside of the folder of the token must not be found, got: %v", err)
+	}
+
+	entries, err := client.ReadDir("/")
+	if err != nil {
+		t.Fatalf("error while read dir: %v", err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("the folder of the token must be the root, got: %s", entryNames(entries))
+	}
+}
+
+func TestSFTPService_Quota(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{}}
+	auditService := &memoryAuditService{}
+	quotaService := &memoryQuotaService{exceeded: true}
+	service := NewService(&Config{}, s3Service, quotaService, auditService, &memoryUserService{})
+	client := newClient(t, service, Session{UserId: 1})
+
+	if _, err := client.Create("/file.txt"); err == nil || !strings.Contains(err.Error(), quota.ErrExceeded.Error()) {
+		t.Errorf("file must not be created over the quota, got: %v", err)
+	}
+
+	if n := auditService.count(audit.ActionResourceCreate, audit.ResultFailure); n != 1 {
+		t.Errorf("rejected upload must be recorded, got %d events", n)
+	}
+}
+
+func TestSFTPService_VerifiedEmail(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{"user-1-files/file.txt": "content"}}
+	auditService := &memoryAuditService{}
+	userService := &memoryUserService{}
+	service := NewService(&Config{RequireVerifiedEmail: true}, s3Service, &memoryQuotaService{}, auditService, userService)
+	client := newClient(t, service, Session{UserId: 1})
+
+	if _, err := client.Create("/new.txt"); !errors.Is(err, os.ErrPermission) {
+		t.Errorf("user with unverified email must not upload files, got: %v", err)
+	}
+
+	if n := auditService.count(audit.ActionResourceCreate, audit.ResultFailure); n != 1 {
+		t.Errorf("rejected upload must be recorded, got %d events", n)
+	}
+
+	if _, err := client.Stat("/file.txt"); err != nil {
+		t.Errorf("user with unverified email must read files, got: %v", err)
+	}
+
+	userService.verified = true
+	writeFile(t, client, "/new.txt", []byte("content"))
+}
+
+func TestWriter(t *testing.T) {
+	s3Service := &memoryS3Service{objects: map[string]string{}}
+	service := NewService(&Config{}, s3Service, &memoryQuotaService{}, &memoryAuditService{}, &memoryUserService{})
+	h := &handlers{ctx: context.Background(), service: service, root: "user-1-files"}
+
+	service.startUpload()
+	w := newWriter(h, "/a.txt")
+	for _, chunk := range []struct {
+		data string
+		off  int64
+	}{{"cd", 2}, {"ef", 4}, {"ab", 0}} {
+		if _, err := w.WriteAt([]byte(chunk.data), chunk.off); err != nil {
+			t.Fatalf("error while write at %d: %v", chunk.off, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("error while close: %v", err)
+	}
+
+	if s3Service.object("user-1-files/a.txt") != "abcdef" {
+		t.Errorf("chunks must be stored in order, got: %q", s3Service.object("user-1-files/a.txt"))
+	}
+
+	// the gap is never written
+	service.startUpload()
+	w = newWriter(h, "/b.txt")
+	if _, err := w.WriteAt([]byte("cd"), 2); err != nil {
+		t.Fatalf("error while write: %v", err)
+	}
+
+	if err := w.Close(); !errors.Is(err, errNotSequential) {
+		t.Errorf("file with the gap must not be stored, got: %v", err)
+	}
+
+	// the connection is lost before the file is closed
+	service.startUpload()
+	w = newWriter(h, "/c.txt")
+	if _, err := w.WriteAt([]byte("ab"), 0); err != nil {
+		t.Fatalf("error while write: %v", err)
+	}
+
+	w.TransferError(io.ErrUnexpectedEOF)
+	_ = w.Close()
+
+	if keys := s3Service.keys(); len(keys) != 1 {
+		t.Errorf("failed uploads must not be stored, got: %v", keys)
+	}
+
+	// started uploads are finished, new ones are rejected
+	service.Wait()
+
+	if service.startUpload() {
+		t.Error("upload must not be started after the service is closing")
+	}
+}
+
+func newClient(t *testing.T, service *Service, session Session) *pkgsftp.Client {
+	t.Helper()
+
+	clientReader, serverWriter := io.Pipe()
+	serverReader, clientWriter := io.Pipe()
+
+	server := pkgsftp.NewRequestServer(
+		struct {
+			io.Reader
+			io.WriteCloser
+		}{serverReader, serverWriter},
+		service.Handlers(context.Background(), session),
+	)
+
+	go func() {
+		_ = server.Serve()
+	}()
+
+	client, err := pkgsftp.NewClientPipe(clientReader, clientWriter, pkgsftp.UseConcurrentWrites(true))
+	if err != nil {
+		t.Fatalf("error while create client: %v", err)
+	}
+
+	t.Cleanup(func() {
+		// the server doesn't close its side when the client does
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	return client
+}
+
+func writeFile(t *testing.T, client *pkgsftp.Client, name string, content []byte) {
+	t.Helper()
+
+	f, err := client.Create(name)
+	if err != nil {
+		t.Fatalf("error while create %s: %v", name, err)
+	}
+
+	if _, err := f.ReadFrom(bytes.NewReader(content)); err != nil {
+		t.Fatalf("error while write %s: %v", name, err)
+	}
+
+	if err := f.Close(); err != nil {
+		t.Fatalf("error while close %s: %v", name, err)
+	}
+}
+
+func entryNames(entries []os.FileInfo) string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	sort.Strings(names)
+
+	return strings.Join(names, " ")
+}
+
+func (s *memoryS3Service) Object(context.Context, resource.Path) (*minio.Object, error) {
+	return nil, errors.New("reading objects is not supported")
+}
+
+func (s *memoryS3Service) StoreFile(
+	_ context.Context,
+	path resource.Path,
+	reader io.Reader,
+	_ int64,
+) (minio.UploadInfo, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[path.CleanPath] = string(data)
+
+	return minio.UploadInfo{Key: path.CleanPath, Size: int64(len(data))}, nil
+}
+
+func (s *memoryS3Service) Stat(_ context.Context, path resource.Path) (minio.ObjectInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := path.CleanPath
+	if path.IsDirectory {
+		key = path.CleanPathWithTailingSlash()
+	}
+
+	if data, ok := s.objects[key]; ok {
+		return minio.ObjectInfo{Key: key, Size: int64(len(data))}, nil
+	}
+
+	if path.IsDirectory && len(s.list(key)) > 0 {
+		return minio.ObjectInfo{Key: key}, nil
+	}
+
+	return minio.ObjectInfo{}, s3.ErrNotFound
+}
+
+func (s *memoryS3Service) Delete(_ context.Context, path resource.Path) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !path.IsDirectory {
+		delete(s.objects, path.CleanPath)
+
+		return nil
+	}
+
+	for _, key := range s.list(path.CleanPathWithTailingSlash()) {
+		delete(s.objects, key)
+	}
+
+	return nil
+}
+
+func (s *memoryS3Service) Move(_ context.Context, to, from resource.Path) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !from.IsDirectory {
+		s.objects[to.CleanPath] = s.objects[from.CleanPath]
+		delete(s.objects, from.CleanPath)
+
+		return nil
+	}
+
+	prefix := from.CleanPathWithTailingSlash()
+	for _, key := range s.list(prefix) {
+		s.objects[to.CleanPathWithTailingSlash()+strings.TrimPrefix(key, prefix)] = s.objects[key]
+		delete(s.objects, key)
+	}
+
+	return nil
+}
+
+func (s *memoryS3Service) StoreDirectory(_ context.Context, path resource.Path) (minio.UploadInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[path.CleanPathWithTailingSlash()] = ""
+
+	return minio.UploadInfo{Key: path.CleanPathWithTailingSlash()}, nil
+}
+
+func (s *memoryS3Service) ListObjects(
+	_ context.Context,
+	prefix, delimiter, startAfter, _ string,
+	maxKeys int,
+) (minio.ListBucketV2Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := minio.ListBucketV2Result{}
+	seen := map[string]bool{}
+
+	for _, key := range s.list(prefix) {
+		if key <= startAfter {
+			continue
+		}
+
+		if len(result.Contents)+len(result.CommonPrefixes) == maxKeys {
+			result.IsTruncated = true
+
+			break
+		}
+
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			p := prefix + rest[:i+1]
+			if !seen[p] {
+				seen[p] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, minio.CommonPrefix{Prefix: p})
+			}
+
+			continue
+		}
+
+		result.Contents = append(result.Contents, minio.ObjectInfo{Key: key, Size: int64(len(s.objects[key]))})
+	}
+
+	return result, nil
+}
+
+func (s *memoryS3Service) UserFolderPath(userId int64) string {
+	return fmt.Sprintf("user-%d-files", userId)
+}
+
+func (s *memoryS3Service) object(key string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.objects[key]
+}
+
+func (s *memoryS3Service) list(prefix string) []string {
+	var keys []string
+	for key := range s.objects {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func (s *memoryS3Service) keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list("")
+}
+
+func (q *memoryQuotaService) Check(context.Context, int64, int64) error {
+	if q.exceeded {
+		return quota.ErrExceeded
+	}
+
+	return nil
+}
+
+func (a *memoryAuditService) Record(event audit.Event) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.events = append(a.events, event)
+}
+
+func (a *memoryAuditService) count(action, result string) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	n := 0
+	for _, e := range a.events {
+		if e.Action == action && e.Result == result {
+			n++
+		}
+	}
+
+	return n
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	u := user.User{Id: userId}
+	if s.verified {
+		u.EmailVerifiedAt = sql.NullString{String: "2026-10-18 09:00:00", Valid: true}
+	}
+
+	return u, nil
+}
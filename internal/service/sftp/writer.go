@@ -0,0 +1,200 @@
+package sftp
+
+import (
+	"bytes"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	pkgsftp "github.com/pkg/sftp"
+	"io"
+	"os"
+	"sync"
+)
+
+var errNotSequential = errors.New("file must be written sequentially")
+
+const (
+	// maxPendingSize limits chunks written ahead of the stored content. Clients send several write requests
+	// at once and the server handles them concurrently, so they arrive slightly out of order
+	maxPendingSize = 32 << 20
+	// quotaCheckInterval is how often the quota is checked while the file is written
+	quotaCheckInterval = 64 << 20
+)
+
+// writer streams the written content to S3. Objects can't be changed in place, so the content
+// must be written from the start to the end, chunks written ahead wait for the gap before them
+type writer struct {
+	h    *handlers
+	name string
+
+	pw   *io.PipeWriter
+	done chan struct{} // closed when the upload is finished
+	// result of the upload, read after done is closed
+	uploadErr error
+
+	mu          sync.Mutex
+	offset      int64 // size of the content passed to the upload
+	nextCheck   int64
+	pending     map[int64][]byte // chunks written ahead by the offsets
+	pendingSize int
+	err         error // the first failure, the upload is aborted with it
+	closed      bool
+}
+
+// newWriter starts the upload, it must be registered with startUpload of the service
+func newWriter(h *handlers, name string) *writer {
+	pr, pw := io.Pipe()
+
+	w := &writer{
+		h:         h,
+		name:      name,
+		pw:        pw,
+		done:      make(chan struct{}),
+		nextCheck: quotaCheckInterval,
+		pending:   map[int64][]byte{},
+	}
+
+	go func() {
+		defer close(w.done)
+
+		_, err := h.service.s3Service.StoreFile(h.ctx, h.path(name, false), pr, -1)
+		// unblocks the writes if the upload failed before reading everything
+		_ = pr.CloseWithError(err)
+
+		w.uploadErr = err
+	}()
+
+	return w
+}
+
+func (w *writer) WriteAt(p []byte, off int64) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.err != nil {
+		return 0, w.error()
+	}
+
+	switch {
+	case off < w.offset:
+		w.fail(errNotSequential)
+
+		return 0, w.error()
+	case off > w.offset:
+		if _, ok := w.pending[off]; ok || w.pendingSize+len(p) > maxPendingSize {
+			w.fail(errNotSequential)
+
+			return 0, w.error()
+		}
+
+		// the buffer is reused by the server after the call
+		w.pending[off] = bytes.Clone(p)
+		w.pendingSize += len(p)
+
+		return len(p), nil
+	}
+
+	if err := w.write(p); err != nil {
+		return 0, w.error()
+	}
+
+	for {
+		chunk, ok := w.pending[w.offset]
+		if !ok {
+			return len(p), nil
+		}
+
+		delete(w.pending, w.offset)
+		w.pendingSize -= len(chunk)
+
+		if err := w.write(chunk); err != nil {
+			return 0, w.error()
+		}
+	}
+}
+
+// TransferError aborts the upload if the connection is lost before the file is closed,
+// otherwise the partial content would be stored
+func (w *writer) TransferError(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.fail(err)
+}
+
+// Close finishes the upload and waits for it to be stored
+func (w *writer) Close() error {
+	w.mu.Lock()
+
+	if w.closed {
+		w.mu.Unlock()
+
+		return os.ErrClosed
+	}
+
+	w.closed = true
+
+	// the gap before the pending chunks was never written
+	if w.err == nil && len(w.pending) > 0 {
+		w.fail(errNotSequential)
+	}
+
+	if w.err == nil {
+		_ = w.pw.Close()
+	}
+
+	err := w.err
+	w.mu.Unlock()
+
+	<-w.done
+	w.h.service.uploads.Done()
+
+	if err == nil {
+		err = w.uploadErr
+	}
+
+	w.h.record(audit.ActionResourceCreate, w.name, "", err)
+
+	return w.h.error("Close", err)
+}
+
+func (w *writer) write(p []byte) error {
+	n, err := w.pw.Write(p)
+	w.offset += int64(n)
+
+	if err != nil {
+		w.fail(err)
+
+		return w.err
+	}
+
+	if w.offset >= w.nextCheck {
+		w.nextCheck = w.offset + quotaCheckInterval
+
+		if err := w.h.service.quotaService.Check(w.h.ctx, w.h.session.UserId, w.offset); err != nil {
+			w.fail(err)
+
+			return w.err
+		}
+	}
+
+	return nil
+}
+
+// error returns the failure sent to the client, unexpected errors are logged once on close
+func (w *writer) error() error {
+	if public(w.err) {
+		return w.err
+	}
+
+	return pkgsftp.ErrSSHFxFailure
+}
+
+// fail aborts the upload with the first error
+func (w *writer) fail(err error) {
+	if w.err != nil {
+		return
+	}
+
+	w.err = err
+	_ = w.pw.CloseWithError(err)
+}
@@ -0,0 +1,104 @@
+package sigv4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// emptySHA256 is the hash of the empty string, a part of the string to sign of chunks
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// Payload checks the body against the signed payload hash and returns the content of it:
+// aws-chunked bodies of streaming uploads are decoded, the signatures of their chunks are checked
+func (s Signature) Payload(body []byte, secret string) ([]byte, error) {
+	switch s.PayloadHash {
+	case UnsignedPayload:
+		return body, nil
+	case StreamingPayload:
+		return s.decodeChunks(body, secret)
+	case StreamingUnsignedTrailer:
+		return s.decodeChunks(body, "")
+	}
+
+	if strings.HasPrefix(s.PayloadHash, "STREAMING-") {
+		return nil, ErrUnsupported
+	}
+
+	sum := sha256.Sum256(body)
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(s.PayloadHash)) != 1 {
+		return nil, ErrContentMismatch
+	}
+
+	return body, nil
+}
+
+// decodeChunks decodes "SIZE;chunk-signature=SIGNATURE\r\nDATA\r\n" chunks ending with the chunk of zero size.
+// Every signature signs the data and the previous signature, starting with the signature of the request.
+// Unsigned chunks have no signatures, their trailing headers with checksums are skipped
+func (s Signature) decodeChunks(body []byte, secret string) ([]byte, error) {
+	var (
+		content  []byte
+		previous = s.Signature
+		key      []byte
+	)
+
+	if secret != "" {
+		key = s.signingKey(secret)
+	}
+
+	for {
+		header, rest, ok := bytes.Cut(body, []byte("\r\n"))
+		if !ok {
+			return nil, fmt.Errorf("%w: chunk header", ErrContentMismatch)
+		}
+
+		sizeHex, extension, _ := strings.Cut(string(header), ";")
+
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil || size < 0 || size > int64(len(rest)) {
+			return nil, fmt.Errorf("%w: chunk size", ErrContentMismatch)
+		}
+
+		data := rest[:size]
+
+		if key != nil {
+			signature, ok := strings.CutPrefix(extension, "chunk-signature=")
+			if !ok {
+				return nil, fmt.Errorf("%w: chunk signature", ErrContentMismatch)
+			}
+
+			sum := sha256.Sum256(data)
+			stringToSign := strings.Join([]string{
+				"AWS4-HMAC-SHA256-PAYLOAD",
+				s.Time.Format(timeFormat),
+				s.scope(),
+				previous,
+				emptySHA256,
+				hex.EncodeToString(sum[:]),
+			}, "\n")
+
+			expected := hex.EncodeToString(hmacSHA256(key, stringToSign))
+			if subtle.ConstantTimeCompare([]byte(expected), []byte(signature)) != 1 {
+				return nil, ErrSignatureMismatch
+			}
+
+			previous = signature
+		}
+
+		if size == 0 {
+			return content, nil
+		}
+
+		content = append(content, data...)
+
+		body, ok = bytes.CutPrefix(rest[size:], []byte("\r\n"))
+		if !ok {
+			return nil, fmt.Errorf("%w: chunk end", ErrContentMismatch)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package sigv4 verifies requests signed with AWS Signature Version 4 by S3 clients
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Algorithm = "AWS4-HMAC-SHA256"
+	// Service is the only service in the credential scope of S3 requests
+	Service = "s3"
+
+	UnsignedPayload          = "UNSIGNED-PAYLOAD"
+	StreamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	StreamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+
+	timeFormat  = "20060102T150405Z"
+	dateFormat  = "20060102"
+	maxSkew     = 15 * time.Minute
+	maxExpires  = 7 * 24 * time.Hour
+	scopeSuffix = "aws4_request"
+)
+
+var (
+	ErrMissing           = errors.New("request is not signed")
+	ErrMalformed         = errors.New("signature of the request is malformed")
+	ErrUnsupported       = errors.New("signature algorithm or payload is not supported")
+	ErrTimeSkewed        = errors.New("request time is too skewed from the server time")
+	ErrExpired           = errors.New("request has expired")
+	ErrSignatureMismatch = errors.New("signature doesn't match")
+	ErrContentMismatch   = errors.New("payload doesn't match the signed hash")
+)
+
+// Signature is the signature of the request from the Authorization header or the query of the presigned URL
+type Signature struct {
+	AccessKeyId   string
+	Time          time.Time
+	Region        string
+	SignedHeaders []string
+	Signature     string
+	// PayloadHash is the x-amz-content-sha256 header, presigned requests don't sign the payload
+	PayloadHash string
+
+	presigned bool
+	expires   time.Duration
+}
+
+// Parse returns the signature of the request without checking it
+func Parse(r *http.Request) (Signature, error) {
+	if r.URL.Query().Has("X-Amz-Algorithm") {
+		return parseQuery(r.URL.Query())
+	}
+
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return Signature{}, ErrMissing
+	}
+
+	algorithm, fields, _ := strings.Cut(auth, " ")
+	if algorithm != Algorithm {
+		return Signature{}, ErrUnsupported
+	}
+
+	values := map[string]string{}
+	for _, field := range strings.Split(fields, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			return Signature{}, ErrMalformed
+		}
+
+		values[k] = v
+	}
+
+	s, err := parseCredential(values["Credential"])
+	if err != nil {
+		return Signature{}, err
+	}
+
+	s.SignedHeaders = strings.Split(values["SignedHeaders"], ";")
+	s.Signature = values["Signature"]
+	s.PayloadHash = r.Header.Get("X-Amz-Content-Sha256")
+
+	if s.Signature == "" || s.PayloadHash == "" {
+		return Signature{}, ErrMalformed
+	}
+
+	if s.Time, err = requestTime(r); err != nil {
+		return Signature{}, err
+	}
+
+	return s, nil
+}
+
+func parseQuery(query url.Values) (Signature, error) {
+	if query.Get("X-Amz-Algorithm") != Algorithm {
+		return Signature{}, ErrUnsupported
+	}
+
+	s, err := parseCredential(query.Get("X-Amz-Credential"))
+	if err != nil {
+		return Signature{}, err
+	}
+
+	s.Time, err = time.Parse(timeFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return Signature{}, ErrMalformed
+	}
+
+	expires, err := strconv.ParseInt(query.Get("X-Amz-Expires"), 10, 64)
+	if err != nil || expires < 0 || time.Duration(expires)*time.Second > maxExpires {
+		return Signature{}, ErrMalformed
+	}
+
+	s.SignedHeaders = strings.Split(query.Get("X-Amz-SignedHeaders"), ";")
+	s.Signature = query.Get("X-Amz-Signature")
+	s.PayloadHash = UnsignedPayload
+	s.presigned = true
+	s.expires = time.Duration(expires) * time.Second
+
+	if s.Signature == "" {
+		return Signature{}, ErrMalformed
+	}
+
+	return s, nil
+}
+
+// parseCredential parses ACCESS_KEY_ID/DATE/REGION/s3/aws4_request
+func parseCredential(credential string) (Signature, error) {
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] == "" || parts[3] != Service || parts[4] != scopeSuffix {
+		return Signature{}, ErrMalformed
+	}
+
+	if _, err := time.Parse(dateFormat, parts[1]); err != nil {
+		return Signature{}, ErrMalformed
+	}
+
+	return Signature{AccessKeyId: parts[0], Region: parts[2]}, nil
+}
+
+func requestTime(r *http.Request) (time.Time, error) {
+	if v := r.Header.Get("X-Amz-Date"); v != "" {
+		t, err := time.Parse(timeFormat, v)
+		if err != nil {
+			return time.Time{}, ErrMalformed
+		}
+
+		return t, nil
+	}
+
+	t, err := http.ParseTime(r.Header.Get("Date"))
+	if err != nil {
+		return time.Time{}, ErrMalformed
+	}
+
+	return t.UTC(), nil
+}
+
+// Verify checks the signature of the request with the secret access key at the time now
+func (s Signature) Verify(r *http.Request, secret, region string, now time.Time) error {
+	if s.Region != region {
+		return fmt.Errorf("%w: region must be %s", ErrMalformed, region)
+	}
+
+	if !slices.Contains(s.SignedHeaders, "host") {
+		return fmt.Errorf("%w: host header must be signed", ErrMalformed)
+	}
+
+	if s.presigned {
+		if now.After(s.Time.Add(s.expires)) {
+			return ErrExpired
+		}
+
+		if s.Time.After(now.Add(maxSkew)) {
+			return ErrTimeSkewed
+		}
+	} else if now.Sub(s.Time) > maxSkew || s.Time.Sub(now) > maxSkew {
+		return ErrTimeSkewed
+	}
+
+	expected := hex.EncodeToString(hmacSHA256(s.signingKey(secret), s.stringToSign(s.canonicalRequest(r))))
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(s.Signature)) != 1 {
+		return ErrSignatureMismatch
+	}
+
+	return nil
+}
+
+func (s Signature) canonicalRequest(r *http.Request) string {
+	var headers strings.Builder
+	for _, name := range s.SignedHeaders {
+		headers.WriteString(name)
+		headers.WriteByte(':')
+		headers.WriteString(headerValue(r, name))
+		headers.WriteByte('\n')
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		s3utils.EncodePath(r.URL.Path),
+		canonicalQuery(r.URL.Query(), s.presigned),
+		headers.String(),
+		strings.Join(s.SignedHeaders, ";"),
+		s.PayloadHash,
+	}, "\n")
+}
+
+func (s Signature) stringToSign(canonicalRequest string) string {
+	sum := sha256.Sum256([]byte(canonicalRequest))
+
+	return strings.Join([]string{Algorithm, s.Time.Format(timeFormat), s.scope(), hex.EncodeToString(sum[:])}, "\n")
+}
+
+func (s Signature) scope() string {
+	return strings.Join([]string{s.Time.Format(dateFormat), s.Region, Service, scopeSuffix}, "/")
+}
+
+func (s Signature) signingKey(secret string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), s.Time.Format(dateFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, Service)
+
+	return hmacSHA256(key, scopeSuffix)
+}
+
+func headerValue(r *http.Request, name string) string {
+	switch name {
+	case "host":
+		return r.Host
+	case "content-length":
+		if r.Header.Get("Content-Length") == "" {
+			return strconv.FormatInt(r.ContentLength, 10)
+		}
+	}
+
+	var values []string
+	for _, v := range r.Header.Values(name) {
+		values = append(values, strings.Join(strings.Fields(v), " "))
+	}
+
+	return strings.Join(values, ",")
+}
+
+// canonicalQuery returns the query sorted by names and values, the signature of presigned URLs isn't signed
+func canonicalQuery(query url.Values, presigned bool) string {
+	if presigned {
+		query.Del("X-Amz-Signature")
+	}
+
+	params := make([][2]string, 0, len(query))
+	for name, values := range query {
+		for _, v := range values {
+			params = append(params, [2]string{encode(name), encode(v)})
+		}
+	}
+
+	// sorted by the name and then by the value, so "a" goes before "a-b"
+	slices.SortFunc(params, func(a, b [2]string) int {
+		if c := strings.Compare(a[0], b[0]); c != 0 {
+			return c
+		}
+
+		return strings.Compare(a[1], b[1])
+	})
+
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p[0] + "=" + p[1]
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// encode escapes everything except unreserved characters, as URI encoding of SigV4 requires
+func encode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+
+	return h.Sum(nil)
+}
@@ -0,0 +1,168 @@
+package sigv4
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/minio/minio-go/v7/pkg/signer"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testAccessKeyId = "CFSTESTACCESSKEY000"
+	testSecret      = "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY"
+	testRegion      = "us-east-1"
+)
+
+type sha256Hasher struct {
+	hash.Hash
+}
+
+func TestSignature_Verify(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://files.local:9000/files/dir/a%20b~c.txt?list-type=2&prefix=a+b&a-b=1&a=2", nil)
+	req.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+	req.Header.Set("X-Amz-Meta-Note", "  two   spaces ")
+	req = signer.SignV4(*req, testAccessKeyId, testSecret, "", testRegion)
+
+	s, err := Parse(req)
+	if err != nil {
+		t.Fatalf("error while parse signature: %v", err)
+	}
+
+	if s.AccessKeyId != testAccessKeyId || s.Region != testRegion {
+		t.Errorf("signature must have the access key id and the region, got: %+v", s)
+	}
+
+	if err := s.Verify(req, testSecret, testRegion, time.Now()); err != nil {
+		t.Errorf("signature of minio-go must be valid, got: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		secret string
+		region string
+		now    time.Time
+		err    error
+	}{
+		{name: "wrong secret", secret: "wrong", region: testRegion, now: time.Now(), err: ErrSignatureMismatch},
+		{name: "wrong region", secret: testSecret, region: "eu-west-1", now: time.Now(), err: ErrMalformed},
+		{name: "old request", secret: testSecret, region: testRegion, now: time.Now().Add(time.Hour), err: ErrTimeSkewed},
+	} {
+		if err := s.Verify(req, tc.secret, tc.region, tc.now); !errors.Is(err, tc.err) {
+			t.Errorf("%s must return %v, got: %v", tc.name, tc.err, err)
+		}
+	}
+
+	req.Header.Set("X-Amz-Meta-Note", "changed")
+	if err := s.Verify(req, testSecret, testRegion, time.Now()); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("changed signed header must not match, got: %v", err)
+	}
+}
+
+func TestSignature_VerifyPresigned(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://files.local:9000/files/report.pdf", nil)
+	req = signer.PreSignV4(*req, testAccessKeyId, testSecret, "", testRegion, 60)
+
+	s, err := Parse(req)
+	if err != nil {
+		t.Fatalf("error while parse presigned signature: %v", err)
+	}
+
+	if err := s.Verify(req, testSecret, testRegion, time.Now()); err != nil {
+		t.Errorf("presigned URL of minio-go must be valid, got: %v", err)
+	}
+
+	if err := s.Verify(req, testSecret, testRegion, time.Now().Add(2*time.Minute)); !errors.Is(err, ErrExpired) {
+		t.Errorf("presigned URL must expire, got: %v", err)
+	}
+
+	if _, err := Parse(httptest.NewRequest(http.MethodGet, "/files/report.pdf", nil)); !errors.Is(err, ErrMissing) {
+		t.Errorf("request without signature must return ErrMissing, got: %v", err)
+	}
+}
+
+func TestSignature_Payload(t *testing.T) {
+	body := []byte("content")
+	sum := sha256.Sum256(body)
+
+	s := Signature{PayloadHash: hex.EncodeToString(sum[:])}
+	if got, err := s.Payload(body, testSecret); err != nil || !bytes.Equal(got, body) {
+		t.Errorf("payload with the signed hash must be returned, got: %q %v", got, err)
+	}
+
+	if _, err := s.Payload([]byte("changed"), testSecret); !errors.Is(err, ErrContentMismatch) {
+		t.Errorf("payload with another hash must return ErrContentMismatch, got: %v", err)
+	}
+}
+
+func TestSignature_PayloadStreaming(t *testing.T) {
+	// chunks of minio-go are 64 KiB, so the content is sent in 3 of them and the final empty one
+	content := bytes.Repeat([]byte("0123456789"), 15000)
+
+	req := httptest.NewRequest(http.MethodPut, "http://files.local:9000/files/big.bin", nil)
+	req.Body = io.NopCloser(bytes.NewReader(content))
+	req = signer.StreamingSignV4(
+		req, testAccessKeyId, testSecret, "", testRegion, int64(len(content)), time.Now().UTC(),
+		sha256Hasher{sha256.New()},
+	)
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("error while read signed body: %v", err)
+	}
+
+	s, err := Parse(req)
+	if err != nil {
+		t.Fatalf("error while parse signature: %v", err)
+	}
+
+	if err := s.Verify(req, testSecret, testRegion, time.Now()); err != nil {
+		t.Fatalf("seed signature must be valid, got: %v", err)
+	}
+
+	got, err := s.Payload(body, testSecret)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("chunks must be decoded to the content, got %d bytes: %v", len(got), err)
+	}
+
+	tampered := bytes.Replace(body, []byte("0123"), []byte("3210"), 1)
+	if _, err := s.Payload(tampered, testSecret); !errors.Is(err, ErrSignatureMismatch) {
+		t.Errorf("changed chunk must not match its signature, got: %v", err)
+	}
+}
+
+func TestSignature_PayloadUnsignedTrailer(t *testing.T) {
+	content := []byte(strings.Repeat("x", 100))
+
+	req := httptest.NewRequest(http.MethodPut, "http://files.local:9000/files/a.txt", bytes.NewReader(content))
+	req.Header.Set("X-Amz-Content-Sha256", StreamingUnsignedTrailer)
+	req = signer.SignV4Trailer(*req, testAccessKeyId, testSecret, "", testRegion, http.Header{"x-amz-checksum-crc32": {"AAAAAA=="}})
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatalf("error while read body: %v", err)
+	}
+
+	s, err := Parse(req)
+	if err != nil {
+		t.Fatalf("error while parse signature: %v", err)
+	}
+
+	if err := s.Verify(req, testSecret, testRegion, time.Now()); err != nil {
+		t.Fatalf("signature must be valid, got: %v", err)
+	}
+
+	got, err := s.Payload(body, testSecret)
+	if err != nil || !bytes.Equal(got, content) {
+		t.Errorf("chunks must be decoded to the content, got %q: %v", got, err)
+	}
+}
+
+func (sha256Hasher) Close() {}
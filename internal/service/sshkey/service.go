@@ -0,0 +1,176 @@
+package sshkey
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/sshkey"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid       = errors.New("ssh key invalid")
+	ErrInvalidKey    = errors.New("ssh public key is malformed or not allowed")
+	ErrNotFound      = errors.New("ssh key not found")
+	ErrNameRequired  = errors.New("ssh key name required")
+	ErrAlreadyExists = errors.New("ssh key already exists")
+)
+
+// minRSABits is the smallest size of RSA keys accepted, shorter keys are considered broken
+const minRSABits = 2048
+
+// lastUsedInterval is how often the last use of the key is stored, clients often try keys on every connection
+const lastUsedInterval = time.Minute
+
+type Service struct {
+	pkg        string
+	sshKeyRepo Repository
+}
+
+type Repository interface {
+	Create(key sshkey.SSHKey) (sshkey.SSHKey, error)
+	ByFingerprint(fingerprint string) (sshkey.SSHKey, error)
+	ByUserId(userId int64) ([]sshkey.SSHKey, error)
+	Delete(userId, keyId int64) error
+	UpdateLastUsed(keyId int64) error
+}
+
+func NewService(sshKeyRepo Repository) *Service {
+	return &Service{
+		pkg:        "sshkey.service",
+		sshKeyRepo: sshKeyRepo,
+	}
+}
+
+// AddKey adds the public key in the authorized_keys format to the user. The comment of the key is its name
+// if the name is empty
+func (s *Service) AddKey(userId int64, name, authorizedKey string) (sshkey.SSHKey, error) {
+	const op = "AddKey"
+
+	pub, comment, _, rest, err := ssh.ParseAuthorizedKey([]byte(strings.TrimSpace(authorizedKey)))
+	if err != nil || len(bytes.TrimSpace(rest)) > 0 || !allowed(pub) {
+		return sshkey.SSHKey{}, ErrInvalidKey
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = strings.TrimSpace(comment)
+	}
+
+	if name == "" {
+		return sshkey.SSHKey{}, ErrNameRequired
+	}
+
+	k, err := s.sshKeyRepo.Create(sshkey.SSHKey{
+		UserId:      userId,
+		Name:        name,
+		PublicKey:   strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub))),
+		Fingerprint: ssh.FingerprintSHA256(pub),
+	})
+	if err != nil {
+		// the key authenticates a single user only
+		if errors.Is(err, storage.ErrDuplicateNotAllowed) {
+			return sshkey.SSHKey{}, ErrAlreadyExists
+		}
+
+		return sshkey.SSHKey{}, logger.Error(s.pkg, op, err)
+	}
+
+	return k, nil
+}
+
+// Keys returns all SSH keys of the user
+func (s *Service) Keys(userId int64) ([]sshkey.SSHKey, error) {
+	const op = "Keys"
+
+	keys, err := s.sshKeyRepo.ByUserId(userId)
+	if err != nil {
+		return nil, logger.Error(s.pkg, op, err)
+	}
+
+	return keys, nil
+}
+
+// RemoveKey deletes the key of the user
+func (s *Service) RemoveKey(userId, keyId int64) error {
+	const op = "RemoveKey"
+
+	err := s.sshKeyRepo.Delete(userId, keyId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Authenticate returns the id of the user the public key is added to, keys of disabled users are invalid
+func (s *Service) Authenticate(pub ssh.PublicKey) (int64, error) {
+	const op = "Authenticate"
+
+	k, err := s.sshKeyRepo.ByFingerprint(ssh.FingerprintSHA256(pub))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, ErrInvalid
+		}
+
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	stored, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.PublicKey))
+	if err != nil {
+		return 0, logger.Error(s.pkg, op, err)
+	}
+
+	if !bytes.Equal(stored.Marshal(), pub.Marshal()) {
+		return 0, ErrInvalid
+	}
+
+	if s.lastUseOutdated(k) {
+		if err := s.sshKeyRepo.UpdateLastUsed(k.Id); err != nil {
+			logger.Add(s.pkg, op, err)
+		}
+	}
+
+	return k.UserId, nil
+}
+
+func (s *Service) lastUseOutdated(k sshkey.SSHKey) bool {
+	if !k.LastUsedAt.Valid {
+		return true
+	}
+
+	lastUsedAt, err := time.ParseInLocation(time.DateTime, k.LastUsedAt.String, time.Local)
+	if err != nil {
+		return true
+	}
+
+	return time.Since(lastUsedAt) >= lastUsedInterval
+}
+
+// allowed rejects certificates, DSA keys and short RSA keys
+func allowed(pub ssh.PublicKey) bool {
+	switch pub.Type() {
+	case ssh.KeyAlgoRSA:
+		cryptoPub, ok := pub.(ssh.CryptoPublicKey)
+		if !ok {
+			return false
+		}
+
+		rsaPub, ok := cryptoPub.CryptoPublicKey().(*rsa.PublicKey)
+
+		return ok && rsaPub.N.BitLen() >= minRSABits
+	case ssh.KeyAlgoED25519, ssh.KeyAlgoSKED25519,
+		ssh.KeyAlgoECDSA256, ssh.KeyAlgoECDSA384, ssh.KeyAlgoECDSA521, ssh.KeyAlgoSKECDSA256:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,177 @@
+package sshkey
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/sshkey"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+)
+
+type memoryRepository struct {
+	lastId   int64
+	keys     map[int64]sshkey.SSHKey
+	lastUsed map[int64]int
+}
+
+func TestSSHKeyService_AddKey(t *testing.T) {
+	repo := &memoryRepository{keys: map[int64]sshkey.SSHKey{}, lastUsed: map[int64]int{}}
+	service := NewService(repo)
+	pub := newPublicKey(t)
+	authorizedKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pub)))
+
+	k, err := service.AddKey(1, " ", authorizedKey+" user@laptop\n")
+	if err != nil {
+		t.Fatalf("error while add key: %v", err)
+	}
+
+	if k.Name != "user@laptop" {
+		t.Errorf("comment of the key must be its name if the name is empty, got: %q", k.Name)
+	}
+
+	if k.PublicKey != authorizedKey || k.Fingerprint != ssh.FingerprintSHA256(pub) {
+		t.Errorf("key must be stored without the comment with its fingerprint, got: %+v", k)
+	}
+
+	if _, err := service.AddKey(2, "other", authorizedKey); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("key added to other user must return ErrAlreadyExists, got: %v", err)
+	}
+
+	noComment := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(newPublicKey(t))))
+	if _, err := service.AddKey(1, "", noComment); !errors.Is(err, ErrNameRequired) {
+		t.Errorf("key without name and comment must return ErrNameRequired, got: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("error while generate key: %v", err)
+	}
+
+	weak, err := ssh.NewPublicKey(&rsaKey.PublicKey)
+	if err != nil {
+		t.Fatalf("error while convert key: %v", err)
+	}
+
+	for _, authorizedKey := range []string{
+		"",
+		"ssh-ed25519 not-base64",
+		string(ssh.MarshalAuthorizedKey(weak)),
+		authorizedKey + "\n" + authorizedKey,
+	} {
+		if _, err := service.AddKey(1, "name", authorizedKey); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("key %q must be invalid, got: %v", authorizedKey, err)
+		}
+	}
+}
+
+func TestSSHKeyService_Authenticate(t *testing.T) {
+	repo := &memoryRepository{keys: map[int64]sshkey.SSHKey{}, lastUsed: map[int64]int{}}
+	service := NewService(repo)
+	pub := newPublicKey(t)
+
+	k, err := service.AddKey(1, "laptop", string(ssh.MarshalAuthorizedKey(pub)))
+	if err != nil {
+		t.Fatalf("error while add key: %v", err)
+	}
+
+	userId, err := service.Authenticate(pub)
+	if err != nil {
+		t.Fatalf("error while authenticate: %v", err)
+	}
+
+	if userId != 1 {
+		t.Errorf("key must authenticate its user, got: %d", userId)
+	}
+
+	if repo.lastUsed[k.Id] != 1 {
+		t.Errorf("last use must be stored, got %d updates", repo.lastUsed[k.Id])
+	}
+
+	if _, err := service.Authenticate(newPublicKey(t)); !errors.Is(err, ErrInvalid) {
+		t.Errorf("unknown key must be invalid, got: %v", err)
+	}
+
+	if err := service.RemoveKey(2, k.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("key of other user must not be removed, got: %v", err)
+	}
+
+	if err := service.RemoveKey(1, k.Id); err != nil {
+		t.Fatalf("error while remove key: %v", err)
+	}
+
+	if _, err := service.Authenticate(pub); !errors.Is(err, ErrInvalid) {
+		t.Errorf("removed key must be invalid, got: %v", err)
+	}
+}
+
+func newPublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	key, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("error while generate key: %v", err)
+	}
+
+	pub, err := ssh.NewPublicKey(key)
+	if err != nil {
+		t.Fatalf("error while convert key: %v", err)
+	}
+
+	return pub
+}
+
+func (r *memoryRepository) Create(key sshkey.SSHKey) (sshkey.SSHKey, error) {
+	for _, k := range r.keys {
+		if k.Fingerprint == key.Fingerprint {
+			return sshkey.SSHKey{}, storage.ErrDuplicateNotAllowed
+		}
+	}
+
+	r.lastId++
+	key.Id = r.lastId
+	r.keys[key.Id] = key
+
+	return key, nil
+}
+
+func (r *memoryRepository) ByFingerprint(fingerprint string) (sshkey.SSHKey, error) {
+	for _, k := range r.keys {
+		if k.Fingerprint == fingerprint {
+			return k, nil
+		}
+	}
+
+	return sshkey.SSHKey{}, storage.ErrNotFound
+}
+
+func (r *memoryRepository) ByUserId(userId int64) ([]sshkey.SSHKey, error) {
+	keys := []sshkey.SSHKey{}
+	for _, k := range r.keys {
+		if k.UserId == userId {
+			keys = append(keys, k)
+		}
+	}
+
+	return keys, nil
+}
+
+func (r *memoryRepository) Delete(userId, keyId int64) error {
+	k, ok := r.keys[keyId]
+	if !ok || k.UserId != userId {
+		return storage.ErrNotFound
+	}
+
+	delete(r.keys, keyId)
+
+	return nil
+}
+
+func (r *memoryRepository) UpdateLastUsed(keyId int64) error {
+	r.lastUsed[keyId]++
+
+	return nil
+}
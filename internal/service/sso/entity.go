@@ -0,0 +1,34 @@
+package sso
+
+import "time"
+
+// Provider is an OpenID Connect identity provider configured in the providers file
+type Provider struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientId     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+type Config struct {
+	Providers     []Provider
+	CallbackURL   string // callback of the provider is CallbackURL/{provider}/callback
+	AutoProvision bool   // create users signed in for the first time
+	Secret        string // signs the login state
+	StateTTL      time.Duration
+}
+
+// Claims are the verified claims of the ID token
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Result of the completed login flow
+type Result struct {
+	UserId          int64
+	Linked          bool // the provider has been linked to the signed-in user
+	Reauthenticated bool // the signed-in user has signed in with the linked provider again
+}
@@ -0,0 +1,135 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// provider discovers the issuer on first use, so the api starts even if the issuer is unavailable
+type provider struct {
+	conf        Provider
+	callbackURL string
+	httpClient  *http.Client
+
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+type idTokenClaims struct {
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"` // some providers send it as a string
+}
+
+// LoadProviders reads providers from the json file, empty path means single sign-on is disabled
+func LoadProviders(path string) ([]Provider, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var providers []Provider
+	if err := json.Unmarshal(b, &providers); err != nil {
+		return nil, err
+	}
+
+	for _, p := range providers {
+		if p.Name == "" || p.Issuer == "" || p.ClientId == "" {
+			return nil, fmt.Errorf("provider %q: name, issuer and client_id are required", p.Name)
+		}
+	}
+
+	return providers, nil
+}
+
+func (p *provider) client() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	// discovery context also configures the key set, so it must not be canceled with the request
+	op, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.httpClient), p.conf.Issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopes := p.conf.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.conf.ClientId,
+		ClientSecret: p.conf.ClientSecret,
+		Endpoint:     op.Endpoint(),
+		RedirectURL:  fmt.Sprintf("%s/%s/callback", strings.TrimSuffix(p.callbackURL, "/"), p.conf.Name),
+		Scopes:       scopes,
+	}
+	p.verifier = op.Verifier(&oidc.Config{ClientID: p.conf.ClientId})
+
+	return p.oauth2, p.verifier, nil
+}
+
+func (p *provider) authURL(st state) (string, error) {
+	conf, _, err := p.client()
+	if err != nil {
+		return "", err
+	}
+
+	return conf.AuthCodeURL(st.State, oidc.Nonce(st.Nonce), oauth2.S256ChallengeOption(st.Verifier)), nil
+}
+
+// exchange trades the code for tokens and verifies the ID token
+func (p *provider) exchange(ctx context.Context, code string, st state) (Claims, error) {
+	conf, verifier, err := p.client()
+	if err != nil {
+		return Claims{}, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+
+	token, err := conf.Exchange(ctx, code, oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		return Claims{}, err
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Claims{}, errors.New("id_token is missing in the token response")
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	if idToken.Nonce != st.Nonce {
+		return Claims{}, errors.New("id_token nonce mismatch")
+	}
+
+	var c idTokenClaims
+	if err := idToken.Claims(&c); err != nil {
+		return Claims{}, err
+	}
+
+	return Claims{
+		Subject:       idToken.Subject,
+		Email:         c.Email,
+		EmailVerified: c.EmailVerified == true || c.EmailVerified == "true",
+	}, nil
+}
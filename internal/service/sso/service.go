@@ -0,0 +1,399 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/email"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/useridentity"
+	"golang.org/x/oauth2"
+	"net/http"
+	"sort"
+	"time"
+)
+
+var (
+	ErrUnknownProvider       = errors.New("unknown identity provider")
+	ErrInvalidState          = errors.New("login state invalid or expired")
+	ErrEmailNotVerified      = errors.New("email is not verified by the identity provider")
+	ErrUserNotFound          = errors.New("user not found and provisioning is disabled")
+	ErrIdentityLinked        = errors.New("identity is linked to another user")
+	ErrProviderAlreadyLinked = errors.New("provider is already linked to the user")
+	ErrNotFound              = errors.New("identity not found")
+	ErrLastLoginMethod       = errors.New("identity is the last way to sign in")
+	ErrNoIdentity            = errors.New("user has no linked identities")
+	ErrUserDisabled          = errors.New("user is disabled")
+)
+
+type Service struct {
+	pkg                string
+	conf               *Config
+	secret             []byte
+	providers          map[string]*provider
+	identityRepo       IdentityRepository
+	userService        UserService
+	userSessionService UserSessionService
+}
+
+type IdentityRepository interface {
+	Create(identity useridentity.Identity) (useridentity.Identity, error)
+	ByProviderSubject(provider, subject string) (useridentity.Identity, error)
+	ByUserId(userId int64) ([]useridentity.Identity, error)
+	Delete(userId, identityId int64) error
+}
+
+type UserService interface {
+	CreateUser(ctx context.Context, us userservice.User) (user.User, error)
+	UserByEmail(ctx context.Context, email string) (user.User, error)
+	UserById(ctx context.Context, userId int64) (user.User, error)
+	VerifyEmail(ctx context.Context, userId int64) error
+	UpdatePassword(ctx context.Context, userId int64, newPassword string) error
+	SetPasswordLoginDisabled(ctx context.Context, userId int64, disabled bool) error
+}
+
+type UserSessionService interface {
+	DeleteUserSessions(ctx context.Context, userId int64) error
+}
+
+func NewService(
+	conf *Config,
+	identityRepo IdentityRepository,
+	userService UserService,
+	userSessionService UserSessionService,
+) *Service {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+
+	providers := make(map[string]*provider, len(conf.Providers))
+	for _, p := range conf.Providers {
+		providers[p.Name] = &provider{conf: p, callbackURL: conf.CallbackURL, httpClient: httpClient}
+	}
+
+	return &Service{
+		pkg:                "sso.service",
+		conf:               conf,
+		secret:             []byte(conf.Secret),
+		providers:          providers,
+		identityRepo:       identityRepo,
+		userService:        userService,
+		userSessionService: userSessionService,
+	}
+}
+
+// Providers returns names of the configured providers
+func (s *Service) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// AuthURL starts the authorization code flow with PKCE. It returns the url of the provider
+// and the signed state which must be returned to Callback. Pass userId to link the provider
+// to the signed-in user, 0 to sign in.
+func (s *Service) AuthURL(providerName string, userId int64) (string, string, error) {
+	return s.authURL(providerName, userId, false)
+}
+
+// ReauthURL starts the flow in which the signed-in user signs in with the linked provider again, it confirms
+// actions instead of the password, e.g. for users who have never set it
+func (s *Service) ReauthURL(providerName string, userId int64) (string, string, error) {
+	return s.authURL(providerName, userId, true)
+}
+
+func (s *Service) authURL(providerName string, userId int64, reauth bool) (string, string, error) {
+	const op = "authURL"
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	st := state{
+		Provider:  providerName,
+		State:     randomString(),
+		Nonce:     randomString(),
+		Verifier:  oauth2.GenerateVerifier(),
+		UserId:    userId,
+		Reauth:    reauth,
+		ExpiresAt: time.Now().Add(s.conf.StateTTL).Unix(),
+	}
+
+	authURL, err := p.authURL(st)
+	if err != nil {
+		return "", "", logger.Error(s.pkg, op, err)
+	}
+
+	encoded, err := s.encodeState(st)
+	if err != nil {
+		return "", "", logger.Error(s.pkg, op, err)
+	}
+
+	return authURL, encoded, nil
+}
+
+// Callback completes the flow started by AuthURL: checks the state, exchanges the code
+// and signs the user in or links the provider
+func (s *Service) Callback(ctx context.Context, providerName, code, stateParam, stateCookie string) (Result, error) {
+	const op = "Callback"
+
+	p, ok := s.providers[providerName]
+	if !ok {
+		return Result{}, ErrUnknownProvider
+	}
+
+	st, err := s.decodeState(stateCookie)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if st.Provider != providerName || st.State == "" || st.State != stateParam || code == "" {
+		return Result{}, ErrInvalidState
+	}
+
+	claims, err := p.exchange(ctx, code, st)
+	if err != nil {
+		return Result{}, logger.Error(s.pkg, op, err)
+	}
+
+	if st.Reauth {
+		return s.reauthenticate(st.UserId, providerName, claims)
+	}
+
+	if st.UserId != 0 {
+		return s.link(ctx, st.UserId, providerName, claims)
+	}
+
+	return s.login(ctx, providerName, claims)
+}
+
+// Identities returns providers linked to the user
+func (s *Service) Identities(ctx context.Context, userId int64) ([]useridentity.Identity, error) {
+	const op = "Identities"
+
+	identities, err := s.identityRepo.ByUserId(userId)
+	if err != nil {
+		return nil, logger.Error(s.pkg, op, err)
+	}
+
+	return identities, nil
+}
+
+// Unlink removes the identity, the last identity can't be removed while password login is disabled
+func (s *Service) Unlink(ctx context.Context, userId, identityId int64) error {
+	const op = "Unlink"
+
+	us, err := s.userService.UserById(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if us.PasswordLoginDisabled {
+		identities, err := s.identityRepo.ByUserId(userId)
+		if err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+
+		if len(identities) <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+
+	err = s.identityRepo.Delete(userId, identityId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// SetPasswordLogin enables or disables sign in with password, it can be disabled
+// only when at least one provider is linked
+func (s *Service) SetPasswordLogin(ctx context.Context, userId int64, enabled bool) error {
+	const op = "SetPasswordLogin"
+
+	if !enabled {
+		identities, err := s.identityRepo.ByUserId(userId)
+		if err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+
+		if len(identities) == 0 {
+			return ErrNoIdentity
+		}
+	}
+
+	err := s.userService.SetPasswordLoginDisabled(ctx, userId, !enabled)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// login finds the user by the linked identity, links the identity by the verified email
+// or provisions a new user
+func (s *Service) login(ctx context.Context, providerName string, claims Claims) (Result, error) {
+	const op = "login"
+
+	identity, err := s.identityRepo.ByProviderSubject(providerName, claims.Subject)
+	if err == nil {
+		us, err := s.userService.UserById(ctx, identity.UserId)
+		if err != nil {
+			return Result{}, logger.Error(s.pkg, op, err)
+		}
+
+		if us.DisabledAt.Valid {
+			return Result{}, ErrUserDisabled
+		}
+
+		return Result{UserId: identity.UserId}, nil
+	}
+
+	if !errors.Is(err, storage.ErrNotFound) {
+		return Result{}, logger.Error(s.pkg, op, err)
+	}
+
+	e := email.Normalize(claims.Email)
+	if !claims.EmailVerified || email.Validate(e) != nil {
+		return Result{}, ErrEmailNotVerified
+	}
+
+	us, err := s.userService.UserByEmail(ctx, e)
+	if err != nil {
+		if !errors.Is(err, userservice.ErrNotFound) {
+			return Result{}, logger.Error(s.pkg, op, err)
+		}
+
+		if !s.conf.AutoProvision {
+			return Result{}, ErrUserNotFound
+		}
+
+		us, err = s.provision(ctx, e)
+		if err != nil {
+			return Result{}, logger.Error(s.pkg, op, err)
+		}
+	} else if us.DisabledAt.Valid {
+		return Result{}, ErrUserDisabled
+	} else if !us.EmailVerifiedAt.Valid {
+		// somebody could have registered the email before its owner, the provider has proven
+		// the ownership now, so the password and sessions of the unverified account are dropped
+		if err := s.takeOver(ctx, us.Id); err != nil {
+			return Result{}, logger.Error(s.pkg, op, err)
+		}
+	}
+
+	_, err = s.identityRepo.Create(useridentity.Identity{
+		UserId:   us.Id,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    e,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrDuplicateNotAllowed) {
+			return Result{}, ErrProviderAlreadyLinked
+		}
+
+		return Result{}, logger.Error(s.pkg, op, err)
+	}
+
+	return Result{UserId: us.Id}, nil
+}
+
+func (s *Service) link(ctx context.Context, userId int64, providerName string, claims Claims) (Result, error) {
+	const op = "link"
+
+	identity, err := s.identityRepo.ByProviderSubject(providerName, claims.Subject)
+	if err == nil {
+		if identity.UserId != userId {
+			return Result{}, ErrIdentityLinked
+		}
+
+		return Result{UserId: userId, Linked: true}, nil
+	}
+
+	if !errors.Is(err, storage.ErrNotFound) {
+		return Result{}, logger.Error(s.pkg, op, err)
+	}
+
+	_, err = s.identityRepo.Create(useridentity.Identity{
+		UserId:   userId,
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    email.Normalize(claims.Email),
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrDuplicateNotAllowed) {
+			return Result{}, ErrProviderAlreadyLinked
+		}
+
+		return Result{}, logger.Error(s.pkg, op, err)
+	}
+
+	return Result{UserId: userId, Linked: true}, nil
+}
+
+// reauthenticate checks that the identity the user has signed in with is linked to the user
+func (s *Service) reauthenticate(userId int64, providerName string, claims Claims) (Result, error) {
+	const op = "reauthenticate"
+
+	identity, err := s.identityRepo.ByProviderSubject(providerName, claims.Subject)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return Result{}, ErrNotFound
+		}
+
+		return Result{}, logger.Error(s.pkg, op, err)
+	}
+
+	if identity.UserId != userId {
+		return Result{}, ErrIdentityLinked
+	}
+
+	return Result{UserId: userId, Reauthenticated: true}, nil
+}
+
+// provision creates a user with an unknown random password, it can be set with password reset
+func (s *Service) provision(ctx context.Context, e string) (user.User, error) {
+	us, err := s.userService.CreateUser(ctx, userservice.User{Email: e, Password: randomString()})
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if err := s.userService.VerifyEmail(ctx, us.Id); err != nil {
+		return user.User{}, err
+	}
+
+	return us, nil
+}
+
+func (s *Service) takeOver(ctx context.Context, userId int64) error {
+	if err := s.userService.UpdatePassword(ctx, userId, randomString()); err != nil {
+		return err
+	}
+
+	if err := s.userSessionService.DeleteUserSessions(ctx, userId); err != nil {
+		return err
+	}
+
+	return s.userService.VerifyEmail(ctx, userId)
+}
+
+func randomString() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b) // never returns an error
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
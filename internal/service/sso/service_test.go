@@ -0,0 +1,567 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/useridentity"
+	"github.com/golang-jwt/jwt/v5"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockIdP is a minimal OpenID Connect provider: discovery, keys and token endpoint
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]authorization
+}
+
+type authorization struct {
+	challenge     string
+	nonce         string
+	subject       string
+	email         string
+	emailVerified bool
+}
+
+type memoryIdentityRepository struct {
+	lastId     int64
+	identities map[int64]useridentity.Identity
+}
+
+type memoryUserService struct {
+	lastId   int64
+	users    map[int64]user.User
+	sessions map[int64]int
+}
+
+func TestSSOService_LoginProvisionsUser(t *testing.T) {
+	idp := newMockIdP(t)
+	service, users := ssoTestService(idp, true)
+
+	result := idp.login(t, service, 0, "sub-1", "New@Example.com", true)
+	if result.UserId == 0 || result.Linked {
+		t.Fatalf("user must be provisioned and signed in, got: %+v", result)
+	}
+
+	us := users.users[result.UserId]
+	if us.Email.String != "new@example.com" || !us.EmailVerifiedAt.Valid {
+		t.Errorf("provisioned user must have verified normalized email, got: %+v", us)
+	}
+
+	// the same identity signs in to the same user, even if the email is changed at the provider
+	again := idp.login(t, service, 0, "sub-1", "other@example.com", false)
+	if again.UserId != result.UserId {
+		t.Errorf("linked identity must sign in user %d, got: %d", result.UserId, again.UserId)
+	}
+}
+
+func TestSSOService_LoginLinksUserByVerifiedEmail(t *testing.T) {
+	idp := newMockIdP(t)
+	service, users := ssoTestService(idp, false)
+
+	us := users.add("user@example.com", true)
+
+	result := idp.login(t, service, 0, "sub-1", "user@example.com", true)
+	if result.UserId != us.Id {
+		t.Errorf("identity must be linked to user %d, got: %d", us.Id, result.UserId)
+	}
+
+	if users.users[us.Id].Password != us.Password {
+		t.Error("password of the verified user must not be changed")
+	}
+}
+
+func TestSSOService_LoginTakesOverUnverifiedUser(t *testing.T) {
+	idp := newMockIdP(t)
+	service, users := ssoTestService(idp, false)
+
+	us := users.add("user@example.com", false)
+	users.sessions[us.Id] = 2
+
+	result := idp.login(t, service, 0, "sub-1", "user@example.com", true)
+	if result.UserId != us.Id {
+		t.Fatalf("identity must be linked to user %d, got: %d", us.Id, result.UserId)
+	}
+
+	taken := users.users[us.Id]
+	if taken.Password == us.Password || !taken.EmailVerifiedAt.Valid || users.sessions[us.Id] != 0 {
+		t.Errorf("password and sessions of the unverified user must be dropped, got: %+v", taken)
+	}
+}
+
+func TestSSOService_LoginRejected(t *testing.T) {
+	idp := newMockIdP(t)
+	service, _ := ssoTestService(idp, false)
+
+	_, err := idp.callback(t, service, 0, "sub-1", "user@example.com", false)
+	if !errors.Is(err, ErrEmailNotVerified) {
+		t.Errorf("unverified email must return ErrEmailNotVerified, got: %v", err)
+	}
+
+	_, err = idp.callback(t, service, 0, "sub-1", "user@example.com", true)
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("unknown user without provisioning must return ErrUserNotFound, got: %v", err)
+	}
+}
+
+func TestSSOService_CallbackInvalidState(t *testing.T) {
+	idp := newMockIdP(t)
+	service, _ := ssoTestService(idp, true)
+
+	authURL, cookie, err := service.AuthURL("mock", 0)
+	if err != nil {
+		t.Fatalf("error while create auth url: %v", err)
+	}
+
+	code, stateParam := idp.authorize(t, authURL, "sub-1", "user@example.com", true)
+
+	for name, tc := range map[string][2]string{
+		"wrong state":    {"wrong", cookie},
+		"forged cookie":  {stateParam, cookie + "x"},
+		"missing cookie": {stateParam, ""},
+	} {
+		_, err = service.Callback(context.Background(), "mock", code, tc[0], tc[1])
+		if !errors.Is(err, ErrInvalidState) {
+			t.Errorf("%s must return ErrInvalidState, got: %v", name, err)
+		}
+	}
+
+	_, err = service.Callback(context.Background(), "unknown", code, stateParam, cookie)
+	if !errors.Is(err, ErrUnknownProvider) {
+		t.Errorf("unknown provider must return ErrUnknownProvider, got: %v", err)
+	}
+
+	// the state of another login has a different PKCE verifier, the provider must refuse the code
+	_, otherCookie, err := service.AuthURL("mock", 0)
+	if err != nil {
+		t.Fatalf("error while create auth url: %v", err)
+	}
+
+	other, _ := service.decodeState(otherCookie)
+	other.State = stateParam
+	otherCookie, _ = service.encodeState(other)
+
+	_, err = service.Callback(context.Background(), "mock", code, stateParam, otherCookie)
+	if err == nil {
+		t.Error("code exchanged with another verifier must fail")
+	}
+}
+
+func TestSSOService_LinkAndUnlink(t *testing.T) {
+	idp := newMockIdP(t)
+	service, users := ssoTestService(idp, false)
+
+	us := users.add("user@example.com", true)
+	another := users.add("another@example.com", true)
+
+	result := idp.login(t, service, us.Id, "sub-1", "work@company.com", false)
+	if result.UserId != us.Id || !result.Linked {
+		t.Fatalf("provider must be linked to user %d, got: %+v", us.Id, result)
+	}
+
+	_, err := idp.callback(t, service, another.Id, "sub-1", "work@company.com", true)
+	if !errors.Is(err, ErrIdentityLinked) {
+		t.Errorf("identity of another user must return ErrIdentityLinked, got: %v", err)
+	}
+
+	if err := service.SetPasswordLogin(context.Background(), another.Id, false); !errors.Is(err, ErrNoIdentity) {
+		t.Errorf("password login can't be disabled without identities, got: %v", err)
+	}
+
+	if err := service.SetPasswordLogin(context.Background(), us.Id, false); err != nil {
+		t.Fatalf("error while disable password login: %v", err)
+	}
+
+	identities, err := service.Identities(context.Background(), us.Id)
+	if err != nil || len(identities) != 1 {
+		t.Fatalf("user must have 1 identity, got: %v, %v", identities, err)
+	}
+
+	if err := service.Unlink(context.Background(), us.Id, identities[0].Id); !errors.Is(err, ErrLastLoginMethod) {
+		t.Errorf("last identity can't be unlinked, got: %v", err)
+	}
+
+	if err := service.SetPasswordLogin(context.Background(), us.Id, true); err != nil {
+		t.Fatalf("error while enable password login: %v", err)
+	}
+
+	if err := service.Unlink(context.Background(), another.Id, identities[0].Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("identity of another user must return ErrNotFound, got: %v", err)
+	}
+
+	if err := service.Unlink(context.Background(), us.Id, identities[0].Id); err != nil {
+		t.Errorf("error while unlink identity: %v", err)
+	}
+}
+
+func TestSSOService_Reauthenticate(t *testing.T) {
+	idp := newMockIdP(t)
+	service, users := ssoTestService(idp, true)
+
+	// the user provisioned by the provider doesn't know the password
+	result := idp.login(t, service, 0, "sub-1", "user@example.com", true)
+	another := users.add("another@example.com", true)
+
+	reauthenticate := func(userId int64, subject string) (Result, error) {
+		authURL, cookie, err := service.ReauthURL("mock", userId)
+		if err != nil {
+			t.Fatalf("error while create auth url: %v", err)
+		}
+
+		code, stateParam := idp.authorize(t, authURL, subject, "user@example.com", true)
+
+		return service.Callback(context.Background(), "mock", code, stateParam, cookie)
+	}
+
+	reauth, err := reauthenticate(result.UserId, "sub-1")
+	if err != nil || !reauth.Reauthenticated || reauth.UserId != result.UserId {
+		t.Fatalf("user must sign in with the linked provider again, got: %+v %v", reauth, err)
+	}
+
+	if _, err := reauthenticate(another.Id, "sub-1"); !errors.Is(err, ErrIdentityLinked) {
+		t.Errorf("identity of another user must return ErrIdentityLinked, got: %v", err)
+	}
+
+	if _, err := reauthenticate(result.UserId, "sub-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("identity which isn't linked must return ErrNotFound, got: %v", err)
+	}
+
+	token, expiresAt := service.ReauthToken(result.UserId)
+	if !service.Reauthenticated(token, result.UserId) || time.Until(expiresAt) > reauthTTL {
+		t.Errorf("token must prove the sign in until it expires, got: %q %v", token, expiresAt)
+	}
+
+	if service.Reauthenticated(token, another.Id) || service.Reauthenticated(token+"x", result.UserId) {
+		t.Error("token must not prove the sign in of another user or be changed")
+	}
+
+	expired := fmt.Sprintf("reauth:%d:%d", result.UserId, time.Now().Add(-time.Second).Unix())
+	if service.Reauthenticated(expired+"."+service.sign(expired), result.UserId) {
+		t.Error("expired token must not prove the sign in")
+	}
+}
+
+func ssoTestService(idp *mockIdP, autoProvision bool) (*Service, *memoryUserService) {
+	users := &memoryUserService{users: map[int64]user.User{}, sessions: map[int64]int{}}
+
+	service := NewService(
+		&Config{
+			Providers: []Provider{{
+				Name:         "mock",
+				Issuer:       idp.server.URL,
+				ClientId:     "client-id",
+				ClientSecret: "client-secret",
+			}},
+			CallbackURL:   "http://localhost/api/auth/oidc",
+			AutoProvision: autoProvision,
+			Secret:        "test-secret",
+			StateTTL:      time.Minute,
+		},
+		&memoryIdentityRepository{identities: map[int64]useridentity.Identity{}},
+		users,
+		users,
+	)
+
+	return service, users
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("error while generate key: %v", err)
+	}
+
+	idp := &mockIdP{key: key, codes: map[string]authorization{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"alg": "RS256",
+			"use": "sig",
+			"kid": "test",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	return idp
+}
+
+// authorize plays the user consenting at the provider and returns the code and state of the redirect
+func (idp *mockIdP) authorize(t *testing.T, authURL, subject, email string, emailVerified bool) (string, string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatalf("error while parse auth url: %v", err)
+	}
+
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		t.Fatalf("auth url must use PKCE S256, got: %s", authURL)
+	}
+
+	if q.Get("redirect_uri") != "http://localhost/api/auth/oidc/mock/callback" {
+		t.Errorf("unexpected redirect_uri: %s", q.Get("redirect_uri"))
+	}
+
+	code := randomString()
+
+	idp.mu.Lock()
+	idp.codes[code] = authorization{
+		challenge:     q.Get("code_challenge"),
+		nonce:         q.Get("nonce"),
+		subject:       subject,
+		email:         email,
+		emailVerified: emailVerified,
+	}
+	idp.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+func (idp *mockIdP) callback(
+	t *testing.T,
+	service *Service,
+	userId int64,
+	subject, email string,
+	emailVerified bool,
+) (Result, error) {
+	t.Helper()
+
+	authURL, cookie, err := service.AuthURL("mock", userId)
+	if err != nil {
+		t.Fatalf("error while create auth url: %v", err)
+	}
+
+	code, stateParam := idp.authorize(t, authURL, subject, email, emailVerified)
+
+	return service.Callback(context.Background(), "mock", code, stateParam, cookie)
+}
+
+func (idp *mockIdP) login(
+	t *testing.T,
+	service *Service,
+	userId int64,
+	subject, email string,
+	emailVerified bool,
+) Result {
+	t.Helper()
+
+	result, err := idp.callback(t, service, userId, subject, email, emailVerified)
+	if err != nil {
+		t.Fatalf("error while login: %v", err)
+	}
+
+	return result
+}
+
+func (idp *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("grant_type") != "authorization_code" {
+		http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+
+		return
+	}
+
+	clientId, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientId, clientSecret = r.Form.Get("client_id"), r.Form.Get("client_secret")
+	}
+
+	idp.mu.Lock()
+	a, ok := idp.codes[r.Form.Get("code")]
+	delete(idp.codes, r.Form.Get("code"))
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || clientId != "client-id" || clientSecret != "client-secret" ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != a.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"error":"invalid_grant"}`))
+
+		return
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            idp.server.URL,
+		"aud":            "client-id",
+		"sub":            a.subject,
+		"email":          a.email,
+		"email_verified": a.emailVerified,
+		"nonce":          a.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	})
+	token.Header["kid"] = "test"
+
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	writeJSON(w, map[string]any{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     idToken,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (r *memoryIdentityRepository) Create(identity useridentity.Identity) (useridentity.Identity, error) {
+	for _, i := range r.identities {
+		if (i.Provider == identity.Provider && i.Subject == identity.Subject) ||
+			(i.UserId == identity.UserId && i.Provider == identity.Provider) {
+			return useridentity.Identity{}, storage.ErrDuplicateNotAllowed
+		}
+	}
+
+	r.lastId++
+	identity.Id = r.lastId
+	r.identities[identity.Id] = identity
+
+	return identity, nil
+}
+
+func (r *memoryIdentityRepository) ByProviderSubject(provider, subject string) (useridentity.Identity, error) {
+	for _, i := range r.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+
+	return useridentity.Identity{}, storage.ErrNotFound
+}
+
+func (r *memoryIdentityRepository) ByUserId(userId int64) ([]useridentity.Identity, error) {
+	var identities []useridentity.Identity
+	for _, i := range r.identities {
+		if i.UserId == userId {
+			identities = append(identities, i)
+		}
+	}
+
+	return identities, nil
+}
+
+func (r *memoryIdentityRepository) Delete(userId, identityId int64) error {
+	i, ok := r.identities[identityId]
+	if !ok || i.UserId != userId {
+		return storage.ErrNotFound
+	}
+
+	delete(r.identities, identityId)
+
+	return nil
+}
+
+func (s *memoryUserService) add(email string, verified bool) user.User {
+	s.lastId++
+	us := user.User{
+		Id:              s.lastId,
+		Email:           sql.NullString{String: email, Valid: true},
+		Password:        "hash-" + email,
+		EmailVerifiedAt: sql.NullString{String: time.Now().Format(time.DateTime), Valid: verified},
+	}
+	s.users[us.Id] = us
+
+	return us
+}
+
+func (s *memoryUserService) CreateUser(_ context.Context, us userservice.User) (user.User, error) {
+	if _, err := s.UserByEmail(context.Background(), us.Email); err == nil {
+		return user.User{}, userservice.ErrAlreadyExists
+	}
+
+	return s.add(us.Email, false), nil
+}
+
+func (s *memoryUserService) UserByEmail(_ context.Context, email string) (user.User, error) {
+	for _, us := range s.users {
+		if us.Email.String == email {
+			return us, nil
+		}
+	}
+
+	return user.User{}, userservice.ErrNotFound
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	us, ok := s.users[userId]
+	if !ok {
+		return user.User{}, userservice.ErrNotFound
+	}
+
+	return us, nil
+}
+
+func (s *memoryUserService) VerifyEmail(_ context.Context, userId int64) error {
+	us := s.users[userId]
+	us.EmailVerifiedAt = sql.NullString{String: time.Now().Format(time.DateTime), Valid: true}
+	s.users[userId] = us
+
+	return nil
+}
+
+func (s *memoryUserService) UpdatePassword(_ context.Context, userId int64, newPassword string) error {
+	us := s.users[userId]
+	us.Password = "hash-" + newPassword
+	s.users[userId] = us
+
+	return nil
+}
+
+func (s *memoryUserService) SetPasswordLoginDisabled(_ context.Context, userId int64, disabled bool) error {
+	us := s.users[userId]
+	us.PasswordLoginDisabled = disabled
+	s.users[userId] = us
+
+	return nil
+}
+
+func (s *memoryUserService) DeleteUserSessions(_ context.Context, userId int64) error {
+	s.sessions[userId] = 0
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package sso
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// state is kept in a signed cookie between the redirect to the provider and the callback,
+// so nothing is stored on the server for unfinished logins
+type state struct {
+	Provider  string `json:"p"`
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	UserId    int64  `json:"u"`           // not 0 when the provider is being linked to the user
+	Reauth    bool   `json:"r,omitempty"` // the signed-in user confirms an action with the linked provider
+	ExpiresAt int64  `json:"e"`
+}
+
+// reauthTTL is how long the sign in with the provider confirms actions instead of the password
+const reauthTTL = 5 * time.Minute
+
+func (s *Service) encodeState(st state) (string, error) {
+	b, err := json.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(b)
+
+	return value + "." + s.sign(value), nil
+}
+
+func (s *Service) decodeState(cookie string) (state, error) {
+	value, signature, ok := strings.Cut(cookie, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(value))) {
+		return state{}, ErrInvalidState
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return state{}, ErrInvalidState
+	}
+
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return state{}, ErrInvalidState
+	}
+
+	if time.Now().Unix() > st.ExpiresAt {
+		return state{}, ErrInvalidState
+	}
+
+	return st, nil
+}
+
+// ReauthToken returns the signed proof that the user has just signed in with the linked provider and the time
+// it expires. Values of states are base64, so the proof is never taken for a state
+func (s *Service) ReauthToken(userId int64) (string, time.Time) {
+	expiresAt := time.Now().Add(reauthTTL)
+	value := fmt.Sprintf("reauth:%d:%d", userId, expiresAt.Unix())
+
+	return value + "." + s.sign(value), expiresAt
+}
+
+// Reauthenticated checks that the token is the unexpired proof of ReauthToken for the user
+func (s *Service) Reauthenticated(token string, userId int64) bool {
+	value, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(s.sign(value))) {
+		return false
+	}
+
+	var id, expires int64
+	if _, err := fmt.Sscanf(value, "reauth:%d:%d", &id, &expires); err != nil {
+		return false
+	}
+
+	return id == userId && time.Now().Unix() <= expires
+}
+
+func (s *Service) sign(value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte("sso:" + value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
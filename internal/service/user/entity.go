@@ -1,5 +1,10 @@
 package user
 
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
 type User struct {
 	Email    string
 	Password string
@@ -1,9 +1,11 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/email"
 	"github.com/albakov/go-cloud-file-storage/internal/service/password"
 	"github.com/albakov/go-cloud-file-storage/internal/storage"
 	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
@@ -12,6 +14,7 @@ import (
 var (
 	ErrNotFound      = errors.New("user not found")
 	ErrAlreadyExists = errors.New("user already exists")
+	ErrInvalidRole   = errors.New("user role invalid")
 )
 
 type Service struct {
@@ -20,10 +23,21 @@ type Service struct {
 }
 
 type Repository interface {
-	Create(user user.User) (user.User, error)
-	IsExistsByEmail(email string) bool
-	ByEmail(email string) (user.User, error)
-	ById(userId int64) (user.User, error)
+	Create(ctx context.Context, user user.User) (user.User, error)
+	IsExistsByEmail(ctx context.Context, email string) bool
+	ByEmail(ctx context.Context, email string) (user.User, error)
+	ById(ctx context.Context, userId int64) (user.User, error)
+	VerifyEmail(ctx context.Context, userId int64) error
+	UpdatePassword(ctx context.Context, userId int64, password string) error
+	UpdateEmail(ctx context.Context, userId int64, email string) error
+	SetPasswordLoginDisabled(ctx context.Context, userId int64, disabled bool) error
+	Search(ctx context.Context, query string, limit, offset int) ([]user.User, error)
+	Count(ctx context.Context, query string) (int64, error)
+	SetRole(ctx context.Context, userId int64, role string) error
+	SetRoleByEmail(ctx context.Context, email, role string) error
+	SetQuota(ctx context.Context, userId int64, quotaBytes sql.NullInt64) error
+	SetDisabled(ctx context.Context, userId int64, disabled bool) error
+	Delete(ctx context.Context, userId int64) error
 }
 
 func NewService(userRepo Repository) *Service {
@@ -33,10 +47,10 @@ func NewService(userRepo Repository) *Service {
 	}
 }
 
-func (s *Service) CreateUser(us User) (user.User, error) {
+func (s *Service) CreateUser(ctx context.Context, us User) (user.User, error) {
 	const op = "CreateUser"
 
-	if s.userRepo.IsExistsByEmail(us.Email) {
+	if s.userRepo.IsExistsByEmail(ctx, us.Email) {
 		return user.User{}, ErrAlreadyExists
 	}
 
@@ -53,7 +67,7 @@ func (s *Service) CreateUser(us User) (user.User, error) {
 		Password: hashedPassword,
 	}
 
-	u, err = s.userRepo.Create(u)
+	u, err = s.userRepo.Create(ctx, u)
 	if err != nil {
 		if errors.Is(err, storage.ErrDuplicateNotAllowed) {
 			return user.User{}, ErrAlreadyExists
@@ -65,10 +79,10 @@ func (s *Service) CreateUser(us User) (user.User, error) {
 	return u, nil
 }
 
-func (s *Service) UserByEmail(email string) (user.User, error) {
+func (s *Service) UserByEmail(ctx context.Context, email string) (user.User, error) {
 	const op = "UserEmail"
 
-	u, err := s.userRepo.ByEmail(email)
+	u, err := s.userRepo.ByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			return user.User{}, ErrNotFound
@@ -80,10 +94,10 @@ func (s *Service) UserByEmail(email string) (user.User, error) {
 	return u, nil
 }
 
-func (s *Service) UserById(userId int64) (user.User, error) {
+func (s *Service) UserById(ctx context.Context, userId int64) (user.User, error) {
 	const op = "UserById"
 
-	u, err := s.userRepo.ById(userId)
+	u, err := s.userRepo.ById(ctx, userId)
 	if err != nil {
 		if errors.Is(err, storage.ErrNotFound) {
 			return user.User{}, ErrNotFound
@@ -94,3 +108,142 @@ func (s *Service) UserById(userId int64) (user.User, error) {
 
 	return u, nil
 }
+
+func (s *Service) VerifyEmail(ctx context.Context, userId int64) error {
+	const op = "VerifyEmail"
+
+	err := s.userRepo.VerifyEmail(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) UpdatePassword(ctx context.Context, userId int64, newPassword string) error {
+	const op = "UpdatePassword"
+
+	hashedPassword, err := password.CreateHashedPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	err = s.userRepo.UpdatePassword(ctx, userId, hashedPassword)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateEmail(ctx context.Context, userId int64, email string) error {
+	const op = "UpdateEmail"
+
+	err := s.userRepo.UpdateEmail(ctx, userId, email)
+	if err != nil {
+		if errors.Is(err, storage.ErrDuplicateNotAllowed) {
+			return ErrAlreadyExists
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// SetPasswordLoginDisabled turns off (or back on) sign in with email and password for the user
+func (s *Service) SetPasswordLoginDisabled(ctx context.Context, userId int64, disabled bool) error {
+	const op = "SetPasswordLoginDisabled"
+
+	err := s.userRepo.SetPasswordLoginDisabled(ctx, userId, disabled)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Users returns a page of users whose email contains the query and the total number of them
+func (s *Service) Users(ctx context.Context, query string, limit, offset int) ([]user.User, int64, error) {
+	const op = "Users"
+
+	users, err := s.userRepo.Search(ctx, query, limit, offset)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	total, err := s.userRepo.Count(ctx, query)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	return users, total, nil
+}
+
+func (s *Service) SetRole(ctx context.Context, userId int64, role string) error {
+	const op = "SetRole"
+
+	if role != RoleUser && role != RoleAdmin {
+		return ErrInvalidRole
+	}
+
+	err := s.userRepo.SetRole(ctx, userId, role)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// PromoteAdmins grants the admin role to existing users with the emails, only verified emails are promoted
+func (s *Service) PromoteAdmins(ctx context.Context, emails []string) error {
+	const op = "PromoteAdmins"
+
+	for _, address := range emails {
+		address = email.Normalize(address)
+		if address == "" {
+			continue
+		}
+
+		err := s.userRepo.SetRoleByEmail(ctx, address, RoleAdmin)
+		if err != nil {
+			return logger.Error(s.pkg, op, err)
+		}
+	}
+
+	return nil
+}
+
+// SetQuota sets storage quota in bytes, negative quota resets it to the default one
+func (s *Service) SetQuota(ctx context.Context, userId int64, quotaBytes int64) error {
+	const op = "SetQuota"
+
+	err := s.userRepo.SetQuota(ctx, userId, sql.NullInt64{Int64: quotaBytes, Valid: quotaBytes >= 0})
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) SetDisabled(ctx context.Context, userId int64, disabled bool) error {
+	const op = "SetDisabled"
+
+	err := s.userRepo.SetDisabled(ctx, userId, disabled)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) DeleteUser(ctx context.Context, userId int64) error {
+	const op = "DeleteUser"
+
+	err := s.userRepo.Delete(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
@@ -1,9 +1,12 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/password"
 	usersessionservice "github.com/albakov/go-cloud-file-storage/internal/service/usersession"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
 	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
 	"github.com/albakov/go-cloud-file-storage/internal/storage/usersession"
 	"github.com/albakov/go-cloud-file-storage/internal/testutil"
@@ -13,165 +16,300 @@ import (
 
 type testService struct {
 	service *Service
-	db      *sql.DB
+	db      *storage.DB
 }
 
 func TestUserService_CreateUser(t *testing.T) {
-	userService := userTestService(t)
-	defer func(db *sql.DB) {
-		err := db.Close()
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		userEntity := User{
+			Email:    "test@example.ru",
+			Password: "1234",
+		}
+
+		u1, err := userService.service.CreateUser(context.Background(), userEntity)
 		if err != nil {
-			panic(err)
+			t.Errorf("error while create new user: %v", err)
 		}
-	}(userService.db)
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
 
-	userEntity := User{
-		Email:    "test@example.ru",
-		Password: "1234",
-	}
+		if u1.Email.String != userEntity.Email {
+			t.Errorf("email does not match")
+		}
+	})
+}
 
-	u1, err := userService.service.CreateUser(userEntity)
-	if err != nil {
-		t.Errorf("error while create new user: %v", err)
-	}
-	defer func(db *sql.DB, userId int64) {
-		err := deleteTestUser(db, userId)
+func TestUserService_CreateUserDuplicate(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		userEntity := User{
+			Email:    "test@example.ru",
+			Password: "1234",
+		}
+
+		u1, err := userService.service.CreateUser(context.Background(), userEntity)
 		if err != nil {
-			t.Errorf("error while delete test user: %v", err)
+			t.Errorf("error while create new user: %v", err)
 		}
-	}(userService.db, u1.Id)
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
 
-	if u1.Email.String != userEntity.Email {
-		t.Errorf("email does not match")
-	}
+		// check when trying to create duplicate user
+		u2, err := userService.service.CreateUser(context.Background(), userEntity)
+		if err != nil {
+			if !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("error while create duplicate user: %v", err)
+			}
+		} else {
+			deleteTestUser(userService.db, u2.Id)
+			t.Error("create duplicate user not allowed")
+		}
+	})
 }
 
-func TestUserService_CreateUserDuplicate(t *testing.T) {
-	userService := userTestService(t)
-	defer func(db *sql.DB) {
-		err := db.Close()
+func TestUserService_UserByRefreshToken(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		u1, err := userService.service.CreateUser(context.Background(), User{
+			Email:    "test@example.ru",
+			Password: "1234",
+		})
 		if err != nil {
-			panic(err)
+			t.Errorf("error while create new user: %v", err)
 		}
-	}(userService.db)
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
 
-	userEntity := User{
-		Email:    "test@example.ru",
-		Password: "1234",
-	}
+		refreshToken := "1234"
 
-	u1, err := userService.service.CreateUser(userEntity)
-	if err != nil {
-		t.Errorf("error while create new user: %v", err)
-	}
-	defer func(db *sql.DB, userId int64) {
-		err := deleteTestUser(db, userId)
+		userSessionRepo := usersession.NewRepository(userService.db)
+		userSessionService := usersessionservice.NewService(userSessionRepo)
+
+		userSession, err := userSessionService.CreateUserSession(context.Background(), usersessionservice.UserSession{
+			UserId:       u1.Id,
+			RefreshToken: refreshToken,
+			ExpiredAt:    time.Now().Add(time.Hour * 24).Format(time.DateTime),
+		})
 		if err != nil {
-			t.Errorf("error while delete test user: %v", err)
+			t.Errorf("error while create new user session: %v", err)
 		}
-	}(userService.db, u1.Id)
+		defer func(db *storage.DB, userSessionId int64) {
+			err := deleteTestUserSession(db, userSessionId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, userSession.Id)
 
-	// check when trying to create duplicate user
-	u2, err := userService.service.CreateUser(userEntity)
-	if err != nil {
-		if !errors.Is(err, ErrAlreadyExists) {
-			t.Errorf("error while create duplicate user: %v", err)
+		u2, err := userSessionService.ValidUserSessionByRefreshToken(context.Background(), refreshToken)
+		if err != nil {
+			t.Errorf("error while get user by refresh token: %v", err)
 		}
-	} else {
-		deleteTestUser(userService.db, u2.Id)
-		t.Error("create duplicate user not allowed")
-	}
+
+		if u2.UserId != u1.Id {
+			t.Errorf("user by refresh token not match")
+		}
+	})
 }
 
-func TestUserService_UserByRefreshToken(t *testing.T) {
-	userService := userTestService(t)
-	defer func(db *sql.DB) {
-		err := db.Close()
+func TestUserService_UserByEmail(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		email := "test@example.ru"
+
+		u1, err := userService.service.CreateUser(context.Background(), User{
+			Email:    email,
+			Password: "1234",
+		})
+		if err != nil {
+			t.Errorf("error while create new user: %v", err)
+		}
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
+
+		u2, err := userService.service.UserByEmail(context.Background(), email)
 		if err != nil {
-			panic(err)
+			t.Errorf("error while get user by email: %v", err)
 		}
-	}(userService.db)
 
-	u1, err := userService.service.CreateUser(User{
-		Email:    "test@example.ru",
-		Password: "1234",
+		if u2.Id != u1.Id {
+			t.Errorf("user by email not match")
+		}
 	})
-	if err != nil {
-		t.Errorf("error while create new user: %v", err)
-	}
-	defer func(db *sql.DB, userId int64) {
-		err := deleteTestUser(db, userId)
+}
+
+func TestUserService_UpdatePassword(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		u1, err := userService.service.CreateUser(context.Background(), User{
+			Email:    "test@example.ru",
+			Password: "1234",
+		})
 		if err != nil {
-			t.Errorf("error while delete test user: %v", err)
+			t.Errorf("error while create new user: %v", err)
 		}
-	}(userService.db, u1.Id)
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
 
-	refreshToken := "1234"
+		err = userService.service.UpdatePassword(context.Background(), u1.Id, "5678")
+		if err != nil {
+			t.Errorf("error while update password: %v", err)
+		}
 
-	userSessionRepo := usersession.NewRepository(userService.db)
-	userSessionService := usersessionservice.NewService(userSessionRepo)
+		u2, err := userService.service.UserById(context.Background(), u1.Id)
+		if err != nil {
+			t.Errorf("error while get user by id: %v", err)
+		}
 
-	userSession, err := userSessionService.CreateUserSession(usersessionservice.UserSession{
-		UserId:       u1.Id,
-		RefreshToken: refreshToken,
-		ExpiredAt:    time.Now().Add(time.Hour * 24).Format(time.DateTime),
+		if !password.CheckPassword("5678", u2.Password) {
+			t.Errorf("password is not updated")
+		}
 	})
-	if err != nil {
-		t.Errorf("error while create new user session: %v", err)
-	}
-	defer func(db *sql.DB, userSessionId int64) {
-		err := deleteTestUserSession(db, userSessionId)
+}
+
+func TestUserService_UpdateEmailDuplicate(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		u1, err := userService.service.CreateUser(context.Background(), User{
+			Email:    "test@example.ru",
+			Password: "1234",
+		})
 		if err != nil {
-			t.Errorf("error while delete test user: %v", err)
+			t.Errorf("error while create new user: %v", err)
 		}
-	}(userService.db, userSession.Id)
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
 
-	u2, err := userSessionService.ValidUserSessionByRefreshToken(refreshToken)
-	if err != nil {
-		t.Errorf("error while get user by refresh token: %v", err)
-	}
+		u2, err := userService.service.CreateUser(context.Background(), User{
+			Email:    "test2@example.ru",
+			Password: "1234",
+		})
+		if err != nil {
+			t.Errorf("error while create new user: %v", err)
+		}
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u2.Id)
 
-	if u2.UserId != u1.Id {
-		t.Errorf("user by refresh token not match")
-	}
+		err = userService.service.UpdateEmail(context.Background(), u2.Id, u1.Email.String)
+		if !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("update to existing email must return ErrAlreadyExists, got: %v", err)
+		}
+	})
 }
 
-func TestUserService_UserByEmail(t *testing.T) {
-	userService := userTestService(t)
-	defer func(db *sql.DB) {
-		err := db.Close()
+func TestUserService_DeleteUser(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		u1, err := userService.service.CreateUser(context.Background(), User{
+			Email:    "test@example.ru",
+			Password: "1234",
+		})
 		if err != nil {
-			panic(err)
+			t.Errorf("error while create new user: %v", err)
 		}
-	}(userService.db)
 
-	email := "test@example.ru"
+		err = userService.service.DeleteUser(context.Background(), u1.Id)
+		if err != nil {
+			t.Errorf("error while delete user: %v", err)
+		}
 
-	u1, err := userService.service.CreateUser(User{
-		Email:    email,
-		Password: "1234",
+		_, err = userService.service.UserById(context.Background(), u1.Id)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("deleted user must not be found, got: %v", err)
+		}
 	})
-	if err != nil {
-		t.Errorf("error while create new user: %v", err)
-	}
-	defer func(db *sql.DB, userId int64) {
-		err := deleteTestUser(db, userId)
+}
+
+func TestUserService_PromoteAdmins(t *testing.T) {
+	testutil.ForEachDatabase(t, func(t *testing.T, db *storage.DB) {
+		userService := userTestService(db)
+
+		u1, err := userService.service.CreateUser(context.Background(), User{
+			Email:    "admin@example.ru",
+			Password: "1234",
+		})
 		if err != nil {
-			t.Errorf("error while delete test user: %v", err)
+			t.Fatalf("error while create new user: %v", err)
 		}
-	}(userService.db, u1.Id)
+		defer func(db *storage.DB, userId int64) {
+			err := deleteTestUser(db, userId)
+			if err != nil {
+				t.Errorf("error while delete test user: %v", err)
+			}
+		}(userService.db, u1.Id)
 
-	u2, err := userService.service.UserByEmail(email)
-	if err != nil {
-		t.Errorf("error while get user by email: %v", err)
-	}
+		// anyone may sign up with the email before its owner
+		err = userService.service.PromoteAdmins(context.Background(), []string{"Admin@example.ru"})
+		if err != nil {
+			t.Fatalf("error while promote admins: %v", err)
+		}
 
-	if u2.Id != u1.Id {
-		t.Errorf("user by email not match")
-	}
+		u, err := userService.service.UserById(context.Background(), u1.Id)
+		if err != nil {
+			t.Fatalf("error while get user: %v", err)
+		}
+
+		if u.Role != RoleUser {
+			t.Errorf("user with unverified email must not be promoted, got role: %s", u.Role)
+		}
+
+		err = userService.service.VerifyEmail(context.Background(), u1.Id)
+		if err != nil {
+			t.Fatalf("error while verify email: %v", err)
+		}
+
+		err = userService.service.PromoteAdmins(context.Background(), []string{"Admin@example.ru"})
+		if err != nil {
+			t.Fatalf("error while promote admins: %v", err)
+		}
+
+		u, err = userService.service.UserById(context.Background(), u1.Id)
+		if err != nil {
+			t.Fatalf("error while get user: %v", err)
+		}
+
+		if u.Role != RoleAdmin {
+			t.Errorf("user with verified email must be promoted, got role: %s", u.Role)
+		}
+	})
 }
 
-func deleteTestUser(db *sql.DB, userId int64) error {
+func deleteTestUser(db *storage.DB, userId int64) error {
 	stmt, err := db.Prepare("DELETE FROM users WHERE id = ?")
 	if err != nil {
 		return err
@@ -187,7 +325,7 @@ func deleteTestUser(db *sql.DB, userId int64) error {
 	return err
 }
 
-func deleteTestUserSession(db *sql.DB, userSessionId int64) error {
+func deleteTestUserSession(db *storage.DB, userSessionId int64) error {
 	stmt, err := db.Prepare("DELETE FROM users_sessions WHERE id = ?")
 	if err != nil {
 		return err
@@ -203,12 +341,7 @@ func deleteTestUserSession(db *sql.DB, userSessionId int64) error {
 	return err
 }
 
-func userTestService(t *testing.T) *testService {
-	db, err := testutil.DbTest()
-	if err != nil {
-		t.Fatal(err)
-	}
-
+func userTestService(db *storage.DB) *testService {
 	return &testService{
 		service: NewService(user.NewRepository(db)),
 		db:      db,
@@ -1,6 +1,7 @@
 package usersession
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
@@ -21,9 +22,11 @@ type Service struct {
 }
 
 type Repository interface {
-	ByRefreshToken(refreshToken string) (usersession.Session, error)
-	Create(userSession usersession.Session) (usersession.Session, error)
-	Delete(userId int64, refreshToken string) error
+	ByRefreshToken(ctx context.Context, refreshToken string) (usersession.Session, error)
+	Create(ctx context.Context, userSession usersession.Session) (usersession.Session, error)
+	Delete(ctx context.Context, userId int64, refreshToken string) error
+	DeleteAllByUserId(ctx context.Context, userId int64) error
+	DeleteAllByUserIdExcept(ctx context.Context, userId int64, refreshToken string) error
 }
 
 func NewService(userSessionRepo Repository) *Service {
@@ -33,10 +36,10 @@ func NewService(userSessionRepo Repository) *Service {
 	}
 }
 
-func (s *Service) ValidUserSessionByRefreshToken(refreshToken string) (usersession.Session, error) {
+func (s *Service) ValidUserSessionByRefreshToken(ctx context.Context, refreshToken string) (usersession.Session, error) {
 	const op = "ValidUserSessionByRefreshToken"
 
-	us, err := s.userSessionRepo.ByRefreshToken(refreshToken)
+	us, err := s.userSessionRepo.ByRefreshToken(ctx, refreshToken)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return usersession.Session{}, ErrNotFound
@@ -57,10 +60,10 @@ func (s *Service) ValidUserSessionByRefreshToken(refreshToken string) (usersessi
 	return us, nil
 }
 
-func (s *Service) CreateUserSession(userSessionEntity UserSession) (usersession.Session, error) {
+func (s *Service) CreateUserSession(ctx context.Context, userSessionEntity UserSession) (usersession.Session, error) {
 	const op = "CreateUserSession"
 
-	us, err := s.userSessionRepo.Create(usersession.Session{
+	us, err := s.userSessionRepo.Create(ctx, usersession.Session{
 		UserId:       userSessionEntity.UserId,
 		RefreshToken: userSessionEntity.RefreshToken,
 		ExpiredAt:    userSessionEntity.ExpiredAt,
@@ -76,10 +79,34 @@ func (s *Service) CreateUserSession(userSessionEntity UserSession) (usersession.
 	return us, nil
 }
 
-func (s *Service) DeleteUserSession(userId int64, refreshToken string) error {
+func (s *Service) DeleteUserSession(ctx context.Context, userId int64, refreshToken string) error {
 	const op = "DeleteUserSession"
 
-	err := s.userSessionRepo.Delete(userId, refreshToken)
+	err := s.userSessionRepo.Delete(ctx, userId, refreshToken)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// DeleteUserSessions removes all sessions of the user (sign out everywhere)
+func (s *Service) DeleteUserSessions(ctx context.Context, userId int64) error {
+	const op = "DeleteUserSessions"
+
+	err := s.userSessionRepo.DeleteAllByUserId(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// DeleteOtherUserSessions removes all sessions of the user except the current one
+func (s *Service) DeleteOtherUserSessions(ctx context.Context, userId int64, refreshToken string) error {
+	const op = "DeleteOtherUserSessions"
+
+	err := s.userSessionRepo.DeleteAllByUserIdExcept(ctx, userId, refreshToken)
 	if err != nil {
 		return logger.Error(s.pkg, op, err)
 	}
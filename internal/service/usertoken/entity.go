@@ -0,0 +1,12 @@
+package usertoken
+
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+	PurposeEmailChange       = "email_change"
+	PurposeAccountUnlock     = "account_unlock"
+)
+
+type Config struct {
+	Secret string
+}
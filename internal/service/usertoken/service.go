@@ -0,0 +1,148 @@
+package usertoken
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usertoken"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("token invalid")
+	ErrExpired = errors.New("token expired")
+	ErrUsed    = errors.New("token already used")
+)
+
+type Service struct {
+	pkg           string
+	secret        []byte
+	userTokenRepo Repository
+}
+
+type Repository interface {
+	Create(token usertoken.Token) (usertoken.Token, error)
+	ByTokenHash(tokenHash string) (usertoken.Token, error)
+	MarkUsed(tokenId int64) error
+	DeleteUnusedByUserId(userId int64, purpose string) error
+}
+
+func NewService(conf *Config, userTokenRepo Repository) *Service {
+	return &Service{
+		pkg:           "usertoken.service",
+		secret:        []byte(conf.Secret),
+		userTokenRepo: userTokenRepo,
+	}
+}
+
+// CreateToken creates a new single-use token for the purpose. Previously issued unused tokens
+// of the same purpose are revoked. Only the hash of the token is stored.
+func (s *Service) CreateToken(userId int64, purpose string, ttl time.Duration) (string, error) {
+	return s.CreateTokenWithPayload(userId, purpose, "", ttl)
+}
+
+// CreateTokenWithPayload creates a token like CreateToken and stores the payload with it
+func (s *Service) CreateTokenWithPayload(userId int64, purpose, payload string, ttl time.Duration) (string, error) {
+	const op = "CreateTokenWithPayload"
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", logger.Error(s.pkg, op, err)
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(b)
+	token := value + "." + s.sign(purpose, value)
+
+	if err := s.userTokenRepo.DeleteUnusedByUserId(userId, purpose); err != nil {
+		return "", logger.Error(s.pkg, op, err)
+	}
+
+	_, err := s.userTokenRepo.Create(usertoken.Token{
+		UserId:    userId,
+		Purpose:   purpose,
+		TokenHash: s.hash(token),
+		ExpiredAt: time.Now().Add(ttl).Format(time.DateTime),
+		Payload:   sql.NullString{String: payload, Valid: payload != ""},
+	})
+	if err != nil {
+		return "", logger.Error(s.pkg, op, err)
+	}
+
+	return token, nil
+}
+
+// ConsumeToken validates the token and marks it as used
+func (s *Service) ConsumeToken(token, purpose string) (usertoken.Token, error) {
+	const op = "ConsumeToken"
+
+	if !s.isSigned(token, purpose) {
+		return usertoken.Token{}, ErrInvalid
+	}
+
+	t, err := s.userTokenRepo.ByTokenHash(s.hash(token))
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return usertoken.Token{}, ErrInvalid
+		}
+
+		return usertoken.Token{}, logger.Error(s.pkg, op, err)
+	}
+
+	if t.Purpose != purpose {
+		return usertoken.Token{}, ErrInvalid
+	}
+
+	if t.UsedAt.Valid {
+		return usertoken.Token{}, ErrUsed
+	}
+
+	expiresAt, err := time.ParseInLocation(time.DateTime, t.ExpiredAt, time.Local)
+	if err != nil {
+		return usertoken.Token{}, logger.Error(s.pkg, op, err)
+	}
+
+	if time.Now().After(expiresAt) {
+		return usertoken.Token{}, ErrExpired
+	}
+
+	err = s.userTokenRepo.MarkUsed(t.Id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return usertoken.Token{}, ErrUsed
+		}
+
+		return usertoken.Token{}, logger.Error(s.pkg, op, err)
+	}
+
+	return t, nil
+}
+
+// isSigned checks the signature, so forged tokens are rejected without a db query
+func (s *Service) isSigned(token, purpose string) bool {
+	value, signature, ok := strings.Cut(token, ".")
+	if !ok || value == "" {
+		return false
+	}
+
+	return hmac.Equal([]byte(signature), []byte(s.sign(purpose, value)))
+}
+
+func (s *Service) sign(purpose, value string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose + ":" + value))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Service) hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
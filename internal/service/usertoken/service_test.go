@@ -0,0 +1,162 @@
+package usertoken
+
+import (
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usertoken"
+	"testing"
+	"time"
+)
+
+type memoryRepository struct {
+	lastId int64
+	tokens map[int64]usertoken.Token
+}
+
+func TestUserTokenService_ConsumeToken(t *testing.T) {
+	service := userTokenTestService()
+
+	token, err := service.CreateToken(1, PurposeEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	ut, err := service.ConsumeToken(token, PurposeEmailVerification)
+	if err != nil {
+		t.Fatalf("error while consume token: %v", err)
+	}
+
+	if ut.UserId != 1 {
+		t.Errorf("user id must be 1, got: %d", ut.UserId)
+	}
+
+	// token is single-use
+	_, err = service.ConsumeToken(token, PurposeEmailVerification)
+	if !errors.Is(err, ErrUsed) {
+		t.Errorf("second consume must return ErrUsed, got: %v", err)
+	}
+}
+
+func TestUserTokenService_ConsumeTokenWithPayload(t *testing.T) {
+	service := userTokenTestService()
+
+	token, err := service.CreateTokenWithPayload(1, PurposeEmailChange, "new@example.ru", time.Hour)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	ut, err := service.ConsumeToken(token, PurposeEmailChange)
+	if err != nil {
+		t.Fatalf("error while consume token: %v", err)
+	}
+
+	if ut.Payload.String != "new@example.ru" {
+		t.Errorf("payload must be new@example.ru, got: %s", ut.Payload.String)
+	}
+}
+
+func TestUserTokenService_ConsumeTokenWrongPurpose(t *testing.T) {
+	service := userTokenTestService()
+
+	token, err := service.CreateToken(1, PurposeEmailVerification, time.Hour)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	_, err = service.ConsumeToken(token, PurposePasswordReset)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("token with another purpose must return ErrInvalid, got: %v", err)
+	}
+}
+
+func TestUserTokenService_ConsumeTokenForged(t *testing.T) {
+	service := userTokenTestService()
+
+	token, err := service.CreateToken(1, PurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	_, err = service.ConsumeToken(token+"x", PurposePasswordReset)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("forged token must return ErrInvalid, got: %v", err)
+	}
+}
+
+func TestUserTokenService_ConsumeTokenExpired(t *testing.T) {
+	service := userTokenTestService()
+
+	token, err := service.CreateToken(1, PurposePasswordReset, -time.Minute)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	_, err = service.ConsumeToken(token, PurposePasswordReset)
+	if !errors.Is(err, ErrExpired) {
+		t.Errorf("expired token must return ErrExpired, got: %v", err)
+	}
+}
+
+func TestUserTokenService_CreateTokenRevokesPrevious(t *testing.T) {
+	service := userTokenTestService()
+
+	token1, err := service.CreateToken(1, PurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	_, err = service.CreateToken(1, PurposePasswordReset, time.Hour)
+	if err != nil {
+		t.Fatalf("error while create token: %v", err)
+	}
+
+	_, err = service.ConsumeToken(token1, PurposePasswordReset)
+	if !errors.Is(err, ErrInvalid) {
+		t.Errorf("previous token must be revoked, got: %v", err)
+	}
+}
+
+func userTokenTestService() *Service {
+	return NewService(&Config{Secret: "test-secret"}, &memoryRepository{tokens: map[int64]usertoken.Token{}})
+}
+
+func (r *memoryRepository) Create(token usertoken.Token) (usertoken.Token, error) {
+	r.lastId++
+	token.Id = r.lastId
+	r.tokens[token.Id] = token
+
+	return token, nil
+}
+
+func (r *memoryRepository) ByTokenHash(tokenHash string) (usertoken.Token, error) {
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+
+	return usertoken.Token{}, storage.ErrNotFound
+}
+
+func (r *memoryRepository) MarkUsed(tokenId int64) error {
+	t, ok := r.tokens[tokenId]
+	if !ok || t.UsedAt.Valid {
+		return storage.ErrNotFound
+	}
+
+	t.UsedAt.String = time.Now().Format(time.DateTime)
+	t.UsedAt.Valid = true
+	r.tokens[tokenId] = t
+
+	return nil
+}
+
+func (r *memoryRepository) DeleteUnusedByUserId(userId int64, purpose string) error {
+	for id, t := range r.tokens {
+		if t.UserId == userId && t.Purpose == purpose && !t.UsedAt.Valid {
+			delete(r.tokens, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,10 @@
+package verification
+
+import "time"
+
+type Config struct {
+	AppURL               string // frontend url, used to build links in emails
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	AccountUnlockTTL     time.Duration
+}
@@ -0,0 +1,322 @@
+package verification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/mailer"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	usertokenservice "github.com/albakov/go-cloud-file-storage/internal/service/usertoken"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usertoken"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken    = errors.New("verification token invalid")
+	ErrAlreadyVerified = errors.New("email already verified")
+)
+
+type Service struct {
+	pkg                string
+	conf               *Config
+	mailer             mailer.Mailer
+	userTokenService   UserTokenService
+	userService        UserService
+	userSessionService UserSessionService
+}
+
+type UserTokenService interface {
+	CreateToken(userId int64, purpose string, ttl time.Duration) (string, error)
+	CreateTokenWithPayload(userId int64, purpose, payload string, ttl time.Duration) (string, error)
+	ConsumeToken(token, purpose string) (usertoken.Token, error)
+}
+
+type UserService interface {
+	UserByEmail(ctx context.Context, email string) (user.User, error)
+	UserById(ctx context.Context, userId int64) (user.User, error)
+	VerifyEmail(ctx context.Context, userId int64) error
+	UpdatePassword(ctx context.Context, userId int64, password string) error
+	UpdateEmail(ctx context.Context, userId int64, email string) error
+}
+
+type UserSessionService interface {
+	DeleteUserSessions(ctx context.Context, userId int64) error
+}
+
+func NewService(
+	conf *Config,
+	mailer mailer.Mailer,
+	userTokenService UserTokenService,
+	userService UserService,
+	userSessionService UserSessionService,
+) *Service {
+	return &Service{
+		pkg:                "verification.service",
+		conf:               conf,
+		mailer:             mailer,
+		userTokenService:   userTokenService,
+		userService:        userService,
+		userSessionService: userSessionService,
+	}
+}
+
+// SendEmailVerification sends a link to confirm the user's email
+func (s *Service) SendEmailVerification(ctx context.Context, userId int64) error {
+	const op = "SendEmailVerification"
+
+	us, err := s.userService.UserById(ctx, userId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	if us.EmailVerifiedAt.Valid {
+		return ErrAlreadyVerified
+	}
+
+	token, err := s.userTokenService.CreateToken(
+		us.Id,
+		usertokenservice.PurposeEmailVerification,
+		s.conf.EmailVerificationTTL,
+	)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      us.Email.String,
+		Subject: "Confirm your email",
+		Body: fmt.Sprintf(
+			"To confirm your email open the link:\n\n%s\n\nThe link expires in %s.",
+			s.link("/verify-email", token),
+			s.conf.EmailVerificationTTL,
+		),
+	})
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	const op = "VerifyEmail"
+
+	t, err := s.consumeToken(token, usertokenservice.PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	err = s.userService.VerifyEmail(ctx, t.UserId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// SendPasswordReset sends a link to reset the password. Unknown emails are ignored,
+// so the response doesn't reveal which emails are registered.
+func (s *Service) SendPasswordReset(ctx context.Context, email string) error {
+	const op = "SendPasswordReset"
+
+	us, err := s.userService.UserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, userservice.ErrNotFound) {
+			return nil
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	token, err := s.userTokenService.CreateToken(us.Id, usertokenservice.PurposePasswordReset, s.conf.PasswordResetTTL)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      us.Email.String,
+		Subject: "Password reset",
+		Body: fmt.Sprintf(
+			"To set a new password open the link:\n\n%s\n\nThe link expires in %s. "+
+				"If you didn't request a password reset, ignore this email.",
+			s.link("/reset-password", token),
+			s.conf.PasswordResetTTL,
+		),
+	})
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password and signs the user out of all sessions
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) error {
+	const op = "ResetPassword"
+
+	t, err := s.consumeToken(token, usertokenservice.PurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	err = s.userService.UpdatePassword(ctx, t.UserId, newPassword)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	// the owner of the email proved the access, so the email is verified too
+	err = s.userService.VerifyEmail(ctx, t.UserId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.userSessionService.DeleteUserSessions(ctx, t.UserId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// SendEmailChange sends a link to the new email. The email is changed only after the link is opened.
+func (s *Service) SendEmailChange(ctx context.Context, userId int64, newEmail string) error {
+	const op = "SendEmailChange"
+
+	token, err := s.userTokenService.CreateTokenWithPayload(
+		userId,
+		usertokenservice.PurposeEmailChange,
+		newEmail,
+		s.conf.EmailVerificationTTL,
+	)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      newEmail,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf(
+			"To use this email for your account open the link:\n\n%s\n\nThe link expires in %s.",
+			s.link("/confirm-email-change", token),
+			s.conf.EmailVerificationTTL,
+		),
+	})
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// ConfirmEmailChange sets the new email from the token and notifies the old email
+func (s *Service) ConfirmEmailChange(ctx context.Context, token string) error {
+	const op = "ConfirmEmailChange"
+
+	t, err := s.consumeToken(token, usertokenservice.PurposeEmailChange)
+	if err != nil {
+		return err
+	}
+
+	us, err := s.userService.UserById(ctx, t.UserId)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.userService.UpdateEmail(ctx, t.UserId, t.Payload.String)
+	if err != nil {
+		if errors.Is(err, userservice.ErrAlreadyExists) {
+			return err
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      us.Email.String,
+		Subject: "Your email was changed",
+		Body:    fmt.Sprintf("The email of your account was changed to %s.", t.Payload.String),
+	})
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// SendAccountUnlock sends a link to unlock the account locked after too many failed sign in attempts.
+// Unknown emails are ignored.
+func (s *Service) SendAccountUnlock(ctx context.Context, email string) error {
+	const op = "SendAccountUnlock"
+
+	us, err := s.userService.UserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, userservice.ErrNotFound) {
+			return nil
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	token, err := s.userTokenService.CreateToken(us.Id, usertokenservice.PurposeAccountUnlock, s.conf.AccountUnlockTTL)
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	err = s.mailer.Send(mailer.Message{
+		To:      us.Email.String,
+		Subject: "Your account was locked",
+		Body: fmt.Sprintf(
+			"Your account was temporarily locked after too many failed sign in attempts.\n"+
+				"To unlock it now open the link:\n\n%s\n\n"+
+				"If it wasn't you, consider changing your password.",
+			s.link("/unlock-account", token),
+		),
+	})
+	if err != nil {
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// UnlockAccount validates the unlock token and returns the email of the account to unlock
+func (s *Service) UnlockAccount(ctx context.Context, token string) (string, error) {
+	const op = "UnlockAccount"
+
+	t, err := s.consumeToken(token, usertokenservice.PurposeAccountUnlock)
+	if err != nil {
+		return "", err
+	}
+
+	us, err := s.userService.UserById(ctx, t.UserId)
+	if err != nil {
+		return "", logger.Error(s.pkg, op, err)
+	}
+
+	return us.Email.String, nil
+}
+
+func (s *Service) consumeToken(token, purpose string) (usertoken.Token, error) {
+	const op = "consumeToken"
+
+	t, err := s.userTokenService.ConsumeToken(token, purpose)
+	if err != nil {
+		if errors.Is(err, usertokenservice.ErrInvalid) ||
+			errors.Is(err, usertokenservice.ErrExpired) ||
+			errors.Is(err, usertokenservice.ErrUsed) {
+			return usertoken.Token{}, ErrInvalidToken
+		}
+
+		return usertoken.Token{}, logger.Error(s.pkg, op, err)
+	}
+
+	return t, nil
+}
+
+func (s *Service) link(path, token string) string {
+	return fmt.Sprintf("%s%s?token=%s", strings.TrimSuffix(s.conf.AppURL, "/"), path, url.QueryEscape(token))
+}
@@ -0,0 +1,368 @@
+package verification
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/service/mailer"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	usertokenservice "github.com/albakov/go-cloud-file-storage/internal/service/usertoken"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usertoken"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testAppURL = "https://storage.example.com"
+
+type memoryTokenRepository struct {
+	lastId int64
+	tokens map[int64]usertoken.Token
+}
+
+type memoryMailer struct {
+	messages []mailer.Message
+}
+
+type memoryUserService struct {
+	users     map[int64]user.User
+	passwords map[int64]string
+}
+
+type memorySessionService struct {
+	deleted []int64
+}
+
+type testService struct {
+	service  *Service
+	mailer   *memoryMailer
+	users    *memoryUserService
+	sessions *memorySessionService
+}
+
+func TestVerificationService_VerifyEmail(t *testing.T) {
+	ts := newService(time.Hour)
+
+	if err := ts.service.SendEmailVerification(context.Background(), 1); err != nil {
+		t.Fatalf("error while send email verification: %v", err)
+	}
+
+	token := ts.token(t, "alice@example.com", "/verify-email")
+
+	if err := ts.service.VerifyEmail(context.Background(), token); err != nil {
+		t.Fatalf("error while verify email: %v", err)
+	}
+
+	if !ts.users.users[1].EmailVerifiedAt.Valid {
+		t.Error("email must be verified")
+	}
+
+	if err := ts.service.VerifyEmail(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("used token must return ErrInvalidToken, got: %v", err)
+	}
+
+	err := ts.service.SendEmailVerification(context.Background(), 1)
+	if !errors.Is(err, ErrAlreadyVerified) {
+		t.Errorf("verified email must return ErrAlreadyVerified, got: %v", err)
+	}
+}
+
+func TestVerificationService_VerifyEmailExpired(t *testing.T) {
+	ts := newService(-time.Minute)
+
+	if err := ts.service.SendEmailVerification(context.Background(), 1); err != nil {
+		t.Fatalf("error while send email verification: %v", err)
+	}
+
+	err := ts.service.VerifyEmail(context.Background(), ts.token(t, "alice@example.com", "/verify-email"))
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expired token must return ErrInvalidToken, got: %v", err)
+	}
+
+	if ts.users.users[1].EmailVerifiedAt.Valid {
+		t.Error("email must not be verified with the expired token")
+	}
+}
+
+func TestVerificationService_ResetPassword(t *testing.T) {
+	ts := newService(time.Hour)
+
+	// unknown emails aren't revealed
+	if err := ts.service.SendPasswordReset(context.Background(), "unknown@example.com"); err != nil {
+		t.Fatalf("unknown email must not return error, got: %v", err)
+	}
+
+	if len(ts.mailer.messages) != 0 {
+		t.Fatalf("nothing must be sent to unknown email, got: %+v", ts.mailer.messages)
+	}
+
+	if err := ts.service.SendEmailVerification(context.Background(), 1); err != nil {
+		t.Fatalf("error while send email verification: %v", err)
+	}
+
+	verificationToken := ts.token(t, "alice@example.com", "/verify-email")
+
+	err := ts.service.ResetPassword(context.Background(), verificationToken, "new-password")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of another purpose must return ErrInvalidToken, got: %v", err)
+	}
+
+	if err := ts.service.SendPasswordReset(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("error while send password reset: %v", err)
+	}
+
+	token := ts.token(t, "alice@example.com", "/reset-password")
+
+	if err := ts.service.ResetPassword(context.Background(), token, "new-password"); err != nil {
+		t.Fatalf("error while reset password: %v", err)
+	}
+
+	if ts.users.passwords[1] != "new-password" {
+		t.Errorf("password must be updated, got: %q", ts.users.passwords[1])
+	}
+
+	if !slices.Equal(ts.sessions.deleted, []int64{1}) {
+		t.Errorf("sessions of the user must be revoked, got: %v", ts.sessions.deleted)
+	}
+
+	if !ts.users.users[1].EmailVerifiedAt.Valid {
+		t.Error("email must be verified by the password reset")
+	}
+
+	err = ts.service.ResetPassword(context.Background(), token, "another-password")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("used token must return ErrInvalidToken, got: %v", err)
+	}
+
+	if ts.users.passwords[1] != "new-password" {
+		t.Errorf("used token must not change the password, got: %q", ts.users.passwords[1])
+	}
+}
+
+func TestVerificationService_ConfirmEmailChange(t *testing.T) {
+	ts := newService(time.Hour)
+
+	if err := ts.service.SendEmailChange(context.Background(), 1, "alice@example.org"); err != nil {
+		t.Fatalf("error while send email change: %v", err)
+	}
+
+	// the link is sent to the new email, so its owner confirms it
+	token := ts.token(t, "alice@example.org", "/confirm-email-change")
+
+	if ts.users.users[1].Email.String != "alice@example.com" {
+		t.Fatal("email must not be changed before the confirmation")
+	}
+
+	if err := ts.service.ConfirmEmailChange(context.Background(), token); err != nil {
+		t.Fatalf("error while confirm email change: %v", err)
+	}
+
+	if ts.users.users[1].Email.String != "alice@example.org" {
+		t.Errorf("email must be changed, got: %s", ts.users.users[1].Email.String)
+	}
+
+	last := ts.mailer.messages[len(ts.mailer.messages)-1]
+	if last.To != "alice@example.com" || !strings.Contains(last.Body, "alice@example.org") {
+		t.Errorf("old email must be notified about the change, got: %+v", last)
+	}
+
+	if err := ts.service.ConfirmEmailChange(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("used token must return ErrInvalidToken, got: %v", err)
+	}
+
+	// the email may be taken by another user after the link is sent
+	if err := ts.service.SendEmailChange(context.Background(), 1, "bob@example.com"); err != nil {
+		t.Fatalf("error while send email change: %v", err)
+	}
+
+	err := ts.service.ConfirmEmailChange(context.Background(), ts.token(t, "bob@example.com", "/confirm-email-change"))
+	if !errors.Is(err, userservice.ErrAlreadyExists) {
+		t.Errorf("taken email must return ErrAlreadyExists, got: %v", err)
+	}
+}
+
+func TestVerificationService_UnlockAccount(t *testing.T) {
+	ts := newService(time.Hour)
+
+	if err := ts.service.SendAccountUnlock(context.Background(), "alice@example.com"); err != nil {
+		t.Fatalf("error while send account unlock: %v", err)
+	}
+
+	token := ts.token(t, "alice@example.com", "/unlock-account")
+
+	email, err := ts.service.UnlockAccount(context.Background(), token)
+	if err != nil {
+		t.Fatalf("error while unlock account: %v", err)
+	}
+
+	if email != "alice@example.com" {
+		t.Errorf("email of the account must be returned, got: %s", email)
+	}
+
+	if _, err := ts.service.UnlockAccount(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("used token must return ErrInvalidToken, got: %v", err)
+	}
+}
+
+// newService returns the service with the users alice and bob, email verification links expire in the ttl
+func newService(ttl time.Duration) *testService {
+	ts := &testService{
+		mailer: &memoryMailer{},
+		users: &memoryUserService{
+			users: map[int64]user.User{
+				1: {Id: 1, Email: sql.NullString{String: "alice@example.com", Valid: true}},
+				2: {Id: 2, Email: sql.NullString{String: "bob@example.com", Valid: true}},
+			},
+			passwords: map[int64]string{},
+		},
+		sessions: &memorySessionService{},
+	}
+
+	ts.service = NewService(
+		&Config{
+			AppURL:               testAppURL + "/",
+			EmailVerificationTTL: ttl,
+			PasswordResetTTL:     time.Hour,
+			AccountUnlockTTL:     time.Hour,
+		},
+		ts.mailer,
+		usertokenservice.NewService(
+			&usertokenservice.Config{Secret: "test-secret"},
+			&memoryTokenRepository{tokens: map[int64]usertoken.Token{}},
+		),
+		ts.users,
+		ts.sessions,
+	)
+
+	return ts
+}
+
+// token returns the token of the last link with the path sent to the email
+func (ts *testService) token(t *testing.T, to, path string) string {
+	t.Helper()
+
+	for i := len(ts.mailer.messages) - 1; i >= 0; i-- {
+		msg := ts.mailer.messages[i]
+		if msg.To != to {
+			continue
+		}
+
+		for _, line := range strings.Split(msg.Body, "\n") {
+			if !strings.HasPrefix(line, testAppURL+path+"?") {
+				continue
+			}
+
+			link, err := url.Parse(line)
+			if err != nil {
+				t.Fatalf("error while parse link: %v", err)
+			}
+
+			return link.Query().Get("token")
+		}
+	}
+
+	t.Fatalf("link %s isn't sent to %s", path, to)
+
+	return ""
+}
+
+func (m *memoryMailer) Send(msg mailer.Message) error {
+	m.messages = append(m.messages, msg)
+
+	return nil
+}
+
+func (s *memoryUserService) UserByEmail(_ context.Context, email string) (user.User, error) {
+	for _, u := range s.users {
+		if u.Email.String == email {
+			return u, nil
+		}
+	}
+
+	return user.User{}, userservice.ErrNotFound
+}
+
+func (s *memoryUserService) UserById(_ context.Context, userId int64) (user.User, error) {
+	u, ok := s.users[userId]
+	if !ok {
+		return user.User{}, userservice.ErrNotFound
+	}
+
+	return u, nil
+}
+
+func (s *memoryUserService) VerifyEmail(_ context.Context, userId int64) error {
+	u := s.users[userId]
+	u.EmailVerifiedAt = sql.NullString{String: time.Now().Format(time.DateTime), Valid: true}
+	s.users[userId] = u
+
+	return nil
+}
+
+func (s *memoryUserService) UpdatePassword(_ context.Context, userId int64, password string) error {
+	s.passwords[userId] = password
+
+	return nil
+}
+
+func (s *memoryUserService) UpdateEmail(ctx context.Context, userId int64, email string) error {
+	if _, err := s.UserByEmail(ctx, email); err == nil {
+		return userservice.ErrAlreadyExists
+	}
+
+	u := s.users[userId]
+	u.Email = sql.NullString{String: email, Valid: true}
+	s.users[userId] = u
+
+	return nil
+}
+
+func (s *memorySessionService) DeleteUserSessions(_ context.Context, userId int64) error {
+	s.deleted = append(s.deleted, userId)
+
+	return nil
+}
+
+func (r *memoryTokenRepository) Create(token usertoken.Token) (usertoken.Token, error) {
+	r.lastId++
+	token.Id = r.lastId
+	r.tokens[token.Id] = token
+
+	return token, nil
+}
+
+func (r *memoryTokenRepository) ByTokenHash(tokenHash string) (usertoken.Token, error) {
+	for _, t := range r.tokens {
+		if t.TokenHash == tokenHash {
+			return t, nil
+		}
+	}
+
+	return usertoken.Token{}, storage.ErrNotFound
+}
+
+func (r *memoryTokenRepository) MarkUsed(tokenId int64) error {
+	t, ok := r.tokens[tokenId]
+	if !ok || t.UsedAt.Valid {
+		return storage.ErrNotFound
+	}
+
+	t.UsedAt = sql.NullString{String: time.Now().Format(time.DateTime), Valid: true}
+	r.tokens[tokenId] = t
+
+	return nil
+}
+
+func (r *memoryTokenRepository) DeleteUnusedByUserId(userId int64, purpose string) error {
+	for id, t := range r.tokens {
+		if t.UserId == userId && t.Purpose == purpose && !t.UsedAt.Valid {
+			delete(r.tokens, id)
+		}
+	}
+
+	return nil
+}
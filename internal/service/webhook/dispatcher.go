@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/service/workerpool"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/webhook"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// leaseMargin is added to the timeout of the request, the delivery is attempted again by another worker
+	// if the worker which claimed it hasn't completed it by then, e.g. because its replica has crashed
+	leaseMargin = time.Minute
+
+	maxResponseBytes = 64 << 10
+)
+
+var errPrivateAddress = errors.New("webhook url resolves to a private address")
+
+// Start runs workers delivering queued deliveries until Shutdown. Deliveries are claimed in the database,
+// so workers of all replicas share them and each attempt is made by one worker
+func (s *Service) Start() {
+	s.pool.Start()
+}
+
+// Shutdown stops looking for deliveries and waits for started attempts, unfinished deliveries stay queued
+func (s *Service) Shutdown(ctx context.Context) error {
+	return s.pool.Shutdown(ctx)
+}
+
+// Signature returns the value of the signature header of the body sent at the unix timestamp
+func Signature(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// due returns deliveries whose attempt is due, queued on any replica or retried
+func (s *Service) due(ctx context.Context, limit int) ([]int64, error) {
+	return s.repo.Due(ctx, s.now().Format(time.DateTime), limit)
+}
+
+// deliver claims the delivery and makes the attempt, the delivery is skipped if another worker has claimed it
+func (s *Service) deliver(id int64) {
+	const op = "deliver"
+
+	// the attempt isn't interrupted by the shutdown, it's limited by the timeout
+	ctx := context.Background()
+	now := s.now()
+
+	claimed, err := s.repo.Claim(
+		ctx,
+		id,
+		now.Format(time.DateTime),
+		now.Add(s.conf.Timeout+leaseMargin).Format(time.DateTime),
+	)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	if !claimed {
+		return
+	}
+
+	d, err := s.repo.FindDelivery(ctx, id)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	w, err := s.repo.Find(ctx, d.WebhookId)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	var attempt webhook.Attempt
+
+	secret, err := s.decrypt(w.UserId, w.SecretEncrypted)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		attempt.Error = "secret of the webhook can't be decrypted"
+	} else {
+		attempt = s.send(ctx, w.URL, secret, d)
+	}
+
+	if attempt.Error == "" {
+		d.Status = webhook.StatusSucceeded
+	} else if d.Attempts >= s.conf.MaxAttempts {
+		d.Status = webhook.StatusFailed
+	} else {
+		d.NextAttemptAt = s.now().Add(workerpool.Backoff(s.conf.Backoff, s.conf.MaxBackoff, d.Attempts)).Format(time.DateTime)
+	}
+
+	if err := s.repo.Complete(ctx, d, attempt); err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	if attempt.Error == "" {
+		if w.Failures > 0 {
+			if err := s.repo.ResetFailures(ctx, w.Id); err != nil {
+				logger.Add(s.pkg, op, err)
+			}
+		}
+
+		return
+	}
+
+	failures, err := s.repo.AddFailure(ctx, w.Id)
+	if err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	if failures >= s.conf.DisableAfter {
+		s.disable(ctx, w, failures)
+	}
+}
+
+// disable turns off the webhook failing too often and tells its user about it
+func (s *Service) disable(ctx context.Context, w webhook.Webhook, failures int) {
+	const op = "disable"
+
+	disabledAt := sql.NullString{String: s.now().Format(time.DateTime), Valid: true}
+	if err := s.repo.SetDisabled(ctx, w.Id, disabledAt); err != nil {
+		logger.Add(s.pkg, op, err)
+
+		return
+	}
+
+	slog.Warn("webhook disabled after failed deliveries", "pkg", s.pkg, "webhook_id", w.Id, "failures", failures)
+
+	s.events.Publish(ctx, events.Event{
+		UserId: w.UserId,
+		Type:   events.TypeWebhookDisabled,
+		Data: map[string]string{
+			"webhook_id": strconv.FormatInt(w.Id, 10),
+			"url":        w.URL,
+			"failures":   strconv.Itoa(failures),
+		},
+	})
+}
+
+// send posts the payload of the delivery, the attempt fails on errors of the connection and statuses other than 2xx
+func (s *Service) send(ctx context.Context, url, secret string, d webhook.Delivery) webhook.Attempt {
+	ctx, cancel := context.WithTimeout(ctx, s.conf.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(d.Payload))
+	if err != nil {
+		return webhook.Attempt{Error: workerpool.Truncate(err.Error())}
+	}
+
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-cloud-file-storage-webhooks")
+	req.Header.Set(HeaderEvent, d.EventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.Id, 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Signature(secret, timestamp, []byte(d.Payload)))
+
+	started := time.Now()
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return webhook.Attempt{Error: workerpool.Truncate(err.Error()), DurationMs: time.Since(started).Milliseconds()}
+	}
+	defer func(body io.ReadCloser) {
+		_ = body.Close()
+	}(resp.Body)
+
+	// the response isn't used, it's read to reuse the connection
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+
+	attempt := webhook.Attempt{StatusCode: resp.StatusCode, DurationMs: time.Since(started).Milliseconds()}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		attempt.Error = "unexpected response status " + resp.Status
+	}
+
+	return attempt
+}
+
+// newHTTPClient returns the client which doesn't follow redirects and, unless private addresses are allowed,
+// doesn't connect to them, so webhooks can't be used to reach internal services
+func newHTTPClient(conf *Config) *http.Client {
+	dialer := &net.Dialer{Timeout: conf.Timeout}
+	if !conf.AllowPrivate {
+		dialer.Control = denyPrivate
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			// a proxy would connect to the address instead of the dialer
+			Proxy:               nil,
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: conf.Timeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// denyPrivate checks the resolved address before connecting, so names resolving to private addresses are denied too
+func denyPrivate(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return errPrivateAddress
+	}
+
+	return nil
+}
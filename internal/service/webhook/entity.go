@@ -0,0 +1,58 @@
+package webhook
+
+import (
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/webhook"
+	"time"
+)
+
+// Headers of delivery requests. The signature is "sha256=" and hex of HMAC-SHA256 of the timestamp, a dot
+// and the body with the secret of the webhook, so the receiver can check the sender and reject old requests
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrNotFound      = errors.New("webhook not found")
+	ErrInvalidURL    = errors.New("webhook url invalid")
+	ErrInvalidEvents = errors.New("webhook events invalid")
+	ErrInvalidFolder = errors.New("webhook folder invalid")
+	ErrInvalidSecret = errors.New("webhook secret invalid")
+	ErrTooMany       = errors.New("too many webhooks")
+	ErrDisabled      = errors.New("webhook disabled")
+)
+
+type Config struct {
+	Secret       string        // encrypts secrets of webhooks stored in the database
+	Timeout      time.Duration // of one delivery request
+	MaxAttempts  int           // the delivery fails after them
+	Backoff      time.Duration // before the second attempt, doubled before each next one
+	MaxBackoff   time.Duration
+	DisableAfter int           // failed attempts in a row disabling the webhook
+	Retention    time.Duration // finished deliveries and their attempts are kept for it
+	Workers      int
+	PollInterval time.Duration // how often other replicas' and retried deliveries are looked for
+	AllowPrivate bool          // allows urls in loopback and private networks, e.g. in development
+}
+
+// Payload is the JSON body of the delivery. Id is the id of the event, it's the same for redeliveries,
+// so the receiver can skip events it has already handled
+type Payload struct {
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	WebhookId int64             `json:"webhook_id"`
+	Path      string            `json:"path"`
+	FromPath  string            `json:"from_path,omitempty"`
+	Cursor    int64             `json:"cursor,omitempty"`
+	Data      map[string]string `json:"data,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// DeliveryLog is the delivery with its attempts, oldest first
+type DeliveryLog struct {
+	Delivery webhook.Delivery
+	Attempts []webhook.Attempt
+}
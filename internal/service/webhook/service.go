@@ -0,0 +1,442 @@
+package webhook
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	"github.com/albakov/go-cloud-file-storage/internal/service/workerpool"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/webhook"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxWebhooks     = 20
+	maxURLLength    = 2048
+	maxFolderLength = 1024
+	minSecretLength = 16
+	maxSecretLength = 128
+)
+
+// Events are file events, other events of the user aren't related to stored files
+var Events = []string{events.TypeFileCreated, events.TypeFileUpdated, events.TypeFileMoved, events.TypeFileDeleted}
+
+type Service struct {
+	pkg    string
+	conf   *Config
+	repo   Repository
+	events Publisher
+	aead   cipher.AEAD
+	client *http.Client
+	now    func() time.Time
+	pool   *workerpool.Pool
+}
+
+type Repository interface {
+	Create(ctx context.Context, w webhook.Webhook) (webhook.Webhook, error)
+	ById(ctx context.Context, userId, id int64) (webhook.Webhook, error)
+	Find(ctx context.Context, id int64) (webhook.Webhook, error)
+	ByUserId(ctx context.Context, userId int64) ([]webhook.Webhook, error)
+	Enabled(ctx context.Context, userId int64) ([]webhook.Webhook, error)
+	Delete(ctx context.Context, userId, id int64) error
+	SetDisabled(ctx context.Context, id int64, disabledAt sql.NullString) error
+	AddFailure(ctx context.Context, id int64) (int, error)
+	ResetFailures(ctx context.Context, id int64) error
+	CreateDelivery(ctx context.Context, d webhook.Delivery) (webhook.Delivery, error)
+	DeliveryById(ctx context.Context, webhookId, id int64) (webhook.Delivery, error)
+	FindDelivery(ctx context.Context, id int64) (webhook.Delivery, error)
+	Deliveries(ctx context.Context, webhookId int64, limit, offset int) ([]webhook.Delivery, int64, error)
+	Attempts(ctx context.Context, deliveryIds []int64) (map[int64][]webhook.Attempt, error)
+	Due(ctx context.Context, now string, limit int) ([]int64, error)
+	Claim(ctx context.Context, id int64, now, lockedUntil string) (bool, error)
+	Complete(ctx context.Context, d webhook.Delivery, attempt webhook.Attempt) error
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, event events.Event)
+}
+
+func NewService(conf *Config, repo Repository, publisher Publisher) *Service {
+	sum := sha256.Sum256([]byte(conf.Secret))
+
+	// AES-256 accepts the key of 32 bytes and GCM the standard nonce, neither returns an error then
+	block, _ := aes.NewCipher(sum[:])
+	aead, _ := cipher.NewGCM(block)
+
+	s := &Service{
+		pkg:    "webhook.service",
+		conf:   conf,
+		repo:   repo,
+		events: publisher,
+		aead:   aead,
+		client: newHTTPClient(conf),
+		now:    time.Now,
+	}
+
+	s.pool = workerpool.New(
+		s.pkg,
+		&workerpool.Config{Workers: conf.Workers, PollInterval: conf.PollInterval, Batch: conf.Workers * 4},
+		s.due,
+		s.deliver,
+	)
+
+	return s
+}
+
+// Create registers the webhook of the user. Empty events subscribe it to all file events, the empty secret
+// is generated. The secret is returned only once, it's stored encrypted, as signing deliveries needs it
+func (s *Service) Create(
+	ctx context.Context,
+	userId int64,
+	rawURL string,
+	secret string,
+	types []string,
+	folder string,
+) (string, webhook.Webhook, error) {
+	const op = "Create"
+
+	rawURL = strings.TrimSpace(rawURL)
+	if !validURL(rawURL) {
+		return "", webhook.Webhook{}, ErrInvalidURL
+	}
+
+	if len(types) == 0 {
+		types = Events
+	}
+
+	for _, t := range types {
+		if !slices.Contains(Events, t) {
+			return "", webhook.Webhook{}, ErrInvalidEvents
+		}
+	}
+
+	folder, ok := cleanFolder(folder)
+	if !ok {
+		return "", webhook.Webhook{}, ErrInvalidFolder
+	}
+
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", webhook.Webhook{}, logger.Error(s.pkg, op, err)
+		}
+
+		secret = hex.EncodeToString(b)
+	}
+
+	if len(secret) < minSecretLength || len(secret) > maxSecretLength {
+		return "", webhook.Webhook{}, ErrInvalidSecret
+	}
+
+	existing, err := s.repo.ByUserId(ctx, userId)
+	if err != nil {
+		return "", webhook.Webhook{}, logger.Error(s.pkg, op, err)
+	}
+
+	if len(existing) >= maxWebhooks {
+		return "", webhook.Webhook{}, ErrTooMany
+	}
+
+	encrypted, err := s.encrypt(userId, secret)
+	if err != nil {
+		return "", webhook.Webhook{}, logger.Error(s.pkg, op, err)
+	}
+
+	w, err := s.repo.Create(ctx, webhook.Webhook{
+		UserId:          userId,
+		URL:             rawURL,
+		SecretEncrypted: encrypted,
+		Events:          strings.Join(slices.Compact(slices.Sorted(slices.Values(types))), ","),
+		Folder:          folder,
+	})
+	if err != nil {
+		return "", webhook.Webhook{}, logger.Error(s.pkg, op, err)
+	}
+
+	return secret, w, nil
+}
+
+func (s *Service) Webhooks(ctx context.Context, userId int64) ([]webhook.Webhook, error) {
+	const op = "Webhooks"
+
+	webhooks, err := s.repo.ByUserId(ctx, userId)
+	if err != nil {
+		return nil, logger.Error(s.pkg, op, err)
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes the webhook with its deliveries
+func (s *Service) Delete(ctx context.Context, userId, id int64) error {
+	const op = "Delete"
+
+	if err := s.repo.Delete(ctx, userId, id); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return ErrNotFound
+		}
+
+		return logger.Error(s.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Enable resumes deliveries of the webhook, pending deliveries are attempted again
+func (s *Service) Enable(ctx context.Context, userId, id int64) (webhook.Webhook, error) {
+	return s.setDisabled(ctx, userId, id, false)
+}
+
+// Disable stops deliveries of the webhook, events aren't queued for it until it's enabled
+func (s *Service) Disable(ctx context.Context, userId, id int64) (webhook.Webhook, error) {
+	return s.setDisabled(ctx, userId, id, true)
+}
+
+// Deliveries returns the page of deliveries of the webhook with their attempts, newest first
+func (s *Service) Deliveries(ctx context.Context, userId, id int64, page, perPage int) ([]DeliveryLog, int64, error) {
+	const op = "Deliveries"
+
+	if _, err := s.webhook(ctx, userId, id); err != nil {
+		return nil, 0, err
+	}
+
+	deliveries, total, err := s.repo.Deliveries(ctx, id, perPage, (page-1)*perPage)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	ids := make([]int64, 0, len(deliveries))
+	for _, d := range deliveries {
+		ids = append(ids, d.Id)
+	}
+
+	attempts, err := s.repo.Attempts(ctx, ids)
+	if err != nil {
+		return nil, 0, logger.Error(s.pkg, op, err)
+	}
+
+	logs := make([]DeliveryLog, 0, len(deliveries))
+	for _, d := range deliveries {
+		logs = append(logs, DeliveryLog{Delivery: d, Attempts: attempts[d.Id]})
+	}
+
+	return logs, total, nil
+}
+
+// Redeliver queues the payload of the delivery again as a new delivery, the event keeps its id
+func (s *Service) Redeliver(ctx context.Context, userId, id, deliveryId int64) (webhook.Delivery, error) {
+	const op = "Redeliver"
+
+	w, err := s.webhook(ctx, userId, id)
+	if err != nil {
+		return webhook.Delivery{}, err
+	}
+
+	if w.DisabledAt.Valid {
+		return webhook.Delivery{}, ErrDisabled
+	}
+
+	d, err := s.repo.DeliveryById(ctx, id, deliveryId)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return webhook.Delivery{}, ErrNotFound
+		}
+
+		return webhook.Delivery{}, logger.Error(s.pkg, op, err)
+	}
+
+	redelivery, err := s.enqueue(ctx, w.Id, d.EventId, d.EventType, d.Payload)
+	if err != nil {
+		return webhook.Delivery{}, logger.Error(s.pkg, op, err)
+	}
+
+	s.pool.Wake()
+
+	return redelivery, nil
+}
+
+// Receive queues deliveries of the file event to enabled webhooks of the user subscribed to it.
+// The event is sent later by workers, so the action publishing it isn't delayed by receivers
+func (s *Service) Receive(ctx context.Context, event events.Event) {
+	const op = "Receive"
+
+	if !slices.Contains(Events, event.Type) {
+		return
+	}
+
+	webhooks, err := s.repo.Enabled(ctx, event.UserId)
+	if err != nil {
+		logger.AddContext(ctx, s.pkg, op, err)
+
+		return
+	}
+
+	queued := false
+	for _, w := range webhooks {
+		if !(events.Filter{Types: strings.Split(w.Events, ","), Folder: w.Folder}).Match(event) {
+			continue
+		}
+
+		payload, err := json.Marshal(Payload{
+			Id:        event.Id,
+			Type:      event.Type,
+			WebhookId: w.Id,
+			Path:      event.Path,
+			FromPath:  event.FromPath,
+			Cursor:    event.Cursor,
+			Data:      event.Data,
+			CreatedAt: event.CreatedAt,
+		})
+		if err != nil {
+			logger.AddContext(ctx, s.pkg, op, err)
+
+			continue
+		}
+
+		if _, err := s.enqueue(ctx, w.Id, event.Id, event.Type, string(payload)); err != nil {
+			logger.AddContext(ctx, s.pkg, op, err)
+
+			continue
+		}
+
+		queued = true
+	}
+
+	if queued {
+		s.pool.Wake()
+	}
+}
+
+func (s *Service) enqueue(ctx context.Context, webhookId int64, eventId, eventType, payload string) (webhook.Delivery, error) {
+	now := s.now()
+
+	return s.repo.CreateDelivery(ctx, webhook.Delivery{
+		WebhookId:     webhookId,
+		EventId:       eventId,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        webhook.StatusPending,
+		NextAttemptAt: now.Format(time.DateTime),
+		ExpiresAt:     now.Add(s.conf.Retention).Format(time.DateTime),
+	})
+}
+
+func (s *Service) setDisabled(ctx context.Context, userId, id int64, disabled bool) (webhook.Webhook, error) {
+	const op = "setDisabled"
+
+	w, err := s.webhook(ctx, userId, id)
+	if err != nil {
+		return webhook.Webhook{}, err
+	}
+
+	var disabledAt sql.NullString
+	if disabled {
+		disabledAt = sql.NullString{String: s.now().Format(time.DateTime), Valid: true}
+	}
+
+	if err := s.repo.SetDisabled(ctx, w.Id, disabledAt); err != nil {
+		return webhook.Webhook{}, logger.Error(s.pkg, op, err)
+	}
+
+	if !disabled {
+		s.pool.Wake()
+	}
+
+	w.DisabledAt, w.Failures = disabledAt, 0
+
+	return w, nil
+}
+
+func (s *Service) webhook(ctx context.Context, userId, id int64) (webhook.Webhook, error) {
+	const op = "webhook"
+
+	w, err := s.repo.ById(ctx, userId, id)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return webhook.Webhook{}, ErrNotFound
+		}
+
+		return webhook.Webhook{}, logger.Error(s.pkg, op, err)
+	}
+
+	return w, nil
+}
+
+// encrypt seals the secret for the user, so it can't be moved to a webhook of another user in the database
+func (s *Service) encrypt(userId int64, secret string) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := s.aead.Seal(nonce, nonce, []byte(secret), []byte(strconv.FormatInt(userId, 10)))
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (s *Service) decrypt(userId int64, encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return "", err
+	}
+
+	if len(sealed) < s.aead.NonceSize() {
+		return "", errors.New("encrypted secret is too short")
+	}
+
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+
+	secret, err := s.aead.Open(nil, nonce, ciphertext, []byte(strconv.FormatInt(userId, 10)))
+	if err != nil {
+		return "", err
+	}
+
+	return string(secret), nil
+}
+
+func validURL(rawURL string) bool {
+	if len(rawURL) > maxURLLength {
+		return false
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() != "" && u.User == nil
+}
+
+// cleanFolder returns the absolute folder without the trailing slash, empty for the root
+func cleanFolder(folder string) (string, bool) {
+	folder = strings.TrimSpace(folder)
+	if folder == "" {
+		return "", true
+	}
+
+	if !strings.HasPrefix(folder, "/") || len(folder) > maxFolderLength {
+		return "", false
+	}
+
+	folder = path.Clean(folder)
+	if folder == "/" {
+		return "", true
+	}
+
+	return folder, true
+}
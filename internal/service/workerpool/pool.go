@@ -0,0 +1,151 @@
+package workerpool
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"sync"
+	"time"
+)
+
+// MaxErrorLength limits errors saved with attempts, e.g. messages of failed connections
+const MaxErrorLength = 1024
+
+type Config struct {
+	Workers      int
+	PollInterval time.Duration // how often due items queued on other replicas and retries are looked for
+	Batch        int           // how many due items are looked for at once
+}
+
+// Due returns ids of items due now, at most the limit
+type Due func(ctx context.Context, limit int) ([]int64, error)
+
+// Pool runs due items of the queue stored in the database. Items must be claimed by the run in the database,
+// so workers of all replicas share them and each item is run by one worker
+type Pool struct {
+	pkg    string
+	conf   *Config
+	due    Due
+	run    func(id int64)
+	wakeup chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New(pkg string, conf *Config, due Due, run func(id int64)) *Pool {
+	return &Pool{
+		pkg:    pkg,
+		conf:   conf,
+		due:    due,
+		run:    run,
+		wakeup: make(chan struct{}, 1),
+	}
+}
+
+// Start runs workers and the poller sending due items to them until Shutdown
+func (p *Pool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	due := make(chan int64)
+
+	for range p.conf.Workers {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+
+			for id := range due {
+				p.run(id)
+			}
+		}()
+	}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer close(due)
+
+		p.poll(ctx, due)
+	}()
+}
+
+// Shutdown stops looking for due items and waits for started runs until the context is done
+func (p *Pool) Shutdown(ctx context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wake makes the poller look for due items at once, e.g. when an item is queued on this instance
+func (p *Pool) Wake() {
+	select {
+	case p.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// poll sends due items to workers when it's woken up and every poll interval
+func (p *Pool) poll(ctx context.Context, due chan<- int64) {
+	const op = "poll"
+
+	ticker := time.NewTicker(p.conf.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		ids, err := p.due(ctx, p.conf.Batch)
+		if err != nil && ctx.Err() == nil {
+			logger.Add(p.pkg, op, err)
+		}
+
+		for _, id := range ids {
+			select {
+			case due <- id:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-p.wakeup:
+		}
+	}
+}
+
+// Backoff returns the delay after the attempt, it's doubled after each attempt up to the max, zero max
+// doesn't limit it
+func Backoff(base, maxDelay time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && (maxDelay == 0 || delay < maxDelay); i++ {
+		delay *= 2
+	}
+
+	if maxDelay > 0 {
+		return min(delay, maxDelay)
+	}
+
+	return delay
+}
+
+// Truncate cuts the error to MaxErrorLength bytes
+func Truncate(s string) string {
+	if len(s) > MaxErrorLength {
+		return s[:MaxErrorLength]
+	}
+
+	return s
+}
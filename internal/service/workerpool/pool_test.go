@@ -0,0 +1,100 @@
+package workerpool
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPool_RunsDueItems(t *testing.T) {
+	var mu sync.Mutex
+	var queued, ran []int64
+
+	due := func(_ context.Context, limit int) ([]int64, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		n := min(limit, len(queued))
+		ids := queued[:n]
+		queued = queued[n:]
+
+		return ids, nil
+	}
+
+	done := make(chan struct{}, 10)
+	run := func(id int64) {
+		mu.Lock()
+		ran = append(ran, id)
+		mu.Unlock()
+
+		done <- struct{}{}
+	}
+
+	pool := New("test", &Config{Workers: 2, PollInterval: time.Hour, Batch: 2}, due, run)
+	pool.Start()
+
+	// the poll interval is long, the items queued after the start are found only by waking the pool up
+	mu.Lock()
+	queued = []int64{1, 2, 3}
+	mu.Unlock()
+
+	for range 3 {
+		pool.Wake()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("queued item must be run after the wake up")
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := pool.Shutdown(ctx); err != nil {
+		t.Fatalf("error while shutdown: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	slices.Sort(ran)
+	if !slices.Equal(ran, []int64{1, 2, 3}) {
+		t.Errorf("each due item must be run once, got: %v", ran)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxDelay time.Duration
+		attempt  int
+		want     time.Duration
+	}{
+		{name: "first attempt", maxDelay: time.Hour, attempt: 1, want: time.Minute},
+		{name: "doubled", maxDelay: time.Hour, attempt: 3, want: 4 * time.Minute},
+		{name: "limited", maxDelay: time.Hour, attempt: 10, want: time.Hour},
+		{name: "unlimited", attempt: 10, want: 512 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Backoff(time.Minute, tt.maxDelay, tt.attempt); got != tt.want {
+				t.Errorf("got: %v, want: %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := Truncate("short"); got != "short" {
+		t.Errorf("short error must be kept, got: %q", got)
+	}
+
+	if got := Truncate(strings.Repeat("a", MaxErrorLength+1)); len(got) != MaxErrorLength {
+		t.Errorf("long error must be cut to %d bytes, got: %d", MaxErrorLength, len(got))
+	}
+}
@@ -0,0 +1,133 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"log/slog"
+	"time"
+)
+
+const defaultCleanupTimeout = 5 * time.Second
+
+type Config struct {
+	Timeout        time.Duration // of all wait steps together
+	CleanupTimeout time.Duration // of every cleanup step, 5 seconds by default
+}
+
+type Step func(ctx context.Context) error
+
+type step struct {
+	name string
+	run  Step
+}
+
+// Coordinator stops the app in steps. Wait steps (draining requests, background workers) run in order
+// and share the timeout, then cleanup steps (aborting uploads, closing connections) run in order,
+// each with its own timeout, even if waiting has timed out, so resources are released anyway
+type Coordinator struct {
+	pkg     string
+	conf    *Config
+	waits   []step
+	cleanup []step
+}
+
+func New(conf *Config) *Coordinator {
+	return &Coordinator{
+		pkg:  "shutdown.Coordinator",
+		conf: conf,
+	}
+}
+
+// Wait adds a step waiting for something to finish
+func (c *Coordinator) Wait(name string, run Step) {
+	c.waits = append(c.waits, step{name: name, run: run})
+}
+
+// Cleanup adds a step releasing a resource
+func (c *Coordinator) Cleanup(name string, run Step) {
+	c.cleanup = append(c.cleanup, step{name: name, run: run})
+}
+
+// Run runs all the steps, a failed step doesn't prevent the next ones. Returns errors of all failed steps
+func (c *Coordinator) Run(ctx context.Context) error {
+	var errs []error
+
+	waitCtx, cancel := context.WithTimeout(ctx, c.conf.Timeout)
+	defer cancel()
+
+	for _, s := range c.waits {
+		errs = append(errs, c.run(waitCtx, s))
+	}
+
+	cleanupTimeout := c.conf.CleanupTimeout
+	if cleanupTimeout <= 0 {
+		cleanupTimeout = defaultCleanupTimeout
+	}
+
+	for _, s := range c.cleanup {
+		stepCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cleanupTimeout)
+		errs = append(errs, c.run(stepCtx, s))
+		cancel()
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Coordinator) run(ctx context.Context, s step) error {
+	const op = "run"
+
+	started := time.Now()
+
+	err := s.run(ctx)
+	if err != nil {
+		err = fmt.Errorf("%s: %w", s.name, err)
+		logger.Add(c.pkg, op, err)
+
+		return err
+	}
+
+	slog.Info("shutdown step finished", "step", s.name, "duration", time.Since(started))
+
+	return nil
+}
+
+// Blocking makes a step of a function which can't be cancelled, e.g. sync.WaitGroup.Wait.
+// The step returns when the function returns or the context is done, the function keeps running then
+func Blocking(wait func()) Step {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+
+			wait()
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Delay makes a step waiting for the duration, e.g. for load balancers to notice failed readiness
+func Delay(d time.Duration) Step {
+	return func(ctx context.Context) error {
+		if d <= 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
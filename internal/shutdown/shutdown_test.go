@@ -0,0 +1,128 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCoordinator_Run(t *testing.T) {
+	var steps []string
+
+	step := func(name string, err error) Step {
+		return func(context.Context) error {
+			steps = append(steps, name)
+
+			return err
+		}
+	}
+
+	errFailed := errors.New("failed")
+
+	c := New(&Config{Timeout: time.Second})
+	c.Cleanup("close", step("close", nil))
+	c.Wait("first", step("first", errFailed))
+	c.Wait("second", step("second", nil))
+
+	err := c.Run(context.Background())
+	if !errors.Is(err, errFailed) {
+		t.Errorf("error of the failed step must be returned, got: %v", err)
+	}
+
+	if !slices.Equal(steps, []string{"first", "second", "close"}) {
+		t.Errorf("wait steps must run in order before cleanup steps, got: %v", steps)
+	}
+}
+
+func TestCoordinator_RunTimeout(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Done()
+
+	cleaned := false
+
+	c := New(&Config{Timeout: 50 * time.Millisecond})
+	c.Wait("workers", Blocking(wg.Wait))
+	c.Cleanup("close", func(ctx context.Context) error {
+		cleaned = ctx.Err() == nil
+
+		return nil
+	})
+
+	err := c.Run(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("workers not finished in time must return deadline error, got: %v", err)
+	}
+
+	if !cleaned {
+		t.Error("cleanup must run with a live context after the wait has timed out")
+	}
+}
+
+func TestCoordinator_InProcessServer(t *testing.T) {
+	started := make(chan struct{})
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/slow", func(ctx *fiber.Ctx) error {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+
+		return ctx.SendString("done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("error while listen: %v", err)
+	}
+
+	go func() {
+		_ = app.Listener(ln)
+	}()
+
+	url := "http://" + ln.Addr().String() + "/slow"
+
+	type result struct {
+		status int
+		err    error
+	}
+	inFlight := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			inFlight <- result{err: err}
+
+			return
+		}
+		_ = resp.Body.Close()
+
+		inFlight <- result{status: resp.StatusCode}
+	}()
+
+	<-started
+
+	c := New(&Config{Timeout: 5 * time.Second})
+	c.Wait("api", app.ShutdownWithContext)
+
+	if err := c.Run(context.Background()); err != nil {
+		t.Fatalf("server must stop in time, got: %v", err)
+	}
+
+	select {
+	case r := <-inFlight:
+		if r.err != nil || r.status != http.StatusOK {
+			t.Errorf("in-flight request must be completed, got: %d, %v", r.status, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Error("in-flight request must be completed")
+	}
+
+	if _, err := net.DialTimeout("tcp", ln.Addr().String(), time.Second); err == nil {
+		t.Error("stopped server must not accept connections")
+	}
+}
@@ -0,0 +1,15 @@
+package accesstoken
+
+import "database/sql"
+
+type AccessToken struct {
+	Id         int64
+	UserId     int64
+	Name       string
+	TokenHash  string
+	Scopes     string // comma separated list
+	Folder     sql.NullString
+	ExpiredAt  sql.NullString
+	LastUsedAt sql.NullString
+	CreatedAt  string
+}
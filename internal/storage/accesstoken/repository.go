@@ -0,0 +1,215 @@
+package accesstoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "accesstoken.repository",
+		db:  db,
+	}
+}
+
+func (at *Repository) Create(ctx context.Context, token AccessToken) (AccessToken, error) {
+	const op = "Create"
+
+	id, err := at.db.InsertContext(
+		ctx,
+		"INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, folder, expires_at) "+
+			"VALUES (?, ?, ?, ?, ?, ?)",
+		token.UserId, token.Name, token.TokenHash, token.Scopes, token.Folder, token.ExpiredAt,
+	)
+	if err != nil {
+		// check if error is because token_hash duplicate
+		if at.db.IsDuplicate(err) {
+			return AccessToken{}, storage.ErrDuplicateNotAllowed
+		}
+
+		return AccessToken{}, logger.Error(at.pkg, op, err)
+	}
+
+	return at.ById(ctx, token.UserId, id)
+}
+
+func (at *Repository) ById(ctx context.Context, userId, tokenId int64) (AccessToken, error) {
+	const op = "ById"
+
+	t, err := at.scan(at.db.QueryRowContext(
+		ctx,
+		"SELECT id, user_id, name, token_hash, scopes, folder, expires_at, last_used_at, created_at "+
+			"FROM personal_access_tokens WHERE id = ? AND user_id = ?",
+		tokenId,
+		userId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AccessToken{}, storage.ErrNotFound
+		}
+
+		return AccessToken{}, logger.Error(at.pkg, op, err)
+	}
+
+	return t, nil
+}
+
+func (at *Repository) ByTokenHash(ctx context.Context, tokenHash string) (AccessToken, error) {
+	const op = "ByTokenHash"
+
+	// tokens of disabled users are not found
+	t, err := at.scan(at.db.QueryRowContext(
+		ctx,
+		"SELECT t.id, t.user_id, t.name, t.token_hash, t.scopes, t.folder, t.expires_at, t.last_used_at, t.created_at "+
+			"FROM personal_access_tokens t INNER JOIN users u ON u.id = t.user_id "+
+			"WHERE t.token_hash = ? AND u.disabled_at IS NULL",
+		tokenHash,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return AccessToken{}, storage.ErrNotFound
+		}
+
+		return AccessToken{}, logger.Error(at.pkg, op, err)
+	}
+
+	return t, nil
+}
+
+func (at *Repository) ByUserId(ctx context.Context, userId int64) ([]AccessToken, error) {
+	const op = "ByUserId"
+
+	rows, err := at.db.QueryContext(
+		ctx,
+		"SELECT id, user_id, name, token_hash, scopes, folder, expires_at, last_used_at, created_at "+
+			"FROM personal_access_tokens WHERE user_id = ? ORDER BY id DESC",
+		userId,
+	)
+	if err != nil {
+		return nil, logger.Error(at.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(at.pkg, op, err)
+		}
+	}(rows)
+
+	tokens := []AccessToken{}
+	for rows.Next() {
+		t, err := at.scan(rows)
+		if err != nil {
+			return nil, logger.Error(at.pkg, op, err)
+		}
+
+		tokens = append(tokens, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(at.pkg, op, err)
+	}
+
+	return tokens, nil
+}
+
+func (at *Repository) Delete(ctx context.Context, userId, tokenId int64) error {
+	const op = "Delete"
+
+	stmt, err := at.db.PrepareContext(ctx, "DELETE FROM personal_access_tokens WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return logger.Error(at.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(at.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.ExecContext(ctx, tokenId, userId)
+	if err != nil {
+		return logger.Error(at.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return logger.Error(at.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (at *Repository) UpdateLastUsed(ctx context.Context, tokenId int64) error {
+	const op = "UpdateLastUsed"
+
+	stmt, err := at.db.PrepareContext(ctx, "UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ?")
+	if err != nil {
+		return logger.Error(at.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(at.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, storage.Now(), tokenId)
+	if err != nil {
+		return logger.Error(at.pkg, op, err)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (at *Repository) scan(row scanner) (AccessToken, error) {
+	var t AccessToken
+	err := row.Scan(
+		&t.Id, &t.UserId, &t.Name, &t.TokenHash, &t.Scopes, &t.Folder, &t.ExpiredAt, &t.LastUsedAt, &t.CreatedAt,
+	)
+
+	return t, err
+}
+
+// DeleteExpired removes expired tokens and returns their number
+func (at *Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	stmt, err := at.db.PrepareContext(ctx, "DELETE FROM personal_access_tokens WHERE expires_at IS NOT NULL AND expires_at < ?")
+	if err != nil {
+		return 0, logger.Error(at.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(at.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.ExecContext(ctx, storage.Now())
+	if err != nil {
+		return 0, logger.Error(at.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return 0, logger.Error(at.pkg, op, err)
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,28 @@
+package auditlog
+
+import "database/sql"
+
+type Entry struct {
+	Id         int64
+	UserId     sql.NullInt64  // NULL - the actor is unknown, e.g. sign in with a wrong email
+	Email      sql.NullString // email used to sign in
+	Action     string
+	Result     string
+	Ip         string
+	UserAgent  string
+	Path       sql.NullString
+	TargetPath sql.NullString // destination of move
+	CreatedAt  string
+}
+
+// Filter selects entries, zero values match everything
+type Filter struct {
+	UserId   int64
+	Email    string
+	Action   string
+	Result   string
+	Ip       string
+	From     string // in time.DateTime format, inclusive
+	To       string // in time.DateTime format, exclusive
+	BeforeId int64  // entries older than the id, used to iterate over all entries
+}
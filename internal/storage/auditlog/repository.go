@@ -0,0 +1,164 @@
+package auditlog
+
+import (
+	"database/sql"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"strings"
+)
+
+const columns = "id, user_id, email, action, result, ip, user_agent, path, target_path, created_at"
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "auditlog.repository",
+		db:  db,
+	}
+}
+
+// Create appends the entry, entries are never updated or deleted by the application
+func (r *Repository) Create(entry Entry) error {
+	const op = "Create"
+
+	stmt, err := r.db.Prepare(
+		"INSERT INTO audit_logs (user_id, email, action, result, ip, user_agent, path, target_path, created_at) " +
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.Exec(
+		entry.UserId,
+		entry.Email,
+		entry.Action,
+		entry.Result,
+		entry.Ip,
+		entry.UserAgent,
+		entry.Path,
+		entry.TargetPath,
+		entry.CreatedAt,
+	)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Search returns entries matching the filter, newest first
+func (r *Repository) Search(filter Filter, limit, offset int) ([]Entry, error) {
+	const op = "Search"
+
+	where, args := conditions(filter)
+	args = append(args, limit, offset)
+
+	rows, err := r.db.Query(
+		"SELECT "+columns+" FROM audit_logs"+where+" ORDER BY id DESC LIMIT ? OFFSET ?",
+		args...,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		err := rows.Scan(
+			&e.Id, &e.UserId, &e.Email, &e.Action, &e.Result, &e.Ip, &e.UserAgent, &e.Path, &e.TargetPath, &e.CreatedAt,
+		)
+		if err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return entries, nil
+}
+
+func (r *Repository) Count(filter Filter) (int64, error) {
+	const op = "Count"
+
+	where, args := conditions(filter)
+
+	var count int64
+	err := r.db.QueryRow("SELECT COUNT(*) FROM audit_logs"+where, args...).Scan(&count)
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return count, nil
+}
+
+// conditions builds WHERE clause of the filter
+func conditions(filter Filter) (string, []any) {
+	var (
+		parts []string
+		args  []any
+	)
+
+	add := func(condition string, arg any) {
+		parts = append(parts, condition)
+		args = append(args, arg)
+	}
+
+	if filter.UserId != 0 {
+		add("user_id = ?", filter.UserId)
+	}
+
+	if filter.Email != "" {
+		add("email = ?", filter.Email)
+	}
+
+	if filter.Action != "" {
+		add("action = ?", filter.Action)
+	}
+
+	if filter.Result != "" {
+		add("result = ?", filter.Result)
+	}
+
+	if filter.Ip != "" {
+		add("ip = ?", filter.Ip)
+	}
+
+	if filter.From != "" {
+		add("created_at >= ?", filter.From)
+	}
+
+	if filter.To != "" {
+		add("created_at < ?", filter.To)
+	}
+
+	if filter.BeforeId != 0 {
+		add("id < ?", filter.BeforeId)
+	}
+
+	if len(parts) == 0 {
+		return "", nil
+	}
+
+	return " WHERE " + strings.Join(parts, " AND "), args
+}
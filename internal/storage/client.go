@@ -1,35 +1,33 @@
 package storage
 
 import (
-	"database/sql"
-	"fmt"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
-	"log"
-	"time"
-
-	_ "github.com/go-sql-driver/mysql"
+	"log/slog"
 )
 
 type Client struct {
-	db *sql.DB
+	db *DB
 }
 
-func MustNewClient(dsn string) *Client {
-	db, err := sql.Open("mysql", dsn)
+func MustNewClient(driver, dsn string) *Client {
+	dialect, err := NewDialect(driver)
 	if err != nil {
 		panic(err)
 	}
 
-	db.SetMaxOpenConns(10)
-	db.SetConnMaxLifetime(time.Minute * 3)
+	db, err := dialect.Open(dsn)
+	if err != nil {
+		panic(err)
+	}
 
+	// the app starts anyway, /readyz reports the database until it's available
 	if err := db.Ping(); err != nil {
-		log.Fatal("database is not response:", err)
+		slog.Warn("database is not available", "driver", dialect.Name(), "err", err)
 	}
 
-	return &Client{db: db}
+	return &Client{db: NewDB(db, dialect)}
 }
-func (cl *Client) DB() *sql.DB {
+func (cl *Client) DB() *DB {
 	return cl.db
 }
 
@@ -38,7 +36,7 @@ func (cl *Client) Shutdown() error {
 		return logger.Error("storage.Client", "Shutdown", err)
 	}
 
-	fmt.Println("DB Shutdown")
+	slog.Info("DB Shutdown")
 
 	return nil
 }
@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var errNullString = errors.New("sql: converting NULL to string is unsupported")
+
+// DB is the connection pool which adapts queries of the repositories to the dialect
+type DB struct {
+	*sql.DB
+	dialect Dialect
+}
+
+// Rows scans datetime columns as strings in time.DateTime format, whatever type the driver returns
+type Rows struct {
+	*sql.Rows
+}
+
+type Row struct {
+	*sql.Row
+}
+
+func NewDB(db *sql.DB, dialect Dialect) *DB {
+	return &DB{DB: db, dialect: dialect}
+}
+
+func (db *DB) Dialect() Dialect {
+	return db.dialect
+}
+
+// IsDuplicate reports whether the error is a violation of a unique key
+func (db *DB) IsDuplicate(err error) bool {
+	return db.dialect.IsDuplicate(err)
+}
+
+func (db *DB) Prepare(query string) (*sql.Stmt, error) {
+	return db.DB.Prepare(db.dialect.Rebind(query))
+}
+
+func (db *DB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return db.DB.PrepareContext(ctx, db.dialect.Rebind(query))
+}
+
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	return db.DB.Exec(db.dialect.Rebind(query), args...)
+}
+
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return db.DB.ExecContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *DB) Query(query string, args ...any) (*Rows, error) {
+	return db.QueryContext(context.Background(), query, args...)
+}
+
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*Rows, error) {
+	rows, err := db.DB.QueryContext(ctx, db.dialect.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rows{Rows: rows}, nil
+}
+
+func (db *DB) QueryRow(query string, args ...any) *Row {
+	return db.QueryRowContext(context.Background(), query, args...)
+}
+
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *Row {
+	return &Row{Row: db.DB.QueryRowContext(ctx, db.dialect.Rebind(query), args...)}
+}
+
+// Insert executes the INSERT query and returns id of the inserted row
+func (db *DB) Insert(query string, args ...any) (int64, error) {
+	return db.InsertContext(context.Background(), query, args...)
+}
+
+func (db *DB) InsertContext(ctx context.Context, query string, args ...any) (int64, error) {
+	if db.dialect.Returning() {
+		var id int64
+		err := db.DB.QueryRowContext(ctx, db.dialect.Rebind(query)+" RETURNING id", args...).Scan(&id)
+
+		return id, err
+	}
+
+	exec, err := db.DB.ExecContext(ctx, db.dialect.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return exec.LastInsertId()
+}
+
+func (r *Rows) Scan(dest ...any) error {
+	return r.Rows.Scan(dateTimeDest(dest)...)
+}
+
+func (r *Row) Scan(dest ...any) error {
+	return r.Row.Scan(dateTimeDest(dest)...)
+}
+
+// dateTimeDest wraps string destinations, so time.Time values are formatted like MySQL DATETIME
+func dateTimeDest(dest []any) []any {
+	wrapped := make([]any, len(dest))
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string, *sql.NullString:
+			wrapped[i] = dateTimeString{dest: d}
+		default:
+			wrapped[i] = d
+		}
+	}
+
+	return wrapped
+}
+
+type dateTimeString struct {
+	dest any
+}
+
+func (d dateTimeString) Scan(src any) error {
+	// PostgreSQL timestamp and SQLite DATETIME are returned as time.Time in UTC holding the stored local time
+	if t, ok := src.(time.Time); ok {
+		src = t.Format(time.DateTime)
+	}
+
+	var s sql.NullString
+	if err := s.Scan(src); err != nil {
+		return err
+	}
+
+	switch dest := d.dest.(type) {
+	case *sql.NullString:
+		*dest = s
+	case *string:
+		if !s.Valid {
+			return errNullString
+		}
+
+		*dest = s.String
+	}
+
+	return nil
+}
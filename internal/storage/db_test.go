@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestNumberedPlaceholders(t *testing.T) {
+	got := numberedPlaceholders("SELECT id FROM users WHERE email LIKE ? ESCAPE '?' AND role = ? AND \"a?\" = ?")
+	want := "SELECT id FROM users WHERE email LIKE $1 ESCAPE '?' AND role = $2 AND \"a?\" = $3"
+
+	if got != want {
+		t.Errorf("placeholders in literals must be kept\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
+func TestDateTimeDest(t *testing.T) {
+	var (
+		createdAt  string
+		verifiedAt sql.NullString
+		disabledAt sql.NullString
+		id         int64
+	)
+
+	dest := dateTimeDest([]any{&createdAt, &verifiedAt, &disabledAt, &id})
+	if dest[3] != &id {
+		t.Error("not string destinations must not be wrapped")
+	}
+
+	stored := time.Date(2026, 10, 18, 9, 7, 0, 0, time.UTC)
+	for i, src := range []any{stored, []byte("2026-10-18 09:07:00"), nil} {
+		if err := dest[i].(sql.Scanner).Scan(src); err != nil {
+			t.Fatalf("error while scan %v: %v", src, err)
+		}
+	}
+
+	if createdAt != "2026-10-18 09:07:00" || verifiedAt.String != "2026-10-18 09:07:00" {
+		t.Errorf("datetime must be scanned in time.DateTime format, got: %q, %q", createdAt, verifiedAt.String)
+	}
+
+	if disabledAt.Valid {
+		t.Error("NULL must be scanned as invalid string")
+	}
+
+	if err := dest[0].(sql.Scanner).Scan(nil); err == nil {
+		t.Error("NULL must not be scanned to string")
+	}
+}
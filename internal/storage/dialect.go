@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DriverMySQL    = "mysql"
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// Dialect hides differences of the databases from the repositories.
+// Repositories write queries with ? placeholders, datetime columns hold local time in time.DateTime format
+type Dialect interface {
+	// Name is the driver in the config, migrations of the database are in db/migrations/<name>
+	Name() string
+	// Open opens the connection pool configured for the database
+	Open(dsn string) (*sql.DB, error)
+	// Rebind replaces ? placeholders with placeholders of the database
+	Rebind(query string) string
+	// IsDuplicate reports whether the error is a violation of a unique key
+	IsDuplicate(err error) bool
+	// Returning reports whether ids of inserted rows are returned by RETURNING instead of LastInsertId
+	Returning() bool
+}
+
+func NewDialect(driver string) (Dialect, error) {
+	switch driver {
+	case DriverMySQL, "":
+		return mysqlDialect{}, nil
+	case DriverPostgres:
+		return postgresDialect{}, nil
+	case DriverSQLite:
+		return newSQLiteDialect()
+	default:
+		return nil, fmt.Errorf("unknown database driver %q, must be one of: %s, %s, %s", driver, DriverMySQL, DriverPostgres, DriverSQLite)
+	}
+}
+
+// Now returns the current time in the format of datetime columns
+func Now() string {
+	return time.Now().Format(time.DateTime)
+}
+
+func openPool(driverName, dsn string) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(10)
+	db.SetConnMaxLifetime(time.Minute * 3)
+
+	return db, nil
+}
+
+// numberedPlaceholders replaces ? outside of quoted literals with $1, $2 ...
+func numberedPlaceholders(query string) string {
+	var (
+		b     strings.Builder
+		n     int
+		quote rune
+	)
+
+	b.Grow(len(query) + 8)
+
+	for _, r := range query {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+
+			continue
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/go-sql-driver/mysql"
+)
+
+// erDupEntry is the MySQL error of a duplicate value of a unique key
+const erDupEntry = 1062
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return DriverMySQL
+}
+
+func (mysqlDialect) Open(dsn string) (*sql.DB, error) {
+	return openPool("mysql", dsn)
+}
+
+func (mysqlDialect) Rebind(query string) string {
+	return query
+}
+
+func (mysqlDialect) IsDuplicate(err error) bool {
+	var mysqlErr *mysql.MySQLError
+
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == erDupEntry
+}
+
+func (mysqlDialect) Returning() bool {
+	return false
+}
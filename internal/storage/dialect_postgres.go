@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// uniqueViolation is the PostgreSQL error code of a duplicate value of a unique key
+const uniqueViolation = "23505"
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return DriverPostgres
+}
+
+func (postgresDialect) Open(dsn string) (*sql.DB, error) {
+	return openPool("pgx", dsn)
+}
+
+func (postgresDialect) Rebind(query string) string {
+	return numberedPlaceholders(query)
+}
+
+func (postgresDialect) IsDuplicate(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+func (postgresDialect) Returning() bool {
+	return true
+}
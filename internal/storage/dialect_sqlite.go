@@ -0,0 +1,60 @@
+//go:build cgo
+
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDriverName is the driver with foreign keys enabled, SQLite doesn't enforce them by default
+const sqliteDriverName = "sqlite3_fk"
+
+func init() {
+	sql.Register(sqliteDriverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			_, err := conn.Exec("PRAGMA foreign_keys = ON; PRAGMA busy_timeout = 5000", nil)
+
+			return err
+		},
+	})
+}
+
+type sqliteDialect struct{}
+
+func newSQLiteDialect() (Dialect, error) {
+	return sqliteDialect{}, nil
+}
+
+func (sqliteDialect) Name() string {
+	return DriverSQLite
+}
+
+// Open opens the pool of one connection: SQLite allows one writer at a time
+// and every connection to :memory: is a separate database
+func (sqliteDialect) Open(dsn string) (*sql.DB, error) {
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	return db, nil
+}
+
+func (sqliteDialect) Rebind(query string) string {
+	return query
+}
+
+func (sqliteDialect) IsDuplicate(err error) bool {
+	var sqliteErr sqlite3.Error
+
+	return errors.As(err, &sqliteErr) &&
+		(sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique || sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey)
+}
+
+func (sqliteDialect) Returning() bool {
+	return false
+}
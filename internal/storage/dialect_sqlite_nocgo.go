@@ -0,0 +1,9 @@
+//go:build !cgo
+
+package storage
+
+import "errors"
+
+func newSQLiteDialect() (Dialect, error) {
+	return nil, errors.New("sqlite driver requires the binary built with CGO_ENABLED=1")
+}
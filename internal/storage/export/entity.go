@@ -0,0 +1,22 @@
+package export
+
+import "database/sql"
+
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+)
+
+type Export struct {
+	Id         int64
+	UserId     int64
+	JobId      int64 // the job writing the archive
+	Format     string
+	Paths      string // JSON of exported paths
+	Status     string
+	ObjectKey  string // the archive in the bucket, set when it's ready
+	Size       int64
+	CreatedAt  string
+	FinishedAt sql.NullString
+	ExpiresAt  string // the export and its archive are removed after it
+}
@@ -0,0 +1,208 @@
+package export
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+const exportColumns = "id, user_id, job_id, format, paths, status, object_key, size, created_at, finished_at, " +
+	"expires_at"
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "export.repository",
+		db:  db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, e Export) (Export, error) {
+	const op = "Create"
+
+	id, err := r.db.InsertContext(
+		ctx,
+		"INSERT INTO exports (user_id, format, paths, status, expires_at) VALUES (?, ?, ?, ?, ?)",
+		e.UserId, e.Format, e.Paths, e.Status, e.ExpiresAt,
+	)
+	if err != nil {
+		return Export{}, logger.Error(r.pkg, op, err)
+	}
+
+	return r.Find(ctx, id)
+}
+
+// SetJob links the export to the job writing its archive
+func (r *Repository) SetJob(ctx context.Context, id, jobId int64) error {
+	const op = "SetJob"
+
+	_, err := r.db.ExecContext(ctx, "UPDATE exports SET job_id = ? WHERE id = ?", jobId, id)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ById(ctx context.Context, userId, id int64) (Export, error) {
+	const op = "ById"
+
+	e, err := r.scanExport(r.db.QueryRowContext(
+		ctx,
+		"SELECT "+exportColumns+" FROM exports WHERE id = ? AND user_id = ?",
+		id,
+		userId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Export{}, storage.ErrNotFound
+		}
+
+		return Export{}, logger.Error(r.pkg, op, err)
+	}
+
+	return e, nil
+}
+
+// Find returns the export of any user, it's used by the job and by download links which don't know the user
+func (r *Repository) Find(ctx context.Context, id int64) (Export, error) {
+	const op = "Find"
+
+	e, err := r.scanExport(r.db.QueryRowContext(ctx, "SELECT "+exportColumns+" FROM exports WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Export{}, storage.ErrNotFound
+		}
+
+		return Export{}, logger.Error(r.pkg, op, err)
+	}
+
+	return e, nil
+}
+
+// ByUserId returns exports of the user, newest first
+func (r *Repository) ByUserId(ctx context.Context, userId int64) ([]Export, error) {
+	const op = "ByUserId"
+
+	exports, err := r.query(ctx, "SELECT "+exportColumns+" FROM exports WHERE user_id = ? ORDER BY id DESC", userId)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return exports, nil
+}
+
+// Expired returns exports which have expired by the time, oldest first
+func (r *Repository) Expired(ctx context.Context, now string, limit int) ([]Export, error) {
+	const op = "Expired"
+
+	exports, err := r.query(
+		ctx,
+		"SELECT "+exportColumns+" FROM exports WHERE expires_at < ? ORDER BY expires_at LIMIT ?",
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return exports, nil
+}
+
+// Ready saves the archive of the pending export. It returns storage.ErrNotFound if the export
+// has been deleted meanwhile
+func (r *Repository) Ready(ctx context.Context, id int64, objectKey string, size int64, now, expiresAt string) error {
+	const op = "Ready"
+
+	exec, err := r.db.ExecContext(
+		ctx,
+		"UPDATE exports SET status = ?, object_key = ?, size = ?, finished_at = ?, expires_at = ? "+
+			"WHERE id = ? AND status = ?",
+		StatusReady,
+		objectKey,
+		size,
+		now,
+		expiresAt,
+		id,
+		StatusPending,
+	)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) Delete(ctx context.Context, id int64) error {
+	const op = "Delete"
+
+	_, err := r.db.ExecContext(ctx, "DELETE FROM exports WHERE id = ?", id)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (r *Repository) query(ctx context.Context, query string, args ...any) ([]Export, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, "query", err)
+		}
+	}(rows)
+
+	exports := []Export{}
+	for rows.Next() {
+		e, err := r.scanExport(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		exports = append(exports, e)
+	}
+
+	return exports, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *Repository) scanExport(row scanner) (Export, error) {
+	var e Export
+	err := row.Scan(
+		&e.Id,
+		&e.UserId,
+		&e.JobId,
+		&e.Format,
+		&e.Paths,
+		&e.Status,
+		&e.ObjectKey,
+		&e.Size,
+		&e.CreatedAt,
+		&e.FinishedAt,
+		&e.ExpiresAt,
+	)
+
+	return e, err
+}
@@ -0,0 +1,16 @@
+package filechange
+
+import "database/sql"
+
+type Entry struct {
+	Id        int64
+	UserId    int64
+	Seq       int64 // number of the change among changes of the user, starts from 1 without gaps
+	Action    string
+	Path      string
+	FromPath  sql.NullString // source of move
+	ETag      string
+	Size      int64
+	CreatedAt string
+	ExpiresAt string
+}
@@ -0,0 +1,151 @@
+package filechange
+
+import (
+	"context"
+	"database/sql"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+const columns = "id, user_id, seq, action, path, from_path, etag, size, created_at, expires_at"
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "filechange.repository",
+		db:  db,
+	}
+}
+
+// Create appends the entry with the next seq of the user and returns the seq. The seq is taken in the same query,
+// so storage.ErrDuplicateNotAllowed is returned if another entry of the user has taken it at the same time
+func (r *Repository) Create(ctx context.Context, entry Entry) (int64, error) {
+	const op = "Create"
+
+	id, err := r.db.InsertContext(
+		ctx,
+		"INSERT INTO file_changes (user_id, seq, action, path, from_path, etag, size, created_at, expires_at) "+
+			"SELECT ?, COALESCE(MAX(seq), 0) + 1, ?, ?, ?, ?, ?, ?, ? FROM file_changes WHERE user_id = ?",
+		entry.UserId,
+		entry.Action,
+		entry.Path,
+		entry.FromPath,
+		entry.ETag,
+		entry.Size,
+		entry.CreatedAt,
+		entry.ExpiresAt,
+		entry.UserId,
+	)
+	if err != nil {
+		if r.db.IsDuplicate(err) {
+			return 0, storage.ErrDuplicateNotAllowed
+		}
+
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	var seq int64
+	if err := r.db.QueryRowContext(ctx, "SELECT seq FROM file_changes WHERE id = ?", id).Scan(&seq); err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return seq, nil
+}
+
+// Since returns entries of the user with seq after since up to until inclusive, oldest first
+func (r *Repository) Since(ctx context.Context, userId, since, until int64, limit int) ([]Entry, error) {
+	const op = "Since"
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+columns+" FROM file_changes WHERE user_id = ? AND seq > ? AND seq <= ? ORDER BY seq LIMIT ?",
+		userId,
+		since,
+		until,
+		limit,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	entries := []Entry{}
+	for rows.Next() {
+		var e Entry
+		err := rows.Scan(
+			&e.Id, &e.UserId, &e.Seq, &e.Action, &e.Path, &e.FromPath, &e.ETag, &e.Size, &e.CreatedAt, &e.ExpiresAt,
+		)
+		if err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return entries, nil
+}
+
+// Bounds returns the oldest and the newest seq of entries of the user, zeros if there are no entries
+func (r *Repository) Bounds(ctx context.Context, userId int64) (int64, int64, error) {
+	const op = "Bounds"
+
+	var first, last int64
+
+	err := r.db.QueryRowContext(
+		ctx,
+		"SELECT COALESCE(MIN(seq), 0), COALESCE(MAX(seq), 0) FROM file_changes WHERE user_id = ?",
+		userId,
+	).Scan(&first, &last)
+	if err != nil {
+		return 0, 0, logger.Error(r.pkg, op, err)
+	}
+
+	return first, last, nil
+}
+
+// DeleteExpired removes expired entries and returns their number. The newest entry of each user is kept,
+// so seq of the user keeps growing after the removal
+func (r *Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	// MySQL doesn't allow the table in a subquery of DELETE, unless the subquery is materialized
+	stmt, err := r.db.PrepareContext(
+		ctx,
+		"DELETE FROM file_changes WHERE expires_at < ? AND id NOT IN "+
+			"(SELECT id FROM (SELECT MAX(id) AS id FROM file_changes GROUP BY user_id) latest)",
+	)
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.ExecContext(ctx, storage.Now())
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,38 @@
+package job
+
+import "database/sql"
+
+const (
+	StatusQueued    = "queued"
+	StatusRunning   = "running"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+	StatusCancelled = "cancelled"
+)
+
+type Job struct {
+	Id                int64
+	UserId            int64 // 0 for jobs of the system
+	Type              string
+	Payload           string // JSON of the handler's payload
+	Status            string
+	ProgressDone      int64
+	ProgressTotal     int64 // zero while the total isn't known
+	Result            sql.NullString
+	Error             string
+	Attempts          int
+	MaxAttempts       int
+	RunAt             string // the job isn't started before it
+	LeaseId           string // the run which claimed the job, it owns the job until the lease expires
+	LockedUntil       sql.NullString
+	CancelRequestedAt sql.NullString
+	CreatedAt         string
+	StartedAt         sql.NullString
+	FinishedAt        sql.NullString
+	ExpiresAt         sql.NullString // finished jobs are removed after it
+}
+
+// Finished tells if the job won't change anymore
+func (j Job) Finished() bool {
+	return j.Status == StatusSucceeded || j.Status == StatusFailed || j.Status == StatusCancelled
+}
@@ -0,0 +1,365 @@
+package job
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+const jobColumns = "id, user_id, type, payload, status, progress_done, progress_total, result, error, attempts, " +
+	"max_attempts, run_at, lease_id, locked_until, cancel_requested_at, created_at, started_at, finished_at, expires_at"
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "job.repository",
+		db:  db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, j Job) (Job, error) {
+	const op = "Create"
+
+	id, err := r.db.InsertContext(
+		ctx,
+		"INSERT INTO jobs (user_id, type, payload, status, max_attempts, run_at) VALUES (?, ?, ?, ?, ?, ?)",
+		sql.NullInt64{Int64: j.UserId, Valid: j.UserId != 0}, j.Type, j.Payload, j.Status, j.MaxAttempts, j.RunAt,
+	)
+	if err != nil {
+		return Job{}, logger.Error(r.pkg, op, err)
+	}
+
+	return r.Find(ctx, id)
+}
+
+func (r *Repository) ById(ctx context.Context, userId, id int64) (Job, error) {
+	const op = "ById"
+
+	j, err := r.scanJob(r.db.QueryRowContext(
+		ctx,
+		"SELECT "+jobColumns+" FROM jobs WHERE id = ? AND user_id = ?",
+		id,
+		userId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, storage.ErrNotFound
+		}
+
+		return Job{}, logger.Error(r.pkg, op, err)
+	}
+
+	return j, nil
+}
+
+// Find returns the job of any user, it's used by workers which don't know the user
+func (r *Repository) Find(ctx context.Context, id int64) (Job, error) {
+	const op = "Find"
+
+	j, err := r.scanJob(r.db.QueryRowContext(ctx, "SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Job{}, storage.ErrNotFound
+		}
+
+		return Job{}, logger.Error(r.pkg, op, err)
+	}
+
+	return j, nil
+}
+
+// ByUserId returns the page of jobs of the user, newest first, and the total number of them
+func (r *Repository) ByUserId(ctx context.Context, userId int64, limit, offset int) ([]Job, int64, error) {
+	const op = "ByUserId"
+
+	var total int64
+
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM jobs WHERE user_id = ?", userId).Scan(&total)
+	if err != nil {
+		return nil, 0, logger.Error(r.pkg, op, err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+jobColumns+" FROM jobs WHERE user_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		userId,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	jobs := []Job{}
+	for rows.Next() {
+		j, err := r.scanJob(rows)
+		if err != nil {
+			return nil, 0, logger.Error(r.pkg, op, err)
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, logger.Error(r.pkg, op, err)
+	}
+
+	return jobs, total, nil
+}
+
+// Due returns ids of queued jobs which should be started at the time and of running jobs whose lease
+// has expired, e.g. because the replica running them has crashed, oldest first
+func (r *Repository) Due(ctx context.Context, now string, limit int) ([]int64, error) {
+	const op = "Due"
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id FROM jobs WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?) "+
+			"ORDER BY run_at LIMIT ?",
+		StatusQueued,
+		now,
+		StatusRunning,
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return ids, nil
+}
+
+// Claim starts the due job under the lease until the time and counts the attempt. It returns false if another
+// worker, possibly of another replica, has claimed it first
+func (r *Repository) Claim(ctx context.Context, id int64, leaseId, now, lockedUntil string) (bool, error) {
+	const op = "Claim"
+
+	affected, err := r.exec(
+		ctx,
+		"UPDATE jobs SET status = ?, lease_id = ?, locked_until = ?, attempts = attempts + 1, "+
+			"started_at = COALESCE(started_at, ?) "+
+			"WHERE id = ? AND ((status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?))",
+		StatusRunning,
+		leaseId,
+		lockedUntil,
+		now,
+		id,
+		StatusQueued,
+		now,
+		StatusRunning,
+		now,
+	)
+	if err != nil {
+		return false, logger.Error(r.pkg, op, err)
+	}
+
+	return affected == 1, nil
+}
+
+// Renew extends the lease of the running job and saves its progress. It returns storage.ErrNotFound if the lease
+// has been lost and whether the user has asked to cancel the job otherwise
+func (r *Repository) Renew(
+	ctx context.Context,
+	id int64,
+	leaseId, lockedUntil string,
+	done, total int64,
+) (bool, error) {
+	const op = "Renew"
+
+	affected, err := r.exec(
+		ctx,
+		"UPDATE jobs SET locked_until = ?, progress_done = ?, progress_total = ? "+
+			"WHERE id = ? AND lease_id = ? AND status = ?",
+		lockedUntil,
+		done,
+		total,
+		id,
+		leaseId,
+		StatusRunning,
+	)
+	if err != nil {
+		return false, logger.Error(r.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return false, storage.ErrNotFound
+	}
+
+	var cancelRequestedAt sql.NullString
+	err = r.db.QueryRowContext(ctx, "SELECT cancel_requested_at FROM jobs WHERE id = ?", id).Scan(&cancelRequestedAt)
+	if err != nil {
+		return false, logger.Error(r.pkg, op, err)
+	}
+
+	return cancelRequestedAt.Valid, nil
+}
+
+// Release saves the outcome of the run and releases the lease: the job is either finished or queued again
+// at RunAt. It returns storage.ErrNotFound if the lease has been lost
+func (r *Repository) Release(ctx context.Context, j Job) error {
+	const op = "Release"
+
+	affected, err := r.exec(
+		ctx,
+		"UPDATE jobs SET status = ?, progress_done = ?, progress_total = ?, result = ?, error = ?, attempts = ?, "+
+			"run_at = ?, lease_id = '', locked_until = NULL, finished_at = ?, expires_at = ? "+
+			"WHERE id = ? AND lease_id = ?",
+		j.Status,
+		j.ProgressDone,
+		j.ProgressTotal,
+		j.Result,
+		j.Error,
+		j.Attempts,
+		j.RunAt,
+		j.FinishedAt,
+		j.ExpiresAt,
+		j.Id,
+		j.LeaseId,
+	)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// CancelQueued cancels the job of the user if it hasn't been started yet
+func (r *Repository) CancelQueued(ctx context.Context, userId, id int64, now, expiresAt string) (bool, error) {
+	const op = "CancelQueued"
+
+	affected, err := r.exec(
+		ctx,
+		"UPDATE jobs SET status = ?, cancel_requested_at = ?, finished_at = ?, expires_at = ? "+
+			"WHERE id = ? AND user_id = ? AND status = ?",
+		StatusCancelled,
+		now,
+		now,
+		expiresAt,
+		id,
+		userId,
+		StatusQueued,
+	)
+	if err != nil {
+		return false, logger.Error(r.pkg, op, err)
+	}
+
+	return affected == 1, nil
+}
+
+// RequestCancel marks the running job of the user, the worker running it stops it when it sees the mark
+func (r *Repository) RequestCancel(ctx context.Context, userId, id int64, now string) (bool, error) {
+	const op = "RequestCancel"
+
+	affected, err := r.exec(
+		ctx,
+		"UPDATE jobs SET cancel_requested_at = ? "+
+			"WHERE id = ? AND user_id = ? AND status = ? AND cancel_requested_at IS NULL",
+		now,
+		id,
+		userId,
+		StatusRunning,
+	)
+	if err != nil {
+		return false, logger.Error(r.pkg, op, err)
+	}
+
+	return affected == 1, nil
+}
+
+// DeleteExpired removes finished jobs after they expire and returns their number
+func (r *Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	affected, err := r.exec(
+		ctx,
+		"DELETE FROM jobs WHERE expires_at < ? AND status IN (?, ?, ?)",
+		storage.Now(),
+		StatusSucceeded,
+		StatusFailed,
+		StatusCancelled,
+	)
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return affected, nil
+}
+
+// exec runs the statement and returns the number of affected rows
+func (r *Repository) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	exec, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return exec.RowsAffected()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *Repository) scanJob(row scanner) (Job, error) {
+	var j Job
+	var userId sql.NullInt64
+	err := row.Scan(
+		&j.Id,
+		&userId,
+		&j.Type,
+		&j.Payload,
+		&j.Status,
+		&j.ProgressDone,
+		&j.ProgressTotal,
+		&j.Result,
+		&j.Error,
+		&j.Attempts,
+		&j.MaxAttempts,
+		&j.RunAt,
+		&j.LeaseId,
+		&j.LockedUntil,
+		&j.CancelRequestedAt,
+		&j.CreatedAt,
+		&j.StartedAt,
+		&j.FinishedAt,
+		&j.ExpiresAt,
+	)
+	j.UserId = userId.Int64
+
+	return j, err
+}
@@ -0,0 +1,60 @@
+package migration
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "migration.repository",
+		db:  db,
+	}
+}
+
+// AppliedVersions returns versions of the migrations applied by goose
+func (m *Repository) AppliedVersions(ctx context.Context) (map[int64]bool, error) {
+	const op = "AppliedVersions"
+
+	// goose adds a row on every up and down, the last row of the version is its state
+	rows, err := m.db.QueryContext(ctx, "SELECT version_id, is_applied FROM goose_db_version ORDER BY id")
+	if err != nil {
+		return nil, logger.Error(m.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(m.pkg, op, err)
+		}
+	}(rows)
+
+	applied := map[int64]bool{}
+	for rows.Next() {
+		var (
+			version   int64
+			isApplied bool
+		)
+
+		if err := rows.Scan(&version, &isApplied); err != nil {
+			return nil, logger.Error(m.pkg, op, err)
+		}
+
+		if isApplied {
+			applied[version] = true
+		} else {
+			delete(applied, version)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(m.pkg, op, err)
+	}
+
+	return applied, nil
+}
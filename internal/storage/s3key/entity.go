@@ -0,0 +1,13 @@
+package s3key
+
+import "database/sql"
+
+type S3Key struct {
+	Id              int64
+	UserId          int64
+	Name            string
+	AccessKeyId     string
+	SecretEncrypted string // base64 of the nonce and the secret access key encrypted with AES-GCM
+	LastUsedAt      sql.NullString
+	CreatedAt       string
+}
@@ -0,0 +1,13 @@
+package sshkey
+
+import "database/sql"
+
+type SSHKey struct {
+	Id          int64
+	UserId      int64
+	Name        string
+	PublicKey   string // authorized_keys format without the comment
+	Fingerprint string // SHA256 fingerprint like OpenSSH shows it
+	LastUsedAt  sql.NullString
+	CreatedAt   string
+}
@@ -0,0 +1,179 @@
+package sshkey
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "sshkey.repository",
+		db:  db,
+	}
+}
+
+func (r *Repository) Create(key SSHKey) (SSHKey, error) {
+	const op = "Create"
+
+	id, err := r.db.Insert(
+		"INSERT INTO ssh_keys (user_id, name, public_key, fingerprint) VALUES (?, ?, ?, ?)",
+		key.UserId, key.Name, key.PublicKey, key.Fingerprint,
+	)
+	if err != nil {
+		// check if error is because fingerprint duplicate
+		if r.db.IsDuplicate(err) {
+			return SSHKey{}, storage.ErrDuplicateNotAllowed
+		}
+
+		return SSHKey{}, logger.Error(r.pkg, op, err)
+	}
+
+	return r.ById(key.UserId, id)
+}
+
+func (r *Repository) ById(userId, keyId int64) (SSHKey, error) {
+	const op = "ById"
+
+	k, err := r.scan(r.db.QueryRow(
+		"SELECT id, user_id, name, public_key, fingerprint, last_used_at, created_at "+
+			"FROM ssh_keys WHERE id = ? AND user_id = ?",
+		keyId,
+		userId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SSHKey{}, storage.ErrNotFound
+		}
+
+		return SSHKey{}, logger.Error(r.pkg, op, err)
+	}
+
+	return k, nil
+}
+
+func (r *Repository) ByFingerprint(fingerprint string) (SSHKey, error) {
+	const op = "ByFingerprint"
+
+	// keys of disabled users are not found
+	k, err := r.scan(r.db.QueryRow(
+		"SELECT k.id, k.user_id, k.name, k.public_key, k.fingerprint, k.last_used_at, k.created_at "+
+			"FROM ssh_keys k INNER JOIN users u ON u.id = k.user_id "+
+			"WHERE k.fingerprint = ? AND u.disabled_at IS NULL",
+		fingerprint,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return SSHKey{}, storage.ErrNotFound
+		}
+
+		return SSHKey{}, logger.Error(r.pkg, op, err)
+	}
+
+	return k, nil
+}
+
+func (r *Repository) ByUserId(userId int64) ([]SSHKey, error) {
+	const op = "ByUserId"
+
+	rows, err := r.db.Query(
+		"SELECT id, user_id, name, public_key, fingerprint, last_used_at, created_at "+
+			"FROM ssh_keys WHERE user_id = ? ORDER BY id DESC",
+		userId,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	keys := []SSHKey{}
+	for rows.Next() {
+		k, err := r.scan(rows)
+		if err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		keys = append(keys, k)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return keys, nil
+}
+
+func (r *Repository) Delete(userId, keyId int64) error {
+	const op = "Delete"
+
+	stmt, err := r.db.Prepare("DELETE FROM ssh_keys WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.Exec(keyId, userId)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) UpdateLastUsed(keyId int64) error {
+	const op = "UpdateLastUsed"
+
+	stmt, err := r.db.Prepare("UPDATE ssh_keys SET last_used_at = ? WHERE id = ?")
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.Exec(storage.Now(), keyId)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *Repository) scan(row scanner) (SSHKey, error) {
+	var k SSHKey
+	err := row.Scan(&k.Id, &k.UserId, &k.Name, &k.PublicKey, &k.Fingerprint, &k.LastUsedAt, &k.CreatedAt)
+
+	return k, err
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// StartSpan starts a span of the repository query on the db, name is "<table>.<operation>"
+func StartSpan(ctx context.Context, db *DB, name string) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(
+		ctx,
+		name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(dbSystem(db.Dialect().Name())),
+	)
+}
+
+// EndSpan ends the span of the query, not found rows and duplicates are expected results, not failures
+func EndSpan(span trace.Span, err error) {
+	tracing.End(span, err, ErrNotFound, ErrDuplicateNotAllowed)
+}
+
+func dbSystem(driver string) attribute.KeyValue {
+	switch driver {
+	case DriverPostgres:
+		return semconv.DBSystemNamePostgreSQL
+	case DriverSQLite:
+		return semconv.DBSystemNameSQLite
+	default:
+		return semconv.DBSystemNameMySQL
+	}
+}
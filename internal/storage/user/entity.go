@@ -3,7 +3,15 @@ package user
 import "database/sql"
 
 type User struct {
-	Id       int64
-	Email    sql.NullString
-	Password string
+	Id              int64
+	Email           sql.NullString
+	Password        string
+	EmailVerifiedAt sql.NullString
+
+	PasswordLoginDisabled bool
+
+	Role       string
+	QuotaBytes sql.NullInt64 // NULL - default quota
+	DisabledAt sql.NullString
+	CreatedAt  string
 }
@@ -1,65 +1,55 @@
 package user
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
 	"github.com/albakov/go-cloud-file-storage/internal/storage"
-	"github.com/go-sql-driver/mysql"
+	"strings"
 )
 
+const columns = "id, email, password, email_verified_at, password_login_disabled, role, quota_bytes, disabled_at, created_at"
+
 type Repository struct {
 	pkg string
-	db  *sql.DB
+	db  *storage.DB
 }
 
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *storage.DB) *Repository {
 	return &Repository{
 		pkg: "user.repository",
 		db:  db,
 	}
 }
 
-func (u *Repository) Create(us User) (User, error) {
+func (u *Repository) Create(ctx context.Context, us User) (User, error) {
 	const op = "Create"
 
-	stmt, err := u.db.Prepare("INSERT INTO users (email, password) VALUES (?, ?)")
-	if err != nil {
-		return User{}, logger.Error(u.pkg, op, err)
-	}
-	defer func(stmt *sql.Stmt) {
-		err := stmt.Close()
-		if err != nil {
-			logger.Add(u.pkg, op, err)
-		}
-	}(stmt)
-
-	exec, err := stmt.Exec(us.Email, us.Password)
+	id, err := u.db.InsertContext(
+		ctx,
+		"INSERT INTO users (email, password) VALUES (?, ?)",
+		us.Email, us.Password,
+	)
 	if err != nil {
 		// check if error is because email duplicate
-		var mysqlErr *mysql.MySQLError
-		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
+		if u.db.IsDuplicate(err) {
 			return User{}, storage.ErrDuplicateNotAllowed
 		}
 
 		return User{}, logger.Error(u.pkg, op, err)
 	}
 
-	id, err := exec.LastInsertId()
-	if err != nil {
-		return User{}, logger.Error(u.pkg, op, err)
-	}
-
 	us.Id = id
 
 	return us, nil
 }
 
-func (u *Repository) IsExistsByEmail(email string) bool {
+func (u *Repository) IsExistsByEmail(ctx context.Context, email string) bool {
 	const op = "IsExistsByEmail"
 
 	var isExists int64
-	err := u.db.QueryRow("SELECT id FROM users WHERE email = ?", email).Scan(&isExists)
+	err := u.db.QueryRowContext(ctx, "SELECT id FROM users WHERE email = ?", email).Scan(&isExists)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return false
@@ -77,14 +67,10 @@ func (u *Repository) IsExistsByEmail(email string) bool {
 	return false
 }
 
-func (u *Repository) ByEmail(email string) (User, error) {
+func (u *Repository) ByEmail(ctx context.Context, email string) (User, error) {
 	const op = "ByEmail"
 
-	var us User
-	err := u.db.QueryRow(
-		"SELECT id, email, password FROM users WHERE email = ?",
-		email,
-	).Scan(&us.Id, &us.Email, &us.Password)
+	us, err := u.scan(u.db.QueryRowContext(ctx, "SELECT "+columns+" FROM users WHERE email = ?", email))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return User{}, storage.ErrNotFound
@@ -96,14 +82,10 @@ func (u *Repository) ByEmail(email string) (User, error) {
 	return us, nil
 }
 
-func (u *Repository) ById(userId int64) (User, error) {
+func (u *Repository) ById(ctx context.Context, userId int64) (User, error) {
 	const op = "ById"
 
-	var us User
-	err := u.db.QueryRow(
-		"SELECT id, email, password FROM users WHERE id = ?",
-		userId,
-	).Scan(&us.Id, &us.Email, &us.Password)
+	us, err := u.scan(u.db.QueryRowContext(ctx, "SELECT "+columns+" FROM users WHERE id = ?", userId))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return User{}, storage.ErrNotFound
@@ -114,3 +96,254 @@ func (u *Repository) ById(userId int64) (User, error) {
 
 	return us, nil
 }
+
+func (u *Repository) VerifyEmail(ctx context.Context, userId int64) error {
+	const op = "VerifyEmail"
+
+	stmt, err := u.db.PrepareContext(ctx, "UPDATE users SET email_verified_at = ? WHERE id = ? AND email_verified_at IS NULL")
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, storage.Now(), userId)
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (u *Repository) UpdatePassword(ctx context.Context, userId int64, password string) error {
+	const op = "UpdatePassword"
+
+	stmt, err := u.db.PrepareContext(ctx, "UPDATE users SET password = ? WHERE id = ?")
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, password, userId)
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+
+	return nil
+}
+
+// UpdateEmail sets a new confirmed email
+func (u *Repository) UpdateEmail(ctx context.Context, userId int64, email string) error {
+	const op = "UpdateEmail"
+
+	stmt, err := u.db.PrepareContext(ctx, "UPDATE users SET email = ?, email_verified_at = ? WHERE id = ?")
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, email, storage.Now(), userId)
+	if err != nil {
+		// check if error is because email duplicate
+		if u.db.IsDuplicate(err) {
+			return storage.ErrDuplicateNotAllowed
+		}
+
+		return logger.Error(u.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (u *Repository) SetPasswordLoginDisabled(ctx context.Context, userId int64, disabled bool) error {
+	const op = "SetPasswordLoginDisabled"
+
+	stmt, err := u.db.PrepareContext(ctx, "UPDATE users SET password_login_disabled = ? WHERE id = ?")
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, disabled, userId)
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+
+	return nil
+}
+
+// Search returns users whose email contains the query ordered by id, empty query matches all users
+func (u *Repository) Search(ctx context.Context, query string, limit, offset int) ([]User, error) {
+	const op = "Search"
+
+	rows, err := u.db.QueryContext(
+		ctx,
+		"SELECT "+columns+" FROM users WHERE email LIKE ? ESCAPE '!' ORDER BY id LIMIT ? OFFSET ?",
+		likePattern(query),
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, logger.Error(u.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(rows)
+
+	users := []User{}
+	for rows.Next() {
+		us, err := u.scan(rows)
+		if err != nil {
+			return nil, logger.Error(u.pkg, op, err)
+		}
+
+		users = append(users, us)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(u.pkg, op, err)
+	}
+
+	return users, nil
+}
+
+func (u *Repository) Count(ctx context.Context, query string) (int64, error) {
+	const op = "Count"
+
+	var count int64
+	err := u.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE email LIKE ? ESCAPE '!'", likePattern(query)).Scan(&count)
+	if err != nil {
+		return 0, logger.Error(u.pkg, op, err)
+	}
+
+	return count, nil
+}
+
+func (u *Repository) SetRole(ctx context.Context, userId int64, role string) error {
+	const op = "SetRole"
+
+	return u.exec(ctx, op, "UPDATE users SET role = ? WHERE id = ?", role, userId)
+}
+
+// SetRoleByEmail sets the role if the user exists and has verified the email, used to bootstrap administrators.
+// The unverified account may be registered by anyone before the owner of the email
+func (u *Repository) SetRoleByEmail(ctx context.Context, email, role string) error {
+	const op = "SetRoleByEmail"
+
+	return u.exec(
+		ctx,
+		op,
+		"UPDATE users SET role = ? WHERE email = ? AND email_verified_at IS NOT NULL",
+		role,
+		email,
+	)
+}
+
+func (u *Repository) SetQuota(ctx context.Context, userId int64, quotaBytes sql.NullInt64) error {
+	const op = "SetQuota"
+
+	return u.exec(ctx, op, "UPDATE users SET quota_bytes = ? WHERE id = ?", quotaBytes, userId)
+}
+
+func (u *Repository) SetDisabled(ctx context.Context, userId int64, disabled bool) error {
+	const op = "SetDisabled"
+
+	if disabled {
+		return u.exec(ctx, op, "UPDATE users SET disabled_at = ? WHERE id = ? AND disabled_at IS NULL", storage.Now(), userId)
+	}
+
+	return u.exec(ctx, op, "UPDATE users SET disabled_at = NULL WHERE id = ?", userId)
+}
+
+// Delete removes the user, sessions and tokens are removed by foreign keys
+func (u *Repository) Delete(ctx context.Context, userId int64) error {
+	const op = "Delete"
+
+	stmt, err := u.db.PrepareContext(ctx, "DELETE FROM users WHERE id = ?")
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, userId)
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (u *Repository) exec(ctx context.Context, op, query string, args ...any) error {
+	stmt, err := u.db.PrepareContext(ctx, query)
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(u.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return logger.Error(u.pkg, op, err)
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (u *Repository) scan(row scanner) (User, error) {
+	var us User
+	err := row.Scan(
+		&us.Id,
+		&us.Email,
+		&us.Password,
+		&us.EmailVerifiedAt,
+		&us.PasswordLoginDisabled,
+		&us.Role,
+		&us.QuotaBytes,
+		&us.DisabledAt,
+		&us.CreatedAt,
+	)
+
+	return us, err
+}
+
+// likePattern escapes LIKE wildcards in the query with "!", backslash isn't an escape character in all databases
+func likePattern(query string) string {
+	replacer := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+	return "%" + replacer.Replace(query) + "%"
+}
@@ -0,0 +1,136 @@
+package user
+
+import (
+	"context"
+	"database/sql"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+// Traced wraps the repository and starts a span of every query
+type Traced struct {
+	*Repository
+}
+
+func NewTraced(repo *Repository) *Traced {
+	return &Traced{Repository: repo}
+}
+
+func (t *Traced) Create(ctx context.Context, us User) (User, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.Create")
+	us, err := t.Repository.Create(ctx, us)
+	storage.EndSpan(span, err)
+
+	return us, err
+}
+
+func (t *Traced) IsExistsByEmail(ctx context.Context, email string) bool {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.IsExistsByEmail")
+	exists := t.Repository.IsExistsByEmail(ctx, email)
+	storage.EndSpan(span, nil)
+
+	return exists
+}
+
+func (t *Traced) ByEmail(ctx context.Context, email string) (User, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.ByEmail")
+	us, err := t.Repository.ByEmail(ctx, email)
+	storage.EndSpan(span, err)
+
+	return us, err
+}
+
+func (t *Traced) ById(ctx context.Context, userId int64) (User, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.ById")
+	us, err := t.Repository.ById(ctx, userId)
+	storage.EndSpan(span, err)
+
+	return us, err
+}
+
+func (t *Traced) VerifyEmail(ctx context.Context, userId int64) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.VerifyEmail")
+	err := t.Repository.VerifyEmail(ctx, userId)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) UpdatePassword(ctx context.Context, userId int64, password string) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.UpdatePassword")
+	err := t.Repository.UpdatePassword(ctx, userId, password)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) UpdateEmail(ctx context.Context, userId int64, email string) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.UpdateEmail")
+	err := t.Repository.UpdateEmail(ctx, userId, email)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) SetPasswordLoginDisabled(ctx context.Context, userId int64, disabled bool) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.SetPasswordLoginDisabled")
+	err := t.Repository.SetPasswordLoginDisabled(ctx, userId, disabled)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) Search(ctx context.Context, query string, limit, offset int) ([]User, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.Search")
+	users, err := t.Repository.Search(ctx, query, limit, offset)
+	storage.EndSpan(span, err)
+
+	return users, err
+}
+
+func (t *Traced) Count(ctx context.Context, query string) (int64, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.Count")
+	n, err := t.Repository.Count(ctx, query)
+	storage.EndSpan(span, err)
+
+	return n, err
+}
+
+func (t *Traced) SetRole(ctx context.Context, userId int64, role string) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.SetRole")
+	err := t.Repository.SetRole(ctx, userId, role)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) SetRoleByEmail(ctx context.Context, email, role string) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.SetRoleByEmail")
+	err := t.Repository.SetRoleByEmail(ctx, email, role)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) SetQuota(ctx context.Context, userId int64, quotaBytes sql.NullInt64) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.SetQuota")
+	err := t.Repository.SetQuota(ctx, userId, quotaBytes)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) SetDisabled(ctx context.Context, userId int64, disabled bool) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.SetDisabled")
+	err := t.Repository.SetDisabled(ctx, userId, disabled)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) Delete(ctx context.Context, userId int64) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users.Delete")
+	err := t.Repository.Delete(ctx, userId)
+	storage.EndSpan(span, err)
+
+	return err
+}
@@ -0,0 +1,10 @@
+package useridentity
+
+type Identity struct {
+	Id        int64
+	UserId    int64
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt string
+}
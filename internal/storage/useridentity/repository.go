@@ -0,0 +1,130 @@
+package useridentity
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "useridentity.repository",
+		db:  db,
+	}
+}
+
+func (ui *Repository) Create(identity Identity) (Identity, error) {
+	const op = "Create"
+
+	id, err := ui.db.Insert(
+		"INSERT INTO users_identities (user_id, provider, subject, email) VALUES (?, ?, ?, ?)",
+		identity.UserId, identity.Provider, identity.Subject, identity.Email,
+	)
+	if err != nil {
+		// the identity is linked to another user or the user has already linked the provider
+		if ui.db.IsDuplicate(err) {
+			return Identity{}, storage.ErrDuplicateNotAllowed
+		}
+
+		return Identity{}, logger.Error(ui.pkg, op, err)
+	}
+
+	identity.Id = id
+
+	return identity, nil
+}
+
+func (ui *Repository) ByProviderSubject(provider, subject string) (Identity, error) {
+	const op = "ByProviderSubject"
+
+	var identity Identity
+	err := ui.db.QueryRow(
+		"SELECT id, user_id, provider, subject, email, created_at FROM users_identities "+
+			"WHERE provider = ? AND subject = ?",
+		provider,
+		subject,
+	).Scan(&identity.Id, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Identity{}, storage.ErrNotFound
+		}
+
+		return Identity{}, logger.Error(ui.pkg, op, err)
+	}
+
+	return identity, nil
+}
+
+func (ui *Repository) ByUserId(userId int64) ([]Identity, error) {
+	const op = "ByUserId"
+
+	rows, err := ui.db.Query(
+		"SELECT id, user_id, provider, subject, email, created_at FROM users_identities WHERE user_id = ? ORDER BY id",
+		userId,
+	)
+	if err != nil {
+		return nil, logger.Error(ui.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(ui.pkg, op, err)
+		}
+	}(rows)
+
+	identities := []Identity{}
+	for rows.Next() {
+		var identity Identity
+		err := rows.Scan(
+			&identity.Id, &identity.UserId, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt,
+		)
+		if err != nil {
+			return nil, logger.Error(ui.pkg, op, err)
+		}
+
+		identities = append(identities, identity)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(ui.pkg, op, err)
+	}
+
+	return identities, nil
+}
+
+func (ui *Repository) Delete(userId, identityId int64) error {
+	const op = "Delete"
+
+	stmt, err := ui.db.Prepare("DELETE FROM users_identities WHERE id = ? AND user_id = ?")
+	if err != nil {
+		return logger.Error(ui.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(ui.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.Exec(identityId, userId)
+	if err != nil {
+		return logger.Error(ui.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return logger.Error(ui.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
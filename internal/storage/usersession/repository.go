@@ -1,30 +1,31 @@
 package usersession
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"github.com/albakov/go-cloud-file-storage/internal/logger"
 	"github.com/albakov/go-cloud-file-storage/internal/storage"
-	"github.com/go-sql-driver/mysql"
 )
 
 type Repository struct {
 	pkg string
-	db  *sql.DB
+	db  *storage.DB
 }
 
-func NewRepository(db *sql.DB) *Repository {
+func NewRepository(db *storage.DB) *Repository {
 	return &Repository{
 		pkg: "usersession.repository",
 		db:  db,
 	}
 }
 
-func (us *Repository) ByRefreshToken(refreshToken string) (Session, error) {
+func (us *Repository) ByRefreshToken(ctx context.Context, refreshToken string) (Session, error) {
 	const op = "ByRefreshToken"
 
 	var s Session
-	err := us.db.QueryRow(
+	err := us.db.QueryRowContext(
+		ctx,
 		"SELECT id, user_id, refresh_token, expires_at FROM users_sessions WHERE refresh_token = ?",
 		refreshToken,
 	).Scan(&s.Id, &s.UserId, &s.RefreshToken, &s.ExpiredAt)
@@ -39,13 +40,35 @@ func (us *Repository) ByRefreshToken(refreshToken string) (Session, error) {
 	return s, nil
 }
 
-func (us *Repository) Create(userSession Session) (Session, error) {
+func (us *Repository) Create(ctx context.Context, userSession Session) (Session, error) {
 	const op = "Create"
 
-	stmt, err := us.db.Prepare("INSERT INTO users_sessions (user_id, refresh_token, expires_at) VALUES (?, ?, ?)")
+	id, err := us.db.InsertContext(
+		ctx,
+		"INSERT INTO users_sessions (user_id, refresh_token, expires_at) VALUES (?, ?, ?)",
+		userSession.UserId, userSession.RefreshToken, userSession.ExpiredAt,
+	)
 	if err != nil {
+		// check if error is because refresh_token duplicate
+		if us.db.IsDuplicate(err) {
+			return Session{}, storage.ErrDuplicateNotAllowed
+		}
+
 		return Session{}, logger.Error(us.pkg, op, err)
 	}
+
+	userSession.Id = id
+
+	return userSession, nil
+}
+
+func (us *Repository) Delete(ctx context.Context, userId int64, refreshToken string) error {
+	const op = "Delete"
+
+	stmt, err := us.db.PrepareContext(ctx, "DELETE FROM users_sessions WHERE user_id = ? AND refresh_token = ?")
+	if err != nil {
+		return logger.Error(us.pkg, op, err)
+	}
 	defer func(stmt *sql.Stmt) {
 		err := stmt.Close()
 		if err != nil {
@@ -53,31 +76,41 @@ func (us *Repository) Create(userSession Session) (Session, error) {
 		}
 	}(stmt)
 
-	exec, err := stmt.Exec(userSession.UserId, userSession.RefreshToken, userSession.ExpiredAt)
+	_, err = stmt.ExecContext(ctx, userId, refreshToken)
 	if err != nil {
-		// check if error is because refresh_token duplicate
-		var mysqlErr *mysql.MySQLError
-		if errors.As(err, &mysqlErr) && mysqlErr.Number == 1062 {
-			return Session{}, storage.ErrDuplicateNotAllowed
-		}
-
-		return Session{}, logger.Error(us.pkg, op, err)
+		return logger.Error(us.pkg, op, err)
 	}
 
-	id, err := exec.LastInsertId()
+	return nil
+}
+
+func (us *Repository) DeleteAllByUserId(ctx context.Context, userId int64) error {
+	const op = "DeleteAllByUserId"
+
+	stmt, err := us.db.PrepareContext(ctx, "DELETE FROM users_sessions WHERE user_id = ?")
 	if err != nil {
-		return Session{}, logger.Error(us.pkg, op, err)
+		return logger.Error(us.pkg, op, err)
 	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(us.pkg, op, err)
+		}
+	}(stmt)
 
-	userSession.Id = id
+	_, err = stmt.ExecContext(ctx, userId)
+	if err != nil {
+		return logger.Error(us.pkg, op, err)
+	}
 
-	return userSession, nil
+	return nil
 }
 
-func (us *Repository) Delete(userId int64, refreshToken string) error {
-	const op = "Delete"
+// DeleteAllByUserIdExcept removes all sessions of the user except the session with the given refresh token
+func (us *Repository) DeleteAllByUserIdExcept(ctx context.Context, userId int64, refreshToken string) error {
+	const op = "DeleteAllByUserIdExcept"
 
-	stmt, err := us.db.Prepare("DELETE FROM users_sessions WHERE user_id = ? AND refresh_token = ?")
+	stmt, err := us.db.PrepareContext(ctx, "DELETE FROM users_sessions WHERE user_id = ? AND refresh_token != ?")
 	if err != nil {
 		return logger.Error(us.pkg, op, err)
 	}
@@ -88,10 +121,51 @@ func (us *Repository) Delete(userId int64, refreshToken string) error {
 		}
 	}(stmt)
 
-	_, err = stmt.Exec(userId, refreshToken)
+	_, err = stmt.ExecContext(ctx, userId, refreshToken)
 	if err != nil {
 		return logger.Error(us.pkg, op, err)
 	}
 
 	return nil
 }
+
+// CountActive returns the number of not expired sessions
+func (us *Repository) CountActive(ctx context.Context) (int64, error) {
+	const op = "CountActive"
+
+	var count int64
+	err := us.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users_sessions WHERE expires_at >= ?", storage.Now()).Scan(&count)
+	if err != nil {
+		return 0, logger.Error(us.pkg, op, err)
+	}
+
+	return count, nil
+}
+
+// DeleteExpired removes expired sessions and returns their number
+func (us *Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	stmt, err := us.db.PrepareContext(ctx, "DELETE FROM users_sessions WHERE expires_at < ?")
+	if err != nil {
+		return 0, logger.Error(us.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(us.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.ExecContext(ctx, storage.Now())
+	if err != nil {
+		return 0, logger.Error(us.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return 0, logger.Error(us.pkg, op, err)
+	}
+
+	return affected, nil
+}
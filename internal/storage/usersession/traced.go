@@ -0,0 +1,71 @@
+package usersession
+
+import (
+	"context"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+// Traced wraps the repository and starts a span of every query
+type Traced struct {
+	*Repository
+}
+
+func NewTraced(repo *Repository) *Traced {
+	return &Traced{Repository: repo}
+}
+
+func (t *Traced) ByRefreshToken(ctx context.Context, refreshToken string) (Session, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.ByRefreshToken")
+	s, err := t.Repository.ByRefreshToken(ctx, refreshToken)
+	storage.EndSpan(span, err)
+
+	return s, err
+}
+
+func (t *Traced) Create(ctx context.Context, userSession Session) (Session, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.Create")
+	s, err := t.Repository.Create(ctx, userSession)
+	storage.EndSpan(span, err)
+
+	return s, err
+}
+
+func (t *Traced) Delete(ctx context.Context, userId int64, refreshToken string) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.Delete")
+	err := t.Repository.Delete(ctx, userId, refreshToken)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) DeleteAllByUserId(ctx context.Context, userId int64) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.DeleteAllByUserId")
+	err := t.Repository.DeleteAllByUserId(ctx, userId)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) DeleteAllByUserIdExcept(ctx context.Context, userId int64, refreshToken string) error {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.DeleteAllByUserIdExcept")
+	err := t.Repository.DeleteAllByUserIdExcept(ctx, userId, refreshToken)
+	storage.EndSpan(span, err)
+
+	return err
+}
+
+func (t *Traced) CountActive(ctx context.Context) (int64, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.CountActive")
+	n, err := t.Repository.CountActive(ctx)
+	storage.EndSpan(span, err)
+
+	return n, err
+}
+
+func (t *Traced) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, span := storage.StartSpan(ctx, t.db, "users_sessions.DeleteExpired")
+	n, err := t.Repository.DeleteExpired(ctx)
+	storage.EndSpan(span, err)
+
+	return n, err
+}
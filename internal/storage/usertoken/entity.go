@@ -0,0 +1,13 @@
+package usertoken
+
+import "database/sql"
+
+type Token struct {
+	Id        int64
+	UserId    int64
+	Purpose   string
+	TokenHash string
+	ExpiredAt string
+	UsedAt    sql.NullString
+	Payload   sql.NullString // purpose specific data, e.g. a new email for email change
+}
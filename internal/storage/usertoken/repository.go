@@ -0,0 +1,145 @@
+package usertoken
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+)
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "usertoken.repository",
+		db:  db,
+	}
+}
+
+func (ut *Repository) Create(token Token) (Token, error) {
+	const op = "Create"
+
+	id, err := ut.db.Insert(
+		"INSERT INTO users_tokens (user_id, purpose, token_hash, expires_at, payload) VALUES (?, ?, ?, ?, ?)",
+		token.UserId, token.Purpose, token.TokenHash, token.ExpiredAt, token.Payload,
+	)
+	if err != nil {
+		// check if error is because token_hash duplicate
+		if ut.db.IsDuplicate(err) {
+			return Token{}, storage.ErrDuplicateNotAllowed
+		}
+
+		return Token{}, logger.Error(ut.pkg, op, err)
+	}
+
+	token.Id = id
+
+	return token, nil
+}
+
+func (ut *Repository) ByTokenHash(tokenHash string) (Token, error) {
+	const op = "ByTokenHash"
+
+	var t Token
+	err := ut.db.QueryRow(
+		"SELECT id, user_id, purpose, token_hash, expires_at, used_at, payload FROM users_tokens WHERE token_hash = ?",
+		tokenHash,
+	).Scan(&t.Id, &t.UserId, &t.Purpose, &t.TokenHash, &t.ExpiredAt, &t.UsedAt, &t.Payload)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Token{}, storage.ErrNotFound
+		}
+
+		return Token{}, logger.Error(ut.pkg, op, err)
+	}
+
+	return t, nil
+}
+
+// MarkUsed marks the token as used. It returns storage.ErrNotFound when the token
+// was already used, so concurrent requests can't consume the same token twice.
+func (ut *Repository) MarkUsed(tokenId int64) error {
+	const op = "MarkUsed"
+
+	stmt, err := ut.db.Prepare("UPDATE users_tokens SET used_at = ? WHERE id = ? AND used_at IS NULL")
+	if err != nil {
+		return logger.Error(ut.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(ut.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.Exec(storage.Now(), tokenId)
+	if err != nil {
+		return logger.Error(ut.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return logger.Error(ut.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// DeleteUnusedByUserId removes not used tokens of the given purpose, so only the latest sent link stays valid
+func (ut *Repository) DeleteUnusedByUserId(userId int64, purpose string) error {
+	const op = "DeleteUnusedByUserId"
+
+	stmt, err := ut.db.Prepare("DELETE FROM users_tokens WHERE user_id = ? AND purpose = ? AND used_at IS NULL")
+	if err != nil {
+		return logger.Error(ut.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(ut.pkg, op, err)
+		}
+	}(stmt)
+
+	_, err = stmt.Exec(userId, purpose)
+	if err != nil {
+		return logger.Error(ut.pkg, op, err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes expired and used tokens and returns their number
+func (ut *Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	stmt, err := ut.db.PrepareContext(ctx, "DELETE FROM users_tokens WHERE expires_at < ? OR used_at IS NOT NULL")
+	if err != nil {
+		return 0, logger.Error(ut.pkg, op, err)
+	}
+	defer func(stmt *sql.Stmt) {
+		err := stmt.Close()
+		if err != nil {
+			logger.Add(ut.pkg, op, err)
+		}
+	}(stmt)
+
+	exec, err := stmt.ExecContext(ctx, storage.Now())
+	if err != nil {
+		return 0, logger.Error(ut.pkg, op, err)
+	}
+
+	affected, err := exec.RowsAffected()
+	if err != nil {
+		return 0, logger.Error(ut.pkg, op, err)
+	}
+
+	return affected, nil
+}
@@ -0,0 +1,44 @@
+package webhook
+
+import "database/sql"
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+type Webhook struct {
+	Id              int64
+	UserId          int64
+	URL             string
+	SecretEncrypted string // base64 of nonce and AES-GCM sealed secret
+	Events          string // comma separated event types
+	Folder          string // only events inside the folder are delivered, empty for all folders
+	Failures        int    // failed attempts in a row
+	DisabledAt      sql.NullString
+	CreatedAt       string
+}
+
+type Delivery struct {
+	Id            int64
+	WebhookId     int64
+	EventId       string
+	EventType     string
+	Payload       string // signed JSON body
+	Status        string
+	Attempts      int
+	NextAttemptAt string
+	LockedUntil   sql.NullString // the worker which claimed the delivery owns it until then
+	CreatedAt     string
+	ExpiresAt     string
+}
+
+type Attempt struct {
+	Id         int64
+	DeliveryId int64
+	StatusCode int    // zero if there was no response
+	Error      string // empty if the attempt succeeded
+	DurationMs int64
+	CreatedAt  string
+}
@@ -0,0 +1,478 @@
+package webhook
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/logger"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"strings"
+)
+
+const (
+	webhookColumns  = "id, user_id, url, secret_encrypted, events, folder, failures, disabled_at, created_at"
+	deliveryColumns = "id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at, " +
+		"locked_until, created_at, expires_at"
+)
+
+type Repository struct {
+	pkg string
+	db  *storage.DB
+}
+
+func NewRepository(db *storage.DB) *Repository {
+	return &Repository{
+		pkg: "webhook.repository",
+		db:  db,
+	}
+}
+
+func (r *Repository) Create(ctx context.Context, w Webhook) (Webhook, error) {
+	const op = "Create"
+
+	id, err := r.db.InsertContext(
+		ctx,
+		"INSERT INTO webhooks (user_id, url, secret_encrypted, events, folder) VALUES (?, ?, ?, ?, ?)",
+		w.UserId, w.URL, w.SecretEncrypted, w.Events, w.Folder,
+	)
+	if err != nil {
+		return Webhook{}, logger.Error(r.pkg, op, err)
+	}
+
+	return r.ById(ctx, w.UserId, id)
+}
+
+func (r *Repository) ById(ctx context.Context, userId, id int64) (Webhook, error) {
+	const op = "ById"
+
+	w, err := r.scanWebhook(r.db.QueryRowContext(
+		ctx,
+		"SELECT "+webhookColumns+" FROM webhooks WHERE id = ? AND user_id = ?",
+		id,
+		userId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, storage.ErrNotFound
+		}
+
+		return Webhook{}, logger.Error(r.pkg, op, err)
+	}
+
+	return w, nil
+}
+
+// Find returns the webhook of any user, it's used by deliveries which don't know the user
+func (r *Repository) Find(ctx context.Context, id int64) (Webhook, error) {
+	const op = "Find"
+
+	w, err := r.scanWebhook(r.db.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Webhook{}, storage.ErrNotFound
+		}
+
+		return Webhook{}, logger.Error(r.pkg, op, err)
+	}
+
+	return w, nil
+}
+
+func (r *Repository) ByUserId(ctx context.Context, userId int64) ([]Webhook, error) {
+	return r.webhooks(ctx, "ByUserId", "SELECT "+webhookColumns+" FROM webhooks WHERE user_id = ? ORDER BY id DESC", userId)
+}
+
+// Enabled returns webhooks of the user which receive events
+func (r *Repository) Enabled(ctx context.Context, userId int64) ([]Webhook, error) {
+	return r.webhooks(
+		ctx,
+		"Enabled",
+		"SELECT "+webhookColumns+" FROM webhooks WHERE user_id = ? AND disabled_at IS NULL ORDER BY id",
+		userId,
+	)
+}
+
+func (r *Repository) Delete(ctx context.Context, userId, id int64) error {
+	const op = "Delete"
+
+	affected, err := r.exec(ctx, "DELETE FROM webhooks WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// SetDisabled disables the webhook at the time or enables it if it's not valid. Failures start from zero again
+func (r *Repository) SetDisabled(ctx context.Context, id int64, disabledAt sql.NullString) error {
+	const op = "SetDisabled"
+
+	affected, err := r.exec(ctx, "UPDATE webhooks SET disabled_at = ?, failures = 0 WHERE id = ?", disabledAt, id)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	if affected == 0 {
+		return storage.ErrNotFound
+	}
+
+	return nil
+}
+
+// AddFailure counts the failed attempt and returns failures in a row
+func (r *Repository) AddFailure(ctx context.Context, id int64) (int, error) {
+	const op = "AddFailure"
+
+	if _, err := r.exec(ctx, "UPDATE webhooks SET failures = failures + 1 WHERE id = ?", id); err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	var failures int
+	if err := r.db.QueryRowContext(ctx, "SELECT failures FROM webhooks WHERE id = ?", id).Scan(&failures); err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return failures, nil
+}
+
+func (r *Repository) ResetFailures(ctx context.Context, id int64) error {
+	const op = "ResetFailures"
+
+	if _, err := r.exec(ctx, "UPDATE webhooks SET failures = 0 WHERE id = ? AND failures > 0", id); err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateDelivery(ctx context.Context, d Delivery) (Delivery, error) {
+	const op = "CreateDelivery"
+
+	id, err := r.db.InsertContext(
+		ctx,
+		"INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload, status, next_attempt_at, expires_at) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?)",
+		d.WebhookId, d.EventId, d.EventType, d.Payload, d.Status, d.NextAttemptAt, d.ExpiresAt,
+	)
+	if err != nil {
+		return Delivery{}, logger.Error(r.pkg, op, err)
+	}
+
+	return r.FindDelivery(ctx, id)
+}
+
+// DeliveryById returns the delivery of the webhook
+func (r *Repository) DeliveryById(ctx context.Context, webhookId, id int64) (Delivery, error) {
+	const op = "DeliveryById"
+
+	d, err := r.scanDelivery(r.db.QueryRowContext(
+		ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ? AND webhook_id = ?",
+		id,
+		webhookId,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Delivery{}, storage.ErrNotFound
+		}
+
+		return Delivery{}, logger.Error(r.pkg, op, err)
+	}
+
+	return d, nil
+}
+
+func (r *Repository) FindDelivery(ctx context.Context, id int64) (Delivery, error) {
+	const op = "FindDelivery"
+
+	d, err := r.scanDelivery(r.db.QueryRowContext(
+		ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ?",
+		id,
+	))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Delivery{}, storage.ErrNotFound
+		}
+
+		return Delivery{}, logger.Error(r.pkg, op, err)
+	}
+
+	return d, nil
+}
+
+// Deliveries returns the page of deliveries of the webhook, newest first, and the total number of them
+func (r *Repository) Deliveries(ctx context.Context, webhookId int64, limit, offset int) ([]Delivery, int64, error) {
+	const op = "Deliveries"
+
+	var total int64
+
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ?", webhookId).
+		Scan(&total)
+	if err != nil {
+		return nil, 0, logger.Error(r.pkg, op, err)
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ? OFFSET ?",
+		webhookId,
+		limit,
+		offset,
+	)
+	if err != nil {
+		return nil, 0, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		d, err := r.scanDelivery(rows)
+		if err != nil {
+			return nil, 0, logger.Error(r.pkg, op, err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, logger.Error(r.pkg, op, err)
+	}
+
+	return deliveries, total, nil
+}
+
+// Attempts returns attempts of the deliveries grouped by the delivery, oldest first
+func (r *Repository) Attempts(ctx context.Context, deliveryIds []int64) (map[int64][]Attempt, error) {
+	const op = "Attempts"
+
+	attempts := map[int64][]Attempt{}
+	if len(deliveryIds) == 0 {
+		return attempts, nil
+	}
+
+	args := make([]any, len(deliveryIds))
+	for i, id := range deliveryIds {
+		args[i] = id
+	}
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT id, delivery_id, status_code, error, duration_ms, created_at FROM webhook_delivery_attempts "+
+			"WHERE delivery_id IN ("+strings.Repeat("?, ", len(args)-1)+"?) ORDER BY id",
+		args...,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	for rows.Next() {
+		var a Attempt
+		if err := rows.Scan(&a.Id, &a.DeliveryId, &a.StatusCode, &a.Error, &a.DurationMs, &a.CreatedAt); err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		attempts[a.DeliveryId] = append(attempts[a.DeliveryId], a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return attempts, nil
+}
+
+// Due returns ids of pending deliveries of enabled webhooks which should be attempted at the time
+// and aren't claimed by a worker, oldest first
+func (r *Repository) Due(ctx context.Context, now string, limit int) ([]int64, error) {
+	const op = "Due"
+
+	rows, err := r.db.QueryContext(
+		ctx,
+		"SELECT d.id FROM webhook_deliveries d INNER JOIN webhooks w ON w.id = d.webhook_id "+
+			"WHERE d.status = ? AND d.next_attempt_at <= ? AND (d.locked_until IS NULL OR d.locked_until < ?) "+
+			"AND w.disabled_at IS NULL ORDER BY d.next_attempt_at LIMIT ?",
+		StatusPending,
+		now,
+		now,
+		limit,
+	)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	ids := []int64{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return ids, nil
+}
+
+// Claim locks the due delivery until the time and counts the attempt. It returns false if another worker,
+// possibly of another replica, has claimed it first
+func (r *Repository) Claim(ctx context.Context, id int64, now, lockedUntil string) (bool, error) {
+	const op = "Claim"
+
+	affected, err := r.exec(
+		ctx,
+		"UPDATE webhook_deliveries SET locked_until = ?, attempts = attempts + 1 "+
+			"WHERE id = ? AND status = ? AND next_attempt_at <= ? AND (locked_until IS NULL OR locked_until < ?)",
+		lockedUntil,
+		id,
+		StatusPending,
+		now,
+		now,
+	)
+	if err != nil {
+		return false, logger.Error(r.pkg, op, err)
+	}
+
+	return affected == 1, nil
+}
+
+// Complete records the attempt and releases the delivery with the status and the time of the next attempt
+func (r *Repository) Complete(ctx context.Context, d Delivery, attempt Attempt) error {
+	const op = "Complete"
+
+	_, err := r.db.InsertContext(
+		ctx,
+		"INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms) VALUES (?, ?, ?, ?)",
+		d.Id, attempt.StatusCode, attempt.Error, attempt.DurationMs,
+	)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	_, err = r.exec(
+		ctx,
+		"UPDATE webhook_deliveries SET status = ?, next_attempt_at = ?, locked_until = NULL WHERE id = ?",
+		d.Status,
+		d.NextAttemptAt,
+		d.Id,
+	)
+	if err != nil {
+		return logger.Error(r.pkg, op, err)
+	}
+
+	return nil
+}
+
+// DeleteExpired removes finished deliveries after they expire and returns their number, attempts are removed
+// with them. Pending deliveries are kept until they finish
+func (r *Repository) DeleteExpired(ctx context.Context) (int64, error) {
+	const op = "DeleteExpired"
+
+	affected, err := r.exec(
+		ctx,
+		"DELETE FROM webhook_deliveries WHERE expires_at < ? AND status <> ?",
+		storage.Now(),
+		StatusPending,
+	)
+	if err != nil {
+		return 0, logger.Error(r.pkg, op, err)
+	}
+
+	return affected, nil
+}
+
+func (r *Repository) webhooks(ctx context.Context, op, query string, args ...any) ([]Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+	defer func(rows *storage.Rows) {
+		err := rows.Close()
+		if err != nil {
+			logger.Add(r.pkg, op, err)
+		}
+	}(rows)
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		w, err := r.scanWebhook(rows)
+		if err != nil {
+			return nil, logger.Error(r.pkg, op, err)
+		}
+
+		webhooks = append(webhooks, w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, logger.Error(r.pkg, op, err)
+	}
+
+	return webhooks, nil
+}
+
+// exec runs the statement and returns the number of affected rows
+func (r *Repository) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	exec, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return exec.RowsAffected()
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *Repository) scanWebhook(row scanner) (Webhook, error) {
+	var w Webhook
+	err := row.Scan(
+		&w.Id, &w.UserId, &w.URL, &w.SecretEncrypted, &w.Events, &w.Folder, &w.Failures, &w.DisabledAt, &w.CreatedAt,
+	)
+
+	return w, err
+}
+
+func (r *Repository) scanDelivery(row scanner) (Delivery, error) {
+	var d Delivery
+	err := row.Scan(
+		&d.Id,
+		&d.WebhookId,
+		&d.EventId,
+		&d.EventType,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LockedUntil,
+		&d.CreatedAt,
+		&d.ExpiresAt,
+	)
+
+	return d, err
+}
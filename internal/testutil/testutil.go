@@ -1,12 +1,15 @@
 package testutil
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"github.com/albakov/go-cloud-file-storage/internal/config"
+	"github.com/albakov/go-cloud-file-storage/db"
+	"github.com/albakov/go-cloud-file-storage/internal/migrator"
 	"github.com/albakov/go-cloud-file-storage/internal/storage"
 	"os"
 	"path/filepath"
+	"strings"
+	"testing"
 )
 
 // FindProjectRoot returns project root path
@@ -27,12 +30,58 @@ func FindProjectRoot() (string, error) {
 	}
 }
 
-// DbTest returns db resource for testing
-func DbTest() (*sql.DB, error) {
-	dir, err := FindProjectRoot()
+// ForEachDatabase runs the test against every database with applied migrations:
+// SQLite in memory, MySQL and PostgreSQL if TEST_MYSQL_DSN and TEST_POSTGRES_DSN are set
+func ForEachDatabase(t *testing.T, test func(t *testing.T, db *storage.DB)) {
+	for _, driver := range []string{storage.DriverSQLite, storage.DriverMySQL, storage.DriverPostgres} {
+		t.Run(driver, func(t *testing.T) {
+			test(t, DbTest(t, driver))
+		})
+	}
+}
+
+// DbTest returns migrated database of the driver for testing, it is closed when the test finishes
+func DbTest(t *testing.T, driver string) *storage.DB {
+	t.Helper()
+
+	dsn := ":memory:"
+	if driver != storage.DriverSQLite {
+		env := "TEST_" + strings.ToUpper(driver) + "_DSN"
+
+		dsn = os.Getenv(env)
+		if dsn == "" {
+			t.Skipf("%s is not set", env)
+		}
+	}
+
+	dialect, err := storage.NewDialect(driver)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	conn, err := dialect.Open(dsn)
+	if err != nil {
+		t.Fatalf("error while open %s: %v", driver, err)
+	}
+
+	database := storage.NewDB(conn, dialect)
+	t.Cleanup(func() {
+		_ = database.Close()
+	})
+
+	migrations, err := db.MigrationsFor(driver)
 	if err != nil {
-		return nil, fmt.Errorf("error while get dir path: %v", err)
+		t.Fatalf("error while open %s migrations: %v", driver, err)
+	}
+
+	m, err := migrator.New(&migrator.Config{Migrations: migrations}, database)
+	if err != nil {
+		t.Fatalf("error while create %s migrator: %v", driver, err)
+	}
+
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("error while migrate %s: %v", driver, err)
 	}
 
-	return storage.MustNewClient(config.MustNew(filepath.Join(dir, ".env.dev")).MysqlDSN).DB(), nil
+	return database
 }
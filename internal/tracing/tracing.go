@@ -0,0 +1,140 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"strconv"
+)
+
+// Name of the instrumentation, used for tracers of all packages
+const Name = "github.com/albakov/go-cloud-file-storage"
+
+type Config struct {
+	ServiceName string
+	Endpoint    string  // host:port of the OTLP HTTP collector, empty disables export
+	Insecure    bool    // plain HTTP to the collector
+	SampleRatio float64 // share of traces started by the app which are exported, 0..1
+}
+
+// Setup installs the tracer provider and the W3C trace context propagator.
+// Without the endpoint spans are not exported, but trace ids are still generated, propagated and logged.
+// The returned function flushes spans and must be called on shutdown
+func Setup(ctx context.Context, conf Config) (func(context.Context) error, error) {
+	if conf.SampleRatio < 0 || conf.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid trace sample ratio %v", conf.SampleRatio)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(conf.ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(res),
+		// remote decision is respected, so a trace started by the client is complete
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(conf.SampleRatio))),
+	}
+
+	if conf.Endpoint != "" {
+		exporterOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(conf.Endpoint)}
+		if conf.Insecure {
+			exporterOpts = append(exporterOpts, otlptracehttp.WithInsecure())
+		}
+
+		exporter, err := otlptracehttp.New(ctx, exporterOpts...)
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	provider := sdktrace.NewTracerProvider(opts...)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	return provider.Shutdown, nil
+}
+
+// Tracer returns the tracer of the application
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// End records the error, if it isn't one of the expected errors, and ends the span
+func End(span trace.Span, err error, expected ...error) {
+	if err != nil && !isExpected(err, expected) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+func isExpected(err error, expected []error) bool {
+	for _, e := range expected {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Transport starts a client span of every request made through base
+func Transport(base http.RoundTripper, name string) http.RoundTripper {
+	return &transport{
+		base: base,
+		name: name,
+	}
+}
+
+type transport struct {
+	base http.RoundTripper
+	name string
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := Tracer().Start(
+		req.Context(),
+		t.name+" "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		End(span, err)
+
+		return nil, err
+	}
+
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, strconv.Itoa(resp.StatusCode))
+	}
+
+	span.End()
+
+	return resp, nil
+}
@@ -0,0 +1,93 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTracing_Transport(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, parent := Tracer().Start(context.Background(), "parent")
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport, "s3")}
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error while request: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("2 spans must be ended, got: %d", len(spans))
+	}
+
+	span := spans[0]
+	if span.Name() != "s3 GET" {
+		t.Errorf("span name must be \"s3 GET\", got: %q", span.Name())
+	}
+
+	if span.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Error("span of the call must be a child of the span of the context")
+	}
+
+	if span.Status().Code != codes.Error {
+		t.Errorf("5xx response must set error status, got: %v", span.Status().Code)
+	}
+}
+
+func TestTracing_End(t *testing.T) {
+	recorder := setupRecorder(t)
+
+	errExpected := errors.New("not found")
+
+	_, span := Tracer().Start(context.Background(), "expected")
+	End(span, errExpected, errExpected)
+
+	_, span = Tracer().Start(context.Background(), "failed")
+	End(span, errors.New("failed"), errExpected)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("2 spans must be ended, got: %d", len(spans))
+	}
+
+	if spans[0].Status().Code != codes.Unset {
+		t.Errorf("expected error must not set error status, got: %v", spans[0].Status().Code)
+	}
+
+	if spans[1].Status().Code != codes.Error || len(spans[1].Events()) != 1 {
+		t.Error("unexpected error must be recorded and set error status")
+	}
+}
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+	})
+
+	return recorder
+}
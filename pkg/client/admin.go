@@ -0,0 +1,118 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Users returns the page of users, the query is a part of the email
+func (c *Client) Users(ctx context.Context, query string, page, perPage int) (UsersPage, error) {
+	values := url.Values{}
+	if query != "" {
+		values.Set("query", query)
+	}
+
+	if page > 0 {
+		values.Set("page", strconv.Itoa(page))
+	}
+
+	if perPage > 0 {
+		values.Set("per_page", strconv.Itoa(perPage))
+	}
+
+	var users UsersPage
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/users", query: values}, nil, &users)
+
+	return users, err
+}
+
+func (c *Client) User(ctx context.Context, id int64) (User, error) {
+	var user User
+
+	err := c.call(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/api/admin/users/%d", id)}, nil, &user)
+
+	return user, err
+}
+
+// DisableUser disables the account and ends its sessions
+func (c *Client) DisableUser(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodPost, path: fmt.Sprintf("/api/admin/users/%d/disable", id)}, nil, nil)
+}
+
+func (c *Client) EnableUser(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodPost, path: fmt.Sprintf("/api/admin/users/%d/enable", id)}, nil, nil)
+}
+
+// SetRole sets the role of the user, user or admin
+func (c *Client) SetRole(ctx context.Context, id int64, role string) error {
+	body := struct {
+		Role string `json:"role"`
+	}{role}
+
+	return c.call(ctx, request{method: http.MethodPatch, path: fmt.Sprintf("/api/admin/users/%d/role", id)}, &body, nil)
+}
+
+// SetQuota sets the storage quota of the user in bytes, nil is the default quota
+func (c *Client) SetQuota(ctx context.Context, id int64, quotaBytes *int64) error {
+	body := struct {
+		QuotaBytes *int64 `json:"quota_bytes"`
+	}{quotaBytes}
+
+	r := request{method: http.MethodPatch, path: fmt.Sprintf("/api/admin/users/%d/quota", id)}
+
+	return c.call(ctx, r, &body, nil)
+}
+
+// Usage returns the storage used by the user
+func (c *Client) Usage(ctx context.Context, id int64) (Usage, error) {
+	var usage Usage
+
+	err := c.call(ctx, request{method: http.MethodGet, path: fmt.Sprintf("/api/admin/users/%d/usage", id)}, nil, &usage)
+
+	return usage, err
+}
+
+// SignOutUser ends all sessions of the user
+func (c *Client) SignOutUser(ctx context.Context, id int64) error {
+	r := request{method: http.MethodDelete, path: fmt.Sprintf("/api/admin/users/%d/sessions", id)}
+
+	return c.call(ctx, r, nil, nil)
+}
+
+// MaintenanceJobs returns names of the maintenance jobs
+func (c *Client) MaintenanceJobs(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Jobs []string `json:"jobs"`
+	}
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/maintenance"}, nil, &resp)
+
+	return resp.Jobs, err
+}
+
+// StartMaintenanceJob starts the maintenance job in background
+func (c *Client) StartMaintenanceJob(ctx context.Context, job string) error {
+	r := request{method: http.MethodPost, path: "/api/admin/maintenance/" + url.PathEscape(job)}
+
+	return c.call(ctx, r, nil, nil)
+}
+
+// AllActivity returns the page of events of all users
+func (c *Client) AllActivity(ctx context.Context, filter ActivityFilter) (ActivityPage, error) {
+	var page ActivityPage
+
+	r := request{method: http.MethodGet, path: "/api/admin/activity", query: filter.query()}
+	err := c.call(ctx, r, nil, &page)
+
+	return page, err
+}
+
+// ExportAllActivity writes events of all users in the format, csv or json
+func (c *Client) ExportAllActivity(ctx context.Context, w io.Writer, format string, filter ActivityFilter) error {
+	return c.export(ctx, "/api/admin/activity/export", w, format, filter)
+}
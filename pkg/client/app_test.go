@@ -0,0 +1,484 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/albakov/go-cloud-file-storage/internal/api"
+	"github.com/albakov/go-cloud-file-storage/internal/config"
+	accesstokenservice "github.com/albakov/go-cloud-file-storage/internal/service/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/audit"
+	"github.com/albakov/go-cloud-file-storage/internal/service/events"
+	exportservice "github.com/albakov/go-cloud-file-storage/internal/service/export"
+	jwtservice "github.com/albakov/go-cloud-file-storage/internal/service/jwt"
+	"github.com/albakov/go-cloud-file-storage/internal/service/loginguard"
+	"github.com/albakov/go-cloud-file-storage/internal/service/mailer"
+	"github.com/albakov/go-cloud-file-storage/internal/service/ratelimit"
+	sshkeyservice "github.com/albakov/go-cloud-file-storage/internal/service/sshkey"
+	userservice "github.com/albakov/go-cloud-file-storage/internal/service/user"
+	usersessionservice "github.com/albakov/go-cloud-file-storage/internal/service/usersession"
+	usertokenservice "github.com/albakov/go-cloud-file-storage/internal/service/usertoken"
+	"github.com/albakov/go-cloud-file-storage/internal/service/verification"
+	webhookservice "github.com/albakov/go-cloud-file-storage/internal/service/webhook"
+	"github.com/albakov/go-cloud-file-storage/internal/storage"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/accesstoken"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/auditlog"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/export"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/sshkey"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/user"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usersession"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/usertoken"
+	"github.com/albakov/go-cloud-file-storage/internal/storage/webhook"
+	"github.com/albakov/go-cloud-file-storage/internal/testutil"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testEmail    = "user@example.com"
+	testPassword = "correct-horse-battery"
+)
+
+// app is the API of the application served in-process with the SQLite database in memory. Routes backed by S3
+// aren't served, transfers and failures of the connection are tested with apiServer
+type app struct {
+	URL      string
+	conf     *config.Config
+	services *api.Services
+	database *storage.DB
+	userId   int64
+}
+
+func newApp(t *testing.T) *app {
+	t.Helper()
+
+	database := testutil.DbTest(t, storage.DriverSQLite)
+
+	conf := &config.Config{
+		AppURL:            "http://localhost/",
+		JWTSecret:         "test-secret",
+		JWTExpiresMinutes: 15,
+		CookieExpires:     24,
+	}
+
+	userService := userservice.NewService(user.NewRepository(database))
+	userSessionService := usersessionservice.NewService(usersession.NewRepository(database))
+	verificationService := verification.NewService(
+		&verification.Config{
+			AppURL:               conf.AppURL,
+			EmailVerificationTTL: time.Hour,
+			PasswordResetTTL:     time.Hour,
+			AccountUnlockTTL:     time.Hour,
+		},
+		mailer.New(&mailer.Config{Driver: mailer.DriverLog, LogPath: filepath.Join(t.TempDir(), "mail.log")}),
+		usertokenservice.NewService(
+			&usertokenservice.Config{Secret: config.DeriveSecret(conf.JWTSecret, config.PurposeUserTokens)},
+			usertoken.NewRepository(database),
+		),
+		userService,
+		userSessionService,
+	)
+
+	eventBus, err := events.NewBus(&events.Config{BufferSize: 16}, events.NewMemoryBroker())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(eventBus.Shutdown)
+
+	rateLimitStore := ratelimit.NewMemoryStore()
+	limiter := ratelimit.NewLimiter(rateLimitStore)
+
+	services := &api.Services{
+		JWT:          jwtservice.NewService(&jwtservice.Config{Secret: conf.JWTSecret, ExpiresMinutes: 15}),
+		User:         userService,
+		UserSession:  userSessionService,
+		AccessToken:  accesstokenservice.NewService(accesstoken.NewRepository(database)),
+		Verification: verificationService,
+		Limiter:      limiter,
+		LoginGuard:   loginguard.NewService(&loginguard.Config{}, rateLimitStore, limiter, verificationService),
+		Audit:        audit.NewService(auditlog.NewRepository(database)),
+		SSHKey:       sshkeyservice.NewService(sshkey.NewRepository(database)),
+		Events:       eventBus,
+		Export: exportservice.NewService(
+			&exportservice.Config{Secret: "test-secret", TTL: time.Hour, MaxPaths: 10},
+			export.NewRepository(database),
+			nil,
+			nil,
+			eventBus,
+		),
+		Webhook: webhookservice.NewService(
+			&webhookservice.Config{
+				Secret:       config.DeriveSecret(conf.JWTSecret, config.PurposeWebhooks),
+				Timeout:      time.Second,
+				MaxAttempts:  3,
+				Backoff:      time.Minute,
+				MaxBackoff:   time.Hour,
+				DisableAfter: 10,
+				Retention:    time.Hour,
+				Workers:      1,
+				PollInterval: time.Minute,
+			},
+			webhook.NewRepository(database),
+			eventBus,
+		),
+	}
+
+	u, err := userService.CreateUser(context.Background(), userservice.User{Email: testEmail, Password: testPassword})
+	if err != nil {
+		t.Fatalf("error while create user: %v", err)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	apiClient := api.MustNewClient(conf, services)
+	go func() {
+		_ = apiClient.Serve(ln)
+	}()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_ = apiClient.Shutdown(ctx)
+	})
+
+	return &app{URL: "http://" + ln.Addr().String(), conf: conf, services: services, database: database, userId: u.Id}
+}
+
+func (a *app) client(t *testing.T, opts ...Option) *Client {
+	c, err := New(a.URL, append([]Option{WithRetry(3, time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+// signedIn returns the client signed in as the user
+func (a *app) signedIn(t *testing.T) *Client {
+	c := a.client(t)
+
+	if err := c.SignIn(context.Background(), testEmail, testPassword); err != nil {
+		t.Fatalf("error while sign in: %v", err)
+	}
+
+	return c
+}
+
+// expiredToken returns the access token of the user which has expired a minute ago
+func (a *app) expiredToken(t *testing.T) string {
+	token, err := jwtservice.NewService(&jwtservice.Config{Secret: a.conf.JWTSecret, ExpiresMinutes: -1}).
+		GenerateAccessToken(a.userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+// refreshes returns the number of successful refreshes of access tokens
+func (a *app) refreshes(t *testing.T) int64 {
+	_, total, err := a.services.Audit.Events(
+		audit.Filter{Action: audit.ActionRefresh, Result: audit.ResultSuccess},
+		1,
+		1,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return total
+}
+
+// createExport adds the pending export of the paths without its job
+func (a *app) createExport(t *testing.T, paths ...string) int64 {
+	exported := make([]exportservice.Path, 0, len(paths))
+	for _, p := range paths {
+		exported = append(exported, exportservice.Path{Path: p, IsDirectory: strings.HasSuffix(p, "/")})
+	}
+
+	data, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := export.NewRepository(a.database).Create(context.Background(), export.Export{
+		UserId:    a.userId,
+		Format:    "zip",
+		Paths:     string(data),
+		Status:    export.StatusPending,
+		ExpiresAt: time.Now().Add(time.Hour).Format(time.DateTime),
+	})
+	if err != nil {
+		t.Fatalf("error while create export: %v", err)
+	}
+
+	return e.Id
+}
+
+func TestClient_SignIn(t *testing.T) {
+	a := newApp(t)
+	c := a.client(t)
+	ctx := context.Background()
+
+	err := c.SignIn(ctx, testEmail, "wrong-password")
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("sign in with wrong password must fail with ErrUnauthorized, got: %v", err)
+	}
+
+	if err := c.SignIn(ctx, testEmail, testPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.AccessToken() == "" || c.RefreshToken() == "" {
+		t.Fatalf("sign in must keep the tokens, got: %q %q", c.AccessToken(), c.RefreshToken())
+	}
+
+	profile, err := c.Me(ctx)
+	if err != nil || profile.Email != testEmail || profile.EmailVerified {
+		t.Fatalf("signed in client must get the profile, got: %+v %v", profile, err)
+	}
+
+	refreshToken := c.RefreshToken()
+
+	if err := c.SignOut(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.AccessToken() != "" || c.RefreshToken() != "" {
+		t.Errorf("sign out must forget the tokens, got: %q %q", c.AccessToken(), c.RefreshToken())
+	}
+
+	if _, err := c.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("signed out client must be unauthorized, got: %v", err)
+	}
+
+	// the session is ended on the server too
+	restored := a.client(t, WithRefreshToken(refreshToken))
+	if _, err := restored.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("session must be ended by the sign out, got: %v", err)
+	}
+}
+
+func TestClient_RefreshesExpiredToken(t *testing.T) {
+	a := newApp(t)
+	c := a.signedIn(t)
+	ctx := context.Background()
+
+	c.setTokens(a.expiredToken(t), c.RefreshToken())
+
+	// requests failed with the same expired token refresh it once
+	var wg sync.WaitGroup
+	var failed atomic.Int32
+
+	for range 10 {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if _, err := c.Me(ctx); err != nil {
+				failed.Add(1)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if failed.Load() > 0 {
+		t.Fatalf("requests with the expired token must succeed after the refresh, %d failed", failed.Load())
+	}
+
+	if n := a.refreshes(t); n != 1 {
+		t.Errorf("expired token must be refreshed once, got: %d", n)
+	}
+
+	// the saved session is restored in another client
+	restored := a.client(t, WithRefreshToken(c.RefreshToken()))
+	if _, err := restored.Me(ctx); err != nil {
+		t.Fatalf("restored session must be authenticated, got: %v", err)
+	}
+
+	if n := a.refreshes(t); n != 2 {
+		t.Errorf("restored session must get the access token, got refreshes: %d", n)
+	}
+
+	// the personal access token isn't refreshed
+	pat := a.client(t, WithAccessToken("cfs_pat_expired"))
+	if _, err := pat.Me(ctx); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("invalid access token must fail with ErrUnauthorized, got: %v", err)
+	}
+}
+
+func TestClient_Errors(t *testing.T) {
+	a := newApp(t)
+	c := a.signedIn(t)
+	ctx := context.Background()
+
+	err := c.DeleteSSHKey(ctx, 999)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing key must fail with ErrNotFound, got: %v", err)
+	}
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound || apiErr.Message != "Not found" {
+		t.Errorf("error must have the status and the message of the response, got: %+v", apiErr)
+	}
+
+	_, err = c.AddSSHKey(ctx, "laptop", "invalid")
+	if !errors.Is(err, ErrBadRequest) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 ||
+		apiErr.Fields[0].Field != "public_key" {
+		t.Errorf("validation error must have the fields, got: %+v", err)
+	}
+
+	token, err := c.CreateAccessToken(ctx, AccessTokenRequest{Name: "backup", Scopes: []string{"read"}})
+	if err != nil {
+		t.Fatalf("error while create access token: %v", err)
+	}
+
+	// keys are managed only in sessions
+	pat := a.client(t, WithAccessToken(token.Token))
+	if _, err := pat.SSHKeys(ctx); !errors.Is(err, ErrForbidden) {
+		t.Errorf("session only endpoint must fail with ErrForbidden for the access token, got: %v", err)
+	}
+
+	if profile, err := pat.Me(ctx); err != nil || profile.Email != testEmail {
+		t.Errorf("access token must be authenticated, got: %+v %v", profile, err)
+	}
+}
+
+func TestClient_Webhooks(t *testing.T) {
+	a := newApp(t)
+	c := a.signedIn(t)
+	ctx := context.Background()
+
+	var apiErr *Error
+
+	_, err := c.CreateWebhook(ctx, WebhookRequest{URL: "ftp://example.com/hooks", Events: []string{EventFileCreated}})
+	if !errors.Is(err, ErrBadRequest) || !errors.As(err, &apiErr) || len(apiErr.Fields) != 1 ||
+		apiErr.Fields[0].Field != "url" {
+		t.Errorf("invalid url must fail with the field, got: %+v", err)
+	}
+
+	created, err := c.CreateWebhook(ctx, WebhookRequest{
+		URL:    "https://example.com/hooks",
+		Events: []string{EventFileCreated},
+		Folder: "/docs",
+	})
+	if err != nil {
+		t.Fatalf("error while create webhook: %v", err)
+	}
+
+	if created.Id == 0 || created.Secret == "" || created.Folder != "/docs" || created.Disabled() {
+		t.Fatalf("created webhook must have the generated secret, got: %+v", created)
+	}
+
+	webhooks, err := c.Webhooks(ctx)
+	if err != nil || len(webhooks) != 1 || webhooks[0].Id != created.Id || webhooks[0].URL != created.URL {
+		t.Fatalf("webhooks must have the created one, got: %+v %v", webhooks, err)
+	}
+
+	a.services.Webhook.Receive(ctx, events.Event{
+		Id:        "event-1",
+		UserId:    a.userId,
+		Type:      events.TypeFileCreated,
+		Path:      "/docs/a.txt",
+		CreatedAt: time.Now(),
+	})
+
+	page, err := c.WebhookDeliveries(ctx, created.Id, 1, 10)
+	if err != nil || page.Total != 1 || len(page.Deliveries) != 1 {
+		t.Fatalf("file event must be delivered to the webhook, got: %+v %v", page, err)
+	}
+
+	delivery := page.Deliveries[0]
+	if delivery.EventId != "event-1" || delivery.EventType != EventFileCreated || delivery.Status != DeliveryPending {
+		t.Errorf("delivery must be pending with the event, got: %+v", delivery)
+	}
+
+	redelivery, err := c.RedeliverWebhook(ctx, created.Id, delivery.Id)
+	if err != nil || redelivery.Id == delivery.Id || redelivery.EventId != delivery.EventId {
+		t.Fatalf("redelivery must queue the event again, got: %+v %v", redelivery, err)
+	}
+
+	if page, err := c.WebhookDeliveries(ctx, created.Id, 1, 1); err != nil || page.Total != 2 ||
+		len(page.Deliveries) != 1 || page.Deliveries[0].Id != redelivery.Id {
+		t.Errorf("deliveries must be paged newest first, got: %+v %v", page, err)
+	}
+
+	disabled, err := c.DisableWebhook(ctx, created.Id)
+	if err != nil || !disabled.Disabled() {
+		t.Fatalf("webhook must be disabled, got: %+v %v", disabled, err)
+	}
+
+	if _, err := c.RedeliverWebhook(ctx, created.Id, delivery.Id); !errors.Is(err, ErrConflict) {
+		t.Errorf("disabled webhook must not be redelivered, got: %v", err)
+	}
+
+	enabled, err := c.EnableWebhook(ctx, created.Id)
+	if err != nil || enabled.Disabled() {
+		t.Fatalf("webhook must be enabled, got: %+v %v", enabled, err)
+	}
+
+	if err := c.DeleteWebhook(ctx, created.Id); err != nil {
+		t.Fatalf("error while delete webhook: %v", err)
+	}
+
+	if err := c.DeleteWebhook(ctx, created.Id); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted webhook must not be found, got: %v", err)
+	}
+
+	if webhooks, err := c.Webhooks(ctx); err != nil || len(webhooks) != 0 {
+		t.Errorf("deleted webhook must not be listed, got: %+v %v", webhooks, err)
+	}
+}
+
+func TestClient_ExportsOfAccessTokenFolder(t *testing.T) {
+	a := newApp(t)
+	c := a.signedIn(t)
+	ctx := context.Background()
+
+	inside := a.createExport(t, "/docs/", "/docs/reports/a.txt")
+	outside := a.createExport(t, "/docs/", "/private/")
+
+	token, err := c.CreateAccessToken(ctx, AccessTokenRequest{
+		Name:   "docs",
+		Scopes: []string{"read", "write"},
+		Folder: "/docs",
+	})
+	if err != nil {
+		t.Fatalf("error while create access token: %v", err)
+	}
+
+	pat := a.client(t, WithAccessToken(token.Token))
+
+	exports, err := pat.Exports(ctx)
+	if err != nil || len(exports) != 1 || exports[0].Id != inside {
+		t.Fatalf("token must see only exports inside of its folder, got: %+v %v", exports, err)
+	}
+
+	if _, err := pat.Export(ctx, outside); !errors.Is(err, ErrNotFound) {
+		t.Errorf("export outside of the token folder must not be found, got: %v", err)
+	}
+
+	if err := pat.DeleteExport(ctx, outside); !errors.Is(err, ErrNotFound) {
+		t.Errorf("export outside of the token folder must not be deleted, got: %v", err)
+	}
+
+	if err := pat.DeleteExport(ctx, inside); err != nil {
+		t.Errorf("export inside of the token folder must be deleted, got: %v", err)
+	}
+
+	// the session sees all exports
+	if exports, err := c.Exports(ctx); err != nil || len(exports) != 1 || exports[0].Id != outside {
+		t.Errorf("session must see all exports, got: %+v %v", exports, err)
+	}
+}
@@ -0,0 +1,152 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password,omitempty"`
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+}
+
+type tokenRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password,omitempty"`
+}
+
+// SignIn starts the session, the client is authenticated with it from now on
+func (c *Client) SignIn(ctx context.Context, email, password string) error {
+	return c.signIn(ctx, "/api/auth/sign-in", email, password)
+}
+
+// SignUp registers the account and starts its session
+func (c *Client) SignUp(ctx context.Context, email, password string) error {
+	return c.signIn(ctx, "/api/auth/sign-up", email, password)
+}
+
+func (c *Client) signIn(ctx context.Context, path, email, password string) error {
+	var resp tokenResponse
+
+	// the account is created or the attempt is counted even if the response is lost
+	r := request{method: http.MethodPost, path: path, public: true, noRetry: true}
+	if err := c.call(ctx, r, &credentials{Email: email, Password: password}, &resp); err != nil {
+		return err
+	}
+
+	c.setTokens(resp.AccessToken, "")
+
+	return nil
+}
+
+// RefreshAccessToken gets the new access token of the session and returns it. Requests refresh
+// the expired token themselves, so it's rarely needed
+func (c *Client) RefreshAccessToken(ctx context.Context) (string, error) {
+	if _, refreshToken := c.tokens(); refreshToken == "" {
+		return "", ErrNoSession
+	}
+
+	var resp tokenResponse
+
+	r := request{method: http.MethodPost, path: "/api/auth/refresh-token", public: true, refreshCookie: true}
+	if err := c.call(ctx, r, nil, &resp); err != nil {
+		return "", err
+	}
+
+	c.setTokens(resp.AccessToken, "")
+
+	return resp.AccessToken, nil
+}
+
+// SignOut ends the session, the tokens are forgotten even if the request fails
+func (c *Client) SignOut(ctx context.Context) error {
+	defer func() {
+		c.mu.Lock()
+		c.accessToken, c.refreshToken = "", ""
+		c.mu.Unlock()
+	}()
+
+	if _, refreshToken := c.tokens(); refreshToken == "" {
+		return ErrNoSession
+	}
+
+	r := request{method: http.MethodPost, path: "/api/auth/sign-out", public: true, refreshCookie: true}
+
+	return c.call(ctx, r, nil, nil)
+}
+
+// VerifyEmail confirms the email with the token sent to it
+func (c *Client) VerifyEmail(ctx context.Context, token string) error {
+	return c.call(ctx, publicPost("/api/auth/verify-email"), &tokenRequest{Token: token}, nil)
+}
+
+// SendEmailVerification sends the verification link to the email of the account again
+func (c *Client) SendEmailVerification(ctx context.Context) error {
+	return c.call(ctx, request{method: http.MethodPost, path: "/api/user/email/verification"}, nil, nil)
+}
+
+// ForgotPassword sends the password reset link to the email
+func (c *Client) ForgotPassword(ctx context.Context, email string) error {
+	return c.call(ctx, publicPost("/api/auth/forgot-password"), &credentials{Email: email}, nil)
+}
+
+// ResetPassword sets the new password with the token sent by ForgotPassword
+func (c *Client) ResetPassword(ctx context.Context, token, password string) error {
+	return c.call(ctx, publicPost("/api/auth/reset-password"), &tokenRequest{Token: token, Password: password}, nil)
+}
+
+// ConfirmEmailChange confirms the new email with the token sent to it
+func (c *Client) ConfirmEmailChange(ctx context.Context, token string) error {
+	return c.call(ctx, publicPost("/api/auth/confirm-email-change"), &tokenRequest{Token: token}, nil)
+}
+
+// UnlockAccount unlocks the account locked after failed sign in attempts with the token sent to its email
+func (c *Client) UnlockAccount(ctx context.Context, token string) error {
+	return c.call(ctx, publicPost("/api/auth/unlock-account"), &tokenRequest{Token: token}, nil)
+}
+
+// Providers returns names of the identity providers of single sign-on
+func (c *Client) Providers(ctx context.Context) ([]string, error) {
+	var providers []string
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/auth/oidc", public: true}, nil, &providers)
+
+	return providers, err
+}
+
+// ProviderLoginURL returns the URL which starts single sign-on with the provider in the browser
+func (c *Client) ProviderLoginURL(provider string) string {
+	return c.baseURL.String() + "/api/auth/oidc/" + url.PathEscape(provider)
+}
+
+// Health checks that the API is up
+func (c *Client) Health(ctx context.Context) error {
+	return c.call(ctx, request{method: http.MethodGet, path: "/healthz", public: true}, nil, nil)
+}
+
+// Ready returns checks of the dependencies of the API, it fails with 503 if any of them is down
+func (c *Client) Ready(ctx context.Context) (Readiness, error) {
+	var readiness Readiness
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/readyz", public: true, noRetry: true}, nil, &readiness)
+
+	return readiness, err
+}
+
+func (c *Client) Version(ctx context.Context) (Version, error) {
+	var version Version
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/version", public: true}, nil, &version)
+
+	return version, err
+}
+
+// publicPost is the request with the token sent by email, which doesn't need the session
+func publicPost(path string) request {
+	return request{method: http.MethodPost, path: path, public: true}
+}
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ChangesCursor returns the cursor of the latest change. The sync client lists its files, then follows
+// changes after the cursor, so nothing made while listing is missed
+func (c *Client) ChangesCursor(ctx context.Context) (int64, error) {
+	var page ChangesPage
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/changes"}, nil, &page)
+
+	return page.Cursor, err
+}
+
+// Changes returns changes after the cursor, oldest first. If there are none, the server waits for them
+// up to the wait, which is rounded to seconds, 60 seconds at most. ErrCursorExpired means the changes
+// after the cursor are removed, the client lists its files again
+func (c *Client) Changes(ctx context.Context, cursor int64, wait time.Duration) (ChangesPage, error) {
+	var page ChangesPage
+
+	query := url.Values{
+		"since":   {strconv.FormatInt(cursor, 10)},
+		"timeout": {strconv.Itoa(int(wait / time.Second))},
+	}
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/changes", query: query}, nil, &page)
+
+	return page, err
+}
@@ -0,0 +1,369 @@
+// Package client is the Go client of the Cloud File Storage REST API
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// refreshCookie is the cookie of the session, the access token is refreshed with it
+	refreshCookie = "refresh_token"
+
+	defaultAttempts   = 3
+	defaultBackoff    = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Client calls the API on behalf of the user. It's authenticated either by signing in, then the access token
+// is refreshed with the refresh cookie of the session when it expires, or with a personal access token.
+// The client is safe for concurrent use
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	userAgent  string
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+
+	mu           sync.Mutex
+	accessToken  string
+	refreshToken string
+	// refreshing serializes refreshes, requests failed with the same expired token refresh it once
+	refreshing sync.Mutex
+}
+
+type Option func(c *Client)
+
+// WithHTTPClient sets the HTTP client, e.g. with the timeouts or the transport of the service
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithAccessToken authenticates the client with the access token, e.g. a personal access token
+func WithAccessToken(token string) Option {
+	return func(c *Client) {
+		c.accessToken = token
+	}
+}
+
+// WithRefreshToken restores the session saved with Client.RefreshToken, the access token is refreshed
+// before the first request
+func WithRefreshToken(token string) Option {
+	return func(c *Client) {
+		c.refreshToken = token
+	}
+}
+
+// WithRetry sets how many times requests are attempted and the delay before the second attempt,
+// the delay is doubled for each next attempt. One attempt disables retries
+func WithRetry(attempts int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.attempts = max(attempts, 1)
+		c.backoff = backoff
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(c *Client) {
+		c.userAgent = userAgent
+	}
+}
+
+// New returns the client of the API at the base URL, e.g. https://files.example.com
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL must be http or https, got %q", baseURL)
+	}
+
+	c := &Client{
+		baseURL:    u,
+		httpClient: http.DefaultClient,
+		userAgent:  "cfs-go-client",
+		attempts:   defaultAttempts,
+		backoff:    defaultBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+// AccessToken returns the current access token
+func (c *Client) AccessToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.accessToken
+}
+
+// RefreshToken returns the refresh token of the session, so it can be restored with WithRefreshToken
+func (c *Client) RefreshToken() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.refreshToken
+}
+
+func (c *Client) setTokens(accessToken, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.accessToken = accessToken
+	if refreshToken != "" {
+		c.refreshToken = refreshToken
+	}
+}
+
+func (c *Client) tokens() (string, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.accessToken, c.refreshToken
+}
+
+// request describes the call of the API. The body is built for every attempt, so the request can be retried
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	// body returns the body and its length, -1 if it's unknown
+	body        func() (io.Reader, int64, error)
+	contentType string
+	// public requests are sent without the access token
+	public bool
+	// refreshCookie sends the refresh token of the session
+	refreshCookie bool
+	// noRetry disables retries of requests which aren't safe to repeat
+	noRetry bool
+}
+
+// do sends the request and returns the successful response, the caller closes its body.
+// Failed responses are returned as *Error. The access token is refreshed once if it has expired,
+// network errors, 429, 502, 503 and 504 are retried with the backoff
+func (c *Client) do(ctx context.Context, r request) (*http.Response, error) {
+	refreshed := false
+
+	for attempt := 1; ; attempt++ {
+		accessToken, refreshToken := c.tokens()
+
+		// the restored session gets its access token before the first request
+		if !r.public && accessToken == "" && refreshToken != "" && !refreshed {
+			if err := c.refresh(ctx, accessToken); err != nil {
+				return nil, err
+			}
+
+			refreshed = true
+			accessToken, refreshToken = c.tokens()
+		}
+
+		resp, err := c.send(ctx, r, accessToken, refreshToken)
+		if err != nil {
+			if ctx.Err() != nil || r.noRetry || attempt >= c.attempts {
+				return nil, err
+			}
+
+			if err := c.wait(ctx, attempt, 0); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if resp.StatusCode < http.StatusBadRequest {
+			return resp, nil
+		}
+
+		apiErr := responseError(resp)
+
+		if resp.StatusCode == http.StatusUnauthorized && !r.public && !refreshed && refreshToken != "" {
+			if err := c.refresh(ctx, accessToken); err != nil {
+				return nil, err
+			}
+
+			refreshed = true
+			attempt--
+
+			continue
+		}
+
+		if !retryable(resp.StatusCode) || r.noRetry || attempt >= c.attempts {
+			return nil, apiErr
+		}
+
+		if err := c.wait(ctx, attempt, apiErr.RetryAfter); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, r request, accessToken, refreshToken string) (*http.Response, error) {
+	u := *c.baseURL
+	u.Path += r.path
+	u.RawQuery = r.query.Encode()
+
+	var body io.Reader
+	var length int64
+
+	if r.body != nil {
+		var err error
+
+		body, length, err = r.body()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.ContentLength = length
+	}
+
+	for name, values := range r.header {
+		req.Header[name] = values
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	if r.contentType != "" {
+		req.Header.Set("Content-Type", r.contentType)
+	}
+
+	if !r.public && accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	if r.refreshCookie && refreshToken != "" {
+		req.AddCookie(&http.Cookie{Name: refreshCookie, Value: refreshToken})
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// the session is rotated by signing in and refreshing, the cleared cookie ends it
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == refreshCookie {
+			c.mu.Lock()
+			c.refreshToken = cookie.Value
+			c.mu.Unlock()
+		}
+	}
+
+	return resp, nil
+}
+
+// refresh gets the new access token with the refresh cookie, unless another request has already
+// replaced the expired one
+func (c *Client) refresh(ctx context.Context, expired string) error {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+
+	if accessToken, _ := c.tokens(); accessToken != expired && accessToken != "" {
+		return nil
+	}
+
+	_, err := c.RefreshAccessToken(ctx)
+
+	return err
+}
+
+// wait sleeps before the next attempt, as long as the server asked for if it did
+func (c *Client) wait(ctx context.Context, attempt int, retryAfter time.Duration) error {
+	d := retryAfter
+	if d <= 0 {
+		d = min(c.backoff<<(attempt-1), c.maxBackoff)
+		// jitter spreads retries of the clients failed at the same time
+		d += time.Duration(rand.Int64N(int64(d)/5 + 1))
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func retryable(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// call sends the request with the JSON body if in isn't nil and decodes the JSON response into out
+// if it isn't nil
+func (c *Client) call(ctx context.Context, r request, in, out any) error {
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+
+		r.contentType = "application/json"
+		r.body = func() (io.Reader, int64, error) {
+			return bytes.NewReader(data), int64(len(data)), nil
+		}
+	}
+
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	if out == nil {
+		return nil
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode response of %s %s: %w", r.method, r.path, err)
+	}
+
+	return nil
+}
+
+// closeBody drains the rest of the body, so the connection is reused
+func closeBody(resp *http.Response) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	_ = resp.Body.Close()
+}
+
+// ErrNoSession is returned when the access token is refreshed without the session
+var ErrNoSession = errors.New("client: not signed in")
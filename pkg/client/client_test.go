@@ -0,0 +1,693 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// apiServer fakes routes of the API backed by S3 and breaks requests on purpose, e.g. to test retries
+// and resumed downloads. Other routes are tested against the application, see newApp
+type apiServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	accessToken string
+	files       map[string][]byte
+	// failures are statuses answered to the next requests before they are served
+	failures []int
+	// cut breaks the connection of the next download after so many bytes
+	cut      int
+	requests []*http.Request
+	// jobs are statuses of queued jobs, each poll of the job returns the next one
+	jobs map[int64][]string
+	// exports are archives of exported folders by the ids of their jobs
+	exports map[int64][]byte
+}
+
+func newAPIServer(t *testing.T) *apiServer {
+	s := &apiServer{files: map[string][]byte{}, jobs: map[int64][]string{}, exports: map[int64][]byte{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/auth/sign-in", s.signIn)
+	mux.HandleFunc("GET /api/user/me", s.authenticated(s.me))
+	mux.HandleFunc("GET /api/resource", s.authenticated(s.resource))
+	mux.HandleFunc("POST /api/resource", s.authenticated(s.upload))
+	mux.HandleFunc("DELETE /api/resource", s.authenticated(s.delete))
+	mux.HandleFunc("GET /api/resource/download", s.authenticated(s.download))
+	mux.HandleFunc("GET /api/jobs/{id}", s.authenticated(s.job))
+	mux.HandleFunc("GET /api/exports/{id}", s.authenticated(s.export))
+	mux.HandleFunc("GET /api/exports/{id}/download", s.exportDownload)
+	mux.HandleFunc("GET /api/events", s.authenticated(s.events))
+
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r)
+
+		if len(s.failures) > 0 {
+			status := s.failures[0]
+			s.failures = s.failures[1:]
+			s.mu.Unlock()
+
+			w.Header().Set("Retry-After", "0")
+			writeJSON(w, status, map[string]string{"message": http.StatusText(status)})
+
+			return
+		}
+		s.mu.Unlock()
+
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *apiServer) client(t *testing.T, opts ...Option) *Client {
+	c, err := New(s.URL, append([]Option{WithRetry(3, time.Millisecond)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func (s *apiServer) fail(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = append(s.failures, statuses...)
+}
+
+func (s *apiServer) signIn(w http.ResponseWriter, r *http.Request) {
+	var body credentials
+	_ = json.NewDecoder(r.Body).Decode(&body)
+
+	if body.Email != "user@example.com" || body.Password != "secret" {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Login or password invalid"})
+
+		return
+	}
+
+	s.mu.Lock()
+	s.accessToken = "access-1"
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: refreshCookie, Value: "refresh-1", Path: "/", HttpOnly: true})
+	writeJSON(w, http.StatusOK, map[string]string{"access_token": "access-1"})
+}
+
+func (s *apiServer) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		valid := r.Header.Get("Authorization") == "Bearer "+s.accessToken
+		s.mu.Unlock()
+
+		if !valid {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"message": "Unauthorized"})
+
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func (s *apiServer) me(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, &Profile{Email: "user@example.com", EmailVerified: true, Role: "user"})
+}
+
+func (s *apiServer) resource(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, ok := s.files[r.URL.Query().Get("path")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+
+		return
+	}
+
+	p := r.URL.Query().Get("path")
+	writeJSON(w, http.StatusOK, &Resource{Path: p, Name: filepath.Base(p), Size: int64(len(data)), Type: TypeFile})
+}
+
+// delete removes files at once and queues the job deleting the directory, the job fails for /locked/
+func (s *apiServer) delete(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasSuffix(path, "/") {
+		delete(s.files, path)
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	id := int64(len(s.jobs) + 1)
+	s.jobs[id] = []string{JobRunning, JobSucceeded}
+	if path == "/locked/" {
+		s.jobs[id] = []string{JobFailed}
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/jobs/%d", id))
+	writeJSON(w, http.StatusAccepted, &Job{Id: id, Type: "resource.delete", Status: JobQueued})
+}
+
+func (s *apiServer) job(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.mu.Lock()
+	statuses, ok := s.jobs[id]
+	if ok && len(statuses) > 1 {
+		s.jobs[id] = statuses[1:]
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+
+		return
+	}
+
+	job := &Job{Id: id, Type: "resource.delete", Status: statuses[0]}
+	if job.Status == JobFailed {
+		job.Error = "internal error"
+	}
+
+	writeJSON(w, http.StatusOK, job)
+}
+
+func (s *apiServer) upload(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(1 << 20); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Bad request"})
+
+		return
+	}
+
+	paths := map[string]string{}
+	_ = json.Unmarshal([]byte(r.FormValue("paths")), &paths)
+
+	etags := map[string]string{}
+	_ = json.Unmarshal([]byte(r.FormValue("etags")), &etags)
+
+	stored := []Resource{}
+
+	for _, header := range r.MultipartForm.File["files"] {
+		name, ok := paths[header.Filename]
+		if !ok {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Bad request"})
+
+			return
+		}
+
+		f, _ := header.Open()
+		data, _ := io.ReadAll(f)
+		_ = f.Close()
+
+		p := r.URL.Query().Get("path") + name
+
+		s.mu.Lock()
+		current, exists := s.files[p]
+		if expected, ok := etags[header.Filename]; ok && (expected != "" || exists) && expected != etagOf(current) {
+			s.mu.Unlock()
+			writeJSON(w, http.StatusPreconditionFailed, map[string]any{"message": "Conflict", "conflicts": []string{p}})
+
+			return
+		}
+
+		s.files[p] = data
+		s.mu.Unlock()
+
+		stored = append(stored, Resource{
+			Path: p,
+			Name: filepath.Base(p),
+			Size: int64(len(data)),
+			Type: TypeFile,
+			ETag: etagOf(data),
+		})
+	}
+
+	writeJSON(w, http.StatusCreated, stored)
+}
+
+// download serves the file with ranges like the API does, the folder is exported
+func (s *apiServer) download(w http.ResponseWriter, r *http.Request) {
+	if p := r.URL.Query().Get("path"); strings.HasSuffix(p, "/") {
+		s.exportFolder(w, p)
+
+		return
+	}
+
+	s.mu.Lock()
+	data, ok := s.files[r.URL.Query().Get("path")]
+	cut := s.cut
+	s.cut = 0
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+
+		return
+	}
+
+	start := 0
+	status := http.StatusOK
+
+	if header := r.Header.Get("Range"); header != "" {
+		_, _ = fmt.Sscanf(header, "bytes=%d-", &start)
+		if start >= len(data) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", len(data)))
+			writeJSON(w, http.StatusRequestedRangeNotSatisfiable, map[string]string{"message": "Bad request"})
+
+			return
+		}
+
+		status = http.StatusPartialContent
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(data)-1, len(data)))
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)-start))
+	w.WriteHeader(status)
+
+	if cut > 0 {
+		_, _ = w.Write(data[start : start+cut])
+		w.(http.Flusher).Flush()
+
+		panic(http.ErrAbortHandler)
+	}
+
+	_, _ = w.Write(data[start:])
+}
+
+// exportFolder queues the job of the export, the archive is the files of the folder one after another
+func (s *apiServer) exportFolder(w http.ResponseWriter, folder string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var archive []byte
+	for _, p := range slices.Sorted(maps.Keys(s.files)) {
+		if strings.HasPrefix(p, folder) {
+			archive = append(archive, s.files[p]...)
+		}
+	}
+
+	id := int64(len(s.jobs) + 1)
+	s.jobs[id] = []string{JobRunning, JobSucceeded}
+	s.exports[id] = archive
+
+	w.Header().Set("Location", fmt.Sprintf("/api/exports/%d", id))
+	writeJSON(w, http.StatusAccepted, &Export{Id: id, JobId: id, Format: ExportZip, Status: ExportPending})
+}
+
+func (s *apiServer) export(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.mu.Lock()
+	_, ok := s.exports[id]
+	s.mu.Unlock()
+
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not found"})
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &Export{
+		Id:          id,
+		JobId:       id,
+		Format:      ExportZip,
+		Status:      ExportReady,
+		DownloadURL: fmt.Sprintf("http://%s/api/exports/%d/download?expires=1&signature=signed", r.Host, id),
+	})
+}
+
+// exportDownload serves the archive by the signed link, without authorization
+func (s *apiServer) exportDownload(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(r.PathValue("id"), 10, 64)
+
+	s.mu.Lock()
+	archive, ok := s.exports[id]
+	s.mu.Unlock()
+
+	if !ok || r.URL.Query().Get("signature") != "signed" || r.Header.Get("Authorization") != "" {
+		writeJSON(w, http.StatusForbidden, map[string]string{"message": "Download link invalid or expired"})
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Length", fmt.Sprint(len(archive)))
+	_, _ = w.Write(archive)
+}
+
+// etagOf is the etag of the file in the test server, the length is enough to tell versions apart
+func etagOf(data []byte) string {
+	return fmt.Sprintf("len-%d", len(data))
+}
+
+// events streams two events of the requested types and ends the stream like the server on shutdown
+func (s *apiServer) events(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	_, _ = fmt.Fprint(w, "retry: 3000\n\n: heartbeat\n\n")
+
+	for i, p := range []string{"/docs/a.txt", "/docs/b.txt"} {
+		_, _ = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: {\"id\":\"%d\",\"type\":%q,\"path\":%q,\"cursor\":%d}\n\n",
+			i, r.URL.Query().Get("types"), i, r.URL.Query().Get("types"), p, i+1)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestClient_Retries(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	s.fail(http.StatusServiceUnavailable, http.StatusTooManyRequests)
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); !errors.Is(err, ErrServer) {
+		t.Fatalf("sign in must not be retried, got: %v", err)
+	}
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); !errors.Is(err, ErrTooManyRequests) {
+		t.Fatalf("sign in must not be retried, got: %v", err)
+	}
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.fail(http.StatusServiceUnavailable, http.StatusBadGateway)
+
+	if _, err := c.Me(ctx); err != nil {
+		t.Fatalf("request must be retried, got: %v", err)
+	}
+
+	s.fail(http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+	if _, err := c.Me(ctx); !errors.Is(err, ErrServer) {
+		t.Fatalf("request must fail after the last attempt, got: %v", err)
+	}
+
+	s.fail(http.StatusBadRequest)
+
+	if _, err := c.Me(ctx); !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("bad request must not be retried, got: %v", err)
+	}
+
+	s.fail(http.StatusInsufficientStorage)
+
+	_, err := c.Upload(ctx, "/", "file.txt", strings.NewReader("content"), 7)
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("exceeded quota must fail with ErrQuotaExceeded, got: %v", err)
+	}
+}
+
+func TestClient_Upload(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+
+	// the retried upload sends the content from the start
+	s.fail(http.StatusServiceUnavailable)
+
+	var last, total int64
+
+	stored, err := c.Upload(
+		ctx,
+		"/docs/",
+		"nested/report.txt",
+		bytes.NewReader(content),
+		int64(len(content)),
+		WithProgress(func(transferred, size int64) {
+			last, total = transferred, size
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Path != "/docs/nested/report.txt" || stored.Size != int64(len(content)) {
+		t.Errorf("upload must return the stored file, got: %+v", stored)
+	}
+
+	if !bytes.Equal(s.files["/docs/nested/report.txt"], content) {
+		t.Errorf("uploaded file must have the content, got %d bytes", len(s.files["/docs/nested/report.txt"]))
+	}
+
+	if last != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("progress must reach the size, got: %d of %d", last, total)
+	}
+
+	local := filepath.Join(t.TempDir(), "local.txt")
+	if err := os.WriteFile(local, []byte("local"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.UploadFile(ctx, "/", local); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(s.files["/local.txt"]) != "local" {
+		t.Errorf("local file must be uploaded with its name, got: %q", s.files["/local.txt"])
+	}
+
+	// the file changed by another client isn't overwritten
+	_, err = c.Upload(ctx, "/", "local.txt", strings.NewReader("mine"), 4, WithExpectedETag("len-3"))
+	if !errors.Is(err, ErrPreconditionFailed) || string(s.files["/local.txt"]) != "local" {
+		t.Errorf("upload of the changed file must fail with ErrPreconditionFailed, got: %v", err)
+	}
+
+	if _, err := c.Upload(ctx, "/", "local.txt", strings.NewReader("new"), 3, WithExpectedETag("")); err == nil {
+		t.Errorf("upload of the new file must fail if the file exists")
+	}
+
+	stored, err = c.Upload(ctx, "/", "local.txt", strings.NewReader("mine"), 4, WithExpectedETag("len-5"))
+	if err != nil || stored.ETag != "len-4" || string(s.files["/local.txt"]) != "mine" {
+		t.Errorf("upload of the unchanged file must overwrite it, got: %+v, %v", stored, err)
+	}
+}
+
+func TestClient_DownloadResumes(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	content := bytes.Repeat([]byte("abcdefghij"), 10000)
+	s.files["/file.bin"] = content
+
+	// the connection breaks in the middle, the download continues from the written byte
+	s.cut = 30000
+
+	var buf bytes.Buffer
+	var last, total int64
+
+	n, err := c.Download(ctx, "/file.bin", &buf, WithProgress(func(transferred, size int64) {
+		last, total = transferred, size
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != int64(len(content)) || !bytes.Equal(buf.Bytes(), content) {
+		t.Fatalf("resumed download must have the content, got %d bytes", n)
+	}
+
+	if last != int64(len(content)) || total != int64(len(content)) {
+		t.Errorf("progress must reach the size, got: %d of %d", last, total)
+	}
+
+	if r := s.requests[len(s.requests)-1]; r.Header.Get("Range") != "bytes=30000-" {
+		t.Errorf("download must be resumed with the range, got: %q", r.Header.Get("Range"))
+	}
+
+	if _, err := c.Download(ctx, "/missing.bin", io.Discard); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing file must fail with ErrNotFound, got: %v", err)
+	}
+}
+
+func TestClient_DownloadFile(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("the content of the file")
+	s.files["/file.txt"] = content
+
+	local := filepath.Join(t.TempDir(), "file.txt")
+
+	// the part left by the interrupted download
+	if err := os.WriteFile(local+partSuffix, content[:10], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DownloadFile(ctx, "/file.txt", local); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(local)
+	if err != nil || !bytes.Equal(data, content) {
+		t.Fatalf("downloaded file must have the content, got: %q %v", data, err)
+	}
+
+	if _, err := os.Stat(local + partSuffix); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("part must be renamed when the download is complete, got: %v", err)
+	}
+
+	// the part is complete, only the rename is left
+	if err := os.WriteFile(local+partSuffix, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DownloadFile(ctx, "/file.txt", local); err != nil {
+		t.Fatalf("complete part must be renamed, got: %v", err)
+	}
+}
+
+func TestClient_DownloadFolder(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	s.files["/docs/a.txt"] = []byte("first ")
+	s.files["/docs/b.txt"] = []byte("second")
+	s.files["/other.txt"] = []byte("other")
+
+	var buf bytes.Buffer
+	var last, total int64
+
+	n, err := c.Download(ctx, "/docs/", &buf, WithProgress(func(transferred, size int64) {
+		last, total = transferred, size
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 12 || buf.String() != "first second" {
+		t.Fatalf("folder must be downloaded as the archive of the export, got %d bytes: %q", n, buf.String())
+	}
+
+	if last != 12 || total != 12 {
+		t.Errorf("progress must reach the size of the archive, got: %d of %d", last, total)
+	}
+
+	if statuses := s.jobs[1]; len(statuses) != 1 || statuses[0] != JobSucceeded {
+		t.Errorf("job of the export must be polled until it succeeds, got: %v", statuses)
+	}
+
+	// the part of the folder can't be resumed, the archive is written from the start
+	local := filepath.Join(t.TempDir(), "docs.zip")
+	if err := os.WriteFile(local+partSuffix, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.DownloadFile(ctx, "/docs/", local); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(local)
+	if err != nil || string(data) != "first second" {
+		t.Errorf("downloaded archive must replace the part, got: %q %v", data, err)
+	}
+}
+
+func TestClient_Events(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []Event
+
+	err := c.Events(ctx, EventsFilter{Types: []string{EventFileCreated}}, func(event Event) error {
+		received = append(received, event)
+
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(received) != 2 || received[1].Type != EventFileCreated || received[1].Path != "/docs/b.txt" ||
+		received[1].Cursor != 2 {
+		t.Errorf("events must be received until the stream ends, got: %+v", received)
+	}
+
+	stop := errors.New("stop")
+
+	err = c.Events(ctx, EventsFilter{}, func(event Event) error {
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Errorf("error of the handler must end the stream, got: %v", err)
+	}
+}
+
+func TestClient_DeleteWaitsForJob(t *testing.T) {
+	s := newAPIServer(t)
+	c := s.client(t)
+	ctx := context.Background()
+
+	if err := c.SignIn(ctx, "user@example.com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Delete(ctx, "/docs/"); err != nil {
+		t.Fatalf("error while delete directory: %v", err)
+	}
+
+	if statuses := s.jobs[1]; len(statuses) != 1 || statuses[0] != JobSucceeded {
+		t.Errorf("job must be polled until it succeeds, got: %v", statuses)
+	}
+
+	if err := c.Delete(ctx, "/locked/"); !errors.Is(err, ErrJobFailed) {
+		t.Errorf("failed job must return ErrJobFailed, got: %v", err)
+	}
+
+	if err := c.Delete(ctx, "/docs/a.txt"); err != nil {
+		t.Errorf("error while delete file: %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	TypeFile      = "FILE"
+	TypeDirectory = "DIRECTORY"
+)
+
+// Resource is the file or the directory, paths of directories end with /
+type Resource struct {
+	Path string `json:"path"`
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Type string `json:"type"`
+	// ETag is the version of the file, the upload with WithExpectedETag overwrites only this version
+	ETag string `json:"etag"`
+}
+
+func (r Resource) IsDirectory() bool {
+	return r.Type == TypeDirectory
+}
+
+const (
+	ChangeCreate = "create"
+	ChangeUpdate = "update"
+	ChangeMove   = "move"
+	ChangeDelete = "delete"
+)
+
+// Change is the change of the file or the directory, FromPath is the previous path of the moved resource
+type Change struct {
+	Cursor    int64  `json:"cursor"`
+	Action    string `json:"action"`
+	Path      string `json:"path"`
+	FromPath  string `json:"from_path"`
+	Type      string `json:"type"`
+	ETag      string `json:"etag"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ChangesPage is the page of changes, Cursor is passed to the next call of Client.Changes
+type ChangesPage struct {
+	Changes []Change `json:"changes"`
+	Cursor  int64    `json:"cursor"`
+	HasMore bool     `json:"has_more"`
+}
+
+const (
+	EventFileCreated  = "file.created"
+	EventFileUpdated  = "file.updated"
+	EventFileMoved    = "file.moved"
+	EventFileDeleted  = "file.deleted"
+	EventQuotaWarning = "quota.warning"
+	EventJobDone      = "job.done"
+	EventExportReady  = "export.ready"
+	EventSignIn       = "auth.sign-in"
+	EventSignOut      = "auth.sign-out"
+
+	EventWebhookDisabled = "webhook.disabled"
+)
+
+// Event happened with the account of the user. Path and FromPath are set for file events, Cursor of the file
+// event is its position in the journal of changes
+type Event struct {
+	Id        string            `json:"id"`
+	Type      string            `json:"type"`
+	Path      string            `json:"path"`
+	FromPath  string            `json:"from_path"`
+	Cursor    int64             `json:"cursor"`
+	Data      map[string]string `json:"data"`
+	CreatedAt string            `json:"created_at"`
+}
+
+// Statuses of background jobs
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobProgress counts processed items of the job, zero Total means it isn't known yet
+type JobProgress struct {
+	Done  int64 `json:"done"`
+	Total int64 `json:"total"`
+}
+
+// Job is the background job of the user, e.g. deleting or moving the directory. Result is set when it succeeds
+type Job struct {
+	Id          int64           `json:"id"`
+	Type        string          `json:"type"`
+	Status      string          `json:"status"`
+	Progress    JobProgress     `json:"progress"`
+	Result      json.RawMessage `json:"result"`
+	Error       string          `json:"error"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	CreatedAt   string          `json:"created_at"`
+	StartedAt   string          `json:"started_at"`
+	FinishedAt  string          `json:"finished_at"`
+}
+
+// Finished tells if the job won't change anymore
+func (j Job) Finished() bool {
+	return j.Status == JobSucceeded || j.Status == JobFailed || j.Status == JobCancelled
+}
+
+// Formats and statuses of exports
+const (
+	ExportZip   = "zip"
+	ExportTarGz = "tar.gz"
+
+	ExportPending = "pending"
+	ExportReady   = "ready"
+)
+
+// Export is the archive of paths of the user written by the job. DownloadURL of the ready export works
+// without authorization until ExpiresAt
+type Export struct {
+	Id          int64    `json:"id"`
+	JobId       int64    `json:"job_id"`
+	Format      string   `json:"format"`
+	Paths       []string `json:"paths"`
+	Status      string   `json:"status"`
+	Size        int64    `json:"size"`
+	DownloadURL string   `json:"download_url"`
+	CreatedAt   string   `json:"created_at"`
+	FinishedAt  string   `json:"finished_at"`
+	ExpiresAt   string   `json:"expires_at"`
+}
+
+type Profile struct {
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Role          string `json:"role"`
+}
+
+// AccessTokenRequest describes the personal access token. Scopes are read, write and delete,
+// the folder restricts the token to the files in it, zero ExpiresAt never expires
+type AccessTokenRequest struct {
+	Name      string
+	Scopes    []string
+	Folder    string
+	ExpiresAt time.Time
+}
+
+type AccessToken struct {
+	Id         int64    `json:"id"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	Folder     string   `json:"folder"`
+	ExpiresAt  string   `json:"expires_at"`
+	LastUsedAt string   `json:"last_used_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+// NewAccessToken is the created personal access token, the secret is shown only once
+type NewAccessToken struct {
+	AccessToken
+	Token string `json:"token"`
+}
+
+type S3Key struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	AccessKeyId string `json:"access_key_id"`
+	LastUsedAt  string `json:"last_used_at"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// NewS3Key is the created access key of the S3 gateway, the secret is shown only once
+type NewS3Key struct {
+	S3Key
+	SecretAccessKey string `json:"secret_access_key"`
+}
+
+type SSHKey struct {
+	Id          int64  `json:"id"`
+	Name        string `json:"name"`
+	PublicKey   string `json:"public_key"`
+	Fingerprint string `json:"fingerprint"`
+	LastUsedAt  string `json:"last_used_at"`
+	CreatedAt   string `json:"created_at"`
+}
+
+// Identity is the account of the identity provider linked for single sign-on
+type Identity struct {
+	Id        int64  `json:"id"`
+	Provider  string `json:"provider"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookRequest describes the webhook. Events are file events, e.g. EventFileCreated, the empty secret is
+// generated, the empty folder is the root
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events"`
+	Folder string   `json:"folder,omitempty"`
+}
+
+// Webhook receives file events of the user, it's disabled after failed deliveries in a row
+type Webhook struct {
+	Id         int64    `json:"id"`
+	URL        string   `json:"url"`
+	Events     []string `json:"events"`
+	Folder     string   `json:"folder"`
+	Failures   int      `json:"failures"`
+	DisabledAt string   `json:"disabled_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+func (w Webhook) Disabled() bool {
+	return w.DisabledAt != ""
+}
+
+// NewWebhook is the created webhook, the secret signing its deliveries is shown only once
+type NewWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+type WebhookAttempt struct {
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error"`
+	DurationMs int64  `json:"duration_ms"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// WebhookDelivery is the event sent to the webhook with its attempts, oldest first
+type WebhookDelivery struct {
+	Id            int64            `json:"id"`
+	EventId       string           `json:"event_id"`
+	EventType     string           `json:"event_type"`
+	Status        string           `json:"status"`
+	Attempts      []WebhookAttempt `json:"attempts"`
+	NextAttemptAt string           `json:"next_attempt_at"`
+	Payload       string           `json:"payload"`
+	CreatedAt     string           `json:"created_at"`
+}
+
+type WebhookDeliveriesPage struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	PerPage    int               `json:"per_page"`
+}
+
+// ActivityFilter filters events of the activity log, zero fields match all events.
+// UserId, Email and Ip are used only by administrators
+type ActivityFilter struct {
+	UserId  int64
+	Email   string
+	Ip      string
+	Action  string
+	Result  string
+	From    time.Time
+	To      time.Time
+	Page    int
+	PerPage int
+}
+
+type ActivityEvent struct {
+	Id         int64  `json:"id"`
+	UserId     int64  `json:"user_id"`
+	Email      string `json:"email"`
+	Action     string `json:"action"`
+	Result     string `json:"result"`
+	Ip         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	Path       string `json:"path"`
+	TargetPath string `json:"target_path"`
+	CreatedAt  string `json:"created_at"`
+}
+
+type ActivityPage struct {
+	Events  []ActivityEvent `json:"events"`
+	Total   int64           `json:"total"`
+	Page    int             `json:"page"`
+	PerPage int             `json:"per_page"`
+}
+
+type User struct {
+	Id                    int64  `json:"id"`
+	Email                 string `json:"email"`
+	EmailVerified         bool   `json:"email_verified"`
+	Role                  string `json:"role"`
+	QuotaBytes            *int64 `json:"quota_bytes"`
+	Disabled              bool   `json:"disabled"`
+	DisabledAt            string `json:"disabled_at"`
+	PasswordLoginDisabled bool   `json:"password_login_disabled"`
+	CreatedAt             string `json:"created_at"`
+}
+
+type UsersPage struct {
+	Users   []User `json:"users"`
+	Total   int64  `json:"total"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+}
+
+type Usage struct {
+	UsedBytes  int64 `json:"used_bytes"`
+	Objects    int64 `json:"objects"`
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+type HealthCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+type Readiness struct {
+	Status string        `json:"status"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+type Version struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Time      string `json:"time"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
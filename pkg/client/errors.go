@@ -0,0 +1,106 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrBadRequest          = errors.New("bad request")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+	ErrNotFound            = errors.New("not found")
+	ErrConflict            = errors.New("conflict")
+	ErrCursorExpired       = errors.New("cursor expired")
+	ErrPreconditionFailed  = errors.New("precondition failed")
+	ErrRangeNotSatisfiable = errors.New("range not satisfiable")
+	ErrTooManyRequests     = errors.New("too many requests")
+	ErrQuotaExceeded       = errors.New("storage quota exceeded")
+	ErrServer              = errors.New("server error")
+	ErrJobFailed           = errors.New("job failed")
+	ErrJobCancelled        = errors.New("job cancelled")
+)
+
+// FieldError is the invalid field of the request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is the failed response of the API. It matches the sentinel error of its status with errors.Is,
+// e.g. errors.Is(err, client.ErrNotFound)
+type Error struct {
+	StatusCode int
+	Message    string
+	// Fields are the invalid fields of the validation error
+	Fields []FieldError
+	// RetryAfter is how long to wait before the next attempt, if the server asked for it
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("client: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+
+	return fmt.Sprintf("client: %d %s", e.StatusCode, e.Message)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrCursorExpired:
+		return e.StatusCode == http.StatusGone
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrRangeNotSatisfiable:
+		return e.StatusCode == http.StatusRequestedRangeNotSatisfiable
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrQuotaExceeded:
+		return e.StatusCode == http.StatusInsufficientStorage
+	case ErrServer:
+		return e.StatusCode >= http.StatusInternalServerError
+	}
+
+	return false
+}
+
+// responseError reads the error response of the API and closes its body
+func responseError(resp *http.Response) *Error {
+	defer closeBody(resp)
+
+	e := &Error{StatusCode: resp.StatusCode}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		e.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	var body struct {
+		Message string       `json:"message"`
+		Errors  []FieldError `json:"errors"`
+	}
+
+	// proxies in front of the API may answer with anything, the status is enough then
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err == nil && json.Unmarshal(data, &body) == nil {
+		e.Message = body.Message
+		e.Fields = body.Errors
+	}
+
+	return e
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// EventsFilter selects events of the stream, zero fields match all events
+type EventsFilter struct {
+	Types  []string
+	Folder string
+}
+
+// Events streams events of the user to the handler until the context is done, the handler returns an error
+// or the server ends the stream, e.g. on shutdown or if the client doesn't keep up. The stream ended
+// by the server returns nil, the caller reconnects and catches up on missed file events with Changes
+func (c *Client) Events(ctx context.Context, filter EventsFilter, handle func(Event) error) error {
+	query := url.Values{}
+	if len(filter.Types) > 0 {
+		query.Set("types", strings.Join(filter.Types, ","))
+	}
+
+	if filter.Folder != "" {
+		query.Set("folder", filter.Folder)
+	}
+
+	r := request{
+		method: http.MethodGet,
+		path:   "/api/events",
+		query:  query,
+		header: http.Header{"Accept": {"text/event-stream"}},
+	}
+
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	// only data lines are needed, the type of the event is in its data too
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	var data strings.Builder
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data.WriteString(strings.TrimPrefix(value, " "))
+
+			continue
+		}
+
+		if line != "" || data.Len() == 0 {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+			return fmt.Errorf("client: decode event: %w", err)
+		}
+
+		data.Reset()
+
+		if err := handle(event); err != nil {
+			return err
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// CreateExport queues the archive of the paths in the format, ExportZip or ExportTarGz.
+// The archive is written in background, WaitExport returns the export when it's ready
+func (c *Client) CreateExport(ctx context.Context, format string, paths []string) (Export, error) {
+	in := struct {
+		Paths  []string `json:"paths"`
+		Format string   `json:"format"`
+	}{Paths: paths, Format: format}
+
+	var export Export
+
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/resource/export", noRetry: true}, in, &export)
+
+	return export, err
+}
+
+// Export returns the export, DownloadURL is set when it's ready
+func (c *Client) Export(ctx context.Context, id int64) (Export, error) {
+	var export Export
+
+	err := c.call(ctx, request{method: http.MethodGet, path: exportPath(id)}, nil, &export)
+
+	return export, err
+}
+
+// Exports returns exports of the user, newest first
+func (c *Client) Exports(ctx context.Context) ([]Export, error) {
+	var exports []Export
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/exports"}, nil, &exports)
+
+	return exports, err
+}
+
+// DeleteExport deletes the export with its archive, the download link stops working
+func (c *Client) DeleteExport(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: exportPath(id)}, nil, nil)
+}
+
+// WaitExport waits for the job writing the archive and returns the ready export.
+// The failed job returns ErrJobFailed, the cancelled one ErrJobCancelled
+func (c *Client) WaitExport(ctx context.Context, export Export) (Export, error) {
+	if export.Status == ExportReady {
+		return export, nil
+	}
+
+	if _, err := c.WaitJob(ctx, export.JobId); err != nil {
+		return export, err
+	}
+
+	return c.Export(ctx, export.Id)
+}
+
+func exportPath(id int64) string {
+	return "/api/exports/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	jobPollInterval    = 200 * time.Millisecond
+	maxJobPollInterval = 5 * time.Second
+)
+
+// Job returns the background job with its status and progress
+func (c *Client) Job(ctx context.Context, id int64) (Job, error) {
+	var job Job
+
+	err := c.call(ctx, request{method: http.MethodGet, path: jobPath(id)}, nil, &job)
+
+	return job, err
+}
+
+// CancelJob cancels the queued or running job, the running job becomes cancelled when it stops.
+// ErrConflict means the job is already finished
+func (c *Client) CancelJob(ctx context.Context, id int64) (Job, error) {
+	var job Job
+
+	err := c.call(ctx, request{method: http.MethodDelete, path: jobPath(id), noRetry: true}, nil, &job)
+
+	return job, err
+}
+
+// WaitJob polls the job until it's finished. The failed job returns ErrJobFailed with its error,
+// the cancelled one ErrJobCancelled
+func (c *Client) WaitJob(ctx context.Context, id int64) (Job, error) {
+	interval := jobPollInterval
+
+	for {
+		job, err := c.Job(ctx, id)
+		if err != nil {
+			return job, err
+		}
+
+		switch job.Status {
+		case JobSucceeded:
+			return job, nil
+		case JobFailed:
+			return job, fmt.Errorf("%w: %s", ErrJobFailed, job.Error)
+		case JobCancelled:
+			return job, ErrJobCancelled
+		}
+
+		select {
+		case <-ctx.Done():
+			return job, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		interval = min(interval*2, maxJobPollInterval)
+	}
+}
+
+// callJob makes the request which the server may accept as the background job and waits for the job
+func (c *Client) callJob(ctx context.Context, r request) error {
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	if resp.StatusCode != http.StatusAccepted {
+		return nil
+	}
+
+	var job Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return fmt.Errorf("client: decode response of %s %s: %w", r.method, r.path, err)
+	}
+
+	_, err = c.WaitJob(ctx, job.Id)
+
+	return err
+}
+
+func jobPath(id int64) string {
+	return "/api/jobs/" + strconv.FormatInt(id, 10)
+}
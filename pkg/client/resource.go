@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Resource returns the file or the directory at the path, paths of directories end with /
+func (c *Client) Resource(ctx context.Context, path string) (Resource, error) {
+	var resource Resource
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/resource", query: pathQuery(path)}, nil, &resource)
+
+	return resource, err
+}
+
+// Directory returns files and directories in the directory
+func (c *Client) Directory(ctx context.Context, path string) ([]Resource, error) {
+	var resources []Resource
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/directory", query: pathQuery(path)}, nil, &resources)
+
+	return resources, err
+}
+
+// CreateDirectory creates the directory, the path ends with /
+func (c *Client) CreateDirectory(ctx context.Context, path string) (Resource, error) {
+	var resource Resource
+
+	r := request{method: http.MethodPost, path: "/api/directory", query: pathQuery(path)}
+	err := c.call(ctx, r, nil, &resource)
+
+	return resource, err
+}
+
+// Delete deletes the file or the directory with everything in it. The directory is deleted by the background job,
+// Delete waits until it's finished
+func (c *Client) Delete(ctx context.Context, path string) error {
+	return c.callJob(ctx, request{method: http.MethodDelete, path: "/api/resource", query: pathQuery(path)})
+}
+
+// Move moves or renames the file or the directory. The directory is moved by the background job,
+// Move waits until it's finished
+func (c *Client) Move(ctx context.Context, from, to string) error {
+	query := url.Values{"from": {from}, "to": {to}}
+
+	return c.callJob(ctx, request{method: http.MethodGet, path: "/api/resource/move", query: query})
+}
+
+// Search returns files and directories with the query in their names
+func (c *Client) Search(ctx context.Context, query string) ([]Resource, error) {
+	var resources []Resource
+
+	r := request{method: http.MethodGet, path: "/api/resource/search", query: url.Values{"query": {query}}}
+	err := c.call(ctx, r, nil, &resources)
+
+	return resources, err
+}
+
+func pathQuery(path string) url.Values {
+	return url.Values{"path": {path}}
+}
+
+// errNotStored is returned when the upload succeeds but the file isn't in the response
+func errNotStored(name string) error {
+	return fmt.Errorf("%w: %s is not stored", ErrServer, name)
+}
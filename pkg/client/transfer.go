@@ -0,0 +1,410 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// partSuffix is added to the name of the file while it's downloaded, the download is resumed from it
+const partSuffix = ".part"
+
+// Progress is called while the file is transferred with the transferred and the total bytes, the total is -1
+// if it's unknown. Transferred bytes start from zero again if the upload is retried
+type Progress func(transferred, total int64)
+
+type TransferOption func(t *transfer)
+
+// WithProgress reports the progress of the transfer, the callback is called on every read or write,
+// so it should be fast
+func WithProgress(progress Progress) TransferOption {
+	return func(t *transfer) {
+		t.progress = progress
+	}
+}
+
+// WithExpectedETag uploads the file only if it still has the etag, so changes of other clients aren't
+// overwritten. The empty etag uploads the file only if it doesn't exist. Otherwise the upload fails
+// with ErrPreconditionFailed
+func WithExpectedETag(etag string) TransferOption {
+	return func(t *transfer) {
+		t.etag = &etag
+	}
+}
+
+type transfer struct {
+	progress Progress
+	etag     *string
+}
+
+func newTransfer(opts []TransferOption) transfer {
+	var t transfer
+	for _, opt := range opts {
+		opt(&t)
+	}
+
+	return t
+}
+
+func (t transfer) report(transferred, total int64) {
+	if t.progress != nil {
+		t.progress(transferred, total)
+	}
+}
+
+// Upload streams the content of the size to the file at the path relative to the directory, e.g. "a/b.txt",
+// overwriting the existing file. The content is read from the start again if the upload is retried
+func (c *Client) Upload(
+	ctx context.Context,
+	dir, name string,
+	content io.ReadSeeker,
+	size int64,
+	opts ...TransferOption,
+) (Resource, error) {
+	if size < 0 {
+		return Resource{}, errors.New("client: size of the upload is unknown")
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		return Resource{}, errors.New("client: name of the upload is empty")
+	}
+
+	t := newTransfer(opts)
+
+	// the form is built around the content, so the body has the length and isn't buffered
+	var buf bytes.Buffer
+
+	form := multipart.NewWriter(&buf)
+
+	// the server takes the base name of the form file, the path maps it to the full name
+	paths, err := json.Marshal(map[string]string{path.Base(name): name})
+	if err != nil {
+		return Resource{}, err
+	}
+
+	if err := form.WriteField("paths", string(paths)); err != nil {
+		return Resource{}, err
+	}
+
+	if t.etag != nil {
+		etags, err := json.Marshal(map[string]string{path.Base(name): *t.etag})
+		if err != nil {
+			return Resource{}, err
+		}
+
+		if err := form.WriteField("etags", string(etags)); err != nil {
+			return Resource{}, err
+		}
+	}
+
+	if _, err := form.CreateFormFile("files", path.Base(name)); err != nil {
+		return Resource{}, err
+	}
+
+	head := bytes.Clone(buf.Bytes())
+	buf.Reset()
+
+	if err := form.Close(); err != nil {
+		return Resource{}, err
+	}
+
+	tail := bytes.Clone(buf.Bytes())
+
+	r := request{
+		method:      http.MethodPost,
+		path:        "/api/resource",
+		query:       pathQuery(dir),
+		contentType: form.FormDataContentType(),
+		body: func() (io.Reader, int64, error) {
+			if _, err := content.Seek(0, io.SeekStart); err != nil {
+				return nil, 0, err
+			}
+
+			t.report(0, size)
+
+			body := io.MultiReader(
+				bytes.NewReader(head),
+				&progressReader{r: io.LimitReader(content, size), total: size, transfer: t},
+				bytes.NewReader(tail),
+			)
+
+			return body, int64(len(head)) + size + int64(len(tail)), nil
+		},
+	}
+
+	var stored []Resource
+	if err := c.call(ctx, r, nil, &stored); err != nil {
+		return Resource{}, err
+	}
+
+	if len(stored) == 0 {
+		return Resource{}, errNotStored(name)
+	}
+
+	return stored[0], nil
+}
+
+// UploadFile uploads the local file to the directory with the same name
+func (c *Client) UploadFile(ctx context.Context, dir, localPath string, opts ...TransferOption) (Resource, error) {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return Resource{}, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+
+	stat, err := f.Stat()
+	if err != nil {
+		return Resource{}, err
+	}
+
+	return c.Upload(ctx, dir, filepath.Base(localPath), f, stat.Size(), opts...)
+}
+
+// Download streams the file to the writer and returns the written bytes. The download is resumed
+// from the last written byte if the connection breaks. Directories are exported, the zip archive
+// of the export is downloaded when it's ready and can't be resumed
+func (c *Client) Download(ctx context.Context, path string, w io.Writer, opts ...TransferOption) (int64, error) {
+	return c.download(ctx, path, w, 0, nil, newTransfer(opts))
+}
+
+// DownloadFile downloads the file to the local path. The file is written next to it with the .part suffix
+// and renamed when it's complete, so the interrupted download is resumed by calling DownloadFile again
+func (c *Client) DownloadFile(ctx context.Context, path, localPath string, opts ...TransferOption) error {
+	part := localPath + partSuffix
+
+	f, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	restart := func() error {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+
+		_, err := f.Seek(0, io.SeekStart)
+
+		return err
+	}
+
+	_, err = c.download(ctx, path, f, offset, restart, newTransfer(opts))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(part, localPath)
+}
+
+// download writes the file from the offset and returns the written bytes. Restart truncates the written bytes
+// if the server sends the whole file instead of the range, it's nil if they can't be taken back
+func (c *Client) download(
+	ctx context.Context,
+	path string,
+	w io.Writer,
+	offset int64,
+	restart func() error,
+	t transfer,
+) (int64, error) {
+	written := offset
+
+	for attempt := 1; ; attempt++ {
+		r := request{method: http.MethodGet, path: "/api/resource/download", query: pathQuery(path)}
+		if written > 0 {
+			r.header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", written)}}
+		}
+
+		resp, err := c.do(ctx, r)
+		if err != nil {
+			// the range is past the end if the file had been downloaded before it was interrupted
+			if written > 0 && errors.Is(err, ErrRangeNotSatisfiable) {
+				if resource, statErr := c.Resource(ctx, path); statErr == nil && resource.Size == written {
+					t.report(written, written)
+
+					return written - offset, nil
+				}
+			}
+
+			return written - offset, err
+		}
+
+		// the folder is exported, its archive is downloaded when the export is ready
+		if resp.StatusCode == http.StatusAccepted {
+			var export Export
+			err := json.NewDecoder(resp.Body).Decode(&export)
+			closeBody(resp)
+
+			if err != nil {
+				return written - offset, fmt.Errorf("client: decode export of %s: %w", path, err)
+			}
+
+			if written > 0 {
+				if restart == nil {
+					return written - offset, errors.New("client: the download can't be resumed")
+				}
+
+				if err := restart(); err != nil {
+					return written - offset, err
+				}
+
+				offset = 0
+			}
+
+			return c.downloadExport(ctx, export, w, t)
+		}
+
+		total := resp.ContentLength
+
+		if written > 0 && resp.StatusCode != http.StatusPartialContent {
+			if restart == nil {
+				closeBody(resp)
+
+				return written - offset, errors.New("client: the download can't be resumed")
+			}
+
+			if err := restart(); err != nil {
+				closeBody(resp)
+
+				return written - offset, err
+			}
+
+			offset, written = 0, 0
+		} else if written > 0 {
+			total = contentRangeSize(resp.Header.Get("Content-Range"))
+		}
+
+		pw := &progressWriter{w: w, written: written, total: total, transfer: t}
+		_, err = io.Copy(pw, resp.Body)
+		_ = resp.Body.Close()
+
+		written = pw.written
+
+		if err == nil {
+			return written - offset, nil
+		}
+
+		// failed writes and cancelled downloads aren't resumed
+		if pw.err != nil || ctx.Err() != nil || attempt >= c.attempts {
+			return written - offset, err
+		}
+
+		if err := c.wait(ctx, attempt, 0); err != nil {
+			return written - offset, err
+		}
+	}
+}
+
+// DownloadExport waits for the export and streams its archive to the writer, the download isn't resumed
+func (c *Client) DownloadExport(
+	ctx context.Context,
+	export Export,
+	w io.Writer,
+	opts ...TransferOption,
+) (int64, error) {
+	return c.downloadExport(ctx, export, w, newTransfer(opts))
+}
+
+func (c *Client) downloadExport(ctx context.Context, export Export, w io.Writer, t transfer) (int64, error) {
+	export, err := c.WaitExport(ctx, export)
+	if err != nil {
+		return 0, err
+	}
+
+	link, err := url.Parse(export.DownloadURL)
+	if err != nil || export.DownloadURL == "" {
+		return 0, fmt.Errorf("client: export %d has no download link", export.Id)
+	}
+
+	// the link is signed, so it's requested without the session and relative to the base URL of the client
+	r := request{method: http.MethodGet, path: exportPath(export.Id) + "/download", query: link.Query(), public: true}
+
+	resp, err := c.do(ctx, r)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	pw := &progressWriter{w: w, total: resp.ContentLength, transfer: t}
+	_, err = io.Copy(pw, resp.Body)
+
+	return pw.written, err
+}
+
+// contentRangeSize returns the size of the file from "bytes start-end/size", -1 if it's unknown
+func contentRangeSize(header string) int64 {
+	_, size, ok := strings.Cut(header, "/")
+	if !ok {
+		return -1
+	}
+
+	n, err := strconv.ParseInt(size, 10, 64)
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+type progressReader struct {
+	r        io.Reader
+	read     int64
+	total    int64
+	transfer transfer
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.read += int64(n)
+		pr.transfer.report(pr.read, pr.total)
+	}
+
+	return n, err
+}
+
+// progressWriter counts written bytes and keeps the error of the writer apart from errors of the connection
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	transfer transfer
+	err      error
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	pw.err = err
+
+	if n > 0 {
+		pw.transfer.report(pw.written, pw.total)
+	}
+
+	return n, err
+}
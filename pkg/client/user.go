@@ -0,0 +1,243 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Me returns the profile of the user
+func (c *Client) Me(ctx context.Context) (Profile, error) {
+	var profile Profile
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/user/me"}, nil, &profile)
+
+	return profile, err
+}
+
+// ChangePassword changes the password, other sessions of the user are ended
+func (c *Client) ChangePassword(ctx context.Context, currentPassword, newPassword string) error {
+	body := struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}{currentPassword, newPassword}
+
+	r := request{method: http.MethodPatch, path: "/api/user/password", refreshCookie: true}
+
+	return c.call(ctx, r, &body, nil)
+}
+
+// ChangeEmail sends the confirmation link to the new email, it's changed by ConfirmEmailChange
+func (c *Client) ChangeEmail(ctx context.Context, email, password string) error {
+	r := request{method: http.MethodPatch, path: "/api/user/email"}
+
+	return c.call(ctx, r, &credentials{Email: email, Password: password}, nil)
+}
+
+// DeleteAccount deletes the account with all its files
+func (c *Client) DeleteAccount(ctx context.Context, password string) error {
+	body := struct {
+		Password string `json:"password"`
+	}{password}
+
+	return c.call(ctx, request{method: http.MethodDelete, path: "/api/user/me", noRetry: true}, &body, nil)
+}
+
+// Identities returns the linked accounts of identity providers
+func (c *Client) Identities(ctx context.Context) ([]Identity, error) {
+	var identities []Identity
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/user/identities"}, nil, &identities)
+
+	return identities, err
+}
+
+// LinkIdentity returns the URL of the provider, the identity is linked when the user signs in there
+func (c *Client) LinkIdentity(ctx context.Context, provider string) (string, error) {
+	var resp struct {
+		URL string `json:"url"`
+	}
+
+	r := request{method: http.MethodPost, path: "/api/user/identities/" + url.PathEscape(provider)}
+	if err := c.call(ctx, r, nil, &resp); err != nil {
+		return "", err
+	}
+
+	return resp.URL, nil
+}
+
+func (c *Client) UnlinkIdentity(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/api/user/identities/%d", id)}, nil, nil)
+}
+
+// SetPasswordLogin enables or disables sign in with the password, single sign-on is used then
+func (c *Client) SetPasswordLogin(ctx context.Context, enabled bool) error {
+	body := struct {
+		Enabled bool `json:"enabled"`
+	}{enabled}
+
+	return c.call(ctx, request{method: http.MethodPatch, path: "/api/user/password-login"}, &body, nil)
+}
+
+// Activity returns the page of the security and file events of the user
+func (c *Client) Activity(ctx context.Context, filter ActivityFilter) (ActivityPage, error) {
+	var page ActivityPage
+
+	r := request{method: http.MethodGet, path: "/api/user/activity", query: filter.query()}
+	err := c.call(ctx, r, nil, &page)
+
+	return page, err
+}
+
+// ExportActivity writes the events of the user in the format, csv or json
+func (c *Client) ExportActivity(ctx context.Context, w io.Writer, format string, filter ActivityFilter) error {
+	return c.export(ctx, "/api/user/activity/export", w, format, filter)
+}
+
+func (c *Client) export(ctx context.Context, path string, w io.Writer, format string, filter ActivityFilter) error {
+	query := filter.query()
+	query.Del("page")
+	query.Del("per_page")
+
+	if format != "" {
+		query.Set("format", format)
+	}
+
+	resp, err := c.do(ctx, request{method: http.MethodGet, path: path, query: query})
+	if err != nil {
+		return err
+	}
+	defer closeBody(resp)
+
+	_, err = io.Copy(w, resp.Body)
+
+	return err
+}
+
+func (f ActivityFilter) query() url.Values {
+	query := url.Values{}
+
+	set := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+
+	if f.UserId > 0 {
+		set("user_id", strconv.FormatInt(f.UserId, 10))
+	}
+
+	set("email", f.Email)
+	set("ip", f.Ip)
+	set("action", f.Action)
+	set("result", f.Result)
+
+	if !f.From.IsZero() {
+		set("from", f.From.Format(time.RFC3339))
+	}
+
+	if !f.To.IsZero() {
+		set("to", f.To.Format(time.RFC3339))
+	}
+
+	if f.Page > 0 {
+		set("page", strconv.Itoa(f.Page))
+	}
+
+	if f.PerPage > 0 {
+		set("per_page", strconv.Itoa(f.PerPage))
+	}
+
+	return query
+}
+
+// AccessTokens returns the personal access tokens of the user, without secrets
+func (c *Client) AccessTokens(ctx context.Context) ([]AccessToken, error) {
+	var tokens []AccessToken
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/user/tokens"}, nil, &tokens)
+
+	return tokens, err
+}
+
+// CreateAccessToken creates the personal access token, its secret is returned only here
+func (c *Client) CreateAccessToken(ctx context.Context, token AccessTokenRequest) (NewAccessToken, error) {
+	body := struct {
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		Folder    string   `json:"folder,omitempty"`
+		ExpiresAt string   `json:"expires_at,omitempty"`
+	}{Name: token.Name, Scopes: token.Scopes, Folder: token.Folder}
+
+	if !token.ExpiresAt.IsZero() {
+		body.ExpiresAt = token.ExpiresAt.Format(time.RFC3339)
+	}
+
+	var created NewAccessToken
+
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/user/tokens", noRetry: true}, &body, &created)
+
+	return created, err
+}
+
+func (c *Client) DeleteAccessToken(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/api/user/tokens/%d", id)}, nil, nil)
+}
+
+// S3Keys returns the access keys of the S3 gateway, without secrets
+func (c *Client) S3Keys(ctx context.Context) ([]S3Key, error) {
+	var keys []S3Key
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/user/s3-keys"}, nil, &keys)
+
+	return keys, err
+}
+
+// CreateS3Key creates the access key of the S3 gateway, its secret is returned only here
+func (c *Client) CreateS3Key(ctx context.Context, name string) (NewS3Key, error) {
+	body := struct {
+		Name string `json:"name"`
+	}{name}
+
+	var created NewS3Key
+
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/user/s3-keys", noRetry: true}, &body, &created)
+
+	return created, err
+}
+
+func (c *Client) DeleteS3Key(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/api/user/s3-keys/%d", id)}, nil, nil)
+}
+
+// SSHKeys returns the public keys of the SFTP server
+func (c *Client) SSHKeys(ctx context.Context) ([]SSHKey, error) {
+	var keys []SSHKey
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/user/ssh-keys"}, nil, &keys)
+
+	return keys, err
+}
+
+// AddSSHKey adds the public key in the authorized_keys format, the comment of the key is the name
+// if the name is empty
+func (c *Client) AddSSHKey(ctx context.Context, name, publicKey string) (SSHKey, error) {
+	body := struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"public_key"`
+	}{name, publicKey}
+
+	var key SSHKey
+
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/user/ssh-keys"}, &body, &key)
+
+	return key, err
+}
+
+func (c *Client) DeleteSSHKey(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: fmt.Sprintf("/api/user/ssh-keys/%d", id)}, nil, nil)
+}
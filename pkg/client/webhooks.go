@@ -0,0 +1,86 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Webhooks returns the webhooks of the user, without secrets
+func (c *Client) Webhooks(ctx context.Context) ([]Webhook, error) {
+	var webhooks []Webhook
+
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/user/webhooks"}, nil, &webhooks)
+
+	return webhooks, err
+}
+
+// CreateWebhook creates the webhook, the secret signing its deliveries is returned only here
+func (c *Client) CreateWebhook(ctx context.Context, webhook WebhookRequest) (NewWebhook, error) {
+	var created NewWebhook
+
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/user/webhooks", noRetry: true}, &webhook, &created)
+
+	return created, err
+}
+
+func (c *Client) DeleteWebhook(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: webhookPath(id)}, nil, nil)
+}
+
+// EnableWebhook enables the disabled webhook, its pending deliveries are attempted again
+func (c *Client) EnableWebhook(ctx context.Context, id int64) (Webhook, error) {
+	var webhook Webhook
+
+	err := c.call(ctx, request{method: http.MethodPost, path: webhookPath(id) + "/enable"}, nil, &webhook)
+
+	return webhook, err
+}
+
+// DisableWebhook stops deliveries to the webhook until it's enabled
+func (c *Client) DisableWebhook(ctx context.Context, id int64) (Webhook, error) {
+	var webhook Webhook
+
+	err := c.call(ctx, request{method: http.MethodPost, path: webhookPath(id) + "/disable"}, nil, &webhook)
+
+	return webhook, err
+}
+
+// WebhookDeliveries returns the page of deliveries of the webhook, newest first
+func (c *Client) WebhookDeliveries(ctx context.Context, id int64, page, perPage int) (WebhookDeliveriesPage, error) {
+	values := url.Values{}
+	if page > 0 {
+		values.Set("page", strconv.Itoa(page))
+	}
+
+	if perPage > 0 {
+		values.Set("per_page", strconv.Itoa(perPage))
+	}
+
+	var deliveries WebhookDeliveriesPage
+
+	r := request{method: http.MethodGet, path: webhookPath(id) + "/deliveries", query: values}
+	err := c.call(ctx, r, nil, &deliveries)
+
+	return deliveries, err
+}
+
+// RedeliverWebhook queues the payload of the delivery again, the event keeps its id
+func (c *Client) RedeliverWebhook(ctx context.Context, id, deliveryId int64) (WebhookDelivery, error) {
+	var delivery WebhookDelivery
+
+	r := request{
+		method:  http.MethodPost,
+		path:    fmt.Sprintf("%s/deliveries/%d/redeliver", webhookPath(id), deliveryId),
+		noRetry: true,
+	}
+	err := c.call(ctx, r, nil, &delivery)
+
+	return delivery, err
+}
+
+func webhookPath(id int64) string {
+	return fmt.Sprintf("/api/user/webhooks/%d", id)
+}